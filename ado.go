@@ -0,0 +1,441 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/core"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/webapi"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+// adoPool é o único dono da conexão e dos clientes do SDK. Handlers, prefetch
+// e tarefas em segundo plano pedem clientes ao pool, que limita a quantidade
+// total de chamadas simultâneas ao Azure DevOps e contabiliza o uso por chamador.
+type adoPool struct {
+	connection *azuredevops.Connection
+	sem        chan struct{}
+
+	clientsMu sync.Mutex
+	work      work.Client
+	wit       workitemtracking.Client
+	core      core.Client
+
+	// Chamado em segundo plano quando o Azure DevOps responde 401/403, para
+	// reler o PAT; nil desativa
+	onAuthError func()
+	// Chamado em segundo plano depois de reconnect descartar os clientes,
+	// para aquecê-los de novo (PREFETCH); nil desativa
+	onReconnect func()
+
+	statsMu sync.Mutex
+	stats   map[string]*AdoCallerStats
+}
+
+// Estatísticas de uso do Azure DevOps por chamador
+type AdoCallerStats struct {
+	Caller    string        `json:"caller"`
+	Calls     int           `json:"calls"`
+	Errors    int           `json:"errors"`
+	InFlight  int           `json:"inFlight"`
+	TotalWait time.Duration `json:"totalWaitNs"`
+	TotalTime time.Duration `json:"totalTimeNs"`
+}
+
+func newAdoPool(connection *azuredevops.Connection, maxConcurrency int) *adoPool {
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+	return &adoPool{
+		connection: connection,
+		sem:        make(chan struct{}, maxConcurrency),
+		stats:      make(map[string]*AdoCallerStats),
+	}
+}
+
+// Função para obter o cliente de work (sprints, capacidades) em nome de um
+// chamador. clientsMu só protege a leitura e a publicação do cliente: a espera
+// pelo semáforo e a criação pelo SDK acontecem fora dele, para um chamador
+// lento ou cancelado não travar os outros. Se duas criações correrem juntas
+// vale a primeira publicada; um cliente criado com uma conexão já trocada por
+// reconnect atende só este chamador.
+func (p *adoPool) Work(ctx context.Context, caller string) (work.Client, error) {
+	p.clientsMu.Lock()
+	client, connection := p.work, p.connection
+	p.clientsMu.Unlock()
+	if client == nil {
+		release, err := p.acquire(ctx, caller)
+		if err != nil {
+			return nil, err
+		}
+		client, err = work.NewClient(ctx, connection)
+		release(err)
+		if err != nil {
+			return nil, err
+		}
+		p.clientsMu.Lock()
+		if p.connection == connection {
+			if p.work != nil {
+				client = p.work
+			} else {
+				p.work = client
+			}
+		}
+		p.clientsMu.Unlock()
+	}
+	return &boundedWorkClient{Client: client, pool: p, caller: caller}, nil
+}
+
+// Função para obter o cliente de work item tracking em nome de um chamador
+func (p *adoPool) WorkItems(ctx context.Context, caller string) (workitemtracking.Client, error) {
+	p.clientsMu.Lock()
+	client, connection := p.wit, p.connection
+	p.clientsMu.Unlock()
+	if client == nil {
+		release, err := p.acquire(ctx, caller)
+		if err != nil {
+			return nil, err
+		}
+		client, err = workitemtracking.NewClient(ctx, connection)
+		release(err)
+		if err != nil {
+			return nil, err
+		}
+		p.clientsMu.Lock()
+		if p.connection == connection {
+			if p.wit != nil {
+				client = p.wit
+			} else {
+				p.wit = client
+			}
+		}
+		p.clientsMu.Unlock()
+	}
+	return &boundedWitClient{Client: client, pool: p, caller: caller}, nil
+}
+
+// Função para obter o cliente core (times e membros) em nome de um chamador
+func (p *adoPool) Core(ctx context.Context, caller string) (core.Client, error) {
+	p.clientsMu.Lock()
+	client, connection := p.core, p.connection
+	p.clientsMu.Unlock()
+	if client == nil {
+		release, err := p.acquire(ctx, caller)
+		if err != nil {
+			return nil, err
+		}
+		client, err = core.NewClient(ctx, connection)
+		release(err)
+		if err != nil {
+			return nil, err
+		}
+		p.clientsMu.Lock()
+		if p.connection == connection {
+			if p.core != nil {
+				client = p.core
+			} else {
+				p.core = client
+			}
+		}
+		p.clientsMu.Unlock()
+	}
+	return &boundedCoreClient{Client: client, pool: p, caller: caller}, nil
+}
+
+// Função para ocupar uma vaga do semáforo global. A função devolvida libera a
+// vaga e registra o resultado da chamada nas estatísticas do chamador.
+func (p *adoPool) acquire(ctx context.Context, caller string) (func(error), error) {
+	waitStart := time.Now()
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, wrapAdoError(ctx.Err(), "adoPool.acquire", "caller=%s", caller)
+	}
+
+	callStart := time.Now()
+	p.statsMu.Lock()
+	stats := p.callerStats(caller)
+	stats.Calls++
+	stats.InFlight++
+	stats.TotalWait += callStart.Sub(waitStart)
+	p.statsMu.Unlock()
+
+	return func(err error) {
+		<-p.sem
+		p.statsMu.Lock()
+		defer p.statsMu.Unlock()
+		stats := p.callerStats(caller)
+		stats.InFlight--
+		stats.TotalTime += time.Since(callStart)
+		if err != nil {
+			stats.Errors++
+			if p.onAuthError != nil && isAdoAuthError(err) {
+				// Em goroutine, para não segurar a vaga durante a releitura
+				go p.onAuthError()
+			}
+		}
+	}, nil
+}
+
+// Função para trocar a conexão (por exemplo após a rotação do PAT). Os
+// clientes guardados carregam a autorização antiga, então são descartados e
+// recriados na próxima chamada; wrappers já entregues terminam com os antigos.
+func (p *adoPool) reconnect(connection *azuredevops.Connection) {
+	p.clientsMu.Lock()
+	p.connection = connection
+	p.work, p.wit, p.core = nil, nil, nil
+	onReconnect := p.onReconnect
+	p.clientsMu.Unlock()
+	if onReconnect != nil {
+		go onReconnect()
+	}
+}
+
+// Função para obter (criando se preciso) as estatísticas de um chamador. Chamar com statsMu travado.
+func (p *adoPool) callerStats(caller string) *AdoCallerStats {
+	stats, ok := p.stats[caller]
+	if !ok {
+		stats = &AdoCallerStats{Caller: caller}
+		p.stats[caller] = stats
+	}
+	return stats
+}
+
+// Função para obter uma cópia das estatísticas, ordenada por chamador
+func (p *adoPool) Stats() []AdoCallerStats {
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	result := make([]AdoCallerStats, 0, len(p.stats))
+	for _, stats := range p.stats {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Caller < result[j].Caller
+	})
+	return result
+}
+
+// boundedWorkClient passa pelo semáforo do pool em cada chamada usada pelo serviço
+type boundedWorkClient struct {
+	work.Client
+	pool   *adoPool
+	caller string
+}
+
+func (c *boundedWorkClient) GetTeamIterations(ctx context.Context, args work.GetTeamIterationsArgs) (*[]work.TeamSettingsIteration, error) {
+	release, err := c.pool.acquire(ctx, c.caller)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Client.GetTeamIterations(ctx, args)
+	release(err)
+	return result, err
+}
+
+func (c *boundedWorkClient) GetTeamIteration(ctx context.Context, args work.GetTeamIterationArgs) (*work.TeamSettingsIteration, error) {
+	release, err := c.pool.acquire(ctx, c.caller)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Client.GetTeamIteration(ctx, args)
+	release(err)
+	return result, err
+}
+
+func (c *boundedWorkClient) GetIterationWorkItems(ctx context.Context, args work.GetIterationWorkItemsArgs) (*work.IterationWorkItems, error) {
+	release, err := c.pool.acquire(ctx, c.caller)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Client.GetIterationWorkItems(ctx, args)
+	release(err)
+	return result, err
+}
+
+func (c *boundedWorkClient) GetCapacitiesWithIdentityRefAndTotals(ctx context.Context, args work.GetCapacitiesWithIdentityRefAndTotalsArgs) (*work.TeamCapacity, error) {
+	release, err := c.pool.acquire(ctx, c.caller)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Client.GetCapacitiesWithIdentityRefAndTotals(ctx, args)
+	release(err)
+	return result, err
+}
+
+func (c *boundedWorkClient) GetTeamSettings(ctx context.Context, args work.GetTeamSettingsArgs) (*work.TeamSetting, error) {
+	release, err := c.pool.acquire(ctx, c.caller)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Client.GetTeamSettings(ctx, args)
+	release(err)
+	return result, err
+}
+
+func (c *boundedWorkClient) GetBacklogLevelWorkItems(ctx context.Context, args work.GetBacklogLevelWorkItemsArgs) (*work.BacklogLevelWorkItems, error) {
+	release, err := c.pool.acquire(ctx, c.caller)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Client.GetBacklogLevelWorkItems(ctx, args)
+	release(err)
+	return result, err
+}
+
+func (c *boundedWorkClient) UpdateCapacityWithIdentityRef(ctx context.Context, args work.UpdateCapacityWithIdentityRefArgs) (*work.TeamMemberCapacityIdentityRef, error) {
+	release, err := c.pool.acquire(ctx, c.caller)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Client.UpdateCapacityWithIdentityRef(ctx, args)
+	release(err)
+	return result, err
+}
+
+func (c *boundedWorkClient) GetTeamDaysOff(ctx context.Context, args work.GetTeamDaysOffArgs) (*work.TeamSettingsDaysOff, error) {
+	release, err := c.pool.acquire(ctx, c.caller)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Client.GetTeamDaysOff(ctx, args)
+	release(err)
+	return result, err
+}
+
+// boundedWitClient passa pelo semáforo do pool em cada chamada usada pelo serviço
+type boundedWitClient struct {
+	workitemtracking.Client
+	pool   *adoPool
+	caller string
+}
+
+func (c *boundedWitClient) GetWorkItems(ctx context.Context, args workitemtracking.GetWorkItemsArgs) (*[]workitemtracking.WorkItem, error) {
+	release, err := c.pool.acquire(ctx, c.caller)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Client.GetWorkItems(ctx, args)
+	release(err)
+	return result, err
+}
+
+func (c *boundedWitClient) GetWorkItem(ctx context.Context, args workitemtracking.GetWorkItemArgs) (*workitemtracking.WorkItem, error) {
+	release, err := c.pool.acquire(ctx, c.caller)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Client.GetWorkItem(ctx, args)
+	release(err)
+	return result, err
+}
+
+func (c *boundedWitClient) QueryByWiql(ctx context.Context, args workitemtracking.QueryByWiqlArgs) (*workitemtracking.WorkItemQueryResult, error) {
+	release, err := c.pool.acquire(ctx, c.caller)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Client.QueryByWiql(ctx, args)
+	release(err)
+	return result, err
+}
+
+func (c *boundedWitClient) UpdateWorkItem(ctx context.Context, args workitemtracking.UpdateWorkItemArgs) (*workitemtracking.WorkItem, error) {
+	release, err := c.pool.acquire(ctx, c.caller)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Client.UpdateWorkItem(ctx, args)
+	release(err)
+	return result, err
+}
+
+func (c *boundedWitClient) CreateWorkItem(ctx context.Context, args workitemtracking.CreateWorkItemArgs) (*workitemtracking.WorkItem, error) {
+	release, err := c.pool.acquire(ctx, c.caller)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Client.CreateWorkItem(ctx, args)
+	release(err)
+	return result, err
+}
+
+func (c *boundedWitClient) AddComment(ctx context.Context, args workitemtracking.AddCommentArgs) (*workitemtracking.Comment, error) {
+	release, err := c.pool.acquire(ctx, c.caller)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Client.AddComment(ctx, args)
+	release(err)
+	return result, err
+}
+
+func (c *boundedWitClient) GetWorkItemFields(ctx context.Context, args workitemtracking.GetWorkItemFieldsArgs) (*[]workitemtracking.WorkItemField2, error) {
+	release, err := c.pool.acquire(ctx, c.caller)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Client.GetWorkItemFields(ctx, args)
+	release(err)
+	return result, err
+}
+
+func (c *boundedWitClient) GetWorkItemTypes(ctx context.Context, args workitemtracking.GetWorkItemTypesArgs) (*[]workitemtracking.WorkItemType, error) {
+	release, err := c.pool.acquire(ctx, c.caller)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Client.GetWorkItemTypes(ctx, args)
+	release(err)
+	return result, err
+}
+
+func (c *boundedWitClient) GetRevisions(ctx context.Context, args workitemtracking.GetRevisionsArgs) (*[]workitemtracking.WorkItem, error) {
+	release, err := c.pool.acquire(ctx, c.caller)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Client.GetRevisions(ctx, args)
+	release(err)
+	return result, err
+}
+
+func (c *boundedWitClient) GetUpdates(ctx context.Context, args workitemtracking.GetUpdatesArgs) (*[]workitemtracking.WorkItemUpdate, error) {
+	release, err := c.pool.acquire(ctx, c.caller)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Client.GetUpdates(ctx, args)
+	release(err)
+	return result, err
+}
+
+// boundedCoreClient passa pelo semáforo do pool em cada chamada usada pelo serviço
+type boundedCoreClient struct {
+	core.Client
+	pool   *adoPool
+	caller string
+}
+
+func (c *boundedCoreClient) GetTeamMembersWithExtendedProperties(ctx context.Context, args core.GetTeamMembersWithExtendedPropertiesArgs) (*[]webapi.TeamMember, error) {
+	release, err := c.pool.acquire(ctx, c.caller)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Client.GetTeamMembersWithExtendedProperties(ctx, args)
+	release(err)
+	return result, err
+}
+
+func (c *boundedCoreClient) GetTeams(ctx context.Context, args core.GetTeamsArgs) (*[]core.WebApiTeam, error) {
+	release, err := c.pool.acquire(ctx, c.caller)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.Client.GetTeams(ctx, args)
+	release(err)
+	return result, err
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+// Roda com go test -race: muitos chamadores disputando poucas vagas do pool
+func TestAdoPoolBoundsConcurrency(t *testing.T) {
+	const (
+		limit      = 3
+		goroutines = 64
+		callsEach  = 20
+	)
+	var inFlight, maxInFlight int64
+	fake := newFakeWitClient()
+	fake.getWorkItems = func(ctx context.Context, args workitemtracking.GetWorkItemsArgs) (*[]workitemtracking.WorkItem, error) {
+		current := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		for {
+			seen := atomic.LoadInt64(&maxInFlight)
+			if current <= seen || atomic.CompareAndSwapInt64(&maxInFlight, seen, current) {
+				break
+			}
+		}
+		time.Sleep(100 * time.Microsecond)
+		if (*args.Ids)[0]%5 == 0 {
+			return nil, errors.New("falha simulada")
+		}
+		return &[]workitemtracking.WorkItem{}, nil
+	}
+	pool := newAdoPool(nil, limit)
+	pool.wit = fake
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			caller := fmt.Sprintf("caller-%d", g%4)
+			client, err := pool.WorkItems(context.Background(), caller)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for i := 0; i < callsEach; i++ {
+				ids := []int{i}
+				client.GetWorkItems(context.Background(), workitemtracking.GetWorkItemsArgs{Ids: &ids})
+			}
+			// Leitura das estatísticas concorrente com as chamadas
+			pool.Stats()
+		}(g)
+	}
+	wg.Wait()
+
+	if maxInFlight > limit {
+		t.Errorf("%d chamadas simultâneas, limite %d", maxInFlight, limit)
+	}
+	totalCalls, totalErrors := 0, 0
+	for _, stats := range pool.Stats() {
+		if stats.InFlight != 0 {
+			t.Errorf("%s terminou com InFlight=%d", stats.Caller, stats.InFlight)
+		}
+		totalCalls += stats.Calls
+		totalErrors += stats.Errors
+	}
+	if totalCalls != goroutines*callsEach {
+		t.Errorf("Calls = %d, quer %d", totalCalls, goroutines*callsEach)
+	}
+	if want := goroutines * 4; totalErrors != want {
+		t.Errorf("Errors = %d, quer %d", totalErrors, want)
+	}
+}
+
+func TestAdoPoolAcquireHonorsContext(t *testing.T) {
+	pool := newAdoPool(nil, 1)
+	release, err := pool.acquire(context.Background(), "holder")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pool.acquire(ctx, "waiter"); !errors.Is(err, ErrAdoUnavailable) {
+		t.Errorf("acquire com o pool cheio = %v, quer ErrAdoUnavailable", err)
+	}
+
+	release(nil)
+	release2, err := pool.acquire(context.Background(), "waiter")
+	if err != nil {
+		t.Fatalf("acquire depois de liberar a vaga: %v", err)
+	}
+	release2(nil)
+}
+
+func TestAdoPoolClientCreationDoesNotBlockCachedClients(t *testing.T) {
+	pool := newAdoPool(nil, 1)
+	pool.wit = newFakeWitClient()
+	release, err := pool.acquire(context.Background(), "holder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release(nil)
+
+	// Work ainda não existe e fica esperando a vaga do semáforo
+	ctx, cancel := context.WithCancel(context.Background())
+	waiting := make(chan error, 1)
+	go func() {
+		_, err := pool.Work(ctx, "criando")
+		waiting <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	got := make(chan error, 1)
+	go func() {
+		_, err := pool.WorkItems(context.Background(), "em cache")
+		got <- err
+	}()
+	select {
+	case err := <-got:
+		if err != nil {
+			t.Fatalf("WorkItems em cache: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WorkItems em cache ficou preso atrás da criação do cliente de work")
+	}
+
+	cancel()
+	select {
+	case err := <-waiting:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Work cancelado = %v, quer context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Work cancelado não retornou")
+	}
+	if pool.work != nil {
+		t.Error("criação cancelada não deveria publicar cliente")
+	}
+}
+
+func TestPrefetchCurrentSprint(t *testing.T) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	iteration := fakeIteration("Sprint 7", today.AddDate(0, 0, -3), today.AddDate(0, 0, 10), work.TimeFrameValues.Current)
+	workClient := &fakeWorkClient{
+		iterations: []work.TeamSettingsIteration{iteration},
+		relations:  map[uuid.UUID][]workitemtracking.WorkItemLink{*iteration.Id: {fakeLink(0, 1)}},
+	}
+	witClient := newFakeWitClient(fakeStory(1, "História", "Active"))
+	pool := newFakePool(workClient, witClient)
+	pool.core = &fakeCoreClient{}
+	cfg := &Config{Project: "Projeto", Team: "Time", TeamName: "Time", Location: time.UTC, RequestTimeout: time.Minute}
+
+	prefetchCurrentSprint(pool, cfg)
+	want := []string{"GetTeamSettings", "GetTeamIterations", "GetIterationWorkItems", "GetCapacitiesWithIdentityRefAndTotals"}
+	if !reflect.DeepEqual(workClient.calls, want) {
+		t.Errorf("chamadas = %v, quer %v", workClient.calls, want)
+	}
+	if len(witClient.getWorkItemsCalls) != 1 {
+		t.Errorf("GetWorkItems chamado %d vezes, quer 1", len(witClient.getWorkItemsCalls))
+	}
+	for _, stats := range pool.Stats() {
+		if stats.Caller == "prefetch" && stats.Calls != 5 {
+			t.Errorf("chamadas do prefetch no pool = %d, quer 5", stats.Calls)
+		}
+	}
+}
+
+func TestAdoPoolReconnectWarmsAgain(t *testing.T) {
+	pool := newFakePool(nil, nil)
+	warmed := make(chan struct{})
+	pool.onReconnect = func() { close(warmed) }
+	pool.reconnect(nil)
+	select {
+	case <-warmed:
+	case <-time.After(time.Second):
+		t.Fatal("reconnect não chamou onReconnect")
+	}
+}
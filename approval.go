@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Situações da aprovação de uma geração com REQUIRE_APPROVAL
+const (
+	approvalPending  = "pending"
+	approvalApproved = "approved"
+	approvalExpired  = "expired"
+)
+
+// Ações registradas na auditoria de uma execução
+const (
+	auditApprovalRequested = "approval-requested"
+	auditApproved          = "approved"
+	auditApprovalRejected  = "approval-rejected"
+	auditApprovalExpired   = "approval-expired"
+	auditExecutionFailed   = "execution-failed"
+)
+
+// Erros da aprovação, respondidos por POST /runs/{id}/approve
+var (
+	errApprovalNotPending  = errors.New("execução não está aguardando aprovação")
+	errApprovalExpired     = errors.New("prazo de aprovação vencido")
+	errApprovalSameKey     = errors.New("a aprovação precisa de outra chave administrativa, diferente da que pediu a geração")
+	errApprovalPlanChanged = errors.New("o plano mudou desde o pedido")
+)
+
+// Pedido e aprovação de uma geração com REQUIRE_APPROVAL. As chaves são
+// identificadas por adminKeyID; o nome de quem aprovou vem de X-Requested-By.
+type RunApproval struct {
+	Status       string     `json:"status"`
+	RequestedKey string     `json:"requestedKey"`
+	RequestedAt  time.Time  `json:"requestedAt"`
+	ExpiresAt    time.Time  `json:"expiresAt"`
+	ApprovedKey  string     `json:"approvedKey,omitempty"`
+	ApprovedBy   string     `json:"approvedBy,omitempty"`
+	ApprovedAt   *time.Time `json:"approvedAt,omitempty"`
+	// Parâmetros e corpo do pedido, repetidos com escrita na aprovação
+	Query string `json:"query"`
+	Body  string `json:"body,omitempty"`
+	// Gravações previstas no pedido (ID, data atual e nova). A aprovação
+	// recalcula a lista e só grava se ela for igual a esta.
+	Plan []DueDateRunItem `json:"plan"`
+}
+
+// Ação registrada na auditoria de uma execução
+type RunAuditEntry struct {
+	Action      string    `json:"action"`
+	At          time.Time `json:"at"`
+	Key         string    `json:"key,omitempty"`
+	RequestedBy string    `json:"requestedBy,omitempty"`
+	RemoteAddr  string    `json:"remoteAddr,omitempty"`
+	Detail      string    `json:"detail,omitempty"`
+}
+
+// Função para obter a situação da aprovação no momento now: pendente depois
+// do prazo é expired
+func (a *RunApproval) statusAt(now time.Time) string {
+	if a.Status == approvalPending && now.After(a.ExpiresAt) {
+		return approvalExpired
+	}
+	return a.Status
+}
+
+// Função para montar a entrada de auditoria de uma ação feita pela requisição
+func auditEntry(action string, r *http.Request, at time.Time, detail string) RunAuditEntry {
+	caller := runCallerFromRequest(r)
+	entry := RunAuditEntry{Action: action, At: at, Key: adminKeyID(r.Header.Get("X-Admin-Key")), RequestedBy: caller.RequestedBy, RemoteAddr: caller.RemoteAddr, Detail: detail}
+	log.Printf("[DEBUG] Auditoria: %s por %s (%s) em %s", action, entry.Key, entry.RequestedBy, at.Format(time.RFC3339))
+	return entry
+}
+
+// Chave de contexto com o ID da geração aprovada que está sendo executada
+type approvedRunKey struct{}
+
+// Função para obter a geração aprovada de uma requisição repetida por
+// POST /runs/{id}/approve; vazio nas outras
+func approvedRunFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(approvedRunKey{}).(string)
+	return id
+}
+
+// Função para registrar uma geração que aguarda aprovação: o relatório é o
+// dry-run do pedido e fica em /runs como pendente até o prazo
+func (s *runStore) recordPendingGeneration(report GenerationReport, team string, r *http.Request, approval RunApproval) {
+	caller := runCallerFromRequest(r)
+	// O relatório guarda a aprovação como estava no pedido
+	requested := approval
+	report.Approval = &requested
+	s.add(&DueDateRun{
+		ID:        report.RunID,
+		Source:    runSourceGenerate,
+		CreatedAt: report.FinishedAt,
+		Sprint:    report.Sprint,
+		SprintID:  report.SprintID,
+		Team:      team,
+		Field:     report.DueDateField,
+		DryRun:    true,
+		Caller:    &caller,
+		Items:     []DueDateRunItem{},
+		Report:    &report,
+		Approval:  &approval,
+		Audit:     []RunAuditEntry{auditEntry(auditApprovalRequested, r, approval.RequestedAt, "")},
+	})
+}
+
+// Função para aprovar uma geração pendente com a chave da requisição. A
+// aprovação é marcada antes da execução, para duas aprovações simultâneas não
+// gravarem duas vezes.
+func (s *runStore) approve(id string, r *http.Request, now time.Time) (DueDateRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[id]
+	if !ok || run.Approval == nil || run.Approval.Status != approvalPending {
+		return DueDateRun{}, errApprovalNotPending
+	}
+	entry := auditEntry(auditApproved, r, now, "")
+	switch {
+	case run.Approval.statusAt(now) == approvalExpired:
+		run.Approval.Status = approvalExpired
+		entry.Action, entry.Detail = auditApprovalExpired, "prazo vencido em "+run.Approval.ExpiresAt.Format(time.RFC3339)
+		run.Audit = append(run.Audit, entry)
+		s.persist()
+		return DueDateRun{}, errApprovalExpired
+	case entry.Key == run.Approval.RequestedKey:
+		entry.Action, entry.Detail = auditApprovalRejected, errApprovalSameKey.Error()
+		run.Audit = append(run.Audit, entry)
+		s.persist()
+		return DueDateRun{}, errApprovalSameKey
+	}
+	run.Approval.Status = approvalApproved
+	run.Approval.ApprovedKey, run.Approval.ApprovedBy, run.Approval.ApprovedAt = entry.Key, entry.RequestedBy, &now
+	run.Audit = append(run.Audit, entry)
+	s.persist()
+	return run.copy(now), nil
+}
+
+// Função para devolver a geração aprovada a pendente quando a execução não
+// chegou a gravar (sprint em uso, erro do Azure DevOps, ...), para que possa
+// ser aprovada de novo dentro do prazo
+func (s *runStore) reopenApproval(id string, r *http.Request, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[id]
+	if !ok || run.Approval == nil || !run.DryRun {
+		return
+	}
+	run.Approval.Status = approvalPending
+	run.Approval.ApprovedKey, run.Approval.ApprovedBy, run.Approval.ApprovedAt = "", "", nil
+	run.Audit = append(run.Audit, auditEntry(auditExecutionFailed, r, time.Now().UTC(), fmt.Sprintf("geração aprovada respondeu %d", status)))
+	s.persist()
+}
+
+// Função para listar as gravações de um plano sem gravar: cada User Story e,
+// com ?cascade=tasks, cada task cuja data mudaria, com as mesmas regras do
+// dueDateWriter. É o que o pedido mostra e o que a aprovação pode gravar.
+func plannedWrites(plans []generationPlan, current map[int]*time.Time, childTasks map[int][]WorkItem, currentTask map[int]*time.Time, policy string, excludeTags []string, loc *time.Location) []DueDateRunItem {
+	writes := []DueDateRunItem{}
+	for _, plan := range plans {
+		story := plan.Story
+		if plan.DueDate == nil || keepExistingDate(policy, story, *plan.DueDate) {
+			continue
+		}
+		if !sameDueDay(current[story.ID], plan.DueDate, loc) {
+			writes = append(writes, DueDateRunItem{ID: story.ID, PreviousDueDate: current[story.ID], NewDueDate: plan.DueDate})
+		}
+		for _, task := range childTasks[story.ID] {
+			if _, found := matchTag(task.tags, excludeTags); found || sameDueDay(currentTask[task.ID], plan.DueDate, loc) || keepExistingDate(policy, task, *plan.DueDate) {
+				continue
+			}
+			writes = append(writes, DueDateRunItem{ID: task.ID, PreviousDueDate: currentTask[task.ID], NewDueDate: plan.DueDate})
+		}
+	}
+	return writes
+}
+
+// Função para comparar as gravações aprovadas com as recalculadas na
+// aprovação. Devolve, em ordem, os IDs que entraram, saíram ou mudaram de
+// data atual ou nova.
+func changedWrites(approved, planned []DueDateRunItem) []int {
+	byID := make(map[int]DueDateRunItem, len(approved))
+	for _, item := range approved {
+		byID[item.ID] = item
+	}
+	var changed []int
+	for _, item := range planned {
+		previous, ok := byID[item.ID]
+		delete(byID, item.ID)
+		if !ok || !sameDueDate(previous.PreviousDueDate, item.PreviousDueDate) || !sameDueDate(previous.NewDueDate, item.NewDueDate) {
+			changed = append(changed, item.ID)
+		}
+	}
+	for id := range byID {
+		changed = append(changed, id)
+	}
+	sort.Ints(changed)
+	return changed
+}
+
+// Função para montar o aviso de uma geração aguardando aprovação
+func pendingApprovalEvent(report GenerationReport, expiresAt time.Time) Event {
+	return Event{
+		Type:     EventRunPendingApproval,
+		Time:     report.FinishedAt,
+		Sprint:   report.Sprint,
+		SprintID: report.SprintID,
+		RunID:    report.RunID,
+		Text: fmt.Sprintf("Geração de datas na sprint '%s' aguardando aprovação até %s: %d previstos (execução %s; aprove com POST /runs/%s/approve usando outra chave)",
+			report.Sprint, expiresAt.Format("2006-01-02 15:04 MST"), report.Planned, report.RunID, report.RunID),
+	}
+}
+
+// approvalWriter guarda o status devolvido pela geração aprovada
+type approvalWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (aw *approvalWriter) WriteHeader(code int) {
+	if aw.status == 0 {
+		aw.status = code
+	}
+	aw.ResponseWriter.WriteHeader(code)
+}
+
+func (aw *approvalWriter) Write(p []byte) (int, error) {
+	if aw.status == 0 {
+		aw.status = http.StatusOK
+	}
+	return aw.ResponseWriter.Write(p)
+}
+
+// Handler de POST /runs/{id}/approve: aprova uma geração pendente com uma
+// chave diferente da que a pediu e executa a geração com os mesmos
+// parâmetros, agora com escrita. A geração recalcula o plano e responde 409
+// sem gravar quando ele difere do pedido (Approval.Plan); nesse caso, e em
+// outras falhas antes de gravar, a execução volta a aguardar aprovação. A
+// resposta é a da geração.
+func handleRunApprove(cfg *Config, runs *runStore, generate http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			jsonError(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		runID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/runs/"), "/approve")
+		run, ok := runs.get(runID)
+		if !ok || !run.ofTeam(cfg.Team) {
+			jsonError(w, fmt.Sprintf("Execução '%s' não encontrada", runID), http.StatusNotFound)
+			return
+		}
+		run, err := runs.approve(runID, r, time.Now().UTC())
+		switch {
+		case errors.Is(err, errApprovalExpired):
+			jsonError(w, fmt.Sprintf("Execução '%s': %v; peça a geração de novo", runID, err), http.StatusGone)
+			return
+		case errors.Is(err, errApprovalSameKey):
+			jsonError(w, fmt.Sprintf("Execução '%s': %v", runID, err), http.StatusForbidden)
+			return
+		case err != nil:
+			jsonError(w, fmt.Sprintf("Execução '%s': %v", runID, err), http.StatusConflict)
+			return
+		}
+		log.Printf("[DEBUG] Geração %s na sprint '%s' aprovada por %s; executando", runID, run.Sprint, run.Approval.ApprovedKey)
+
+		request := r.Clone(context.WithValue(r.Context(), approvedRunKey{}, runID))
+		request.URL.Path = "/generate-due-dates"
+		request.URL.RawQuery = run.Approval.Query
+		request.Body = io.NopCloser(strings.NewReader(run.Approval.Body))
+		request.ContentLength = int64(len(run.Approval.Body))
+		request.Header.Del(idempotencyHeader)
+		writer := &approvalWriter{ResponseWriter: w}
+		generate(writer, request)
+		if writer.status != http.StatusOK && writer.status != http.StatusMultiStatus {
+			log.Printf("[WARN] Geração aprovada %s respondeu %d; volta a aguardar aprovação", runID, writer.status)
+			runs.reopenApproval(runID, r, writer.status)
+		}
+	}
+}
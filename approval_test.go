@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+// Função para montar a geração de duas User Stories com REQUIRE_APPROVAL e
+// as chaves de Ana e Bruno
+func approvalFixture(t *testing.T) (*fakeWitClient, *Config, *runStore, *notifierSet, http.HandlerFunc, http.HandlerFunc) {
+	t.Helper()
+	iteration := fakeIteration("Sprint 7", day(2030, 3, 4), day(2030, 3, 15), work.TimeFrameValues.Future)
+	workClient := &fakeWorkClient{
+		iterations: []work.TeamSettingsIteration{iteration},
+		relations:  map[uuid.UUID][]workitemtracking.WorkItemLink{*iteration.Id: {fakeLink(0, 1), fakeLink(0, 2)}},
+	}
+	witClient := newFakeWitClient(fakeStory(1, "História", "Active"), fakeStory(2, "História", "Active"))
+	cfg := &Config{Project: "Projeto", Team: "Time", TeamName: "Time", WorkItemTypes: []string{"User Story"}, ChildWorkItemTypes: []string{"Task"},
+		DefaultCapacityPerDay: 8, HoursPerStoryPoint: 8, FocusFactor: 1, DueDateWriteField: dueDateField, DueDateCommentTemplate: defaultDueDateCommentTemplate,
+		Location: time.UTC, AdminAPIKey: &secret{name: "ADMIN_API_KEY", value: "chave-ana, chave-bruno"}, RequireApproval: true, ApprovalTTL: time.Hour}
+	store, err := newRunStore("", runRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier := &recordingNotifier{}
+	events := &notifierSet{notifiers: []Notifier{notifier}, backoff: time.Millisecond}
+	pool := newFakePool(workClient, witClient)
+	idempotency := newIdempotencyStore(time.Hour)
+	generate := requireAdmin(cfg, handleGenerateDueDates(pool, cfg, store, idempotency, events))
+	return witClient, cfg, store, events, generate, requireAdmin(cfg, handleRuns(pool, cfg, store, idempotency, events))
+}
+
+// Função para chamar um handler com a chave administrativa informada
+func callWithKey(handler http.HandlerFunc, method, target, key string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, nil)
+	request.Header.Set("X-Admin-Key", key)
+	request.Header.Set("X-Requested-By", key)
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	return recorder
+}
+
+func TestGenerateDueDatesRequiresApproval(t *testing.T) {
+	witClient, _, store, events, generate, runsHandler := approvalFixture(t)
+
+	recorder := callWithKey(generate, http.MethodPost, "/generate-due-dates?sprint=Sprint%207&strategy=even", "chave-ana")
+	if recorder.Code != http.StatusAccepted {
+		t.Fatalf("pedido: status = %d, quer 202: %s", recorder.Code, recorder.Body.String())
+	}
+	var pending GenerationReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &pending); err != nil {
+		t.Fatal(err)
+	}
+	if !pending.DryRun || pending.Planned != 2 || pending.Approval == nil || pending.Approval.Status != approvalPending || pending.Approval.RequestedKey != adminKeyID("chave-ana") {
+		t.Fatalf("pedido = dryRun %t, %d previstos, aprovação %+v", pending.DryRun, pending.Planned, pending.Approval)
+	}
+	if len(witClient.updates) != 0 {
+		t.Fatalf("pedido gravou %d itens antes da aprovação", len(witClient.updates))
+	}
+	if summaries := store.list("Time", "", ""); len(summaries) != 1 || summaries[0].Approval != approvalPending {
+		t.Errorf("GET /runs = %+v, quer a execução pendente", summaries)
+	}
+	events.wait()
+	if announced := events.notifiers[0].(*recordingNotifier).received(); len(announced) != 1 || announced[0].Type != EventRunPendingApproval || announced[0].RunID != pending.RunID {
+		t.Errorf("eventos = %+v, quer run.pending-approval", announced)
+	}
+
+	// A mesma chave não aprova
+	approveURL := "/runs/" + pending.RunID + "/approve"
+	if recorder := callWithKey(runsHandler, http.MethodPost, approveURL, "chave-ana"); recorder.Code != http.StatusForbidden || len(witClient.updates) != 0 {
+		t.Fatalf("aprovação pela mesma chave: status = %d, %d gravações, quer 403 sem gravar", recorder.Code, len(witClient.updates))
+	}
+
+	recorder = callWithKey(runsHandler, http.MethodPost, approveURL, "chave-bruno")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("aprovação: status = %d, quer 200: %s", recorder.Code, recorder.Body.String())
+	}
+	var executed GenerationReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &executed); err != nil {
+		t.Fatal(err)
+	}
+	if executed.RunID != pending.RunID || executed.DryRun || executed.Updated != 2 || len(witClient.updates) != 2 {
+		t.Errorf("geração aprovada = run %s, dryRun %t, %d gravados, %d gravações; quer o mesmo ID e 2 gravações", executed.RunID, executed.DryRun, executed.Updated, len(witClient.updates))
+	}
+
+	run, ok := store.get(pending.RunID)
+	if !ok || run.DryRun || len(run.Items) != 2 || run.Caller == nil || run.Caller.RequestedBy != "chave-ana" {
+		t.Fatalf("execução = %+v, quer a geração gravada pedida por Ana", run)
+	}
+	if run.Approval == nil || run.Approval.Status != approvalApproved || run.Approval.ApprovedKey != adminKeyID("chave-bruno") || run.Approval.ApprovedBy != "chave-bruno" || run.Approval.ApprovedAt == nil {
+		t.Errorf("aprovação = %+v", run.Approval)
+	}
+	var actions []string
+	for _, entry := range run.Audit {
+		actions = append(actions, entry.Action)
+	}
+	if want := []string{auditApprovalRequested, auditApprovalRejected, auditApproved}; !reflect.DeepEqual(actions, want) {
+		t.Errorf("auditoria = %v, quer %v", actions, want)
+	}
+
+	// Já aprovada não executa de novo
+	if recorder := callWithKey(runsHandler, http.MethodPost, approveURL, "chave-bruno"); recorder.Code != http.StatusConflict || len(witClient.updates[1]) != 1 {
+		t.Errorf("segunda aprovação: status = %d, quer 409 sem gravar", recorder.Code)
+	}
+}
+
+func TestApproveExpiredRun(t *testing.T) {
+	witClient, cfg, store, _, generate, runsHandler := approvalFixture(t)
+	cfg.ApprovalTTL = time.Nanosecond
+
+	recorder := callWithKey(generate, http.MethodPost, "/generate-due-dates?sprint=Sprint%207", "chave-ana")
+	var pending GenerationReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &pending); err != nil || recorder.Code != http.StatusAccepted {
+		t.Fatalf("pedido: status = %d (%v)", recorder.Code, err)
+	}
+	time.Sleep(time.Millisecond)
+	if run, _ := store.get(pending.RunID); run.Approval == nil || run.Approval.Status != approvalExpired {
+		t.Errorf("GET /runs/{id} depois do prazo = %+v, quer expired", run.Approval)
+	}
+	if recorder := callWithKey(runsHandler, http.MethodPost, "/runs/"+pending.RunID+"/approve", "chave-bruno"); recorder.Code != http.StatusGone || len(witClient.updates) != 0 {
+		t.Errorf("aprovação vencida: status = %d, %d gravações, quer 410 sem gravar", recorder.Code, len(witClient.updates))
+	}
+}
+
+func TestRequireAdminAcceptsEveryKey(t *testing.T) {
+	cfg := &Config{AdminAPIKey: &secret{name: "ADMIN_API_KEY", value: "chave-ana,chave-bruno"}}
+	handler := requireAdmin(cfg, func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	for key, want := range map[string]int{"chave-ana": http.StatusNoContent, "chave-bruno": http.StatusNoContent, "chave-ana,chave-bruno": http.StatusUnauthorized, "": http.StatusUnauthorized} {
+		if recorder := callWithKey(handler, http.MethodPost, "/runs", key); recorder.Code != want {
+			t.Errorf("X-Admin-Key %q: status = %d, quer %d", key, recorder.Code, want)
+		}
+	}
+}
+
+func TestApproveChangedPlan(t *testing.T) {
+	witClient, _, store, _, generate, runsHandler := approvalFixture(t)
+
+	recorder := callWithKey(generate, http.MethodPost, "/generate-due-dates?sprint=Sprint%207&strategy=even", "chave-ana")
+	var pending GenerationReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &pending); err != nil || recorder.Code != http.StatusAccepted {
+		t.Fatalf("pedido: status = %d (%v)", recorder.Code, err)
+	}
+	if run, _ := store.get(pending.RunID); run.Approval == nil || len(run.Approval.Plan) != 2 {
+		t.Fatalf("plano do pedido = %+v, quer as 2 User Stories", run.Approval)
+	}
+
+	// Data preenchida na #2 depois do pedido: o plano aprovado não vale mais
+	witClient.mu.Lock()
+	(*witClient.items[2].Fields)[dueDateField] = day(2030, 3, 20).Format(time.RFC3339)
+	witClient.mu.Unlock()
+
+	recorder = callWithKey(runsHandler, http.MethodPost, "/runs/"+pending.RunID+"/approve", "chave-bruno")
+	if recorder.Code != http.StatusConflict || len(witClient.updates) != 0 {
+		t.Fatalf("aprovação com plano alterado: status = %d, %d gravações, quer 409 sem gravar: %s", recorder.Code, len(witClient.updates), recorder.Body.String())
+	}
+	var response struct {
+		Changed []int `json:"changed"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || !reflect.DeepEqual(response.Changed, []int{2}) {
+		t.Errorf("changed = %v (%v), quer [2]", response.Changed, err)
+	}
+	if run, _ := store.get(pending.RunID); !run.DryRun || run.Approval.Status != approvalPending {
+		t.Errorf("execução depois do 409 = dryRun %t, %s; quer pendente sem gravar", run.DryRun, run.Approval.Status)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Arquivo dentro do zip de GET /export/archive, gerado pelo endpoint
+// equivalente da API para manter o mesmo formato
+type archiveEntry struct {
+	Name        string
+	Description string
+	// Rota cujo handler gera o arquivo e parâmetros acrescentados aos da requisição
+	Path    string
+	Query   url.Values
+	Handler http.HandlerFunc
+}
+
+// Arquivo descrito em manifest.json. Status é o status HTTP que o endpoint
+// respondeu; com Failed o arquivo traz o erro JSON dele no lugar do conteúdo.
+type ArchiveFile struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Source      string `json:"source"`
+	ContentType string `json:"contentType"`
+	Status      int    `json:"status"`
+	Failed      bool   `json:"failed,omitempty"`
+}
+
+// Conteúdo de manifest.json, o último arquivo do zip
+type ArchiveManifest struct {
+	Sprint      string        `json:"sprint"`
+	SprintID    string        `json:"sprintId"`
+	Team        string        `json:"team"`
+	GeneratedAt time.Time     `json:"generatedAt"`
+	Files       []ArchiveFile `json:"files"`
+}
+
+// archiveEntryWriter recebe a resposta de um handler e a escreve direto na
+// entrada do zip, sem juntar o arquivo em memória
+type archiveEntryWriter struct {
+	w      io.Writer
+	header http.Header
+	status int
+}
+
+func (aw *archiveEntryWriter) Header() http.Header {
+	return aw.header
+}
+
+func (aw *archiveEntryWriter) WriteHeader(code int) {
+	if aw.status == 0 {
+		aw.status = code
+	}
+}
+
+func (aw *archiveEntryWriter) Write(p []byte) (int, error) {
+	if aw.status == 0 {
+		aw.status = http.StatusOK
+	}
+	return aw.w.Write(p)
+}
+
+// GET /export/archive?sprint=: o registro da sprint num zip só, com o resumo,
+// o plano em CSV, a capacidade, a precisão das datas, as execuções de /runs e
+// o burndown. O zip é enviado enquanto os arquivos são gerados.
+func handleSprintArchive(pool *adoPool, cfg *Config, runs *runStore) http.HandlerFunc {
+	entries := []archiveEntry{
+		{Name: "summary.json", Description: "Resumo da sprint (GET /sprint-summary)", Path: "/sprint-summary", Handler: handleSprintSummary(pool, cfg, runs)},
+		{Name: "plan.csv", Description: "Plano da geração de datas no formato do MS Project (GET /export/project-csv)", Path: "/export/project-csv", Handler: handleProjectExport(pool, cfg)},
+		{Name: "developers.json", Description: "Capacidade e trabalho por desenvolvedor, com as tasks fechadas (GET /developers?includeClosed=true)", Path: "/developers",
+			Query: url.Values{"includeClosed": {"true"}}, Handler: handleDevelopers(pool, cfg)},
+		{Name: "accuracy.json", Description: "Precisão das datas das User Stories fechadas (GET /metrics/due-date-accuracy)", Path: "/metrics/due-date-accuracy", Handler: handleDueDateAccuracy(pool, cfg, runs)},
+		// Como em GET /runs, as execuções só vêm com X-Admin-Key. Só GET /runs
+		// é chamado, então a aprovação (idempotência e eventos) nunca roda aqui.
+		{Name: "runs.json", Description: "Execuções de gravação de datas da sprint (GET /runs, com X-Admin-Key)", Path: "/runs", Handler: requireAdmin(cfg, handleRuns(pool, cfg, runs, nil, nil))},
+		{Name: "burndown.json", Description: "Série do burndown da sprint (GET /burndown)", Path: "/burndown", Handler: handleBurndown(pool, cfg)},
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			jsonError(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		ref, err := sprintRefFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			jsonError(w, "Streaming não suportado pelo servidor", http.StatusInternalServerError)
+			return
+		}
+
+		// A sprint é resolvida antes do zip para os erros virem em JSON
+		ctx := r.Context()
+		workClient, err := pool.Work(ctx, "archive")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
+			return
+		}
+		targetIteration, err := resolveSprintRef(ctx, workClient, cfg, ref)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		sprintName := *targetIteration.Name
+		sprintID := targetIteration.Id.String()
+		setSprintHeaders(w, targetIteration)
+
+		manifest := ArchiveManifest{Sprint: sprintName, SprintID: sprintID, Team: cfg.TeamName, GeneratedAt: time.Now().UTC(), Files: []ArchiveFile{}}
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-archive.zip"`, exportFileName(sprintName)))
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		archive := zip.NewWriter(w)
+		for _, entry := range entries {
+			// Os endpoints recebem a sprint já resolvida pelo ID, com os outros
+			// parâmetros da requisição (team, areaPath, includeWeekends, ...)
+			query := r.URL.Query()
+			query.Del("sprint")
+			query.Set("sprintId", sprintID)
+			for key, values := range entry.Query {
+				query[key] = values
+			}
+			request := r.Clone(ctx)
+			request.URL.Path = entry.Path
+			request.URL.RawQuery = query.Encode()
+
+			file, err := archive.CreateHeader(&zip.FileHeader{Name: entry.Name, Method: zip.Deflate, Modified: manifest.GeneratedAt})
+			if err != nil {
+				log.Printf("[ERROR] Erro ao escrever %s no arquivo da sprint '%s': %v", entry.Name, sprintName, err)
+				return
+			}
+			writer := &archiveEntryWriter{w: file, header: make(http.Header)}
+			entry.Handler(writer, request)
+			described := ArchiveFile{
+				Name:        entry.Name,
+				Description: entry.Description,
+				Source:      entry.Path + "?" + request.URL.RawQuery,
+				ContentType: writer.header.Get("Content-Type"),
+				Status:      writer.status,
+				Failed:      writer.status < 200 || writer.status > 299,
+			}
+			if described.Failed {
+				log.Printf("[WARN] %s do arquivo da sprint '%s' respondeu %d", entry.Path, sprintName, writer.status)
+			}
+			manifest.Files = append(manifest.Files, described)
+			flusher.Flush()
+		}
+
+		file, err := archive.CreateHeader(&zip.FileHeader{Name: "manifest.json", Method: zip.Deflate, Modified: manifest.GeneratedAt})
+		if err == nil {
+			writeJSON(&archiveEntryWriter{w: file, header: make(http.Header)}, http.StatusOK, manifest)
+			err = archive.Close()
+		}
+		if err != nil {
+			log.Printf("[ERROR] Erro ao finalizar o arquivo da sprint '%s': %v", sprintName, err)
+			return
+		}
+		log.Printf("[DEBUG] Arquivo da sprint '%s' enviado com %d arquivos", sprintName, len(manifest.Files)+1)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+)
+
+func TestHandleSprintArchive(t *testing.T) {
+	workClient, witClient, iteration := developersFixture()
+	// Sprint e geração recentes: o resumo conta a idade do plano em dias úteis
+	// até hoje, limitada a maxCalendarDays
+	start := sprintDate(time.Now()).AddDate(0, 0, -14)
+	recent := fakeIteration("Sprint 1", start, start.AddDate(0, 0, 11), "")
+	recent.Id = iteration.Id
+	workClient.iterations = []work.TeamSettingsIteration{recent}
+	cfg := &Config{Project: "Projeto", Team: "Time", TeamName: "Time", WorkItemTypes: []string{"User Story"}, ChildWorkItemTypes: []string{"Task"},
+		DefaultCapacityPerDay: 6, HoursPerStoryPoint: 8, FocusFactor: 1, DueDateWriteField: dueDateField, Location: time.UTC,
+		AdminAPIKey: &secret{name: "ADMIN_API_KEY", value: "chave"}}
+	store, err := newRunStore("", runRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addGenerationRun(store, "run-sprint", iteration.Id.String(), start, false, 100)
+	addGenerationRun(store, "run-outra", "outra-sprint", start, false, 200)
+	handler := handleSprintArchive(newFakePool(workClient, witClient), cfg, store)
+
+	request := httptest.NewRequest(http.MethodGet, "/export/archive?sprint=Sprint%201", nil)
+	request.Header.Set("X-Admin-Key", "chave")
+	files, names, manifest := readSprintArchive(t, handler, request)
+	want := []string{"summary.json", "plan.csv", "developers.json", "accuracy.json", "runs.json", "burndown.json", "manifest.json"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("arquivos = %v, quer %v", names, want)
+	}
+	if manifest.Sprint != "Sprint 1" || manifest.SprintID != iteration.Id.String() || manifest.GeneratedAt.IsZero() || len(manifest.Files) != len(want)-1 {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+	for _, file := range manifest.Files {
+		if file.Failed || file.Status != http.StatusOK || file.Description == "" || !strings.Contains(file.Source, "sprintId="+iteration.Id.String()) {
+			t.Errorf("%s no manifest = %+v: %s", file.Name, file, files[file.Name])
+		}
+	}
+
+	if !strings.HasPrefix(string(files["plan.csv"]), utf8BOM+strings.Join(projectCSVHeader, ",")) {
+		t.Errorf("plan.csv fora do formato da exportação: %q", files["plan.csv"])
+	}
+	var developers DevelopersResponse
+	if err := json.Unmarshal(files["developers.json"], &developers); err != nil || len(developers.Developers) != 2 {
+		t.Errorf("developers.json = %s (%v)", files["developers.json"], err)
+	}
+	// Só as execuções da sprint
+	var runs []DueDateRunSummary
+	if err := json.Unmarshal(files["runs.json"], &runs); err != nil || len(runs) != 1 || runs[0].ID != "run-sprint" {
+		t.Errorf("runs.json = %s (%v)", files["runs.json"], err)
+	}
+
+	// Sem X-Admin-Key o zip sai, mas sem as execuções
+	_, _, manifest = readSprintArchive(t, handler, httptest.NewRequest(http.MethodGet, "/export/archive?sprint=Sprint%201", nil))
+	for _, file := range manifest.Files {
+		if wantFailed := file.Name == "runs.json"; file.Failed != wantFailed {
+			t.Errorf("%s sem chave: failed = %t (status %d), quer %t", file.Name, file.Failed, file.Status, wantFailed)
+		}
+	}
+}
+
+// Função para chamar o handler e abrir o zip, devolvendo o conteúdo de cada
+// arquivo, os nomes na ordem do zip e o manifest
+func readSprintArchive(t *testing.T, handler http.HandlerFunc, request *http.Request) (map[string][]byte, []string, ArchiveManifest) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if got := recorder.Header().Get("Content-Disposition"); got != `attachment; filename="Sprint-1-archive.zip"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(recorder.Body.Bytes()), int64(recorder.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string][]byte)
+	var names []string
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[file.Name] = content
+		names = append(names, file.Name)
+	}
+	var manifest ArchiveManifest
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil {
+		t.Fatal(err)
+	}
+	return files, names, manifest
+}
+
+func TestHandleSprintArchiveErrorsBeforeZip(t *testing.T) {
+	workClient, witClient, _ := developersFixture()
+	cfg := &Config{Project: "Projeto", Team: "Time", TeamName: "Time", WorkItemTypes: []string{"User Story"}, Location: time.UTC}
+	handler := handleSprintArchive(newFakePool(workClient, witClient), cfg, nil)
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodGet, "/export/archive?sprint=Inexistente", nil))
+	if recorder.Code != http.StatusNotFound || !strings.HasPrefix(recorder.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("status = %d (%s), quer 404 em JSON", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// User Story com data avaliada por GET /at-risk. ProjectedCompletion é o dia
+// em que o responsável termina o trabalho restante dela, depois das User
+// Stories dele com data anterior; nil quando o trabalho passa do fim da sprint.
+// ShortfallHours é quanto do trabalho acumulado até ela não cabe na
+// capacidade do responsável até a data.
+type AtRiskStory struct {
+	WorkItem
+	RemainingWork       float64    `json:"remainingWork"`
+	ProjectedCompletion *time.Time `json:"projectedCompletion"`
+	ShortfallHours      float64    `json:"shortfallHours"`
+}
+
+// Resposta de GET /at-risk. Toda User Story aberta da sprint aparece em
+// exatamente uma das listas.
+type AtRiskReport struct {
+	Sprint      string    `json:"sprint"`
+	SprintStart time.Time `json:"sprintStart"`
+	SprintEnd   time.Time `json:"sprintEnd"`
+	// Início do cálculo: o maior entre o início da sprint e hoje
+	Start      time.Time     `json:"start"`
+	AtRisk     []AtRiskStory `json:"atRisk"`
+	OnTrack    []AtRiskStory `json:"onTrack"`
+	NoDueDate  []WorkItem    `json:"noDueDate"`
+	NoEstimate []WorkItem    `json:"noEstimate"`
+	Unassigned []WorkItem    `json:"unassigned"`
+	// Última geração de datas e se o plano ficou desatualizado
+	PlanFreshness
+	Warnings []string `json:"warnings"`
+}
+
+// Função para somar as horas do cursor até date, inclusive, a partir do
+// primeiro dia dele (independente do ponto atual)
+func (c *capacityCursor) hoursUntil(date time.Time) float64 {
+	hours := 0.0
+	for _, day := range c.days {
+		if day.Date.After(date) {
+			break
+		}
+		hours += day.Hours
+	}
+	return hours
+}
+
+// Função para avaliar as User Stories com data: cada responsável percorre as
+// dele por data (empates pela ordem recebida) consumindo a capacidade, como na
+// estratégia capacity. Devolve as avaliações na ordem em que foram feitas.
+func evaluateAtRisk(stories []WorkItem, input capacityPlanInput) ([]AtRiskStory, []string, error) {
+	ordered := append([]WorkItem{}, stories...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return sprintDate(*ordered[i].DueDate).Before(sprintDate(*ordered[j].DueDate))
+	})
+
+	planner := newCapacityPlanner(input)
+	accumulated := make(map[*capacityCursor]float64)
+	evaluated := make([]AtRiskStory, 0, len(ordered))
+	for _, story := range ordered {
+		cursor, err := planner.cursor(story.AssignedTo)
+		if err != nil {
+			return nil, nil, err
+		}
+		item := AtRiskStory{WorkItem: story, RemainingWork: input.TaskWork[story.ID]}
+		if _, ok := input.TaskWork[story.ID]; !ok {
+			item.RemainingWork = *story.StoryPoints * input.HoursPerStoryPoint
+		}
+		if finish, fits := cursor.consume(item.RemainingWork); fits {
+			item.ProjectedCompletion = &finish
+		}
+		accumulated[cursor] += item.RemainingWork
+		shortfall := accumulated[cursor] - cursor.hoursUntil(sprintDate(*story.DueDate))
+		if shortfall > capacityEpsilon {
+			item.ShortfallHours = shortfall
+		}
+		evaluated = append(evaluated, item)
+	}
+	return evaluated, planner.warnings(), nil
+}
+
+// Função para verificar se a avaliação mostra que a data não será cumprida
+func (s AtRiskStory) late() bool {
+	return s.ProjectedCompletion == nil || s.ProjectedCompletion.After(sprintDate(*s.DueDate)) || s.ShortfallHours > 0
+}
+
+// GET /at-risk: User Stories abertas da sprint cuja data não cabe na
+// capacidade restante do responsável
+func handleAtRisk(pool *adoPool, cfg *Config, runs *runStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ref, err := sprintRefFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cal, err := calendarForRequest(cfg, r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		keepArea, err := areaPathFilterFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		workClient, err := pool.Work(ctx, "at-risk")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
+			return
+		}
+		targetIteration, err := resolveSprintRef(ctx, workClient, cfg, ref)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		sprintName := *targetIteration.Name
+		setSprintHeaders(w, targetIteration)
+		sprintStart, sprintEnd, err := sprintDates(targetIteration)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		teamDaysOff, err := fetchTeamDaysOff(ctx, workClient, cfg, targetIteration)
+		if err != nil {
+			respondError(w, "Erro ao buscar folgas do time", err)
+			return
+		}
+		capacities, err := fetchTeamCapacities(ctx, workClient, cfg, targetIteration)
+		if err != nil {
+			respondError(w, "Erro ao buscar capacidades da sprint", err)
+			return
+		}
+		witClient, err := pool.WorkItems(ctx, "at-risk")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+		types, _ := typesFromRequest(cfg, r)
+		sprintStories, _, missing, err := fetchSprintStories(ctx, workClient, witClient, cfg, targetIteration, types, nil)
+		if err != nil {
+			respondError(w, "Erro ao buscar User Stories", err)
+			return
+		}
+		var stories []WorkItem
+		for _, item := range sprintStories {
+			if isOpenStory(item) && keepArea(item.AreaPath) {
+				stories = append(stories, item)
+			}
+		}
+		if err := sortWorkItems(stories, "priority", ""); err != nil {
+			respondError(w, "Erro ao ordenar User Stories", err)
+			return
+		}
+		var storyIds []int
+		for _, story := range stories {
+			storyIds = append(storyIds, story.ID)
+		}
+		taskWork, err := storyTaskWork(ctx, witClient, cfg, storyIds)
+		if err != nil {
+			respondError(w, "Erro ao buscar tasks das User Stories", err)
+			return
+		}
+
+		// Com a sprint em andamento, o trabalho restante começa hoje
+		start := sprintStart
+		if today := cal.dateOf(time.Now()); today.After(start) {
+			start = today
+		}
+		report := AtRiskReport{
+			Sprint:      sprintName,
+			SprintStart: sprintStart,
+			SprintEnd:   sprintEnd,
+			Start:       start,
+			AtRisk:      []AtRiskStory{},
+			OnTrack:     []AtRiskStory{},
+			NoDueDate:   []WorkItem{},
+			NoEstimate:  []WorkItem{},
+			Unassigned:  []WorkItem{},
+			Warnings:    missingWorkItemWarnings(missing),
+		}
+		var dated []WorkItem
+		for _, story := range stories {
+			_, estimated := taskWork[story.ID]
+			switch {
+			case story.DueDate == nil:
+				report.NoDueDate = append(report.NoDueDate, story)
+			case !estimated && story.StoryPoints == nil:
+				report.NoEstimate = append(report.NoEstimate, story)
+			case story.AssignedTo == nil:
+				report.Unassigned = append(report.Unassigned, story)
+			default:
+				dated = append(dated, story)
+			}
+		}
+
+		evaluated, warnings, err := evaluateAtRisk(dated, capacityPlanInput{
+			Start:              start,
+			End:                sprintEnd,
+			TaskWork:           taskWork,
+			Capacities:         capacities,
+			TeamDaysOff:        teamDaysOff,
+			DefaultPerDay:      cfg.DefaultCapacityPerDay,
+			HoursPerStoryPoint: cfg.HoursPerStoryPoint,
+			Calendar:           cal,
+			FocusFactor:        cfg.FocusFactor,
+			CeremonyHours:      cfg.CeremonyHoursPerDay,
+			Availability:       cfg.Availability,
+		})
+		if err != nil {
+			respondError(w, fmt.Sprintf("Datas inválidas na sprint '%s'", sprintName), err)
+			return
+		}
+		report.Warnings = append(report.Warnings, warnings...)
+		for _, item := range evaluated {
+			if item.late() {
+				report.AtRisk = append(report.AtRisk, item)
+			} else {
+				report.OnTrack = append(report.OnTrack, item)
+			}
+		}
+		freshness, warning, err := planFreshness(runs, cfg.Team, targetIteration.Id.String(), stories, time.Now(), cal, cfg.PlanStaleAfterDays)
+		if err != nil {
+			respondError(w, fmt.Sprintf("Datas inválidas na sprint '%s'", sprintName), err)
+			return
+		}
+		report.PlanFreshness = freshness
+		if warning != "" {
+			report.Warnings = append(report.Warnings, warning)
+		}
+		// As em risco vêm das maiores faltas para as menores
+		sort.SliceStable(report.AtRisk, func(i, j int) bool {
+			return report.AtRisk[i].ShortfallHours > report.AtRisk[j].ShortfallHours
+		})
+
+		log.Printf("[DEBUG] %d User Stories em risco na sprint '%s' (%d no prazo, %d sem data, %d sem estimativa, %d sem responsável)",
+			len(report.AtRisk), sprintName, len(report.OnTrack), len(report.NoDueDate), len(report.NoEstimate), len(report.Unassigned))
+		writeJSON(w, http.StatusOK, report)
+	}
+}
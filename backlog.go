@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"sort"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+)
+
+// Categoria do backlog de requisitos (User Story / Product Backlog Item)
+const requirementBacklogID = "Microsoft.RequirementCategory"
+
+// Função para obter a ordem manual do backlog do time (prioridade do board).
+// Devolve um mapa de ID do work item para sua posição, começando em 1.
+func fetchBacklogRanks(ctx context.Context, workClient work.Client, cfg *Config) (map[int]int, error) {
+	backlogID := requirementBacklogID
+	backlog, err := workClient.GetBacklogLevelWorkItems(ctx, work.GetBacklogLevelWorkItemsArgs{
+		Project:   &cfg.Project,
+		Team:      &cfg.Team,
+		BacklogId: &backlogID,
+	})
+	if err != nil {
+		return nil, wrapAdoError(err, "GetBacklogLevelWorkItems", "team=%s, backlog=%s", cfg.TeamName, backlogID)
+	}
+
+	ranks := make(map[int]int)
+	if backlog == nil || backlog.WorkItems == nil {
+		return ranks, nil
+	}
+	for _, link := range *backlog.WorkItems {
+		if link.Target == nil || link.Target.Id == nil {
+			continue
+		}
+		if _, exists := ranks[*link.Target.Id]; !exists {
+			ranks[*link.Target.Id] = len(ranks) + 1
+		}
+	}
+	return ranks, nil
+}
+
+// Função para ordenar as User Stories da geração pela ordem manual do
+// backlog. As que estão fora do backlog vêm depois, pelo StackRank, e as sem
+// nenhum dos dois por último, pelo ID. Devolve os IDs ordenados só pelo ID.
+func sortByBacklogOrder(items []WorkItem) []int {
+	group := func(item WorkItem) int {
+		switch {
+		case item.BacklogRank != nil:
+			return 0
+		case item.StackRank != nil:
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if groupA, groupB := group(a), group(b); groupA != groupB {
+			return groupA < groupB
+		}
+		switch {
+		case a.BacklogRank != nil && *a.BacklogRank != *b.BacklogRank:
+			return *a.BacklogRank < *b.BacklogRank
+		case a.BacklogRank == nil && a.StackRank != nil && *a.StackRank != *b.StackRank:
+			return *a.StackRank < *b.StackRank
+		}
+		return a.ID < b.ID
+	})
+	var byID []int
+	for _, item := range items {
+		if group(item) == 2 {
+			byID = append(byID, item.ID)
+		}
+	}
+	return byID
+}
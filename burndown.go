@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Dia da série de GET /burndown. PlannedRemaining são as horas das User
+// Stories com data depois do dia (ou sem data); ActualRemaining só vem no dia
+// de hoje, com o RemainingWork atual (não há histórico).
+type BurndownDay struct {
+	Date    time.Time `json:"date"`
+	Working bool      `json:"working"`
+	Weekend bool      `json:"weekend,omitempty"`
+	Holiday string    `json:"holiday,omitempty"`
+	// Parte do dia em folga do time (1 = dia inteiro)
+	TeamDayOff float64 `json:"teamDayOff,omitempty"`
+	// Dia na zona congelada (FREEZE_DAYS_BEFORE_END)
+	Frozen           bool     `json:"frozen,omitempty"`
+	PlannedRemaining float64  `json:"plannedRemaining"`
+	ActualRemaining  *float64 `json:"actualRemaining"`
+}
+
+// Resposta de GET /burndown
+type BurndownReport struct {
+	Sprint      string    `json:"sprint"`
+	SprintStart time.Time `json:"sprintStart"`
+	SprintEnd   time.Time `json:"sprintEnd"`
+	Today       time.Time `json:"today"`
+	// RemainingWork atual de todas as User Stories consideradas
+	ActualRemaining float64 `json:"actualRemaining"`
+	// Horas de User Stories sem data ou com data depois do fim da sprint, que
+	// nunca saem da linha planejada
+	UnscheduledHours float64 `json:"unscheduledHours"`
+	// User Stories abertas sem nenhuma task com RemainingWork, fora da série
+	UnestimatedStories int           `json:"unestimatedStories"`
+	Days               []BurndownDay `json:"days"`
+	Warnings           []string      `json:"warnings,omitempty"`
+}
+
+// Função para montar a série do burndown: cada dia do calendário da sprint,
+// marcado como útil ou não, com as horas planejadas restantes pelas datas
+func burndownDays(start, end, today time.Time, work map[int]float64, dueDays map[int]time.Time, teamDaysOff []DayOff, freeze sprintFreeze, cal workCalendar) []BurndownDay {
+	total := 0.0
+	for _, hours := range work {
+		total += hours
+	}
+	var days []BurndownDay
+	for current := start; !current.After(end); current = current.AddDate(0, 0, 1) {
+		day := BurndownDay{
+			Date:       current,
+			Weekend:    cal.isWeekend(current.Weekday()),
+			TeamDayOff: cal.dayOffFraction(current, teamDaysOff),
+			Frozen:     freeze.frozen(current),
+		}
+		if holiday, ok := cal.Holidays[current]; ok {
+			day.Holiday = holiday.Name
+			if day.Holiday == "" {
+				day.Holiday = current.Format("2006-01-02")
+			}
+		}
+		day.Working = cal.dayWeight(current) > 0 && day.TeamDayOff < 1
+
+		// Trabalho com data até o dia já saiu da linha planejada
+		day.PlannedRemaining = total
+		for id, due := range dueDays {
+			if !due.After(current) {
+				day.PlannedRemaining -= work[id]
+			}
+		}
+		if current.Equal(today) {
+			actual := total
+			day.ActualRemaining = &actual
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// GET /burndown: linha planejada (pelas datas das User Stories) e o trabalho
+// restante atual, dia a dia da sprint
+func handleBurndown(pool *adoPool, cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ref, err := sprintRefFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cal, err := calendarForRequest(cfg, r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		keepArea, err := areaPathFilterFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		workClient, err := pool.Work(ctx, "burndown")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
+			return
+		}
+		targetIteration, err := resolveSprintRef(ctx, workClient, cfg, ref)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		sprintName := *targetIteration.Name
+		setSprintHeaders(w, targetIteration)
+		sprintStart, sprintEnd, err := sprintDates(targetIteration)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		if sprintEnd.Sub(sprintStart) > maxCalendarDays*24*time.Hour {
+			respondError(w, "", fmt.Errorf("%w: %s a %s excede %d dias", ErrInvalidDateRange,
+				sprintStart.Format("2006-01-02"), sprintEnd.Format("2006-01-02"), maxCalendarDays))
+			return
+		}
+		teamDaysOff, err := fetchTeamDaysOff(ctx, workClient, cfg, targetIteration)
+		if err != nil {
+			respondError(w, "Erro ao buscar folgas do time", err)
+			return
+		}
+		witClient, err := pool.WorkItems(ctx, "burndown")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+		types, _ := typesFromRequest(cfg, r)
+		sprintStories, _, missing, err := fetchSprintStories(ctx, workClient, witClient, cfg, targetIteration, types, nil)
+		if err != nil {
+			respondError(w, "Erro ao buscar User Stories", err)
+			return
+		}
+		var stories []WorkItem
+		var storyIds []int
+		for _, story := range sprintStories {
+			if !story.Removed && keepArea(story.AreaPath) {
+				stories = append(stories, story)
+				storyIds = append(storyIds, story.ID)
+			}
+		}
+		taskWork, err := storyTaskWork(ctx, witClient, cfg, storyIds)
+		if err != nil {
+			respondError(w, "Erro ao buscar tasks das User Stories", err)
+			return
+		}
+
+		report := BurndownReport{
+			Sprint:      sprintName,
+			SprintStart: sprintStart,
+			SprintEnd:   sprintEnd,
+			Today:       cal.dateOf(time.Now()),
+			Warnings:    missingWorkItemWarnings(missing),
+		}
+		// Datas antes do início da sprint contam como entregues no primeiro dia
+		dueDays := make(map[int]time.Time)
+		for _, story := range stories {
+			hours, estimated := taskWork[story.ID]
+			if !estimated {
+				if isOpenStory(story) {
+					report.UnestimatedStories++
+				}
+				continue
+			}
+			report.ActualRemaining += hours
+			if story.DueDate == nil {
+				report.UnscheduledHours += hours
+				continue
+			}
+			day := dueDateDay(*story.DueDate, cal)
+			if day.After(sprintEnd) {
+				report.UnscheduledHours += hours
+			}
+			dueDays[story.ID] = day
+		}
+		if report.UnestimatedStories > 0 {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%d User Stories abertas sem tasks com RemainingWork ficaram fora do burndown", report.UnestimatedStories))
+		}
+		freeze, err := newSprintFreeze(cfg, sprintStart, sprintEnd, teamDaysOff, cal)
+		if err != nil {
+			respondError(w, fmt.Sprintf("Datas inválidas na sprint '%s'", sprintName), err)
+			return
+		}
+		report.Days = burndownDays(sprintStart, sprintEnd, report.Today, taskWork, dueDays, teamDaysOff, freeze, cal)
+
+		log.Printf("[DEBUG] Burndown da sprint '%s': %.1fh restantes, %.1fh sem data na sprint", sprintName, report.ActualRemaining, report.UnscheduledHours)
+		writeJSON(w, http.StatusOK, report)
+	}
+}
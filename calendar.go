@@ -0,0 +1,383 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Maior intervalo aceito pelo cálculo de dias úteis. Sprints têm semanas;
+// um intervalo maior indica datas corrompidas (por exemplo, data zero).
+const maxCalendarDays = 2 * 366
+
+var ErrInvalidDateRange = errors.New("intervalo de datas inválido")
+
+// workCalendar define quais dias contam como úteis. Com IncludeWeekends, os
+// dias de fim de semana (WeekendDays, padrão sábado e domingo) contam com o
+// peso WeekendFactor (1.0 = dia cheio).
+type workCalendar struct {
+	IncludeWeekends bool
+	WeekendFactor   float64
+	// Fuso usado para decidir a que dia do calendário pertence cada horário
+	// de verdade (agora, datas de fechamento...). Datas sem horário, como as
+	// de sprint, folgas e feriados, não passam por ele.
+	Location *time.Location
+	// Dias da semana de folga do time (WEEKEND_DAYS)
+	WeekendDays map[time.Weekday]bool
+	// Feriados que não contam para ninguém, indexados pelo dia (meia-noite UTC)
+	Holidays map[time.Time]Holiday
+}
+
+// Holiday é um feriado configurado em HOLIDAYS ou HOLIDAYS_CALENDAR_FILE
+type Holiday struct {
+	Date time.Time `json:"date"`
+	Name string    `json:"name,omitempty"`
+}
+
+// AvailabilityWindow é o período em que um desenvolvedor está no time
+// (AVAILABILITY_FILE), para quem entra ou sai no meio da sprint. From e To
+// são dias inclusivos; nil deixa aquele lado sem limite.
+type AvailabilityWindow struct {
+	From *time.Time `json:"from,omitempty"`
+	To   *time.Time `json:"to,omitempty"`
+}
+
+// Função para recortar o intervalo start..end pela janela. clipped indica se
+// algum lado mudou; empty, se a janela fica inteira fora do intervalo.
+func (w AvailabilityWindow) clip(start, end time.Time) (from, to time.Time, clipped, empty bool) {
+	from, to = start, end
+	if w.From != nil && w.From.After(from) {
+		from = *w.From
+	}
+	if w.To != nil && w.To.Before(to) {
+		to = *w.To
+	}
+	return from, to, !from.Equal(start) || !to.Equal(end), to.Before(from)
+}
+
+// Função para montar o calendário do time com a configuração do ambiente
+func (cfg *Config) calendar() workCalendar {
+	return workCalendar{
+		IncludeWeekends: cfg.IncludeWeekends,
+		WeekendFactor:   cfg.WeekendCapacityFactor,
+		Location:        cfg.Location,
+		WeekendDays:     cfg.WeekendDays,
+		Holidays:        cfg.Holidays,
+	}
+}
+
+// Função para montar o calendário do time, permitindo que a requisição
+// sobrescreva includeWeekends (?includeWeekends=true|false)
+func calendarForRequest(cfg *Config, r *http.Request) (workCalendar, error) {
+	cal := cfg.calendar()
+	if value := r.URL.Query().Get("includeWeekends"); value != "" {
+		include, err := strconv.ParseBool(value)
+		if err != nil {
+			return cal, fmt.Errorf("Parâmetro 'includeWeekends' inválido: %q", value)
+		}
+		cal.IncludeWeekends = include
+	}
+	return cal, nil
+}
+
+// Função para obter o dia do calendário de um horário, no fuso do calendário.
+// O dia é representado como meia-noite UTC para que a iteração com AddDate não
+// sofra com dias de 23 ou 25 horas (horário de verão) nem com meias-noites
+// inexistentes no fuso local. Vale só para horários de verdade: datas que já
+// são dias (sprint, folgas, feriados) usam sprintDate, senão a meia-noite UTC
+// vira o dia anterior em fusos a oeste de UTC.
+func (c workCalendar) dateOf(t time.Time) time.Time {
+	loc := c.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	year, month, day := t.In(loc).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// Função para obter a fração do dia (meia-noite UTC) coberta por
+// folgas: 0 quando não há folga, 1 para o dia inteiro. Se várias folgas
+// cobrem o mesmo dia, vale a maior fração.
+func (c workCalendar) dayOffFraction(day time.Time, daysOff []DayOff) float64 {
+	fraction := 0.0
+	for _, off := range daysOff {
+		if !day.Before(sprintDate(off.Start)) && !day.After(sprintDate(off.End)) {
+			if portion := off.portion(); portion > fraction {
+				fraction = portion
+			}
+		}
+	}
+	return fraction
+}
+
+// Função para obter o peso de um dia no calendário (0 quando não é dia útil).
+// Feriados em fim de semana já valem 0 pelo fim de semana e não descontam de novo.
+func (c workCalendar) dayWeight(day time.Time) float64 {
+	if _, holiday := c.Holidays[day]; holiday {
+		return 0
+	}
+	return c.weekdayWeight(day)
+}
+
+// Função para verificar se o dia da semana é folga semanal do time.
+// Sem WEEKEND_DAYS configurado, vale sábado e domingo.
+func (c workCalendar) isWeekend(weekday time.Weekday) bool {
+	if c.WeekendDays == nil {
+		return weekday == time.Saturday || weekday == time.Sunday
+	}
+	return c.WeekendDays[weekday]
+}
+
+// Função para obter o peso de um dia considerando apenas o dia da semana
+func (c workCalendar) weekdayWeight(day time.Time) float64 {
+	if c.isWeekend(day.Weekday()) {
+		if !c.IncludeWeekends {
+			return 0
+		}
+		return c.WeekendFactor
+	}
+	return 1
+}
+
+// Função para calcular dias úteis entre duas datas (dias, como as de sprint).
+// Fins de semana só contam quando o calendário os inclui, ponderados pelo
+// fator configurado.
+func calculateWorkingDays(start, end time.Time, daysOff []DayOff, cal workCalendar) (float64, error) {
+	if end.Sub(start) > maxCalendarDays*24*time.Hour {
+		return 0, fmt.Errorf("%w: %s a %s excede %d dias", ErrInvalidDateRange,
+			start.Format("2006-01-02"), end.Format("2006-01-02"), maxCalendarDays)
+	}
+
+	workingDays := 0.0
+	current := sprintDate(start)
+	last := sprintDate(end)
+
+	for !current.After(last) {
+		// Verifica se é fim de semana (ou se o calendário inclui fins de semana)
+		if weight := cal.dayWeight(current); weight > 0 {
+			// Desconta a parte do dia em folga, comparando apenas as datas
+			workingDays += weight * (1 - cal.dayOffFraction(current, daysOff))
+		}
+		current = current.AddDate(0, 0, 1)
+	}
+
+	return workingDays, nil
+}
+
+// Função para contar os dias úteis decorridos entre dois horários: os dias
+// depois do dia de from até o dia de to, inclusive, no fuso do calendário.
+// Começo e fim no mesmo dia dão zero. Folgas individuais não entram.
+func elapsedWorkingDays(from, to time.Time, cal workCalendar) (float64, error) {
+	return workingDaysAfter(cal.dateOf(from), cal.dateOf(to), cal)
+}
+
+// Função para contar os dias úteis depois de fromDay até toDay, inclusive,
+// com os dois já como dias do calendário (meia-noite UTC, como devolvem
+// dateOf e dueDateDay); zero quando toDay não é posterior
+func workingDaysAfter(fromDay, toDay time.Time, cal workCalendar) (float64, error) {
+	if toDay.Sub(fromDay) > maxCalendarDays*24*time.Hour {
+		return 0, fmt.Errorf("%w: %s a %s excede %d dias", ErrInvalidDateRange,
+			fromDay.Format("2006-01-02"), toDay.Format("2006-01-02"), maxCalendarDays)
+	}
+	elapsed := 0.0
+	for current := fromDay.AddDate(0, 0, 1); !current.After(toDay); current = current.AddDate(0, 0, 1) {
+		elapsed += cal.dayWeight(current)
+	}
+	return elapsed, nil
+}
+
+// Função para contar os dias úteis de folga dentro do intervalo. Cada intervalo
+// de folga é recortado ao intervalo pedido e dias cobertos por mais de um
+// intervalo contam uma vez só; folgas totalmente fora do intervalo não contam.
+// Folgas parciais contam pela fração (meio dia = 0.5).
+func countDaysOff(start, end time.Time, daysOff []DayOff, cal workCalendar) float64 {
+	count := 0.0
+	last := sprintDate(end)
+	for current := sprintDate(start); !current.After(last); current = current.AddDate(0, 0, 1) {
+		if cal.dayWeight(current) > 0 {
+			count += cal.dayOffFraction(current, daysOff)
+		}
+	}
+	return count
+}
+
+// Função para listar os feriados que caem em dias úteis dentro do intervalo,
+// ou seja, os que de fato reduziram a contagem de dias úteis
+func holidaysInRange(start, end time.Time, cal workCalendar) []Holiday {
+	holidays := []Holiday{}
+	last := sprintDate(end)
+	for current := sprintDate(start); !current.After(last); current = current.AddDate(0, 0, 1) {
+		if holiday, ok := cal.Holidays[current]; ok && cal.weekdayWeight(current) > 0 {
+			holidays = append(holidays, holiday)
+		}
+	}
+	return holidays
+}
+
+// Função para listar os dias úteis do intervalo, em ordem: dias com peso no
+// calendário que não estão inteiramente cobertos por folgas. Dias com meia
+// folga continuam na lista.
+func workingDates(start, end time.Time, daysOff []DayOff, cal workCalendar) ([]time.Time, error) {
+	if end.Sub(start) > maxCalendarDays*24*time.Hour {
+		return nil, fmt.Errorf("%w: %s a %s excede %d dias", ErrInvalidDateRange,
+			start.Format("2006-01-02"), end.Format("2006-01-02"), maxCalendarDays)
+	}
+	var dates []time.Time
+	last := sprintDate(end)
+	for current := sprintDate(start); !current.After(last); current = current.AddDate(0, 0, 1) {
+		if cal.dayWeight(current) > 0 && cal.dayOffFraction(current, daysOff) < 1 {
+			dates = append(dates, current)
+		}
+	}
+	return dates, nil
+}
+
+// Direções aceitas para mover uma data que cai fora de dia útil
+const (
+	rollPrevious = "previous"
+	rollNext     = "next"
+)
+
+// Função para ler a direção de ajuste (?roll=previous|next, padrão previous)
+func rollDirectionFromQuery(value string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", rollPrevious:
+		return rollPrevious, true
+	case rollNext:
+		return rollNext, true
+	}
+	return "", false
+}
+
+// Função para mover uma data que cai em fim de semana ou feriado para o dia
+// útil anterior (ou seguinte, com rollNext) sem sair de [start, end]. Quando
+// a direção pedida sairia do intervalo, tenta a outra; sem nenhum dia útil
+// no intervalo a data volta inalterada.
+func (c workCalendar) rollToBusinessDay(date, start, end time.Time, direction string) time.Time {
+	if c.dayWeight(date) > 0 {
+		return date
+	}
+	first, last := sprintDate(start), sprintDate(end)
+	search := func(step int) (time.Time, bool) {
+		for day := date.AddDate(0, 0, step); !day.Before(first) && !day.After(last); day = day.AddDate(0, 0, step) {
+			if c.dayWeight(day) > 0 {
+				return day, true
+			}
+		}
+		return time.Time{}, false
+	}
+	steps := []int{-1, 1}
+	if direction == rollNext {
+		steps = []int{1, -1}
+	}
+	for _, step := range steps {
+		if day, ok := search(step); ok {
+			return day
+		}
+	}
+	return date
+}
+
+// Dia de GET /calendar, com as mesmas marcações dos dias de /burndown
+type SprintCalendarDay struct {
+	Date      time.Time `json:"date"`
+	DayOfWeek string    `json:"dayOfWeek"`
+	Working   bool      `json:"working"`
+	Weekend   bool      `json:"weekend,omitempty"`
+	Holiday   string    `json:"holiday,omitempty"`
+	// Parte do dia em folga do time (1 = dia inteiro)
+	TeamDayOff float64 `json:"teamDayOff,omitempty"`
+	// Dia na zona congelada (FREEZE_DAYS_BEFORE_END)
+	Frozen bool `json:"frozen,omitempty"`
+}
+
+type SprintCalendar struct {
+	Sprint      string    `json:"sprint"`
+	SprintStart time.Time `json:"sprintStart"`
+	SprintEnd   time.Time `json:"sprintEnd"`
+	WorkingDays int       `json:"workingDays"`
+	// Primeiro dia congelado e atividades que podem usá-lo; ausentes sem
+	// congelamento
+	FreezeStart            *time.Time          `json:"freezeStart,omitempty"`
+	FreezeExemptActivities []string            `json:"freezeExemptActivities,omitempty"`
+	Days                   []SprintCalendarDay `json:"days"`
+}
+
+// Handler de GET /calendar?sprint=: os dias da sprint com o que decide cada
+// um no cálculo (fim de semana, feriado, folga do time, congelamento)
+func handleSprintCalendar(pool *adoPool, cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ref, err := sprintRefFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cal, err := calendarForRequest(cfg, r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		workClient, err := pool.Work(ctx, "calendar")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
+			return
+		}
+		targetIteration, err := resolveSprintRef(ctx, workClient, cfg, ref)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		setSprintHeaders(w, targetIteration)
+		sprintStart, sprintEnd, err := sprintDates(targetIteration)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		if sprintEnd.Sub(sprintStart) > maxCalendarDays*24*time.Hour {
+			respondError(w, "", fmt.Errorf("%w: %s a %s excede %d dias", ErrInvalidDateRange,
+				sprintStart.Format("2006-01-02"), sprintEnd.Format("2006-01-02"), maxCalendarDays))
+			return
+		}
+		teamDaysOff, err := fetchTeamDaysOff(ctx, workClient, cfg, targetIteration)
+		if err != nil {
+			respondError(w, "Erro ao buscar folgas do time", err)
+			return
+		}
+		freeze, err := newSprintFreeze(cfg, sprintStart, sprintEnd, teamDaysOff, cal)
+		if err != nil {
+			respondError(w, fmt.Sprintf("Datas inválidas na sprint '%s'", *targetIteration.Name), err)
+			return
+		}
+
+		response := SprintCalendar{Sprint: *targetIteration.Name, SprintStart: sprintStart, SprintEnd: sprintEnd, Days: []SprintCalendarDay{}}
+		if freeze.enabled() {
+			response.FreezeStart, response.FreezeExemptActivities = &freeze.Start, freeze.Exempt
+		}
+		for current := sprintStart; !current.After(sprintEnd); current = current.AddDate(0, 0, 1) {
+			day := SprintCalendarDay{
+				Date:       current,
+				DayOfWeek:  current.Weekday().String(),
+				Weekend:    cal.isWeekend(current.Weekday()),
+				TeamDayOff: cal.dayOffFraction(current, teamDaysOff),
+				Frozen:     freeze.frozen(current),
+			}
+			if holiday, ok := cal.Holidays[current]; ok {
+				day.Holiday = holiday.Name
+				if day.Holiday == "" {
+					day.Holiday = current.Format("2006-01-02")
+				}
+			}
+			day.Working = cal.dayWeight(current) > 0 && day.TeamDayOff < 1
+			if day.Working {
+				response.WorkingDays++
+			}
+			response.Days = append(response.Days, day)
+		}
+		writeJSON(w, http.StatusOK, response)
+	}
+}
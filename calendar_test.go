@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// Função para montar um dia (meia-noite UTC), como o Azure DevOps devolve as datas de sprint
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("fuso %s indisponível: %v", name, err)
+	}
+	return loc
+}
+
+func TestCalculateWorkingDaysIgnoresTimezoneForDates(t *testing.T) {
+	saoPaulo := mustLocation(t, "America/Sao_Paulo")
+	tests := []struct {
+		name       string
+		start, end time.Time
+		daysOff    []DayOff
+		loc        *time.Location
+		want       float64
+	}{
+		{name: "semana de segunda a sexta em UTC", start: day(2024, 3, 4), end: day(2024, 3, 8), loc: time.UTC, want: 5},
+		{name: "semana de segunda a sexta em São Paulo", start: day(2024, 3, 4), end: day(2024, 3, 8), loc: saoPaulo, want: 5},
+		// Horário de verão de 2018 começou em 04/11, quando a meia-noite local não existiu
+		{name: "duas semanas com início do horário de verão", start: day(2018, 10, 29), end: day(2018, 11, 9), loc: saoPaulo, want: 10},
+		// E terminou em 17/02/2019, com o dia 16 tendo 25 horas
+		{name: "duas semanas com fim do horário de verão", start: day(2019, 2, 11), end: day(2019, 2, 22), loc: saoPaulo, want: 10},
+		{name: "duas semanas com horário de verão em UTC", start: day(2018, 10, 29), end: day(2018, 11, 9), loc: time.UTC, want: 10},
+		{
+			name:  "horários fora da meia-noite vindos do Azure DevOps",
+			start: time.Date(2024, 3, 4, 3, 0, 0, 0, time.UTC),
+			end:   time.Date(2024, 3, 8, 23, 59, 59, 0, time.UTC),
+			loc:   saoPaulo,
+			want:  5,
+		},
+		{
+			name:    "folga de um dia em São Paulo",
+			start:   day(2024, 3, 4),
+			end:     day(2024, 3, 8),
+			daysOff: []DayOff{{Start: day(2024, 3, 4), End: day(2024, 3, 4)}},
+			loc:     saoPaulo,
+			want:    4,
+		},
+		{
+			name:    "meia folga no dia da mudança de horário",
+			start:   day(2018, 10, 29),
+			end:     day(2018, 11, 9),
+			daysOff: []DayOff{{Start: day(2018, 11, 5), End: day(2018, 11, 5), Fraction: 0.5}},
+			loc:     saoPaulo,
+			want:    9.5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := calculateWorkingDays(tt.start, tt.end, tt.daysOff, workCalendar{Location: tt.loc})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("calculateWorkingDays = %v, quer %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkingDatesKeepsWeekdaysInWesternTimezone(t *testing.T) {
+	cal := workCalendar{Location: mustLocation(t, "America/Sao_Paulo")}
+	dates, err := workingDates(day(2024, 3, 4), day(2024, 3, 10), nil, cal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dates) != 5 {
+		t.Fatalf("workingDates devolveu %d dias, quer 5: %v", len(dates), dates)
+	}
+	for i, date := range dates {
+		if want := time.Weekday(i + 1); date.Weekday() != want {
+			t.Errorf("dia %d é %s, quer %s", i, date.Weekday(), want)
+		}
+	}
+}
+
+func TestHolidaysInRangeInWesternTimezone(t *testing.T) {
+	cal := workCalendar{
+		Location: mustLocation(t, "America/Sao_Paulo"),
+		Holidays: map[time.Time]Holiday{day(2024, 3, 8): {Date: day(2024, 3, 8), Name: "Feriado"}},
+	}
+	holidays := holidaysInRange(day(2024, 3, 4), day(2024, 3, 8), cal)
+	if len(holidays) != 1 {
+		t.Fatalf("holidaysInRange devolveu %v, quer o feriado de sexta", holidays)
+	}
+	days, err := calculateWorkingDays(day(2024, 3, 4), day(2024, 3, 8), nil, cal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if days != 4 {
+		t.Errorf("calculateWorkingDays = %v, quer 4", days)
+	}
+}
+
+func TestElapsedWorkingDaysUsesTimezoneForTimestamps(t *testing.T) {
+	saoPaulo := mustLocation(t, "America/Sao_Paulo")
+	// 02:00 UTC de terça ainda é segunda à noite em São Paulo
+	from := time.Date(2024, 3, 5, 2, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 5, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		loc  *time.Location
+		want float64
+	}{
+		{loc: time.UTC, want: 0},
+		{loc: saoPaulo, want: 1},
+	}
+	for _, tt := range tests {
+		got, err := elapsedWorkingDays(from, to, workCalendar{Location: tt.loc})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("elapsedWorkingDays em %s = %v, quer %v", tt.loc, got, tt.want)
+		}
+	}
+}
+
+func TestCalendarPathologicalRanges(t *testing.T) {
+	cal := workCalendar{Location: time.UTC}
+	tests := []struct {
+		name       string
+		start, end time.Time
+		want       float64
+		wantErr    bool
+	}{
+		{name: "fim antes do início", start: day(2024, 3, 8), end: day(2024, 3, 4), want: 0},
+		{name: "mesmo dia útil", start: day(2024, 3, 4), end: day(2024, 3, 4), want: 1},
+		{name: "mesmo dia de fim de semana", start: day(2024, 3, 9), end: day(2024, 3, 9), want: 0},
+		{name: "início na data zero", start: time.Time{}, end: day(2024, 3, 8), wantErr: true},
+		{name: "fim muito distante", start: day(2024, 3, 4), end: day(9999, 12, 31), wantErr: true},
+		{name: "limite exato", start: day(2024, 1, 1), end: day(2024, 1, 1).AddDate(0, 0, maxCalendarDays), want: 525},
+		{name: "um dia além do limite", start: day(2024, 1, 1), end: day(2024, 1, 1).AddDate(0, 0, maxCalendarDays+1), wantErr: true},
+		{name: "fim na data zero", start: day(2024, 3, 4), end: time.Time{}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := calculateWorkingDays(tt.start, tt.end, nil, cal)
+			dates, datesErr := workingDates(tt.start, tt.end, nil, cal)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidDateRange) || !errors.Is(datesErr, ErrInvalidDateRange) {
+					t.Fatalf("erros = %v / %v, quer ErrInvalidDateRange", err, datesErr)
+				}
+				return
+			}
+			if err != nil || datesErr != nil {
+				t.Fatalf("erros inesperados: %v / %v", err, datesErr)
+			}
+			if got != tt.want || float64(len(dates)) != tt.want {
+				t.Errorf("calculateWorkingDays = %v, workingDates = %d dias, quer %v", got, len(dates), tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkingDaysAfterPathologicalRanges(t *testing.T) {
+	cal := workCalendar{Location: time.UTC}
+	if got, err := workingDaysAfter(day(2024, 3, 8), day(2024, 3, 4), cal); err != nil || got != 0 {
+		t.Errorf("fim antes do início = %v, %v; quer 0", got, err)
+	}
+	if _, err := workingDaysAfter(time.Time{}, day(2024, 3, 4), cal); !errors.Is(err, ErrInvalidDateRange) {
+		t.Errorf("data zero = %v, quer ErrInvalidDateRange", err)
+	}
+	if _, err := elapsedWorkingDays(day(2024, 3, 4), day(2024, 3, 4).AddDate(10, 0, 0), cal); !errors.Is(err, ErrInvalidDateRange) {
+		t.Errorf("dez anos = %v, quer ErrInvalidDateRange", err)
+	}
+}
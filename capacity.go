@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/core"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+)
+
+// Situação de cada membro na cópia de capacidade
+const (
+	capacityCopied    = "copied"
+	capacityWouldCopy = "would-copy"
+	capacitySkipped   = "skipped"
+	capacityFailed    = "failed"
+)
+
+// Resultado da cópia de capacidade de um membro do time
+type CapacityCopyResult struct {
+	Member     string             `json:"member"`
+	Status     string             `json:"status"`
+	Reason     string             `json:"reason,omitempty"`
+	Activities []CapacityActivity `json:"activities,omitempty"`
+}
+
+// Atividade copiada (nome e horas por dia)
+type CapacityActivity struct {
+	Name           string  `json:"name"`
+	CapacityPerDay float64 `json:"capacityPerDay"`
+}
+
+type CapacityCopyResponse struct {
+	From    string               `json:"from"`
+	To      string               `json:"to"`
+	DryRun  bool                 `json:"dryRun"`
+	Copied  int                  `json:"copied"`
+	Skipped int                  `json:"skipped"`
+	Failed  int                  `json:"failed"`
+	Results []CapacityCopyResult `json:"results"`
+}
+
+// Função para identificar um membro da capacidade pelo nome de exibição
+func capacityMemberName(member work.TeamMemberCapacityIdentityRef) string {
+	if member.TeamMember == nil {
+		return ""
+	}
+	if member.TeamMember.DisplayName != nil && *member.TeamMember.DisplayName != "" {
+		return *member.TeamMember.DisplayName
+	}
+	if member.TeamMember.UniqueName != nil {
+		return *member.TeamMember.UniqueName
+	}
+	return ""
+}
+
+// Função para verificar se um membro tem alguma atividade com horas configuradas
+func hasCapacity(member work.TeamMemberCapacityIdentityRef) bool {
+	if member.Activities == nil {
+		return false
+	}
+	for _, activity := range *member.Activities {
+		if activity.CapacityPerDay != nil && *activity.CapacityPerDay > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Função para converter as capacidades do Azure DevOps para o modelo do
+// serviço, indexadas por identityKey (a mesma chave usada para System.AssignedTo)
+func teamMemberCapacities(capacity *work.TeamCapacity) map[string]TeamMemberCapacity {
+	capacities := make(map[string]TeamMemberCapacity)
+	if capacity == nil || capacity.TeamMembers == nil {
+		return capacities
+	}
+	for _, member := range *capacity.TeamMembers {
+		name := capacityMemberName(member)
+		if name == "" {
+			continue
+		}
+		memberCapacity := TeamMemberCapacity{
+			Name:       name,
+			Activities: []CapacityActivity{},
+			DaysOff:    []DayOff{},
+		}
+		var uniqueName, descriptor, displayName string
+		if member.TeamMember.UniqueName != nil {
+			uniqueName = *member.TeamMember.UniqueName
+		}
+		if member.TeamMember.Descriptor != nil {
+			descriptor = *member.TeamMember.Descriptor
+		}
+		if member.TeamMember.DisplayName != nil {
+			displayName = *member.TeamMember.DisplayName
+		}
+		memberCapacity.Email, _ = identityEmail(uniqueName, descriptor)
+		if member.Activities != nil {
+			for _, activity := range *member.Activities {
+				if activity.CapacityPerDay == nil {
+					continue
+				}
+				activityName := ""
+				if activity.Name != nil {
+					activityName = *activity.Name
+				}
+				memberCapacity.Activities = append(memberCapacity.Activities, CapacityActivity{
+					Name:           activityName,
+					CapacityPerDay: float64(*activity.CapacityPerDay),
+				})
+			}
+		}
+		memberCapacity.DaysOff = toDaysOff(member.DaysOff)
+		capacities[identityKey(uniqueName, descriptor, displayName)] = memberCapacity
+	}
+	return capacities
+}
+
+// Função para buscar as capacidades de uma sprint, indexadas por identityKey
+func fetchTeamCapacities(ctx context.Context, workClient work.Client, cfg *Config, iteration *work.TeamSettingsIteration) (map[string]TeamMemberCapacity, error) {
+	teamCapacity, err := workClient.GetCapacitiesWithIdentityRefAndTotals(ctx, work.GetCapacitiesWithIdentityRefAndTotalsArgs{
+		Project:     &cfg.Project,
+		Team:        &cfg.Team,
+		IterationId: iteration.Id,
+	})
+	if err != nil {
+		return nil, wrapAdoError(err, "GetCapacitiesWithIdentityRefAndTotals", "sprint=%s", *iteration.Name)
+	}
+	return teamMemberCapacities(teamCapacity), nil
+}
+
+// Função para buscar as folgas do time inteiro numa sprint (feriados da
+// empresa etc.), que valem para todos
+func fetchTeamDaysOff(ctx context.Context, workClient work.Client, cfg *Config, iteration *work.TeamSettingsIteration) ([]DayOff, error) {
+	response, err := workClient.GetTeamDaysOff(ctx, work.GetTeamDaysOffArgs{
+		Project:     &cfg.Project,
+		Team:        &cfg.Team,
+		IterationId: iteration.Id,
+	})
+	if err != nil {
+		return nil, wrapAdoError(err, "GetTeamDaysOff", "sprint=%s", *iteration.Name)
+	}
+	if response == nil {
+		return []DayOff{}, nil
+	}
+	return toDaysOff(response.DaysOff), nil
+}
+
+// Função para converter intervalos de folga do Azure DevOps, ignorando os incompletos
+func toDaysOff(ranges *[]work.DateRange) []DayOff {
+	daysOff := []DayOff{}
+	if ranges == nil {
+		return daysOff
+	}
+	for _, dayOff := range *ranges {
+		if dayOff.Start == nil || dayOff.End == nil {
+			continue
+		}
+		daysOff = append(daysOff, DayOff{
+			Start: dayOff.Start.Time,
+			End:   dayOff.End.Time,
+		})
+	}
+	return daysOff
+}
+
+// Função para indexar as capacidades de uma iteração pelo ID do membro
+func capacitiesByMember(capacity *work.TeamCapacity) map[string]work.TeamMemberCapacityIdentityRef {
+	members := make(map[string]work.TeamMemberCapacityIdentityRef)
+	if capacity == nil || capacity.TeamMembers == nil {
+		return members
+	}
+	for _, member := range *capacity.TeamMembers {
+		if member.TeamMember != nil && member.TeamMember.Id != nil {
+			members[strings.ToLower(*member.TeamMember.Id)] = member
+		}
+	}
+	return members
+}
+
+// Função para calcular a utilização (horas alocadas / capacidade total).
+// Sem capacidade a razão não tem sentido e o resultado é nil.
+func utilization(allocatedHours, totalCapacity float64) *float64 {
+	if totalCapacity <= 0 {
+		return nil
+	}
+	value := allocatedHours / totalCapacity
+	return &value
+}
+
+// Handler de POST /capacity/copy?from=...&to=...: copia atividades e horas por
+// dia (sem dias de folga) de uma sprint para outra. Membros que saíram do time
+// ou que já têm capacidade na sprint de destino são ignorados.
+func handleCapacityCopy(pool *adoPool, cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			jsonError(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		fromName := r.URL.Query().Get("from")
+		toName := r.URL.Query().Get("to")
+		if fromName == "" || toName == "" {
+			jsonError(w, "Parâmetros 'from' e 'to' são obrigatórios", http.StatusBadRequest)
+			return
+		}
+		if fromName == toName {
+			jsonError(w, "As sprints 'from' e 'to' devem ser diferentes", http.StatusBadRequest)
+			return
+		}
+
+		dryRun := false
+		if value := r.URL.Query().Get("dryRun"); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				jsonError(w, fmt.Sprintf("Parâmetro 'dryRun' inválido: %q", value), http.StatusBadRequest)
+				return
+			}
+			dryRun = parsed
+		}
+
+		project := cfg.Project
+		team := cfg.Team
+		ctx := r.Context()
+
+		workClient, err := pool.Work(ctx, "capacity-copy")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
+			return
+		}
+		coreClient, err := pool.Core(ctx, "capacity-copy")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "core.NewClient", ""))
+			return
+		}
+
+		fromIteration, err := resolveSprintRef(ctx, workClient, cfg, sprintRef{Name: fromName})
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		toIteration, err := resolveSprintRef(ctx, workClient, cfg, sprintRef{Name: toName})
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		// Nomes relativos (previous, current...) podem apontar para a mesma sprint
+		if *fromIteration.Id == *toIteration.Id {
+			jsonError(w, "As sprints 'from' e 'to' devem ser diferentes", http.StatusBadRequest)
+			return
+		}
+
+		source, err := workClient.GetCapacitiesWithIdentityRefAndTotals(ctx, work.GetCapacitiesWithIdentityRefAndTotalsArgs{
+			Project:     &project,
+			Team:        &team,
+			IterationId: fromIteration.Id,
+		})
+		if err != nil {
+			respondError(w, "Erro ao buscar capacidades da sprint de origem", wrapAdoError(err, "GetCapacitiesWithIdentityRefAndTotals", "sprint=%s", fromName))
+			return
+		}
+		target, err := workClient.GetCapacitiesWithIdentityRefAndTotals(ctx, work.GetCapacitiesWithIdentityRefAndTotalsArgs{
+			Project:     &project,
+			Team:        &team,
+			IterationId: toIteration.Id,
+		})
+		if err != nil {
+			respondError(w, "Erro ao buscar capacidades da sprint de destino", wrapAdoError(err, "GetCapacitiesWithIdentityRefAndTotals", "sprint=%s", toName))
+			return
+		}
+
+		teamMembers, err := coreClient.GetTeamMembersWithExtendedProperties(ctx, core.GetTeamMembersWithExtendedPropertiesArgs{
+			ProjectId: &project,
+			TeamId:    &team,
+		})
+		if err != nil {
+			respondError(w, "Erro ao buscar membros do time", wrapAdoError(err, "GetTeamMembersWithExtendedProperties", "team=%s", cfg.TeamName))
+			return
+		}
+		currentMembers := make(map[string]bool)
+		if teamMembers != nil {
+			for _, member := range *teamMembers {
+				if member.Identity != nil && member.Identity.Id != nil {
+					currentMembers[strings.ToLower(*member.Identity.Id)] = true
+				}
+			}
+		}
+
+		targetByMember := capacitiesByMember(target)
+		response := CapacityCopyResponse{
+			From:    fromName,
+			To:      toName,
+			DryRun:  dryRun,
+			Results: make([]CapacityCopyResult, 0),
+		}
+
+		if source != nil && source.TeamMembers != nil {
+			for _, member := range *source.TeamMembers {
+				result := CapacityCopyResult{Member: capacityMemberName(member)}
+
+				if !hasCapacity(member) {
+					continue
+				}
+				for _, activity := range *member.Activities {
+					if activity.CapacityPerDay == nil || *activity.CapacityPerDay <= 0 {
+						continue
+					}
+					name := ""
+					if activity.Name != nil {
+						name = *activity.Name
+					}
+					result.Activities = append(result.Activities, CapacityActivity{Name: name, CapacityPerDay: float64(*activity.CapacityPerDay)})
+				}
+
+				var memberId uuid.UUID
+				parseErr := fmt.Errorf("membro sem ID")
+				if member.TeamMember != nil && member.TeamMember.Id != nil {
+					memberId, parseErr = uuid.Parse(*member.TeamMember.Id)
+				}
+				switch {
+				case parseErr != nil:
+					result.Status = capacitySkipped
+					result.Reason = "membro sem identificador válido"
+				case !currentMembers[strings.ToLower(*member.TeamMember.Id)]:
+					result.Status = capacitySkipped
+					result.Reason = "não faz mais parte do time"
+				case hasCapacity(targetByMember[strings.ToLower(*member.TeamMember.Id)]):
+					result.Status = capacitySkipped
+					result.Reason = "já possui capacidade na sprint de destino"
+				case dryRun:
+					result.Status = capacityWouldCopy
+				default:
+					// Apenas atividades; dias de folga são específicos de cada sprint
+					_, err := workClient.UpdateCapacityWithIdentityRef(ctx, work.UpdateCapacityWithIdentityRefArgs{
+						Patch:        &work.CapacityPatch{Activities: member.Activities},
+						Project:      &project,
+						Team:         &team,
+						IterationId:  toIteration.Id,
+						TeamMemberId: &memberId,
+					})
+					if err != nil {
+						err = wrapAdoError(err, "UpdateCapacityWithIdentityRef", "member=%s, sprint=%s", result.Member, toName)
+						log.Printf("[ERROR] %v", err)
+						result.Status = capacityFailed
+						result.Reason = err.Error()
+					} else {
+						result.Status = capacityCopied
+					}
+				}
+
+				switch result.Status {
+				case capacityCopied, capacityWouldCopy:
+					response.Copied++
+				case capacitySkipped:
+					response.Skipped++
+				case capacityFailed:
+					response.Failed++
+				}
+				response.Results = append(response.Results, result)
+			}
+		}
+
+		log.Printf("[DEBUG] Cópia de capacidade %s -> %s (dryRun=%t): %d copiados, %d ignorados, %d falhas",
+			fromName, toName, dryRun, response.Copied, response.Skipped, response.Failed)
+		writeJSON(w, http.StatusOK, response)
+	}
+}
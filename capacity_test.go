@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+)
+
+// Função para carregar a resposta gravada de GetCapacitiesWithIdentityRefAndTotals
+func loadCapacityPayload(t *testing.T) *work.TeamCapacity {
+	t.Helper()
+	data, err := os.ReadFile("testdata/capacities.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var capacity work.TeamCapacity
+	if err := json.Unmarshal(data, &capacity); err != nil {
+		t.Fatal(err)
+	}
+	return &capacity
+}
+
+func TestTeamMemberCapacitiesFromRecordedPayload(t *testing.T) {
+	capacities := teamMemberCapacities(loadCapacityPayload(t))
+
+	tests := []struct {
+		key        string
+		name       string
+		email      string
+		activities []CapacityActivity
+		daysOff    int
+	}{
+		{
+			key:        "ana.souza@example.com",
+			name:       "Ana Souza",
+			email:      "Ana.Souza@example.com",
+			activities: []CapacityActivity{{Name: "Development", CapacityPerDay: 4}, {Name: "Testing", CapacityPerDay: 2}},
+			daysOff:    1,
+		},
+		{
+			key:        "bruno.lima@example.com",
+			name:       "Bruno Lima",
+			email:      "bruno.lima@example.com",
+			activities: []CapacityActivity{{Name: "", CapacityPerDay: 6.5}},
+		},
+		{
+			key:        `[projeto]\projeto team`,
+			name:       "Projeto Team",
+			email:      `[Projeto]\Projeto Team`,
+			activities: []CapacityActivity{{Name: "Design", CapacityPerDay: 0}},
+		},
+		{
+			// Sem uniqueName a chave é o descriptor; atividade sem horas e folga incompleta ficam de fora
+			key:        "aad.NGU5ZDkxMjEtNWU3MS00ZjYyLThkODEtNDQ0NDQ0NDQ0NDQ0",
+			name:       "Carla Dias",
+			email:      "aad.NGU5ZDkxMjEtNWU3MS00ZjYyLThkODEtNDQ0NDQ0NDQ0NDQ0",
+			activities: []CapacityActivity{},
+			daysOff:    1,
+		},
+	}
+	if len(capacities) != len(tests) {
+		t.Fatalf("%d capacidades, quer %d: %v", len(capacities), len(tests), capacities)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			capacity, ok := capacities[tt.key]
+			if !ok {
+				t.Fatalf("sem capacidade para a chave %q", tt.key)
+			}
+			if capacity.Name != tt.name || capacity.Email != tt.email {
+				t.Errorf("membro = {%q, %q}, quer {%q, %q}", capacity.Name, capacity.Email, tt.name, tt.email)
+			}
+			if len(capacity.Activities) != len(tt.activities) {
+				t.Fatalf("atividades = %v, quer %v", capacity.Activities, tt.activities)
+			}
+			for i := range tt.activities {
+				if capacity.Activities[i] != tt.activities[i] {
+					t.Errorf("atividade %d = %v, quer %v", i, capacity.Activities[i], tt.activities[i])
+				}
+			}
+			if len(capacity.DaysOff) != tt.daysOff {
+				t.Errorf("%d folgas, quer %d", len(capacity.DaysOff), tt.daysOff)
+			}
+		})
+	}
+}
+
+func TestMemberTotalCapacityFromRecordedPayload(t *testing.T) {
+	capacities := teamMemberCapacities(loadCapacityPayload(t))
+	cal := workCalendar{Location: time.UTC}
+	teamDaysOff := []DayOff{{Start: day(2024, 3, 8), End: day(2024, 3, 8)}}
+
+	// Sprint de 04/03 a 15/03 (10 dias úteis); Ana tem 6h/dia e folga em 06 e 07/03,
+	// o time folga em 08/03: 7 dias × 6h
+	total, err := memberTotalCapacity(capacities["ana.souza@example.com"], day(2024, 3, 4), day(2024, 3, 15), teamDaysOff, cal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 42 {
+		t.Errorf("capacidade de Ana = %v, quer 42", total)
+	}
+
+	// Bruno: 6.5h/dia em 9 dias (só a folga do time)
+	total, err = memberTotalCapacity(capacities["bruno.lima@example.com"], day(2024, 3, 4), day(2024, 3, 15), teamDaysOff, cal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 58.5 {
+		t.Errorf("capacidade de Bruno = %v, quer 58.5", total)
+	}
+}
+
+func TestHasCapacityFromRecordedPayload(t *testing.T) {
+	payload := loadCapacityPayload(t)
+	want := map[string]bool{"Ana Souza": true, "Bruno Lima": true, "Projeto Team": false, "Carla Dias": false}
+	for _, member := range *payload.TeamMembers {
+		name := capacityMemberName(member)
+		if got := hasCapacity(member); got != want[name] {
+			t.Errorf("hasCapacity(%s) = %t, quer %t", name, got, want[name])
+		}
+	}
+}
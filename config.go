@@ -0,0 +1,795 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config reúne as configurações lidas das variáveis de ambiente
+type Config struct {
+	// AZURE_DEVOPS_PAT (ou AZURE_DEVOPS_PAT_FILE, relido em execução)
+	PAT          *secret
+	Organization string
+	Project      string
+	// Nome configurado; após resolveTeam passa a ser o ID do time
+	Team string
+	// Nome canônico do time, preenchido por resolveTeam
+	TeamName string
+
+	// Aquece clientes e sprint atual em segundo plano ao iniciar (PREFETCH)
+	Prefetch bool
+	// Janela, em dias ao redor de hoje, usada para procurar sprints por nome
+	// antes de recorrer à lista completa de iterações
+	SprintLookupWindowDays int
+	// Tempo máximo de processamento de uma requisição
+	RequestTimeout time.Duration
+	// Tipos de work item tratados como itens de backlog (WORK_ITEM_TYPES)
+	WorkItemTypes []string
+	// Tipos dos itens filhos das User Stories cujo trabalho é somado nelas
+	// (CHILD_WORK_ITEM_TYPES, padrão Task)
+	ChildWorkItemTypes []string
+	// Estados de itens concluídos, fora de /overdue (DONE_STATES, padrão
+	// Closed, Removed e Resolved)
+	DoneStates []string
+	// Máximo de itens devolvidos por GET /search (SEARCH_MAX_RESULTS, padrão 200)
+	SearchMaxResults int
+	// IDs de exemplo por motivo no diagnóstico da geração de datas
+	// (DIAGNOSTIC_EXAMPLES, padrão 5; 0 mostra só as contagens)
+	DiagnosticExamples int
+	// GET /leaderboard ativo (LEADERBOARD_ENABLED, padrão true; o time pode
+	// desligar com leaderboard: false em TEAMS_CONFIG)
+	LeaderboardEnabled bool
+	// Campos adicionais (reference names) repassados em extraFields
+	ExtraFields []string
+	// Conta sábados e domingos como dias úteis (releases de fim de semana)
+	IncludeWeekends bool
+	// Peso de um dia de fim de semana quando IncludeWeekends está ativo
+	WeekendCapacityFactor float64
+	// Fuso usado para saber o dia de hoje e de horários (TIMEZONE, padrão
+	// UTC). As datas de sprint, folgas e feriados são dias e não passam por ele.
+	Location *time.Location
+	// Dias da semana que não são trabalhados (WEEKEND_DAYS, padrão sábado e domingo)
+	WeekendDays map[time.Weekday]bool
+	// Feriados (HOLIDAYS e HOLIDAYS_CALENDAR_FILE) descontados de todos
+	Holidays map[time.Time]Holiday
+	// Janelas de disponibilidade (AVAILABILITY_FILE) de quem entra ou sai
+	// do time no meio da sprint, indexadas pelo uniqueName em minúsculas
+	Availability map[string]AvailabilityWindow
+	// Horas por dia usadas para quem tem tasks mas não tem capacidade na sprint
+	DefaultCapacityPerDay float64
+	// Ordem das atividades das tasks de uma mesma User Story
+	// (ACTIVITY_ORDER, por exemplo Development,Testing): na estratégia rollup,
+	// tasks de uma atividade posterior só começam depois do fim das anteriores.
+	// Vazia desativa a regra.
+	ActivityOrder []string
+	// Últimos dias úteis da sprint congelados (FREEZE_DAYS_BEFORE_END, padrão
+	// 0 desativa): o cálculo não põe trabalho nem datas neles, fora as
+	// atividades de FreezeExemptActivities (FREEZE_EXEMPT_ACTIVITIES, por
+	// exemplo Testing)
+	FreezeDaysBeforeEnd    int
+	FreezeExemptActivities []string
+	// Horas estimadas por Story Point para User Stories sem tasks estimadas
+	// (HOURS_PER_STORY_POINT), usadas na geração com strategy=capacity
+	HoursPerStoryPoint float64
+	// Unidade do cálculo das estratégias capacity e rollup (GRANULARITY,
+	// hours ou days; padrão hours). Em days as tasks são estimadas em dias em
+	// DayEstimateField e cada uma ocupa dias úteis inteiros.
+	Granularity string
+	// Campo com a estimativa em dias das tasks e, sem tasks estimadas, da
+	// própria User Story (DAY_ESTIMATE_FIELD); obrigatório com GRANULARITY=days
+	DayEstimateField string
+	// Parte da capacidade do Azure DevOps que vira trabalho nos itens
+	// (FOCUS_FACTOR, padrão 1), aplicada às horas por dia no cálculo das
+	// estratégias capacity e rollup, em /simulate e em /at-risk
+	FocusFactor float64
+	// Horas por dia de cerimônias (daily, planning, review...) descontadas da
+	// capacidade de cada desenvolvedor antes de FOCUS_FACTOR
+	// (CEREMONY_HOURS_PER_DAY, padrão 0)
+	CeremonyHoursPerDay float64
+	// Folga aplicada por POST /generate-due-dates quando a requisição não
+	// informa bufferPercent nem bufferDays (DUE_DATE_BUFFER, por exemplo 15%
+	// ou 2d; padrão sem folga)
+	DueDateBuffer generationBuffer
+	// Responsáveis (e-mail ou nome de exibição) cujas User Stories ficam fora
+	// da geração de datas (EXCLUDED_ASSIGNEES)
+	ExcludedAssignees []string
+	// Campo gravado por POST /generate-due-dates e pelo rollback das gerações
+	// (DUE_DATE_WRITE_FIELD, padrão Microsoft.VSTS.Scheduling.DueDate)
+	DueDateWriteField string
+	// Arquivo YAML com os ajustes por time (TEAMS_CONFIG); vazio usa só o ambiente
+	TeamsConfigFile string
+	// Perfil de TEAMS_CONFIG aplicado a esta configuração (nil sem perfil)
+	Profile *TeamProfile
+	// Percentual acima da capacidade tolerado antes de marcar um desenvolvedor
+	// como sobrealocado (OVERALLOCATION_THRESHOLD, padrão 0)
+	OverallocationThreshold float64
+	// Chave exigida no header X-Admin-Key pelos endpoints que escrevem no
+	// Azure DevOps; vazia desabilita esses endpoints. Aceita várias chaves
+	// separadas por vírgula (uma por pessoa, para a aprovação). Também pode
+	// vir de ADMIN_API_KEY_FILE, relido em execução.
+	AdminAPIKey *secret
+	// Gerações com escrita ficam pendentes até outra chave aprovar em
+	// POST /runs/{id}/approve (REQUIRE_APPROVAL=true)
+	RequireApproval bool
+	// Prazo para aprovar uma geração pendente (APPROVAL_TTL, padrão 24h)
+	ApprovalTTL time.Duration
+	// Intervalo de releitura dos segredos vindos de *_FILE
+	// (SECRETS_RELOAD_INTERVAL, padrão 5m); zero relê só em falhas de autenticação
+	SecretsReloadInterval time.Duration
+	// Máximo de chamadas simultâneas ao Azure DevOps, somando todos os chamadores
+	AdoMaxConcurrency int
+	// Arquivo JSON onde as execuções que gravam datas são guardadas para
+	// rollback (DUE_DATE_RUNS_FILE); vazio mantém apenas em memória
+	DueDateRunsFile string
+	// Retenção das execuções registradas: quantidade (RUNS_RETENTION_COUNT,
+	// padrão 500) e idade em dias (RUNS_RETENTION_DAYS); zero não limita
+	RunsRetentionCount int
+	RunsRetentionDays  int
+	// Por quanto tempo uma resposta fica guardada para um Idempotency-Key
+	// repetido (IDEMPOTENCY_WINDOW, padrão 24h)
+	IdempotencyWindow time.Duration
+	// Tag acrescentada aos work items gravados por POST /generate-due-dates
+	// (GENERATED_TAG, padrão duedate-generated); vazia não marca
+	GeneratedTag string
+	// Tag das User Stories de um Delivery Plan (DELIVERY_PLAN_TAG); depois de
+	// cada geração elas precisam de StartDate e da data gerada. Vazia não verifica.
+	DeliveryPlanTag string
+	// Campo com a data comprometida no Delivery Plan
+	// (DELIVERY_PLAN_COMMITTED_FIELD); vazio não compara
+	DeliveryPlanCommittedField string
+	// Tag que marca uma User Story como bloqueada, além do campo
+	// Microsoft.VSTS.CMMI.Blocked (BLOCKED_TAG, padrão blocked); vazia não marca
+	BlockedTag string
+	// Dias úteis desde a última geração a partir dos quais o plano da sprint
+	// é considerado desatualizado (PLAN_STALE_AFTER_DAYS, padrão 5); zero
+	// considera só as User Stories novas
+	PlanStaleAfterDays int
+	// Webhook de entrada do Teams ou do Slack que recebe os eventos de
+	// notificação (NOTIFY_WEBHOOK_URL ou NOTIFY_WEBHOOK_URL_FILE, antes
+	// STALE_PLAN_WEBHOOK_URL). A URL carrega o token de acesso e é tratada
+	// como segredo; vazio deixa só o canal de log
+	NotifyWebhook *secret
+	// Eventos enviados ao webhook (NOTIFY_WEBHOOK_EVENTS, padrão
+	// run.completed,run.failed,plan.stale)
+	NotifyWebhookEvents []EventType
+	// Intervalo entre as verificações do plano da sprint atual, que publicam
+	// plan.stale e digest.due (STALE_PLAN_CHECK_INTERVAL, padrão 24h)
+	StalePlanCheckInterval time.Duration
+	// Texto do comentário deixado com ?comment=true em POST /generate-due-dates
+	// (DUE_DATE_COMMENT_TEMPLATE), com os marcadores de dueDateCommentFields
+	DueDateCommentTemplate string
+}
+
+// Função para validar a URL de um webhook de entrada (NOTIFY_WEBHOOK_URL)
+func validateWebhookURL(value string) error {
+	webhook, err := url.Parse(strings.TrimSpace(value))
+	if err != nil || (webhook.Scheme != "http" && webhook.Scheme != "https") || webhook.Host == "" {
+		return fmt.Errorf("NOTIFY_WEBHOOK_URL inválido: use uma URL http(s) de webhook de entrada")
+	}
+	return nil
+}
+
+// Função para carregar e validar a configuração a partir do ambiente
+func loadConfig() (*Config, error) {
+	pat, err := loadSecret("AZURE_DEVOPS_PAT")
+	if err != nil {
+		return nil, err
+	}
+	adminAPIKey, err := loadSecret("ADMIN_API_KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{
+		PAT:                    pat,
+		Organization:           os.Getenv("AZURE_DEVOPS_ORG"),
+		Project:                os.Getenv("AZURE_DEVOPS_PROJECT"),
+		Team:                   os.Getenv("AZURE_DEVOPS_TEAM"),
+		Prefetch:               os.Getenv("PREFETCH") == "true",
+		SprintLookupWindowDays: 90,
+		RequestTimeout:         60 * time.Second,
+		AdoMaxConcurrency:      8,
+		IncludeWeekends:        os.Getenv("INCLUDE_WEEKENDS") == "true",
+		LeaderboardEnabled:     os.Getenv("LEADERBOARD_ENABLED") != "false",
+		WeekendCapacityFactor:  1.0,
+		DefaultCapacityPerDay:  8.0,
+		HoursPerStoryPoint:     8.0,
+		FocusFactor:            1.0,
+		DueDateWriteField:      dueDateField,
+		TeamsConfigFile:        strings.TrimSpace(os.Getenv("TEAMS_CONFIG")),
+		Location:               time.UTC,
+		AdminAPIKey:            adminAPIKey,
+		RequireApproval:        os.Getenv("REQUIRE_APPROVAL") == "true",
+		ApprovalTTL:            24 * time.Hour,
+		DueDateRunsFile:        os.Getenv("DUE_DATE_RUNS_FILE"),
+		RunsRetentionCount:     500,
+		SearchMaxResults:       200,
+		DiagnosticExamples:     5,
+		IdempotencyWindow:      24 * time.Hour,
+		GeneratedTag:           "duedate-generated",
+		BlockedTag:             "blocked",
+		DueDateCommentTemplate: defaultDueDateCommentTemplate,
+		SecretsReloadInterval:  5 * time.Minute,
+		PlanStaleAfterDays:     5,
+		NotifyWebhookEvents:    defaultWebhookEvents,
+		StalePlanCheckInterval: 24 * time.Hour,
+	}
+
+	if cfg.PAT.Value() == "" || cfg.Organization == "" || cfg.Project == "" || cfg.Team == "" {
+		return nil, fmt.Errorf("Todas as variáveis de ambiente são obrigatórias: AZURE_DEVOPS_PAT (ou AZURE_DEVOPS_PAT_FILE), AZURE_DEVOPS_ORG, AZURE_DEVOPS_PROJECT, AZURE_DEVOPS_TEAM")
+	}
+
+	if value := os.Getenv("SPRINT_LOOKUP_WINDOW_DAYS"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return nil, fmt.Errorf("SPRINT_LOOKUP_WINDOW_DAYS inválido: %q", value)
+		}
+		cfg.SprintLookupWindowDays = days
+	}
+
+	cfg.ExtraFields = splitList(os.Getenv("EXTRA_FIELDS"))
+
+	// "Product Backlog Item" no processo Scrum, "Requirement" no CMMI
+	cfg.WorkItemTypes = splitList(os.Getenv("WORK_ITEM_TYPES"))
+	if len(cfg.WorkItemTypes) == 0 {
+		cfg.WorkItemTypes = []string{"User Story"}
+	}
+
+	cfg.ChildWorkItemTypes = splitList(os.Getenv("CHILD_WORK_ITEM_TYPES"))
+	if len(cfg.ChildWorkItemTypes) == 0 {
+		cfg.ChildWorkItemTypes = []string{defaultChildType}
+	}
+
+	cfg.ActivityOrder = splitList(os.Getenv("ACTIVITY_ORDER"))
+
+	if value := os.Getenv("FREEZE_DAYS_BEFORE_END"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return nil, fmt.Errorf("FREEZE_DAYS_BEFORE_END inválido: %q (use um número inteiro de dias úteis, 0 desativa)", value)
+		}
+		cfg.FreezeDaysBeforeEnd = days
+	}
+	cfg.FreezeExemptActivities = splitList(os.Getenv("FREEZE_EXEMPT_ACTIVITIES"))
+
+	cfg.DoneStates = splitList(os.Getenv("DONE_STATES"))
+	if len(cfg.DoneStates) == 0 {
+		cfg.DoneStates = []string{closedState, removedState, "Resolved"}
+	}
+
+	if value := os.Getenv("REQUEST_TIMEOUT"); value != "" {
+		timeout, err := parseDurationSetting(value)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("REQUEST_TIMEOUT inválido: %q", value)
+		}
+		cfg.RequestTimeout = timeout
+	}
+
+	if value := os.Getenv("ADO_MAX_CONCURRENCY"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("ADO_MAX_CONCURRENCY inválido: %q", value)
+		}
+		cfg.AdoMaxConcurrency = limit
+	}
+
+	if value := os.Getenv("RUNS_RETENTION_COUNT"); value != "" {
+		count, err := strconv.Atoi(value)
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("RUNS_RETENTION_COUNT inválido: %q", value)
+		}
+		cfg.RunsRetentionCount = count
+	}
+
+	if value := os.Getenv("RUNS_RETENTION_DAYS"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return nil, fmt.Errorf("RUNS_RETENTION_DAYS inválido: %q", value)
+		}
+		cfg.RunsRetentionDays = days
+	}
+
+	if value := os.Getenv("SEARCH_MAX_RESULTS"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("SEARCH_MAX_RESULTS inválido: %q", value)
+		}
+		cfg.SearchMaxResults = limit
+	}
+
+	if value := os.Getenv("DIAGNOSTIC_EXAMPLES"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("DIAGNOSTIC_EXAMPLES inválido: %q", value)
+		}
+		cfg.DiagnosticExamples = limit
+	}
+
+	if value := os.Getenv("IDEMPOTENCY_WINDOW"); value != "" {
+		window, err := parseDurationSetting(value)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("IDEMPOTENCY_WINDOW inválido: %q", value)
+		}
+		cfg.IdempotencyWindow = window
+	}
+
+	// Aprovação por outra pessoa: precisa de pelo menos duas chaves
+	if value := os.Getenv("APPROVAL_TTL"); value != "" {
+		ttl, err := parseDurationSetting(value)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("APPROVAL_TTL inválido: %q", value)
+		}
+		cfg.ApprovalTTL = ttl
+	}
+	if cfg.RequireApproval && len(cfg.adminKeys()) < 2 {
+		return nil, fmt.Errorf("REQUIRE_APPROVAL=true exige pelo menos duas chaves em ADMIN_API_KEY, separadas por vírgula: quem aprova precisa usar outra chave")
+	}
+
+	if value := os.Getenv("SECRETS_RELOAD_INTERVAL"); value != "" {
+		interval, err := parseDurationSetting(value)
+		if err != nil || interval < 0 {
+			return nil, fmt.Errorf("SECRETS_RELOAD_INTERVAL inválido: %q", value)
+		}
+		cfg.SecretsReloadInterval = interval
+	}
+
+	if value := os.Getenv("PLAN_STALE_AFTER_DAYS"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return nil, fmt.Errorf("PLAN_STALE_AFTER_DAYS inválido: %q", value)
+		}
+		cfg.PlanStaleAfterDays = days
+	}
+
+	notifyWebhook, err := loadSecret("NOTIFY_WEBHOOK_URL")
+	if err != nil {
+		return nil, err
+	}
+	// Nome antigo, de quando o webhook só recebia o lembrete de plano desatualizado
+	if strings.TrimSpace(notifyWebhook.Value()) == "" && strings.TrimSpace(os.Getenv("STALE_PLAN_WEBHOOK_URL")) != "" {
+		log.Printf("[WARN] STALE_PLAN_WEBHOOK_URL está obsoleta; use NOTIFY_WEBHOOK_URL")
+		if notifyWebhook, err = loadSecret("STALE_PLAN_WEBHOOK_URL"); err != nil {
+			return nil, err
+		}
+	}
+	if notifyWebhook.Value() != "" {
+		if err := validateWebhookURL(notifyWebhook.Value()); err != nil {
+			return nil, err
+		}
+	}
+	notifyWebhook.validate = validateWebhookURL
+	cfg.NotifyWebhook = notifyWebhook
+	if value := os.Getenv("NOTIFY_WEBHOOK_EVENTS"); value != "" {
+		events, err := parseEventTypes(value)
+		if err != nil || len(events) == 0 {
+			return nil, fmt.Errorf("NOTIFY_WEBHOOK_EVENTS inválido: %q (use %s)", value, eventTypeNames())
+		}
+		cfg.NotifyWebhookEvents = events
+	}
+
+	if value := os.Getenv("STALE_PLAN_CHECK_INTERVAL"); value != "" {
+		interval, err := parseDurationSetting(value)
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("STALE_PLAN_CHECK_INTERVAL inválido: %q", value)
+		}
+		cfg.StalePlanCheckInterval = interval
+	}
+
+	// GENERATED_TAG definido e vazio desativa a marcação
+	if value, ok := os.LookupEnv("GENERATED_TAG"); ok {
+		tag := strings.TrimSpace(value)
+		if strings.ContainsAny(tag, ";,") {
+			return nil, fmt.Errorf("GENERATED_TAG inválido: %q (uma única tag, sem ';' ou ',')", value)
+		}
+		cfg.GeneratedTag = tag
+	}
+
+	if value := strings.TrimSpace(os.Getenv("DELIVERY_PLAN_TAG")); value != "" {
+		if strings.ContainsAny(value, ";,") {
+			return nil, fmt.Errorf("DELIVERY_PLAN_TAG inválido: %q (uma única tag, sem ';' ou ',')", value)
+		}
+		cfg.DeliveryPlanTag = value
+	}
+	if value := strings.TrimSpace(os.Getenv("DELIVERY_PLAN_COMMITTED_FIELD")); value != "" {
+		if !fieldReferencePattern.MatchString(value) {
+			return nil, fmt.Errorf("DELIVERY_PLAN_COMMITTED_FIELD inválido: %q (use o reference name do campo, por exemplo Custom.CommittedDate)", value)
+		}
+		cfg.DeliveryPlanCommittedField = value
+	}
+
+	// BLOCKED_TAG definido e vazio considera só o campo Blocked
+	if value, ok := os.LookupEnv("BLOCKED_TAG"); ok {
+		tag := strings.TrimSpace(value)
+		if strings.ContainsAny(tag, ";,") {
+			return nil, fmt.Errorf("BLOCKED_TAG inválido: %q (uma única tag, sem ';' ou ',')", value)
+		}
+		cfg.BlockedTag = tag
+	}
+
+	if value := strings.TrimSpace(os.Getenv("DUE_DATE_COMMENT_TEMPLATE")); value != "" {
+		cfg.DueDateCommentTemplate = value
+	}
+
+	if value := os.Getenv("TIMEZONE"); value != "" {
+		location, err := time.LoadLocation(value)
+		if err != nil {
+			return nil, fmt.Errorf("TIMEZONE inválido: %q: %w", value, err)
+		}
+		cfg.Location = location
+	}
+
+	weekendDays, err := parseWeekendDays(os.Getenv("WEEKEND_DAYS"))
+	if err != nil {
+		return nil, err
+	}
+	cfg.WeekendDays = weekendDays
+
+	holidays, err := loadHolidays(os.Getenv("HOLIDAYS"), os.Getenv("HOLIDAYS_CALENDAR_FILE"))
+	if err != nil {
+		return nil, err
+	}
+	cfg.Holidays = holidays
+
+	availability, err := loadAvailability(os.Getenv("AVAILABILITY_FILE"))
+	if err != nil {
+		return nil, err
+	}
+	cfg.Availability = availability
+
+	if value := os.Getenv("DEFAULT_CAPACITY_PER_DAY"); value != "" {
+		hours, err := strconv.ParseFloat(value, 64)
+		if err != nil || hours < 0 || hours > 24 {
+			return nil, fmt.Errorf("DEFAULT_CAPACITY_PER_DAY inválido: %q", value)
+		}
+		cfg.DefaultCapacityPerDay = hours
+	}
+
+	if value := os.Getenv("HOURS_PER_STORY_POINT"); value != "" {
+		hours, err := strconv.ParseFloat(value, 64)
+		if err != nil || hours <= 0 {
+			return nil, fmt.Errorf("HOURS_PER_STORY_POINT inválido: %q (use um número maior que 0)", value)
+		}
+		cfg.HoursPerStoryPoint = hours
+	}
+
+	cfg.Granularity = granularityHours
+	if value := strings.ToLower(strings.TrimSpace(os.Getenv("GRANULARITY"))); value != "" {
+		if value != granularityHours && value != granularityDays {
+			return nil, fmt.Errorf("GRANULARITY inválido: %q (use hours ou days)", value)
+		}
+		cfg.Granularity = value
+	}
+	if value := strings.TrimSpace(os.Getenv("DAY_ESTIMATE_FIELD")); value != "" {
+		if !fieldReferencePattern.MatchString(value) {
+			return nil, fmt.Errorf("DAY_ESTIMATE_FIELD inválido: %q (use o reference name do campo, por exemplo Custom.EstimateDays)", value)
+		}
+		cfg.DayEstimateField = value
+	}
+	if cfg.Granularity == granularityDays && cfg.DayEstimateField == "" {
+		return nil, fmt.Errorf("DAY_ESTIMATE_FIELD é obrigatório com GRANULARITY=days")
+	}
+
+	if value := os.Getenv("FOCUS_FACTOR"); value != "" {
+		factor, err := parseFocusFactor(value)
+		if err != nil {
+			return nil, fmt.Errorf("FOCUS_FACTOR inválido: %w", err)
+		}
+		cfg.FocusFactor = factor
+	}
+
+	if value := os.Getenv("CEREMONY_HOURS_PER_DAY"); value != "" {
+		hours, err := parseCeremonyHours(value)
+		if err != nil {
+			return nil, fmt.Errorf("CEREMONY_HOURS_PER_DAY inválido: %w", err)
+		}
+		cfg.CeremonyHoursPerDay = hours
+	}
+
+	if value := os.Getenv("DUE_DATE_BUFFER"); value != "" {
+		buffer, err := parseBufferSetting(value)
+		if err != nil {
+			return nil, fmt.Errorf("DUE_DATE_BUFFER inválido: %w", err)
+		}
+		cfg.DueDateBuffer = buffer
+	}
+
+	cfg.ExcludedAssignees = splitList(os.Getenv("EXCLUDED_ASSIGNEES"))
+
+	if value := strings.TrimSpace(os.Getenv("DUE_DATE_WRITE_FIELD")); value != "" {
+		if !fieldReferencePattern.MatchString(value) {
+			return nil, fmt.Errorf("DUE_DATE_WRITE_FIELD inválido: %q (use o reference name do campo, por exemplo Custom.CommittedDate)", value)
+		}
+		cfg.DueDateWriteField = value
+	}
+
+	if value := os.Getenv("OVERALLOCATION_THRESHOLD"); value != "" {
+		threshold, err := parseOverallocationThreshold(value)
+		if err != nil {
+			return nil, fmt.Errorf("OVERALLOCATION_THRESHOLD inválido: %w", err)
+		}
+		cfg.OverallocationThreshold = threshold
+	}
+
+	if value := os.Getenv("WEEKEND_CAPACITY_FACTOR"); value != "" {
+		factor, err := strconv.ParseFloat(value, 64)
+		if err != nil || factor <= 0 || factor > 1 {
+			return nil, fmt.Errorf("WEEKEND_CAPACITY_FACTOR inválido: %q (use um valor entre 0 e 1)", value)
+		}
+		cfg.WeekendCapacityFactor = factor
+	}
+
+	return cfg, nil
+}
+
+// Unidades aceitas em GRANULARITY
+const (
+	granularityHours = "hours"
+	granularityDays  = "days"
+)
+
+// Função para saber se o cálculo é em dias inteiros (GRANULARITY=days)
+func (cfg *Config) dayGranularity() bool {
+	return cfg.Granularity == granularityDays
+}
+
+// Tipo de item filho padrão (CHILD_WORK_ITEM_TYPES)
+const defaultChildType = "Task"
+
+// Reference name de um campo de work item (System.Title, Custom.CommittedDate)
+var fieldReferencePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*(\.[A-Za-z0-9_]+)+$`)
+
+// Função para ler a fração do dia que vira trabalho (FOCUS_FACTOR)
+func parseFocusFactor(value string) (float64, error) {
+	factor, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || factor <= 0 || factor > 1 {
+		return 0, fmt.Errorf("%q (use um valor maior que 0 e até 1)", value)
+	}
+	return factor, nil
+}
+
+// Função para ler as horas diárias de cerimônias (CEREMONY_HOURS_PER_DAY)
+func parseCeremonyHours(value string) (float64, error) {
+	hours, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || hours < 0 || hours > 24 {
+		return 0, fmt.Errorf("%q (use horas por dia entre 0 e 24)", value)
+	}
+	return hours, nil
+}
+
+// Função para ler uma folga padrão no formato "15%" (percentual da duração)
+// ou "2d" (dias úteis), com os mesmos limites de bufferPercent e bufferDays;
+// "0" desliga
+func parseBufferSetting(value string) (generationBuffer, error) {
+	var buffer generationBuffer
+	value = strings.TrimSpace(value)
+	invalid := fmt.Errorf("%q (use um percentual como 15%% ou dias úteis como 2d)", value)
+	switch {
+	case value == "0":
+		return buffer, nil
+	case strings.HasSuffix(value, "%"):
+		percent, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(value, "%")), 64)
+		if err != nil || percent < 0 || percent > maxBufferPercent {
+			return buffer, invalid
+		}
+		buffer.Percent = percent
+	case strings.HasSuffix(value, "d"):
+		days, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(value, "d")))
+		if err != nil || days < 0 || days > maxCalendarDays {
+			return buffer, invalid
+		}
+		buffer.Days = days
+	default:
+		return buffer, invalid
+	}
+	return buffer, nil
+}
+
+// Função para escrever a folga no formato de DUE_DATE_BUFFER
+func (b generationBuffer) String() string {
+	switch {
+	case b.Percent > 0:
+		return strconv.FormatFloat(b.Percent, 'f', -1, 64) + "%"
+	case b.Days > 0:
+		return strconv.Itoa(b.Days) + "d"
+	}
+	return "0"
+}
+
+// Função para obter os tipos de itens filhos, com Task quando não configurados
+func (cfg *Config) childTypes() []string {
+	if len(cfg.ChildWorkItemTypes) == 0 {
+		return []string{defaultChildType}
+	}
+	return cfg.ChildWorkItemTypes
+}
+
+// Função para obter o campo gravado pela geração, com o DueDate quando não configurado
+func (cfg *Config) writeField() string {
+	if cfg.DueDateWriteField == "" {
+		return dueDateField
+	}
+	return cfg.DueDateWriteField
+}
+
+// Função para ler um percentual de tolerância de sobrealocação (0 ou mais)
+func parseOverallocationThreshold(value string) (float64, error) {
+	threshold, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(value, "%")), 64)
+	if err != nil || threshold < 0 || math.IsInf(threshold, 0) || math.IsNaN(threshold) {
+		return 0, fmt.Errorf("%q (use um percentual maior ou igual a 0)", value)
+	}
+	return threshold, nil
+}
+
+// Função para montar o link do work item na interface web do Azure DevOps.
+// AZURE_DEVOPS_ORG pode ser só o nome da organização ou a URL completa
+// (https://dev.azure.com/org ou https://org.visualstudio.com).
+func workItemURL(cfg *Config, id int) string {
+	base := strings.TrimRight(cfg.Organization, "/")
+	if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+		base = "https://dev.azure.com/" + base
+	}
+	return fmt.Sprintf("%s/%s/_workitems/edit/%d", base, url.PathEscape(cfg.Project), id)
+}
+
+// Função para ler WEEKEND_DAYS (nomes em inglês separados por vírgula, por
+// exemplo "Friday,Saturday"). Vazio mantém sábado e domingo.
+func parseWeekendDays(value string) (map[time.Weekday]bool, error) {
+	weekendDays := map[time.Weekday]bool{time.Saturday: true, time.Sunday: true}
+	names := splitList(value)
+	if len(names) == 0 {
+		return weekendDays, nil
+	}
+
+	weekendDays = make(map[time.Weekday]bool)
+	for _, name := range names {
+		found := false
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.EqualFold(name, day.String()) {
+				weekendDays[day] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("WEEKEND_DAYS contém um dia inválido: %q (use Sunday, Monday, ..., Saturday)", name)
+		}
+	}
+	if len(weekendDays) == 7 {
+		return nil, fmt.Errorf("WEEKEND_DAYS não pode incluir todos os dias da semana")
+	}
+	return weekendDays, nil
+}
+
+// Função para carregar os feriados da lista HOLIDAYS (datas ISO separadas por
+// vírgula) e do arquivo JSON HOLIDAYS_CALENDAR_FILE, no formato
+// [{"date": "2024-11-15", "name": "Proclamação da República"}]
+func loadHolidays(list string, path string) (map[time.Time]Holiday, error) {
+	holidays := make(map[time.Time]Holiday)
+
+	for _, value := range splitList(list) {
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, fmt.Errorf("HOLIDAYS contém uma data inválida: %q (use AAAA-MM-DD)", value)
+		}
+		holidays[date] = Holiday{Date: date}
+	}
+
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Erro ao ler HOLIDAYS_CALENDAR_FILE (%s): %w", path, err)
+		}
+		var entries []struct {
+			Date string `json:"date"`
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(content, &entries); err != nil {
+			return nil, fmt.Errorf("HOLIDAYS_CALENDAR_FILE inválido (%s): %w", path, err)
+		}
+		for _, entry := range entries {
+			date, err := time.Parse("2006-01-02", entry.Date)
+			if err != nil {
+				return nil, fmt.Errorf("HOLIDAYS_CALENDAR_FILE contém uma data inválida: %q (use AAAA-MM-DD)", entry.Date)
+			}
+			holidays[date] = Holiday{Date: date, Name: entry.Name}
+		}
+	}
+
+	if len(holidays) > 0 {
+		log.Printf("[DEBUG] %d feriados carregados", len(holidays))
+	}
+	return holidays, nil
+}
+
+// Função para carregar as janelas de disponibilidade do arquivo JSON
+// AVAILABILITY_FILE, no formato
+// [{"uniqueName": "ana@empresa.com", "from": "2024-03-07", "to": "2024-03-20"}].
+// from e to são opcionais (sem limite daquele lado), mas não os dois.
+func loadAvailability(path string) (map[string]AvailabilityWindow, error) {
+	availability := make(map[string]AvailabilityWindow)
+	if path == "" {
+		return availability, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Erro ao ler AVAILABILITY_FILE (%s): %w", path, err)
+	}
+	var entries []struct {
+		UniqueName string `json:"uniqueName"`
+		From       string `json:"from"`
+		To         string `json:"to"`
+	}
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return nil, fmt.Errorf("AVAILABILITY_FILE inválido (%s): %w", path, err)
+	}
+	parse := func(value string) (*time.Time, error) {
+		if value == "" {
+			return nil, nil
+		}
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, fmt.Errorf("AVAILABILITY_FILE contém uma data inválida: %q (use AAAA-MM-DD)", value)
+		}
+		return &date, nil
+	}
+	for _, entry := range entries {
+		key := strings.ToLower(strings.TrimSpace(entry.UniqueName))
+		if key == "" {
+			return nil, fmt.Errorf("AVAILABILITY_FILE contém uma entrada sem uniqueName")
+		}
+		if _, exists := availability[key]; exists {
+			return nil, fmt.Errorf("AVAILABILITY_FILE repete o uniqueName %q", entry.UniqueName)
+		}
+		var window AvailabilityWindow
+		if window.From, err = parse(entry.From); err != nil {
+			return nil, err
+		}
+		if window.To, err = parse(entry.To); err != nil {
+			return nil, err
+		}
+		if window.From == nil && window.To == nil {
+			return nil, fmt.Errorf("AVAILABILITY_FILE: informe from, to ou os dois para %q", entry.UniqueName)
+		}
+		if window.From != nil && window.To != nil && window.To.Before(*window.From) {
+			return nil, fmt.Errorf("AVAILABILITY_FILE: to antes de from para %q", entry.UniqueName)
+		}
+		availability[key] = window
+	}
+
+	if len(availability) > 0 {
+		log.Printf("[DEBUG] %d janelas de disponibilidade carregadas", len(availability))
+	}
+	return availability, nil
+}
+
+// Função para ler durações como "90s"/"2m" ou apenas um número de segundos
+func parseDurationSetting(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// Função para obter as chaves aceitas em X-Admin-Key (ADMIN_API_KEY,
+// separadas por vírgula)
+func (cfg *Config) adminKeys() []string {
+	return splitList(cfg.AdminAPIKey.Value())
+}
+
+// Função para ler listas separadas por vírgula, ignorando itens vazios
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+// Campo de início usado pelas barras do Delivery Plan
+const startDateField = "Microsoft.VSTS.Scheduling.StartDate"
+
+// Problemas das User Stories de um Delivery Plan depois da geração
+const (
+	deliveryMissingDueDate   = "missing-due-date"
+	deliveryMissingStartDate = "missing-start-date"
+	deliveryStartAfterDue    = "start-after-due"
+	deliveryStartDateFailed  = "start-date-failed"
+	deliveryAfterCommitted   = "after-committed-date"
+)
+
+// User Story do Delivery Plan com StartDate acrescentado ou algum problema
+type DeliveryPlanItem struct {
+	ID        int        `json:"id"`
+	Title     string     `json:"title"`
+	StartDate *time.Time `json:"startDate"`
+	// StartDate acrescentado (ou que seria, em dry-run), do início mais cedo das tasks
+	StartDateAdded bool       `json:"startDateAdded,omitempty"`
+	DueDate        *time.Time `json:"dueDate"`
+	CommittedDate  *time.Time `json:"committedDate,omitempty"`
+	Issues         []string   `json:"issues"`
+	Reason         string     `json:"reason,omitempty"`
+}
+
+// Verificação do Delivery Plan no relatório da geração (DELIVERY_PLAN_TAG)
+type DeliveryPlanReport struct {
+	Tag            string `json:"tag"`
+	CommittedField string `json:"committedField,omitempty"`
+	// User Stories da geração com a tag
+	Checked         int                `json:"checked"`
+	StartDatesAdded int                `json:"startDatesAdded"`
+	WithIssues      int                `json:"withIssues"`
+	Items           []DeliveryPlanItem `json:"items"`
+}
+
+// Função para verificar as User Stories com DELIVERY_PLAN_TAG depois da
+// geração: as barras do Delivery Plan precisam de StartDate e da data, então
+// um StartDate vazio é preenchido com o início mais cedo das tasks (abertas
+// ou fechadas), e datas depois da data comprometida
+// (DELIVERY_PLAN_COMMITTED_FIELD) são apontadas. A data da User Story é a
+// gravada (ou prevista) pela geração ou, quando ela foi mantida, a existente.
+func checkDeliveryPlan(ctx context.Context, witClient workitemtracking.Client, cfg *Config, writer *dueDateWriter, stories []WorkItem, items []GenerationItem) (*DeliveryPlanReport, error) {
+	report := &DeliveryPlanReport{Tag: cfg.DeliveryPlanTag, CommittedField: cfg.DeliveryPlanCommittedField, Items: []DeliveryPlanItem{}}
+	var ids []int
+	for _, story := range stories {
+		if _, tagged := matchTag(story.tags, []string{cfg.DeliveryPlanTag}); tagged {
+			ids = append(ids, story.ID)
+		}
+	}
+	report.Checked = len(ids)
+	if len(ids) == 0 {
+		return report, nil
+	}
+
+	// Releitura depois da gravação, com a revisão atual para o StartDate
+	fields := []string{"System.Title", startDateField}
+	if cfg.DeliveryPlanCommittedField != "" {
+		fields = append(fields, cfg.DeliveryPlanCommittedField)
+	}
+	workItems, err := getWorkItemsChunked(ctx, witClient, cfg.Project, ids, fields)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := queryChildTasks(ctx, witClient, cfg, ids, []string{"System.Parent", startDateField}, true)
+	if err != nil {
+		return nil, err
+	}
+	earliest := make(map[int]time.Time)
+	for _, task := range tasks {
+		parent := getFieldFloat(task.Fields, "System.Parent")
+		start := currentFieldDate(task.Fields, startDateField)
+		if parent == nil || start == nil {
+			continue
+		}
+		day := dueDay(*start, cfg.Location)
+		if first, ok := earliest[int(*parent)]; !ok || day.Before(first) {
+			earliest[int(*parent)] = day
+		}
+	}
+	generated := make(map[int]GenerationItem, len(items))
+	for _, item := range items {
+		generated[item.ID] = item
+	}
+
+	for _, detail := range presentWorkItems(ids, workItems) {
+		id := *detail.Id
+		entry := DeliveryPlanItem{ID: id, Title: getFieldValue(detail.Fields, "System.Title"), Issues: []string{}}
+		item := generated[id]
+		switch item.Status {
+		case dueDatePlanned, dueDateUpdated, dueDateUnchanged:
+			entry.DueDate = item.DueDate
+		default:
+			entry.DueDate = item.ExistingDate
+		}
+		if entry.DueDate == nil {
+			entry.Issues = append(entry.Issues, deliveryMissingDueDate)
+		}
+
+		entry.StartDate = currentFieldDate(detail.Fields, startDateField)
+		if entry.StartDate == nil {
+			start, ok := earliest[id]
+			switch {
+			case !ok:
+				entry.Issues = append(entry.Issues, deliveryMissingStartDate)
+				entry.Reason = "sem StartDate e sem tasks com StartDate"
+			case entry.DueDate != nil && start.After(sprintDate(*entry.DueDate)):
+				entry.Issues = append(entry.Issues, deliveryStartAfterDue)
+				entry.Reason = fmt.Sprintf("início mais cedo das tasks (%s) depois da data; StartDate não gravado", start.Format("2006-01-02"))
+			default:
+				revision := 0
+				if detail.Rev != nil {
+					revision = *detail.Rev
+				}
+				if err := writer.writeStartDate(id, revision, start); err != nil {
+					entry.Issues = append(entry.Issues, deliveryStartDateFailed)
+					entry.Reason = err.Error()
+				} else {
+					entry.StartDate, entry.StartDateAdded = &start, true
+					report.StartDatesAdded++
+				}
+			}
+		}
+
+		if cfg.DeliveryPlanCommittedField != "" && entry.DueDate != nil {
+			entry.CommittedDate = currentFieldDate(detail.Fields, cfg.DeliveryPlanCommittedField)
+			if entry.CommittedDate != nil && sprintDate(*entry.DueDate).After(dueDay(*entry.CommittedDate, cfg.Location)) {
+				entry.Issues = append(entry.Issues, deliveryAfterCommitted)
+			}
+		}
+
+		if len(entry.Issues) > 0 {
+			report.WithIssues++
+		}
+		if len(entry.Issues) > 0 || entry.StartDateAdded {
+			report.Items = append(report.Items, entry)
+		}
+	}
+	log.Printf("[DEBUG] Delivery plan (tag '%s'): %d User Stories verificadas, %d StartDate acrescentados, %d com problemas",
+		cfg.DeliveryPlanTag, report.Checked, report.StartDatesAdded, report.WithIssues)
+	return report, nil
+}
+
+// Função para gravar o StartDate de uma User Story do Delivery Plan, com o
+// mesmo teste de revisão e espaçamento das datas; em dry-run não grava
+func (g *dueDateWriter) writeStartDate(id, revision int, start time.Time) error {
+	if g.dryRun {
+		return nil
+	}
+	g.pace()
+	document := append(revisionTest(revision), fieldDatePatch(startDateField, &start)...)
+	if _, err := patchDueDate(g.ctx, g.witClient, g.project, id, startDateField, document, nil, &start); err != nil {
+		log.Printf("[ERROR] %v", err)
+		return err
+	}
+	return nil
+}
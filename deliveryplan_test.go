@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+func TestGenerateDueDatesDeliveryPlan(t *testing.T) {
+	iteration := fakeIteration("Sprint 7", day(2030, 3, 4), day(2030, 3, 15), work.TimeFrameValues.Future)
+	story := func(id int, tags string, fields map[string]interface{}) workitemtracking.WorkItem {
+		item := fakeStory(id, "História", "Active")
+		(*item.Fields)["System.Tags"] = tags
+		for name, value := range fields {
+			(*item.Fields)[name] = value
+		}
+		return item
+	}
+	task := func(id, parent int, state, start string) workitemtracking.WorkItem {
+		return fakeWorkItem(id, map[string]interface{}{
+			"System.WorkItemType": "Task",
+			"System.Title":        "Task",
+			"System.State":        state,
+			"System.Parent":       float64(parent),
+			startDateField:        start,
+		})
+	}
+	workClient := &fakeWorkClient{
+		iterations: []work.TeamSettingsIteration{iteration},
+		relations:  map[uuid.UUID][]workitemtracking.WorkItemLink{*iteration.Id: {fakeLink(0, 1), fakeLink(0, 2), fakeLink(0, 3), fakeLink(0, 4)}},
+	}
+	witClient := newFakeWitClient(
+		// Sem StartDate: recebe o início mais cedo das tasks, inclusive a fechada
+		story(1, "roadmap", nil),
+		// Já tem StartDate e a data comprometida é anterior à sprint
+		story(2, "Roadmap; outra", map[string]interface{}{startDateField: "2030-03-04T00:00:00Z", "Custom.CommittedDate": "2030-03-01T00:00:00Z"}),
+		// Sem StartDate e sem tasks
+		story(3, "roadmap", nil),
+		// Fora do Delivery Plan
+		story(4, "", nil),
+		task(11, 1, "Active", "2030-03-06T00:00:00Z"),
+		task(12, 1, "Closed", "2030-03-05T00:00:00Z"),
+		task(13, 4, "Active", "2030-03-05T00:00:00Z"),
+	)
+	witClient.queryByWiql = func(query string) []int {
+		if strings.Contains(query, "System.Parent") {
+			return []int{11, 12, 13}
+		}
+		return nil
+	}
+	cfg := &Config{Project: "Projeto", Team: "Time", TeamName: "Time", WorkItemTypes: []string{"User Story"}, ChildWorkItemTypes: []string{"Task"},
+		DefaultCapacityPerDay: 8, HoursPerStoryPoint: 8, FocusFactor: 1, DueDateWriteField: dueDateField, DueDateCommentTemplate: defaultDueDateCommentTemplate,
+		Location: time.UTC, DeliveryPlanTag: "roadmap", DeliveryPlanCommittedField: "Custom.CommittedDate"}
+	store, err := newRunStore("", runRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := handleGenerateDueDates(newFakePool(workClient, witClient), cfg, store, newIdempotencyStore(time.Hour), nil)
+	generate := func(query string) *DeliveryPlanReport {
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest(http.MethodPost, "/generate-due-dates?sprint=Sprint%207&strategy=even"+query, nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+		}
+		var report GenerationReport
+		if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		if report.DeliveryPlan == nil {
+			t.Fatalf("relatório sem deliveryPlan: %s", recorder.Body.String())
+		}
+		return report.DeliveryPlan
+	}
+	issues := func(plan *DeliveryPlanReport) map[int][]string {
+		got := make(map[int][]string)
+		for _, item := range plan.Items {
+			got[item.ID] = item.Issues
+		}
+		return got
+	}
+	wantIssues := map[int][]string{1: {}, 2: {deliveryAfterCommitted}, 3: {deliveryMissingStartDate}}
+
+	// Em dry-run o StartDate aparece como acrescentado, sem gravar
+	preview := generate("&dryRun=true")
+	if preview.Checked != 3 || preview.StartDatesAdded != 1 || preview.WithIssues != 2 || !reflect.DeepEqual(issues(preview), wantIssues) {
+		t.Errorf("dry-run: deliveryPlan = %+v, quer 3 verificadas, 1 StartDate e problemas %v", preview, wantIssues)
+	}
+	if len(witClient.updates) != 0 {
+		t.Fatalf("dry-run gravou %d itens", len(witClient.updates))
+	}
+
+	plan := generate("")
+	if plan.StartDatesAdded != 1 || !reflect.DeepEqual(issues(plan), wantIssues) {
+		t.Fatalf("deliveryPlan = %+v, quer 1 StartDate e problemas %v", plan, wantIssues)
+	}
+	for _, item := range plan.Items {
+		if item.ID == 1 && (!item.StartDateAdded || item.StartDate == nil || !item.StartDate.Equal(day(2030, 3, 5))) {
+			t.Errorf("#1 = %+v, quer StartDate 2030-03-05 acrescentado", item)
+		}
+		if item.ID == 2 && (item.CommittedDate == nil || item.DueDate == nil) {
+			t.Errorf("#2 = %+v, quer dueDate e committedDate", item)
+		}
+	}
+	// A User Story 1 recebe a data e depois o StartDate; a 4 só a data
+	if len(witClient.updates[1]) != 2 || len(witClient.updates[4]) != 1 {
+		t.Errorf("gravações: #1 = %d, #4 = %d, quer 2 e 1", len(witClient.updates[1]), len(witClient.updates[4]))
+	}
+	if got := currentFieldDate(witClient.items[1].Fields, startDateField); got == nil || !got.Equal(day(2030, 3, 5)) {
+		t.Errorf("StartDate gravado em #1 = %v, quer 2030-03-05", got)
+	}
+
+	// Na próxima geração o StartDate já existe e nada mais é acrescentado
+	if again := generate(""); again.StartDatesAdded != 0 || len(witClient.updates[1]) != 2 {
+		t.Errorf("segunda geração: %d StartDate acrescentados, %d gravações em #1", again.StartDatesAdded, len(witClient.updates[1]))
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+// Tipos de vínculo de dependência do Azure DevOps: Forward aponta para o
+// sucessor e Reverse para o predecessor
+const (
+	linkSuccessor   = "System.LinkTypes.Dependency-Forward"
+	linkPredecessor = "System.LinkTypes.Dependency-Reverse"
+)
+
+// Função para obter o ID do work item no fim da URL de um vínculo
+// (.../_apis/wit/workItems/123)
+func relationTargetId(url string) (int, bool) {
+	index := strings.LastIndex(url, "/")
+	if index < 0 {
+		return 0, false
+	}
+	id, err := strconv.Atoi(url[index+1:])
+	return id, err == nil
+}
+
+// Função para buscar os vínculos de dependência entre os work items
+// informados, devolvendo os predecessores de cada um. Vínculos com itens
+// fora da lista são ignorados.
+func fetchPredecessors(ctx context.Context, witClient workitemtracking.Client, project string, ids []int) (map[int][]int, error) {
+	predecessors := make(map[int][]int)
+	wanted := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	seen := make(map[[2]int]bool)
+	addEdge := func(predecessor, successor int) {
+		edge := [2]int{predecessor, successor}
+		if predecessor == successor || seen[edge] || !wanted[predecessor] || !wanted[successor] {
+			return
+		}
+		seen[edge] = true
+		predecessors[successor] = append(predecessors[successor], predecessor)
+	}
+
+	// O Azure DevOps não aceita fields junto com expand, então os vínculos vêm numa busca própria
+	expand := workitemtracking.WorkItemExpandValues.Relations
+	for _, chunk := range chunkIds(ids, maxWorkItemsPerCall) {
+		chunk := chunk
+		workItems, err := witClient.GetWorkItems(ctx, workitemtracking.GetWorkItemsArgs{
+			Ids:         &chunk,
+			Project:     &project,
+			Expand:      &expand,
+			ErrorPolicy: &omitMissingWorkItems,
+		})
+		if err != nil {
+			return nil, wrapAdoError(err, "GetWorkItems", "ids=%d..%d, expand=relations", chunk[0], chunk[len(chunk)-1])
+		}
+		for _, workItem := range presentWorkItems(chunk, workItems) {
+			if workItem.Relations == nil {
+				continue
+			}
+			for _, relation := range *workItem.Relations {
+				if relation.Rel == nil || relation.Url == nil {
+					continue
+				}
+				target, ok := relationTargetId(*relation.Url)
+				if !ok {
+					continue
+				}
+				switch *relation.Rel {
+				case linkSuccessor:
+					addEdge(*workItem.Id, target)
+				case linkPredecessor:
+					addEdge(target, *workItem.Id)
+				}
+			}
+		}
+	}
+	for _, list := range predecessors {
+		sort.Ints(list)
+	}
+	return predecessors, nil
+}
+
+// Função para reordenar as User Stories de forma que cada predecessor venha
+// antes dos sucessores, mantendo a ordem recebida (prioridade) sempre que os
+// vínculos permitirem. Ciclos são quebrados antes, ignorando um vínculo de
+// cada ciclo; os vínculos mantidos e os avisos dos ciclos são devolvidos.
+func orderByDependencies(stories []WorkItem, predecessors map[int][]int) ([]WorkItem, map[int][]int, []string) {
+	kept, warnings := breakDependencyCycles(stories, predecessors)
+
+	ordered := make([]WorkItem, 0, len(stories))
+	done := make(map[int]bool, len(stories))
+	for len(ordered) < len(stories) {
+		// Próxima User Story liberada, na ordem de prioridade; sem ciclos sempre existe uma
+		for _, story := range stories {
+			if !done[story.ID] && allDone(kept[story.ID], done) {
+				done[story.ID] = true
+				ordered = append(ordered, story)
+				break
+			}
+		}
+	}
+	return ordered, kept, warnings
+}
+
+// Função para quebrar os ciclos de dependência. Em cada ciclo, o vínculo
+// ignorado é o que prende o item de maior prioridade ao predecessor dele.
+func breakDependencyCycles(stories []WorkItem, predecessors map[int][]int) (map[int][]int, []string) {
+	position := make(map[int]int, len(stories))
+	for i, story := range stories {
+		position[story.ID] = i
+	}
+	kept := make(map[int][]int, len(predecessors))
+	for successor, list := range predecessors {
+		kept[successor] = append([]int(nil), list...)
+	}
+
+	var warnings []string
+	for {
+		done := resolvableStories(stories, kept)
+		if len(done) == len(stories) {
+			return kept, warnings
+		}
+		cycle := findDependencyCycle(stories, kept, done)
+		broken := 0
+		for i := range cycle {
+			if position[cycle[i]] < position[cycle[broken]] {
+				broken = i
+			}
+		}
+		successor := cycle[broken]
+		predecessor := cycle[(broken+1)%len(cycle)]
+		kept[successor] = removeInt(kept[successor], predecessor)
+		names := make([]string, len(cycle))
+		for i, id := range cycle {
+			names[i] = fmt.Sprintf("#%d", id)
+		}
+		warnings = append(warnings, fmt.Sprintf("Ciclo de dependências entre %s; o vínculo #%d → #%d foi ignorado",
+			strings.Join(names, ", "), predecessor, successor))
+	}
+}
+
+// Função para marcar as User Stories que podem ser ordenadas (todos os
+// predecessores ordenáveis). As que sobram dependem de algum ciclo.
+func resolvableStories(stories []WorkItem, predecessors map[int][]int) map[int]bool {
+	done := make(map[int]bool, len(stories))
+	for progress := true; progress; {
+		progress = false
+		for _, story := range stories {
+			if !done[story.ID] && allDone(predecessors[story.ID], done) {
+				done[story.ID] = true
+				progress = true
+			}
+		}
+	}
+	return done
+}
+
+func allDone(ids []int, done map[int]bool) bool {
+	for _, id := range ids {
+		if !done[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// Função para encontrar um ciclo entre as User Stories que não podem ser
+// ordenadas. Toda User Story pendente tem um predecessor pendente, então
+// seguir esses predecessores sempre repete um item.
+// O ciclo volta na ordem sucessor → predecessor.
+func findDependencyCycle(stories []WorkItem, predecessors map[int][]int, done map[int]bool) []int {
+	start := 0
+	for _, story := range stories {
+		if !done[story.ID] {
+			start = story.ID
+			break
+		}
+	}
+	visited := make(map[int]int)
+	var path []int
+	current := start
+	for {
+		if index, ok := visited[current]; ok {
+			return path[index:]
+		}
+		visited[current] = len(path)
+		path = append(path, current)
+		for _, predecessor := range predecessors[current] {
+			if !done[predecessor] {
+				current = predecessor
+				break
+			}
+		}
+	}
+}
+
+func removeInt(list []int, value int) []int {
+	for i, item := range list {
+		if item == value {
+			return append(list[:i:i], list[i+1:]...)
+		}
+	}
+	return list
+}
+
+// Função para empurrar a data de cada sucessor para pelo menos um dia útil
+// depois da data dos predecessores. plans deve estar na ordem de
+// orderByDependencies. Quando não há dia útil depois do predecessor, a data
+// fica no fim da sprint e a User Story é marcada em risco. Datas explícitas
+// de overrides não mudam, mas continuam valendo para os sucessores.
+func applyDependencyDates(plans []generationPlan, predecessors map[int][]int, days []time.Time, end time.Time) {
+	dueDates := make(map[int]*time.Time, len(plans))
+	for i := range plans {
+		plan := &plans[i]
+		if plan.DueDate != nil && plan.Override != overrideDueDate {
+			for _, predecessor := range predecessors[plan.Story.ID] {
+				before := dueDates[predecessor]
+				if before == nil {
+					continue
+				}
+				next, ok := nextWorkingDate(days, *before)
+				if !ok {
+					if !plan.DueDate.After(*before) {
+						plan.DueDate = &end
+						plan.AtRisk = true
+					}
+					continue
+				}
+				if plan.DueDate.Before(next) {
+					plan.DueDate = &next
+				}
+			}
+		}
+		dueDates[plan.Story.ID] = plan.DueDate
+	}
+}
+
+// Função para obter o primeiro dia útil depois de date
+func nextWorkingDate(days []time.Time, date time.Time) (time.Time, bool) {
+	for _, day := range days {
+		if day.After(date) {
+			return day, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+// Handler de GET /developers: tasks, horas alocadas e capacidade de cada
+// desenvolvedor na sprint, com as folgas individuais e do time. Com
+// GRANULARITY=days também traz a capacidade e a carga em dias inteiros,
+// que passam a valer para a utilização e a sobrealocação.
+func handleDevelopers(pool *adoPool, cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		project := cfg.Project
+		team := cfg.Team
+
+		ref, err := sprintRefFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		cal, err := calendarForRequest(cfg, r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		overallocationThreshold := cfg.OverallocationThreshold
+		if value := r.URL.Query().Get("overallocationThreshold"); value != "" {
+			overallocationThreshold, err = parseOverallocationThreshold(value)
+			if err != nil {
+				jsonError(w, fmt.Sprintf("Parâmetro 'overallocationThreshold' inválido: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		detail := r.URL.Query().Get("detail") == "true"
+
+		keepArea, err := areaPathFilterFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Tasks fechadas só contam quando pedido explicitamente (relatórios)
+		includeClosed := false
+		if value := r.URL.Query().Get("includeClosed"); value != "" {
+			includeClosed, err = strconv.ParseBool(value)
+			if err != nil {
+				jsonError(w, fmt.Sprintf("Parâmetro 'includeClosed' inválido: %q", value), http.StatusBadRequest)
+				return
+			}
+		}
+
+		ctx := r.Context()
+		workClient, err := pool.Work(ctx, "developers")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
+			return
+		}
+
+		// Buscar a sprint pelo nome ou pelo ID
+		targetIteration, err := resolveSprintRef(ctx, workClient, cfg, ref)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		sprintName := *targetIteration.Name
+		setSprintHeaders(w, targetIteration)
+
+		// Calcular capacidade total e dias úteis
+		var sprintStart, sprintEnd time.Time
+		if targetIteration.Attributes != nil {
+			if targetIteration.Attributes.StartDate != nil {
+				sprintStart = time.Time(targetIteration.Attributes.StartDate.Time)
+			}
+			if targetIteration.Attributes.FinishDate != nil {
+				sprintEnd = time.Time(targetIteration.Attributes.FinishDate.Time)
+			}
+		}
+
+		// Buscar work items da sprint
+		workItemsResponse, err := workClient.GetIterationWorkItems(ctx, work.GetIterationWorkItemsArgs{
+			Project:     &project,
+			Team:        &team,
+			IterationId: targetIteration.Id,
+		})
+		if err != nil {
+			respondError(w, "Erro ao buscar work items da sprint", wrapAdoError(err, "GetIterationWorkItems", "sprint=%s", sprintName))
+			return
+		}
+
+		witClient, err := pool.WorkItems(ctx, "developers")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+
+		// Primeiro, vamos buscar todas as User Stories da sprint
+		workItemIds := iterationWorkItemIds(workItemsResponse)
+
+		// Mapa para contar tasks por desenvolvedor, indexado por identityKey
+		devMap := make(map[string]*Developer)
+		// Quebra por User Story de cada desenvolvedor (?detail=true), pela mesma chave
+		devStories := make(map[string]map[int]*DeveloperUserStory)
+		storyTitles := make(map[int]string)
+		// Work items que sumiram entre a listagem e a busca de detalhes
+		var missing []int
+
+		if len(workItemIds) > 0 {
+			// Buscar as User Stories
+			workItems, err := getWorkItemsChunked(ctx, witClient, project, workItemIds, []string{"System.Id", "System.Title", "System.WorkItemType", "System.State", "System.AreaPath"})
+			if err != nil {
+				respondError(w, "Erro ao buscar User Stories", err)
+				return
+			}
+
+			details := presentWorkItems(workItemIds, workItems)
+			missing = append(missing, missingWorkItemIds(workItemIds, workItems)...)
+			types, explicitTypes := typesFromRequest(cfg, r)
+			if explicitTypes {
+				if err := validateRequestedTypes(types, details); err != nil {
+					jsonError(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+
+			// WIQL para buscar tasks vinculadas aos itens de backlog da sprint
+			var userStoryIds []int
+			for _, wi := range details {
+				// User Stories removidas ou fora de ?areaPath= não contribuem para a carga dos desenvolvedores
+				if isTrackedType(types, getFieldValue(wi.Fields, "System.WorkItemType")) &&
+					!isRemovedState(getFieldValue(wi.Fields, "System.State")) &&
+					keepArea(getFieldValue(wi.Fields, "System.AreaPath")) {
+					userStoryIds = append(userStoryIds, *wi.Id)
+					storyTitles[*wi.Id] = getFieldValue(wi.Fields, "System.Title")
+				}
+			}
+
+			if len(userStoryIds) > 0 {
+				taskQuery := newWiqlQuery("System.Id", "System.AssignedTo", "Microsoft.VSTS.Common.Activity").
+					Where("System.WorkItemType", "=", "Task").
+					WhereInInts("System.Parent", userStoryIds).
+					Where("System.AssignedTo", "<>", "").
+					Where("System.State", "<>", removedState)
+				if !includeClosed {
+					taskQuery.Where("System.State", "<>", closedState)
+				}
+				wiql, err := taskQuery.Build()
+				if err != nil {
+					respondError(w, "Erro ao montar consulta de tasks", err)
+					return
+				}
+
+				query := workitemtracking.Wiql{Query: &wiql}
+				queryResults, err := witClient.QueryByWiql(ctx, workitemtracking.QueryByWiqlArgs{
+					Wiql:    &query,
+					Project: &project,
+				})
+
+				if err != nil {
+					respondError(w, "Erro ao buscar tasks", wrapAdoError(err, "QueryByWiql", "parents=%d", len(userStoryIds)))
+					return
+				}
+
+				var taskIds []int
+				if queryResults != nil && queryResults.WorkItems != nil {
+					for _, item := range *queryResults.WorkItems {
+						if item.Id != nil {
+							taskIds = append(taskIds, *item.Id)
+						}
+					}
+				}
+
+				if len(taskIds) > 0 {
+					taskFields := []string{"System.AssignedTo", "System.State", "System.Parent", "Microsoft.VSTS.Common.Activity", "Microsoft.VSTS.Scheduling.RemainingWork"}
+					if cfg.dayGranularity() {
+						taskFields = append(taskFields, cfg.DayEstimateField)
+					}
+					tasks, err := getWorkItemsChunked(ctx, witClient, project, taskIds, taskFields)
+					if err != nil {
+						respondError(w, "Erro ao buscar detalhes das tasks", err)
+						return
+					}
+
+					missing = append(missing, missingWorkItemIds(taskIds, tasks)...)
+					for _, task := range presentWorkItems(taskIds, tasks) {
+						if assignedTo := getFieldIdentity(task.Fields, "System.AssignedTo"); assignedTo != nil {
+							key := identityKey(assignedTo.UniqueName, assignedTo.Descriptor, assignedTo.DisplayName)
+							dev, exists := devMap[key]
+							if !exists {
+								email, mailable := identityEmail(assignedTo.UniqueName, assignedTo.Descriptor)
+								dev = &Developer{Name: assignedTo.DisplayName, Email: email, Mailable: mailable, TasksByActivity: map[string]int{}}
+								devMap[key] = dev
+							}
+							dev.Tasks++
+							// Tasks sem atividade contam apenas no total
+							if activity := getFieldValue(task.Fields, "Microsoft.VSTS.Common.Activity"); activity != "" {
+								dev.TasksByActivity[activity]++
+							}
+							// Horas restantes só das tasks abertas; as sem estimativa ficam à parte
+							remaining := 0.0
+							open := !strings.EqualFold(getFieldValue(task.Fields, "System.State"), closedState)
+							switch {
+							case open && cfg.dayGranularity():
+								// Em dias, sem estimativa em dias a task fica à parte
+								if days := dayEstimate(cfg, task.Fields); days != nil {
+									allocated := *days
+									if dev.AllocatedDays != nil {
+										allocated += *dev.AllocatedDays
+									}
+									dev.AllocatedDays = &allocated
+								} else {
+									dev.UnestimatedTasks++
+								}
+								if value := getFieldFloat(task.Fields, "Microsoft.VSTS.Scheduling.RemainingWork"); value != nil {
+									remaining = *value
+									dev.AllocatedHours += remaining
+								}
+							case open:
+								if value := getFieldFloat(task.Fields, "Microsoft.VSTS.Scheduling.RemainingWork"); value != nil {
+									remaining = *value
+									dev.AllocatedHours += remaining
+								} else {
+									dev.UnestimatedTasks++
+								}
+							}
+
+							if detail {
+								if parent := getFieldFloat(task.Fields, "System.Parent"); parent != nil {
+									parentID := int(*parent)
+									if devStories[key] == nil {
+										devStories[key] = make(map[int]*DeveloperUserStory)
+									}
+									story, ok := devStories[key][parentID]
+									if !ok {
+										story = &DeveloperUserStory{UserStoryID: parentID, UserStoryTitle: storyTitles[parentID]}
+										devStories[key][parentID] = story
+									}
+									story.TaskCount++
+									story.RemainingWork += remaining
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+
+		// Capacidades reais da sprint, pela mesma chave de identidade de devMap
+		devCapacities, err := fetchTeamCapacities(ctx, workClient, cfg, targetIteration)
+		if err != nil {
+			respondError(w, "Erro ao buscar capacidades da sprint", err)
+			return
+		}
+
+		// Folgas do time inteiro (feriados da empresa etc.) valem para todos
+		teamDaysOff, err := fetchTeamDaysOff(ctx, workClient, cfg, targetIteration)
+		if err != nil {
+			respondError(w, "Erro ao buscar folgas do time", err)
+			return
+		}
+
+		// Membros com capacidade configurada aparecem mesmo sem tasks
+		for key, capacity := range devCapacities {
+			_, mailable := identityEmail(capacity.Email, "")
+			if dev, exists := devMap[key]; exists {
+				dev.Email = capacity.Email
+				dev.Mailable = mailable
+			} else {
+				devMap[key] = &Developer{Name: capacity.Name, Email: capacity.Email, Mailable: mailable, TasksByActivity: map[string]int{}}
+			}
+		}
+
+		// Quem tem tasks mas não tem capacidade na sprint recebe a capacidade padrão
+		var defaulted []string
+		for key, dev := range devMap {
+			if _, exists := devCapacities[key]; !exists {
+				devCapacities[key] = TeamMemberCapacity{
+					// Sem nome de atividade: entra só na capacidade total
+					Activities: []CapacityActivity{{CapacityPerDay: cfg.DefaultCapacityPerDay}},
+					DaysOff:    []DayOff{},
+				}
+				defaulted = append(defaulted, dev.Name)
+			}
+		}
+		sort.Strings(defaulted)
+
+		// Valida o intervalo da sprint antes de iterar o calendário por desenvolvedor
+		sprintWorkingDays, err := calculateWorkingDays(sprintStart, sprintEnd, teamDaysOff, cal)
+		if err != nil {
+			respondError(w, fmt.Sprintf("Datas inválidas na sprint '%s'", sprintName), err)
+			return
+		}
+
+		response := DevelopersResponse{
+			Sprint:      sprintName,
+			SprintStart: sprintStart,
+			SprintEnd:   sprintEnd,
+			TeamDaysOff: teamDaysOff,
+			Holidays:    holidaysInRange(sprintStart, sprintEnd, cal),
+			Granularity: cfg.Granularity,
+			Warnings:    missingWorkItemWarnings(missing),
+		}
+		if cfg.dayGranularity() {
+			response.CapacityDays, response.AllocatedDays = new(float64), new(float64)
+		}
+		if len(defaulted) > 0 {
+			response.Warnings = append(response.Warnings, fmt.Sprintf(
+				"Sem capacidade configurada na sprint '%s' para %s; usando %gh/dia. Para copiar de uma sprint anterior use POST /capacity/copy?from=<sprint anterior>&to=%s",
+				sprintName, strings.Join(defaulted, ", "), cfg.DefaultCapacityPerDay, sprintName))
+		}
+
+		// Converter mapa para slice e calcular capacidades
+		developers := make([]Developer, 0, len(devMap))
+		totalDaysOff := 0.0
+		var outsideWindow []string
+		for key, dev := range devMap {
+			developer := Developer{
+				Name:               dev.Name,
+				Email:              dev.Email,
+				Mailable:           dev.Mailable,
+				Tasks:              dev.Tasks,
+				TasksByActivity:    dev.TasksByActivity,
+				AllocatedDays:      dev.AllocatedDays,
+				CapacityByActivity: map[string]float64{},
+				AllocatedHours:     dev.AllocatedHours,
+				UnestimatedTasks:   dev.UnestimatedTasks,
+			}
+
+			if capacity, exists := devCapacities[key]; exists {
+				// Capacidade total é a soma de todas as atividades; as nomeadas
+				// também ficam na quebra por atividade
+				for _, activity := range capacity.Activities {
+					developer.CapacityPerDay += activity.CapacityPerDay
+					if activity.Name != "" {
+						developer.CapacityByActivity[activity.Name] += activity.CapacityPerDay
+					}
+				}
+
+				// Quem entra ou sai no meio da sprint só conta os dias da janela
+				devStart, devEnd := sprintStart, sprintEnd
+				outside := false
+				if window, ok := cfg.Availability[key]; ok {
+					var clipped bool
+					devStart, devEnd, clipped, outside = window.clip(sprintStart, sprintEnd)
+					if outside {
+						outsideWindow = append(outsideWindow, dev.Name)
+					} else if clipped {
+						developer.EffectiveStart, developer.EffectiveEnd = &devStart, &devEnd
+					}
+				}
+
+				// Calcula dias úteis considerando dias de folga
+				effectiveDaysOff := append(append([]DayOff{}, teamDaysOff...), capacity.DaysOff...)
+				var workingDays float64
+				var available []capacityDay
+				if !outside {
+					workingDays, err = calculateWorkingDays(devStart, devEnd, effectiveDaysOff, cal)
+					if err != nil {
+						respondError(w, fmt.Sprintf("Erro ao calcular dias úteis de %s", dev.Name), err)
+						return
+					}
+					developer.DaysOff = countDaysOff(devStart, devEnd, capacity.DaysOff, cal)
+					// Em dias, cada dia útil sem folga vale um dia inteiro, como no cálculo das datas
+					if cfg.dayGranularity() {
+						available, err = availableDays(devStart, devEnd, developer.CapacityPerDay > 0, effectiveDaysOff, cal)
+						if err != nil {
+							respondError(w, fmt.Sprintf("Erro ao calcular dias úteis de %s", dev.Name), err)
+							return
+						}
+					}
+				}
+				totalDaysOff += developer.DaysOff
+
+				// Calcula capacidade total
+				developer.TotalCapacity = workingDays * developer.CapacityPerDay
+				response.TotalCapacity += developer.TotalCapacity
+				if cfg.dayGranularity() {
+					capacityDays := float64(len(available))
+					developer.CapacityDays = &capacityDays
+				}
+			}
+			developer.Utilization = utilization(developer.AllocatedHours, developer.TotalCapacity)
+			if detail {
+				developer.UserStories = make([]DeveloperUserStory, 0, len(devStories[key]))
+				for _, story := range devStories[key] {
+					developer.UserStories = append(developer.UserStories, *story)
+				}
+				sort.Slice(developer.UserStories, func(i, j int) bool {
+					return developer.UserStories[i].UserStoryID < developer.UserStories[j].UserStoryID
+				})
+			}
+			if cfg.dayGranularity() {
+				if developer.AllocatedDays == nil {
+					developer.AllocatedDays = new(float64)
+				}
+				if developer.CapacityDays == nil {
+					developer.CapacityDays = new(float64)
+				}
+				developer.Utilization = utilization(*developer.AllocatedDays, *developer.CapacityDays)
+				if *developer.AllocatedDays > *developer.CapacityDays*(1+overallocationThreshold/100) {
+					developer.OverAllocated = true
+					developer.OverAllocationDays = *developer.AllocatedDays - *developer.CapacityDays
+				}
+				*response.CapacityDays += *developer.CapacityDays
+				*response.AllocatedDays += *developer.AllocatedDays
+			} else if developer.AllocatedHours > developer.TotalCapacity*(1+overallocationThreshold/100) {
+				developer.OverAllocated = true
+				developer.OverAllocationHours = developer.AllocatedHours - developer.TotalCapacity
+			}
+			response.AllocatedHours += developer.AllocatedHours
+			response.UnestimatedTasks += developer.UnestimatedTasks
+
+			developers = append(developers, developer)
+		}
+
+		// Ordenar por nome
+		sort.Slice(developers, func(i, j int) bool {
+			return developers[i].Name < developers[j].Name
+		})
+
+		if len(outsideWindow) > 0 {
+			sort.Strings(outsideWindow)
+			response.Warnings = append(response.Warnings, fmt.Sprintf(
+				"A janela de disponibilidade (AVAILABILITY_FILE) de %s fica fora da sprint '%s'; capacidade zerada",
+				strings.Join(outsideWindow, ", "), sprintName))
+		}
+
+		for _, developer := range developers {
+			if developer.OverAllocated && cfg.dayGranularity() {
+				response.Warnings = append(response.Warnings, fmt.Sprintf(
+					"%s está sobrealocado(a) em %g dia(s) (%g dias estimados para %g dias disponíveis)",
+					developer.Name, developer.OverAllocationDays, *developer.AllocatedDays, *developer.CapacityDays))
+			} else if developer.OverAllocated {
+				response.Warnings = append(response.Warnings, fmt.Sprintf(
+					"%s está sobrealocado(a) em %.1fh (%.1fh alocadas para %.1fh de capacidade)",
+					developer.Name, developer.OverAllocationHours, developer.AllocatedHours, developer.TotalCapacity))
+			}
+		}
+
+		response.Developers = developers
+		response.Utilization = utilization(response.AllocatedHours, response.TotalCapacity)
+		if cfg.dayGranularity() {
+			response.Utilization = utilization(*response.AllocatedDays, *response.CapacityDays)
+		}
+		response.TotalDaysOff = totalDaysOff
+		response.WorkingDays = sprintWorkingDays
+
+		writeJSON(w, http.StatusOK, response)
+	}
+}
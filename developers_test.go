@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/webapi"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+var wiqlParentsPattern = regexp.MustCompile(`\[System\.Parent\] IN \(([0-9,]+)\)`)
+
+// Função para montar o cenário de /developers: User Stories e tasks em
+// estados variados numa sprint de 04/03 a 15/03/2024 (10 dias úteis)
+func developersFixture() (*fakeWorkClient, *fakeWitClient, work.TeamSettingsIteration) {
+	iteration := fakeIteration("Sprint 1", day(2024, 3, 4), day(2024, 3, 15), "")
+	ana := fakeIdentity("Ana Souza", "ana@example.com")
+	bruno := fakeIdentity("Bruno Lima", "bruno@example.com")
+	anaPerDay := float32(6)
+	development := "Development"
+	anaRef := "ana@example.com"
+	anaName := "Ana Souza"
+
+	workClient := &fakeWorkClient{
+		iterations: []work.TeamSettingsIteration{iteration},
+		relations: map[uuid.UUID][]workitemtracking.WorkItemLink{
+			*iteration.Id: {fakeLink(0, 100), fakeLink(0, 101), fakeLink(0, 102)},
+		},
+		capacities: map[uuid.UUID]*work.TeamCapacity{
+			*iteration.Id: {TeamMembers: &[]work.TeamMemberCapacityIdentityRef{{
+				TeamMember: &webapi.IdentityRef{DisplayName: &anaName, UniqueName: &anaRef},
+				Activities: &[]work.Activity{{CapacityPerDay: &anaPerDay, Name: &development}},
+			}}},
+		},
+	}
+
+	task := func(id, parent int, assignedTo map[string]interface{}, state string, remaining interface{}, activity string) workitemtracking.WorkItem {
+		fields := map[string]interface{}{
+			"System.WorkItemType": "Task",
+			"System.Parent":       float64(parent),
+			"System.AssignedTo":   assignedTo,
+			"System.State":        state,
+		}
+		if remaining != nil {
+			fields["Microsoft.VSTS.Scheduling.RemainingWork"] = remaining
+		}
+		if activity != "" {
+			fields["Microsoft.VSTS.Common.Activity"] = activity
+		}
+		return fakeWorkItem(id, fields)
+	}
+	witClient := newFakeWitClient(
+		fakeStory(100, "Login", "Active"),
+		fakeStory(101, "Removida", "Removed"),
+		fakeStory(102, "Entregue", "Closed"),
+		task(200, 100, ana, "Active", 5.0, "Development"),
+		task(201, 100, ana, "Closed", 3.0, "Development"),
+		task(205, 100, ana, "Resolved", 2.0, ""),
+		task(202, 102, bruno, "New", nil, "Testing"),
+		task(203, 101, bruno, "Active", 8.0, "Development"),
+		task(204, 100, bruno, "Removed", 4.0, "Development"),
+	)
+	// Avalia as condições que o handler monta: pais, Removed sempre fora e Closed fora sem includeClosed
+	witClient.queryByWiql = func(query string) []int {
+		parents := map[int]bool{}
+		if match := wiqlParentsPattern.FindStringSubmatch(query); match != nil {
+			for _, value := range strings.Split(match[1], ",") {
+				id, _ := strconv.Atoi(value)
+				parents[id] = true
+			}
+		}
+		var ids []int
+		for _, id := range []int{200, 201, 202, 203, 204, 205} {
+			fields := *witClient.items[id].Fields
+			state := fields["System.State"].(string)
+			if !parents[int(fields["System.Parent"].(float64))] || state == "Removed" {
+				continue
+			}
+			if state == "Closed" && strings.Contains(query, "[System.State] <> 'Closed'") {
+				continue
+			}
+			ids = append(ids, id)
+		}
+		return ids
+	}
+	return workClient, witClient, iteration
+}
+
+func TestHandleDevelopersMixedStates(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantAna    Developer
+		wantBruno  Developer
+		wantClosed bool
+	}{
+		{
+			name:      "padrão sem tasks fechadas",
+			wantAna:   Developer{Tasks: 2, AllocatedHours: 7, TasksByActivity: map[string]int{"Development": 1}},
+			wantBruno: Developer{Tasks: 1, UnestimatedTasks: 1, TasksByActivity: map[string]int{"Testing": 1}},
+		},
+		{
+			name:       "includeClosed conta a task fechada sem somar horas",
+			query:      "&includeClosed=true",
+			wantAna:    Developer{Tasks: 3, AllocatedHours: 7, TasksByActivity: map[string]int{"Development": 2}},
+			wantBruno:  Developer{Tasks: 1, UnestimatedTasks: 1, TasksByActivity: map[string]int{"Testing": 1}},
+			wantClosed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workClient, witClient, iteration := developersFixture()
+			cfg := &Config{Project: "Projeto", Team: "Time", WorkItemTypes: []string{"User Story"}, DefaultCapacityPerDay: 6}
+			handler := handleDevelopers(newFakePool(workClient, witClient), cfg)
+
+			recorder := httptest.NewRecorder()
+			handler(recorder, httptest.NewRequest(http.MethodGet, "/developers?sprintId="+iteration.Id.String()+tt.query, nil))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+			}
+			var response DevelopersResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(witClient.wiqlQueries) != 1 {
+				t.Fatalf("%d consultas WIQL, quer 1", len(witClient.wiqlQueries))
+			}
+			query := witClient.wiqlQueries[0]
+			if strings.Contains(query, "101") {
+				t.Errorf("User Story removida entrou na consulta: %s", query)
+			}
+			if hasClosed := !strings.Contains(query, "<> 'Closed'"); hasClosed != tt.wantClosed {
+				t.Errorf("consulta com tasks fechadas = %t, quer %t: %s", hasClosed, tt.wantClosed, query)
+			}
+
+			if response.WorkingDays != 10 || len(response.Developers) != 2 {
+				t.Fatalf("workingDays = %v, developers = %+v", response.WorkingDays, response.Developers)
+			}
+			for i, want := range []Developer{tt.wantAna, tt.wantBruno} {
+				got := response.Developers[i]
+				if got.Tasks != want.Tasks || got.AllocatedHours != want.AllocatedHours || got.UnestimatedTasks != want.UnestimatedTasks {
+					t.Errorf("%s: tasks=%d horas=%v sem estimativa=%d, quer %d/%v/%d",
+						got.Name, got.Tasks, got.AllocatedHours, got.UnestimatedTasks, want.Tasks, want.AllocatedHours, want.UnestimatedTasks)
+				}
+				if len(got.TasksByActivity) != len(want.TasksByActivity) {
+					t.Errorf("%s: tasksByActivity = %v, quer %v", got.Name, got.TasksByActivity, want.TasksByActivity)
+				}
+				for activity, count := range want.TasksByActivity {
+					if got.TasksByActivity[activity] != count {
+						t.Errorf("%s: tasksByActivity = %v, quer %v", got.Name, got.TasksByActivity, want.TasksByActivity)
+					}
+				}
+				// Ana tem 6h/dia configuradas e Bruno recebe DEFAULT_CAPACITY_PER_DAY
+				if got.TotalCapacity != 60 {
+					t.Errorf("%s: totalCapacity = %v, quer 60", got.Name, got.TotalCapacity)
+				}
+			}
+			if len(response.Warnings) != 1 || !strings.Contains(response.Warnings[0], "Bruno Lima") {
+				t.Errorf("warnings = %v, quer o aviso de capacidade padrão de Bruno", response.Warnings)
+			}
+		})
+	}
+}
+
+func TestHandleDevelopersReportsItemsDeletedDuringRequest(t *testing.T) {
+	workClient, witClient, iteration := developersFixture()
+	// A User Story 102 está na iteração mas foi excluída antes do GetWorkItems
+	delete(witClient.items, 102)
+	// A task 200 volta no WIQL e é excluída antes da busca de detalhes
+	query := witClient.queryByWiql
+	witClient.queryByWiql = func(wiql string) []int {
+		ids := query(wiql)
+		delete(witClient.items, 200)
+		return ids
+	}
+	cfg := &Config{Project: "Projeto", Team: "Time", WorkItemTypes: []string{"User Story"}, DefaultCapacityPerDay: 6}
+	handler := handleDevelopers(newFakePool(workClient, witClient), cfg)
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodGet, "/developers?sprintId="+iteration.Id.String(), nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response DevelopersResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"#102", "#200"} {
+		found := false
+		for _, warning := range response.Warnings {
+			found = found || strings.Contains(warning, id)
+		}
+		if !found {
+			t.Errorf("warnings = %v, quer um aviso para %s", response.Warnings, id)
+		}
+	}
+	if len(response.Developers) != 1 || response.Developers[0].Tasks != 1 || response.Developers[0].AllocatedHours != 2 {
+		t.Errorf("developers = %+v, quer só Ana com a task 205", response.Developers)
+	}
+}
+
+func TestHandleDevelopersDayGranularity(t *testing.T) {
+	workClient, witClient, iteration := developersFixture()
+	// Ana: 1,5 dia e meio dia nas tasks abertas, arredondados task a task;
+	// a fechada não conta. Bruno não estima em dias.
+	(*witClient.items[200].Fields)["Custom.EstimateDays"] = 1.5
+	(*witClient.items[201].Fields)["Custom.EstimateDays"] = 4.0
+	(*witClient.items[205].Fields)["Custom.EstimateDays"] = 0.5
+	// Folga do time na segunda 11/03: 9 dias disponíveis
+	workClient.daysOff = map[uuid.UUID][]work.DateRange{*iteration.Id: {{Start: &azuredevops.Time{Time: day(2024, 3, 11)}, End: &azuredevops.Time{Time: day(2024, 3, 11)}}}}
+	cfg := &Config{Project: "Projeto", Team: "Time", WorkItemTypes: []string{"User Story"}, DefaultCapacityPerDay: 6, Granularity: granularityDays, DayEstimateField: "Custom.EstimateDays"}
+	handler := handleDevelopers(newFakePool(workClient, witClient), cfg)
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodGet, "/developers?sprintId="+iteration.Id.String()+"&overallocationThreshold=0", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response DevelopersResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Granularity != granularityDays || response.CapacityDays == nil || *response.CapacityDays != 18 || response.AllocatedDays == nil || *response.AllocatedDays != 3 {
+		t.Fatalf("resposta = granularity %q, capacityDays %v, allocatedDays %v; quer days, 18 e 3", response.Granularity, formatFloatPtr(response.CapacityDays), formatFloatPtr(response.AllocatedDays))
+	}
+	ana, bruno := response.Developers[0], response.Developers[1]
+	if *ana.CapacityDays != 9 || *ana.AllocatedDays != 3 || ana.Utilization == nil || *ana.Utilization != 3.0/9 || ana.UnestimatedTasks != 0 {
+		t.Errorf("Ana = %g dias de %g, utilização %v, %d sem estimativa; quer 3 de 9", *ana.AllocatedDays, *ana.CapacityDays, formatFloatPtr(ana.Utilization), ana.UnestimatedTasks)
+	}
+	// As horas continuam informadas, mas não decidem a utilização
+	if ana.AllocatedHours != 7 || ana.TotalCapacity != 54 {
+		t.Errorf("Ana = %gh de %gh, quer 7h de 54h", ana.AllocatedHours, ana.TotalCapacity)
+	}
+	if *bruno.CapacityDays != 9 || *bruno.AllocatedDays != 0 || bruno.UnestimatedTasks != 1 {
+		t.Errorf("Bruno = %g dias de %g, %d sem estimativa; quer 0 de 9 e 1", *bruno.AllocatedDays, *bruno.CapacityDays, bruno.UnestimatedTasks)
+	}
+}
+
+func TestHandleDevelopersAvailabilityWindow(t *testing.T) {
+	datePtr := func(date time.Time) *time.Time { return &date }
+	tests := []struct {
+		name         string
+		window       AvailabilityWindow
+		wantCapacity float64
+		wantStart    *time.Time
+		wantEnd      *time.Time
+		wantWarning  bool
+	}{
+		// Ana (6h/dia) entra na quinta 07/03: 7 dias úteis
+		{name: "entrada", window: AvailabilityWindow{From: datePtr(day(2024, 3, 7))}, wantCapacity: 42, wantStart: datePtr(day(2024, 3, 7)), wantEnd: datePtr(day(2024, 3, 15))},
+		// Sai na terça 05/03: 2 dias úteis
+		{name: "saída", window: AvailabilityWindow{To: datePtr(day(2024, 3, 5))}, wantCapacity: 12, wantStart: datePtr(day(2024, 3, 4)), wantEnd: datePtr(day(2024, 3, 5))},
+		{name: "fora da sprint", window: AvailabilityWindow{To: datePtr(day(2024, 2, 29))}, wantWarning: true},
+		{name: "janela cobrindo a sprint", window: AvailabilityWindow{From: datePtr(day(2024, 1, 1))}, wantCapacity: 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workClient, witClient, iteration := developersFixture()
+			cfg := &Config{Project: "Projeto", Team: "Time", WorkItemTypes: []string{"User Story"}, DefaultCapacityPerDay: 6,
+				Availability: map[string]AvailabilityWindow{"ana@example.com": tt.window}}
+			recorder := httptest.NewRecorder()
+			handleDevelopers(newFakePool(workClient, witClient), cfg)(recorder, httptest.NewRequest(http.MethodGet, "/developers?sprintId="+iteration.Id.String(), nil))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+			}
+			var response DevelopersResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			ana, bruno := response.Developers[0], response.Developers[1]
+			if ana.TotalCapacity != tt.wantCapacity || !reflect.DeepEqual(ana.EffectiveStart, tt.wantStart) || !reflect.DeepEqual(ana.EffectiveEnd, tt.wantEnd) {
+				t.Errorf("Ana = %gh, de %v a %v; quer %gh, de %v a %v", ana.TotalCapacity, ana.EffectiveStart, ana.EffectiveEnd, tt.wantCapacity, tt.wantStart, tt.wantEnd)
+			}
+			// Bruno não tem janela e segue com a sprint inteira
+			if bruno.TotalCapacity != 60 || bruno.EffectiveStart != nil {
+				t.Errorf("Bruno = %gh, início %v; quer 60h sem recorte", bruno.TotalCapacity, bruno.EffectiveStart)
+			}
+			warned := false
+			for _, warning := range response.Warnings {
+				warned = warned || strings.Contains(warning, "AVAILABILITY_FILE")
+			}
+			if warned != tt.wantWarning {
+				t.Errorf("warnings = %v, quer aviso da janela: %t", response.Warnings, tt.wantWarning)
+			}
+		})
+	}
+}
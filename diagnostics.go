@@ -0,0 +1,98 @@
+package main
+
+import "fmt"
+
+// Motivos do diagnóstico da geração, na ordem em que aparecem no relatório
+const (
+	diagnosticCompleted       = "completed"
+	diagnosticUnassigned      = "unassigned"
+	diagnosticBlocked         = "blocked"
+	diagnosticMissingEstimate = "missing-estimate"
+	diagnosticOtherType       = "other-type"
+	diagnosticOutsideArea     = "outside-area"
+	diagnosticExcluded        = "excluded"
+)
+
+// Descrição de cada motivo do diagnóstico
+var diagnosticDescriptions = []struct {
+	Code        string
+	Description string
+}{
+	{diagnosticCompleted, "itens em estado concluído ou removido"},
+	{diagnosticUnassigned, "User Stories sem responsável, que capacity e rollup não calculam"},
+	{diagnosticBlocked, "User Stories abertas bloqueadas"},
+	{diagnosticMissingEstimate, "User Stories sem estimativa ou sem tasks que permitam o cálculo"},
+	{diagnosticOtherType, "work items de tipos fora de WORK_ITEM_TYPES (e de types) na sprint"},
+	{diagnosticOutsideArea, "User Stories fora do filtro de área"},
+	{diagnosticExcluded, "User Stories excluídas por excludeTags ou EXCLUDED_ASSIGNEES"},
+}
+
+// Diagnóstico de POST /generate-due-dates: por que itens da sprint ficaram
+// sem data calculada. Com o plano vazio vem com os IDs de exemplo; nos
+// outros planos vem recolhido, só com as contagens.
+type GenerationDiagnostics struct {
+	// User Stories com data calculada
+	Schedulable int                `json:"schedulable"`
+	Collapsed   bool               `json:"collapsed"`
+	Message     string             `json:"message,omitempty"`
+	Buckets     []DiagnosticBucket `json:"buckets"`
+}
+
+// Um motivo do diagnóstico, com a quantidade e até DIAGNOSTIC_EXAMPLES IDs
+type DiagnosticBucket struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	Count       int    `json:"count"`
+	Examples    []int  `json:"examples,omitempty"`
+}
+
+// Acumula os itens de cada motivo durante a geração
+type diagnosticsCollector struct {
+	limit int
+	items map[string][]int
+}
+
+func newDiagnosticsCollector(limit int) *diagnosticsCollector {
+	return &diagnosticsCollector{limit: limit, items: make(map[string][]int)}
+}
+
+// Função para registrar um item em um motivo
+func (c *diagnosticsCollector) add(code string, id int) {
+	c.items[code] = append(c.items[code], id)
+}
+
+// Função para registrar o motivo de uma User Story que ficou sem data no
+// cálculo (sem responsável ou sem estimativa)
+func (c *diagnosticsCollector) addPlan(plan generationPlan) {
+	if plan.DueDate != nil {
+		return
+	}
+	switch plan.ReasonCode {
+	case reasonNoAssignee:
+		c.add(diagnosticUnassigned, plan.Story.ID)
+	case reasonNoEstimate, reasonNoTasks, reasonNoDatableTasks:
+		c.add(diagnosticMissingEstimate, plan.Story.ID)
+	}
+}
+
+// Função para montar o diagnóstico. Todos os motivos aparecem, mesmo com
+// zero, para o formato ser estável; os exemplos só vêm com o plano vazio.
+func (c *diagnosticsCollector) build(schedulable int, sprintName string) *GenerationDiagnostics {
+	diagnostics := &GenerationDiagnostics{
+		Schedulable: schedulable,
+		Collapsed:   schedulable > 0,
+		Buckets:     make([]DiagnosticBucket, 0, len(diagnosticDescriptions)),
+	}
+	for _, entry := range diagnosticDescriptions {
+		ids := c.items[entry.Code]
+		bucket := DiagnosticBucket{Code: entry.Code, Description: entry.Description, Count: len(ids)}
+		if !diagnostics.Collapsed && len(ids) > 0 && c.limit > 0 {
+			bucket.Examples = append([]int{}, ids[:min(len(ids), c.limit)]...)
+		}
+		diagnostics.Buckets = append(diagnostics.Buckets, bucket)
+	}
+	if schedulable == 0 {
+		diagnostics.Message = fmt.Sprintf("Nenhuma User Story da sprint '%s' pôde receber data; veja os motivos em buckets", sprintName)
+	}
+	return diagnostics
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+// Função para achar um motivo do diagnóstico pelo código
+func diagnosticBucket(diagnostics *GenerationDiagnostics, code string) DiagnosticBucket {
+	for _, bucket := range diagnostics.Buckets {
+		if bucket.Code == code {
+			return bucket
+		}
+	}
+	return DiagnosticBucket{}
+}
+
+func TestDiagnosticsCollector(t *testing.T) {
+	collector := newDiagnosticsCollector(2)
+	for _, id := range []int{1, 2, 3} {
+		collector.add(diagnosticCompleted, id)
+	}
+	collector.addPlan(generationPlan{Story: WorkItem{ID: 4}, ReasonCode: reasonNoAssignee})
+	collector.addPlan(generationPlan{Story: WorkItem{ID: 5}, ReasonCode: reasonNoTasks})
+	date := day(2024, 3, 5)
+	collector.addPlan(generationPlan{Story: WorkItem{ID: 6}, DueDate: &date})
+
+	empty := collector.build(0, "Sprint 7")
+	if empty.Collapsed || !strings.Contains(empty.Message, "Sprint 7") || len(empty.Buckets) != len(diagnosticDescriptions) {
+		t.Fatalf("diagnóstico = %+v, quer todos os motivos expandidos", empty)
+	}
+	if got := diagnosticBucket(empty, diagnosticCompleted); got.Count != 3 || !reflect.DeepEqual(got.Examples, []int{1, 2}) {
+		t.Errorf("concluídos = %+v, quer 3 com 2 exemplos", got)
+	}
+	if got := diagnosticBucket(empty, diagnosticUnassigned); got.Count != 1 || !reflect.DeepEqual(got.Examples, []int{4}) {
+		t.Errorf("sem responsável = %+v", got)
+	}
+	if got := diagnosticBucket(empty, diagnosticMissingEstimate); got.Count != 1 {
+		t.Errorf("sem estimativa = %+v", got)
+	}
+	if got := diagnosticBucket(empty, diagnosticOutsideArea); got.Count != 0 || got.Examples != nil {
+		t.Errorf("fora da área = %+v, quer zero", got)
+	}
+
+	collapsed := collector.build(1, "Sprint 7")
+	if !collapsed.Collapsed || collapsed.Message != "" || diagnosticBucket(collapsed, diagnosticCompleted).Examples != nil || diagnosticBucket(collapsed, diagnosticCompleted).Count != 3 {
+		t.Errorf("diagnóstico = %+v, quer só as contagens", collapsed)
+	}
+
+	if got := diagnosticBucket(newDiagnosticsCollector(0).build(0, "Sprint 7"), diagnosticCompleted); got.Examples != nil {
+		t.Errorf("DIAGNOSTIC_EXAMPLES=0 deveria omitir os exemplos: %+v", got)
+	}
+}
+
+func TestGenerateDueDatesDiagnostics(t *testing.T) {
+	iteration := fakeIteration("Sprint 7", day(2030, 3, 4), day(2030, 3, 15), work.TimeFrameValues.Future)
+	closed := fakeStory(1, "Fechada", "Closed")
+	unassigned := fakeStory(2, "Sem responsável", "Active")
+	bug := fakeWorkItem(3, map[string]interface{}{"System.WorkItemType": "Bug", "System.Title": "Bug", "System.State": "Active"})
+	task := fakeWorkItem(4, map[string]interface{}{"System.WorkItemType": "Task", "System.Title": "Task", "System.State": "Active", "System.Parent": float64(2)})
+	otherArea := fakeStory(5, "Outra área", "Active")
+	(*otherArea.Fields)["System.AreaPath"] = `Projeto\Outra`
+	(*unassigned.Fields)["System.AreaPath"] = `Projeto\Web`
+	(*closed.Fields)["System.AreaPath"] = `Projeto\Web`
+
+	workClient := &fakeWorkClient{
+		iterations: []work.TeamSettingsIteration{iteration},
+		relations: map[uuid.UUID][]workitemtracking.WorkItemLink{
+			*iteration.Id: {fakeLink(0, 1), fakeLink(0, 2), fakeLink(0, 3), fakeLink(2, 4), fakeLink(0, 5)},
+		},
+	}
+	witClient := newFakeWitClient(closed, unassigned, bug, task, otherArea)
+	cfg := &Config{Project: "Projeto", Team: "Time", TeamName: "Time", WorkItemTypes: []string{"User Story"}, ChildWorkItemTypes: []string{"Task"},
+		DefaultCapacityPerDay: 8, HoursPerStoryPoint: 8, DueDateWriteField: dueDateField, DiagnosticExamples: 5, Location: time.UTC}
+	store, err := newRunStore("", runRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := handleGenerateDueDates(newFakePool(workClient, witClient), cfg, store, newIdempotencyStore(time.Hour), nil)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/generate-due-dates?sprint=Sprint%207&strategy=capacity&dryRun=true&areaPath=Projeto%5CWeb", nil)
+	handler(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var report GenerationReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	diagnostics := report.Diagnostics
+	if diagnostics == nil || diagnostics.Schedulable != 0 || diagnostics.Collapsed {
+		t.Fatalf("diagnóstico = %+v, quer plano vazio expandido", diagnostics)
+	}
+	want := map[string][]int{
+		diagnosticCompleted:   {1},
+		diagnosticUnassigned:  {2},
+		diagnosticOtherType:   {3},
+		diagnosticOutsideArea: {5},
+	}
+	for _, bucket := range diagnostics.Buckets {
+		if !reflect.DeepEqual(bucket.Examples, want[bucket.Code]) || bucket.Count != len(want[bucket.Code]) {
+			t.Errorf("motivo %s = %+v, quer %v", bucket.Code, bucket, want[bucket.Code])
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/webapi"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+// Campo gravado pelos endpoints de escrita de data
+const dueDateField = "Microsoft.VSTS.Scheduling.DueDate"
+
+// Resultado da gravação da data de um work item
+type DueDateUpdate struct {
+	ID         int        `json:"id"`
+	OldDueDate *time.Time `json:"oldDueDate"`
+	NewDueDate *time.Time `json:"newDueDate"`
+	Revision   int        `json:"revision"`
+}
+
+// Situação de cada item em POST /due-dates
+const (
+	dueDateUpdated = "updated"
+	dueDateSkipped = "skipped"
+	dueDateFailed  = "failed"
+)
+
+// Limite de itens por lote em POST /due-dates
+const maxDueDateBatch = 500
+
+// Intervalo entre escritas do lote, para não estourar o limite de requisições do Azure DevOps
+const dueDateBatchInterval = 100 * time.Millisecond
+
+// Item pedido em POST /due-dates
+type dueDateBatchItem struct {
+	ID      int    `json:"id"`
+	DueDate string `json:"dueDate"`
+}
+
+// Resultado de um item do lote
+type DueDateBatchResult struct {
+	DueDateUpdate
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type DueDateBatchResponse struct {
+	RunID string `json:"runId,omitempty"`
+	// Resposta guardada devolvida para um Idempotency-Key repetido
+	Replayed bool                 `json:"replayed,omitempty"`
+	Updated  int                  `json:"updated"`
+	Skipped  int                  `json:"skipped"`
+	Failed   int                  `json:"failed"`
+	Results  []DueDateBatchResult `json:"results"`
+}
+
+// Resposta de PATCH /work-items/{id}/due-date
+type DueDateResponse struct {
+	DueDateUpdate
+	RunID string `json:"runId"`
+}
+
+// Corpo de PATCH /work-items/{id}/due-date
+type dueDateRequest struct {
+	DueDate string `json:"dueDate"`
+}
+
+// Função para ler a data pedida, mantendo só o dia (meia-noite UTC, como o
+// Azure DevOps guarda datas sem horário)
+func parseDueDate(value string) (time.Time, error) {
+	parsed, err := parseDate(strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, err
+	}
+	year, month, day := parsed.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC), nil
+}
+
+// Função para exibir um DueDate em mensagens e comentários; "nenhum" quando vazio
+func formatDueDate(date *time.Time) string {
+	if date == nil {
+		return "nenhum"
+	}
+	return date.Format("2006-01-02")
+}
+
+// Função para ler a data atual de um campo de data; nil quando vazio
+func currentFieldDate(fields *map[string]interface{}, field string) *time.Time {
+	value := getFieldValue(fields, field)
+	if value == "" {
+		return nil
+	}
+	date, err := parseDate(value)
+	if err != nil {
+		log.Printf("[WARN] %s atual ilegível (%q): %v", field, value, err)
+		return nil
+	}
+	return &date
+}
+
+// Função para comparar duas datas de entrega pelo dia; nil só é igual a nil
+func sameDueDate(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return sprintDate(*a).Equal(sprintDate(*b))
+}
+
+// Função para montar o JSON Patch de um campo de data: add grava ou
+// substitui o valor, remove limpa o campo (newDate nil)
+func fieldDatePatch(field string, newDate *time.Time) []webapi.JsonPatchOperation {
+	path := "/fields/" + field
+	if newDate == nil {
+		return []webapi.JsonPatchOperation{{Op: &webapi.OperationValues.Remove, Path: &path}}
+	}
+	return []webapi.JsonPatchOperation{{
+		Op:    &webapi.OperationValues.Add,
+		Path:  &path,
+		Value: newDate.UTC().Format(time.RFC3339),
+	}}
+}
+
+// Função para montar a operação que grava a lista completa de tags; o
+// Azure DevOps substitui System.Tags inteiro, então as existentes vão junto
+func tagsPatch(tags []string) webapi.JsonPatchOperation {
+	path := "/fields/System.Tags"
+	return webapi.JsonPatchOperation{Op: &webapi.OperationValues.Add, Path: &path, Value: strings.Join(tags, "; ")}
+}
+
+// Função para montar a operação test em /rev, que faz o Azure DevOps recusar
+// a gravação se o work item mudou desde a leitura. Sem revisão (0) não testa.
+func revisionTest(revision int) []webapi.JsonPatchOperation {
+	if revision <= 0 {
+		return nil
+	}
+	path := "/rev"
+	return []webapi.JsonPatchOperation{{Op: &webapi.OperationValues.Test, Path: &path, Value: revision}}
+}
+
+// Função para gravar a data de um work item no campo do time
+// (DUE_DATE_WRITE_FIELD). oldDate é o valor lido antes da escrita e volta no
+// resultado, junto com a nova revisão.
+func applyDueDate(ctx context.Context, witClient workitemtracking.Client, project string, id int, field string, oldDate, newDate *time.Time) (DueDateUpdate, error) {
+	return patchDueDate(ctx, witClient, project, id, field, fieldDatePatch(field, newDate), oldDate, newDate)
+}
+
+// Função para aplicar um documento JSON Patch que grava a data em field (e
+// eventualmente outras operações na mesma chamada)
+func patchDueDate(ctx context.Context, witClient workitemtracking.Client, project string, id int, field string, document []webapi.JsonPatchOperation, oldDate, newDate *time.Time) (DueDateUpdate, error) {
+	result := DueDateUpdate{ID: id, OldDueDate: oldDate, NewDueDate: newDate}
+	updated, err := witClient.UpdateWorkItem(ctx, workitemtracking.UpdateWorkItemArgs{
+		Document: &document,
+		Id:       &id,
+		Project:  &project,
+	})
+	if err != nil {
+		return result, wrapAdoError(err, "UpdateWorkItem", "id=%d, field=%s", id, field)
+	}
+	if updated != nil && updated.Rev != nil {
+		result.Revision = *updated.Rev
+	}
+	return result, nil
+}
+
+// Função para encontrar as datas da sprint de um IterationPath entre as
+// iterações do time. ok é false quando o caminho não é uma sprint do time
+// ou a sprint não tem datas.
+func sprintWindowForPath(ctx context.Context, workClient work.Client, cfg *Config, iterationPath string) (start, end time.Time, ok bool, err error) {
+	iterations, err := workClient.GetTeamIterations(ctx, work.GetTeamIterationsArgs{
+		Project: &cfg.Project,
+		Team:    &cfg.Team,
+	})
+	if err != nil {
+		return start, end, false, wrapAdoError(err, "GetTeamIterations", "team=%s", cfg.TeamName)
+	}
+	if iterations == nil {
+		return start, end, false, nil
+	}
+	wanted := normalizeIterationPath(iterationPath)
+	for _, iteration := range *iterations {
+		if iteration.Path == nil || normalizeIterationPath(*iteration.Path) != wanted {
+			continue
+		}
+		if iteration.Attributes == nil || iteration.Attributes.StartDate == nil || iteration.Attributes.FinishDate == nil {
+			return start, end, false, nil
+		}
+		return sprintDate(iteration.Attributes.StartDate.Time), sprintDate(iteration.Attributes.FinishDate.Time), true, nil
+	}
+	return start, end, false, nil
+}
+
+// Handler de PATCH (ou PUT) /work-items/{id}/due-date: grava a data de um
+// único work item no campo do time (DUE_DATE_WRITE_FIELD). Datas fora da
+// sprint do item são recusadas, exceto com ?force=true.
+func handleWorkItemDueDate(pool *adoPool, cfg *Config, runs *runStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch && r.Method != http.MethodPut {
+			jsonError(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		rest := strings.TrimPrefix(r.URL.Path, "/work-items/")
+		idText, suffix, found := strings.Cut(rest, "/")
+		if !found || suffix != "due-date" {
+			jsonError(w, "Rota não encontrada", http.StatusNotFound)
+			return
+		}
+		id, err := strconv.Atoi(idText)
+		if err != nil || id <= 0 {
+			jsonError(w, fmt.Sprintf("ID de work item inválido: %q", idText), http.StatusBadRequest)
+			return
+		}
+
+		var body dueDateRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			jsonError(w, fmt.Sprintf("Corpo inválido: %v", err), http.StatusBadRequest)
+			return
+		}
+		if body.DueDate == "" {
+			jsonError(w, "Campo 'dueDate' é obrigatório", http.StatusBadRequest)
+			return
+		}
+		newDate, err := parseDueDate(body.DueDate)
+		if err != nil {
+			jsonError(w, fmt.Sprintf("Campo 'dueDate' inválido: %v", err), http.StatusBadRequest)
+			return
+		}
+		force := r.URL.Query().Get("force") == "true"
+		field := cfg.writeField()
+
+		ctx := r.Context()
+		witClient, err := pool.WorkItems(ctx, "due-date")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+		workItem, err := witClient.GetWorkItem(ctx, workitemtracking.GetWorkItemArgs{
+			Id:      &id,
+			Project: &cfg.Project,
+			Fields:  &[]string{field, "System.IterationPath"},
+		})
+		if err != nil {
+			respondError(w, fmt.Sprintf("Erro ao buscar work item #%d", id), wrapAdoError(err, "GetWorkItem", "id=%d", id))
+			return
+		}
+
+		if !force {
+			workClient, err := pool.Work(ctx, "due-date")
+			if err != nil {
+				respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
+				return
+			}
+			iterationPath := getFieldValue(workItem.Fields, "System.IterationPath")
+			start, end, ok, err := sprintWindowForPath(ctx, workClient, cfg, iterationPath)
+			if err != nil {
+				respondError(w, "Erro ao buscar a sprint do work item", err)
+				return
+			}
+			if !ok {
+				jsonError(w, fmt.Sprintf("Work item #%d não está em uma sprint do time com datas (%s); use ?force=true para gravar mesmo assim", id, iterationPath), http.StatusUnprocessableEntity)
+				return
+			}
+			if newDate.Before(start) || newDate.After(end) {
+				jsonError(w, fmt.Sprintf("Data %s fora da sprint do work item #%d (%s a %s); use ?force=true para gravar mesmo assim",
+					newDate.Format("2006-01-02"), id, start.Format("2006-01-02"), end.Format("2006-01-02")), http.StatusUnprocessableEntity)
+				return
+			}
+		}
+
+		result, err := applyDueDate(ctx, witClient, cfg.Project, id, field, currentFieldDate(workItem.Fields, field), &newDate)
+		if err != nil {
+			respondError(w, fmt.Sprintf("Erro ao gravar a data do work item #%d", id), err)
+			return
+		}
+		runID := runs.record(runSourcePatch, cfg.Team, field, []DueDateRunItem{{ID: id, PreviousDueDate: result.OldDueDate, NewDueDate: result.NewDueDate}})
+		log.Printf("[DEBUG] %s do work item #%d gravado: %s (revisão %d, execução %s)", field, id, newDate.Format("2006-01-02"), result.Revision, runID)
+		writeJSON(w, http.StatusOK, DueDateResponse{DueDateUpdate: result, RunID: runID})
+	}
+}
+
+// Handler de POST /due-dates: grava a data de vários work items no campo do
+// time (DUE_DATE_WRITE_FIELD). Falhas
+// individuais não interrompem o lote; cada item volta com updated, skipped
+// (data já igual ou ID repetido) ou failed. Com alguma falha a resposta é 207.
+func handleDueDatesBatch(pool *adoPool, cfg *Config, runs *runStore, idempotency *idempotencyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			jsonError(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// O corpo é lido inteiro para entrar na chave de idempotência
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			jsonError(w, fmt.Sprintf("Erro ao ler o corpo: %v", err), http.StatusBadRequest)
+			return
+		}
+		var items []dueDateBatchItem
+		if err := json.Unmarshal(body, &items); err != nil {
+			jsonError(w, fmt.Sprintf("Corpo inválido: %v", err), http.StatusBadRequest)
+			return
+		}
+		if len(items) == 0 || len(items) > maxDueDateBatch {
+			jsonError(w, fmt.Sprintf("O lote deve ter entre 1 e %d itens (recebidos %d)", maxDueDateBatch, len(items)), http.StatusBadRequest)
+			return
+		}
+		// A mesma chave em outro time executa de novo
+		idempotencyScope := "due-dates:" + cfg.Team
+		idempotencyKey, ok := idempotency.claim(w, r, idempotencyScope, body)
+		if !ok {
+			return
+		}
+		defer idempotency.release(idempotencyScope, idempotencyKey)
+		field := cfg.writeField()
+
+		ctx := r.Context()
+		witClient, err := pool.WorkItems(ctx, "due-dates")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+
+		// Valores atuais de todos os itens em poucas chamadas, para o valor
+		// anterior e para pular o que já está correto
+		var ids []int
+		for _, item := range items {
+			if item.ID > 0 {
+				ids = append(ids, item.ID)
+			}
+		}
+		ids = dedupeIds(ids)
+		current := make(map[int]*time.Time)
+		exists := make(map[int]bool)
+		if len(ids) > 0 {
+			workItems, err := getWorkItemsChunked(ctx, witClient, cfg.Project, ids, []string{field})
+			if err != nil {
+				respondError(w, "Erro ao buscar os work items do lote", err)
+				return
+			}
+			for _, workItem := range presentWorkItems(ids, workItems) {
+				exists[*workItem.Id] = true
+				current[*workItem.Id] = currentFieldDate(workItem.Fields, field)
+			}
+		}
+
+		response := DueDateBatchResponse{Results: make([]DueDateBatchResult, 0, len(items))}
+		seen := make(map[int]bool)
+		wrote := false
+		var written []DueDateRunItem
+		for _, item := range items {
+			result := DueDateBatchResult{DueDateUpdate: DueDateUpdate{ID: item.ID}}
+			newDate, parseErr := parseDueDate(item.DueDate)
+			switch {
+			case item.ID <= 0:
+				result.Status, result.Reason = dueDateFailed, "ID inválido"
+			case seen[item.ID]:
+				result.Status, result.Reason = dueDateSkipped, "ID repetido no lote"
+			case parseErr != nil:
+				result.Status, result.Reason = dueDateFailed, fmt.Sprintf("dueDate inválido: %v", parseErr)
+			case !exists[item.ID]:
+				result.Status, result.Reason = dueDateFailed, fmt.Sprintf("Work item #%d não encontrado", item.ID)
+			case sameDueDate(current[item.ID], &newDate):
+				result.OldDueDate, result.NewDueDate = current[item.ID], current[item.ID]
+				result.Status, result.Reason = dueDateSkipped, "data já está correta"
+			default:
+				// Espaça as escritas; o pool limita a concorrência, não a taxa
+				if wrote {
+					select {
+					case <-time.After(dueDateBatchInterval):
+					case <-ctx.Done():
+					}
+				}
+				wrote = true
+				update, err := applyDueDate(ctx, witClient, cfg.Project, item.ID, field, current[item.ID], &newDate)
+				result.DueDateUpdate = update
+				if err != nil {
+					log.Printf("[ERROR] %v", err)
+					result.Status, result.Reason = dueDateFailed, err.Error()
+				} else {
+					result.Status = dueDateUpdated
+					written = append(written, DueDateRunItem{ID: item.ID, PreviousDueDate: update.OldDueDate, NewDueDate: update.NewDueDate})
+				}
+			}
+			if item.ID > 0 {
+				seen[item.ID] = true
+			}
+
+			switch result.Status {
+			case dueDateUpdated:
+				response.Updated++
+			case dueDateSkipped:
+				response.Skipped++
+			case dueDateFailed:
+				response.Failed++
+			}
+			response.Results = append(response.Results, result)
+		}
+
+		// Só os itens gravados entram no registro usado pelo rollback
+		response.RunID = runs.record(runSourceBatch, cfg.Team, field, written)
+		log.Printf("[DEBUG] Lote de datas: %d gravados, %d ignorados, %d falhas (execução %s)", response.Updated, response.Skipped, response.Failed, response.RunID)
+		status := http.StatusOK
+		if response.Failed > 0 {
+			status = http.StatusMultiStatus
+		}
+		replayed := response
+		replayed.Replayed = true
+		idempotency.complete(idempotencyScope, idempotencyKey, status, replayed)
+		writeJSON(w, status, response)
+	}
+}
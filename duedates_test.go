@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleDueDatesBatchWriteField(t *testing.T) {
+	const field = "Custom.DataEntrega"
+	witClient := newFakeWitClient(fakeWorkItem(1, map[string]interface{}{
+		dueDateField: day(2024, 3, 1).Format(time.RFC3339),
+		field:        day(2024, 3, 6).Format(time.RFC3339),
+	}))
+	store, err := newRunStore("", runRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idempotency := newIdempotencyStore(time.Hour)
+	batch := func(team string) DueDateBatchResponse {
+		t.Helper()
+		cfg := &Config{Project: "Projeto", Team: team, DueDateWriteField: field}
+		request := httptest.NewRequest(http.MethodPost, "/due-dates", strings.NewReader(`[{"id": 1, "dueDate": "2024-03-08"}]`))
+		request.Header.Set(idempotencyHeader, "lote-1")
+		recorder := httptest.NewRecorder()
+		handleDueDatesBatch(newFakePool(nil, witClient), cfg, store, idempotency)(recorder, request)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+		}
+		var response DueDateBatchResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	response := batch("Time A")
+	if response.Updated != 1 || !sameDueDate(response.Results[0].OldDueDate, ptrDay(day(2024, 3, 6))) {
+		t.Fatalf("lote = %+v, quer #1 gravado a partir da data de %s", response, field)
+	}
+	if got := currentFieldDate(witClient.items[1].Fields, field); !sameDueDate(got, ptrDay(day(2024, 3, 8))) {
+		t.Errorf("%s = %v, quer 2024-03-08", field, got)
+	}
+	if got := currentFieldDate(witClient.items[1].Fields, dueDateField); !sameDueDate(got, ptrDay(day(2024, 3, 1))) {
+		t.Errorf("%s = %v, quer o valor original", dueDateField, got)
+	}
+	if run, ok := store.get(response.RunID); !ok || run.Team != "Time A" || run.Field != field {
+		t.Errorf("execução = time %q, campo %q; quer Time A e %s", run.Team, run.Field, field)
+	}
+
+	// A mesma chave em outro time não devolve a resposta do primeiro
+	if replayed := batch("Time B"); replayed.Replayed || replayed.Skipped != 1 {
+		t.Errorf("Time B com a mesma chave = %+v, quer o lote executado de novo (já com a data)", replayed)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Item de /due-today e /overdue: o WorkItem de /user-stories e, em /overdue,
+// há quantos dias corridos a data passou
+type DueWorkItem struct {
+	WorkItem
+	DaysOverdue int `json:"daysOverdue,omitempty"`
+}
+
+// Função para obter o dia de um DueDate. Datas sem horário (meia-noite UTC,
+// como as gravadas por esta API) valem pelo próprio dia; as demais são
+// convertidas para o fuso configurado.
+func dueDateDay(date time.Time, cal workCalendar) time.Time {
+	utc := date.UTC()
+	if utc.Hour() == 0 && utc.Minute() == 0 && utc.Second() == 0 && utc.Nanosecond() == 0 {
+		return sprintDate(utc)
+	}
+	return cal.dateOf(date)
+}
+
+// Função para verificar se um estado está em DONE_STATES
+func isDoneState(cfg *Config, state string) bool {
+	for _, done := range cfg.DoneStates {
+		if strings.EqualFold(done, state) {
+			return true
+		}
+	}
+	return false
+}
+
+// GET /due-today e GET /overdue: itens de backlog da sprint (padrão a atual)
+// com data hoje ou já vencida, comparando dias no fuso configurado. Em
+// /overdue, itens em DONE_STATES ficam de fora.
+func handleDueList(pool *adoPool, cfg *Config, overdue bool) http.HandlerFunc {
+	caller := "due-today"
+	if overdue {
+		caller = "overdue"
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ref := sprintRef{Name: "current"}
+		if r.URL.Query().Get("sprint") != "" || r.URL.Query().Get("sprintId") != "" {
+			var err error
+			ref, err = sprintRefFromRequest(r)
+			if err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		keepArea, err := areaPathFilterFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cal := workCalendar{Location: cfg.Location}
+		today := cal.dateOf(time.Now())
+
+		ctx := r.Context()
+		workClient, err := pool.Work(ctx, caller)
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
+			return
+		}
+		targetIteration, err := resolveSprintRef(ctx, workClient, cfg, ref)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		sprintName := *targetIteration.Name
+		setSprintHeaders(w, targetIteration)
+
+		witClient, err := pool.WorkItems(ctx, caller)
+		if err != nil {
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+		backlogRanks, err := fetchBacklogRanks(ctx, workClient, cfg)
+		if err != nil {
+			log.Printf("[WARN] Ordem do backlog indisponível: %v", err)
+		}
+
+		types, explicitTypes := typesFromRequest(cfg, r)
+		sprintStories, details, missing, err := fetchSprintStories(ctx, workClient, witClient, cfg, targetIteration, types, backlogRanks)
+		if err != nil {
+			respondError(w, "Erro ao buscar detalhes dos work items", err)
+			return
+		}
+		if explicitTypes {
+			if err := validateRequestedTypes(types, details); err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		stories := make([]WorkItem, 0)
+		daysOverdue := make(map[int]int)
+		for _, item := range sprintStories {
+			if item.Removed || item.DueDate == nil || !keepArea(item.AreaPath) {
+				continue
+			}
+			day := dueDateDay(*item.DueDate, cal)
+			switch {
+			case !overdue && day.Equal(today):
+				stories = append(stories, item)
+			case overdue && day.Before(today) && !isDoneState(cfg, item.State):
+				stories = append(stories, item)
+				daysOverdue[item.ID] = int(today.Sub(day).Hours() / 24)
+			}
+		}
+
+		if err := sortWorkItems(stories, r.URL.Query().Get("sort"), r.URL.Query().Get("order")); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Sem ?sort=, os mais atrasados vêm primeiro (empates pela prioridade)
+		if overdue && r.URL.Query().Get("sort") == "" {
+			sort.SliceStable(stories, func(i, j int) bool {
+				return daysOverdue[stories[i].ID] > daysOverdue[stories[j].ID]
+			})
+		}
+		result := make([]DueWorkItem, 0, len(stories))
+		for _, story := range stories {
+			result = append(result, DueWorkItem{WorkItem: story, DaysOverdue: daysOverdue[story.ID]})
+		}
+		log.Printf("[DEBUG] %d itens em /%s na sprint '%s' (hoje %s)", len(result), caller, sprintName, today.Format("2006-01-02"))
+		setMissingWorkItemsHeader(w, missing)
+		writeJSON(w, http.StatusOK, result)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7"
+)
+
+// Erros de domínio. Handlers e testes devem compará-los com errors.Is em vez
+// de inspecionar o texto da mensagem.
+var (
+	ErrSprintNotFound   = errors.New("sprint não encontrada")
+	ErrSprintAmbiguous  = errors.New("sprint ambígua")
+	ErrWorkItemNotFound = errors.New("work item não encontrado")
+	// 404 do Azure DevOps fora das operações de work item (time, iteração...)
+	ErrAdoNotFound    = errors.New("recurso não encontrado no Azure DevOps")
+	ErrAdoUnavailable = errors.New("Azure DevOps indisponível")
+	ErrAdoAuth        = errors.New("acesso negado pelo Azure DevOps")
+	ErrPlanInfeasible = errors.New("plano de datas inviável")
+	// O work item mudou entre a leitura e a gravação (operação test em /rev)
+	ErrConcurrentModification = errors.New("alteração concorrente no work item")
+)
+
+// SprintNotFoundError indica que nenhuma iteração do time corresponde ao
+// nome pedido. Compara como ErrSprintNotFound via errors.Is.
+type SprintNotFoundError struct {
+	Name string
+	// Nomes de sprint mais parecidos com o pedido, sugeridos na mensagem
+	Candidates []string
+}
+
+func (e *SprintNotFoundError) Error() string {
+	if len(e.Candidates) > 0 {
+		return fmt.Sprintf("Sprint '%s' não encontrada. Sprints parecidas: %s", e.Name, strings.Join(e.Candidates, ", "))
+	}
+	return fmt.Sprintf("Sprint '%s' não encontrada", e.Name)
+}
+
+func (e *SprintNotFoundError) Is(target error) bool {
+	return target == ErrSprintNotFound
+}
+
+// SprintAmbiguousError indica que o nome pedido corresponde a mais de uma
+// iteração (mesmo nome em caminhos diferentes). Compara como ErrSprintAmbiguous.
+type SprintAmbiguousError struct {
+	Name  string
+	Paths []string
+}
+
+func (e *SprintAmbiguousError) Error() string {
+	return fmt.Sprintf("Sprint '%s' é ambígua; use o caminho completo ou o sprintId. Caminhos: %s", e.Name, strings.Join(e.Paths, ", "))
+}
+
+func (e *SprintAmbiguousError) Is(target error) bool {
+	return target == ErrSprintAmbiguous
+}
+
+// AdoError envolve um erro do SDK do Azure DevOps com a operação que falhou
+// e os parâmetros usados, além do erro de domínio correspondente (Kind).
+type AdoError struct {
+	Op     string
+	Params string
+	Kind   error
+	Err    error
+}
+
+func (e *AdoError) Error() string {
+	if e.Params == "" {
+		return fmt.Sprintf("%s: %v", e.Op, e.Err)
+	}
+	return fmt.Sprintf("%s (%s): %v", e.Op, e.Params, e.Err)
+}
+
+// Permite errors.Is tanto com o erro de domínio quanto com o erro original
+func (e *AdoError) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// Função para envolver um erro do SDK com o contexto da operação.
+// params segue o formato de fmt.Sprintf, por exemplo "sprint=%s".
+func wrapAdoError(err error, op string, params string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	if len(args) > 0 {
+		params = fmt.Sprintf(params, args...)
+	}
+	return &AdoError{
+		Op:     op,
+		Params: params,
+		Kind:   adoErrorKind(err, op),
+		Err:    err,
+	}
+}
+
+// Operações do SDK que tratam de um work item específico; um 404 nelas quer
+// dizer que o work item não existe (ou não está visível)
+var workItemOps = map[string]bool{
+	"GetWorkItem":    true,
+	"GetWorkItems":   true,
+	"UpdateWorkItem": true,
+	"AddComment":     true,
+	"GetRevisions":   true,
+	"GetUpdates":     true,
+}
+
+// Função para obter o erro de domínio de uma operação: o 404 genérico vira
+// ErrWorkItemNotFound apenas nas operações de work item
+func adoErrorKind(err error, op string) error {
+	kind := classifyAdoError(err)
+	if kind == ErrAdoNotFound && workItemOps[op] {
+		return ErrWorkItemNotFound
+	}
+	return kind
+}
+
+// Função para obter o status HTTP de um erro do Azure DevOps; 0 quando o erro
+// não traz status (falhas de rede, timeouts)
+func adoStatusCode(err error) int {
+	var wrappedPtr *azuredevops.WrappedError
+	var wrapped azuredevops.WrappedError
+	if errors.As(err, &wrappedPtr) && wrappedPtr.StatusCode != nil {
+		return *wrappedPtr.StatusCode
+	} else if errors.As(err, &wrapped) && wrapped.StatusCode != nil {
+		return *wrapped.StatusCode
+	}
+	return 0
+}
+
+// Função para identificar o erro de domínio a partir do status HTTP devolvido
+// pelo Azure DevOps ou de falhas de rede
+func classifyAdoError(err error) error {
+	statusCode := adoStatusCode(err)
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrAdoAuth
+	case statusCode == http.StatusNotFound:
+		return ErrAdoNotFound
+	case statusCode == http.StatusPreconditionFailed || strings.Contains(err.Error(), "TF401289"):
+		// TF401289: a revisão atual não corresponde à informada
+		return ErrConcurrentModification
+	case statusCode == http.StatusTooManyRequests || statusCode >= 500:
+		return ErrAdoUnavailable
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) {
+		return ErrAdoUnavailable
+	}
+	return nil
+}
+
+// Função que concentra o mapeamento de erros de domínio para status HTTP
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, ErrSprintNotFound), errors.Is(err, ErrWorkItemNotFound), errors.Is(err, ErrAdoNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrSprintAmbiguous), errors.Is(err, ErrConcurrentModification):
+		return http.StatusConflict
+	case errors.Is(err, ErrPlanInfeasible), errors.Is(err, ErrInvalidDateRange):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrAdoAuth):
+		return http.StatusBadGateway
+	case errors.Is(err, ErrAdoUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7"
+)
+
+// Função para simular um erro do SDK com o status HTTP informado
+func adoStatusError(statusCode int, message string) error {
+	return &azuredevops.WrappedError{StatusCode: &statusCode, Message: &message}
+}
+
+func TestWrapAdoErrorKind(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		op   string
+		want error
+	}{
+		{name: "401", err: adoStatusError(http.StatusUnauthorized, "unauthorized"), op: "GetTeamIterations", want: ErrAdoAuth},
+		{name: "403", err: adoStatusError(http.StatusForbidden, "forbidden"), op: "UpdateWorkItem", want: ErrAdoAuth},
+		{name: "404 em work item", err: adoStatusError(http.StatusNotFound, "not found"), op: "GetWorkItem", want: ErrWorkItemNotFound},
+		{name: "404 em atualização", err: adoStatusError(http.StatusNotFound, "not found"), op: "UpdateWorkItem", want: ErrWorkItemNotFound},
+		{name: "404 em iteração", err: adoStatusError(http.StatusNotFound, "not found"), op: "GetTeamIteration", want: ErrAdoNotFound},
+		{name: "404 em capacidade", err: adoStatusError(http.StatusNotFound, "not found"), op: "GetCapacitiesWithIdentityRefAndTotals", want: ErrAdoNotFound},
+		{name: "412", err: adoStatusError(http.StatusPreconditionFailed, "precondition"), op: "UpdateWorkItem", want: ErrConcurrentModification},
+		{name: "TF401289", err: adoStatusError(http.StatusBadRequest, "TF401289: revision mismatch"), op: "UpdateWorkItem", want: ErrConcurrentModification},
+		{name: "429", err: adoStatusError(http.StatusTooManyRequests, "throttled"), op: "GetWorkItems", want: ErrAdoUnavailable},
+		{name: "503", err: adoStatusError(http.StatusServiceUnavailable, "down"), op: "QueryByWiql", want: ErrAdoUnavailable},
+		{name: "timeout", err: fmt.Errorf("request: %w", context.DeadlineExceeded), op: "GetWorkItems", want: ErrAdoUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := wrapAdoError(tt.err, tt.op, "id=%d", 42)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.want)
+			}
+			// O erro original continua acessível
+			if !errors.Is(err, tt.err) {
+				t.Errorf("erro original perdido em %v", err)
+			}
+			var adoErr *AdoError
+			if !errors.As(err, &adoErr) {
+				t.Fatalf("errors.As(%v, *AdoError) = false", err)
+			}
+			if adoErr.Op != tt.op || adoErr.Params != "id=42" {
+				t.Errorf("AdoError = {Op: %q, Params: %q}, quer {%q, \"id=42\"}", adoErr.Op, adoErr.Params, tt.op)
+			}
+		})
+	}
+}
+
+func TestWrapAdoErrorWithoutKind(t *testing.T) {
+	err := wrapAdoError(adoStatusError(http.StatusBadRequest, "bad request"), "UpdateWorkItem", "")
+	for _, kind := range []error{ErrWorkItemNotFound, ErrAdoNotFound, ErrAdoAuth, ErrAdoUnavailable, ErrConcurrentModification} {
+		if errors.Is(err, kind) {
+			t.Errorf("400 não deveria ser %v", kind)
+		}
+	}
+	if statusCode := adoStatusCode(err); statusCode != http.StatusBadRequest {
+		t.Errorf("adoStatusCode = %d, quer 400", statusCode)
+	}
+	if wrapAdoError(nil, "GetWorkItem", "") != nil {
+		t.Error("wrapAdoError(nil) deveria devolver nil")
+	}
+}
+
+func TestStatusForError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "sprint não encontrada", err: &SprintNotFoundError{Name: "Sprint 1"}, want: http.StatusNotFound},
+		{name: "work item não encontrado", err: wrapAdoError(adoStatusError(404, "not found"), "GetWorkItem", ""), want: http.StatusNotFound},
+		{name: "recurso não encontrado", err: wrapAdoError(adoStatusError(404, "not found"), "GetTeamDaysOff", ""), want: http.StatusNotFound},
+		{name: "sprint ambígua", err: &SprintAmbiguousError{Name: "Sprint 1", Paths: []string{`A\Sprint 1`, `B\Sprint 1`}}, want: http.StatusConflict},
+		{name: "alteração concorrente", err: wrapAdoError(adoStatusError(412, "precondition"), "UpdateWorkItem", ""), want: http.StatusConflict},
+		{name: "plano inviável", err: fmt.Errorf("sprint: %w", ErrPlanInfeasible), want: http.StatusUnprocessableEntity},
+		{name: "intervalo inválido", err: fmt.Errorf("%w: datas", ErrInvalidDateRange), want: http.StatusUnprocessableEntity},
+		{name: "autenticação", err: wrapAdoError(adoStatusError(401, "unauthorized"), "GetTeams", ""), want: http.StatusBadGateway},
+		{name: "indisponível", err: wrapAdoError(adoStatusError(500, "error"), "GetTeams", ""), want: http.StatusServiceUnavailable},
+		{name: "desconhecido", err: errors.New("falha"), want: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statusForError(tt.err); got != tt.want {
+				t.Errorf("statusForError(%v) = %d, quer %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFailedOutcomeReasonCodes(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: wrapAdoError(adoStatusError(404, "not found"), "UpdateWorkItem", ""), want: reasonAdoNotFound},
+		{err: wrapAdoError(adoStatusError(404, "not found"), "GetTeamIteration", ""), want: reasonAdoNotFound},
+		{err: wrapAdoError(adoStatusError(403, "forbidden"), "UpdateWorkItem", ""), want: reasonAdoAuth},
+		{err: wrapAdoError(adoStatusError(412, "precondition"), "UpdateWorkItem", ""), want: reasonConcurrentModification},
+		{err: wrapAdoError(adoStatusError(503, "down"), "UpdateWorkItem", ""), want: reasonAdoUnavailable},
+		{err: wrapAdoError(adoStatusError(400, "bad request"), "UpdateWorkItem", ""), want: reasonAdoError},
+	}
+	for _, tt := range tests {
+		outcome := failedOutcome(tt.err, 3)
+		if outcome.ReasonCode != tt.want {
+			t.Errorf("failedOutcome(%v).ReasonCode = %q, quer %q", tt.err, outcome.ReasonCode, tt.want)
+		}
+		if outcome.AdoStatus != adoStatusCode(tt.err) {
+			t.Errorf("failedOutcome(%v).AdoStatus = %d", tt.err, outcome.AdoStatus)
+		}
+	}
+}
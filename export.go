@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Colunas esperadas pela importação de CSV do MS Project (e por modelos de
+// Gantt no Excel), nesta ordem
+var projectCSVHeader = []string{"Name", "Duration", "Start", "Finish", "Predecessors", "Resource Names"}
+
+// Marca de ordem de bytes do UTF-8, para o Excel não corromper os acentos
+const utf8BOM = "\ufeff"
+
+// Linha da exportação para o MS Project
+type projectExportItem struct {
+	ID           int
+	Title        string
+	AssignedTo   string
+	Start        time.Time
+	Finish       time.Time
+	Predecessors []int
+	// User Story concluída: vira um marco (duração zero)
+	Completed bool
+}
+
+// GET /export/project-csv: o plano da geração (sempre dry-run) no formato
+// de importação do MS Project. Aceita os mesmos parâmetros de cálculo de
+// POST /generate-due-dates.
+func handleProjectExport(pool *adoPool, cfg *Config) http.HandlerFunc {
+	return generationHandler(pool, cfg, nil, nil, nil, generationExport)
+}
+
+// Função para montar as linhas da exportação: as User Stories com data no
+// plano, na ordem do cálculo (predecessores antes dos sucessores), seguidas
+// das concluídas como marcos na data que já têm (ou no início da sprint).
+// Devolve também os IDs que ficaram de fora por não terem data.
+func projectExportItems(items []GenerationItem, plans []generationPlan, completed []WorkItem, sprintStart, sprintEnd time.Time) ([]projectExportItem, []int) {
+	starts := make(map[int]time.Time, len(plans))
+	for _, plan := range plans {
+		starts[plan.Story.ID] = plan.Start
+	}
+	var rows []projectExportItem
+	var omitted []int
+	for _, item := range items {
+		if item.DueDate == nil {
+			omitted = append(omitted, item.ID)
+			continue
+		}
+		finish := sprintDate(*item.DueDate)
+		// O início nunca passa do fim (o ajuste de fim de semana pode ter recuado a data)
+		start := sprintDate(starts[item.ID])
+		if start.IsZero() || start.After(finish) {
+			start = finish
+		}
+		rows = append(rows, projectExportItem{
+			ID:           item.ID,
+			Title:        item.Title,
+			AssignedTo:   item.AssignedTo,
+			Start:        start,
+			Finish:       finish,
+			Predecessors: item.Predecessors,
+		})
+	}
+	for _, story := range completed {
+		date := sprintStart
+		if story.DueDate != nil {
+			date = sprintDate(*story.DueDate)
+		}
+		if date.Before(sprintStart) || date.After(sprintEnd) {
+			date = sprintStart
+		}
+		row := projectExportItem{ID: story.ID, Title: story.Title, Start: date, Finish: date, Completed: true}
+		if story.AssignedTo != nil {
+			row.AssignedTo = story.AssignedTo.DisplayName
+		}
+		rows = append(rows, row)
+	}
+	return rows, omitted
+}
+
+// O MS Project usa vírgula e colchetes como separadores em nomes de recurso
+var projectResourceReplacer = strings.NewReplacer(",", " ", "[", "(", "]", ")")
+
+// Função para limpar um nome de responsável para a coluna Resource Names
+func projectResourceName(name string) string {
+	return strings.Join(strings.Fields(projectResourceReplacer.Replace(name)), " ")
+}
+
+// Função para contar a duração em dias úteis do calendário do time (fins de
+// semana, feriados e folgas do time já fora de days), contando o início e o
+// fim. Itens abertos duram pelo menos um dia; concluídos são marcos de zero dias.
+func projectDuration(row projectExportItem, days []time.Time) int {
+	if row.Completed {
+		return 0
+	}
+	duration := 0
+	for _, day := range days {
+		if !day.Before(row.Start) && !day.After(row.Finish) {
+			duration++
+		}
+	}
+	return max(duration, 1)
+}
+
+// Função para escrever a duração como o MS Project mostra ("1 day", "3 days")
+func projectDurationText(days int) string {
+	if days == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", days)
+}
+
+// Função para gerar o CSV da exportação. Os predecessores viram números de
+// linha (a primeira linha de dados é 1), separados por vírgula na mesma
+// célula; vínculos com itens fora da exportação são descartados.
+func projectCSV(rows []projectExportItem, days []time.Time) ([]byte, error) {
+	rowNumber := make(map[int]int, len(rows))
+	for i, row := range rows {
+		rowNumber[row.ID] = i + 1
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString(utf8BOM)
+	writer := csv.NewWriter(&buffer)
+	writer.UseCRLF = true
+	if err := writer.Write(projectCSVHeader); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		var predecessors []string
+		for _, id := range row.Predecessors {
+			if number, ok := rowNumber[id]; ok {
+				predecessors = append(predecessors, strconv.Itoa(number))
+			}
+		}
+		record := []string{
+			fmt.Sprintf("#%d %s", row.ID, row.Title),
+			projectDurationText(projectDuration(row, days)),
+			row.Start.Format("2006-01-02"),
+			row.Finish.Format("2006-01-02"),
+			strings.Join(predecessors, ","),
+			projectResourceName(row.AssignedTo),
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Caracteres fora de nomes de arquivo seguros
+var unsafeFileNamePattern = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Função para montar o nome do anexo a partir do nome da sprint
+func exportFileName(sprintName string) string {
+	fileName := strings.Trim(unsafeFileNamePattern.ReplaceAllString(sprintName, "-"), "-")
+	if fileName == "" {
+		return "sprint"
+	}
+	return fileName
+}
+
+// Função para responder o CSV como anexo. Os IDs sem data no plano vão no
+// header X-Export-Omitted.
+func writeProjectCSV(w http.ResponseWriter, sprintName string, rows []projectExportItem, omitted []int, days []time.Time) {
+	body, err := projectCSV(rows, days)
+	if err != nil {
+		respondError(w, "Erro ao gerar o CSV", err)
+		return
+	}
+	fileName := exportFileName(sprintName)
+	if len(omitted) > 0 {
+		ids := make([]string, len(omitted))
+		for i, id := range omitted {
+			ids[i] = strconv.Itoa(id)
+		}
+		w.Header().Set("X-Export-Omitted", strings.Join(ids, ","))
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, fileName))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		log.Printf("[ERROR] Erro ao escrever resposta: %v", err)
+	}
+}
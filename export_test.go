@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Sprint de segunda 04/03/2024 a sexta 15/03/2024 com folga do time na quarta 13/03
+func projectExportFixture(t *testing.T) ([]projectExportItem, []time.Time) {
+	t.Helper()
+	days, err := workingDates(day(2024, 3, 4), day(2024, 3, 15), []DayOff{{Start: day(2024, 3, 13), End: day(2024, 3, 13)}}, workCalendar{Location: time.UTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	thursday := day(2024, 3, 7)
+	monday := day(2024, 3, 11)
+	tuesday := day(2024, 3, 12)
+	friday := day(2024, 3, 15)
+	items := []GenerationItem{
+		{ID: 1, Title: "Login", AssignedTo: "Ana", DueDate: ptrDay(day(2024, 3, 6))},
+		// Vírgula no título e nome de recurso com separadores do MS Project
+		{ID: 2, Title: "Cadastro, etapa 2", AssignedTo: "Silva, Bruno [QA]", DueDate: &monday, Predecessors: []int{1}},
+		// Vários predecessores, um deles fora da exportação
+		{ID: 3, Title: "Relatório de vendas", AssignedTo: "Ana", DueDate: &tuesday, Predecessors: []int{1, 2, 99}},
+		// A folga de quarta não conta na duração
+		{ID: 4, Title: "Integração", AssignedTo: "Carla", DueDate: ptrDay(day(2024, 3, 14))},
+		// Sem data no plano: fica de fora
+		{ID: 6, Title: "Sem responsável"},
+		// Data recuada pelo ajuste de fim de semana para antes do início
+		{ID: 7, Title: "Ajuste", AssignedTo: "Ana", DueDate: &friday},
+	}
+	plans := []generationPlan{
+		{Story: WorkItem{ID: 1}, Start: day(2024, 3, 4)},
+		{Story: WorkItem{ID: 2}, Start: thursday},
+		{Story: WorkItem{ID: 3}, Start: tuesday},
+		{Story: WorkItem{ID: 4}, Start: tuesday},
+		{Story: WorkItem{ID: 7}, Start: day(2024, 3, 16)},
+	}
+	completed := []WorkItem{
+		{ID: 5, Title: "Setup do ambiente", AssignedTo: &Identity{DisplayName: "Bruno"}, DueDate: ptrDay(day(2024, 3, 5))},
+		// Sem data, ou com data fora da sprint: marco no início da sprint
+		{ID: 8, Title: "Spike"},
+		{ID: 9, Title: "Antiga", DueDate: ptrDay(day(2024, 2, 20))},
+	}
+	rows, omitted := projectExportItems(items, plans, completed, day(2024, 3, 4), day(2024, 3, 15))
+	if !reflect.DeepEqual(omitted, []int{6}) {
+		t.Errorf("omitidos = %v, quer [6]", omitted)
+	}
+	return rows, days
+}
+
+func ptrDay(date time.Time) *time.Time {
+	return &date
+}
+
+func TestProjectCSVMatchesExpectedFile(t *testing.T) {
+	rows, days := projectExportFixture(t)
+	got, err := projectCSV(rows, days)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("testdata/project-export.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("CSV diferente de testdata/project-export.csv:\n%s", got)
+	}
+}
+
+func TestProjectCSVRoundTrip(t *testing.T) {
+	rows, days := projectExportFixture(t)
+	body, err := projectCSV(rows, days)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(body, []byte(utf8BOM)) {
+		t.Error("CSV sem BOM do UTF-8")
+	}
+	records, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(body, []byte(utf8BOM)))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(records[0], projectCSVHeader) {
+		t.Fatalf("cabeçalho = %v", records[0])
+	}
+	records = records[1:]
+	if len(records) != len(rows) {
+		t.Fatalf("%d linhas, quer %d", len(records), len(rows))
+	}
+
+	// Lido de volta, cada linha aponta para os predecessores certos e a duração bate com o calendário
+	wantDurations := map[int]int{1: 3, 2: 3, 3: 1, 4: 2, 5: 0, 7: 1, 8: 0, 9: 0}
+	wantPredecessors := map[int][]int{2: {1}, 3: {1, 2}}
+	for i, record := range records {
+		row := rows[i]
+		if !strings.HasPrefix(record[0], "#"+strconv.Itoa(row.ID)+" ") {
+			t.Errorf("linha %d: nome %q não começa com #%d", i+1, record[0], row.ID)
+		}
+		duration, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSuffix(record[1], "s"), " day"))
+		if err != nil || duration != wantDurations[row.ID] {
+			t.Errorf("#%d: duração %q, quer %d days", row.ID, record[1], wantDurations[row.ID])
+		}
+		start, errStart := time.Parse("2006-01-02", record[2])
+		finish, errFinish := time.Parse("2006-01-02", record[3])
+		if errStart != nil || errFinish != nil || finish.Before(start) {
+			t.Errorf("#%d: início %q e fim %q inválidos", row.ID, record[2], record[3])
+		}
+		var predecessors []int
+		if record[4] != "" {
+			for _, number := range strings.Split(record[4], ",") {
+				n, err := strconv.Atoi(number)
+				if err != nil || n < 1 || n > len(rows) {
+					t.Fatalf("#%d: predecessor %q não é uma linha", row.ID, number)
+				}
+				predecessors = append(predecessors, rows[n-1].ID)
+			}
+		}
+		if !reflect.DeepEqual(predecessors, wantPredecessors[row.ID]) {
+			t.Errorf("#%d: predecessores %v, quer %v", row.ID, predecessors, wantPredecessors[row.ID])
+		}
+		if strings.ContainsAny(record[5], ",[]") {
+			t.Errorf("#%d: recurso %q com separadores do MS Project", row.ID, record[5])
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/webapi"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+// fakeWitClient implementa só as chamadas de work item tracking usadas nos
+// testes; as demais caem no Client embutido (nil) e entram em pânico.
+type fakeWitClient struct {
+	workitemtracking.Client
+
+	mu sync.Mutex
+	// Work items existentes, por ID; IDs ausentes voltam como entrada vazia
+	// (política Omit) ou como 404 quando a política não é Omit
+	items map[int]workitemtracking.WorkItem
+	// IDs pedidos em cada chamada de GetWorkItems, na ordem das chamadas
+	getWorkItemsCalls [][]int
+	// Quando definido, substitui o comportamento padrão de GetWorkItems
+	getWorkItems func(ctx context.Context, args workitemtracking.GetWorkItemsArgs) (*[]workitemtracking.WorkItem, error)
+	// Consultas WIQL recebidas e a resposta de cada uma (IDs encontrados)
+	wiqlQueries []string
+	queryByWiql func(query string) []int
+	// Documentos JSON Patch recebidos por UpdateWorkItem, por ID, na ordem
+	updates map[int][][]webapi.JsonPatchOperation
+	// Quando definido, roda antes de cada UpdateWorkItem (edição concorrente)
+	beforeUpdate func(id int)
+	// Tipos de work item do projeto, devolvidos por GetWorkItemTypes
+	types []string
+	// Textos recebidos por AddComment, por ID, na ordem
+	comments map[int][]string
+}
+
+func newFakeWitClient(items ...workitemtracking.WorkItem) *fakeWitClient {
+	fake := &fakeWitClient{items: make(map[int]workitemtracking.WorkItem)}
+	for _, item := range items {
+		fake.items[*item.Id] = item
+	}
+	return fake
+}
+
+func (f *fakeWitClient) GetWorkItems(ctx context.Context, args workitemtracking.GetWorkItemsArgs) (*[]workitemtracking.WorkItem, error) {
+	f.mu.Lock()
+	if args.Ids != nil {
+		f.getWorkItemsCalls = append(f.getWorkItemsCalls, append([]int(nil), *args.Ids...))
+	}
+	override := f.getWorkItems
+	f.mu.Unlock()
+	if override != nil {
+		return override(ctx, args)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	result := []workitemtracking.WorkItem{}
+	if args.Ids == nil {
+		return &result, nil
+	}
+	for _, id := range *args.Ids {
+		item, ok := f.items[id]
+		if !ok {
+			if args.ErrorPolicy == nil || *args.ErrorPolicy != workitemtracking.WorkItemErrorPolicyValues.Omit {
+				return nil, adoStatusError(404, "TF401232: Work item does not exist")
+			}
+		}
+		result = append(result, item)
+	}
+	return &result, nil
+}
+
+// Função para montar a resposta do GetWorkItems com a política Omit, para
+// hooks de getWorkItems que só querem atrasar ou observar a chamada
+func (f *fakeWitClient) omitted(ids []int) *[]workitemtracking.WorkItem {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	result := make([]workitemtracking.WorkItem, len(ids))
+	for i, id := range ids {
+		result[i] = f.items[id]
+	}
+	return &result
+}
+
+func (f *fakeWitClient) QueryByWiql(ctx context.Context, args workitemtracking.QueryByWiqlArgs) (*workitemtracking.WorkItemQueryResult, error) {
+	f.mu.Lock()
+	query := *args.Wiql.Query
+	f.wiqlQueries = append(f.wiqlQueries, query)
+	handler := f.queryByWiql
+	f.mu.Unlock()
+	references := []workitemtracking.WorkItemReference{}
+	if handler != nil {
+		for _, id := range handler(query) {
+			id := id
+			references = append(references, workitemtracking.WorkItemReference{Id: &id})
+		}
+	}
+	return &workitemtracking.WorkItemQueryResult{WorkItems: &references}, nil
+}
+
+func (f *fakeWitClient) GetWorkItemTypes(ctx context.Context, args workitemtracking.GetWorkItemTypesArgs) (*[]workitemtracking.WorkItemType, error) {
+	result := []workitemtracking.WorkItemType{}
+	for _, name := range f.types {
+		name := name
+		result = append(result, workitemtracking.WorkItemType{Name: &name})
+	}
+	return &result, nil
+}
+
+// UpdateWorkItem aplica add/remove em /fields/* e, como o Azure DevOps,
+// recusa o documento inteiro com 412 quando o test em /rev não confere
+func (f *fakeWitClient) UpdateWorkItem(ctx context.Context, args workitemtracking.UpdateWorkItemArgs) (*workitemtracking.WorkItem, error) {
+	f.mu.Lock()
+	hook := f.beforeUpdate
+	f.mu.Unlock()
+	if hook != nil {
+		hook(*args.Id)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.updates == nil {
+		f.updates = make(map[int][][]webapi.JsonPatchOperation)
+	}
+	f.updates[*args.Id] = append(f.updates[*args.Id], *args.Document)
+	item, ok := f.items[*args.Id]
+	if !ok {
+		return nil, adoStatusError(404, "TF401232: Work item does not exist")
+	}
+	for _, operation := range *args.Document {
+		if *operation.Op == webapi.OperationValues.Test && *operation.Path == "/rev" && operation.Value != *item.Rev {
+			return nil, adoStatusError(412, "TF401289: The current work item revision does not match the expected revision")
+		}
+	}
+	fields := make(map[string]interface{})
+	for name, value := range *item.Fields {
+		fields[name] = value
+	}
+	for _, operation := range *args.Document {
+		field, isField := strings.CutPrefix(*operation.Path, "/fields/")
+		switch {
+		case !isField:
+		case *operation.Op == webapi.OperationValues.Remove:
+			delete(fields, field)
+		default:
+			fields[field] = operation.Value
+		}
+	}
+	rev := *item.Rev + 1
+	item.Rev, item.Fields = &rev, &fields
+	f.items[*args.Id] = item
+	return &item, nil
+}
+
+func (f *fakeWitClient) AddComment(ctx context.Context, args workitemtracking.AddCommentArgs) (*workitemtracking.Comment, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.comments == nil {
+		f.comments = make(map[int][]string)
+	}
+	f.comments[*args.WorkItemId] = append(f.comments[*args.WorkItemId], *args.Request.Text)
+	return &workitemtracking.Comment{WorkItemId: args.WorkItemId, Text: args.Request.Text}, nil
+}
+
+// Função para editar um work item do fake como outro usuário faria: troca o
+// campo e avança a revisão
+func (f *fakeWitClient) edit(id int, field string, value interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	item := f.items[id]
+	fields := make(map[string]interface{})
+	for name, current := range *item.Fields {
+		fields[name] = current
+	}
+	fields[field] = value
+	rev := *item.Rev + 1
+	item.Rev, item.Fields = &rev, &fields
+	f.items[id] = item
+}
+
+// Função para montar um work item do fake com os campos informados
+func fakeWorkItem(id int, fields map[string]interface{}) workitemtracking.WorkItem {
+	if fields == nil {
+		fields = map[string]interface{}{}
+	}
+	rev := 1
+	return workitemtracking.WorkItem{Id: &id, Rev: &rev, Fields: &fields}
+}
+
+// fakeWorkClient implementa as chamadas de work (iterações, capacidades)
+// usadas nos testes, com as respostas fixas informadas
+type fakeWorkClient struct {
+	work.Client
+
+	mu         sync.Mutex
+	iterations []work.TeamSettingsIteration
+	// Relações devolvidas por GetIterationWorkItems, por ID da iteração
+	relations map[uuid.UUID][]workitemtracking.WorkItemLink
+	// Capacidades e folgas do time, por ID da iteração
+	capacities map[uuid.UUID]*work.TeamCapacity
+	daysOff    map[uuid.UUID][]work.DateRange
+	// IDs do backlog do time na ordem manual do board
+	backlog []int
+	// Nomes das chamadas feitas, na ordem
+	calls []string
+}
+
+func (f *fakeWorkClient) record(call string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call)
+}
+
+func (f *fakeWorkClient) GetTeamIterations(ctx context.Context, args work.GetTeamIterationsArgs) (*[]work.TeamSettingsIteration, error) {
+	call := "GetTeamIterations"
+	if args.Timeframe != nil {
+		call += "(" + *args.Timeframe + ")"
+	}
+	f.record(call)
+	var result []work.TeamSettingsIteration
+	for _, iteration := range f.iterations {
+		if args.Timeframe != nil && (iteration.Attributes == nil || iteration.Attributes.TimeFrame == nil ||
+			!strings.EqualFold(string(*iteration.Attributes.TimeFrame), *args.Timeframe)) {
+			continue
+		}
+		result = append(result, iteration)
+	}
+	return &result, nil
+}
+
+func (f *fakeWorkClient) GetTeamIteration(ctx context.Context, args work.GetTeamIterationArgs) (*work.TeamSettingsIteration, error) {
+	f.record("GetTeamIteration")
+	for _, iteration := range f.iterations {
+		if *iteration.Id == *args.Id {
+			iteration := iteration
+			return &iteration, nil
+		}
+	}
+	return nil, adoStatusError(404, "iteration not found")
+}
+
+func (f *fakeWorkClient) GetIterationWorkItems(ctx context.Context, args work.GetIterationWorkItemsArgs) (*work.IterationWorkItems, error) {
+	f.record("GetIterationWorkItems")
+	relations := f.relations[*args.IterationId]
+	return &work.IterationWorkItems{WorkItemRelations: &relations}, nil
+}
+
+func (f *fakeWorkClient) GetCapacitiesWithIdentityRefAndTotals(ctx context.Context, args work.GetCapacitiesWithIdentityRefAndTotalsArgs) (*work.TeamCapacity, error) {
+	f.record("GetCapacitiesWithIdentityRefAndTotals")
+	if capacity, ok := f.capacities[*args.IterationId]; ok {
+		return capacity, nil
+	}
+	return &work.TeamCapacity{}, nil
+}
+
+func (f *fakeWorkClient) GetTeamDaysOff(ctx context.Context, args work.GetTeamDaysOffArgs) (*work.TeamSettingsDaysOff, error) {
+	f.record("GetTeamDaysOff")
+	daysOff := f.daysOff[*args.IterationId]
+	return &work.TeamSettingsDaysOff{DaysOff: &daysOff}, nil
+}
+
+func (f *fakeWorkClient) GetTeamSettings(ctx context.Context, args work.GetTeamSettingsArgs) (*work.TeamSetting, error) {
+	f.record("GetTeamSettings")
+	return &work.TeamSetting{}, nil
+}
+
+// Backlog do time na ordem de backlog; vazio, fetchBacklogRanks devolve um mapa vazio
+func (f *fakeWorkClient) GetBacklogLevelWorkItems(ctx context.Context, args work.GetBacklogLevelWorkItemsArgs) (*work.BacklogLevelWorkItems, error) {
+	f.record("GetBacklogLevelWorkItems")
+	links := make([]workitemtracking.WorkItemLink, 0, len(f.backlog))
+	for _, id := range f.backlog {
+		links = append(links, fakeLink(0, id))
+	}
+	return &work.BacklogLevelWorkItems{WorkItems: &links}, nil
+}
+
+// Função para montar uma iteração do fake com datas (meia-noite UTC) e timeframe
+func fakeIteration(name string, start, end time.Time, timeframe work.TimeFrame) work.TeamSettingsIteration {
+	id := uuid.New()
+	path := `Projeto\` + name
+	attributes := &work.TeamIterationAttributes{
+		StartDate:  &azuredevops.Time{Time: start},
+		FinishDate: &azuredevops.Time{Time: end},
+	}
+	if timeframe != "" {
+		attributes.TimeFrame = &timeframe
+	}
+	return work.TeamSettingsIteration{Id: &id, Name: &name, Path: &path, Attributes: attributes}
+}
+
+// Função para montar a relação de um work item da iteração; source zero é item raiz
+func fakeLink(source, target int) workitemtracking.WorkItemLink {
+	link := workitemtracking.WorkItemLink{Target: &workitemtracking.WorkItemReference{Id: &target}}
+	if source != 0 {
+		link.Source = &workitemtracking.WorkItemReference{Id: &source}
+	}
+	return link
+}
+
+// Função para montar um pool que entrega os clientes falsos, passando pelo
+// mesmo semáforo e pelas mesmas estatísticas dos clientes reais
+func newFakePool(workClient work.Client, witClient workitemtracking.Client) *adoPool {
+	pool := newAdoPool(nil, 4)
+	pool.work = workClient
+	pool.wit = witClient
+	return pool
+}
+
+// Função para montar o valor de System.AssignedTo como o ADO devolve
+func fakeIdentity(displayName, uniqueName string) map[string]interface{} {
+	return map[string]interface{}{"displayName": displayName, "uniqueName": uniqueName}
+}
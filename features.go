@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tipo de work item agrupador em GET /features
+const featureType = "Feature"
+
+// Título do grupo de User Stories sem Feature pai
+const noFeatureTitle = "(sem feature)"
+
+// Feature de GET /features com o consolidado das User Stories dela na sprint.
+// O grupo das User Stories sem Feature pai vem com id null.
+type FeatureRollup struct {
+	ID              *int           `json:"id"`
+	Title           string         `json:"title"`
+	State           string         `json:"state,omitempty"`
+	Url             string         `json:"url,omitempty"`
+	EarliestDueDate *time.Time     `json:"earliestDueDate"`
+	LatestDueDate   *time.Time     `json:"latestDueDate"`
+	StoryPoints     float64        `json:"storyPoints"`
+	Stories         int            `json:"stories"`
+	WithoutDueDate  int            `json:"withoutDueDate"`
+	ByState         map[string]int `json:"byState"`
+	StoryIds        []int          `json:"storyIds"`
+}
+
+// Resposta de GET /features
+type FeaturesReport struct {
+	Sprint   string          `json:"sprint"`
+	Features []FeatureRollup `json:"features"`
+	Warnings []string        `json:"warnings"`
+}
+
+// Função para acrescentar uma User Story ao consolidado da Feature
+func (f *FeatureRollup) add(story WorkItem) {
+	f.Stories++
+	f.ByState[story.State]++
+	f.StoryIds = append(f.StoryIds, story.ID)
+	if story.StoryPoints != nil {
+		f.StoryPoints += *story.StoryPoints
+	}
+	if story.DueDate == nil {
+		f.WithoutDueDate++
+		return
+	}
+	if f.EarliestDueDate == nil || story.DueDate.Before(*f.EarliestDueDate) {
+		f.EarliestDueDate = story.DueDate
+	}
+	if f.LatestDueDate == nil || story.DueDate.After(*f.LatestDueDate) {
+		f.LatestDueDate = story.DueDate
+	}
+}
+
+// GET /features: User Stories da sprint agrupadas pela Feature pai
+// (System.Parent), com a menor e a maior data de cada grupo
+func handleFeatures(pool *adoPool, cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ref, err := sprintRefFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		keepArea, err := areaPathFilterFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		workClient, err := pool.Work(ctx, "features")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
+			return
+		}
+		targetIteration, err := resolveSprintRef(ctx, workClient, cfg, ref)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		sprintName := *targetIteration.Name
+		setSprintHeaders(w, targetIteration)
+		witClient, err := pool.WorkItems(ctx, "features")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+
+		types, explicitTypes := typesFromRequest(cfg, r)
+		sprintStories, details, missing, err := fetchSprintStories(ctx, workClient, witClient, cfg, targetIteration, types, nil)
+		if err != nil {
+			respondError(w, "Erro ao buscar User Stories", err)
+			return
+		}
+		if explicitTypes {
+			if err := validateRequestedTypes(types, details); err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		var stories []WorkItem
+		var parentIds []int
+		seenParents := make(map[int]bool)
+		for _, story := range sprintStories {
+			if story.Removed || !keepArea(story.AreaPath) {
+				continue
+			}
+			stories = append(stories, story)
+			if story.parentID != 0 && !seenParents[story.parentID] {
+				seenParents[story.parentID] = true
+				parentIds = append(parentIds, story.parentID)
+			}
+		}
+
+		// Os pais de todas as User Stories numa busca em lotes; só os do tipo
+		// Feature viram grupos
+		report := FeaturesReport{Sprint: sprintName, Features: []FeatureRollup{}, Warnings: missingWorkItemWarnings(missing)}
+		features := make(map[int]*FeatureRollup)
+		if len(parentIds) > 0 {
+			parents, err := getWorkItemsChunked(ctx, witClient, cfg.Project, parentIds, []string{"System.Title", "System.WorkItemType", "System.State"})
+			if err != nil {
+				respondError(w, "Erro ao buscar Features", err)
+				return
+			}
+			for _, missingID := range missingWorkItemIds(parentIds, parents) {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("Pai #%d não encontrado (provavelmente excluído); suas User Stories ficam em '%s'", missingID, noFeatureTitle))
+			}
+			for _, parent := range presentWorkItems(parentIds, parents) {
+				if !strings.EqualFold(getFieldValue(parent.Fields, "System.WorkItemType"), featureType) {
+					continue
+				}
+				id := *parent.Id
+				features[id] = &FeatureRollup{
+					ID:      &id,
+					Title:   getFieldValue(parent.Fields, "System.Title"),
+					State:   getFieldValue(parent.Fields, "System.State"),
+					Url:     workItemURL(cfg, id),
+					ByState: map[string]int{},
+				}
+			}
+		}
+
+		noFeature := &FeatureRollup{Title: noFeatureTitle, ByState: map[string]int{}}
+		for _, story := range stories {
+			if feature, ok := features[story.parentID]; ok {
+				feature.add(story)
+			} else {
+				noFeature.add(story)
+			}
+		}
+		for _, feature := range features {
+			report.Features = append(report.Features, *feature)
+		}
+		// Pela maior data (quando a Feature fica pronta), sem data no fim
+		sort.Slice(report.Features, func(i, j int) bool {
+			a, b := report.Features[i], report.Features[j]
+			if (a.LatestDueDate == nil) != (b.LatestDueDate == nil) {
+				return a.LatestDueDate != nil
+			}
+			if a.LatestDueDate != nil && !a.LatestDueDate.Equal(*b.LatestDueDate) {
+				return a.LatestDueDate.Before(*b.LatestDueDate)
+			}
+			return *a.ID < *b.ID
+		})
+		if noFeature.Stories > 0 {
+			report.Features = append(report.Features, *noFeature)
+		}
+
+		log.Printf("[DEBUG] %d Features na sprint '%s' (%d User Stories sem Feature)", len(features), sprintName, noFeature.Stories)
+		writeJSON(w, http.StatusOK, report)
+	}
+}
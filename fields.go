@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+func getFieldValue(fields *map[string]interface{}, fieldName string) string {
+	if fields == nil {
+		return ""
+	}
+	if value, ok := (*fields)[fieldName]; ok {
+		// Log para debug
+		log.Printf("Campo %s encontrado com tipo %T e valor %v", fieldName, value, value)
+
+		switch v := value.(type) {
+		case string:
+			return v
+		case azuredevops.Time, *azuredevops.Time, time.Time:
+			// O SDK às vezes devolve datas já convertidas; %v não seria legível por parseDate
+			if t, ok := fieldTimeValue(v); ok {
+				return t.Format(time.RFC3339Nano)
+			}
+		case map[string]interface{}:
+			// Para campos complexos, tenta obter o displayName ou value
+			if displayName, ok := v["displayName"].(string); ok {
+				return displayName
+			}
+			if val, ok := v["value"].(string); ok {
+				return val
+			}
+		}
+		// Se não conseguir converter, converte para string
+		return fmt.Sprintf("%v", value)
+	}
+	return ""
+}
+
+// Função para ler um campo de identidade (System.AssignedTo e afins). O ADO
+// devolve um mapa com displayName, uniqueName e imageUrl; campos vazios
+// retornam nil. Valores em texto (identidades antigas) viram só o nome.
+func getFieldIdentity(fields *map[string]interface{}, fieldName string) *Identity {
+	if fields == nil {
+		return nil
+	}
+	switch v := (*fields)[fieldName].(type) {
+	case map[string]interface{}:
+		identity := &Identity{}
+		identity.DisplayName, _ = v["displayName"].(string)
+		identity.UniqueName, _ = v["uniqueName"].(string)
+		identity.ImageUrl, _ = v["imageUrl"].(string)
+		identity.Descriptor, _ = v["descriptor"].(string)
+		if identity.ImageUrl == "" {
+			// Versões mais novas da API só trazem o avatar em _links
+			if links, ok := v["_links"].(map[string]interface{}); ok {
+				if avatar, ok := links["avatar"].(map[string]interface{}); ok {
+					identity.ImageUrl, _ = avatar["href"].(string)
+				}
+			}
+		}
+		if identity.DisplayName == "" && identity.UniqueName == "" && identity.Descriptor == "" {
+			return nil
+		}
+		return identity
+	case string:
+		if v == "" {
+			return nil
+		}
+		return &Identity{DisplayName: v}
+	}
+	return nil
+}
+
+// Função para gerar a chave que identifica uma pessoa: o uniqueName (sem
+// diferenciar maiúsculas) e, na falta dele, o descriptor ou o nome de exibição
+func identityKey(uniqueName, descriptor, displayName string) string {
+	if uniqueName != "" {
+		return strings.ToLower(uniqueName)
+	}
+	if descriptor != "" {
+		return descriptor
+	}
+	return displayName
+}
+
+// Função para escolher o "e-mail" de uma identidade: o uniqueName e, sem ele,
+// o descriptor. O booleano indica se o valor é um endereço de e-mail de fato
+// (grupos têm uniqueName como "[Projeto]\Time").
+func identityEmail(uniqueName, descriptor string) (string, bool) {
+	if uniqueName == "" {
+		return descriptor, false
+	}
+	_, err := mail.ParseAddress(uniqueName)
+	return uniqueName, err == nil
+}
+
+// Função para ler um campo preservando o tipo quando faz sentido: números e
+// booleanos passam direto, datas viram RFC3339 e identidades viram o nome de
+// exibição, como em getFieldValue. Campos ausentes retornam nil.
+func getFieldTyped(fields *map[string]interface{}, fieldName string) interface{} {
+	if fields == nil {
+		return nil
+	}
+	value, ok := (*fields)[fieldName]
+	if !ok || value == nil {
+		return nil
+	}
+
+	switch v := value.(type) {
+	case float64, bool:
+		return v
+	case azuredevops.Time, *azuredevops.Time, time.Time:
+		return getFieldValue(fields, fieldName)
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t.Format(time.RFC3339)
+		}
+		return v
+	case map[string]interface{}:
+		return getFieldValue(fields, fieldName)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// Função para obter o time.Time de um valor de data já tipado pelo SDK
+// (azuredevops.Time, *azuredevops.Time ou time.Time)
+func fieldTimeValue(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case azuredevops.Time:
+		return v.Time, true
+	case *azuredevops.Time:
+		if v != nil {
+			return v.Time, true
+		}
+	case time.Time:
+		return v, true
+	}
+	return time.Time{}, false
+}
+
+// Função para ler um campo de data, em texto (convertido por parseDate) ou
+// já tipado pelo SDK. Campos vazios ou ilegíveis retornam nil.
+func getFieldTime(fields *map[string]interface{}, fieldName string) *time.Time {
+	value := getFieldValue(fields, fieldName)
+	if value == "" {
+		return nil
+	}
+	parsed, err := parseDate(value)
+	if err != nil {
+		log.Printf("[WARN] Data ilegível em %s (%q): %v", fieldName, value, err)
+		return nil
+	}
+	return &parsed
+}
+
+// Função para ler um campo numérico (horas, pontos). O ADO devolve números
+// como float64 no mapa de campos; campos vazios ou não numéricos retornam nil.
+func getFieldFloat(fields *map[string]interface{}, fieldName string) *float64 {
+	if fields == nil {
+		return nil
+	}
+	switch v := (*fields)[fieldName].(type) {
+	case float64:
+		return &v
+	case int:
+		value := float64(v)
+		return &value
+	case string:
+		if value, err := strconv.ParseFloat(v, 64); err == nil {
+			return &value
+		}
+	}
+	return nil
+}
+
+// Função para ler as tags de um work item. O Azure DevOps guarda System.Tags
+// como uma única string separada por ponto e vírgula ("tag1; tag2").
+func workItemTags(fields *map[string]interface{}) []string {
+	if fields == nil {
+		return nil
+	}
+	value, _ := (*fields)["System.Tags"].(string)
+	return splitTags(value)
+}
+
+// Função para separar uma lista de tags por ponto e vírgula ou vírgula,
+// ignorando espaços e itens vazios
+func splitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ';' || r == ',' }) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Função para encontrar, sem diferenciar maiúsculas, a primeira tag de
+// wanted presente em tags
+func matchTag(tags, wanted []string) (string, bool) {
+	for _, tag := range tags {
+		for _, candidate := range wanted {
+			if strings.EqualFold(tag, candidate) {
+				return tag, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Função para verificar se um work item está bloqueado: campo
+// Microsoft.VSTS.CMMI.Blocked igual a Yes (processos Scrum e CMMI) ou a tag
+// configurada em BLOCKED_TAG; tag vazia considera só o campo
+func isBlocked(fields *map[string]interface{}, tags []string, blockedTag string) bool {
+	if strings.EqualFold(getFieldValue(fields, "Microsoft.VSTS.CMMI.Blocked"), "Yes") {
+		return true
+	}
+	if blockedTag == "" {
+		return false
+	}
+	_, found := matchTag(tags, []string{blockedTag})
+	return found
+}
+
+// Função para montar o mapa de campos extras configurados em EXTRA_FIELDS
+func extraFieldValues(fields *map[string]interface{}, extraFields []string) map[string]interface{} {
+	if len(extraFields) == 0 {
+		return nil
+	}
+	values := make(map[string]interface{}, len(extraFields))
+	for _, name := range extraFields {
+		values[name] = getFieldTyped(fields, name)
+	}
+	return values
+}
+
+// Função para acrescentar os campos extras a uma lista de campos do GetWorkItems
+func withExtraFields(fields []string, extraFields []string) []string {
+	if len(extraFields) == 0 {
+		return fields
+	}
+	combined := make([]string, 0, len(fields)+len(extraFields))
+	combined = append(combined, fields...)
+	for _, name := range extraFields {
+		duplicate := false
+		for _, field := range fields {
+			if strings.EqualFold(field, name) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			combined = append(combined, name)
+		}
+	}
+	return combined
+}
+
+// Função para validar EXTRA_FIELDS contra os campos do projeto.
+// Nomes desconhecidos são avisados e descartados, já que o Azure DevOps
+// rejeita o GetWorkItems inteiro quando um campo não existe.
+func validateExtraFields(ctx context.Context, pool *adoPool, cfg *Config) {
+	if len(cfg.ExtraFields) == 0 {
+		return
+	}
+
+	witClient, err := pool.WorkItems(ctx, "validate-extra-fields")
+	if err != nil {
+		log.Printf("[WARN] Não foi possível validar EXTRA_FIELDS: %v", err)
+		return
+	}
+	fields, err := witClient.GetWorkItemFields(ctx, workitemtracking.GetWorkItemFieldsArgs{
+		Project: &cfg.Project,
+	})
+	if err != nil {
+		log.Printf("[WARN] Não foi possível validar EXTRA_FIELDS: %v", wrapAdoError(err, "GetWorkItemFields", "project=%s", cfg.Project))
+		return
+	}
+
+	known := make(map[string]string)
+	if fields != nil {
+		for _, field := range *fields {
+			if field.ReferenceName != nil {
+				known[strings.ToLower(*field.ReferenceName)] = *field.ReferenceName
+			}
+		}
+	}
+
+	valid := make([]string, 0, len(cfg.ExtraFields))
+	for _, name := range cfg.ExtraFields {
+		referenceName, ok := known[strings.ToLower(name)]
+		if !ok {
+			log.Printf("[WARN] Campo extra '%s' não existe no projeto %s e será ignorado", name, cfg.Project)
+			continue
+		}
+		valid = append(valid, referenceName)
+	}
+	cfg.ExtraFields = valid
+}
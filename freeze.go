@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// sprintFreeze é a zona congelada do fim da sprint (FREEZE_DAYS_BEFORE_END):
+// nos últimos dias úteis só as atividades isentas (FREEZE_EXEMPT_ACTIVITIES)
+// recebem trabalho e datas
+type sprintFreeze struct {
+	// Primeiro dia congelado; zero sem congelamento
+	Start time.Time
+	// Último dia útil antes do congelamento, data de quem não cabe antes
+	// dele; zero quando a sprint inteira fica congelada
+	LastOpen time.Time
+	Exempt   []string
+}
+
+// Função para montar o congelamento de uma sprint: os últimos
+// FREEZE_DAYS_BEFORE_END dias úteis do time (fora feriados e folgas do time)
+func newSprintFreeze(cfg *Config, start, end time.Time, teamDaysOff []DayOff, cal workCalendar) (sprintFreeze, error) {
+	freeze := sprintFreeze{Exempt: cfg.FreezeExemptActivities}
+	if cfg.FreezeDaysBeforeEnd <= 0 {
+		return freeze, nil
+	}
+	days, err := workingDates(start, end, teamDaysOff, cal)
+	if err != nil || len(days) == 0 {
+		return freeze, err
+	}
+	first := len(days) - cfg.FreezeDaysBeforeEnd
+	if first < 0 {
+		first = 0
+	}
+	freeze.Start = days[first]
+	if first > 0 {
+		freeze.LastOpen = days[first-1]
+	}
+	return freeze, nil
+}
+
+// Função para verificar se há congelamento na sprint
+func (f sprintFreeze) enabled() bool {
+	return !f.Start.IsZero()
+}
+
+// Função para verificar se um dia (meia-noite UTC) está na zona congelada
+func (f sprintFreeze) frozen(day time.Time) bool {
+	return f.enabled() && !day.Before(f.Start)
+}
+
+// Função para verificar se uma atividade pode usar os dias congelados (sem
+// diferenciar maiúsculas); sem atividade nunca é isenta
+func (f sprintFreeze) exempt(activity string) bool {
+	for _, name := range f.Exempt {
+		if strings.EqualFold(name, activity) {
+			return true
+		}
+	}
+	return false
+}
+
+// Função para obter o limite do trabalho de uma atividade: o início do
+// congelamento, ou zero (sem limite) para as isentas e sem congelamento
+func (f sprintFreeze) limit(activity string) time.Time {
+	if !f.enabled() || f.exempt(activity) {
+		return time.Time{}
+	}
+	return f.Start
+}
+
+// Item com data na zona congelada sem atividade isenta. Kind é story (User
+// Story, que nunca é isenta) ou task; ParentID só vem em tasks.
+type FreezeViolation struct {
+	ID         int       `json:"id"`
+	Kind       string    `json:"kind"`
+	Title      string    `json:"title,omitempty"`
+	ParentID   int       `json:"parentId,omitempty"`
+	Activity   string    `json:"activity,omitempty"`
+	AssignedTo string    `json:"assignedTo,omitempty"`
+	DueDate    time.Time `json:"dueDate"`
+}
+
+// Resposta de GET /validate
+type FreezeValidation struct {
+	Sprint                 string            `json:"sprint"`
+	FreezeStart            *time.Time        `json:"freezeStart"`
+	FreezeExemptActivities []string          `json:"freezeExemptActivities,omitempty"`
+	Checked                int               `json:"checked"`
+	Violations             []FreezeViolation `json:"violations"`
+	Warnings               []string          `json:"warnings,omitempty"`
+}
+
+// Handler de GET /validate?sprint=: confere as datas já gravadas nas User
+// Stories abertas e nas tasks abertas delas contra o congelamento do fim da
+// sprint. User Stories e tasks de atividades fora de FREEZE_EXEMPT_ACTIVITIES
+// com data na zona congelada voltam em violations.
+func handleFreezeValidate(pool *adoPool, cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ref, err := sprintRefFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cal, err := calendarForRequest(cfg, r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		workClient, err := pool.Work(ctx, "validate")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
+			return
+		}
+		targetIteration, err := resolveSprintRef(ctx, workClient, cfg, ref)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		sprintName := *targetIteration.Name
+		setSprintHeaders(w, targetIteration)
+		sprintStart, sprintEnd, err := sprintDates(targetIteration)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		teamDaysOff, err := fetchTeamDaysOff(ctx, workClient, cfg, targetIteration)
+		if err != nil {
+			respondError(w, "Erro ao buscar folgas do time", err)
+			return
+		}
+		freeze, err := newSprintFreeze(cfg, sprintStart, sprintEnd, teamDaysOff, cal)
+		if err != nil {
+			respondError(w, fmt.Sprintf("Datas inválidas na sprint '%s'", sprintName), err)
+			return
+		}
+
+		response := FreezeValidation{Sprint: sprintName, Violations: []FreezeViolation{}}
+		if !freeze.enabled() {
+			response.Warnings = append(response.Warnings, "Sem congelamento configurado (FREEZE_DAYS_BEFORE_END); nada a conferir")
+			writeJSON(w, http.StatusOK, response)
+			return
+		}
+		response.FreezeStart, response.FreezeExemptActivities = &freeze.Start, freeze.Exempt
+
+		witClient, err := pool.WorkItems(ctx, "validate")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+		types, _ := typesFromRequest(cfg, r)
+		sprintStories, _, missing, err := fetchSprintStories(ctx, workClient, witClient, cfg, targetIteration, types, nil)
+		if err != nil {
+			respondError(w, "Erro ao buscar User Stories", err)
+			return
+		}
+		response.Warnings = missingWorkItemWarnings(missing)
+
+		var storyIds []int
+		for _, story := range sprintStories {
+			if !isOpenStory(story) {
+				continue
+			}
+			storyIds = append(storyIds, story.ID)
+			if story.DueDate == nil {
+				continue
+			}
+			response.Checked++
+			if freeze.frozen(dueDateDay(*story.DueDate, cal)) {
+				violation := FreezeViolation{ID: story.ID, Kind: "story", Title: story.Title, DueDate: *story.DueDate}
+				if story.AssignedTo != nil {
+					violation.AssignedTo = story.AssignedTo.DisplayName
+				}
+				response.Violations = append(response.Violations, violation)
+			}
+		}
+
+		tasks, err := storyRollupTasks(ctx, witClient, cfg, storyIds)
+		if err != nil {
+			respondError(w, "Erro ao buscar tasks das User Stories", err)
+			return
+		}
+		for _, parent := range storyIds {
+			for _, task := range tasks[parent] {
+				if task.DueDate == nil {
+					continue
+				}
+				response.Checked++
+				if freeze.exempt(task.Activity) || !freeze.frozen(dueDateDay(*task.DueDate, cal)) {
+					continue
+				}
+				violation := FreezeViolation{ID: task.ID, Kind: "task", ParentID: parent, Activity: task.Activity, DueDate: *task.DueDate}
+				if task.AssignedTo != nil {
+					violation.AssignedTo = task.AssignedTo.DisplayName
+				}
+				response.Violations = append(response.Violations, violation)
+			}
+		}
+
+		log.Printf("[DEBUG] %d de %d datas na zona congelada da sprint '%s' (a partir de %s)",
+			len(response.Violations), response.Checked, sprintName, freeze.Start.Format("2006-01-02"))
+		writeJSON(w, http.StatusOK, response)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+// Sprint de 04/03/2024 a 15/03/2024 com 8h/dia para quem aparecer e os dois
+// últimos dias úteis (14 e 15/03) congelados, fora Testing
+func freezeSprint() capacityPlanInput {
+	cal := workCalendar{WeekendFactor: 1, Location: time.UTC}
+	freeze, err := newSprintFreeze(&Config{FreezeDaysBeforeEnd: 2, FreezeExemptActivities: []string{"Testing"}}, day(2024, 3, 4), day(2024, 3, 15), nil, cal)
+	if err != nil {
+		panic(err)
+	}
+	return capacityPlanInput{
+		Start:         day(2024, 3, 4),
+		End:           day(2024, 3, 15),
+		Capacities:    map[string]TeamMemberCapacity{},
+		DefaultPerDay: 8,
+		Calendar:      cal,
+		Freeze:        freeze,
+	}
+}
+
+func TestNewSprintFreeze(t *testing.T) {
+	cal := workCalendar{WeekendFactor: 1, Location: time.UTC}
+	// Folga do time na sexta 15/03: o congelamento recua para 13 e 14/03
+	teamDaysOff := []DayOff{{Start: day(2024, 3, 15), End: day(2024, 3, 15)}}
+	tests := []struct {
+		name         string
+		days         int
+		wantStart    time.Time
+		wantLastOpen time.Time
+	}{
+		{name: "desativado", days: 0},
+		{name: "dois dias", days: 2, wantStart: day(2024, 3, 13), wantLastOpen: day(2024, 3, 12)},
+		{name: "sprint inteira", days: 30, wantStart: day(2024, 3, 4)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			freeze, err := newSprintFreeze(&Config{FreezeDaysBeforeEnd: tt.days}, day(2024, 3, 4), day(2024, 3, 15), teamDaysOff, cal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !freeze.Start.Equal(tt.wantStart) || !freeze.LastOpen.Equal(tt.wantLastOpen) {
+				t.Errorf("congelamento de %s (último aberto %s), quer %s (%s)", freeze.Start.Format("2006-01-02"), freeze.LastOpen.Format("2006-01-02"),
+					tt.wantStart.Format("2006-01-02"), tt.wantLastOpen.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func TestPlanCapacityFreeze(t *testing.T) {
+	ana := &Identity{DisplayName: "Ana", UniqueName: "ana@example.com"}
+	stories := []WorkItem{{ID: 1, AssignedTo: ana}, {ID: 2, AssignedTo: ana}, {ID: 3, AssignedTo: ana}}
+	taskWork := map[int]float64{1: 40, 2: 32, 3: 40}
+	tests := []struct {
+		name       string
+		freeze     bool
+		wantDates  []time.Time
+		wantAtRisk []bool
+		wantFrozen []bool
+	}{
+		{
+			name:       "sem congelamento",
+			wantDates:  []time.Time{day(2024, 3, 8), day(2024, 3, 14), day(2024, 3, 15)},
+			wantAtRisk: []bool{false, false, true},
+			wantFrozen: []bool{false, false, false},
+		},
+		{
+			// A segunda caberia no dia 14, congelado: fica no dia 13 marcada
+			// pelo congelamento. A terceira não caberia nem com os dias
+			// congelados e segue como falta de capacidade.
+			name:       "com congelamento",
+			freeze:     true,
+			wantDates:  []time.Time{day(2024, 3, 8), day(2024, 3, 13), day(2024, 3, 15)},
+			wantAtRisk: []bool{false, true, true},
+			wantFrozen: []bool{false, true, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := freezeSprint()
+			if !tt.freeze {
+				input.Freeze = sprintFreeze{}
+			}
+			input.TaskWork = taskWork
+			plans, _, err := planCapacity(stories, input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, plan := range plans {
+				if !plan.DueDate.Equal(tt.wantDates[i]) || plan.AtRisk != tt.wantAtRisk[i] || plan.FrozenOut != tt.wantFrozen[i] {
+					t.Errorf("#%d = %s, atRisk %t, congelamento %t; quer %s, %t, %t", plan.Story.ID, plan.DueDate.Format("2006-01-02"), plan.AtRisk, plan.FrozenOut,
+						tt.wantDates[i].Format("2006-01-02"), tt.wantAtRisk[i], tt.wantFrozen[i])
+				}
+			}
+			if tt.freeze && len(plans[1].Explanation) != 1 {
+				t.Errorf("explicação de #2 = %v, quer o congelamento", plans[1].Explanation)
+			}
+		})
+	}
+}
+
+func TestPlanRollupFreezeExemptActivity(t *testing.T) {
+	ana := &Identity{DisplayName: "Ana", UniqueName: "ana@example.com"}
+	hours := func(value float64) *float64 { return &value }
+	stories := []WorkItem{{ID: 1}, {ID: 2}}
+	tasks := map[int][]rollupTask{
+		// Desenvolvimento até 12/03 e testes usando os dias congelados
+		1: {
+			{ID: 10, AssignedTo: ana, Activity: "Development", RemainingWork: hours(56)},
+			{ID: 11, AssignedTo: ana, Activity: "testing", RemainingWork: hours(16)},
+		},
+		// Desenvolvimento que só caberia no dia 15, congelado
+		2: {{ID: 20, AssignedTo: ana, Activity: "Development", RemainingWork: hours(8)}},
+	}
+	plans, _, err := planRollup(stories, tasks, freezeSprint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan := plans[0]; !plan.DueDate.Equal(day(2024, 3, 14)) || plan.AtRisk || plan.FrozenOut {
+		t.Errorf("#1 = %s, atRisk %t; quer 2024-03-14 com os testes no congelamento", plan.DueDate.Format("2006-01-02"), plan.AtRisk)
+	}
+	if plan := plans[1]; !plan.DueDate.Equal(day(2024, 3, 13)) || !plan.AtRisk || !plan.FrozenOut || len(plan.Explanation) != 1 {
+		t.Errorf("#2 = %s, atRisk %t, congelamento %t, %v; quer 2024-03-13 em risco pelo congelamento", plan.DueDate.Format("2006-01-02"), plan.AtRisk, plan.FrozenOut, plan.Explanation)
+	}
+}
+
+// Sprint de 2030 com os dois últimos dias úteis congelados, fora Testing
+func freezeFixture() (*fakeWorkClient, *Config, work.TeamSettingsIteration) {
+	iteration := fakeIteration("Sprint 7", day(2030, 3, 4), day(2030, 3, 15), work.TimeFrameValues.Future)
+	workClient := &fakeWorkClient{iterations: []work.TeamSettingsIteration{iteration}}
+	cfg := &Config{Project: "Projeto", Team: "Time", TeamName: "Time", WorkItemTypes: []string{"User Story"}, ChildWorkItemTypes: []string{"Task"},
+		DefaultCapacityPerDay: 8, HoursPerStoryPoint: 8, FocusFactor: 1, DueDateWriteField: dueDateField, DueDateCommentTemplate: defaultDueDateCommentTemplate,
+		Location: time.UTC, FreezeDaysBeforeEnd: 2, FreezeExemptActivities: []string{"Testing"}}
+	return workClient, cfg, iteration
+}
+
+func TestGenerateDueDatesFreezeOverflow(t *testing.T) {
+	workClient, cfg, iteration := freezeFixture()
+	workClient.relations = map[uuid.UUID][]workitemtracking.WorkItemLink{*iteration.Id: {fakeLink(0, 1)}}
+	story := fakeStory(1, "História", "Active")
+	(*story.Fields)["System.AssignedTo"] = fakeIdentity("Ana Souza", "ana@example.com")
+	// 9 dias de 8h: caberia no dia 14, congelado
+	(*story.Fields)["Microsoft.VSTS.Scheduling.StoryPoints"] = 9.0
+	store, err := newRunStore("", runRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := handleGenerateDueDates(newFakePool(workClient, newFakeWitClient(story)), cfg, store, newIdempotencyStore(time.Hour), nil)
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodPost, "/generate-due-dates?sprint=Sprint%207&strategy=capacity&dryRun=true", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var report GenerationReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.FreezeStart == nil || !report.FreezeStart.Equal(day(2030, 3, 14)) {
+		t.Errorf("freezeStart = %v, quer 2030-03-14", report.FreezeStart)
+	}
+	item := report.Items[0]
+	if !item.AtRisk || item.ReasonCode != reasonFreezeOverflow || item.DueDate == nil || !item.DueDate.Equal(day(2030, 3, 13)) {
+		t.Errorf("#1 = %v, atRisk %t, %s; quer 2030-03-13 em risco com %s", item.DueDate, item.AtRisk, item.ReasonCode, reasonFreezeOverflow)
+	}
+}
+
+func TestSprintCalendarMarksFrozenDays(t *testing.T) {
+	workClient, cfg, iteration := freezeFixture()
+	cfg.Holidays = map[time.Time]Holiday{day(2030, 3, 6): {Date: day(2030, 3, 6), Name: "Cinzas"}}
+	// Folga do time na sexta 15/03: o congelamento fica em 13 e 14/03
+	workClient.daysOff = map[uuid.UUID][]work.DateRange{*iteration.Id: {{Start: &azuredevops.Time{Time: day(2030, 3, 15)}, End: &azuredevops.Time{Time: day(2030, 3, 15)}}}}
+	recorder := httptest.NewRecorder()
+	handleSprintCalendar(newFakePool(workClient, newFakeWitClient()), cfg)(recorder, httptest.NewRequest(http.MethodGet, "/calendar?sprint=Sprint%207", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var calendar SprintCalendar
+	if err := json.Unmarshal(recorder.Body.Bytes(), &calendar); err != nil {
+		t.Fatal(err)
+	}
+	if len(calendar.Days) != 12 || calendar.WorkingDays != 8 || calendar.FreezeStart == nil || !calendar.FreezeStart.Equal(day(2030, 3, 13)) {
+		t.Fatalf("%d dias, %d úteis, congelamento %v; quer 12, 8 e 2030-03-13", len(calendar.Days), calendar.WorkingDays, calendar.FreezeStart)
+	}
+	var frozen []time.Time
+	for _, d := range calendar.Days {
+		if d.Frozen {
+			frozen = append(frozen, d.Date)
+		}
+	}
+	if want := []time.Time{day(2030, 3, 13), day(2030, 3, 14), day(2030, 3, 15)}; !reflect.DeepEqual(frozen, want) {
+		t.Errorf("dias congelados = %v, quer %v", frozen, want)
+	}
+	if ash := calendar.Days[2]; ash.Working || ash.Holiday != "Cinzas" {
+		t.Errorf("06/03 = %+v, quer feriado", ash)
+	}
+	if last := calendar.Days[11]; last.Working || last.TeamDayOff != 1 {
+		t.Errorf("15/03 = %+v, quer folga do time", last)
+	}
+}
+
+func TestFreezeValidate(t *testing.T) {
+	workClient, cfg, iteration := freezeFixture()
+	workClient.relations = map[uuid.UUID][]workitemtracking.WorkItemLink{*iteration.Id: {fakeLink(0, 1), fakeLink(0, 2), fakeLink(0, 3)}}
+	dated := func(item workitemtracking.WorkItem, due time.Time) workitemtracking.WorkItem {
+		(*item.Fields)[dueDateField] = due.Format(time.RFC3339)
+		return item
+	}
+	task := func(id, parent int, activity string, due time.Time) workitemtracking.WorkItem {
+		return dated(fakeWorkItem(id, map[string]interface{}{"System.WorkItemType": "Task", "System.State": "Active", "System.Parent": float64(parent),
+			"Microsoft.VSTS.Common.Activity": activity}), due)
+	}
+	witClient := newFakeWitClient(
+		dated(fakeStory(1, "Na zona", "Active"), day(2030, 3, 14)),
+		dated(fakeStory(2, "Antes", "Active"), day(2030, 3, 13)),
+		// Fechada não é conferida
+		dated(fakeStory(3, "Entregue", "Closed"), day(2030, 3, 15)),
+		task(21, 2, "Development", day(2030, 3, 15)),
+		task(22, 2, "Testing", day(2030, 3, 15)),
+		task(23, 2, "Development", day(2030, 3, 12)),
+	)
+	witClient.queryByWiql = func(query string) []int { return []int{21, 22, 23} }
+	handler := handleFreezeValidate(newFakePool(workClient, witClient), cfg)
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodGet, "/validate?sprint=Sprint%207", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var validation FreezeValidation
+	if err := json.Unmarshal(recorder.Body.Bytes(), &validation); err != nil {
+		t.Fatal(err)
+	}
+	if validation.FreezeStart == nil || !validation.FreezeStart.Equal(day(2030, 3, 14)) || validation.Checked != 5 {
+		t.Fatalf("congelamento %v, %d conferidas; quer 2030-03-14 e 5", validation.FreezeStart, validation.Checked)
+	}
+	var got []int
+	for _, violation := range validation.Violations {
+		got = append(got, violation.ID)
+	}
+	if want := []int{1, 21}; !reflect.DeepEqual(got, want) {
+		t.Errorf("violações = %v, quer %v (a task de Testing é isenta)", got, want)
+	}
+	if task := validation.Violations[len(validation.Violations)-1]; task.Kind != "task" || task.ParentID != 2 || task.Activity != "Development" {
+		t.Errorf("violação da task = %+v", task)
+	}
+
+	// Sem congelamento configurado não há o que conferir
+	cfg.FreezeDaysBeforeEnd = 0
+	recorder = httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodGet, "/validate?sprint=Sprint%207", nil))
+	validation = FreezeValidation{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &validation); err != nil || validation.FreezeStart != nil || len(validation.Violations) != 0 || len(validation.Warnings) != 1 {
+		t.Errorf("sem congelamento = %+v (%v)", validation, err)
+	}
+}
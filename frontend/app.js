@@ -82,12 +82,21 @@ function getDueDateBadge(dueDate) {
 
 // Função para renderizar tasks de uma User Story
 function renderTasks(userStoryId) {
-    const tasks = tasksCache.get(userStoryId) || [];
+    const entry = tasksCache.get(userStoryId) || { tasks: [], warnings: [] };
+    if (entry.error) {
+        return `<div class="alert alert-danger mt-3">Erro ao carregar tasks: ${entry.error}</div>`;
+    }
+
+    const warningsHTML = (entry.warnings || []).map(warning => `
+        <div class="alert alert-warning mt-2 mb-0 py-1 small">${warning}</div>
+    `).join('');
+
+    const tasks = entry.tasks || [];
     if (tasks.length === 0) {
-        return '<div class="alert alert-info mt-3">Nenhuma task encontrada.</div>';
+        return warningsHTML + '<div class="alert alert-info mt-3">Nenhuma task encontrada.</div>';
     }
 
-    return tasks.map(task => `
+    return warningsHTML + tasks.map(task => `
         <div class="card task-card mt-2">
             <div class="card-body">
                 <div class="d-flex justify-content-between align-items-start">
@@ -125,11 +134,14 @@ async function loadTasks(userStoryId) {
             throw new Error(data.error || 'Erro ao carregar tasks');
         }
         
+        // Resposta no formato { parentId, parentTitle, tasks, warnings }
         tasksCache.set(userStoryId, data);
         return data;
     } catch (error) {
         console.error('Erro ao carregar tasks:', error);
-        return [];
+        const entry = { tasks: [], warnings: [], error: error.message };
+        tasksCache.set(userStoryId, entry);
+        return entry;
     }
 }
 
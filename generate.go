@@ -0,0 +1,1588 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+// Estratégias aceitas em ?strategy= de POST /generate-due-dates
+const (
+	strategyEven     = "even"
+	strategyCapacity = "capacity"
+	strategyRollup   = "rollup"
+)
+
+// Situação dos itens em uma simulação (?dryRun=true): a data seria gravada
+const dueDatePlanned = "planned"
+
+// Situação dos itens cuja data atual já é a calculada: nada é gravado no
+// work item (nem data, nem tag, nem comentário)
+const dueDateUnchanged = "unchanged"
+
+// Item do relatório de geração, na ordem usada no cálculo (prioridade, com
+// predecessores antes dos sucessores)
+type GenerationItem struct {
+	ID        int      `json:"id"`
+	Title     string   `json:"title"`
+	StackRank *float64 `json:"stackRank"`
+	// Posição no backlog do time (ordem manual do board); null fora dele
+	BacklogRank   *int     `json:"backlogRank"`
+	AssignedTo    string   `json:"assignedTo,omitempty"`
+	RemainingWork *float64 `json:"remainingWork,omitempty"`
+	AtRisk        bool     `json:"atRisk,omitempty"`
+	// User Story bloqueada; com ?blockedToEnd=true a data vai para o último dia útil
+	Blocked         bool       `json:"blocked,omitempty"`
+	Predecessors    []int      `json:"predecessors,omitempty"`
+	PreviousDueDate *time.Time `json:"previousDueDate"`
+	// Data que a User Story já tinha (DueDate ou TargetDate) e o campo de origem
+	ExistingDate      *time.Time `json:"existingDate,omitempty"`
+	ExistingDateField string     `json:"existingDateField,omitempty"`
+	RawDueDate        *time.Time `json:"rawDueDate,omitempty"`
+	DueDate           *time.Time `json:"dueDate"`
+	// Ajuste de overrides usado no cálculo (dueDate ou workingDays)
+	Override string `json:"override,omitempty"`
+	// Dia da semana de dueDate e se ele foi antecipado por ?spreadWithinWeek=true
+	DueDayOfWeek string `json:"dueDayOfWeek,omitempty"`
+	Spread       bool   `json:"spread,omitempty"`
+	// Regras do cálculo que mudaram a data (ordem das atividades, ...)
+	Explanation []string `json:"explanation,omitempty"`
+	GenerationOutcome
+	// Tasks da User Story com ?cascade=tasks
+	Tasks []GenerationTaskItem `json:"tasks,omitempty"`
+}
+
+// Task que recebe a data da User Story com ?cascade=tasks
+type GenerationTaskItem struct {
+	ID                int        `json:"id"`
+	Title             string     `json:"title"`
+	PreviousDueDate   *time.Time `json:"previousDueDate"`
+	ExistingDate      *time.Time `json:"existingDate,omitempty"`
+	ExistingDateField string     `json:"existingDateField,omitempty"`
+	DueDate           *time.Time `json:"dueDate"`
+	GenerationOutcome
+}
+
+// Resultado de um item da geração. Status e ReasonCode têm valores fixos
+// (constantes dueDate* e reason*); Reason é o texto para leitura.
+type GenerationOutcome struct {
+	Status     string `json:"status"`
+	ReasonCode string `json:"reasonCode,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	// Status HTTP devolvido pelo Azure DevOps quando a gravação falhou
+	AdoStatus int `json:"adoStatus,omitempty"`
+	Revision  int `json:"revision,omitempty"`
+	// Gravado na segunda tentativa, depois de reler o work item alterado por outra pessoa
+	Retried bool `json:"retried,omitempty"`
+	// Tag acrescentada (ou que seria, em dry-run) junto com a data
+	TagAdded string `json:"tagAdded,omitempty"`
+	// Comentário deixado no work item com ?comment=true
+	Commented bool `json:"commented,omitempty"`
+}
+
+// Códigos de motivo do relatório de geração
+const (
+	reasonNoAssignee             = "no-assignee"
+	reasonNoEstimate             = "no-estimate"
+	reasonNoTasks                = "no-tasks"
+	reasonNoDatableTasks         = "no-datable-tasks"
+	reasonUnchanged              = "unchanged"
+	reasonExistingDate           = "existing-date"
+	reasonOverwritten            = "overwritten"
+	reasonExceedsSprintEnd       = "exceeds-sprint-end"
+	reasonFreezeOverflow         = "freeze-overflow"
+	reasonExcludedTag            = "excluded-tag"
+	reasonExcludedAssignee       = "excluded-assignee"
+	reasonConcurrentModification = "concurrent-modification"
+	reasonAdoAuth                = "ado-auth"
+	reasonAdoNotFound            = "ado-not-found"
+	reasonAdoUnavailable         = "ado-unavailable"
+	reasonAdoError               = "ado-error"
+)
+
+// Função para saber se o responsável está em EXCLUDED_ASSIGNEES, pelo
+// e-mail ou pelo nome de exibição, sem diferenciar maiúsculas
+func excludedAssignee(identity *Identity, excluded []string) bool {
+	if identity == nil {
+		return false
+	}
+	for _, name := range excluded {
+		if strings.EqualFold(name, identity.UniqueName) || strings.EqualFold(name, identity.DisplayName) {
+			return true
+		}
+	}
+	return false
+}
+
+// Função para montar o resultado de uma gravação que falhou, com o código
+// pelo erro de domínio e o status HTTP do Azure DevOps quando houver
+func failedOutcome(err error, revision int) GenerationOutcome {
+	outcome := GenerationOutcome{Status: dueDateFailed, ReasonCode: reasonAdoError, Reason: err.Error(), Revision: revision}
+	switch {
+	case errors.Is(err, ErrConcurrentModification):
+		outcome.ReasonCode = reasonConcurrentModification
+	case errors.Is(err, ErrAdoAuth):
+		outcome.ReasonCode = reasonAdoAuth
+	case errors.Is(err, ErrWorkItemNotFound), errors.Is(err, ErrAdoNotFound):
+		outcome.ReasonCode = reasonAdoNotFound
+	case errors.Is(err, ErrAdoUnavailable):
+		outcome.ReasonCode = reasonAdoUnavailable
+	}
+	if statusCode := adoStatusCode(err); statusCode != 0 {
+		outcome.AdoStatus = statusCode
+		outcome.Reason = fmt.Sprintf("ADO %d: %s", statusCode, outcome.Reason)
+	}
+	return outcome
+}
+
+// Contagem de itens por situação
+type GenerationCounts struct {
+	Planned   int `json:"planned"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Skipped   int `json:"skipped"`
+	Failed    int `json:"failed"`
+}
+
+func (c *GenerationCounts) count(status string) {
+	switch status {
+	case dueDatePlanned:
+		c.Planned++
+	case dueDateUpdated:
+		c.Updated++
+	case dueDateUnchanged:
+		c.Unchanged++
+	case dueDateSkipped:
+		c.Skipped++
+	case dueDateFailed:
+		c.Failed++
+	}
+}
+
+// Valores de ?cascade=
+const cascadeTasks = "tasks"
+
+// Função para ler ?cascade= (vazio ou tasks)
+func cascadeFromQuery(value string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "":
+		return "", true
+	case cascadeTasks:
+		return cascadeTasks, true
+	}
+	return "", false
+}
+
+// dueDateWriter aplica a política de gravação de uma geração: pula datas já
+// corretas e datas existentes mantidas por ?overwrite=, espaça as escritas e
+// guarda os itens gravados para o registro da execução
+type dueDateWriter struct {
+	ctx       context.Context
+	witClient workitemtracking.Client
+	project   string
+	// Campo gravado (DUE_DATE_WRITE_FIELD)
+	field string
+	// Fuso (TIMEZONE) do dia de datas digitadas no Azure DevOps
+	location *time.Location
+	policy   string
+	dryRun   bool
+	wrote    bool
+	written  []DueDateRunItem
+	// Tag acrescentada a cada item gravado (GENERATED_TAG); vazia não marca
+	tag string
+	// Com ?comment=true, texto do comentário deixado em cada item gravado;
+	// nil não comenta. Falhas no comentário viram avisos.
+	commentText func(previous, newDate *time.Time) string
+	warnings    []string
+	// Recalcula uma User Story relida depois de uma alteração concorrente
+	// quando o responsável ou a estimativa mudaram; nil quando a estratégia
+	// não depende deles
+	recompute func(story WorkItem) generationPlan
+}
+
+// Campos relidos de um work item depois de uma alteração concorrente
+var rereadFields = append([]string{
+	"System.AssignedTo",
+	"System.Tags",
+	"Microsoft.VSTS.Scheduling.StoryPoints",
+	"Microsoft.VSTS.Scheduling.Effort",
+}, dueDateFields...)
+
+// Função para decidir e, fora do dry-run, gravar a data de um work item.
+// current é o DueDate atual; target.DueDate é a data existente pela cadeia
+// de campos. A gravação só vale se o item ainda estiver na revisão lida; em
+// conflito o item é relido, recalculado se o responsável ou a estimativa
+// mudaram, e gravado mais uma vez. Devolve o resultado e a data usada.
+func (g *dueDateWriter) apply(target WorkItem, current, newDate *time.Time) (GenerationOutcome, *time.Time) {
+	outcome := g.attempt(target, current, newDate)
+	if outcome.ReasonCode != reasonConcurrentModification || outcome.Status != dueDateFailed {
+		return outcome, newDate
+	}
+
+	log.Printf("[WARN] Work item #%d alterado durante a geração; relendo para tentar de novo", target.ID)
+	fresh, freshCurrent, err := g.reread(target)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		return failedOutcome(err, 0), newDate
+	}
+	if g.recompute != nil && !sameAssignment(target, fresh) {
+		plan := g.recompute(fresh)
+		if plan.DueDate == nil {
+			return GenerationOutcome{Status: dueDateSkipped, ReasonCode: plan.ReasonCode, Reason: plan.Reason, Retried: true}, nil
+		}
+		newDate = plan.DueDate
+	}
+	outcome = g.attempt(fresh, freshCurrent, newDate)
+	outcome.Retried = true
+	if outcome.ReasonCode == reasonConcurrentModification {
+		outcome.Reason = "alteração concorrente: o work item mudou de novo antes da gravação"
+	}
+	return outcome, newDate
+}
+
+// Função para reler o responsável, a estimativa, as datas e a revisão de um work item
+func (g *dueDateWriter) reread(target WorkItem) (WorkItem, *time.Time, error) {
+	fields := withExtraFields(rereadFields, []string{g.field})
+	workItem, err := g.witClient.GetWorkItem(g.ctx, workitemtracking.GetWorkItemArgs{
+		Id:      &target.ID,
+		Project: &g.project,
+		Fields:  &fields,
+	})
+	if err != nil {
+		return target, nil, wrapAdoError(err, "GetWorkItem", "id=%d", target.ID)
+	}
+	fresh := target
+	fresh.AssignedTo = getFieldIdentity(workItem.Fields, "System.AssignedTo")
+	fresh.StoryPoints = workItemStoryPoints(workItem.Fields)
+	fresh.DueDate, fresh.dueDateField = workItemDueDate(target.ID, workItem.Fields)
+	fresh.tags = workItemTags(workItem.Fields)
+	if workItem.Rev != nil {
+		fresh.revision = *workItem.Rev
+	}
+	current := currentFieldDate(workItem.Fields, g.field)
+	preferWrittenDate(&fresh, current, g.field)
+	return fresh, current, nil
+}
+
+// Função para usar como data existente a do campo gravado pela geração,
+// quando preenchido; vazio, vale a cadeia de dueDateFields
+func preferWrittenDate(item *WorkItem, current *time.Time, field string) {
+	if current != nil {
+		item.DueDate, item.dueDateField = current, field
+	}
+}
+
+// Função para comparar responsável e estimativa de duas leituras do mesmo work item
+func sameAssignment(a, b WorkItem) bool {
+	if (a.AssignedTo == nil) != (b.AssignedTo == nil) {
+		return false
+	}
+	if a.AssignedTo != nil && a.AssignedTo.UniqueName != b.AssignedTo.UniqueName {
+		return false
+	}
+	if (a.StoryPoints == nil) != (b.StoryPoints == nil) {
+		return false
+	}
+	return a.StoryPoints == nil || *a.StoryPoints == *b.StoryPoints
+}
+
+// Função para obter o dia de uma data lida de um work item. As gravadas pela
+// geração são dias (meia-noite UTC); uma data digitada no Azure DevOps guarda
+// a meia-noite do fuso de quem editou, então vale o dia dela no fuso configurado.
+func dueDay(t time.Time, loc *time.Location) time.Time {
+	day := sprintDate(t)
+	if !t.Equal(day) {
+		day = workCalendar{Location: loc}.dateOf(t)
+	}
+	return day
+}
+
+// Função para comparar a data atual de um item com a calculada, só pelo dia
+func sameDueDay(current, computed *time.Time, loc *time.Location) bool {
+	if current == nil || computed == nil {
+		return current == nil && computed == nil
+	}
+	return dueDay(*current, loc).Equal(sprintDate(*computed))
+}
+
+func (g *dueDateWriter) attempt(target WorkItem, current, newDate *time.Time) GenerationOutcome {
+	switch {
+	case sameDueDay(current, newDate, g.location):
+		return GenerationOutcome{Status: dueDateUnchanged, ReasonCode: reasonUnchanged, Reason: "data já está correta"}
+	case keepExistingDate(g.policy, target, *newDate):
+		return GenerationOutcome{Status: dueDateSkipped, ReasonCode: reasonExistingDate, Reason: existingDateReason(g.policy, target)}
+	}
+	var outcome GenerationOutcome
+	if target.DueDate != nil {
+		outcome.ReasonCode = reasonOverwritten
+		outcome.Reason = fmt.Sprintf("data existente substituída (overwrite=%s)", g.policy)
+	}
+	if _, tagged := matchTag(target.tags, []string{g.tag}); g.tag != "" && !tagged {
+		outcome.TagAdded = g.tag
+	}
+	if g.dryRun {
+		outcome.Status = dueDatePlanned
+		return outcome
+	}
+
+	g.pace()
+	document := append(revisionTest(target.revision), fieldDatePatch(g.field, newDate)...)
+	if outcome.TagAdded != "" {
+		document = append(document, tagsPatch(append(target.tags, outcome.TagAdded)))
+	}
+	update, err := patchDueDate(g.ctx, g.witClient, g.project, target.ID, g.field, document, current, newDate)
+	if err != nil {
+		log.Printf("[ERROR] %v", err)
+		return failedOutcome(err, update.Revision)
+	}
+	g.written = append(g.written, DueDateRunItem{ID: target.ID, PreviousDueDate: update.OldDueDate, NewDueDate: update.NewDueDate})
+	outcome.Status, outcome.Revision = dueDateUpdated, update.Revision
+	if g.commentText != nil {
+		outcome.Commented = g.addComment(target.ID, g.commentText(current, newDate))
+	}
+	return outcome
+}
+
+// Função para espaçar as escritas como em POST /due-dates
+func (g *dueDateWriter) pace() {
+	if g.wrote {
+		select {
+		case <-time.After(dueDateBatchInterval):
+		case <-g.ctx.Done():
+		}
+	}
+	g.wrote = true
+}
+
+// Função para comentar no work item a data gravada. A data já foi gravada,
+// então uma falha aqui só vira aviso no relatório.
+func (g *dueDateWriter) addComment(id int, text string) bool {
+	_, err := g.witClient.AddComment(g.ctx, workitemtracking.AddCommentArgs{
+		Request:    &workitemtracking.CommentCreate{Text: &text},
+		Project:    &g.project,
+		WorkItemId: &id,
+	})
+	if err != nil {
+		err = wrapAdoError(err, "AddComment", "id=%d", id)
+		log.Printf("[WARN] Data gravada, mas o comentário falhou: %v", err)
+		g.warnings = append(g.warnings, fmt.Sprintf("Work item #%d: data gravada, mas o comentário falhou: %v", id, err))
+		return false
+	}
+	return true
+}
+
+// Texto padrão do comentário de ?comment=true. Marcadores: {runId},
+// {strategy}, {sprint}, {previousDueDate} e {dueDate}.
+const defaultDueDateCommentTemplate = "Data de entrega definida como {dueDate} pela geração {runId} (estratégia {strategy}, sprint {sprint}). Valor anterior: {previousDueDate}."
+
+// Função para montar o comentário de uma data gravada pela geração. Os
+// valores são escapados porque o Azure DevOps trata comentários como HTML.
+func dueDateCommentText(template, runID, strategy, sprint string, previous, newDate *time.Time) string {
+	return strings.NewReplacer(
+		"{runId}", html.EscapeString(runID),
+		"{strategy}", html.EscapeString(strategy),
+		"{sprint}", html.EscapeString(sprint),
+		"{previousDueDate}", formatDueDate(previous),
+		"{dueDate}", formatDueDate(newDate),
+	).Replace(template)
+}
+
+// Versão do formato de GenerationReport; muda apenas quando um campo
+// existente é removido ou muda de significado
+const generationSchemaVersion = 1
+
+// Resposta de POST /generate-due-dates. Toda geração, inclusive dry-run,
+// recebe um RunID e fica registrada em /runs com este relatório.
+type GenerationReport struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	RunID         string `json:"runId"`
+	// Resposta guardada devolvida para um Idempotency-Key repetido
+	Replayed    bool      `json:"replayed,omitempty"`
+	StartedAt   time.Time `json:"startedAt"`
+	FinishedAt  time.Time `json:"finishedAt"`
+	Sprint      string    `json:"sprint"`
+	SprintID    string    `json:"sprintId"`
+	SprintStart time.Time `json:"sprintStart"`
+	SprintEnd   time.Time `json:"sprintEnd"`
+	// Time da geração e campo gravado (DUE_DATE_WRITE_FIELD)
+	Team         string `json:"team"`
+	DueDateField string `json:"dueDateField"`
+	Strategy     string `json:"strategy"`
+	// Unidade de remainingWork nos itens (GRANULARITY): hours ou days
+	Granularity string `json:"granularity,omitempty"`
+	DryRun      bool   `json:"dryRun"`
+	Overwrite   string `json:"overwrite"`
+	Cascade     string `json:"cascade,omitempty"`
+	// Tag acrescentada aos itens gravados e tags que excluem User Stories
+	Tag           string   `json:"tag,omitempty"`
+	ExcludeTags   []string `json:"excludeTags,omitempty"`
+	Comment       bool     `json:"comment,omitempty"`
+	AreaPath      string   `json:"areaPath,omitempty"`
+	AreaPathExact bool     `json:"areaPathExact,omitempty"`
+	BufferPercent float64  `json:"bufferPercent,omitempty"`
+	BufferDays    int      `json:"bufferDays,omitempty"`
+	WorkingDays   int      `json:"workingDays"`
+	GenerationCounts
+	AtRisk int `json:"atRisk"`
+	// User Stories bloqueadas e se as datas delas foram levadas ao fim da sprint
+	Blocked      int  `json:"blocked"`
+	BlockedToEnd bool `json:"blockedToEnd,omitempty"`
+	// Datas espalhadas na semana (?spreadWithinWeek=true) e distribuição das
+	// datas das User Stories por dia da semana
+	SpreadWithinWeek bool           `json:"spreadWithinWeek,omitempty"`
+	Weekdays         []WeekdayCount `json:"weekdays"`
+	// Ordem das atividades aplicada às tasks na estratégia rollup; vazia
+	// quando a regra está desligada
+	ActivityOrder []string `json:"activityOrder,omitempty"`
+	// User Stories sem ordem do backlog nem StackRank, calculadas por último
+	// na ordem do ID
+	OrderedByID []int `json:"orderedById,omitempty"`
+	// Primeiro dia congelado (FREEZE_DAYS_BEFORE_END) nas estratégias
+	// capacity e rollup; ausente sem congelamento
+	FreezeStart *time.Time `json:"freezeStart,omitempty"`
+	// Totais das tasks com ?cascade=tasks
+	Tasks *GenerationCounts `json:"tasks,omitempty"`
+	// Por que itens da sprint ficaram sem data; recolhido quando alguma
+	// User Story recebeu data
+	Diagnostics *GenerationDiagnostics `json:"diagnostics"`
+	// Pedido e aprovação com REQUIRE_APPROVAL; pendente, o relatório é o
+	// dry-run do que será gravado
+	Approval *RunApproval `json:"approval,omitempty"`
+	// Verificação das User Stories com DELIVERY_PLAN_TAG
+	DeliveryPlan *DeliveryPlanReport `json:"deliveryPlan,omitempty"`
+	Items        []GenerationItem    `json:"items"`
+	Warnings     []string            `json:"warnings"`
+}
+
+// Função para ler ?strategy= (padrão even)
+func strategyFromQuery(value string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", strategyEven:
+		return strategyEven, true
+	case strategyCapacity:
+		return strategyCapacity, true
+	case strategyRollup:
+		return strategyRollup, true
+	}
+	return "", false
+}
+
+// Políticas de ?overwrite= para User Stories que já têm data
+const (
+	overwriteNever  = "false"
+	overwriteAlways = "true"
+	overwriteOlder  = "older"
+)
+
+// Função para ler ?overwrite= (padrão false: não altera datas existentes)
+func overwriteFromQuery(value string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", overwriteNever:
+		return overwriteNever, true
+	case overwriteAlways:
+		return overwriteAlways, true
+	case overwriteOlder:
+		return overwriteOlder, true
+	}
+	return "", false
+}
+
+// Função para decidir se a data que a User Story já tem (DueDate, TargetDate
+// ...) deve ser mantida: sempre com overwrite=false, nunca com true e, com
+// older, quando ela não é anterior à calculada
+func keepExistingDate(policy string, story WorkItem, computed time.Time) bool {
+	if story.DueDate == nil {
+		return false
+	}
+	switch policy {
+	case overwriteAlways:
+		return false
+	case overwriteOlder:
+		return !sprintDate(*story.DueDate).Before(computed)
+	}
+	return true
+}
+
+// Função para descrever no relatório por que a data existente foi mantida
+func existingDateReason(policy string, story WorkItem) string {
+	existing := story.DueDate.Format("2006-01-02")
+	if policy == overwriteOlder {
+		return fmt.Sprintf("data existente (%s em %s) não é anterior à calculada", existing, story.dueDateField)
+	}
+	return fmt.Sprintf("já tem data (%s em %s); use overwrite=true para substituir", existing, story.dueDateField)
+}
+
+// Função para verificar se uma User Story ainda recebe data (nem fechada nem removida)
+func isOpenStory(item WorkItem) bool {
+	return !item.Removed && !strings.EqualFold(item.State, closedState)
+}
+
+// Função para buscar as tasks abertas (fora Closed e Removed) das User
+// Stories informadas, com os campos pedidos
+func fetchChildTasks(ctx context.Context, witClient workitemtracking.Client, cfg *Config, storyIds []int, fields []string) ([]workitemtracking.WorkItem, error) {
+	return queryChildTasks(ctx, witClient, cfg, storyIds, fields, false)
+}
+
+// Função para buscar as tasks (itens filhos dos tipos de
+// CHILD_WORK_ITEM_TYPES) das User Stories informadas, fora as removidas; sem
+// includeClosed, também fora as fechadas
+func queryChildTasks(ctx context.Context, witClient workitemtracking.Client, cfg *Config, storyIds []int, fields []string, includeClosed bool) ([]workitemtracking.WorkItem, error) {
+	query := newWiqlQuery("System.Id").
+		WhereInStrings("System.WorkItemType", cfg.childTypes()).
+		WhereInInts("System.Parent", storyIds).
+		Where("System.State", "<>", removedState)
+	if !includeClosed {
+		query = query.Where("System.State", "<>", closedState)
+	}
+	wiql, err := query.Build()
+	if err != nil {
+		return nil, err
+	}
+	request := workitemtracking.Wiql{Query: &wiql}
+	queryResults, err := witClient.QueryByWiql(ctx, workitemtracking.QueryByWiqlArgs{
+		Wiql:    &request,
+		Project: &cfg.Project,
+	})
+	if err != nil {
+		return nil, wrapAdoError(err, "QueryByWiql", "parents=%d", len(storyIds))
+	}
+	var taskIds []int
+	if queryResults != nil && queryResults.WorkItems != nil {
+		for _, item := range *queryResults.WorkItems {
+			if item.Id != nil {
+				taskIds = append(taskIds, *item.Id)
+			}
+		}
+	}
+	if len(taskIds) == 0 {
+		return nil, nil
+	}
+	tasks, err := getWorkItemsChunked(ctx, witClient, cfg.Project, taskIds, fields)
+	if err != nil {
+		return nil, err
+	}
+	return presentWorkItems(taskIds, tasks), nil
+}
+
+// Função para somar o RemainingWork das tasks abertas de cada User Story.
+// Só entram no mapa as User Stories com pelo menos uma task estimada.
+func storyTaskWork(ctx context.Context, witClient workitemtracking.Client, cfg *Config, storyIds []int) (map[int]float64, error) {
+	taskWork := make(map[int]float64)
+	if len(storyIds) == 0 {
+		return taskWork, nil
+	}
+	tasks, err := fetchChildTasks(ctx, witClient, cfg, storyIds, []string{"System.Parent", "Microsoft.VSTS.Scheduling.RemainingWork"})
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		parent := getFieldFloat(task.Fields, "System.Parent")
+		remaining := getFieldFloat(task.Fields, "Microsoft.VSTS.Scheduling.RemainingWork")
+		if parent == nil || remaining == nil {
+			continue
+		}
+		taskWork[int(*parent)] += *remaining
+	}
+	return taskWork, nil
+}
+
+// Função para ler a estimativa em dias (DAY_ESTIMATE_FIELD) de um work item,
+// arredondada para cima: cada task ocupa dias inteiros. nil sem estimativa.
+func dayEstimate(cfg *Config, fields *map[string]interface{}) *float64 {
+	value := getFieldFloat(fields, cfg.DayEstimateField)
+	if value == nil || *value < 0 {
+		return nil
+	}
+	days := math.Ceil(*value - capacityEpsilon)
+	return &days
+}
+
+// Função para somar a estimativa em dias das tasks abertas de cada User Story
+// com GRANULARITY=days, arredondando task a task. User Stories sem task
+// estimada usam o campo nelas mesmas; sem nenhum dos dois ficam fora do mapa.
+func storyDayWork(ctx context.Context, witClient workitemtracking.Client, cfg *Config, storyIds []int) (map[int]float64, error) {
+	dayWork := make(map[int]float64)
+	if len(storyIds) == 0 {
+		return dayWork, nil
+	}
+	tasks, err := fetchChildTasks(ctx, witClient, cfg, storyIds, []string{"System.Parent", cfg.DayEstimateField})
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		parent := getFieldFloat(task.Fields, "System.Parent")
+		days := dayEstimate(cfg, task.Fields)
+		if parent == nil || days == nil {
+			continue
+		}
+		dayWork[int(*parent)] += *days
+	}
+	var withoutTasks []int
+	for _, id := range storyIds {
+		if _, ok := dayWork[id]; !ok {
+			withoutTasks = append(withoutTasks, id)
+		}
+	}
+	if len(withoutTasks) == 0 {
+		return dayWork, nil
+	}
+	stories, err := getWorkItemsChunked(ctx, witClient, cfg.Project, withoutTasks, []string{cfg.DayEstimateField})
+	if err != nil {
+		return nil, err
+	}
+	for _, story := range presentWorkItems(withoutTasks, stories) {
+		if days := dayEstimate(cfg, story.Fields); days != nil {
+			dayWork[*story.Id] = *days
+		}
+	}
+	return dayWork, nil
+}
+
+// Função para buscar as tasks abertas de cada User Story com o que a
+// estratégia rollup usa: data existente, RemainingWork (ou a estimativa em
+// dias, com GRANULARITY=days) e responsável. As tasks de cada User Story vêm
+// pelo StackRank delas e, sem ele, pelo ID; entre User Stories vale a ordem
+// do backlog, em que a geração percorre as User Stories.
+func storyRollupTasks(ctx context.Context, witClient workitemtracking.Client, cfg *Config, storyIds []int) (map[int][]rollupTask, error) {
+	tasks := make(map[int][]rollupTask)
+	if len(storyIds) == 0 {
+		return tasks, nil
+	}
+	estimateField := "Microsoft.VSTS.Scheduling.RemainingWork"
+	if cfg.dayGranularity() {
+		estimateField = cfg.DayEstimateField
+	}
+	fields := withExtraFields(append([]string{"System.Parent", "System.AssignedTo", "Microsoft.VSTS.Common.Activity",
+		"Microsoft.VSTS.Common.StackRank", "Microsoft.VSTS.Common.BacklogPriority", estimateField}, dueDateFields...), []string{cfg.writeField()})
+	children, err := fetchChildTasks(ctx, witClient, cfg, storyIds, fields)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		parent := getFieldFloat(child.Fields, "System.Parent")
+		if parent == nil {
+			continue
+		}
+		task := rollupTask{
+			ID:            *child.Id,
+			AssignedTo:    getFieldIdentity(child.Fields, "System.AssignedTo"),
+			Activity:      getFieldValue(child.Fields, "Microsoft.VSTS.Common.Activity"),
+			RemainingWork: getFieldFloat(child.Fields, estimateField),
+			StackRank:     getFieldFloat(child.Fields, "Microsoft.VSTS.Common.StackRank"),
+		}
+		if task.StackRank == nil {
+			task.StackRank = getFieldFloat(child.Fields, "Microsoft.VSTS.Common.BacklogPriority")
+		}
+		// Em dias, o trabalho da task é a estimativa em dias inteiros
+		if cfg.dayGranularity() {
+			task.RemainingWork = dayEstimate(cfg, child.Fields)
+		}
+		task.DueDate, _ = workItemDueDate(task.ID, child.Fields)
+		if written := currentFieldDate(child.Fields, cfg.writeField()); written != nil {
+			task.DueDate = written
+		}
+		tasks[int(*parent)] = append(tasks[int(*parent)], task)
+	}
+	for _, list := range tasks {
+		sort.Slice(list, func(i, j int) bool {
+			a, b := list[i], list[j]
+			switch {
+			case (a.StackRank == nil) != (b.StackRank == nil):
+				return a.StackRank != nil
+			case a.StackRank != nil && *a.StackRank != *b.StackRank:
+				return *a.StackRank < *b.StackRank
+			}
+			return a.ID < b.ID
+		})
+	}
+	return tasks, nil
+}
+
+// Ajuste de uma User Story no corpo de POST /generate-due-dates; apenas um
+// dos campos deve ser informado
+type generationOverrideRequest struct {
+	DueDate     string `json:"dueDate"`
+	WorkingDays *int   `json:"workingDays"`
+}
+
+// Corpo opcional de POST /generate-due-dates e POST /simulate; adjustments
+// só é aceito na simulação
+type generationRequest struct {
+	Overrides   map[string]generationOverrideRequest `json:"overrides"`
+	Adjustments []CapacityAdjustment                 `json:"adjustments"`
+}
+
+// Função para ler o corpo da geração: os ajustes por User Story e os ajustes
+// de capacidade. Corpo vazio não tem ajustes.
+func parseGenerationBody(body io.Reader) (map[int]generationOverride, []CapacityAdjustment, error) {
+	var request generationRequest
+	if err := json.NewDecoder(body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, fmt.Errorf("Corpo inválido: %v", err)
+	}
+	overrides := make(map[int]generationOverride, len(request.Overrides))
+	for key, value := range request.Overrides {
+		id, err := strconv.Atoi(strings.TrimSpace(key))
+		if err != nil || id <= 0 {
+			return nil, nil, fmt.Errorf("ID de work item inválido em 'overrides': %q", key)
+		}
+		if (value.DueDate == "") == (value.WorkingDays == nil) {
+			return nil, nil, fmt.Errorf("overrides[%s]: informe 'dueDate' ou 'workingDays' (apenas um)", key)
+		}
+		if value.WorkingDays != nil {
+			if *value.WorkingDays < 1 || *value.WorkingDays > maxCalendarDays {
+				return nil, nil, fmt.Errorf("overrides[%s]: 'workingDays' deve estar entre 1 e %d", key, maxCalendarDays)
+			}
+			overrides[id] = generationOverride{WorkingDays: *value.WorkingDays}
+			continue
+		}
+		date, err := parseDueDate(value.DueDate)
+		if err != nil {
+			return nil, nil, fmt.Errorf("overrides[%s]: 'dueDate' inválido: %v", key, err)
+		}
+		overrides[id] = generationOverride{DueDate: &date}
+	}
+	return overrides, request.Adjustments, nil
+}
+
+// Handler de POST /generate-due-dates: calcula o DueDate das User Stories
+// abertas da sprint, em ordem de prioridade, e grava as datas (ou só as
+// devolve com ?dryRun=true). Itens que já estão com a data calculada são
+// ignorados; a execução pode ser desfeita com POST /runs/{id}/rollback.
+func handleGenerateDueDates(pool *adoPool, cfg *Config, runs *runStore, idempotency *idempotencyStore, events *notifierSet) http.HandlerFunc {
+	return generationHandler(pool, cfg, runs, idempotency, events, generationWrite)
+}
+
+// Função para montar o evento do fim de uma geração com escrita:
+// run.failed quando alguma gravação falhou, run.completed caso contrário
+func generationEvent(report GenerationReport, status int) Event {
+	event := Event{Type: EventRunCompleted, Time: report.FinishedAt, Sprint: report.Sprint, SprintID: report.SprintID, RunID: report.RunID}
+	summary := fmt.Sprintf("%d previstos, %d gravados, %d sem mudança, %d ignorados, %d falhas", report.Planned, report.Updated, report.Unchanged, report.Skipped, report.Failed)
+	if status != http.StatusOK {
+		event.Type = EventRunFailed
+		if report.Tasks != nil && report.Tasks.Failed > 0 {
+			summary += fmt.Sprintf(", %d tasks com falha", report.Tasks.Failed)
+		}
+		event.Text = fmt.Sprintf("Geração de datas na sprint '%s' terminou com falhas: %s (execução %s)", report.Sprint, summary, report.RunID)
+		return event
+	}
+	event.Text = fmt.Sprintf("Geração de datas na sprint '%s' concluída: %s (execução %s)", report.Sprint, summary, report.RunID)
+	return event
+}
+
+// Endpoints que compartilham o fluxo da geração
+type generationMode int
+
+const (
+	// POST /generate-due-dates
+	generationWrite generationMode = iota
+	// POST /simulate
+	generationSimulate
+	// GET /export/project-csv
+	generationExport
+)
+
+// Função com o fluxo da geração, compartilhado com POST /simulate e GET
+// /export/project-csv. Na simulação e na exportação a geração é sempre
+// dry-run e não usa idempotência, trava por sprint, o registro de execuções
+// nem notificações; só a simulação aceita ajustes de capacidade no corpo.
+func generationHandler(pool *adoPool, cfg *Config, runs *runStore, idempotency *idempotencyStore, events *notifierSet, mode generationMode) http.HandlerFunc {
+	simulate, export := mode == generationSimulate, mode == generationExport
+	return func(w http.ResponseWriter, r *http.Request) {
+		method := http.MethodPost
+		if export {
+			method = http.MethodGet
+		}
+		if r.Method != method {
+			jsonError(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		startedAt := time.Now().UTC()
+		ref, err := sprintRefFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cal, err := calendarForRequest(cfg, r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		strategyParam := r.URL.Query().Get("strategy")
+		// A simulação é sobre capacidade, então o padrão dela é capacity
+		if simulate && strategyParam == "" {
+			strategyParam = strategyCapacity
+		}
+		strategy, ok := strategyFromQuery(strategyParam)
+		if !ok {
+			jsonError(w, fmt.Sprintf("Parâmetro 'strategy' inválido: %q (use %s, %s ou %s)", r.URL.Query().Get("strategy"), strategyEven, strategyCapacity, strategyRollup), http.StatusBadRequest)
+			return
+		}
+		buffer, err := bufferFromQuery(r.URL.Query(), cfg.DueDateBuffer)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		roll, ok := rollDirectionFromQuery(r.URL.Query().Get("roll"))
+		if !ok {
+			jsonError(w, fmt.Sprintf("Parâmetro 'roll' inválido: %q (use %s ou %s)", r.URL.Query().Get("roll"), rollPrevious, rollNext), http.StatusBadRequest)
+			return
+		}
+		overwrite, ok := overwriteFromQuery(r.URL.Query().Get("overwrite"))
+		if !ok {
+			jsonError(w, fmt.Sprintf("Parâmetro 'overwrite' inválido: %q (use %s, %s ou %s)", r.URL.Query().Get("overwrite"), overwriteNever, overwriteAlways, overwriteOlder), http.StatusBadRequest)
+			return
+		}
+		cascade, ok := cascadeFromQuery(r.URL.Query().Get("cascade"))
+		if !ok {
+			jsonError(w, fmt.Sprintf("Parâmetro 'cascade' inválido: %q (use %s)", r.URL.Query().Get("cascade"), cascadeTasks), http.StatusBadRequest)
+			return
+		}
+		excludeTags := splitTags(r.URL.Query().Get("excludeTags"))
+		keepArea, err := areaPathFilterFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Já validado por areaPathFilterFromRequest
+		areaPathExact, _ := strconv.ParseBool(r.URL.Query().Get("areaPathExact"))
+		comment := false
+		if value := r.URL.Query().Get("comment"); value != "" {
+			comment, err = strconv.ParseBool(value)
+			if err != nil {
+				jsonError(w, fmt.Sprintf("Parâmetro 'comment' inválido: %q", value), http.StatusBadRequest)
+				return
+			}
+		}
+		dryRun := false
+		if value := r.URL.Query().Get("dryRun"); value != "" {
+			dryRun, err = strconv.ParseBool(value)
+			if err != nil {
+				jsonError(w, fmt.Sprintf("Parâmetro 'dryRun' inválido: %q", value), http.StatusBadRequest)
+				return
+			}
+		}
+		if simulate {
+			if r.URL.Query().Get("dryRun") != "" && !dryRun {
+				jsonError(w, "A simulação nunca grava no Azure DevOps; use POST /generate-due-dates", http.StatusBadRequest)
+				return
+			}
+			dryRun = true
+		}
+		if export {
+			if r.URL.Query().Get("dryRun") != "" && !dryRun {
+				jsonError(w, "A exportação nunca grava no Azure DevOps; use POST /generate-due-dates", http.StatusBadRequest)
+				return
+			}
+			dryRun = true
+		}
+		blockedToEnd := false
+		if value := r.URL.Query().Get("blockedToEnd"); value != "" {
+			blockedToEnd, err = strconv.ParseBool(value)
+			if err != nil {
+				jsonError(w, fmt.Sprintf("Parâmetro 'blockedToEnd' inválido: %q", value), http.StatusBadRequest)
+				return
+			}
+		}
+		spread := false
+		if value := r.URL.Query().Get("spreadWithinWeek"); value != "" {
+			spread, err = strconv.ParseBool(value)
+			if err != nil {
+				jsonError(w, fmt.Sprintf("Parâmetro 'spreadWithinWeek' inválido: %q", value), http.StatusBadRequest)
+				return
+			}
+		}
+		// ?activityOrder=false desliga ACTIVITY_ORDER para times que paralelizam as atividades
+		activityOrder := cfg.ActivityOrder
+		if value := r.URL.Query().Get("activityOrder"); value != "" {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				jsonError(w, fmt.Sprintf("Parâmetro 'activityOrder' inválido: %q", value), http.StatusBadRequest)
+				return
+			}
+			if !enabled {
+				activityOrder = nil
+			}
+		}
+		wait := false
+		if value := r.URL.Query().Get("wait"); value != "" {
+			wait, err = strconv.ParseBool(value)
+			if err != nil {
+				jsonError(w, fmt.Sprintf("Parâmetro 'wait' inválido: %q", value), http.StatusBadRequest)
+				return
+			}
+		}
+		// O corpo fica guardado para repetir o pedido na aprovação
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			jsonError(w, fmt.Sprintf("Erro ao ler o corpo: %v", err), http.StatusBadRequest)
+			return
+		}
+		overrides, adjustmentRequests, err := parseGenerationBody(bytes.NewReader(body))
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !simulate && len(adjustmentRequests) > 0 {
+			jsonError(w, "Campo 'adjustments' só é aceito em POST /simulate", http.StatusBadRequest)
+			return
+		}
+		adjustments, err := parseCapacityAdjustments(adjustmentRequests)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Com REQUIRE_APPROVAL a geração com escrita vira um dry-run pendente;
+		// a aprovação repete o pedido com o mesmo ID, agora gravando
+		runID := uuid.New().String()
+		approvedRunID := approvedRunFromContext(r.Context())
+		pendingApproval := mode == generationWrite && cfg.RequireApproval && !dryRun && approvedRunID == ""
+		if pendingApproval {
+			dryRun = true
+		}
+		if approvedRunID != "" {
+			runID = approvedRunID
+		}
+
+		ctx := r.Context()
+		workClient, err := pool.Work(ctx, "generate")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
+			return
+		}
+		targetIteration, err := resolveSprintRef(ctx, workClient, cfg, ref)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		sprintName := *targetIteration.Name
+		setSprintHeaders(w, targetIteration)
+
+		idempotencyScope := "generate-due-dates:" + cfg.Team + "/" + targetIteration.Id.String()
+		var idempotencyKey string
+		if mode == generationWrite {
+			idempotencyKey, ok = idempotency.claim(w, r, idempotencyScope, body)
+			if !ok {
+				return
+			}
+			defer idempotency.release(idempotencyScope, idempotencyKey)
+		}
+
+		// Uma geração com escrita por sprint: gravações intercaladas deixariam
+		// os relatórios e o rollback inconsistentes. Dry-run não grava e não espera.
+		if !dryRun {
+			release, inFlight, ok := runs.startGeneration(ctx, cfg.Team+"/"+targetIteration.Id.String(), runID, wait)
+			if !ok {
+				log.Printf("[WARN] Geração na sprint '%s' recusada: execução %s em andamento", sprintName, inFlight)
+				writeJSON(w, http.StatusConflict, map[string]string{
+					"error": fmt.Sprintf("Já existe uma geração de datas em andamento na sprint '%s'", sprintName),
+					"runId": inFlight,
+				})
+				return
+			}
+			defer release()
+		}
+
+		// Geração com escrita interrompida por erro depois de começar
+		finished := false
+		if !dryRun {
+			defer func() {
+				if !finished {
+					events.publish(Event{Type: EventRunFailed, Sprint: sprintName, SprintID: targetIteration.Id.String(), RunID: runID,
+						Text: fmt.Sprintf("Geração de datas na sprint '%s' interrompida por erro (execução %s); veja o log do serviço", sprintName, runID)})
+				}
+			}()
+		}
+
+		sprintStart, sprintEnd, err := sprintDates(targetIteration)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+
+		// Folgas do time valem como dias não úteis para todas as User Stories
+		teamDaysOff, err := fetchTeamDaysOff(ctx, workClient, cfg, targetIteration)
+		if err != nil {
+			respondError(w, "Erro ao buscar folgas do time", err)
+			return
+		}
+		days, err := workingDates(sprintStart, sprintEnd, teamDaysOff, cal)
+		if err != nil {
+			respondError(w, fmt.Sprintf("Datas inválidas na sprint '%s'", sprintName), err)
+			return
+		}
+
+		workItemsResponse, err := workClient.GetIterationWorkItems(ctx, work.GetIterationWorkItemsArgs{
+			Project:     &cfg.Project,
+			Team:        &cfg.Team,
+			IterationId: targetIteration.Id,
+		})
+		if err != nil {
+			respondError(w, "Erro ao buscar work items da sprint", wrapAdoError(err, "GetIterationWorkItems", "sprint=%s", sprintName))
+			return
+		}
+		witClient, err := pool.WorkItems(ctx, "generate")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+
+		// Ordem manual do backlog, que decide quem termina primeiro; sem ela
+		// vale o StackRank
+		var orderWarnings []string
+		backlogRanks, err := fetchBacklogRanks(ctx, workClient, cfg)
+		if err != nil {
+			log.Printf("[WARN] Ordem do backlog indisponível na geração: %v", err)
+			orderWarnings = append(orderWarnings, fmt.Sprintf("Ordem do backlog indisponível; User Stories ordenadas pelo StackRank: %v", err))
+		}
+
+		// User Stories abertas e o DueDate atual de cada uma
+		var stories, excluded, completed []WorkItem
+		current := make(map[int]*time.Time)
+		diagnostics := newDiagnosticsCollector(cfg.DiagnosticExamples)
+		workItemIds := iterationWorkItemIds(workItemsResponse)
+		if len(workItemIds) > 0 {
+			fields := withExtraFields(withExtraFields(userStoryFields, []string{cfg.writeField()}), cfg.ExtraFields)
+			workItems, err := getWorkItemsChunked(ctx, witClient, cfg.Project, workItemIds, fields)
+			if err != nil {
+				respondError(w, "Erro ao buscar User Stories", err)
+				return
+			}
+			types, _ := typesFromRequest(cfg, r)
+			childTypes := cfg.childTypes()
+			for _, detail := range presentWorkItems(workItemIds, workItems) {
+				item, ok := buildUserStory(detail, cfg, types, backlogRanks)
+				if !ok {
+					// Tasks e outros filhos fazem parte das User Stories, não são outro tipo
+					if !isTrackedType(childTypes, getFieldValue(detail.Fields, "System.WorkItemType")) {
+						diagnostics.add(diagnosticOtherType, *detail.Id)
+					}
+					continue
+				}
+				if !keepArea(item.AreaPath) {
+					diagnostics.add(diagnosticOutsideArea, item.ID)
+					continue
+				}
+				if !isOpenStory(item) {
+					diagnostics.add(diagnosticCompleted, item.ID)
+					// A exportação mostra as concluídas como marcos
+					if export && !item.Removed {
+						completed = append(completed, item)
+					}
+					continue
+				}
+				current[item.ID] = currentFieldDate(detail.Fields, cfg.writeField())
+				preferWrittenDate(&item, current[item.ID], cfg.writeField())
+				// User Stories marcadas com uma tag de excludeTags, ou de um
+				// responsável em EXCLUDED_ASSIGNEES, ficam fora do cálculo
+				if _, found := matchTag(item.tags, excludeTags); found || excludedAssignee(item.AssignedTo, cfg.ExcludedAssignees) {
+					excluded = append(excluded, item)
+					diagnostics.add(diagnosticExcluded, item.ID)
+					continue
+				}
+				if item.Blocked {
+					diagnostics.add(diagnosticBlocked, item.ID)
+				}
+				stories = append(stories, item)
+			}
+		}
+		orderedByID := sortByBacklogOrder(stories)
+		if len(orderedByID) > 0 {
+			orderWarnings = append(orderWarnings, fmt.Sprintf("%d User Stories sem ordem do backlog nem StackRank, ordenadas pelo ID: %v", len(orderedByID), orderedByID))
+		}
+
+		// Predecessores vêm antes dos sucessores, mantendo a prioridade onde os vínculos permitem
+		var storyIds []int
+		for _, story := range stories {
+			storyIds = append(storyIds, story.ID)
+		}
+		predecessors := map[int][]int{}
+		if len(storyIds) > 1 {
+			predecessors, err = fetchPredecessors(ctx, witClient, cfg.Project, storyIds)
+			if err != nil {
+				respondError(w, "Erro ao buscar dependências das User Stories", err)
+				return
+			}
+		}
+		stories, predecessors, warnings := orderByDependencies(stories, predecessors)
+		warnings = append(orderWarnings, warnings...)
+
+		// Ajustes para itens fora da geração (outra sprint, fechados, filtrados) só viram aviso
+		considered := make(map[int]bool, len(stories))
+		for _, story := range stories {
+			considered[story.ID] = true
+		}
+		overrideIds := make([]int, 0, len(overrides))
+		for id := range overrides {
+			overrideIds = append(overrideIds, id)
+		}
+		sort.Ints(overrideIds)
+		for _, id := range overrideIds {
+			if !considered[id] {
+				warnings = append(warnings, fmt.Sprintf("Ajuste em overrides para #%d ignorado: não é uma User Story aberta considerada na sprint '%s'", id, sprintName))
+				delete(overrides, id)
+				continue
+			}
+			if date := overrides[id].DueDate; date != nil && (date.Before(sprintStart) || date.After(sprintEnd)) {
+				warnings = append(warnings, fmt.Sprintf("Data explícita de #%d (%s) fora da sprint '%s'; ajustada para dentro dela",
+					id, date.Format("2006-01-02"), sprintName))
+			}
+		}
+
+		var input capacityPlanInput
+		var rollupTasks map[int][]rollupTask
+		var adjustedBy map[string]string
+		if strategy == strategyEven && len(stories) > 0 && len(days) == 0 {
+			respondError(w, "", fmt.Errorf("%w: sprint '%s' não tem dias úteis para %d User Stories", ErrPlanInfeasible, sprintName, len(stories)))
+			return
+		}
+		// A simulação sempre precisa das capacidades, para a utilização
+		if strategy != strategyEven || simulate {
+			capacities, err := fetchTeamCapacities(ctx, workClient, cfg, targetIteration)
+			if err != nil {
+				respondError(w, "Erro ao buscar capacidades da sprint", err)
+				return
+			}
+			// Com a sprint em andamento, o trabalho restante começa hoje
+			start := sprintStart
+			if today := cal.dateOf(time.Now()); today.After(start) {
+				start = today
+			}
+			input = capacityPlanInput{
+				Start:              start,
+				End:                sprintEnd,
+				Capacities:         capacities,
+				TeamDaysOff:        teamDaysOff,
+				DefaultPerDay:      cfg.DefaultCapacityPerDay,
+				HoursPerStoryPoint: cfg.HoursPerStoryPoint,
+				Calendar:           cal,
+				FocusFactor:        cfg.FocusFactor,
+				CeremonyHours:      cfg.CeremonyHoursPerDay,
+				Days:               cfg.dayGranularity(),
+				Availability:       cfg.Availability,
+			}
+			if strategy == strategyRollup {
+				input.ActivityOrder = activityOrder
+			}
+			input.Freeze, err = newSprintFreeze(cfg, sprintStart, sprintEnd, teamDaysOff, cal)
+			if err != nil {
+				respondError(w, fmt.Sprintf("Datas inválidas na sprint '%s'", sprintName), err)
+				return
+			}
+			if simulate {
+				input.Capacities, adjustedBy, err = applyCapacityAdjustments(input.Capacities, adjustments, cfg.DefaultCapacityPerDay)
+				if err != nil {
+					jsonError(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				warnings = append(warnings, reassignStories(stories, adjustments, input.Capacities)...)
+			}
+			if strategy == strategyCapacity {
+				input.Durations = make(map[int]int)
+				for id, override := range overrides {
+					if override.WorkingDays > 0 {
+						input.Durations[id] = override.WorkingDays
+					}
+				}
+			}
+			switch {
+			case (strategy == strategyCapacity || simulate) && input.Days:
+				input.TaskWork, err = storyDayWork(ctx, witClient, cfg, storyIds)
+			case strategy == strategyCapacity || simulate:
+				input.TaskWork, err = storyTaskWork(ctx, witClient, cfg, storyIds)
+			}
+			if err == nil && strategy == strategyRollup {
+				rollupTasks, err = storyRollupTasks(ctx, witClient, cfg, storyIds)
+			}
+			if err != nil {
+				respondError(w, "Erro ao buscar tasks das User Stories", err)
+				return
+			}
+		}
+
+		// Datas explícitas de overrides ficam fora da estratégia; durações entram
+		// no cálculo de capacity e, nas outras estratégias, contam dias úteis a
+		// partir do início do planejamento
+		durationStart := input.Start
+		if strategy == strategyEven {
+			durationStart = sprintStart
+		}
+		// Cálculo das datas na ordem das User Stories; também usado para
+		// recalcular uma User Story relida depois de uma alteração concorrente
+		computePlans := func(stories []WorkItem) ([]generationPlan, []string, error) {
+			fixed := make(map[int]generationPlan)
+			var planned []WorkItem
+			for _, story := range stories {
+				override, ok := overrides[story.ID]
+				switch {
+				case !ok || (override.DueDate == nil && strategy == strategyCapacity):
+					planned = append(planned, story)
+				case override.DueDate != nil:
+					date := *override.DueDate
+					fixed[story.ID] = generationPlan{Story: story, Start: date, DueDate: &date, Override: overrideDueDate}
+				default:
+					plan := generationPlan{Story: story, Start: durationStart, Override: overrideWorkingDays}
+					date, fits := nthWorkingDate(days, durationStart, override.WorkingDays)
+					if !fits {
+						date, plan.AtRisk = sprintEnd, true
+					}
+					plan.DueDate = &date
+					fixed[story.ID] = plan
+				}
+			}
+
+			var computed []generationPlan
+			var warnings []string
+			var err error
+			switch strategy {
+			case strategyEven:
+				computed = planEven(planned, days)
+			case strategyCapacity:
+				computed, warnings, err = planCapacity(planned, input)
+			case strategyRollup:
+				computed, warnings, err = planRollup(planned, rollupTasks, input)
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+
+			// De volta à ordem das User Stories, que a dependência e a folga exigem
+			byID := make(map[int]generationPlan, len(computed))
+			for _, plan := range computed {
+				byID[plan.Story.ID] = plan
+			}
+			plans := make([]generationPlan, 0, len(stories))
+			for _, story := range stories {
+				if plan, ok := fixed[story.ID]; ok {
+					plans = append(plans, plan)
+				} else {
+					plans = append(plans, byID[story.ID])
+				}
+			}
+			if blockedToEnd {
+				pushBlockedToEnd(plans, days)
+			}
+			applyDependencyDates(plans, predecessors, days, sprintEnd)
+			if spread {
+				spreadWithinWeek(plans, days, predecessors)
+			}
+			applyBuffer(plans, buffer, days, predecessors)
+			rollDueDates(plans, cal, sprintStart, sprintEnd, roll)
+			return plans, warnings, nil
+		}
+		plans, planWarnings, err := computePlans(stories)
+		if err != nil {
+			respondError(w, fmt.Sprintf("Datas inválidas na sprint '%s'", sprintName), err)
+			return
+		}
+		warnings = append(warnings, planWarnings...)
+
+		report := GenerationReport{
+			SchemaVersion:    generationSchemaVersion,
+			RunID:            runID,
+			StartedAt:        startedAt,
+			Sprint:           sprintName,
+			SprintID:         targetIteration.Id.String(),
+			Team:             cfg.TeamName,
+			DueDateField:     cfg.writeField(),
+			SprintStart:      sprintStart,
+			SprintEnd:        sprintEnd,
+			Strategy:         strategy,
+			Granularity:      cfg.Granularity,
+			DryRun:           dryRun,
+			Overwrite:        overwrite,
+			Cascade:          cascade,
+			Tag:              cfg.GeneratedTag,
+			ExcludeTags:      excludeTags,
+			Comment:          comment,
+			AreaPath:         strings.TrimSpace(r.URL.Query().Get("areaPath")),
+			AreaPathExact:    areaPathExact,
+			BufferPercent:    buffer.Percent,
+			BufferDays:       buffer.Days,
+			BlockedToEnd:     blockedToEnd,
+			SpreadWithinWeek: spread,
+			ActivityOrder:    input.ActivityOrder,
+			OrderedByID:      orderedByID,
+			WorkingDays:      len(days),
+			Items:            make([]GenerationItem, 0, len(stories)+len(excluded)),
+			Warnings:         append([]string{}, warnings...),
+		}
+		if input.Freeze.enabled() {
+			report.FreezeStart = &input.Freeze.Start
+		}
+		// Tasks abertas de cada User Story, para ?cascade=tasks
+		childTasks := make(map[int][]WorkItem)
+		currentTask := make(map[int]*time.Time)
+		if cascade == cascadeTasks && len(storyIds) > 0 {
+			tasks, err := fetchChildTasks(ctx, witClient, cfg, storyIds, withExtraFields(append([]string{"System.Title", "System.Parent", "System.Tags"}, dueDateFields...), []string{cfg.writeField()}))
+			if err != nil {
+				respondError(w, "Erro ao buscar tasks das User Stories", err)
+				return
+			}
+			for _, task := range tasks {
+				parent := getFieldFloat(task.Fields, "System.Parent")
+				if parent == nil {
+					continue
+				}
+				item := WorkItem{ID: *task.Id, Title: getFieldValue(task.Fields, "System.Title")}
+				item.DueDate, item.dueDateField = workItemDueDate(item.ID, task.Fields)
+				item.tags = workItemTags(task.Fields)
+				if task.Rev != nil {
+					item.revision = *task.Rev
+				}
+				currentTask[item.ID] = currentFieldDate(task.Fields, cfg.writeField())
+				preferWrittenDate(&item, currentTask[item.ID], cfg.writeField())
+				childTasks[int(*parent)] = append(childTasks[int(*parent)], item)
+			}
+			report.Tasks = &GenerationCounts{}
+		}
+
+		// A aprovação só grava o plano revisto no pedido: iterações,
+		// capacidades ou work items alterados desde então mudam as gravações
+		var writes []DueDateRunItem
+		if pendingApproval || approvedRunID != "" {
+			writes = plannedWrites(plans, current, childTasks, currentTask, overwrite, excludeTags, cfg.Location)
+		}
+		if approvedRunID != "" {
+			var approved []DueDateRunItem
+			if pending, ok := runs.get(approvedRunID); ok && pending.Approval != nil {
+				approved = pending.Approval.Plan
+			}
+			if changed := changedWrites(approved, writes); len(changed) > 0 {
+				log.Printf("[WARN] Geração aprovada %s na sprint '%s' não executada: plano mudou em %d itens %v", runID, sprintName, len(changed), changed)
+				finished = true
+				writeJSON(w, http.StatusConflict, map[string]interface{}{
+					"error":   fmt.Sprintf("Execução '%s': %v em %d itens; nada foi gravado, peça a geração de novo", runID, errApprovalPlanChanged, len(changed)),
+					"runId":   runID,
+					"changed": changed,
+				})
+				return
+			}
+		}
+
+		writer := &dueDateWriter{ctx: ctx, witClient: witClient, project: cfg.Project, field: cfg.writeField(), location: cfg.Location, policy: overwrite, dryRun: dryRun, tag: cfg.GeneratedTag}
+		if comment {
+			writer.commentText = func(previous, newDate *time.Time) string {
+				return dueDateCommentText(cfg.DueDateCommentTemplate, runID, strategy, sprintName, previous, newDate)
+			}
+		}
+		// Só capacity usa responsável e Story Points da própria User Story
+		if strategy == strategyCapacity {
+			writer.recompute = func(fresh WorkItem) generationPlan {
+				replaced := make([]WorkItem, len(stories))
+				for i, story := range stories {
+					if story.ID == fresh.ID {
+						story = fresh
+					}
+					replaced[i] = story
+				}
+				plans, _, err := computePlans(replaced)
+				for _, plan := range plans {
+					if err == nil && plan.Story.ID == fresh.ID {
+						return plan
+					}
+				}
+				return generationPlan{Story: fresh, ReasonCode: reasonConcurrentModification, Reason: "não foi possível recalcular a data após a alteração concorrente"}
+			}
+		}
+		schedulable := 0
+		for _, plan := range plans {
+			diagnostics.addPlan(plan)
+			if plan.DueDate != nil {
+				schedulable++
+			}
+		}
+		report.Diagnostics = diagnostics.build(schedulable, sprintName)
+		for _, plan := range plans {
+			story := plan.Story
+			item := GenerationItem{
+				ID:              story.ID,
+				Title:           story.Title,
+				StackRank:       story.StackRank,
+				BacklogRank:     story.BacklogRank,
+				RemainingWork:   plan.RemainingWork,
+				Predecessors:    predecessors[story.ID],
+				AtRisk:          plan.AtRisk,
+				Blocked:         story.Blocked,
+				PreviousDueDate: current[story.ID],
+				RawDueDate:      plan.RawDueDate,
+				DueDate:         plan.DueDate,
+				Override:        plan.Override,
+				Spread:          plan.Spread,
+				Explanation:     plan.Explanation,
+			}
+			if story.AssignedTo != nil {
+				item.AssignedTo = story.AssignedTo.DisplayName
+			}
+			if plan.AtRisk {
+				report.AtRisk++
+			}
+			if story.Blocked {
+				report.Blocked++
+			}
+			if story.DueDate != nil {
+				item.ExistingDate, item.ExistingDateField = story.DueDate, story.dueDateField
+			}
+			if plan.DueDate == nil {
+				item.GenerationOutcome = GenerationOutcome{Status: dueDateSkipped, ReasonCode: plan.ReasonCode, Reason: plan.Reason}
+			} else {
+				item.GenerationOutcome, item.DueDate = writer.apply(story, current[story.ID], plan.DueDate)
+				// Em risco sem outro motivo: o trabalho não cabe até o fim da
+				// sprint ou, quando só os dias congelados faltam, até o congelamento
+				switch {
+				case plan.AtRisk && item.ReasonCode == "" && plan.FrozenOut:
+					item.ReasonCode, item.Reason = reasonFreezeOverflow, "trabalho não cabe antes do congelamento do fim da sprint (FREEZE_DAYS_BEFORE_END)"
+				case plan.AtRisk && item.ReasonCode == "":
+					item.ReasonCode, item.Reason = reasonExceedsSprintEnd, "trabalho passa do fim da sprint"
+				}
+				if item.DueDate != nil {
+					item.DueDayOfWeek = item.DueDate.Weekday().String()
+				}
+			}
+			report.count(item.Status)
+
+			// As tasks recebem a data da User Story quando ela foi (ou seria) gravada ou já estava correta
+			if report.Tasks != nil && item.DueDate != nil && item.Status != dueDateFailed && !keepExistingDate(overwrite, story, *item.DueDate) {
+				item.Tasks = make([]GenerationTaskItem, 0, len(childTasks[story.ID]))
+				for _, task := range childTasks[story.ID] {
+					taskItem := GenerationTaskItem{
+						ID:              task.ID,
+						Title:           task.Title,
+						PreviousDueDate: currentTask[task.ID],
+						DueDate:         item.DueDate,
+					}
+					if task.DueDate != nil {
+						taskItem.ExistingDate, taskItem.ExistingDateField = task.DueDate, task.dueDateField
+					}
+					if tag, found := matchTag(task.tags, excludeTags); found {
+						taskItem.GenerationOutcome = GenerationOutcome{Status: dueDateSkipped, ReasonCode: reasonExcludedTag, Reason: fmt.Sprintf("tag '%s' exclui o item da geração", tag)}
+					} else {
+						taskItem.GenerationOutcome, _ = writer.apply(task, currentTask[task.ID], item.DueDate)
+					}
+					report.Tasks.count(taskItem.Status)
+					item.Tasks = append(item.Tasks, taskItem)
+				}
+			}
+			report.Items = append(report.Items, item)
+		}
+		for _, story := range excluded {
+			item := GenerationItem{
+				ID:              story.ID,
+				Title:           story.Title,
+				StackRank:       story.StackRank,
+				BacklogRank:     story.BacklogRank,
+				PreviousDueDate: current[story.ID],
+				GenerationOutcome: GenerationOutcome{
+					Status:     dueDateSkipped,
+					ReasonCode: reasonExcludedAssignee,
+					Reason:     "responsável em EXCLUDED_ASSIGNEES",
+				},
+			}
+			if tag, found := matchTag(story.tags, excludeTags); found {
+				item.ReasonCode, item.Reason = reasonExcludedTag, fmt.Sprintf("tag '%s' exclui o item da geração", tag)
+			}
+			if story.AssignedTo != nil {
+				item.AssignedTo = story.AssignedTo.DisplayName
+			}
+			if story.DueDate != nil {
+				item.ExistingDate, item.ExistingDateField = story.DueDate, story.dueDateField
+			}
+			report.count(item.Status)
+			report.Items = append(report.Items, item)
+		}
+		report.Warnings = append(report.Warnings, writer.warnings...)
+		report.Weekdays = weekdayDistribution(report.Items)
+		if len(stories) == 0 && len(excluded) == 0 {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("Nenhuma User Story aberta na sprint '%s'", sprintName))
+		}
+
+		if simulate {
+			developers, err := simulatedUtilization(plans, input, adjustedBy)
+			if err != nil {
+				respondError(w, fmt.Sprintf("Datas inválidas na sprint '%s'", sprintName), err)
+				return
+			}
+			report.FinishedAt = time.Now().UTC()
+			log.Printf("[DEBUG] Simulação (%s) na sprint '%s' com %d ajustes: %d previstos, %d em risco",
+				strategy, sprintName, len(adjustments), report.Planned, report.AtRisk)
+			writeJSON(w, http.StatusOK, simulationReport(report, adjustments, developers))
+			return
+		}
+		if export {
+			rows, omitted := projectExportItems(report.Items, plans, completed, sprintStart, sprintEnd)
+			log.Printf("[DEBUG] Exportação para o MS Project (%s) na sprint '%s': %d linhas, %d itens sem data",
+				strategy, sprintName, len(rows), len(omitted))
+			writeProjectCSV(w, sprintName, rows, omitted, days)
+			return
+		}
+
+		// Com DELIVERY_PLAN_TAG, as User Stories do plano recebem StartDate e
+		// são comparadas com a data comprometida; falhas viram avisos
+		if cfg.DeliveryPlanTag != "" {
+			considered := append(append([]WorkItem{}, stories...), excluded...)
+			deliveryPlan, err := checkDeliveryPlan(ctx, witClient, cfg, writer, considered, report.Items)
+			if err != nil {
+				log.Printf("[WARN] Erro ao verificar o delivery plan na sprint '%s': %v", sprintName, err)
+				report.Warnings = append(report.Warnings, fmt.Sprintf("Delivery plan não verificado: %v", err))
+			}
+			report.DeliveryPlan = deliveryPlan
+		}
+
+		report.FinishedAt = time.Now().UTC()
+		if pendingApproval {
+			approval := RunApproval{
+				Status:       approvalPending,
+				RequestedKey: adminKeyID(r.Header.Get("X-Admin-Key")),
+				RequestedAt:  report.FinishedAt,
+				ExpiresAt:    report.FinishedAt.Add(cfg.ApprovalTTL),
+				Query:        r.URL.RawQuery,
+				Body:         string(body),
+				Plan:         writes,
+			}
+			runs.recordPendingGeneration(report, cfg.Team, r, approval)
+			report.Approval = &approval
+			log.Printf("[DEBUG] Geração de datas (%s) na sprint '%s' aguardando aprovação até %s: %d previstos (execução %s)",
+				strategy, sprintName, approval.ExpiresAt.Format(time.RFC3339), report.Planned, report.RunID)
+			events.publish(pendingApprovalEvent(report, approval.ExpiresAt))
+			replayed := report
+			replayed.Replayed = true
+			idempotency.complete(idempotencyScope, idempotencyKey, http.StatusAccepted, replayed)
+			writeJSON(w, http.StatusAccepted, report)
+			return
+		}
+		if approvedRunID != "" {
+			if pending, ok := runs.get(approvedRunID); ok {
+				report.Approval = pending.Approval
+			}
+		}
+		runs.recordGeneration(report, cfg.Team, runCallerFromRequest(r), writer.written)
+		log.Printf("[DEBUG] Geração de datas (%s) na sprint '%s': %d previstos, %d gravados, %d sem mudança, %d ignorados, %d falhas (execução %s)",
+			strategy, sprintName, report.Planned, report.Updated, report.Unchanged, report.Skipped, report.Failed, report.RunID)
+		status := http.StatusOK
+		if report.Failed > 0 || (report.Tasks != nil && report.Tasks.Failed > 0) {
+			status = http.StatusMultiStatus
+		}
+		finished = true
+		if !dryRun {
+			events.publish(generationEvent(report, status))
+		}
+		replayed := report
+		replayed.Replayed = true
+		idempotency.complete(idempotencyScope, idempotencyKey, status, replayed)
+		writeJSON(w, status, report)
+	}
+}
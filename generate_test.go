@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+func TestSameDueDay(t *testing.T) {
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Skip(err)
+	}
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip(err)
+	}
+	computed := day(2024, 3, 5)
+	at := func(value string) *time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &parsed
+	}
+	tests := []struct {
+		name    string
+		current *time.Time
+		loc     *time.Location
+		want    bool
+	}{
+		{name: "gravada pela geração", current: at("2024-03-05T00:00:00Z"), loc: saoPaulo, want: true},
+		{name: "digitada em São Paulo", current: at("2024-03-05T03:00:00Z"), loc: saoPaulo, want: true},
+		{name: "digitada em Tóquio", current: at("2024-03-04T15:00:00Z"), loc: tokyo, want: true},
+		{name: "digitada em Tóquio lida em UTC", current: at("2024-03-04T15:00:00Z"), loc: time.UTC, want: false},
+		{name: "outro dia", current: at("2024-03-06T03:00:00Z"), loc: saoPaulo, want: false},
+		{name: "sem data", loc: saoPaulo, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameDueDay(tt.current, &computed, tt.loc); got != tt.want {
+				t.Errorf("sameDueDay = %t, quer %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateDueDatesSecondRunWritesNothing(t *testing.T) {
+	iteration := fakeIteration("Sprint 7", day(2030, 3, 4), day(2030, 3, 15), work.TimeFrameValues.Future)
+	story := func(id int, points float64) workitemtracking.WorkItem {
+		item := fakeStory(id, "História", "Active")
+		(*item.Fields)["System.AssignedTo"] = fakeIdentity("Ana Souza", "ana@example.com")
+		(*item.Fields)["Microsoft.VSTS.Scheduling.StoryPoints"] = points
+		return item
+	}
+	workClient := &fakeWorkClient{
+		iterations: []work.TeamSettingsIteration{iteration},
+		relations:  map[uuid.UUID][]workitemtracking.WorkItemLink{*iteration.Id: {fakeLink(0, 1), fakeLink(0, 2)}},
+	}
+	witClient := newFakeWitClient(story(1, 1), story(2, 2))
+	cfg := &Config{Project: "Projeto", Team: "Time", TeamName: "Time", WorkItemTypes: []string{"User Story"}, ChildWorkItemTypes: []string{"Task"},
+		DefaultCapacityPerDay: 8, HoursPerStoryPoint: 8, FocusFactor: 1, DueDateWriteField: dueDateField, GeneratedTag: "duedate-generated",
+		DueDateCommentTemplate: defaultDueDateCommentTemplate, Location: time.UTC}
+	store, err := newRunStore("", runRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := handleGenerateDueDates(newFakePool(workClient, witClient), cfg, store, newIdempotencyStore(time.Hour), nil)
+	generate := func() GenerationReport {
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest(http.MethodPost, "/generate-due-dates?sprint=Sprint%207&strategy=capacity&comment=true", nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+		}
+		var report GenerationReport
+		if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+
+	first := generate()
+	if first.Updated != 2 || first.Unchanged != 0 || len(witClient.updates) != 2 || len(witClient.comments) != 2 {
+		t.Fatalf("primeira execução: updated=%d unchanged=%d, %d gravações e %d comentários, quer 2/0/2/2", first.Updated, first.Unchanged, len(witClient.updates), len(witClient.comments))
+	}
+
+	second := generate()
+	if second.Updated != 0 || second.Unchanged != 2 || second.Skipped != 0 {
+		t.Errorf("segunda execução: updated=%d unchanged=%d skipped=%d, quer 0/2/0", second.Updated, second.Unchanged, second.Skipped)
+	}
+	for _, item := range second.Items {
+		if item.Status != dueDateUnchanged || item.ReasonCode != reasonUnchanged || item.TagAdded != "" || item.Commented {
+			t.Errorf("item #%d = %+v, quer unchanged sem tag nem comentário", item.ID, item.GenerationOutcome)
+		}
+	}
+	for id, documents := range witClient.updates {
+		if len(documents) != 1 || len(witClient.comments[id]) != 1 {
+			t.Errorf("item #%d: %d gravações e %d comentários, quer só os da primeira execução", id, len(documents), len(witClient.comments[id]))
+		}
+	}
+}
+
+func TestGenerateDueDatesDayGranularity(t *testing.T) {
+	iteration := fakeIteration("Sprint 7", day(2030, 3, 4), day(2030, 3, 15), work.TimeFrameValues.Future)
+	story := func(id int, fields map[string]interface{}) workitemtracking.WorkItem {
+		item := fakeStory(id, "História", "Active")
+		(*item.Fields)["System.AssignedTo"] = fakeIdentity("Ana Souza", "ana@example.com")
+		for name, value := range fields {
+			(*item.Fields)[name] = value
+		}
+		return item
+	}
+	task := func(id, parent int, days float64) workitemtracking.WorkItem {
+		return fakeWorkItem(id, map[string]interface{}{"System.WorkItemType": "Task", "System.State": "Active", "System.Parent": float64(parent), "Custom.EstimateDays": days})
+	}
+	workClient := &fakeWorkClient{
+		iterations: []work.TeamSettingsIteration{iteration},
+		relations:  map[uuid.UUID][]workitemtracking.WorkItemLink{*iteration.Id: {fakeLink(0, 1), fakeLink(0, 2), fakeLink(0, 3)}},
+	}
+	witClient := newFakeWitClient(
+		// 1,2 + 0,5 dia nas tasks: 2 + 1 dias inteiros, de segunda a quarta
+		story(1, nil),
+		task(11, 1, 1.2),
+		task(12, 1, 0.5),
+		// Sem tasks: 1,5 dia na própria User Story vira 2, quinta e sexta
+		story(2, map[string]interface{}{"Custom.EstimateDays": 1.5}),
+		// Story Points não valem em dias
+		story(3, map[string]interface{}{"Microsoft.VSTS.Scheduling.StoryPoints": 3.0}),
+	)
+	witClient.queryByWiql = func(query string) []int { return []int{11, 12} }
+	cfg := &Config{Project: "Projeto", Team: "Time", TeamName: "Time", WorkItemTypes: []string{"User Story"}, ChildWorkItemTypes: []string{"Task"},
+		DefaultCapacityPerDay: 8, HoursPerStoryPoint: 8, FocusFactor: 0.5, CeremonyHoursPerDay: 2, DueDateWriteField: dueDateField,
+		DueDateCommentTemplate: defaultDueDateCommentTemplate, Location: time.UTC, Granularity: granularityDays, DayEstimateField: "Custom.EstimateDays"}
+	store, err := newRunStore("", runRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := handleGenerateDueDates(newFakePool(workClient, witClient), cfg, store, newIdempotencyStore(time.Hour), nil)
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodPost, "/generate-due-dates?sprint=Sprint%207&strategy=capacity&dryRun=true", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var report GenerationReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Granularity != granularityDays {
+		t.Errorf("granularity = %q, quer days", report.Granularity)
+	}
+	if len(report.Items) != 3 {
+		t.Fatalf("%d itens, quer 3", len(report.Items))
+	}
+	// FOCUS_FACTOR e cerimônias não mudam dias inteiros
+	want := map[int]time.Time{1: day(2030, 3, 6), 2: day(2030, 3, 8)}
+	for _, item := range report.Items {
+		if date, ok := want[item.ID]; ok {
+			if item.DueDate == nil || !item.DueDate.Equal(date) {
+				t.Errorf("#%d = %v, quer %s", item.ID, item.DueDate, date.Format("2006-01-02"))
+			}
+		} else if item.DueDate != nil || item.ReasonCode != reasonNoEstimate {
+			t.Errorf("#%d = %v (%s), quer fora sem estimativa em dias", item.ID, item.DueDate, item.ReasonCode)
+		}
+	}
+}
+
+func TestGenerateDueDatesBacklogOrder(t *testing.T) {
+	iteration := fakeIteration("Sprint 7", day(2030, 3, 4), day(2030, 3, 15), work.TimeFrameValues.Future)
+	// #3 e #1 no backlog, #2 fora dele com StackRank e #4 sem nenhum dos dois
+	workClient := &fakeWorkClient{
+		iterations: []work.TeamSettingsIteration{iteration},
+		relations:  map[uuid.UUID][]workitemtracking.WorkItemLink{*iteration.Id: {fakeLink(0, 1), fakeLink(0, 2), fakeLink(0, 3), fakeLink(0, 4)}},
+		backlog:    []int{3, 1},
+	}
+	ranked := fakeStory(2, "História", "Active")
+	(*ranked.Fields)["Microsoft.VSTS.Common.StackRank"] = 5.0
+	witClient := newFakeWitClient(fakeStory(1, "História", "Active"), ranked, fakeStory(3, "História", "Active"), fakeStory(4, "História", "Active"))
+	cfg := &Config{Project: "Projeto", Team: "Time", TeamName: "Time", WorkItemTypes: []string{"User Story"}, ChildWorkItemTypes: []string{"Task"},
+		DefaultCapacityPerDay: 8, HoursPerStoryPoint: 8, FocusFactor: 1, DueDateWriteField: dueDateField, DueDateCommentTemplate: defaultDueDateCommentTemplate, Location: time.UTC}
+	store, err := newRunStore("", runRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	handleGenerateDueDates(newFakePool(workClient, witClient), cfg, store, newIdempotencyStore(time.Hour), nil)(recorder,
+		httptest.NewRequest(http.MethodPost, "/generate-due-dates?sprint=Sprint%207&dryRun=true", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var report GenerationReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	var order []int
+	for _, item := range report.Items {
+		order = append(order, item.ID)
+	}
+	if want := []int{3, 1, 2, 4}; !reflect.DeepEqual(order, want) {
+		t.Errorf("ordem = %v, quer %v", order, want)
+	}
+	if report.Items[0].BacklogRank == nil || *report.Items[0].BacklogRank != 1 || report.Items[2].BacklogRank != nil {
+		t.Errorf("backlogRank = %v, %v; quer 1 para #3 e null para #2", report.Items[0].BacklogRank, report.Items[2].BacklogRank)
+	}
+	if !reflect.DeepEqual(report.OrderedByID, []int{4}) {
+		t.Errorf("orderedById = %v, quer [4]", report.OrderedByID)
+	}
+}
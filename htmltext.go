@@ -0,0 +1,114 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Formatos aceitos em ?format= para campos de descrição
+const (
+	descriptionFormatText = "text"
+	descriptionFormatHTML = "html"
+)
+
+var (
+	// Blocos cujo conteúdo nunca é texto visível
+	htmlInvisiblePattern = regexp.MustCompile(`(?is)<!--.*?-->|<(script|style)\b[^>]*>.*?</(script|style)\s*>`)
+	htmlTagPattern       = regexp.MustCompile(`(?s)<(/?)([a-zA-Z][a-zA-Z0-9]*)\b[^>]*?(/?)>`)
+	htmlSpacesPattern    = regexp.MustCompile(`[ \t\r\f\v]+`)
+)
+
+// Tags que encerram uma linha no texto final
+var htmlBlockTags = map[string]bool{
+	"p": true, "div": true, "tr": true, "table": true, "blockquote": true, "pre": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true,
+}
+
+// Função para converter um campo HTML do Azure DevOps (System.Description e
+// afins) em texto simples: <br> e blocos viram quebras de linha, itens de
+// lista viram "- " indentados pelo nível, imagens e scripts são descartados e
+// entidades (&nbsp;, &atilde; ...) são decodificadas.
+func htmlToText(value string) string {
+	if value == "" {
+		return ""
+	}
+	value = htmlInvisiblePattern.ReplaceAllString(value, "")
+
+	var out strings.Builder
+	listDepth := 0
+	last := 0
+	for _, match := range htmlTagPattern.FindAllStringSubmatchIndex(value, -1) {
+		out.WriteString(value[last:match[0]])
+		last = match[1]
+
+		closing := match[3] > match[2]
+		tag := strings.ToLower(value[match[4]:match[5]])
+		switch {
+		case tag == "br":
+			out.WriteString("\n")
+		case tag == "ul" || tag == "ol":
+			if closing {
+				if listDepth > 0 {
+					listDepth--
+				}
+			} else {
+				listDepth++
+			}
+			out.WriteString("\n")
+		case tag == "li" && !closing:
+			depth := listDepth
+			if depth < 1 {
+				depth = 1
+			}
+			out.WriteString("\n" + strings.Repeat("  ", depth-1) + "- ")
+		case tag == "td" || tag == "th":
+			if closing {
+				out.WriteString(" ")
+			}
+		case htmlBlockTags[tag]:
+			out.WriteString("\n")
+		}
+		// Demais tags (inclusive <img>) são removidas sem deixar texto
+	}
+	out.WriteString(value[last:])
+
+	text := html.UnescapeString(out.String())
+	text = strings.ReplaceAll(text, "\u00a0", " ")
+
+	// Normaliza espaços por linha e descarta as linhas vazias deixadas pelos blocos
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		line = htmlSpacesPattern.ReplaceAllString(strings.TrimSpace(line), " ")
+		if line == "" {
+			continue
+		}
+		// Preserva a indentação dos itens de lista aninhados
+		if strings.HasPrefix(line, "- ") {
+			line = strings.Repeat(" ", indent) + line
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Função para aplicar o formato pedido a um campo de descrição
+func formatDescription(value, format string) string {
+	if format == descriptionFormatHTML {
+		return value
+	}
+	return htmlToText(value)
+}
+
+// Função para ler ?format= (text, padrão, ou html)
+func descriptionFormatFromQuery(value string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", descriptionFormatText:
+		return descriptionFormatText, true
+	case descriptionFormatHTML:
+		return descriptionFormatHTML, true
+	}
+	return "", false
+}
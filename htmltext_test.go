@@ -0,0 +1,89 @@
+package main
+
+import "testing"
+
+func TestHtmlToText(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{name: "vazio", html: "", want: ""},
+		{name: "texto puro", html: "Sem marcação", want: "Sem marcação"},
+		{
+			name: "parágrafos e quebras",
+			html: "<div>Primeira linha<br>Segunda linha</div><p>Terceira</p>",
+			want: "Primeira linha\nSegunda linha\nTerceira",
+		},
+		{
+			name: "entidades em português",
+			html: "<p>Valida&ccedil;&atilde;o do cart&atilde;o &eacute; obrigat&oacute;ria &ndash; prazo at&eacute; sexta&nbsp;&agrave;s&nbsp;18h</p>",
+			want: "Validação do cartão é obrigatória – prazo até sexta às 18h",
+		},
+		{
+			name: "entidades numéricas e escapes",
+			html: "<p>Op&#231;&#245;es &#x2192; &lt;script&gt; &amp; &quot;aspas&quot;</p>",
+			want: "Opções → <script> & \"aspas\"",
+		},
+		{
+			name: "lista simples",
+			html: "<ul><li>Login</li><li>Logout</li></ul>",
+			want: "- Login\n- Logout",
+		},
+		{
+			name: "listas aninhadas",
+			html: "<p>Critérios:</p><ul><li>Cadastro<ul><li>Nome</li><li>E-mail<ol><li>Formato válido</li></ol></li></ul></li><li>Confirma&ccedil;&atilde;o</li></ul>",
+			want: "Critérios:\n- Cadastro\n  - Nome\n  - E-mail\n    - Formato válido\n- Confirmação",
+		},
+		{
+			name: "lista aninhada com atributos e espaços",
+			html: "<ol style=\"margin:0\">\n  <li>Passo <b>um</b>\n    <ul class=\"x\">\n      <li>detalhe&nbsp;a</li>\n    </ul>\n  </li>\n  <li>Passo dois</li>\n</ol>",
+			want: "- Passo um\n  - detalhe a\n- Passo dois",
+		},
+		{
+			name: "li fora de lista",
+			html: "<li>solto</li>",
+			want: "- solto",
+		},
+		{
+			name: "imagens, scripts, estilos e comentários",
+			html: "<p>Antes<img src=\"x.png\" alt=\"imagem\"/></p><script>alert('x')</script><style>p{color:red}</style><!-- nota --><p>Depois</p>",
+			want: "Antes\nDepois",
+		},
+		{
+			name: "tabela",
+			html: "<table><tr><th>Campo</th><th>Valor</th></tr><tr><td>Prazo</td><td>sexta</td></tr></table>",
+			want: "Campo Valor\nPrazo sexta",
+		},
+		{
+			name: "maiúsculas nas tags",
+			html: "<DIV>Um<BR/>Dois</DIV>",
+			want: "Um\nDois",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := htmlToText(tt.html); got != tt.want {
+				t.Errorf("htmlToText(%q) =\n%q\nquer\n%q", tt.html, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatDescription(t *testing.T) {
+	value := "<p>Descri&ccedil;&atilde;o</p>"
+	if got := formatDescription(value, descriptionFormatHTML); got != value {
+		t.Errorf("format=html alterou o valor: %q", got)
+	}
+	if got := formatDescription(value, descriptionFormatText); got != "Descrição" {
+		t.Errorf("format=text = %q", got)
+	}
+	for _, value := range []string{"", "text", " HTML "} {
+		if _, ok := descriptionFormatFromQuery(value); !ok {
+			t.Errorf("descriptionFormatFromQuery(%q) recusado", value)
+		}
+	}
+	if _, ok := descriptionFormatFromQuery("markdown"); ok {
+		t.Error("descriptionFormatFromQuery aceitou markdown")
+	}
+}
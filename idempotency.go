@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Header com a chave de idempotência dos POSTs que gravam datas
+const idempotencyHeader = "Idempotency-Key"
+
+const maxIdempotencyKeyLength = 255
+
+// Resposta guardada para uma chave. Enquanto a requisição original não
+// termina, done fica false e repetições recebem 409. fingerprint identifica o
+// pedido (método, query e corpo) que reservou a chave.
+type idempotentResponse struct {
+	fingerprint string
+	status      int
+	body        interface{}
+	done        bool
+	expiresAt   time.Time
+}
+
+// idempotencyStore guarda em memória, por IDEMPOTENCY_WINDOW, a resposta de
+// cada POST enviado com Idempotency-Key. As chaves valem por escopo (endpoint
+// e sprint), então a mesma chave em outro endpoint ou sprint executa de novo.
+type idempotencyStore struct {
+	mu      sync.Mutex
+	window  time.Duration
+	entries map[string]*idempotentResponse
+}
+
+func newIdempotencyStore(window time.Duration) *idempotencyStore {
+	return &idempotencyStore{window: window, entries: make(map[string]*idempotentResponse)}
+}
+
+// Função para resumir o pedido ligado a uma chave: método, query (em ordem
+// canônica) e corpo já lido pelo handler
+func idempotencyFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n", r.Method, r.URL.Query().Encode())
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Função para reservar a chave de uma requisição. Sem o header, devolve chave
+// vazia e a requisição segue normalmente. Quando a chave já foi usada no
+// escopo, responde com o resultado guardado (ou 409 se a original ainda está
+// em andamento) e devolve false; a mesma chave com outro método, query ou
+// corpo recebe 422 em vez do resultado de um pedido diferente. Com a chave
+// reservada, o handler deve chamar release com defer e complete antes de
+// responder.
+func (s *idempotencyStore) claim(w http.ResponseWriter, r *http.Request, scope string, body []byte) (string, bool) {
+	key := strings.TrimSpace(r.Header.Get(idempotencyHeader))
+	if key == "" {
+		return "", true
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		jsonError(w, fmt.Sprintf("Header %s muito longo (máximo %d caracteres)", idempotencyHeader, maxIdempotencyKeyLength), http.StatusBadRequest)
+		return "", false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, entry := range s.entries {
+		if entry.done && now.After(entry.expiresAt) {
+			delete(s.entries, id)
+		}
+	}
+	fingerprint := idempotencyFingerprint(r, body)
+	entry, ok := s.entries[scope+"\x00"+key]
+	switch {
+	case !ok:
+		s.entries[scope+"\x00"+key] = &idempotentResponse{fingerprint: fingerprint}
+		return key, true
+	case entry.fingerprint != fingerprint:
+		jsonError(w, fmt.Sprintf("%s já usado com outro pedido (método, parâmetros ou corpo diferentes)", idempotencyHeader), http.StatusUnprocessableEntity)
+		return "", false
+	case !entry.done:
+		jsonError(w, fmt.Sprintf("Requisição com o mesmo %s ainda em andamento", idempotencyHeader), http.StatusConflict)
+		return "", false
+	default:
+		log.Printf("[DEBUG] %s '%s' repetido em %s; devolvendo o resultado guardado", idempotencyHeader, key, scope)
+		writeJSON(w, entry.status, entry.body)
+		return "", false
+	}
+}
+
+// Função para guardar a resposta de uma chave reservada. body deve ser a
+// versão marcada como replayed, devolvida nas repetições.
+func (s *idempotencyStore) complete(scope, key string, status int, body interface{}) {
+	if key == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[scope+"\x00"+key]; ok {
+		entry.status, entry.body, entry.done = status, body, true
+		entry.expiresAt = time.Now().Add(s.window)
+	}
+}
+
+// Função para liberar uma chave que não chegou a complete (erro antes de
+// executar, panic), para que a repetição execute normalmente
+func (s *idempotencyStore) release(scope, key string) {
+	if key == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[scope+"\x00"+key]; ok && !entry.done {
+		delete(s.entries, scope+"\x00"+key)
+	}
+}
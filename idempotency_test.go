@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKeyBoundToRequest(t *testing.T) {
+	store := newIdempotencyStore(time.Hour)
+	claim := func(method, target, body string) (*httptest.ResponseRecorder, string, bool) {
+		request := httptest.NewRequest(method, target, nil)
+		request.Header.Set(idempotencyHeader, "chave-1")
+		recorder := httptest.NewRecorder()
+		key, ok := store.claim(recorder, request, "due-dates:Time A", []byte(body))
+		return recorder, key, ok
+	}
+
+	_, key, ok := claim(http.MethodPost, "/due-dates?a=1&b=2", `[{"id": 1}]`)
+	if !ok || key != "chave-1" {
+		t.Fatalf("primeira reserva = %q, %t", key, ok)
+	}
+	store.complete("due-dates:Time A", key, http.StatusOK, map[string]bool{"replayed": true})
+
+	// Mesma query em outra ordem é o mesmo pedido
+	if recorder, _, ok := claim(http.MethodPost, "/due-dates?b=2&a=1", `[{"id": 1}]`); ok || recorder.Code != http.StatusOK {
+		t.Errorf("repetição idêntica: ok=%t status=%d, quer a resposta guardada", ok, recorder.Code)
+	}
+	for _, request := range []struct{ method, target, body string }{
+		{http.MethodPost, "/due-dates?a=1&b=2", `[{"id": 2}]`},
+		{http.MethodPost, "/due-dates?a=1&b=3", `[{"id": 1}]`},
+		{http.MethodPut, "/due-dates?a=1&b=2", `[{"id": 1}]`},
+	} {
+		if recorder, _, ok := claim(request.method, request.target, request.body); ok || recorder.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s %s %s com a mesma chave: ok=%t status=%d, quer 422", request.method, request.target, request.body, ok, recorder.Code)
+		}
+	}
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+)
+
+// sprintRef identifica a sprint pedida pelo cliente: pelo nome (?sprint=) ou
+// pelo GUID da iteração (?sprintId=), que evita ambiguidade de nomes
+type sprintRef struct {
+	Name string
+	ID   *uuid.UUID
+}
+
+func (ref sprintRef) String() string {
+	if ref.ID != nil {
+		return ref.ID.String()
+	}
+	return ref.Name
+}
+
+// Função para ler a sprint pedida na query string
+func sprintRefFromRequest(r *http.Request) (sprintRef, error) {
+	if value := r.URL.Query().Get("sprintId"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return sprintRef{}, fmt.Errorf("Parâmetro 'sprintId' inválido: %q", value)
+		}
+		return sprintRef{ID: &id}, nil
+	}
+	if name := r.URL.Query().Get("sprint"); name != "" {
+		return sprintRef{Name: name}, nil
+	}
+	return sprintRef{}, fmt.Errorf("Parâmetro 'sprint' (ou 'sprintId') é obrigatório")
+}
+
+// Limites da janela de /sprints (?past= e ?future=)
+const (
+	defaultSprintsPast   = 3
+	defaultSprintsFuture = 3
+	maxSprintsWindow     = 100
+)
+
+// sprintWindow diz quantas sprints antes e depois da atual /sprints devolve;
+// All desliga o filtro
+type sprintWindow struct {
+	Past   int
+	Future int
+	All    bool
+}
+
+// Função para ler a janela de sprints da query string (?past=N&future=M&all=true)
+func sprintWindowFromRequest(r *http.Request) (sprintWindow, error) {
+	window := sprintWindow{Past: defaultSprintsPast, Future: defaultSprintsFuture}
+	query := r.URL.Query()
+	if value := query.Get("all"); value != "" {
+		all, err := strconv.ParseBool(value)
+		if err != nil {
+			return window, fmt.Errorf("Parâmetro 'all' inválido: %q", value)
+		}
+		window.All = all
+	}
+	for _, param := range []struct {
+		name   string
+		target *int
+	}{{"past", &window.Past}, {"future", &window.Future}} {
+		value := query.Get(param.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > maxSprintsWindow {
+			return window, fmt.Errorf("Parâmetro '%s' inválido: %q (use um inteiro entre 0 e %d)", param.name, value, maxSprintsWindow)
+		}
+		*param.target = n
+	}
+	return window, nil
+}
+
+// Função para obter o dia de uma data de sprint. O Azure DevOps guarda só a
+// data, como meia-noite UTC, então o fuso configurado não se aplica aqui. O
+// mesmo vale para folgas e feriados.
+func sprintDate(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// Função para verificar se hoje, no fuso configurado, está entre o primeiro e
+// o último dia da sprint, inclusive. A data de término é o último dia da
+// sprint (meia-noite), por isso a comparação é por dia e não por horário.
+func isCurrentSprint(start, end, now time.Time, loc *time.Location) bool {
+	today := workCalendar{Location: loc}.dateOf(now)
+	return !today.Before(sprintDate(start)) && !today.After(sprintDate(end))
+}
+
+// Função para recortar as sprints (em ordem cronológica) na janela pedida.
+// Com a sprint atual, devolve a atual e até Past/Future ao redor dela; sem
+// ela, as Past últimas que já começaram e as Future próximas.
+func (window sprintWindow) apply(sprints []Sprint, currentIndex int, now time.Time, loc *time.Location) []Sprint {
+	if window.All {
+		return sprints
+	}
+	var start, end int
+	if currentIndex >= 0 {
+		start, end = currentIndex-window.Past, currentIndex+1+window.Future
+	} else {
+		today := workCalendar{Location: loc}.dateOf(now)
+		split := len(sprints)
+		for i, sprint := range sprints {
+			if !sprint.StartDate.IsZero() && sprintDate(sprint.StartDate).After(today) {
+				split = i
+				break
+			}
+		}
+		start, end = split-window.Past, split+window.Future
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > len(sprints) {
+		end = len(sprints)
+	}
+	return sprints[start:end]
+}
+
+// Nomes relativos aceitos em ?sprint=, resolvidos a partir da sprint atual
+const (
+	sprintCurrent  = "current"
+	sprintNext     = "next"
+	sprintPrevious = "previous"
+)
+
+// Função para localizar a iteração de uma sprintRef, pelo ID, por um nome
+// relativo (current, next, previous) ou pelo nome. É o único caminho usado
+// pelos handlers para achar uma sprint; a iteração devolvida sempre tem Name e
+// Id, e iterações antigas sem nome são ignoradas.
+func resolveSprintRef(ctx context.Context, workClient work.Client, cfg *Config, ref sprintRef) (*work.TeamSettingsIteration, error) {
+	if ref.ID == nil {
+		switch relative := strings.ToLower(strings.TrimSpace(ref.Name)); relative {
+		case sprintCurrent, sprintNext, sprintPrevious:
+			return resolveRelativeSprint(ctx, workClient, cfg, relative)
+		}
+		return resolveIteration(ctx, workClient, cfg, ref.Name)
+	}
+	iteration, err := workClient.GetTeamIteration(ctx, work.GetTeamIterationArgs{
+		Project: &cfg.Project,
+		Team:    &cfg.Team,
+		Id:      ref.ID,
+	})
+	if err != nil {
+		err = wrapAdoError(err, "GetTeamIteration", "team=%s, id=%s", cfg.TeamName, ref.ID)
+		if errors.Is(err, ErrAdoNotFound) {
+			return nil, &SprintNotFoundError{Name: ref.String()}
+		}
+		return nil, err
+	}
+	if iteration == nil || iteration.Name == nil || iteration.Id == nil {
+		return nil, &SprintNotFoundError{Name: ref.String()}
+	}
+	return iteration, nil
+}
+
+// Função para resolver current, next ou previous contra as iterações do time
+// (em ordem cronológica). A sprint atual segue a mesma regra de /sprints:
+// timeframe do Azure DevOps e, sem ele, as datas inclusivas. Sem sprint atual,
+// next é a primeira que ainda não começou e previous a última já encerrada.
+func resolveRelativeSprint(ctx context.Context, workClient work.Client, cfg *Config, relative string) (*work.TeamSettingsIteration, error) {
+	result, err := workClient.GetTeamIterations(ctx, work.GetTeamIterationsArgs{
+		Project: &cfg.Project,
+		Team:    &cfg.Team,
+	})
+	if err != nil {
+		return nil, wrapAdoError(err, "GetTeamIterations", "team=%s", cfg.TeamName)
+	}
+
+	var iterations []work.TeamSettingsIteration
+	if result != nil {
+		for _, iteration := range *result {
+			if iteration.Name != nil && iteration.Id != nil {
+				iterations = append(iterations, iteration)
+			}
+		}
+	}
+
+	now := time.Now()
+	today := workCalendar{Location: cfg.Location}.dateOf(now)
+	current := -1
+	for i, iteration := range iterations {
+		if iterationIsCurrent(iteration, now, cfg.Location) {
+			current = i
+			break
+		}
+	}
+
+	index := -1
+	switch {
+	case relative == sprintCurrent:
+		index = current
+	case current >= 0 && relative == sprintNext:
+		index = current + 1
+	case current >= 0 && relative == sprintPrevious:
+		index = current - 1
+	case relative == sprintNext:
+		for i, iteration := range iterations {
+			if iteration.Attributes != nil && iteration.Attributes.StartDate != nil &&
+				sprintDate(iteration.Attributes.StartDate.Time).After(today) {
+				index = i
+				break
+			}
+		}
+	case relative == sprintPrevious:
+		for i, iteration := range iterations {
+			if iteration.Attributes != nil && iteration.Attributes.FinishDate != nil &&
+				sprintDate(iteration.Attributes.FinishDate.Time).Before(today) {
+				index = i
+			}
+		}
+	}
+	if index < 0 || index >= len(iterations) {
+		return nil, &SprintNotFoundError{Name: relative}
+	}
+	log.Printf("[DEBUG] Sprint '%s' resolvida para '%s'", relative, *iterations[index].Name)
+	return &iterations[index], nil
+}
+
+// Função para obter o primeiro e o último dia de uma sprint; sprints sem
+// datas não permitem cálculo de dias úteis (ErrPlanInfeasible)
+func sprintDates(iteration *work.TeamSettingsIteration) (time.Time, time.Time, error) {
+	if iteration.Attributes == nil || iteration.Attributes.StartDate == nil || iteration.Attributes.FinishDate == nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: sprint '%s' sem datas de início e fim", ErrPlanInfeasible, *iteration.Name)
+	}
+	return sprintDate(iteration.Attributes.StartDate.Time), sprintDate(iteration.Attributes.FinishDate.Time), nil
+}
+
+// Função para verificar se uma iteração é a atual: pelo timeframe do Azure
+// DevOps ou, quando ele não vem, pelas datas
+func iterationIsCurrent(iteration work.TeamSettingsIteration, now time.Time, loc *time.Location) bool {
+	if iteration.Attributes == nil {
+		return false
+	}
+	if iteration.Attributes.TimeFrame != nil {
+		return *iteration.Attributes.TimeFrame == work.TimeFrameValues.Current
+	}
+	if iteration.Attributes.StartDate == nil || iteration.Attributes.FinishDate == nil {
+		return false
+	}
+	return isCurrentSprint(iteration.Attributes.StartDate.Time, iteration.Attributes.FinishDate.Time, now, loc)
+}
+
+// Função para devolver nos headers a sprint efetivamente usada, útil quando o
+// cliente pediu current, next ou previous
+func setSprintHeaders(w http.ResponseWriter, iteration *work.TeamSettingsIteration) {
+	if iteration.Id != nil {
+		w.Header().Set("X-Sprint-Id", iteration.Id.String())
+	}
+	if iteration.Name != nil {
+		w.Header().Set("X-Sprint-Name", *iteration.Name)
+	}
+	if iteration.Attributes != nil {
+		if iteration.Attributes.StartDate != nil {
+			w.Header().Set("X-Sprint-Start", iteration.Attributes.StartDate.Time.Format(time.RFC3339))
+		}
+		if iteration.Attributes.FinishDate != nil {
+			w.Header().Set("X-Sprint-End", iteration.Attributes.FinishDate.Time.Format(time.RFC3339))
+		}
+	}
+}
+
+// Função para localizar uma iteração do time pelo nome, pelo caminho completo
+// ("Projeto\Release 2\Sprint 42") ou pelo GUID. Nomes são comparados sem
+// diferenciar maiúsculas e ignorando espaços nas pontas.
+// Primeiro tenta o atalho Timeframe=current, que devolve uma única iteração;
+// depois procura entre as iterações que se sobrepõem à janela configurada ao
+// redor de hoje e só então considera o histórico completo. Assim, nomes
+// repetidos em anos anteriores não vencem a sprint recente. Um nome que
+// corresponde a mais de uma iteração na mesma etapa é ambíguo (409).
+func resolveIteration(ctx context.Context, workClient work.Client, cfg *Config, sprintName string) (*work.TeamSettingsIteration, error) {
+	wanted := strings.TrimSpace(sprintName)
+	if id, err := uuid.Parse(wanted); err == nil {
+		return resolveSprintRef(ctx, workClient, cfg, sprintRef{ID: &id})
+	}
+
+	timeframe := string(work.TimeFrameValues.Current)
+	current, err := workClient.GetTeamIterations(ctx, work.GetTeamIterationsArgs{
+		Project:   &cfg.Project,
+		Team:      &cfg.Team,
+		Timeframe: &timeframe,
+	})
+	if err != nil {
+		return nil, wrapAdoError(err, "GetTeamIterations", "team=%s, timeframe=current", cfg.TeamName)
+	}
+	if iteration, err := findIterationByName(current, wanted, nil); iteration != nil || err != nil {
+		return iteration, err
+	}
+
+	iterations, err := workClient.GetTeamIterations(ctx, work.GetTeamIterationsArgs{
+		Project: &cfg.Project,
+		Team:    &cfg.Team,
+	})
+	if err != nil {
+		return nil, wrapAdoError(err, "GetTeamIterations", "team=%s", cfg.TeamName)
+	}
+
+	window := time.Duration(cfg.SprintLookupWindowDays) * 24 * time.Hour
+	now := time.Now()
+	inWindow := func(iteration work.TeamSettingsIteration) bool {
+		if iteration.Attributes == nil || iteration.Attributes.StartDate == nil || iteration.Attributes.FinishDate == nil {
+			return false
+		}
+		start := iteration.Attributes.StartDate.Time
+		end := iteration.Attributes.FinishDate.Time
+		return !end.Before(now.Add(-window)) && !start.After(now.Add(window))
+	}
+	if iteration, err := findIterationByName(iterations, wanted, inWindow); iteration != nil || err != nil {
+		return iteration, err
+	}
+
+	// O nome não está na janela: recorre à lista completa
+	if iteration, err := findIterationByName(iterations, wanted, nil); iteration != nil || err != nil {
+		return iteration, err
+	}
+
+	return nil, &SprintNotFoundError{Name: sprintName, Candidates: closestSprintNames(iterations, wanted, maxSprintCandidates)}
+}
+
+// Função para procurar uma iteração pelo nome ou caminho, opcionalmente
+// restrita por um filtro. Correspondências exatas têm prioridade sobre as que
+// só diferem em maiúsculas; mais de uma no mesmo nível gera SprintAmbiguousError.
+func findIterationByName(iterations *[]work.TeamSettingsIteration, sprintName string, filter func(work.TeamSettingsIteration) bool) (*work.TeamSettingsIteration, error) {
+	if iterations == nil {
+		return nil, nil
+	}
+	byPath := strings.ContainsAny(sprintName, `\/`)
+	wantedPath := normalizeIterationPath(sprintName)
+
+	var exact, folded []work.TeamSettingsIteration
+	for _, iteration := range *iterations {
+		if iteration.Name == nil || iteration.Id == nil {
+			continue
+		}
+		if filter != nil && !filter(iteration) {
+			continue
+		}
+		if byPath {
+			if iteration.Path != nil && normalizeIterationPath(*iteration.Path) == wantedPath {
+				exact = append(exact, iteration)
+			}
+			continue
+		}
+		switch {
+		case *iteration.Name == sprintName:
+			exact = append(exact, iteration)
+		case strings.EqualFold(*iteration.Name, sprintName):
+			folded = append(folded, iteration)
+		}
+	}
+
+	matches := exact
+	if len(matches) == 0 {
+		matches = folded
+	}
+	switch len(matches) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &matches[0], nil
+	}
+	paths := make([]string, 0, len(matches))
+	for _, iteration := range matches {
+		if iteration.Path != nil {
+			paths = append(paths, *iteration.Path)
+		} else {
+			paths = append(paths, *iteration.Name)
+		}
+	}
+	return nil, &SprintAmbiguousError{Name: sprintName, Paths: paths}
+}
+
+// Função para normalizar um caminho de iteração para comparação: separadores
+// como "\", sem barras nas pontas, sem diferenciar maiúsculas e sem o nó
+// "Iteration" que algumas respostas do Azure DevOps incluem após o projeto
+func normalizeIterationPath(path string) string {
+	path = strings.ReplaceAll(strings.TrimSpace(path), "/", `\`)
+	path = strings.ToLower(strings.Trim(path, `\`))
+	if parts := strings.SplitN(path, `\`, 3); len(parts) == 3 && parts[1] == "iteration" {
+		path = parts[0] + `\` + parts[2]
+	}
+	return path
+}
+
+// Quantidade de sugestões no 404 de sprint não encontrada
+const maxSprintCandidates = 5
+
+// Função para sugerir os nomes de sprint mais parecidos com o pedido, pela
+// distância de edição (sem diferenciar maiúsculas)
+func closestSprintNames(iterations *[]work.TeamSettingsIteration, sprintName string, limit int) []string {
+	if iterations == nil {
+		return nil
+	}
+	wanted := strings.ToLower(sprintName)
+	type candidate struct {
+		name     string
+		distance int
+	}
+	seen := make(map[string]bool)
+	var candidates []candidate
+	for _, iteration := range *iterations {
+		if iteration.Name == nil || seen[*iteration.Name] {
+			continue
+		}
+		seen[*iteration.Name] = true
+		candidates = append(candidates, candidate{*iteration.Name, editDistance(strings.ToLower(*iteration.Name), wanted)})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].name < candidates[j].name
+	})
+	names := make([]string, 0, limit)
+	for i := 0; i < len(candidates) && i < limit; i++ {
+		names = append(names, candidates[i].name)
+	}
+	return names
+}
+
+// Função para calcular a distância de Levenshtein entre dois textos
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current := make([]int, len(rb)+1)
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(rb)]
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+)
+
+func TestResolveIterationCallPattern(t *testing.T) {
+	today := sprintDate(time.Now())
+	current := fakeIteration("Sprint 10", today.AddDate(0, 0, -3), today.AddDate(0, 0, 10), work.TimeFrameValues.Current)
+	recent := fakeIteration("Sprint 9", today.AddDate(0, 0, -17), today.AddDate(0, 0, -4), work.TimeFrameValues.Past)
+	oldNamesake := fakeIteration("Sprint 9", today.AddDate(-2, 0, 0), today.AddDate(-2, 0, 13), work.TimeFrameValues.Past)
+	oldPath := `Projeto\2022\Sprint 9`
+	oldNamesake.Path = &oldPath
+	legacy := fakeIteration("Sprint Legado", today.AddDate(-3, 0, 0), today.AddDate(-3, 0, 13), work.TimeFrameValues.Past)
+
+	tests := []struct {
+		name      string
+		sprint    string
+		want      *work.TeamSettingsIteration
+		wantErr   error
+		wantCalls []string
+	}{
+		{
+			name:      "sprint atual pelo atalho current",
+			sprint:    "Sprint 10",
+			want:      &current,
+			wantCalls: []string{"GetTeamIterations(current)"},
+		},
+		{
+			name:      "nome com espaços e maiúsculas",
+			sprint:    "  sprint 10 ",
+			want:      &current,
+			wantCalls: []string{"GetTeamIterations(current)"},
+		},
+		{
+			name:      "nome repetido fica com a sprint da janela",
+			sprint:    "Sprint 9",
+			want:      &recent,
+			wantCalls: []string{"GetTeamIterations(current)", "GetTeamIterations"},
+		},
+		{
+			name:      "caminho completo escolhe a antiga",
+			sprint:    `Projeto\2022\Sprint 9`,
+			want:      &oldNamesake,
+			wantCalls: []string{"GetTeamIterations(current)", "GetTeamIterations"},
+		},
+		{
+			name:      "fora da janela recorre ao histórico",
+			sprint:    "Sprint Legado",
+			want:      &legacy,
+			wantCalls: []string{"GetTeamIterations(current)", "GetTeamIterations"},
+		},
+		{
+			name:      "GUID busca a iteração direto",
+			sprint:    legacy.Id.String(),
+			want:      &legacy,
+			wantCalls: []string{"GetTeamIteration"},
+		},
+		{
+			name:      "inexistente",
+			sprint:    "Sprint 99",
+			wantErr:   ErrSprintNotFound,
+			wantCalls: []string{"GetTeamIterations(current)", "GetTeamIterations"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workClient := &fakeWorkClient{iterations: []work.TeamSettingsIteration{oldNamesake, legacy, recent, current}}
+			cfg := &Config{Project: "Projeto", Team: "Time", SprintLookupWindowDays: 90}
+
+			got, err := resolveIteration(context.Background(), workClient, cfg, tt.sprint)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("erro = %v, quer %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if *got.Id != *tt.want.Id {
+				t.Errorf("iteração = %s (%s), quer %s (%s)", *got.Name, *got.Path, *tt.want.Name, *tt.want.Path)
+			}
+			if !reflect.DeepEqual(workClient.calls, tt.wantCalls) {
+				t.Errorf("chamadas = %v, quer %v", workClient.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestResolveIterationAmbiguousInWindow(t *testing.T) {
+	today := sprintDate(time.Now())
+	first := fakeIteration("Sprint 5", today.AddDate(0, 0, -20), today.AddDate(0, 0, -7), work.TimeFrameValues.Past)
+	firstPath := `Projeto\Time A\Sprint 5`
+	first.Path = &firstPath
+	second := fakeIteration("Sprint 5", today.AddDate(0, 0, -20), today.AddDate(0, 0, -7), work.TimeFrameValues.Past)
+	secondPath := `Projeto\Time B\Sprint 5`
+	second.Path = &secondPath
+	workClient := &fakeWorkClient{iterations: []work.TeamSettingsIteration{first, second}}
+	cfg := &Config{Project: "Projeto", Team: "Time", SprintLookupWindowDays: 90}
+
+	_, err := resolveIteration(context.Background(), workClient, cfg, "Sprint 5")
+	var ambiguous *SprintAmbiguousError
+	if !errors.As(err, &ambiguous) || len(ambiguous.Paths) != 2 {
+		t.Fatalf("erro = %v, quer SprintAmbiguousError com os dois caminhos", err)
+	}
+}
+
+func TestIsCurrentSprint(t *testing.T) {
+	saoPaulo := mustLocation(t, "America/Sao_Paulo")
+	start, end := day(2024, 3, 4), day(2024, 3, 15)
+	nextStart := day(2024, 3, 18)
+	tests := []struct {
+		name string
+		now  time.Time
+		loc  *time.Location
+		want bool
+	}{
+		{name: "agora igual ao início", now: start, loc: time.UTC, want: true},
+		{name: "início em São Paulo à meia-noite local", now: time.Date(2024, 3, 4, 0, 0, 0, 0, saoPaulo), loc: saoPaulo, want: true},
+		{name: "véspera do início em São Paulo (já é dia 4 em UTC)", now: time.Date(2024, 3, 3, 22, 0, 0, 0, saoPaulo), loc: saoPaulo, want: false},
+		{name: "último dia às 15h", now: time.Date(2024, 3, 15, 15, 0, 0, 0, time.UTC), loc: time.UTC, want: true},
+		{name: "último dia às 15h em São Paulo", now: time.Date(2024, 3, 15, 15, 0, 0, 0, saoPaulo), loc: saoPaulo, want: true},
+		{name: "último dia às 23h em São Paulo (já é dia 16 em UTC)", now: time.Date(2024, 3, 15, 23, 0, 0, 0, saoPaulo), loc: saoPaulo, want: true},
+		{name: "último instante do último dia", now: time.Date(2024, 3, 15, 23, 59, 59, 0, time.UTC), loc: time.UTC, want: true},
+		{name: "dia seguinte ao fim", now: day(2024, 3, 16), loc: time.UTC, want: false},
+		{name: "fim de semana entre sprints", now: time.Date(2024, 3, 17, 12, 0, 0, 0, time.UTC), loc: time.UTC, want: false},
+		{name: "antes do início", now: time.Date(2024, 3, 3, 23, 59, 59, 0, time.UTC), loc: time.UTC, want: false},
+		{name: "fuso nil vale UTC", now: time.Date(2024, 3, 10, 8, 0, 0, 0, time.UTC), loc: nil, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCurrentSprint(start, end, tt.now, tt.loc); got != tt.want {
+				t.Errorf("isCurrentSprint(%s) = %t, quer %t", tt.now, got, tt.want)
+			}
+		})
+	}
+	// No intervalo entre as sprints nenhuma das duas é a atual
+	gap := time.Date(2024, 3, 16, 10, 0, 0, 0, time.UTC)
+	if isCurrentSprint(start, end, gap, time.UTC) || isCurrentSprint(nextStart, day(2024, 3, 29), gap, time.UTC) {
+		t.Error("uma sprint foi considerada atual no intervalo entre as duas")
+	}
+	if !isCurrentSprint(nextStart, day(2024, 3, 29), nextStart, time.UTC) {
+		t.Error("a sprint seguinte deveria ser a atual no primeiro dia dela")
+	}
+}
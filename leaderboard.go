@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+)
+
+// Quantidade padrão e máxima de sprints anteriores usadas na sequência (?lookback=)
+const (
+	defaultLeaderboardLookback = 5
+	maxLeaderboardLookback     = 12
+)
+
+// Desenvolvedor em GET /leaderboard. Só contam as User Stories fechadas com
+// data gravada por POST /generate-due-dates; as outras ficam em Excluded e
+// fora do percentual, para que evitar a geração não melhore o resultado.
+type LeaderboardEntry struct {
+	Rank      int    `json:"rank"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	Completed int    `json:"completed"`
+	OnTime    int    `json:"onTime"`
+	Late      int    `json:"late"`
+	// nil quando nenhuma User Story do desenvolvedor teve data gerada
+	OnTimePercent *float64 `json:"onTimePercent"`
+	// Fechadas sem data gerada, fora do cálculo
+	Excluded int `json:"excluded"`
+	// User Stories seguidas no prazo, da mais recente para trás, somando a
+	// sprint pedida e as anteriores de lookback
+	Streak int `json:"streak"`
+}
+
+// Resposta de GET /leaderboard
+type LeaderboardReport struct {
+	Sprint     string             `json:"sprint"`
+	SprintID   string             `json:"sprintId"`
+	Lookback   int                `json:"lookback"`
+	Developers []LeaderboardEntry `json:"developers"`
+	// Fechadas na sprint sem data gerada (fora do percentual) e sem responsável
+	ExcludedIds   []int    `json:"excludedIds"`
+	UnassignedIds []int    `json:"unassignedIds"`
+	Warnings      []string `json:"warnings"`
+}
+
+// Função para identificar o desenvolvedor pelo e-mail ou, sem ele, pelo nome
+func leaderboardKey(identity *Identity) string {
+	if identity.UniqueName != "" {
+		return strings.ToLower(identity.UniqueName)
+	}
+	return strings.ToLower(identity.DisplayName)
+}
+
+// Função para montar o ranking a partir da precisão da sprint pedida e das
+// anteriores (da mais antiga para a mais recente). No prazo é o mesmo
+// critério de /metrics/due-date-accuracy pela data gerada: fechar até o dia dela.
+func leaderboardEntries(current []AccuracyItem, history [][]AccuracyItem) ([]LeaderboardEntry, []int, []int) {
+	entries := make(map[string]*LeaderboardEntry)
+	entryOf := func(identity *Identity) *LeaderboardEntry {
+		key := leaderboardKey(identity)
+		if entries[key] == nil {
+			entries[key] = &LeaderboardEntry{Name: identity.DisplayName, Email: identity.UniqueName}
+		}
+		return entries[key]
+	}
+
+	excluded, unassigned := []int{}, []int{}
+	for _, item := range current {
+		if item.AssignedTo == nil {
+			unassigned = append(unassigned, item.ID)
+			continue
+		}
+		entry := entryOf(item.AssignedTo)
+		if item.GeneratedSlipDays == nil {
+			entry.Excluded++
+			excluded = append(excluded, item.ID)
+			continue
+		}
+		entry.Completed++
+		if *item.GeneratedSlipDays <= 0 {
+			entry.OnTime++
+		} else {
+			entry.Late++
+		}
+	}
+
+	// Sequência: as User Stories com data gerada de cada desenvolvedor em
+	// ordem de fechamento, contando para trás até a primeira atrasada
+	closedByKey := make(map[string][]AccuracyItem)
+	for _, sprint := range append(append([][]AccuracyItem{}, history...), current) {
+		for _, item := range sprint {
+			if item.AssignedTo != nil && item.GeneratedSlipDays != nil {
+				key := leaderboardKey(item.AssignedTo)
+				closedByKey[key] = append(closedByKey[key], item)
+			}
+		}
+	}
+	for key, entry := range entries {
+		items := closedByKey[key]
+		sort.SliceStable(items, func(i, j int) bool { return items[i].ClosedDate.Before(items[j].ClosedDate) })
+		for i := len(items) - 1; i >= 0 && *items[i].GeneratedSlipDays <= 0; i-- {
+			entry.Streak++
+		}
+		if entry.Completed > 0 {
+			percent := math.Round(float64(entry.OnTime)/float64(entry.Completed)*1000) / 10
+			entry.OnTimePercent = &percent
+		}
+	}
+
+	result := make([]LeaderboardEntry, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, *entry)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.OnTime != b.OnTime {
+			return a.OnTime > b.OnTime
+		}
+		if percentOf(a) != percentOf(b) {
+			return percentOf(a) > percentOf(b)
+		}
+		if a.Streak != b.Streak {
+			return a.Streak > b.Streak
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+	// Empates no prazo, no percentual e na sequência dividem a posição
+	for i := range result {
+		result[i].Rank = i + 1
+		if i > 0 && result[i].OnTime == result[i-1].OnTime && percentOf(result[i]) == percentOf(result[i-1]) && result[i].Streak == result[i-1].Streak {
+			result[i].Rank = result[i-1].Rank
+		}
+	}
+	return result, excluded, unassigned
+}
+
+// Função para ordenar pelo percentual, com nil abaixo de zero
+func percentOf(entry LeaderboardEntry) float64 {
+	if entry.OnTimePercent == nil {
+		return -1
+	}
+	return *entry.OnTimePercent
+}
+
+// GET /leaderboard?sprint=: por desenvolvedor, quantas User Stories fechadas
+// na sprint ficaram no prazo da data gerada e a sequência no prazo. Desligado
+// com LEADERBOARD_ENABLED=false (ou leaderboard: false no time).
+func handleLeaderboard(pool *adoPool, cfg *Config, runs *runStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !cfg.LeaderboardEnabled {
+			jsonError(w, fmt.Sprintf("Leaderboard desativado para o time '%s'", cfg.TeamName), http.StatusNotFound)
+			return
+		}
+		ref, err := sprintRefFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cal, err := calendarForRequest(cfg, r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		keepArea, err := areaPathFilterFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		lookback := defaultLeaderboardLookback
+		if value := r.URL.Query().Get("lookback"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 || parsed > maxLeaderboardLookback {
+				jsonError(w, fmt.Sprintf("Parâmetro 'lookback' inválido: %q (use um inteiro entre 0 e %d)", value, maxLeaderboardLookback), http.StatusBadRequest)
+				return
+			}
+			lookback = parsed
+		}
+		types, _ := typesFromRequest(cfg, r)
+
+		ctx := r.Context()
+		workClient, err := pool.Work(ctx, "leaderboard")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
+			return
+		}
+		targetIteration, err := resolveSprintRef(ctx, workClient, cfg, ref)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		sprintName := *targetIteration.Name
+		setSprintHeaders(w, targetIteration)
+		sprintStart, _, err := sprintDates(targetIteration)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		witClient, err := pool.WorkItems(ctx, "leaderboard")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+
+		// Sprints encerradas antes do início da pedida, só para a sequência
+		var previous []work.TeamSettingsIteration
+		if lookback > 0 {
+			previous, err = completedSprints(ctx, workClient, cfg, sprintStart, lookback)
+			if err != nil {
+				respondError(w, "Erro ao buscar sprints", err)
+				return
+			}
+		}
+
+		report := LeaderboardReport{Sprint: sprintName, SprintID: targetIteration.Id.String(), Lookback: lookback, Warnings: []string{}}
+		var history [][]AccuracyItem
+		var withoutDueDate []int
+		for _, iteration := range append(previous, *targetIteration) {
+			iteration := iteration
+			stories, _, missing, err := fetchSprintStories(ctx, workClient, witClient, cfg, &iteration, types, nil)
+			if err != nil {
+				respondError(w, fmt.Sprintf("Erro ao buscar User Stories da sprint '%s'", *iteration.Name), err)
+				return
+			}
+			accuracy, err := dueDateAccuracy(ctx, witClient, cfg, runs, &iteration, stories, keepArea, cal, defaultAccuracyTop)
+			if err != nil {
+				respondError(w, fmt.Sprintf("Erro ao buscar datas de fechamento da sprint '%s'", *iteration.Name), err)
+				return
+			}
+			if *iteration.Id == *targetIteration.Id {
+				report.Warnings = append(report.Warnings, missingWorkItemWarnings(missing)...)
+				report.Warnings = append(report.Warnings, accuracy.Warnings...)
+				withoutDueDate = accuracy.WithoutDueDateIds
+			}
+			history = append(history, accuracy.Items)
+		}
+		report.Developers, report.ExcludedIds, report.UnassignedIds = leaderboardEntries(history[len(history)-1], history[:len(history)-1])
+		// Fechadas sem data nenhuma também não contam
+		report.ExcludedIds = append(report.ExcludedIds, withoutDueDate...)
+		sort.Ints(report.ExcludedIds)
+		if len(report.ExcludedIds) > 0 {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%d User Stories fechadas sem data gerada ficaram fora do percentual: %s", len(report.ExcludedIds), formatItemIds(report.ExcludedIds, maxStaleItemsInWarning)))
+		}
+
+		log.Printf("[DEBUG] Leaderboard da sprint '%s': %d desenvolvedores, %d sprints anteriores na sequência", sprintName, len(report.Developers), len(previous))
+		writeJSON(w, http.StatusOK, report)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// Função para montar uma User Story fechada na precisão; slip nil é fechada sem data gerada
+func accuracyItem(id int, assignee string, closed int, slip *float64) AccuracyItem {
+	item := AccuracyItem{ID: id, ClosedDate: day(2024, 3, closed), GeneratedSlipDays: slip}
+	if assignee != "" {
+		item.AssignedTo = &Identity{DisplayName: assignee, UniqueName: assignee + "@example.com"}
+	}
+	return item
+}
+
+func TestLeaderboardEntries(t *testing.T) {
+	history := [][]AccuracyItem{
+		{accuracyItem(1, "Ana", 1, ptrFloat(0)), accuracyItem(2, "Bruno", 1, ptrFloat(0))},
+		{accuracyItem(3, "Ana", 5, ptrFloat(-1)), accuracyItem(4, "Bruno", 5, ptrFloat(2)), accuracyItem(5, "Carla", 5, ptrFloat(0))},
+	}
+	current := []AccuracyItem{
+		// Ordem de fechamento diferente da lista: a atrasada de Ana é a mais antiga
+		accuracyItem(12, "Ana", 13, ptrFloat(0)),
+		accuracyItem(11, "Ana", 11, ptrFloat(3)),
+		accuracyItem(13, "Bruno", 12, ptrFloat(0)),
+		accuracyItem(14, "Bruno", 12, nil),
+		accuracyItem(15, "Carla", 14, ptrFloat(-2)),
+		accuracyItem(16, "Davi", 14, nil),
+		accuracyItem(17, "", 14, ptrFloat(0)),
+	}
+
+	entries, excluded, unassigned := leaderboardEntries(current, history)
+	type row struct {
+		Rank, OnTime, Late, Excluded, Streak int
+		Percent                              float64
+	}
+	got := make(map[string]row)
+	var order []string
+	for _, entry := range entries {
+		got[entry.Name] = row{entry.Rank, entry.OnTime, entry.Late, entry.Excluded, entry.Streak, percentOf(entry)}
+		order = append(order, entry.Name)
+	}
+	want := map[string]row{
+		// Sequência de Ana parou na atrasada da sprint pedida
+		"Ana": {Rank: 3, OnTime: 1, Late: 1, Streak: 1, Percent: 50},
+		// A fechada sem data gerada não entra no percentual; a atrasada da
+		// sprint anterior encerra a sequência
+		"Bruno": {Rank: 2, OnTime: 1, Excluded: 1, Streak: 1, Percent: 100},
+		// Desempata com Bruno pela sequência, que vem da sprint anterior
+		"Carla": {Rank: 1, OnTime: 1, Streak: 2, Percent: 100},
+		"Davi":  {Rank: 4, Excluded: 1, Percent: -1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ranking = %+v, quer %+v", got, want)
+	}
+	if wantOrder := []string{"Carla", "Bruno", "Ana", "Davi"}; !reflect.DeepEqual(order, wantOrder) {
+		t.Errorf("ordem = %v, quer %v", order, wantOrder)
+	}
+	if !reflect.DeepEqual(excluded, []int{14, 16}) || !reflect.DeepEqual(unassigned, []int{17}) {
+		t.Errorf("excluídas = %v, sem responsável = %v", excluded, unassigned)
+	}
+}
+
+func TestLeaderboardEntriesTies(t *testing.T) {
+	current := []AccuracyItem{
+		accuracyItem(1, "Bruno", 11, ptrFloat(0)),
+		accuracyItem(2, "Ana", 12, ptrFloat(0)),
+		accuracyItem(3, "Carla", 12, ptrFloat(1)),
+	}
+	entries, _, _ := leaderboardEntries(current, nil)
+	var ranks []int
+	var names []string
+	for _, entry := range entries {
+		ranks = append(ranks, entry.Rank)
+		names = append(names, entry.Name)
+	}
+	if !reflect.DeepEqual(ranks, []int{1, 1, 3}) || !reflect.DeepEqual(names, []string{"Ana", "Bruno", "Carla"}) {
+		t.Errorf("posições = %v %v, quer empate de Ana e Bruno em 1º", ranks, names)
+	}
+}
+
+func TestLeaderboardRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		enabled    bool
+		query      string
+		wantStatus int
+	}{
+		{name: "desativado", query: "sprint=Sprint%207", wantStatus: http.StatusNotFound},
+		{name: "lookback acima do máximo", enabled: true, query: "sprint=Sprint%207&lookback=13", wantStatus: http.StatusBadRequest},
+		{name: "lookback negativo", enabled: true, query: "sprint=Sprint%207&lookback=-1", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{TeamName: "Time", LeaderboardEnabled: tt.enabled}
+			recorder := httptest.NewRecorder()
+			handleLeaderboard(nil, cfg, nil)(recorder, httptest.NewRequest(http.MethodGet, "/leaderboard?"+tt.query, nil))
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, quer %d: %s", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+		})
+	}
+}
@@ -2,12 +2,9 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -20,92 +17,237 @@ import (
 )
 
 type WorkItem struct {
-	ID      int        `json:"id"`
-	Title   string     `json:"title"`
-	Type    string     `json:"type"`
-	State   string     `json:"state"`
-	DueDate *time.Time `json:"dueDate"`
+	ID             int        `json:"id"`
+	Title          string     `json:"title"`
+	Type           string     `json:"type"`
+	State          string     `json:"state"`
+	DueDate        *time.Time `json:"dueDate"`
+	BacklogRank    *int       `json:"backlogRank"`
+	AssignedTo     *Identity  `json:"assignedTo"`
+	AssignedToName string     `json:"assignedToName"` // obsoleto: removido na próxima versão; use assignedTo.displayName
+	StoryPoints    *float64   `json:"storyPoints"`
+	StackRank      *float64   `json:"stackRank"`
+	Url            string     `json:"url"`
+	IterationPath  string     `json:"iterationPath"`
+	AreaPath       string     `json:"areaPath"`
+	// Coluna do quadro (System.BoardColumn e System.BoardColumnDone); omitidas
+	// quando o item não está em um quadro
+	BoardColumn     string `json:"boardColumn,omitempty"`
+	BoardColumnDone *bool  `json:"boardColumnDone,omitempty"`
+	// Microsoft.VSTS.CMMI.Blocked = Yes ou a tag de BLOCKED_TAG
+	Blocked     bool                   `json:"blocked"`
+	Removed     bool                   `json:"removed,omitempty"`
+	ExtraFields map[string]interface{} `json:"extraFields,omitempty"`
+	// Tasks filhas, só com ?expand=tasks em /user-stories; ponteiro para que
+	// uma User Story sem tasks devolva [] e a resposta padrão não mude
+	Tasks *[]Task `json:"tasks,omitempty"`
+	// Datas do ciclo de vida, só com ?expand=dates em /user-stories
+	Dates *WorkItemDates `json:"dates,omitempty"`
+	// Campo de onde DueDate foi lido (um de dueDateFields); fora do JSON
+	dueDateField string
+	// Revisão (System.Rev) lida, usada para detectar alterações concorrentes
+	revision int
+	// Tags (System.Tags) lidas; fora do JSON
+	tags []string
+	// Pai (System.Parent), zero quando não há; fora do JSON
+	parentID int
 }
 
 type Sprint struct {
 	ID        uuid.UUID `json:"id"`
 	Name      string    `json:"name"`
+	Path      string    `json:"path"`
 	StartDate time.Time `json:"startDate,omitempty"`
 	EndDate   time.Time `json:"endDate,omitempty"`
+	Timeframe string    `json:"timeframe"` // past, current ou future, calculado pelo Azure DevOps
 	IsCurrent bool      `json:"isCurrent"`
+	// Última geração de datas (fora dry-run) registrada para a sprint
+	LastGeneratedAt *time.Time `json:"lastGeneratedAt,omitempty"`
 }
 
 type Task struct {
-	ID          int    `json:"id"`
-	Title       string `json:"title"`
-	State       string `json:"state"`
-	Description string `json:"description"`
-	AssignedTo  string `json:"assignedTo"`
+	ID               int                    `json:"id"`
+	Title            string                 `json:"title"`
+	State            string                 `json:"state"`
+	Description      string                 `json:"description"`
+	AssignedTo       *Identity              `json:"assignedTo"`
+	AssignedToName   string                 `json:"assignedToName"` // obsoleto: removido na próxima versão; use assignedTo.displayName
+	Url              string                 `json:"url"`
+	IterationPath    string                 `json:"iterationPath"`
+	Activity         string                 `json:"activity"`
+	RemainingWork    *float64               `json:"remainingWork"`
+	OriginalEstimate *float64               `json:"originalEstimate"`
+	CompletedWork    *float64               `json:"completedWork"`
+	Removed          bool                   `json:"removed,omitempty"`
+	ExtraFields      map[string]interface{} `json:"extraFields,omitempty"`
 }
 
+// Identity é uma identidade do Azure DevOps (System.AssignedTo e afins).
+// UniqueName (e-mail/UPN) distingue pessoas com o mesmo nome de exibição.
+type Identity struct {
+	DisplayName string `json:"displayName"`
+	UniqueName  string `json:"uniqueName"`
+	ImageUrl    string `json:"imageUrl"`
+	Descriptor  string `json:"descriptor,omitempty"`
+}
+
+// DayOff é um intervalo de folga. Fraction indica a parte de cada dia do
+// intervalo em folga (0.5 = meio período); zero vale como dia inteiro.
 type DayOff struct {
-	Start time.Time `json:"start"`
-	End   time.Time `json:"end"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Fraction float64   `json:"fraction,omitempty"`
+}
+
+// Função para obter a parte do dia em folga, tratando zero como dia inteiro
+func (d DayOff) portion() float64 {
+	if d.Fraction <= 0 || d.Fraction >= 1 {
+		return 1
+	}
+	return d.Fraction
 }
 
 type TeamMemberCapacity struct {
-	Activities []struct {
-		CapacityPerDay float64 `json:"capacityPerDay"`
-		Name           string  `json:"name"`
-	} `json:"activities"`
-	DaysOff []DayOff `json:"daysOff"`
+	Name       string             `json:"name"`
+	Email      string             `json:"email"`
+	Activities []CapacityActivity `json:"activities"`
+	DaysOff    []DayOff           `json:"daysOff"`
 }
 
+// Developer traz a capacidade total (CapacityPerDay, soma de todas as
+// atividades) e a quebra por atividade. Tasks sem atividade contam só no total.
+// Email é o uniqueName do responsável; quando ele falta (grupos, identidades
+// antigas) vem o descriptor e Mailable fica false. AllocatedHours soma o
+// RemainingWork das tasks abertas; Utilization é AllocatedHours/TotalCapacity
+// (null sem capacidade) e ignora as UnestimatedTasks, sem RemainingWork.
 type Developer struct {
-	Name           string  `json:"name"`
-	Email          string  `json:"email"`
-	Tasks          int     `json:"tasks"`
-	CapacityPerDay float64 `json:"capacityPerDay"`
-	TotalCapacity  float64 `json:"totalCapacity"`
-	DaysOff        int     `json:"daysOff"`
+	Name               string             `json:"name"`
+	Email              string             `json:"email"`
+	Mailable           bool               `json:"mailable"`
+	Tasks              int                `json:"tasks"`
+	TasksByActivity    map[string]int     `json:"tasksByActivity"`
+	CapacityPerDay     float64            `json:"capacityPerDay"`
+	CapacityByActivity map[string]float64 `json:"capacityByActivity"`
+	TotalCapacity      float64            `json:"totalCapacity"`
+	DaysOff            float64            `json:"daysOff"`
+	AllocatedHours     float64            `json:"allocatedHours"`
+	Utilization        *float64           `json:"utilization"`
+	UnestimatedTasks   int                `json:"unestimatedTasks"`
+	// Com GRANULARITY=days: dias inteiros em que está disponível na sprint e
+	// dias estimados nas tasks abertas (DAY_ESTIMATE_FIELD, arredondados para
+	// cima). Utilization e a sobrealocação passam a ser em dias.
+	CapacityDays  *float64 `json:"capacityDays,omitempty"`
+	AllocatedDays *float64 `json:"allocatedDays,omitempty"`
+	// Sobrealocado quando AllocatedHours passa de TotalCapacity acrescida da
+	// tolerância; OverAllocationHours é o excesso sobre TotalCapacity
+	OverAllocated       bool    `json:"overAllocated"`
+	OverAllocationHours float64 `json:"overAllocationHours"`
+	OverAllocationDays  float64 `json:"overAllocationDays,omitempty"`
+	// Período em que está no time dentro da sprint, quando a janela de
+	// AVAILABILITY_FILE recorta o início ou o fim
+	EffectiveStart *time.Time `json:"effectiveStart,omitempty"`
+	EffectiveEnd   *time.Time `json:"effectiveEnd,omitempty"`
+	// Só com ?detail=true: tasks do desenvolvedor agrupadas por User Story
+	UserStories []DeveloperUserStory `json:"userStories,omitempty"`
+}
+
+// Parte da carga de um desenvolvedor que vem de uma User Story
+type DeveloperUserStory struct {
+	UserStoryID    int     `json:"userStoryId"`
+	UserStoryTitle string  `json:"userStoryTitle"`
+	TaskCount      int     `json:"taskCount"`
+	RemainingWork  float64 `json:"remainingWork"`
 }
 
 type DevelopersResponse struct {
-	Developers    []Developer `json:"developers"`
-	SprintStart   time.Time   `json:"sprintStart"`
-	SprintEnd     time.Time   `json:"sprintEnd"`
-	TotalCapacity float64     `json:"totalCapacity"`
-	TotalDaysOff  int         `json:"totalDaysOff"`
-	WorkingDays   int         `json:"workingDays"`
+	Developers     []Developer `json:"developers"`
+	Sprint         string      `json:"sprint"`
+	SprintStart    time.Time   `json:"sprintStart"`
+	SprintEnd      time.Time   `json:"sprintEnd"`
+	TotalCapacity  float64     `json:"totalCapacity"`
+	AllocatedHours float64     `json:"allocatedHours"`
+	Utilization    *float64    `json:"utilization"`
+	// Unidade de utilization (GRANULARITY) e, em days, os totais em dias
+	Granularity      string    `json:"granularity,omitempty"`
+	CapacityDays     *float64  `json:"capacityDays,omitempty"`
+	AllocatedDays    *float64  `json:"allocatedDays,omitempty"`
+	UnestimatedTasks int       `json:"unestimatedTasks"`
+	TotalDaysOff     float64   `json:"totalDaysOff"`
+	WorkingDays      float64   `json:"workingDays"`
+	TeamDaysOff      []DayOff  `json:"teamDaysOff"`
+	Holidays         []Holiday `json:"holidays"`
+	Warnings         []string  `json:"warnings,omitempty"`
 }
 
-func getFieldValue(fields *map[string]interface{}, fieldName string) string {
-	if fields == nil {
-		return ""
+// Política do GetWorkItems que devolve null para itens excluídos (lixeira)
+// em vez de falhar a chamada inteira
+var omitMissingWorkItems = workitemtracking.WorkItemErrorPolicyValues.Omit
+
+// Função para descartar os work items que foram excluídos entre a listagem
+// e a busca de detalhes. Com a política Omit, o ADO devolve uma entrada
+// vazia na mesma posição do ID solicitado.
+func presentWorkItems(requestedIds []int, workItems *[]workitemtracking.WorkItem) []workitemtracking.WorkItem {
+	present := make([]workitemtracking.WorkItem, 0)
+	if workItems == nil {
+		return present
 	}
-	if value, ok := (*fields)[fieldName]; ok {
-		// Log para debug
-		log.Printf("Campo %s encontrado com tipo %T e valor %v", fieldName, value, value)
-
-		switch v := value.(type) {
-		case string:
-			return v
-		case map[string]interface{}:
-			// Para campos complexos, tenta obter o displayName ou value
-			if displayName, ok := v["displayName"].(string); ok {
-				return displayName
-			}
-			if val, ok := v["value"].(string); ok {
-				return val
+	for i, workItem := range *workItems {
+		if workItem.Id == nil {
+			if i < len(requestedIds) {
+				log.Printf("[WARN] Work item #%d não encontrado (provavelmente excluído), ignorando", requestedIds[i])
 			}
+			continue
 		}
-		// Se não conseguir converter, converte para string
-		return fmt.Sprintf("%v", value)
+		present = append(present, workItem)
 	}
-	return ""
+	return present
+}
+
+// Função para listar os IDs pedidos que voltaram vazios do GetWorkItems
+func missingWorkItemIds(requestedIds []int, workItems *[]workitemtracking.WorkItem) []int {
+	var missing []int
+	if workItems == nil {
+		return missing
+	}
+	for i, workItem := range *workItems {
+		if workItem.Id == nil && i < len(requestedIds) {
+			missing = append(missing, requestedIds[i])
+		}
+	}
+	return missing
+}
+
+// Função para montar os avisos dos work items que sumiram entre a listagem e
+// a busca de detalhes, para que a resposta mostre o que ficou de fora.
+// Nunca devolve nil, para servir de valor inicial de Warnings.
+func missingWorkItemWarnings(missing []int) []string {
+	warnings := make([]string, 0, len(missing))
+	for _, id := range missing {
+		warnings = append(warnings, fmt.Sprintf("Work item #%d não encontrado (provavelmente excluído durante a consulta); ignorado", id))
+	}
+	return warnings
+}
+
+// Função para informar os work items ignorados nas respostas que são só uma
+// lista (sem campo warnings): X-Missing-Work-Items com os IDs separados por vírgula
+func setMissingWorkItemsHeader(w http.ResponseWriter, missing []int) {
+	if len(missing) == 0 {
+		return
+	}
+	ids := make([]string, len(missing))
+	for i, id := range missing {
+		ids[i] = strconv.Itoa(id)
+	}
+	w.Header().Set("X-Missing-Work-Items", strings.Join(ids, ","))
 }
 
 // Middleware para adicionar headers CORS
 func enableCors(handler http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-Admin-Key, X-Requested-By, Idempotency-Key")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Sprint-Id, X-Sprint-Name, X-Sprint-Start, X-Sprint-End, X-Search-Truncated")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -149,31 +291,65 @@ func parseDate(dateStr string) (time.Time, error) {
 	return time.Time{}, fmt.Errorf("formato de data não reconhecido: %s", dateStr)
 }
 
-// Função para calcular dias úteis entre duas datas
-func calculateWorkingDays(start, end time.Time, daysOff []DayOff) int {
-	workingDays := 0
-	current := start
-
-	for current.Before(end) || current.Equal(end) {
-		// Verifica se é fim de semana
-		if current.Weekday() != time.Saturday && current.Weekday() != time.Sunday {
-			// Verifica se é um dia de folga
-			isDayOff := false
-			for _, off := range daysOff {
-				if (current.Equal(off.Start) || current.After(off.Start)) &&
-					(current.Equal(off.End) || current.Before(off.End)) {
-					isDayOff = true
-					break
-				}
-			}
-			if !isDayOff {
-				workingDays++
-			}
-		}
-		current = current.Add(24 * time.Hour)
+// Função para aquecer o Azure DevOps em segundo plano (PREFETCH): pelo pool,
+// no limite de ADO_MAX_CONCURRENCY, cria os clientes de work, work item
+// tracking e core, resolve a sprint atual do time e busca os work items, as
+// capacidades e as configurações do time, pagando antes da primeira requisição
+// a descoberta de recursos e de rotas do SDK. Roda ao iniciar e de novo quando
+// o pool descarta os clientes (reconnect). Erros são apenas registrados em log.
+func prefetchCurrentSprint(pool *adoPool, cfg *Config) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.RequestTimeout)
+	defer cancel()
+
+	workClient, err := pool.Work(ctx, "prefetch")
+	if err != nil {
+		log.Printf("[PREFETCH] Erro ao criar cliente do Azure DevOps: %v", err)
+		return
+	}
+	witClient, err := pool.WorkItems(ctx, "prefetch")
+	if err != nil {
+		log.Printf("[PREFETCH] Erro ao criar cliente de work items: %v", err)
+		return
+	}
+	if _, err := pool.Core(ctx, "prefetch"); err != nil {
+		log.Printf("[PREFETCH] Erro ao criar cliente core: %v", err)
+		return
+	}
+
+	if _, err := workClient.GetTeamSettings(ctx, work.GetTeamSettingsArgs{
+		Project: &cfg.Project,
+		Team:    &cfg.Team,
+	}); err != nil {
+		log.Printf("[PREFETCH] Erro ao buscar configurações do time: %v", err)
+		return
 	}
 
-	return workingDays
+	current, err := resolveSprintRef(ctx, workClient, cfg, sprintRef{Name: sprintCurrent})
+	if err != nil {
+		log.Printf("[PREFETCH] Sprint atual do time %s não resolvida: %v", cfg.TeamName, err)
+		return
+	}
+	workItemsResponse, err := workClient.GetIterationWorkItems(ctx, work.GetIterationWorkItemsArgs{
+		Project:     &cfg.Project,
+		Team:        &cfg.Team,
+		IterationId: current.Id,
+	})
+	if err != nil {
+		log.Printf("[PREFETCH] Erro ao buscar work items da sprint '%s': %v", *current.Name, err)
+		return
+	}
+	workItemIds := iterationWorkItemIds(workItemsResponse)
+	if _, err := getWorkItemsChunked(ctx, witClient, cfg.Project, workItemIds, userStoryFields); err != nil {
+		log.Printf("[PREFETCH] Erro ao buscar detalhes dos work items: %v", err)
+		return
+	}
+	if _, err := fetchTeamCapacities(ctx, workClient, cfg, current); err != nil {
+		log.Printf("[PREFETCH] Erro ao buscar capacidades da sprint '%s': %v", *current.Name, err)
+		return
+	}
+
+	log.Printf("[PREFETCH] Sprint '%s' pré-carregada (%d work items) em %v", *current.Name, len(workItemIds), time.Since(start))
 }
 
 func main() {
@@ -182,23 +358,80 @@ func main() {
 		log.Fatal("Erro ao carregar arquivo .env")
 	}
 
-	pat := os.Getenv("AZURE_DEVOPS_PAT")
-	organization := os.Getenv("AZURE_DEVOPS_ORG")
-	project := os.Getenv("AZURE_DEVOPS_PROJECT")
-	team := os.Getenv("AZURE_DEVOPS_TEAM")
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	connection := azuredevops.NewPatConnection(cfg.Organization, cfg.PAT.Value())
+	// Todas as chamadas ao Azure DevOps passam pelo pool, inclusive as de segundo plano
+	pool := newAdoPool(connection, cfg.AdoMaxConcurrency)
+
+	// Segredos vindos de *_FILE são relidos quando o Azure DevOps recusa o PAT
+	// e, com SECRETS_RELOAD_INTERVAL, periodicamente
+	if cfg.hasSecretFiles() {
+		refresher := &secretRefresher{cfg: cfg, pool: pool}
+		pool.onAuthError = refresher.onAuthError
+		if cfg.SecretsReloadInterval > 0 {
+			go refresher.run(cfg.SecretsReloadInterval)
+		}
+	}
+
+	// Corrige espaços e maiúsculas no nome do time e passa a usar o ID dele
+	resolveCtx, cancelResolve := context.WithTimeout(context.Background(), 30*time.Second)
+	err = resolveTeam(resolveCtx, pool, cfg)
+	cancelResolve()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	if pat == "" || organization == "" || project == "" || team == "" {
-		log.Fatal("Todas as variáveis de ambiente são obrigatórias: AZURE_DEVOPS_PAT, AZURE_DEVOPS_ORG, AZURE_DEVOPS_PROJECT, AZURE_DEVOPS_TEAM")
+	// Descarta campos extras inexistentes antes de atender requisições
+	validateCtx, cancelValidate := context.WithTimeout(context.Background(), 30*time.Second)
+	validateExtraFields(validateCtx, pool, cfg)
+	cancelValidate()
+
+	// Configuração de cada time atendido (?team=): a do ambiente e, com
+	// TEAMS_CONFIG, os ajustes do arquivo, relido com SIGHUP
+	teamsCtx, cancelTeams := context.WithTimeout(context.Background(), 30*time.Second)
+	teams, err := newTeamConfigs(teamsCtx, pool, cfg)
+	cancelTeams()
+	if err != nil {
+		log.Fatal(err)
 	}
+	go reloadTeamsOnSignal(teams)
 
-	connection := azuredevops.NewPatConnection(organization, pat)
+	// Registro das execuções que gravam datas, para rollback
+	runs, err := newRunStore(cfg.DueDateRunsFile, runRetention{
+		MaxRuns: cfg.RunsRetentionCount,
+		MaxAge:  time.Duration(cfg.RunsRetentionDays) * 24 * time.Hour,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Respostas guardadas por Idempotency-Key nos POSTs que gravam datas
+	idempotency := newIdempotencyStore(cfg.IdempotencyWindow)
+
+	// Canais de notificação (log sempre; webhook com NOTIFY_WEBHOOK_URL)
+	events := newNotifierSet(cfg)
 
 	// Endpoint para listar sprints
 	http.HandleFunc("/sprints", enableCors(func(w http.ResponseWriter, r *http.Request) {
-		ctx := context.Background()
-		workClient, err := work.NewClient(ctx, connection)
+		cfg, ok := teams.forRequest(w, r)
+		if !ok {
+			return
+		}
+		project, team := cfg.Project, cfg.Team
+
+		window, err := sprintWindowFromRequest(r)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Erro ao criar cliente do Azure DevOps: %v", err), http.StatusInternalServerError)
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		workClient, err := pool.Work(ctx, "sprints")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
 			return
 		}
 
@@ -207,17 +440,18 @@ func main() {
 			Team:    &team,
 		})
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Erro ao buscar sprints: %v", err), http.StatusInternalServerError)
+			respondError(w, "Erro ao buscar sprints", wrapAdoError(err, "GetTeamIterations", "team=%s", cfg.TeamName))
 			return
 		}
 
 		var allSprints []Sprint
 		var currentSprintIndex int = -1
 		now := time.Now()
+		lastGenerated := runs.lastGenerated(cfg.Team)
 
 		if iterations != nil && len(*iterations) > 0 {
 			// Primeiro, vamos converter todas as iterações em sprints e identificar a atual
-			for i, iteration := range *iterations {
+			for _, iteration := range *iterations {
 				if iteration.Name == nil {
 					continue
 				}
@@ -226,12 +460,15 @@ func main() {
 					Name: *iteration.Name,
 				}
 
-				if iteration.Path != nil {
-					iterationID, err := uuid.Parse(*iteration.Path)
-					if err == nil {
-						sprint.ID = iterationID
+				if iteration.Id != nil {
+					sprint.ID = *iteration.Id
+					if generatedAt, ok := lastGenerated[sprint.ID.String()]; ok {
+						sprint.LastGeneratedAt = &generatedAt
 					}
 				}
+				if iteration.Path != nil {
+					sprint.Path = *iteration.Path
+				}
 
 				if iteration.Attributes != nil {
 					if iteration.Attributes.StartDate != nil {
@@ -241,334 +478,98 @@ func main() {
 						sprint.EndDate = time.Time(iteration.Attributes.FinishDate.Time)
 					}
 
-					// Verifica se é a sprint atual
-					if !sprint.StartDate.IsZero() && !sprint.EndDate.IsZero() {
-						if now.After(sprint.StartDate) && now.Before(sprint.EndDate) {
-							sprint.IsCurrent = true
-							currentSprintIndex = i
-						}
+					if iteration.Attributes.TimeFrame != nil {
+						sprint.Timeframe = string(*iteration.Attributes.TimeFrame)
+					}
+				}
+				// O timeframe do Azure DevOps é a fonte principal para a sprint
+				// atual; as datas só são comparadas quando ele não vem
+				sprint.IsCurrent = iterationIsCurrent(iteration, now, cfg.Location)
+
+				// Com iterações sobrepostas, só a primeira atual conta
+				if sprint.IsCurrent {
+					if currentSprintIndex < 0 {
+						currentSprintIndex = len(allSprints)
+					} else {
+						sprint.IsCurrent = false
 					}
 				}
 
 				allSprints = append(allSprints, sprint)
 			}
 
-			// Se encontramos a sprint atual, vamos filtrar para mostrar apenas 3 antes e 3 depois
-			var filteredSprints []Sprint
-			if currentSprintIndex >= 0 {
-				startIndex := currentSprintIndex - 3
-				if startIndex < 0 {
-					startIndex = 0
-				}
-				endIndex := currentSprintIndex + 4 // +4 porque o slice é exclusivo no final
-				if endIndex > len(allSprints) {
-					endIndex = len(allSprints)
-				}
-				filteredSprints = allSprints[startIndex:endIndex]
-			} else {
-				// Se não encontrou a sprint atual, retorna as últimas 7 sprints
-				if len(allSprints) > 7 {
-					filteredSprints = allSprints[len(allSprints)-7:]
-				} else {
-					filteredSprints = allSprints
-				}
+			// Filtra a janela pedida ao redor da sprint atual (padrão 3 antes e 3 depois)
+			filteredSprints := window.apply(allSprints, currentSprintIndex, now, cfg.Location)
+			if filteredSprints == nil {
+				filteredSprints = []Sprint{}
 			}
 
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(filteredSprints)
+			writeJSON(w, http.StatusOK, filteredSprints)
 		} else {
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode([]Sprint{})
+			writeJSON(w, http.StatusOK, []Sprint{})
 		}
 	}))
 
-	// Função para retornar erro em formato JSON
-	jsonError := func(w http.ResponseWriter, message string, code int) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(code)
-		json.NewEncoder(w).Encode(map[string]string{"error": message})
-	}
-
 	http.HandleFunc("/user-stories", enableCors(func(w http.ResponseWriter, r *http.Request) {
-		sprintName := r.URL.Query().Get("sprint")
-		if sprintName == "" {
-			jsonError(w, "Parâmetro 'sprint' é obrigatório", http.StatusBadRequest)
+		cfg, ok := teams.forRequest(w, r)
+		if !ok {
 			return
 		}
+		project, team := cfg.Project, cfg.Team
 
-		ctx := context.Background()
-		workClient, err := work.NewClient(ctx, connection)
+		ref, err := sprintRefFromRequest(r)
 		if err != nil {
-			log.Printf("Erro ao criar cliente do Azure DevOps: %v", err)
-			jsonError(w, fmt.Sprintf("Erro ao criar cliente do Azure DevOps: %v", err), http.StatusInternalServerError)
+			jsonError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		// Buscar o ID da sprint pelo nome
-		iterations, err := workClient.GetTeamIterations(ctx, work.GetTeamIterationsArgs{
-			Project: &project,
-			Team:    &team,
-		})
+		keepState, err := stateFilterFromRequest(r)
 		if err != nil {
-			log.Printf("Erro ao buscar sprints: %v", err)
-			jsonError(w, fmt.Sprintf("Erro ao buscar sprints: %v", err), http.StatusInternalServerError)
+			jsonError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		var targetIteration *work.TeamSettingsIteration
-		for _, iteration := range *iterations {
-			if *iteration.Name == sprintName {
-				targetIteration = &iteration
-				break
-			}
-		}
-
-		if targetIteration == nil {
-			jsonError(w, fmt.Sprintf("Sprint '%s' não encontrada", sprintName), http.StatusNotFound)
-			return
-		}
-
-		// Buscar work items da sprint
-		workItemsResponse, err := workClient.GetIterationWorkItems(ctx, work.GetIterationWorkItemsArgs{
-			Project:     &project,
-			Team:        &team,
-			IterationId: targetIteration.Id,
-		})
+		keepArea, err := areaPathFilterFromRequest(r)
 		if err != nil {
-			log.Printf("Erro ao buscar work items da sprint: %v", err)
-			jsonError(w, fmt.Sprintf("Erro ao buscar work items: %v", err), http.StatusInternalServerError)
+			jsonError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		// Criar cliente para buscar detalhes dos work items
-		witClient, err := workitemtracking.NewClient(ctx, connection)
-		if err != nil {
-			log.Printf("Erro ao criar cliente de work items: %v", err)
-			jsonError(w, fmt.Sprintf("Erro ao criar cliente de work items: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		var workItemIds []int
-		if workItemsResponse != nil && workItemsResponse.WorkItemRelations != nil {
-			for _, relation := range *workItemsResponse.WorkItemRelations {
-				if relation.Target != nil && relation.Target.Id != nil {
-					workItemIds = append(workItemIds, *relation.Target.Id)
-				}
-			}
-		}
-
-		result := make([]WorkItem, 0)
-		if len(workItemIds) > 0 {
-			log.Printf("Buscando detalhes para %d work items", len(workItemIds))
-			workItems, err := witClient.GetWorkItems(ctx, workitemtracking.GetWorkItemsArgs{
-				Ids: &workItemIds,
-				Fields: &[]string{
-					"System.Title",
-					"System.WorkItemType",
-					"System.State",
-					"Microsoft.VSTS.Scheduling.DueDate",
-					"Microsoft.VSTS.Scheduling.TargetDate",
-					"System.BoardColumn",
-				},
-				Project: &project,
-			})
-
-			if err != nil {
-				log.Printf("Erro ao buscar detalhes dos work items: %v", err)
-				jsonError(w, fmt.Sprintf("Erro ao buscar detalhes dos work items: %v", err), http.StatusInternalServerError)
+		expandTasks, expandDates := false, false
+		for _, expand := range splitList(r.URL.Query().Get("expand")) {
+			switch expand {
+			case "tasks":
+				expandTasks = true
+			case "dates":
+				expandDates = true
+			default:
+				jsonError(w, fmt.Sprintf("Parâmetro 'expand' inválido: %q (use tasks, dates ou os dois separados por vírgula)", expand), http.StatusBadRequest)
 				return
 			}
-
-			for _, detail := range *workItems {
-				workItemType := getFieldValue(detail.Fields, "System.WorkItemType")
-				if workItemType == "User Story" {
-					log.Printf("Processando User Story #%d", *detail.Id)
-
-					item := WorkItem{
-						ID:      *detail.Id,
-						Title:   getFieldValue(detail.Fields, "System.Title"),
-						Type:    workItemType,
-						State:   getFieldValue(detail.Fields, "System.State"),
-						DueDate: nil,
-					}
-
-					// Log dos campos disponíveis
-					log.Printf("=== Campos disponíveis para US #%d ===", *detail.Id)
-					for fieldName, fieldValue := range *detail.Fields {
-						log.Printf("[DEBUG] Campo %s = %v (tipo: %T)", fieldName, fieldValue, fieldValue)
-					}
-
-					// Tentar obter a data de diferentes campos
-					dateFields := []string{
-						"Microsoft.VSTS.Scheduling.DueDate",
-						"Microsoft.VSTS.Scheduling.TargetDate",
-						"Microsoft.VSTS.Common.DueDate",
-					}
-
-					var dueDateStr string
-					for _, field := range dateFields {
-						dueDateStr = getFieldValue(detail.Fields, field)
-						if dueDateStr != "" {
-							log.Printf("[DEBUG] Data encontrada no campo %s para US #%d: %s", field, *detail.Id, dueDateStr)
-							break
-						}
-					}
-
-					if dueDateStr != "" {
-						log.Printf("[DEBUG] Tentando converter data '%s' para US #%d", dueDateStr, *detail.Id)
-						if dueDate, err := parseDate(dueDateStr); err == nil {
-							item.DueDate = &dueDate
-							log.Printf("[DEBUG] Data convertida com sucesso para US #%d: %v", *detail.Id, dueDate)
-						} else {
-							log.Printf("[ERROR] Erro ao converter data '%s' para US #%d: %v", dueDateStr, *detail.Id, err)
-						}
-					} else {
-						log.Printf("[DEBUG] Nenhuma data encontrada para US #%d nos campos: %v", *detail.Id, dateFields)
-					}
-
-					result = append(result, item)
-				}
-			}
 		}
-
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(result); err != nil {
-			log.Printf("Erro ao codificar resposta JSON: %v", err)
-			jsonError(w, "Erro ao processar resposta", http.StatusInternalServerError)
+		format, ok := descriptionFormatFromQuery(r.URL.Query().Get("format"))
+		if !ok {
+			jsonError(w, fmt.Sprintf("Parâmetro 'format' inválido: %q (use text ou html)", r.URL.Query().Get("format")), http.StatusBadRequest)
 			return
 		}
-	}))
-
-	http.HandleFunc("/user-story-tasks/", enableCors(func(w http.ResponseWriter, r *http.Request) {
-		// Extrair ID da User Story da URL
-		userStoryID := r.URL.Path[len("/user-story-tasks/"):]
-		if userStoryID == "" {
-			http.Error(w, "ID da User Story é obrigatório", http.StatusBadRequest)
-			return
-		}
-
-		id, err := strconv.Atoi(userStoryID)
+		page, err := pageFromRequest(r)
 		if err != nil {
-			http.Error(w, "ID da User Story inválido", http.StatusBadRequest)
+			jsonError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		ctx := context.Background()
-		witClient, err := workitemtracking.NewClient(ctx, connection)
+		ctx := r.Context()
+		workClient, err := pool.Work(ctx, "user-stories")
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Erro ao criar cliente do Azure DevOps: %v", err), http.StatusInternalServerError)
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
 			return
 		}
 
-		// Buscar tasks vinculadas à User Story
-		wiql := fmt.Sprintf(`SELECT [System.Id], [System.Title], [System.State], [System.Description], [System.AssignedTo] 
-							FROM WorkItems 
-							WHERE [System.WorkItemType] = 'Task' 
-							AND [System.Parent] = %d`, id)
-
-		query := workitemtracking.Wiql{Query: &wiql}
-		queryResults, err := witClient.QueryByWiql(ctx, workitemtracking.QueryByWiqlArgs{
-			Wiql:    &query,
-			Project: &project,
-		})
-
+		// Buscar a sprint pelo nome ou pelo ID
+		targetIteration, err := resolveSprintRef(ctx, workClient, cfg, ref)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Erro ao buscar tasks: %v", err), http.StatusInternalServerError)
+			respondError(w, "", err)
 			return
 		}
-
-		var taskIds []int
-		if queryResults != nil && queryResults.WorkItems != nil {
-			for _, item := range *queryResults.WorkItems {
-				if item.Id != nil {
-					taskIds = append(taskIds, *item.Id)
-				}
-			}
-		}
-
-		tasks := make([]Task, 0)
-		if len(taskIds) > 0 {
-			workItems, err := witClient.GetWorkItems(ctx, workitemtracking.GetWorkItemsArgs{
-				Ids:     &taskIds,
-				Fields:  &[]string{"System.Title", "System.State", "System.Description", "System.AssignedTo"},
-				Project: &project,
-			})
-
-			if err != nil {
-				http.Error(w, fmt.Sprintf("Erro ao buscar detalhes das tasks: %v", err), http.StatusInternalServerError)
-				return
-			}
-
-			for _, workItem := range *workItems {
-				task := Task{
-					ID:    *workItem.Id,
-					Title: getFieldValue(workItem.Fields, "System.Title"),
-					State: getFieldValue(workItem.Fields, "System.State"),
-				}
-
-				// Campos opcionais
-				if desc := getFieldValue(workItem.Fields, "System.Description"); desc != "" {
-					task.Description = desc
-				}
-				if assignedTo := getFieldValue(workItem.Fields, "System.AssignedTo"); assignedTo != "" {
-					task.AssignedTo = assignedTo
-				}
-
-				tasks = append(tasks, task)
-			}
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(tasks)
-	}))
-
-	http.HandleFunc("/developers", enableCors(func(w http.ResponseWriter, r *http.Request) {
-		sprintName := r.URL.Query().Get("sprint")
-		if sprintName == "" {
-			jsonError(w, "Parâmetro 'sprint' é obrigatório", http.StatusBadRequest)
-			return
-		}
-
-		ctx := context.Background()
-		workClient, err := work.NewClient(ctx, connection)
-		if err != nil {
-			jsonError(w, fmt.Sprintf("Erro ao criar cliente do Azure DevOps: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		// Buscar o ID da sprint pelo nome
-		iterations, err := workClient.GetTeamIterations(ctx, work.GetTeamIterationsArgs{
-			Project: &project,
-			Team:    &team,
-		})
-		if err != nil {
-			jsonError(w, fmt.Sprintf("Erro ao buscar sprints: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		var targetIteration *work.TeamSettingsIteration
-		for _, iteration := range *iterations {
-			if *iteration.Name == sprintName {
-				targetIteration = &iteration
-				break
-			}
-		}
-
-		if targetIteration == nil {
-			jsonError(w, fmt.Sprintf("Sprint '%s' não encontrada", sprintName), http.StatusNotFound)
-			return
-		}
-
-		// Calcular capacidade total e dias úteis
-		var sprintStart, sprintEnd time.Time
-		if targetIteration.Attributes != nil {
-			if targetIteration.Attributes.StartDate != nil {
-				sprintStart = time.Time(targetIteration.Attributes.StartDate.Time)
-			}
-			if targetIteration.Attributes.FinishDate != nil {
-				sprintEnd = time.Time(targetIteration.Attributes.FinishDate.Time)
-			}
-		}
+		sprintName := *targetIteration.Name
+		setSprintHeaders(w, targetIteration)
 
 		// Buscar work items da sprint
 		workItemsResponse, err := workClient.GetIterationWorkItems(ctx, work.GetIterationWorkItemsArgs{
@@ -577,172 +578,275 @@ func main() {
 			IterationId: targetIteration.Id,
 		})
 		if err != nil {
-			jsonError(w, fmt.Sprintf("Erro ao buscar work items da sprint: %v", err), http.StatusInternalServerError)
+			respondError(w, "Erro ao buscar work items", wrapAdoError(err, "GetIterationWorkItems", "sprint=%s", sprintName))
 			return
 		}
 
-		witClient, err := workitemtracking.NewClient(ctx, connection)
+		// Criar cliente para buscar detalhes dos work items
+		witClient, err := pool.WorkItems(ctx, "user-stories")
 		if err != nil {
-			jsonError(w, fmt.Sprintf("Erro ao criar cliente de work items: %v", err), http.StatusInternalServerError)
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
 			return
 		}
 
-		// Primeiro, vamos buscar todas as User Stories da sprint
-		var workItemIds []int
-		if workItemsResponse != nil && workItemsResponse.WorkItemRelations != nil {
-			for _, relation := range *workItemsResponse.WorkItemRelations {
-				if relation.Target != nil && relation.Target.Id != nil {
-					workItemIds = append(workItemIds, *relation.Target.Id)
-				}
-			}
+		workItemIds := iterationWorkItemIds(workItemsResponse)
+
+		// Ordem manual do backlog; sem ela os itens apenas ficam sem rank
+		backlogRanks, err := fetchBacklogRanks(ctx, workClient, cfg)
+		if err != nil {
+			log.Printf("[WARN] Ordem do backlog indisponível: %v", err)
 		}
 
-		// Mapa para contar tasks por desenvolvedor
-		devMap := make(map[string]*Developer)
+		// Itens removidos só aparecem, marcados, quando pedidos para auditoria
+		includeRemoved := r.URL.Query().Get("includeRemoved") == "true"
+		types, explicitTypes := typesFromRequest(cfg, r)
 
+		storyFields := withExtraFields(userStoryFields, cfg.ExtraFields)
+		if expandDates {
+			storyFields = append(append([]string{}, storyFields...), workItemDateFields...)
+		}
+		result := make([]WorkItem, 0)
 		if len(workItemIds) > 0 {
-			// Buscar as User Stories
-			workItems, err := witClient.GetWorkItems(ctx, workitemtracking.GetWorkItemsArgs{
-				Ids:     &workItemIds,
-				Fields:  &[]string{"System.Id", "System.WorkItemType"},
-				Project: &project,
-			})
-
+			log.Printf("Buscando detalhes para %d work items", len(workItemIds))
+			workItems, err := getWorkItemsChunked(ctx, witClient, project, workItemIds, storyFields)
 			if err != nil {
-				jsonError(w, fmt.Sprintf("Erro ao buscar User Stories: %v", err), http.StatusInternalServerError)
+				respondError(w, "Erro ao buscar detalhes dos work items", err)
 				return
 			}
 
-			// WIQL para buscar tasks vinculadas às User Stories da sprint
-			var userStoryIds []string
-			for _, wi := range *workItems {
-				if getFieldValue(wi.Fields, "System.WorkItemType") == "User Story" {
-					userStoryIds = append(userStoryIds, fmt.Sprintf("%d", *wi.Id))
-				}
-			}
-
-			if len(userStoryIds) > 0 {
-				wiql := fmt.Sprintf(`SELECT [System.Id], [System.AssignedTo] 
-								   FROM WorkItems 
-								   WHERE [System.WorkItemType] = 'Task' 
-								   AND [System.Parent] IN (%s)
-								   AND [System.AssignedTo] <> ''`,
-					strings.Join(userStoryIds, ","))
-
-				query := workitemtracking.Wiql{Query: &wiql}
-				queryResults, err := witClient.QueryByWiql(ctx, workitemtracking.QueryByWiqlArgs{
-					Wiql:    &query,
-					Project: &project,
-				})
-
-				if err != nil {
-					jsonError(w, fmt.Sprintf("Erro ao buscar tasks: %v", err), http.StatusInternalServerError)
+			details := presentWorkItems(workItemIds, workItems)
+			if explicitTypes {
+				if err := validateRequestedTypes(types, details); err != nil {
+					jsonError(w, err.Error(), http.StatusBadRequest)
 					return
 				}
+			}
+			setMissingWorkItemsHeader(w, missingWorkItemIds(workItemIds, workItems))
 
-				var taskIds []int
-				if queryResults != nil && queryResults.WorkItems != nil {
-					for _, item := range *queryResults.WorkItems {
-						if item.Id != nil {
-							taskIds = append(taskIds, *item.Id)
-						}
-					}
+			for _, detail := range details {
+				// Filtra o estado antes de interpretar datas, evitando logs de itens descartados
+				if !keepState(getFieldValue(detail.Fields, "System.State")) || !keepArea(getFieldValue(detail.Fields, "System.AreaPath")) {
+					continue
 				}
-
-				if len(taskIds) > 0 {
-					tasks, err := witClient.GetWorkItems(ctx, workitemtracking.GetWorkItemsArgs{
-						Ids:     &taskIds,
-						Fields:  &[]string{"System.AssignedTo"},
-						Project: &project,
-					})
-
-					if err != nil {
-						jsonError(w, fmt.Sprintf("Erro ao buscar detalhes das tasks: %v", err), http.StatusInternalServerError)
-						return
+				if item, ok := buildUserStory(detail, cfg, types, backlogRanks); ok {
+					if item.Removed && !includeRemoved {
+						continue
 					}
-
-					for _, task := range *tasks {
-						if assignedTo := getFieldValue(task.Fields, "System.AssignedTo"); assignedTo != "" {
-							if dev, exists := devMap[assignedTo]; exists {
-								dev.Tasks++
-							} else {
-								devMap[assignedTo] = &Developer{
-									Name:  assignedTo,
-									Tasks: 1,
-								}
-							}
-						}
+					if expandDates {
+						item.Dates = workItemDates(detail.Fields)
 					}
+					result = append(result, item)
 				}
 			}
 		}
 
-		// Mapa para armazenar capacidade por desenvolvedor
-		devCapacities := make(map[string]TeamMemberCapacity)
-
-		// Definir capacidade padrão para todos os desenvolvedores
-		for _, dev := range devMap {
-			devCapacities[dev.Name] = TeamMemberCapacity{
-				Activities: []struct {
-					CapacityPerDay float64 `json:"capacityPerDay"`
-					Name           string  `json:"name"`
-				}{
-					{
-						CapacityPerDay: 8.0, // 8 horas por dia como padrão
-						Name:           "Desenvolvimento",
-					},
-				},
-				DaysOff: []DayOff{},
-			}
+		if err := sortWorkItems(result, r.URL.Query().Get("sort"), r.URL.Query().Get("order")); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
-		response := DevelopersResponse{
-			SprintStart: sprintStart,
-			SprintEnd:   sprintEnd,
+		// A página é cortada depois de filtrar e ordenar, para ser estável
+		// entre chamadas; as tasks só são buscadas para os itens da página
+		var pageInfo PageInfo
+		if page.Enabled {
+			start, end, info := page.bounds(len(result))
+			result, pageInfo = result[start:end], info
 		}
 
-		// Converter mapa para slice e calcular capacidades
-		developers := make([]Developer, 0, len(devMap))
-		totalDaysOff := 0
-		for _, dev := range devMap {
-			developer := Developer{
-				Name:  dev.Name,
-				Tasks: dev.Tasks,
+		// Tasks de todas as User Stories numa consulta só, em vez de uma
+		// chamada a /user-story-tasks/{id} por User Story
+		if expandTasks && len(result) > 0 {
+			storyIds := make([]int, len(result))
+			for i, item := range result {
+				storyIds[i] = item.ID
 			}
-
-			if capacity, exists := devCapacities[dev.Name]; exists {
-				// Soma todas as capacidades por dia
-				for _, activity := range capacity.Activities {
-					developer.CapacityPerDay += activity.CapacityPerDay
+			tasksByParent, warnings, err := fetchTasksByParent(ctx, witClient, cfg, storyIds, format, includeRemoved)
+			if err != nil {
+				respondError(w, "Erro ao buscar tasks das User Stories", err)
+				return
+			}
+			for _, warning := range warnings {
+				log.Printf("[WARN] %s", warning)
+			}
+			for i := range result {
+				tasks := tasksByParent[result[i].ID]
+				if tasks == nil {
+					tasks = []Task{}
 				}
+				result[i].Tasks = &tasks
+			}
+		}
 
-				// Calcula dias úteis considerando dias de folga
-				workingDays := calculateWorkingDays(sprintStart, sprintEnd, capacity.DaysOff)
-				developer.DaysOff = len(capacity.DaysOff)
-				totalDaysOff += developer.DaysOff
+		if page.Enabled {
+			writeJSON(w, http.StatusOK, PagedResponse{Items: result, PageInfo: pageInfo})
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}))
 
-				// Calcula capacidade total
-				developer.TotalCapacity = float64(workingDays) * developer.CapacityPerDay
-				response.TotalCapacity += developer.TotalCapacity
+	http.HandleFunc("/user-stories/stream", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleUserStoriesStream(pool, cfg)
+	})))
+
+	// Tasks de uma User Story; a criação (POST) exige X-Admin-Key
+	http.HandleFunc("/user-story-tasks/", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		listTasks := handleUserStoryTasks(pool, cfg)
+		createTask := requireAdmin(cfg, handleCreateUserStoryTask(pool, cfg))
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				createTask(w, r)
+				return
 			}
+			listTasks(w, r)
+		}
+	})))
+
+	// Endpoint administrativo para copiar a capacidade de uma sprint anterior
+	http.HandleFunc("/capacity/copy", enableCors(requireAdmin(cfg, teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleCapacityCopy(pool, cfg)
+	}))))
+
+	// Gravação pontual do DueDate de um work item e histórico das datas dele;
+	// o histórico é só leitura e não exige X-Admin-Key
+	http.HandleFunc("/work-items/", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		writeDueDate := requireAdmin(cfg, handleWorkItemDueDate(pool, cfg, runs))
+		dueDateHistory := handleDueDateHistory(pool, cfg)
+		return func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/due-date-history") {
+				dueDateHistory(w, r)
+				return
+			}
+			writeDueDate(w, r)
+		}
+	})))
+	http.HandleFunc("/due-dates", enableCors(requireAdmin(cfg, teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleDueDatesBatch(pool, cfg, runs, idempotency)
+	}))))
+	runsHandler := requireAdmin(cfg, teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleRuns(pool, cfg, runs, idempotency, events)
+	}))
+	http.HandleFunc("/runs", enableCors(runsHandler))
+	http.HandleFunc("/runs/", enableCors(runsHandler))
+	http.HandleFunc("/generate-due-dates", enableCors(requireAdmin(cfg, teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleGenerateDueDates(pool, cfg, runs, idempotency, events)
+	}))))
+
+	// Configuração efetiva de cada time e releitura de TEAMS_CONFIG
+	http.HandleFunc("/config", enableCors(handleConfig(teams)))
+	http.HandleFunc("/config/reload", enableCors(requireAdmin(cfg, handleConfigReload(teams))))
+
+	// Rota para simular a geração com ajustes de capacidade, sem gravar nada
+	http.HandleFunc("/simulate", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleSimulate(pool, cfg)
+	})))
+
+	// Rota para exportar o plano da geração no formato de importação do MS Project
+	http.HandleFunc("/export/project-csv", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleProjectExport(pool, cfg)
+	})))
+
+	// Rota com o registro da sprint (resumo, plano, capacidade, precisão, execuções e burndown) num zip
+	http.HandleFunc("/export/archive", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleSprintArchive(pool, cfg, runs)
+	})))
+
+	// Rota com as User Stories cuja data não cabe na capacidade restante do responsável
+	http.HandleFunc("/at-risk", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleAtRisk(pool, cfg, runs)
+	})))
+
+	// Rota com o resumo da sprint (estados, Story Points, cobertura de datas e estimativas, capacidade)
+	http.HandleFunc("/sprint-summary", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleSprintSummary(pool, cfg, runs)
+	})))
+
+	// Rota com os dados do burndown (planejado pelas datas × restante atual)
+	http.HandleFunc("/burndown", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleBurndown(pool, cfg)
+	})))
+
+	// Rota com os dias da sprint (úteis, feriados, folgas do time, congelamento)
+	http.HandleFunc("/calendar", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleSprintCalendar(pool, cfg)
+	})))
+
+	// Rota com as datas gravadas que caem no congelamento do fim da sprint
+	http.HandleFunc("/validate", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleFreezeValidate(pool, cfg)
+	})))
+
+	// Rotas para o acompanhamento diário: itens com data hoje e itens atrasados
+	http.HandleFunc("/due-today", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleDueList(pool, cfg, false)
+	})))
+	http.HandleFunc("/overdue", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleDueList(pool, cfg, true)
+	})))
+
+	// Rota com as User Stories da sprint agrupadas pela Feature pai
+	http.HandleFunc("/features", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleFeatures(pool, cfg)
+	})))
+
+	// Rota com cycle time e lead time das User Stories fechadas na sprint
+	http.HandleFunc("/metrics/cycle-time", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleCycleTime(pool, cfg)
+	})))
+
+	// Rota com a precisão das datas (data × fechamento) das User Stories fechadas na sprint
+	http.HandleFunc("/metrics/due-date-accuracy", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleDueDateAccuracy(pool, cfg, runs)
+	})))
+
+	// Rota com a sugestão de FOCUS_FACTOR pelas últimas sprints encerradas
+	http.HandleFunc("/tuning", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleTuning(pool, cfg, runs)
+	})))
+
+	// Rota com as entregas, a utilização e a precisão das sprints de um ano
+	annualStats := newAnnualStatsCache(annualStatsCacheTTL)
+	http.HandleFunc("/stats/annual", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleAnnualStats(pool, cfg, runs, annualStats)
+	})))
+
+	// Rota com o ranking de entregas no prazo da data gerada por desenvolvedor
+	http.HandleFunc("/leaderboard", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleLeaderboard(pool, cfg, runs)
+	})))
+
+	// Rota para buscar itens da sprint por palavra no título
+	http.HandleFunc("/search", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleSearch(pool, cfg)
+	})))
+
+	// Endpoint com o uso do Azure DevOps por chamador (chamadas, erros, espera no limite de concorrência)
+	http.HandleFunc("/ado/stats", enableCors(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, pool.Stats())
+	}))
 
-			developers = append(developers, developer)
-		}
+	http.HandleFunc("/developers", enableCors(teams.route(func(cfg *Config) http.HandlerFunc {
+		return handleDevelopers(pool, cfg)
+	})))
 
-		// Ordenar por nome
-		sort.Slice(developers, func(i, j int) bool {
-			return developers[i].Name < developers[j].Name
-		})
+	// Saúde do serviço e origem (env ou file) dos segredos, sem os valores
+	http.HandleFunc("/health", enableCors(handleHealth(cfg)))
 
-		response.Developers = developers
-		response.TotalDaysOff = totalDaysOff
-		response.WorkingDays = calculateWorkingDays(sprintStart, sprintEnd, nil)
+	// Verificação periódica do plano da sprint atual (resumo e aviso de plano
+	// desatualizado); só com algum canal além do log, para não consultar o
+	// Azure DevOps à toa
+	if events.external() {
+		reminder := &stalePlanReminder{pool: pool, teams: teams, runs: runs, events: events}
+		go reminder.run(cfg.StalePlanCheckInterval, cfg.RequestTimeout)
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-	}))
+	// Aquecimento opcional em segundo plano; nunca bloqueia o servidor. Os
+	// clientes descartados por reconnect (PAT relido) são aquecidos de novo.
+	if cfg.Prefetch {
+		pool.onReconnect = func() { prefetchCurrentSprint(pool, cfg) }
+		go prefetchCurrentSprint(pool, cfg)
+	}
 
 	port := ":8088"
 	fmt.Printf("Servidor rodando na porta %s\n", port)
-	log.Fatal(http.ListenAndServe(port, nil))
+	log.Fatal(http.ListenAndServe(port, withRecover(withTimeout(http.DefaultServeMux, cfg.RequestTimeout))))
 }
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+// Origem da data de ativação usada no cycle time
+const (
+	activatedFromField     = "field"
+	activatedFromRevisions = "revisions"
+)
+
+// Resumo de uma série de durações em dias úteis. P85 é o valor abaixo do qual
+// ficam 85% dos itens, interpolado entre os vizinhos como a mediana.
+type DurationStats struct {
+	Count  int     `json:"count"`
+	Min    float64 `json:"min"`
+	Median float64 `json:"median"`
+	P85    float64 `json:"p85"`
+	Max    float64 `json:"max"`
+}
+
+// User Story fechada medida por GET /metrics/cycle-time. CycleTime vai da
+// ativação ao fechamento e LeadTime da criação ao fechamento, em dias úteis.
+type CycleTimeItem struct {
+	ID            int       `json:"id"`
+	Title         string    `json:"title"`
+	Type          string    `json:"type"`
+	State         string    `json:"state"`
+	AssignedTo    *Identity `json:"assignedTo"`
+	CreatedDate   time.Time `json:"createdDate"`
+	ActivatedDate time.Time `json:"activatedDate"`
+	// activatedFromField (ActivatedDate) ou activatedFromRevisions (primeira
+	// mudança de estado nas revisões)
+	ActivatedFrom string    `json:"activatedFrom"`
+	ClosedDate    time.Time `json:"closedDate"`
+	CycleTime     float64   `json:"cycleTime"`
+	LeadTime      float64   `json:"leadTime"`
+}
+
+// User Story fechada que ficou fora das métricas, com o motivo
+type MetricExclusion struct {
+	ID     int    `json:"id"`
+	Title  string `json:"title"`
+	Reason string `json:"reason"`
+}
+
+// Resposta de GET /metrics/cycle-time. As estatísticas ficam null quando
+// nenhuma User Story pôde ser medida.
+type CycleTimeReport struct {
+	Sprint        string            `json:"sprint"`
+	Closed        int               `json:"closed"`
+	CycleTime     *DurationStats    `json:"cycleTime"`
+	LeadTime      *DurationStats    `json:"leadTime"`
+	Items         []CycleTimeItem   `json:"items"`
+	Excluded      int               `json:"excluded"`
+	ExcludedItems []MetricExclusion `json:"excludedItems"`
+	Warnings      []string          `json:"warnings"`
+}
+
+// Função para obter o percentil p (0 a 1) de valores já ordenados,
+// interpolando entre as duas posições mais próximas
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	position := p * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	upper := int(math.Ceil(position))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(position-float64(lower))
+}
+
+// Função para resumir uma série de durações; nil quando vazia
+func durationStats(values []float64) *DurationStats {
+	if len(values) == 0 {
+		return nil
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	return &DurationStats{
+		Count:  len(sorted),
+		Min:    sorted[0],
+		Median: percentile(sorted, 0.5),
+		P85:    percentile(sorted, 0.85),
+		Max:    sorted[len(sorted)-1],
+	}
+}
+
+// GET /metrics/cycle-time: cycle time e lead time, em dias úteis do
+// calendário do serviço, das User Stories da sprint em DONE_STATES
+func handleCycleTime(pool *adoPool, cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ref, err := sprintRefFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cal, err := calendarForRequest(cfg, r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		keepArea, err := areaPathFilterFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		workClient, err := pool.Work(ctx, "cycle-time")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
+			return
+		}
+		targetIteration, err := resolveSprintRef(ctx, workClient, cfg, ref)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		sprintName := *targetIteration.Name
+		setSprintHeaders(w, targetIteration)
+		witClient, err := pool.WorkItems(ctx, "cycle-time")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+		types, _ := typesFromRequest(cfg, r)
+		sprintStories, _, missing, err := fetchSprintStories(ctx, workClient, witClient, cfg, targetIteration, types, nil)
+		if err != nil {
+			respondError(w, "Erro ao buscar User Stories", err)
+			return
+		}
+		var closed []WorkItem
+		var closedIds []int
+		for _, story := range sprintStories {
+			if !story.Removed && isDoneState(cfg, story.State) && keepArea(story.AreaPath) {
+				closed = append(closed, story)
+				closedIds = append(closedIds, story.ID)
+			}
+		}
+
+		report := CycleTimeReport{
+			Sprint:        sprintName,
+			Closed:        len(closed),
+			Items:         []CycleTimeItem{},
+			ExcludedItems: []MetricExclusion{},
+			Warnings:      missingWorkItemWarnings(missing),
+		}
+		// As datas do ciclo de vida só são pedidas para as User Stories fechadas
+		dates := make(map[int]*WorkItemDates)
+		if len(closedIds) > 0 {
+			workItems, err := getWorkItemsChunked(ctx, witClient, cfg.Project, closedIds, workItemDateFields)
+			if err != nil {
+				respondError(w, "Erro ao buscar datas das User Stories", err)
+				return
+			}
+			for _, workItem := range presentWorkItems(closedIds, workItems) {
+				dates[*workItem.Id] = workItemDates(workItem.Fields)
+			}
+		}
+
+		var cycleTimes, leadTimes []float64
+		exclude := func(story WorkItem, reason string) {
+			report.ExcludedItems = append(report.ExcludedItems, MetricExclusion{ID: story.ID, Title: story.Title, Reason: reason})
+		}
+		for _, story := range closed {
+			storyDates := dates[story.ID]
+			switch {
+			case storyDates == nil:
+				exclude(story, "work item não encontrado ao buscar as datas")
+				continue
+			case storyDates.ClosedDate == nil:
+				exclude(story, "sem ClosedDate")
+				continue
+			case storyDates.CreatedDate == nil:
+				exclude(story, "sem CreatedDate")
+				continue
+			}
+			item := CycleTimeItem{
+				ID:          story.ID,
+				Title:       story.Title,
+				Type:        story.Type,
+				State:       story.State,
+				AssignedTo:  story.AssignedTo,
+				CreatedDate: *storyDates.CreatedDate,
+				ClosedDate:  *storyDates.ClosedDate,
+			}
+			// Sem ActivatedDate (por exemplo no processo Scrum), vale a primeira
+			// mudança de estado registrada nas revisões
+			if storyDates.ActivatedDate != nil {
+				item.ActivatedDate, item.ActivatedFrom = *storyDates.ActivatedDate, activatedFromField
+			} else {
+				revisions, err := fetchRevisions(ctx, witClient, cfg.Project, story.ID)
+				if err != nil {
+					log.Printf("[WARN] Revisões da User Story #%d indisponíveis: %v", story.ID, err)
+					report.Warnings = append(report.Warnings, fmt.Sprintf("Revisões da User Story #%d indisponíveis: %v", story.ID, err))
+					exclude(story, "sem ActivatedDate e revisões indisponíveis")
+					continue
+				}
+				activated, found := firstStateTransition(revisions)
+				if !found {
+					exclude(story, "sem ActivatedDate nem mudança de estado nas revisões")
+					continue
+				}
+				item.ActivatedDate, item.ActivatedFrom = activated, activatedFromRevisions
+			}
+
+			if item.CycleTime, err = elapsedWorkingDays(item.ActivatedDate, item.ClosedDate, cal); err == nil {
+				item.LeadTime, err = elapsedWorkingDays(item.CreatedDate, item.ClosedDate, cal)
+			}
+			if err != nil {
+				exclude(story, err.Error())
+				continue
+			}
+			report.Items = append(report.Items, item)
+			cycleTimes = append(cycleTimes, item.CycleTime)
+			leadTimes = append(leadTimes, item.LeadTime)
+		}
+		report.Excluded = len(report.ExcludedItems)
+		report.CycleTime = durationStats(cycleTimes)
+		report.LeadTime = durationStats(leadTimes)
+		sort.SliceStable(report.Items, func(i, j int) bool {
+			return report.Items[i].CycleTime > report.Items[j].CycleTime
+		})
+
+		log.Printf("[DEBUG] Cycle time da sprint '%s': %d User Stories medidas, %d fora", sprintName, len(report.Items), report.Excluded)
+		writeJSON(w, http.StatusOK, report)
+	}
+}
+
+// Tamanho padrão e máximo da lista de maiores atrasos (?top=)
+const (
+	defaultAccuracyTop = 5
+	maxAccuracyTop     = 100
+)
+
+// User Story fechada avaliada por GET /metrics/due-date-accuracy. SlipDays é a
+// diferença em dias úteis entre a data e o fechamento: positiva quando fechou
+// depois da data, negativa quando antes. GeneratedDueDate é a última data
+// gravada por POST /generate-due-dates, mesmo que alguém a tenha mudado depois.
+type AccuracyItem struct {
+	ID                int        `json:"id"`
+	Title             string     `json:"title"`
+	AssignedTo        *Identity  `json:"assignedTo"`
+	ClosedDate        time.Time  `json:"closedDate"`
+	DueDate           *time.Time `json:"dueDate"`
+	SlipDays          *float64   `json:"slipDays"`
+	GeneratedDueDate  *time.Time `json:"generatedDueDate"`
+	GeneratedSlipDays *float64   `json:"generatedSlipDays"`
+}
+
+// Consolidado das diferenças: no prazo é fechar até o dia da data.
+// AverageSlip considera só as atrasadas; AverageDifference, todas.
+type AccuracyStats struct {
+	Count             int     `json:"count"`
+	OnTime            int     `json:"onTime"`
+	OnTimePercent     float64 `json:"onTimePercent"`
+	Late              int     `json:"late"`
+	AverageSlip       float64 `json:"averageSlip"`
+	AverageDifference float64 `json:"averageDifference"`
+}
+
+// Resposta de GET /metrics/due-date-accuracy. As estatísticas ficam null sem
+// User Stories para comparar.
+type DueDateAccuracyReport struct {
+	Sprint string `json:"sprint"`
+	Closed int    `json:"closed"`
+	// Pela data atual do work item e pela data gravada pela geração
+	Accuracy          *AccuracyStats `json:"accuracy"`
+	GeneratedAccuracy *AccuracyStats `json:"generatedAccuracy"`
+	// Fechadas sem data atual nem data gravada por geração
+	WithoutDueDate    int               `json:"withoutDueDate"`
+	WithoutDueDateIds []int             `json:"withoutDueDateIds"`
+	WorstOffenders    []AccuracyItem    `json:"worstOffenders"`
+	Items             []AccuracyItem    `json:"items"`
+	ExcludedItems     []MetricExclusion `json:"excludedItems"`
+	Warnings          []string          `json:"warnings"`
+}
+
+// Função para calcular a diferença com sinal, em dias úteis, entre o dia da
+// data e o dia do fechamento
+func slipWorkingDays(dueDay, closedDay time.Time, cal workCalendar) (float64, error) {
+	if closedDay.Before(dueDay) {
+		early, err := workingDaysAfter(closedDay, dueDay, cal)
+		return -early, err
+	}
+	return workingDaysAfter(dueDay, closedDay, cal)
+}
+
+// Função para consolidar as diferenças; nil quando vazia
+func accuracyStats(slips []float64) *AccuracyStats {
+	if len(slips) == 0 {
+		return nil
+	}
+	stats := &AccuracyStats{Count: len(slips)}
+	total, late := 0.0, 0.0
+	for _, slip := range slips {
+		total += slip
+		if slip > 0 {
+			stats.Late++
+			late += slip
+		} else {
+			stats.OnTime++
+		}
+	}
+	stats.OnTimePercent = math.Round(float64(stats.OnTime)/float64(stats.Count)*1000) / 10
+	stats.AverageDifference = total / float64(stats.Count)
+	if stats.Late > 0 {
+		stats.AverageSlip = late / float64(stats.Late)
+	}
+	return stats
+}
+
+// GET /metrics/due-date-accuracy: quão boas foram as datas das User Stories
+// fechadas da sprint, comparando a data com o ClosedDate em dias úteis
+func handleDueDateAccuracy(pool *adoPool, cfg *Config, runs *runStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ref, err := sprintRefFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cal, err := calendarForRequest(cfg, r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		keepArea, err := areaPathFilterFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		top := defaultAccuracyTop
+		if value := r.URL.Query().Get("top"); value != "" {
+			top, err = strconv.Atoi(value)
+			if err != nil || top < 1 || top > maxAccuracyTop {
+				jsonError(w, fmt.Sprintf("Parâmetro 'top' inválido: %q (use um inteiro entre 1 e %d)", value, maxAccuracyTop), http.StatusBadRequest)
+				return
+			}
+		}
+
+		ctx := r.Context()
+		workClient, err := pool.Work(ctx, "due-date-accuracy")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
+			return
+		}
+		targetIteration, err := resolveSprintRef(ctx, workClient, cfg, ref)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		sprintName := *targetIteration.Name
+		setSprintHeaders(w, targetIteration)
+		witClient, err := pool.WorkItems(ctx, "due-date-accuracy")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+		types, _ := typesFromRequest(cfg, r)
+		sprintStories, _, missing, err := fetchSprintStories(ctx, workClient, witClient, cfg, targetIteration, types, nil)
+		if err != nil {
+			respondError(w, "Erro ao buscar User Stories", err)
+			return
+		}
+		report, err := dueDateAccuracy(ctx, witClient, cfg, runs, targetIteration, sprintStories, keepArea, cal, top)
+		if err != nil {
+			respondError(w, "Erro ao buscar datas de fechamento", err)
+			return
+		}
+		report.Warnings = append(missingWorkItemWarnings(missing), report.Warnings...)
+
+		log.Printf("[DEBUG] Precisão das datas da sprint '%s': %d User Stories comparadas, %d sem data", sprintName, len(report.Items), report.WithoutDueDate)
+		writeJSON(w, http.StatusOK, report)
+	}
+}
+
+// Função para comparar as datas das User Stories fechadas da sprint (em
+// DONE_STATES e dentro do filtro de área) com o fechamento delas. É o cálculo
+// de GET /metrics/due-date-accuracy, reaproveitado por GET /tuning.
+func dueDateAccuracy(ctx context.Context, witClient workitemtracking.Client, cfg *Config, runs *runStore, iteration *work.TeamSettingsIteration, sprintStories []WorkItem, keepArea func(string) bool, cal workCalendar, top int) (DueDateAccuracyReport, error) {
+	var closed []WorkItem
+	var closedIds []int
+	for _, story := range sprintStories {
+		if !story.Removed && isDoneState(cfg, story.State) && keepArea(story.AreaPath) {
+			closed = append(closed, story)
+			closedIds = append(closedIds, story.ID)
+		}
+	}
+
+	report := DueDateAccuracyReport{
+		Sprint:            *iteration.Name,
+		Closed:            len(closed),
+		WithoutDueDateIds: []int{},
+		WorstOffenders:    []AccuracyItem{},
+		Items:             []AccuracyItem{},
+		ExcludedItems:     []MetricExclusion{},
+		Warnings:          []string{},
+	}
+	closedDates := make(map[int]*time.Time)
+	if len(closedIds) > 0 {
+		workItems, err := getWorkItemsChunked(ctx, witClient, cfg.Project, closedIds, []string{"Microsoft.VSTS.Common.ClosedDate"})
+		if err != nil {
+			return report, err
+		}
+		for _, workItem := range presentWorkItems(closedIds, workItems) {
+			closedDates[*workItem.Id] = getFieldTime(workItem.Fields, "Microsoft.VSTS.Common.ClosedDate")
+		}
+	}
+	generated := runs.generatedDueDates(cfg.Team, iteration.Id.String())
+
+	var slips, generatedSlips []float64
+	for _, story := range closed {
+		closedDate := closedDates[story.ID]
+		if closedDate == nil {
+			report.ExcludedItems = append(report.ExcludedItems, MetricExclusion{ID: story.ID, Title: story.Title, Reason: "sem ClosedDate"})
+			continue
+		}
+		item := AccuracyItem{ID: story.ID, Title: story.Title, AssignedTo: story.AssignedTo, ClosedDate: *closedDate, DueDate: story.DueDate}
+		if date, ok := generated[story.ID]; ok {
+			item.GeneratedDueDate = &date
+		}
+		if item.DueDate == nil && item.GeneratedDueDate == nil {
+			report.WithoutDueDate++
+			report.WithoutDueDateIds = append(report.WithoutDueDateIds, story.ID)
+			continue
+		}
+
+		closedDay := cal.dateOf(*closedDate)
+		if item.DueDate != nil {
+			slip, err := slipWorkingDays(dueDateDay(*item.DueDate, cal), closedDay, cal)
+			if err != nil {
+				report.ExcludedItems = append(report.ExcludedItems, MetricExclusion{ID: story.ID, Title: story.Title, Reason: err.Error()})
+				continue
+			}
+			item.SlipDays = &slip
+		}
+		if item.GeneratedDueDate != nil {
+			slip, err := slipWorkingDays(dueDateDay(*item.GeneratedDueDate, cal), closedDay, cal)
+			if err != nil {
+				report.ExcludedItems = append(report.ExcludedItems, MetricExclusion{ID: story.ID, Title: story.Title, Reason: err.Error()})
+				continue
+			}
+			item.GeneratedSlipDays = &slip
+		}
+		if item.SlipDays != nil {
+			slips = append(slips, *item.SlipDays)
+		}
+		if item.GeneratedSlipDays != nil {
+			generatedSlips = append(generatedSlips, *item.GeneratedSlipDays)
+		}
+		report.Items = append(report.Items, item)
+	}
+	report.Accuracy = accuracyStats(slips)
+	report.GeneratedAccuracy = accuracyStats(generatedSlips)
+
+	// Maiores atrasos pela data atual; sem ela, pela data gravada pela geração
+	slipOf := func(item AccuracyItem) float64 {
+		if item.SlipDays != nil {
+			return *item.SlipDays
+		}
+		return *item.GeneratedSlipDays
+	}
+	sort.SliceStable(report.Items, func(i, j int) bool {
+		return slipOf(report.Items[i]) > slipOf(report.Items[j])
+	})
+	for _, item := range report.Items {
+		if len(report.WorstOffenders) == top || slipOf(item) <= 0 {
+			break
+		}
+		report.WorstOffenders = append(report.WorstOffenders, item)
+	}
+
+	return report, nil
+}
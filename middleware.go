@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timeoutWriter acumula a resposta do handler em memória para que, se o tempo
+// acabar, o cliente receba apenas o erro JSON. Handlers de streaming que chamam
+// Flush passam a escrever diretamente no ResponseWriter original.
+type timeoutWriter struct {
+	w         http.ResponseWriter
+	mu        sync.Mutex
+	header    http.Header
+	buf       bytes.Buffer
+	status    int
+	timedOut  bool
+	streaming bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.status != 0 {
+		return
+	}
+	tw.status = code
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.streaming {
+		return tw.w.Write(p)
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	if !tw.streaming {
+		tw.commit()
+		tw.streaming = true
+	}
+	if flusher, ok := tw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Envia cabeçalhos, status e o corpo acumulado ao cliente. Chamar com mu travado.
+func (tw *timeoutWriter) commit() {
+	dst := tw.w.Header()
+	for key, values := range tw.header {
+		dst[key] = values
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	tw.w.WriteHeader(tw.status)
+	tw.w.Write(tw.buf.Bytes())
+	tw.buf.Reset()
+}
+
+// Função para identificar as requisições que gravam datas item a item, com
+// dueDateBatchInterval entre as gravações. Cortadas pelo REQUEST_TIMEOUT no
+// meio, deixariam parte dos itens gravada sem execução registrada para
+// rollback, então ficam fora do limite.
+func writesDueDates(r *http.Request) bool {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	switch r.Method {
+	case http.MethodPost:
+		return path == "/generate-due-dates" || path == "/due-dates" ||
+			(strings.HasPrefix(path, "/runs/") && (strings.HasSuffix(path, "/rollback") || strings.HasSuffix(path, "/approve")))
+	case http.MethodPatch:
+		return strings.HasPrefix(path, "/work-items/") && strings.HasSuffix(path, "/due-date")
+	}
+	return false
+}
+
+// Middleware que limita o tempo de cada requisição, com a mesma semântica do
+// http.TimeoutHandler mas respondendo no formato de erro JSON da API.
+// O contexto da requisição é cancelado ao expirar, interrompendo chamadas ao
+// Azure DevOps em andamento. As gravações de datas (writesDueDates) não têm
+// limite e terminam com a execução registrada.
+func withTimeout(next http.Handler, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if writesDueDates(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		tw := &timeoutWriter{w: w, header: make(http.Header)}
+		done := make(chan struct{})
+		panicChan := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					// Guarda a pilha da goroutine do handler, perdida ao repassar o panic
+					panicChan <- handlerPanic{value: p, stack: debug.Stack()}
+				}
+			}()
+			next.ServeHTTP(tw, r.WithContext(ctx))
+			close(done)
+		}()
+
+		select {
+		case p := <-panicChan:
+			panic(p)
+		case <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			if !tw.streaming {
+				tw.commit()
+			}
+		case <-ctx.Done():
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			tw.timedOut = true
+			if !tw.streaming {
+				jsonError(w, "Tempo limite da requisição excedido", http.StatusServiceUnavailable)
+			}
+		}
+	})
+}
+
+// handlerPanic carrega um panic recuperado em outra goroutine junto com a pilha original
+type handlerPanic struct {
+	value interface{}
+	stack []byte
+}
+
+// Middleware que transforma panics dos handlers em erro 500 JSON, em vez de
+// derrubar a conexão sem resposta. A pilha vai para o log.
+func withRecover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			stack := debug.Stack()
+			if hp, ok := p.(handlerPanic); ok {
+				p, stack = hp.value, hp.stack
+			}
+			// http.ErrAbortHandler é a forma padrão de abortar a resposta
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			log.Printf("[ERROR] Panic em %s %s: %v\n%s", r.Method, r.URL.Path, p, stack)
+			jsonError(w, "Erro interno do servidor", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Função para proteger endpoints administrativos com as chaves de
+// ADMIN_API_KEY, enviadas no header X-Admin-Key. Sem chave configurada o
+// endpoint fica desabilitado.
+func requireAdmin(cfg *Config, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminKeys := cfg.adminKeys()
+		if len(adminKeys) == 0 {
+			jsonError(w, "Endpoint administrativo desabilitado: configure ADMIN_API_KEY", http.StatusForbidden)
+			return
+		}
+		key := r.Header.Get("X-Admin-Key")
+		valid := false
+		for _, adminKey := range adminKeys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) == 1 {
+				valid = true
+			}
+		}
+		if !valid {
+			jsonError(w, "Chave administrativa inválida", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Função para identificar uma chave administrativa sem expô-la: início do
+// SHA-256, registrado em quem pediu e em quem aprovou uma geração
+func adminKeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "key-" + hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithTimeoutSkipsDueDateWrites(t *testing.T) {
+	slow := withTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(20 * time.Millisecond):
+			w.WriteHeader(http.StatusOK)
+		case <-r.Context().Done():
+		}
+	}), time.Millisecond)
+	tests := []struct {
+		method string
+		target string
+		want   int
+	}{
+		{http.MethodGet, "/developers?sprint=Sprint%207", http.StatusServiceUnavailable},
+		{http.MethodPost, "/simulate", http.StatusServiceUnavailable},
+		{http.MethodGet, "/runs/abc", http.StatusServiceUnavailable},
+		{http.MethodPost, "/generate-due-dates?sprint=Sprint%207", http.StatusOK},
+		{http.MethodPost, "/due-dates", http.StatusOK},
+		{http.MethodPatch, "/work-items/42/due-date", http.StatusOK},
+		{http.MethodPost, "/runs/abc/rollback", http.StatusOK},
+		{http.MethodPost, "/runs/abc/approve", http.StatusOK},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		slow.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.target, nil))
+		if recorder.Code != tt.want {
+			t.Errorf("%s %s: status = %d, quer %d", tt.method, tt.target, recorder.Code, tt.want)
+		}
+	}
+}
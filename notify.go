@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tipos de evento publicados para os canais de notificação
+type EventType string
+
+const (
+	// Geração com escrita terminada sem falhas
+	EventRunCompleted EventType = "run.completed"
+	// Geração com escrita com falhas de gravação ou interrompida por erro
+	EventRunFailed EventType = "run.failed"
+	// Geração com escrita aguardando aprovação (REQUIRE_APPROVAL)
+	EventRunPendingApproval EventType = "run.pending-approval"
+	// Plano de datas da sprint atual desatualizado (verificação periódica)
+	EventPlanStale EventType = "plan.stale"
+	// Resumo periódico do plano da sprint atual
+	EventDigestDue EventType = "digest.due"
+)
+
+// Todos os tipos de evento, na ordem da documentação
+var eventTypes = []EventType{EventRunCompleted, EventRunFailed, EventRunPendingApproval, EventPlanStale, EventDigestDue}
+
+// Função para listar os tipos de evento nas mensagens de erro
+func eventTypeNames() string {
+	names := make([]string, len(eventTypes))
+	for i, eventType := range eventTypes {
+		names[i] = string(eventType)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Eventos enviados ao webhook quando NOTIFY_WEBHOOK_EVENTS não é definido;
+// o resumo periódico só vai quando pedido
+var defaultWebhookEvents = []EventType{EventRunCompleted, EventRunFailed, EventRunPendingApproval, EventPlanStale}
+
+// Tentativas de entrega por canal e espera antes da segunda tentativa
+// (dobrada a cada nova tentativa)
+const (
+	notifyAttempts = 3
+	notifyBackoff  = 2 * time.Second
+)
+
+// Evento publicado para os canais de notificação
+type Event struct {
+	Type     EventType `json:"type"`
+	Time     time.Time `json:"time"`
+	Sprint   string    `json:"sprint,omitempty"`
+	SprintID string    `json:"sprintId,omitempty"`
+	RunID    string    `json:"runId,omitempty"`
+	// Resumo legível, usado como mensagem nos canais de chat
+	Text string `json:"text"`
+	// Work items citados pelo evento (User Stories novas no plano desatualizado)
+	Items []int `json:"items,omitempty"`
+}
+
+// Canal de notificação. Notify entrega um evento; o erro faz a entrega ser
+// tentada de novo, a menos que seja permanente (permanentNotifyError).
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, event Event) error
+}
+
+// Falha que não melhora com nova tentativa (por exemplo, webhook respondendo 4xx)
+type permanentNotifyError struct {
+	err error
+}
+
+func (e permanentNotifyError) Error() string { return e.err.Error() }
+func (e permanentNotifyError) Unwrap() error { return e.err }
+
+// Função para converter uma lista como "run.completed,plan.stale" em tipos
+// de evento, recusando nomes desconhecidos
+func parseEventTypes(value string) ([]EventType, error) {
+	var types []EventType
+	for _, part := range strings.Split(value, ",") {
+		name := EventType(strings.TrimSpace(part))
+		if name == "" {
+			continue
+		}
+		known := false
+		for _, eventType := range eventTypes {
+			if eventType == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("evento desconhecido %q", name)
+		}
+		types = append(types, name)
+	}
+	return types, nil
+}
+
+// notifierSet publica cada evento para todos os canais registrados. Cada
+// canal recebe o evento em sua própria goroutine, com tentativas e timeout
+// independentes: a falha (ou o pânico) de um não afeta os outros nem quem
+// publicou.
+type notifierSet struct {
+	notifiers []Notifier
+	timeout   time.Duration
+	backoff   time.Duration
+	pending   sync.WaitGroup
+}
+
+// Função para registrar os canais configurados. O de log está sempre
+// presente, para os eventos aparecerem mesmo sem nenhum canal configurado.
+func newNotifierSet(cfg *Config) *notifierSet {
+	set := &notifierSet{notifiers: []Notifier{logNotifier{}}, timeout: cfg.RequestTimeout, backoff: notifyBackoff}
+	if cfg.NotifyWebhook.Value() != "" {
+		set.notifiers = append(set.notifiers, &webhookNotifier{
+			url:    cfg.NotifyWebhook,
+			events: cfg.NotifyWebhookEvents,
+			client: &http.Client{Timeout: 30 * time.Second},
+		})
+	}
+	names := make([]string, len(set.notifiers))
+	for i, notifier := range set.notifiers {
+		names[i] = notifier.Name()
+	}
+	log.Printf("[DEBUG] Canais de notificação: %s", strings.Join(names, ", "))
+	return set
+}
+
+// Função para saber se há algum canal além do log
+func (s *notifierSet) external() bool {
+	return s != nil && len(s.notifiers) > 1
+}
+
+// Função para publicar um evento sem bloquear quem chamou. Um conjunto nil
+// descarta o evento (simulação e exportação não notificam).
+func (s *notifierSet) publish(event Event) {
+	if s == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	for _, notifier := range s.notifiers {
+		s.pending.Add(1)
+		go func(notifier Notifier) {
+			defer s.pending.Done()
+			timeout := s.timeout
+			if timeout <= 0 {
+				timeout = 30 * time.Second
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			if err := s.deliver(ctx, notifier, event); err != nil {
+				log.Printf("[WARN] Notificação %s para o canal %s falhou: %v", event.Type, notifier.Name(), err)
+			}
+		}(notifier)
+	}
+}
+
+// Função para esperar as entregas em andamento (usada nos testes)
+func (s *notifierSet) wait() {
+	s.pending.Wait()
+}
+
+// Função para entregar um evento a um canal, com até notifyAttempts
+// tentativas. Falhas permanentes e o fim do prazo encerram as tentativas.
+func (s *notifierSet) deliver(ctx context.Context, notifier Notifier, event Event) (err error) {
+	wait := s.backoff
+	for attempt := 1; ; attempt++ {
+		err = notifySafely(ctx, notifier, event)
+		if err == nil {
+			return nil
+		}
+		var permanent permanentNotifyError
+		if attempt == notifyAttempts || errors.As(err, &permanent) {
+			return err
+		}
+		log.Printf("[DEBUG] Notificação %s para o canal %s falhou (tentativa %d de %d): %v", event.Type, notifier.Name(), attempt, notifyAttempts, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// Função para chamar o canal convertendo um pânico em erro
+func notifySafely(ctx context.Context, notifier Notifier, event Event) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = permanentNotifyError{fmt.Errorf("pânico no canal: %v", recovered)}
+		}
+	}()
+	return notifier.Notify(ctx, event)
+}
+
+// Canal que só escreve o evento no log
+type logNotifier struct{}
+
+func (logNotifier) Name() string { return "log" }
+
+func (logNotifier) Notify(ctx context.Context, event Event) error {
+	log.Printf("[DEBUG] Evento %s: %s", event.Type, event.Text)
+	return nil
+}
+
+// Canal de webhook de entrada do Teams ou do Slack (NOTIFY_WEBHOOK_URL),
+// limitado aos eventos de NOTIFY_WEBHOOK_EVENTS. A URL é lida a cada envio,
+// para valer a releitura de NOTIFY_WEBHOOK_URL_FILE.
+type webhookNotifier struct {
+	url    *secret
+	events []EventType
+	client *http.Client
+}
+
+func (n *webhookNotifier) Name() string { return "webhook" }
+
+func (n *webhookNotifier) Notify(ctx context.Context, event Event) error {
+	wanted := false
+	for _, eventType := range n.events {
+		if eventType == event.Type {
+			wanted = true
+			break
+		}
+	}
+	if !wanted {
+		return nil
+	}
+	return postWebhookText(ctx, n.client, strings.TrimSpace(n.url.Value()), event.Text)
+}
+
+// Função para publicar um texto em um webhook de entrada. Teams e Slack
+// aceitam o mesmo corpo mínimo {"text": "..."}. Respostas 4xx (fora 429)
+// são falhas permanentes.
+func postWebhookText(ctx context.Context, client *http.Client, webhookURL, text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Erro ao montar a chamada do webhook: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := client.Do(request)
+	if err != nil {
+		// A URL do webhook carrega o token de acesso e não vai para o log
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("Erro ao chamar o webhook: %w", err)
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		err := fmt.Errorf("webhook respondeu %d", response.StatusCode)
+		if response.StatusCode >= 400 && response.StatusCode < 500 && response.StatusCode != http.StatusTooManyRequests {
+			return permanentNotifyError{err}
+		}
+		return err
+	}
+	return nil
+}
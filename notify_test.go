@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Canal de teste que guarda os eventos recebidos e falha nas primeiras
+// failures chamadas com err (ou entra em pânico com panicking)
+type recordingNotifier struct {
+	name      string
+	err       error
+	failures  int
+	panicking bool
+
+	mu     sync.Mutex
+	calls  int
+	events []Event
+}
+
+func (n *recordingNotifier) Name() string {
+	if n.name == "" {
+		return "teste"
+	}
+	return n.name
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, event Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.calls++
+	if n.panicking {
+		panic("canal quebrado")
+	}
+	if n.calls <= n.failures {
+		return n.err
+	}
+	n.events = append(n.events, event)
+	return nil
+}
+
+// Função para ler os eventos recebidos sem disputar com as entregas
+func (n *recordingNotifier) received() []Event {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Event(nil), n.events...)
+}
+
+func TestNotifierSetDeliverRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		notifier  *recordingNotifier
+		wantErr   bool
+		wantCalls int
+	}{
+		{name: "entrega na primeira", notifier: &recordingNotifier{}, wantCalls: 1},
+		{name: "falha temporária", notifier: &recordingNotifier{err: errors.New("timeout"), failures: 2}, wantCalls: 3},
+		{name: "esgota as tentativas", notifier: &recordingNotifier{err: errors.New("timeout"), failures: 10}, wantErr: true, wantCalls: notifyAttempts},
+		{name: "falha permanente não repete", notifier: &recordingNotifier{err: permanentNotifyError{errors.New("webhook respondeu 404")}, failures: 10}, wantErr: true, wantCalls: 1},
+		{name: "pânico vira erro", notifier: &recordingNotifier{panicking: true}, wantErr: true, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := &notifierSet{backoff: time.Millisecond}
+			err := set.deliver(context.Background(), tt.notifier, Event{Type: EventRunCompleted})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("erro = %v, quer erro %t", err, tt.wantErr)
+			}
+			if tt.notifier.calls != tt.wantCalls {
+				t.Errorf("chamadas = %d, quer %d", tt.notifier.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestNotifierSetIsolatesNotifiers(t *testing.T) {
+	broken := &recordingNotifier{name: "quebrado", panicking: true}
+	failing := &recordingNotifier{name: "fora do ar", err: errors.New("connection refused"), failures: 10}
+	healthy := &recordingNotifier{name: "ok"}
+	set := &notifierSet{notifiers: []Notifier{broken, failing, logNotifier{}, healthy}, backoff: time.Millisecond}
+
+	set.publish(Event{Type: EventRunFailed, RunID: "run", Text: "falhou"})
+	set.wait()
+
+	events := healthy.received()
+	if len(events) != 1 || events[0].RunID != "run" || events[0].Time.IsZero() {
+		t.Errorf("eventos do canal saudável = %+v, quer o evento com horário", events)
+	}
+	if failing.calls != notifyAttempts {
+		t.Errorf("tentativas do canal fora do ar = %d, quer %d", failing.calls, notifyAttempts)
+	}
+
+	// Conjunto nil (simulação e exportação) descarta sem falhar
+	var none *notifierSet
+	none.publish(Event{Type: EventRunCompleted})
+	if none.external() {
+		t.Error("conjunto nil não tem canais")
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var texts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("corpo do webhook inválido: %v", err)
+		}
+		texts = append(texts, body.Text)
+	}))
+	defer server.Close()
+
+	notifier := &webhookNotifier{url: &secret{name: "NOTIFY_WEBHOOK_URL", value: server.URL}, events: defaultWebhookEvents, client: server.Client()}
+	for _, event := range []Event{
+		{Type: EventRunCompleted, Text: "concluída"},
+		{Type: EventDigestDue, Text: "resumo"},
+		{Type: EventPlanStale, Text: "desatualizado"},
+	} {
+		if err := notifier.Notify(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"concluída", "desatualizado"}; !reflect.DeepEqual(texts, want) {
+		t.Errorf("mensagens = %q, quer %q (digest.due fora do padrão)", texts, want)
+	}
+}
+
+func TestPostWebhookTextErrors(t *testing.T) {
+	status := http.StatusForbidden
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	var permanent permanentNotifyError
+	err := postWebhookText(context.Background(), server.Client(), server.URL+"/token-secreto", "oi")
+	if err == nil || !strings.Contains(err.Error(), "403") || !errors.As(err, &permanent) {
+		t.Errorf("erro = %v, quer resposta 403 permanente", err)
+	}
+	status = http.StatusBadGateway
+	err = postWebhookText(context.Background(), server.Client(), server.URL+"/token-secreto", "oi")
+	if err == nil || errors.As(err, &permanent) {
+		t.Errorf("erro = %v, quer 502 temporário", err)
+	}
+
+	server.Close()
+	err = postWebhookText(context.Background(), server.Client(), server.URL+"/token-secreto", "oi")
+	if err == nil || strings.Contains(err.Error(), "token-secreto") {
+		t.Errorf("erro = %v, quer falha sem a URL do webhook", err)
+	}
+}
+
+func TestGenerationEvent(t *testing.T) {
+	report := GenerationReport{RunID: "run", Sprint: "Sprint 7", SprintID: "id"}
+	report.Planned, report.Updated = 3, 3
+	if event := generationEvent(report, http.StatusOK); event.Type != EventRunCompleted || event.RunID != "run" || !strings.Contains(event.Text, "concluída") {
+		t.Errorf("evento = %+v, quer run.completed", event)
+	}
+	report.Updated, report.Failed = 2, 1
+	if event := generationEvent(report, http.StatusMultiStatus); event.Type != EventRunFailed || !strings.Contains(event.Text, "1 falhas") {
+		t.Errorf("evento = %+v, quer run.failed", event)
+	}
+}
+
+func TestParseEventTypes(t *testing.T) {
+	got, err := parseEventTypes(" run.failed, digest.due ,")
+	if err != nil || !reflect.DeepEqual(got, []EventType{EventRunFailed, EventDigestDue}) {
+		t.Errorf("eventos = %v, %v", got, err)
+	}
+	if _, err := parseEventTypes("run.failed,run.started"); err == nil {
+		t.Error("evento desconhecido deveria falhar")
+	}
+}
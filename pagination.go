@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// Tamanho de página quando só ?offset= é informado
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// Paginação pedida na query string (?limit=N&offset=M). Enabled fica falso
+// quando nenhum dos dois vem, e a resposta continua com todos os itens.
+type pageRequest struct {
+	Limit   int
+	Offset  int
+	Enabled bool
+}
+
+// Dados da página devolvidos junto com os itens. NextOffset é o offset da
+// próxima página, null na última.
+type PageInfo struct {
+	Total      int  `json:"total"`
+	Offset     int  `json:"offset"`
+	Limit      int  `json:"limit"`
+	NextOffset *int `json:"nextOffset"`
+}
+
+// Resposta paginada de listas que sem paginação são um array simples
+type PagedResponse struct {
+	Items interface{} `json:"items"`
+	PageInfo
+}
+
+// Função para ler ?limit= e ?offset= da query string. Valores inválidos são
+// erro, e não ajustados, para o cliente não pular itens sem perceber.
+func pageFromRequest(r *http.Request) (pageRequest, error) {
+	page := pageRequest{Limit: defaultPageLimit}
+	query := r.URL.Query()
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxPageLimit {
+			return page, fmt.Errorf("Parâmetro 'limit' inválido: %q (use um inteiro entre 1 e %d)", value, maxPageLimit)
+		}
+		page.Limit = n
+		page.Enabled = true
+	}
+	if value := query.Get("offset"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return page, fmt.Errorf("Parâmetro 'offset' inválido: %q (use um inteiro maior ou igual a 0)", value)
+		}
+		page.Offset = n
+		page.Enabled = true
+	}
+	return page, nil
+}
+
+// Função para obter o intervalo [start, end) da página numa lista de total
+// itens já filtrada e ordenada. Um offset além do fim devolve página vazia.
+func (p pageRequest) bounds(total int) (int, int, PageInfo) {
+	info := PageInfo{Total: total, Offset: p.Offset, Limit: p.Limit}
+	start := p.Offset
+	if start > total {
+		start = total
+	}
+	end := start + p.Limit
+	if end >= total {
+		end = total
+	} else {
+		info.NextOffset = &end
+	}
+	return start, end, info
+}
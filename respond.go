@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Função para responder JSON de forma atômica: serializa em memória antes de
+// escrever qualquer coisa, então o cliente recebe ou um corpo íntegro ou um
+// erro limpo, nunca os dois misturados.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("[ERROR] Erro ao codificar resposta JSON: %v", err)
+		status = http.StatusInternalServerError
+		body, _ = json.Marshal(map[string]string{"error": "Erro ao processar resposta"})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	body = append(body, '\n')
+	if _, err := w.Write(body); err != nil {
+		log.Printf("[ERROR] Erro ao escrever resposta: %v", err)
+	}
+}
+
+// Função para retornar erro em formato JSON
+func jsonError(w http.ResponseWriter, message string, code int) {
+	writeJSON(w, code, map[string]string{"error": message})
+}
+
+// Função para registrar e responder um erro, escolhendo o status HTTP pelo
+// tipo do erro. message descreve o que o handler estava fazendo e pode ser
+// vazia quando o próprio erro já é autoexplicativo.
+func respondError(w http.ResponseWriter, message string, err error) {
+	if message != "" {
+		message = fmt.Sprintf("%s: %v", message, err)
+	} else {
+		message = err.Error()
+	}
+	log.Printf("%s", message)
+	jsonError(w, message, statusForError(err))
+}
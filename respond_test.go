@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteJSON(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	writeJSON(recorder, http.StatusCreated, map[string]int{"id": 7})
+
+	if recorder.Code != http.StatusCreated {
+		t.Errorf("status = %d, quer 201", recorder.Code)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Content-Type = %q", contentType)
+	}
+	if body := recorder.Body.String(); body != "{\"id\":7}\n" {
+		t.Errorf("corpo = %q", body)
+	}
+}
+
+func TestWriteJSONMarshalFailure(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{name: "NaN", value: map[string]float64{"capacity": math.NaN()}},
+		{name: "infinito", value: struct{ Hours float64 }{Hours: math.Inf(1)}},
+		{name: "canal", value: map[string]interface{}{"events": make(chan int)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			writeJSON(recorder, http.StatusOK, tt.value)
+
+			if recorder.Code != http.StatusInternalServerError {
+				t.Errorf("status = %d, quer 500", recorder.Code)
+			}
+			// O corpo é só o erro, sem pedaços da resposta que falhou
+			var body map[string]string
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+				t.Fatalf("corpo não é JSON válido: %q", recorder.Body.String())
+			}
+			if len(body) != 1 || body["error"] != "Erro ao processar resposta" {
+				t.Errorf("corpo = %v", body)
+			}
+			if strings.Contains(recorder.Body.String(), "capacity") {
+				t.Errorf("corpo mistura a resposta original: %q", recorder.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+// Revisões pedidas por página em GetRevisions e GetUpdates; itens antigos
+// passam de centenas
+const revisionsPageSize = 200
+
+// Função para buscar todas as revisões de um work item, página a página,
+// da mais antiga para a mais recente
+func fetchRevisions(ctx context.Context, witClient workitemtracking.Client, project string, id int) ([]workitemtracking.WorkItem, error) {
+	var revisions []workitemtracking.WorkItem
+	top := revisionsPageSize
+	for {
+		skip := len(revisions)
+		page, err := witClient.GetRevisions(ctx, workitemtracking.GetRevisionsArgs{
+			Id:      &id,
+			Project: &project,
+			Top:     &top,
+			Skip:    &skip,
+		})
+		if err != nil {
+			return nil, wrapAdoError(err, "GetRevisions", "id=%d skip=%d", id, skip)
+		}
+		if page == nil || len(*page) == 0 {
+			return revisions, nil
+		}
+		revisions = append(revisions, *page...)
+		if len(*page) < top {
+			return revisions, nil
+		}
+	}
+}
+
+// Função para encontrar quando o estado mudou pela primeira vez, saindo do
+// estado em que o item foi criado. Devolve false quando nunca mudou.
+func firstStateTransition(revisions []workitemtracking.WorkItem) (time.Time, bool) {
+	if len(revisions) == 0 {
+		return time.Time{}, false
+	}
+	initial := getFieldValue(revisions[0].Fields, "System.State")
+	for _, revision := range revisions[1:] {
+		if getFieldValue(revision.Fields, "System.State") == initial {
+			continue
+		}
+		if changed := getFieldTime(revision.Fields, "System.ChangedDate"); changed != nil {
+			return *changed, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Função para buscar todas as atualizações de um work item (o que mudou em
+// cada revisão), página a página, da mais antiga para a mais recente
+func fetchUpdates(ctx context.Context, witClient workitemtracking.Client, project string, id int) ([]workitemtracking.WorkItemUpdate, error) {
+	var updates []workitemtracking.WorkItemUpdate
+	top := revisionsPageSize
+	for {
+		skip := len(updates)
+		page, err := witClient.GetUpdates(ctx, workitemtracking.GetUpdatesArgs{
+			Id:      &id,
+			Project: &project,
+			Top:     &top,
+			Skip:    &skip,
+		})
+		if err != nil {
+			return nil, wrapAdoError(err, "GetUpdates", "id=%d skip=%d", id, skip)
+		}
+		if page == nil || len(*page) == 0 {
+			return updates, nil
+		}
+		updates = append(updates, *page...)
+		if len(*page) < top {
+			return updates, nil
+		}
+	}
+}
+
+// Alteração de um campo de data em GET /work-items/{id}/due-date-history
+type DueDateChange struct {
+	Revision  int        `json:"revision"`
+	Field     string     `json:"field"`
+	OldValue  *time.Time `json:"oldValue"`
+	NewValue  *time.Time `json:"newValue"`
+	ChangedBy *Identity  `json:"changedBy"`
+	ChangedAt *time.Time `json:"changedAt"`
+}
+
+// Resposta de GET /work-items/{id}/due-date-history
+type DueDateHistoryResponse struct {
+	ID      int             `json:"id"`
+	Changes []DueDateChange `json:"changes"`
+}
+
+// Função para converter o valor antigo ou novo de uma atualização em data;
+// nil quando vazio ou ilegível
+func updateTimeValue(value interface{}) *time.Time {
+	if value == nil {
+		return nil
+	}
+	fields := map[string]interface{}{"value": value}
+	return getFieldTime(&fields, "value")
+}
+
+// Função para converter a identidade de quem fez a revisão
+func identityFromReference(ref *workitemtracking.IdentityReference) *Identity {
+	if ref == nil {
+		return nil
+	}
+	identity := &Identity{}
+	if ref.DisplayName != nil {
+		identity.DisplayName = *ref.DisplayName
+	}
+	if ref.UniqueName != nil {
+		identity.UniqueName = *ref.UniqueName
+	}
+	if ref.ImageUrl != nil {
+		identity.ImageUrl = *ref.ImageUrl
+	}
+	if ref.Descriptor != nil {
+		identity.Descriptor = *ref.Descriptor
+	}
+	return identity
+}
+
+// Função para extrair, em ordem, as alterações dos campos de dueDateFields
+func dueDateChanges(updates []workitemtracking.WorkItemUpdate) []DueDateChange {
+	changes := make([]DueDateChange, 0)
+	for _, update := range updates {
+		if update.Fields == nil {
+			continue
+		}
+		for _, field := range dueDateFields {
+			fieldUpdate, ok := (*update.Fields)[field]
+			if !ok {
+				continue
+			}
+			change := DueDateChange{
+				Field:     field,
+				OldValue:  updateTimeValue(fieldUpdate.OldValue),
+				NewValue:  updateTimeValue(fieldUpdate.NewValue),
+				ChangedBy: identityFromReference(update.RevisedBy),
+			}
+			if update.Rev != nil {
+				change.Revision = *update.Rev
+			}
+			// RevisedDate da última revisão é 9999-01-01; System.ChangedDate traz o horário real
+			if changed, ok := (*update.Fields)["System.ChangedDate"]; ok {
+				change.ChangedAt = updateTimeValue(changed.NewValue)
+			}
+			if change.ChangedAt == nil && update.RevisedDate != nil {
+				change.ChangedAt = &update.RevisedDate.Time
+			}
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// Handler de GET /work-items/{id}/due-date-history: quem mudou as datas
+// (DueDate, TargetDate e Common.DueDate) de um work item, e quando
+func handleDueDateHistory(pool *adoPool, cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			jsonError(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		rest := strings.TrimPrefix(r.URL.Path, "/work-items/")
+		idText, _, _ := strings.Cut(rest, "/")
+		id, err := strconv.Atoi(idText)
+		if err != nil || id <= 0 {
+			jsonError(w, fmt.Sprintf("ID de work item inválido: %q", idText), http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		witClient, err := pool.WorkItems(ctx, "due-date-history")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+		updates, err := fetchUpdates(ctx, witClient, cfg.Project, id)
+		if err != nil {
+			respondError(w, fmt.Sprintf("Erro ao buscar o histórico do work item #%d", id), err)
+			return
+		}
+		response := DueDateHistoryResponse{ID: id, Changes: dueDateChanges(updates)}
+		log.Printf("[DEBUG] Histórico de datas do work item #%d: %d alterações em %d revisões", id, len(response.Changes), len(updates))
+		writeJSON(w, http.StatusOK, response)
+	}
+}
@@ -0,0 +1,694 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Origem de uma execução que gravou datas
+const (
+	runSourcePatch    = "patch"
+	runSourceBatch    = "batch"
+	runSourceRollback = "rollback"
+	runSourceGenerate = "generate"
+)
+
+// DueDateRun registra uma execução que gravou DueDate, com o valor anterior
+// de cada item, para permitir desfazê-la com POST /runs/{id}/rollback.
+// Gerações (source generate) são registradas sempre, inclusive em dry-run,
+// com o relatório completo em Report.
+type DueDateRun struct {
+	ID        string    `json:"id"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"createdAt"`
+	Sprint    string    `json:"sprint,omitempty"`
+	SprintID  string    `json:"sprintId,omitempty"`
+	// ID do time da geração (AZURE_DEVOPS_TEAM ou ?team=); vazio nas gravações
+	// avulsas e nas execuções anteriores aos perfis por time, que valem para todos
+	Team string `json:"team,omitempty"`
+	// Campo gravado pela geração (DUE_DATE_WRITE_FIELD); vazio é o DueDate
+	Field        string            `json:"field,omitempty"`
+	DryRun       bool              `json:"dryRun,omitempty"`
+	Caller       *RunCaller        `json:"caller,omitempty"`
+	Items        []DueDateRunItem  `json:"items"`
+	RolledBackAt *time.Time        `json:"rolledBackAt,omitempty"`
+	Report       *GenerationReport `json:"report,omitempty"`
+	// Pedido e aprovação das gerações com REQUIRE_APPROVAL, e o registro
+	// de auditoria das duas ações
+	Approval *RunApproval    `json:"approval,omitempty"`
+	Audit    []RunAuditEntry `json:"audit,omitempty"`
+}
+
+// Quem pediu a execução. O X-Admin-Key pode ser compartilhado, então o nome vem do
+// header opcional X-Requested-By.
+type RunCaller struct {
+	RequestedBy  string `json:"requestedBy,omitempty"`
+	RemoteAddr   string `json:"remoteAddr,omitempty"`
+	ForwardedFor string `json:"forwardedFor,omitempty"`
+	UserAgent    string `json:"userAgent,omitempty"`
+}
+
+// Função para obter os dados do chamador de uma requisição
+func runCallerFromRequest(r *http.Request) RunCaller {
+	return RunCaller{
+		RequestedBy:  strings.TrimSpace(r.Header.Get("X-Requested-By")),
+		RemoteAddr:   r.RemoteAddr,
+		ForwardedFor: r.Header.Get("X-Forwarded-For"),
+		UserAgent:    r.UserAgent(),
+	}
+}
+
+// Resumo de uma execução em GET /runs
+type DueDateRunSummary struct {
+	ID           string            `json:"id"`
+	Source       string            `json:"source"`
+	CreatedAt    time.Time         `json:"createdAt"`
+	Sprint       string            `json:"sprint,omitempty"`
+	SprintID     string            `json:"sprintId,omitempty"`
+	Team         string            `json:"team,omitempty"`
+	Strategy     string            `json:"strategy,omitempty"`
+	DryRun       bool              `json:"dryRun,omitempty"`
+	RequestedBy  string            `json:"requestedBy,omitempty"`
+	Items        int               `json:"items"`
+	Counts       *GenerationCounts `json:"counts,omitempty"`
+	RolledBackAt *time.Time        `json:"rolledBackAt,omitempty"`
+	// Situação da aprovação (pending, approved ou expired), só com REQUIRE_APPROVAL
+	Approval string `json:"approval,omitempty"`
+}
+
+// Limites de retenção das execuções (RUNS_RETENTION_COUNT e
+// RUNS_RETENTION_DAYS); zero não limita
+type runRetention struct {
+	MaxRuns int
+	MaxAge  time.Duration
+}
+
+// Item gravado em uma execução. RolledBack marca os itens já revertidos,
+// para que um rollback parcial possa ser repetido só com o que falhou.
+type DueDateRunItem struct {
+	ID              int        `json:"id"`
+	PreviousDueDate *time.Time `json:"previousDueDate"`
+	NewDueDate      *time.Time `json:"newDueDate"`
+	RolledBack      bool       `json:"rolledBack,omitempty"`
+}
+
+// runStore guarda as execuções em memória e, com DUE_DATE_RUNS_FILE, também
+// em um arquivo JSON regravado a cada alteração
+type runStore struct {
+	mu        sync.Mutex
+	path      string
+	retention runRetention
+	runs      map[string]*DueDateRun
+	// Execuções com rollback em andamento, para não reverter duas vezes em paralelo
+	active map[string]bool
+	// Gerações com escrita em andamento, por ID da sprint
+	generating map[string]*generationLock
+}
+
+// Geração em andamento em uma sprint; done é fechado ao terminar
+type generationLock struct {
+	runID string
+	done  chan struct{}
+}
+
+// Tempo máximo de espera por outra geração na mesma sprint com ?wait=true
+const maxGenerationLockWait = 30 * time.Second
+
+// Função para criar o registro de execuções, carregando o arquivo quando existir
+func newRunStore(path string, retention runRetention) (*runStore, error) {
+	store := &runStore{
+		path:       path,
+		retention:  retention,
+		runs:       make(map[string]*DueDateRun),
+		active:     make(map[string]bool),
+		generating: make(map[string]*generationLock),
+	}
+	if path == "" {
+		return store, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Erro ao ler DUE_DATE_RUNS_FILE (%s): %w", path, err)
+	}
+	var runs []*DueDateRun
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil, fmt.Errorf("DUE_DATE_RUNS_FILE inválido (%s): %w", path, err)
+	}
+	for _, run := range runs {
+		store.runs[run.ID] = run
+	}
+	store.prune()
+	log.Printf("[DEBUG] %d execuções de datas carregadas de %s", len(runs), path)
+	return store, nil
+}
+
+// Função para registrar uma execução do time (ID) com os itens efetivamente
+// gravados em field, que o rollback usa. Sem itens nada é registrado e o ID
+// volta vazio.
+func (s *runStore) record(source, team, field string, items []DueDateRunItem) string {
+	if len(items) == 0 {
+		return ""
+	}
+	run := &DueDateRun{
+		ID:        uuid.New().String(),
+		Source:    source,
+		CreatedAt: time.Now().UTC(),
+		Team:      team,
+		Field:     field,
+		Items:     items,
+	}
+	s.add(run)
+	return run.ID
+}
+
+// Função para registrar uma geração de datas do time (ID) com o relatório
+// completo, mesmo em dry-run ou sem itens gravados. O ID da execução é o
+// RunID do relatório.
+func (s *runStore) recordGeneration(report GenerationReport, team string, caller RunCaller, items []DueDateRunItem) {
+	if items == nil {
+		items = []DueDateRunItem{}
+	}
+	s.add(&DueDateRun{
+		ID:        report.RunID,
+		Source:    runSourceGenerate,
+		CreatedAt: report.FinishedAt,
+		Sprint:    report.Sprint,
+		SprintID:  report.SprintID,
+		Team:      team,
+		Field:     report.DueDateField,
+		DryRun:    report.DryRun,
+		Caller:    &caller,
+		Items:     items,
+		Report:    &report,
+	})
+}
+
+func (s *runStore) add(run *DueDateRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// A geração aprovada substitui a pendente com o mesmo ID e mantém quem
+	// pediu, a aprovação e a auditoria
+	if pending, ok := s.runs[run.ID]; ok && pending.Approval != nil && run.Approval == nil {
+		run.Caller, run.Approval, run.Audit = pending.Caller, pending.Approval, pending.Audit
+		if run.Report != nil {
+			approval := *pending.Approval
+			run.Report.Approval = &approval
+		}
+	}
+	s.runs[run.ID] = run
+	s.prune()
+	s.persist()
+}
+
+// Função para obter uma cópia de uma execução
+func (s *runStore) get(id string) (DueDateRun, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[id]
+	if !ok {
+		return DueDateRun{}, false
+	}
+	return run.copy(time.Now()), true
+}
+
+// Função para copiar uma execução, com a situação da aprovação no momento
+// now (pendentes vencidas aparecem como expired). Chamar com mu travado.
+func (run *DueDateRun) copy(now time.Time) DueDateRun {
+	copied := *run
+	copied.Items = append([]DueDateRunItem(nil), run.Items...)
+	copied.Audit = append([]RunAuditEntry(nil), run.Audit...)
+	if run.Approval != nil {
+		approval := *run.Approval
+		approval.Status = approval.statusAt(now)
+		copied.Approval = &approval
+	}
+	return copied
+}
+
+// Função para saber se a execução é do time (ID); execuções sem time valem
+// para todos
+func (run *DueDateRun) ofTeam(team string) bool {
+	return run.Team == "" || team == "" || strings.EqualFold(run.Team, team)
+}
+
+// Função para listar os resumos das execuções do time, da mais recente para
+// a mais antiga. sprint aceita o nome ou o ID da sprint; filtros vazios não
+// filtram.
+func (s *runStore) list(team, sprint, source string) []DueDateRunSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := s.sorted()
+	summaries := make([]DueDateRunSummary, 0, len(runs))
+	for i := len(runs) - 1; i >= 0; i-- {
+		run := runs[i]
+		if !run.ofTeam(team) || (sprint != "" && !strings.EqualFold(run.Sprint, sprint) && !strings.EqualFold(run.SprintID, sprint)) {
+			continue
+		}
+		if source != "" && !strings.EqualFold(run.Source, source) {
+			continue
+		}
+		summary := DueDateRunSummary{
+			ID:           run.ID,
+			Source:       run.Source,
+			CreatedAt:    run.CreatedAt,
+			Sprint:       run.Sprint,
+			SprintID:     run.SprintID,
+			Team:         run.Team,
+			DryRun:       run.DryRun,
+			Items:        len(run.Items),
+			RolledBackAt: run.RolledBackAt,
+		}
+		if run.Caller != nil {
+			summary.RequestedBy = run.Caller.RequestedBy
+		}
+		if run.Approval != nil {
+			summary.Approval = run.Approval.statusAt(time.Now())
+		}
+		if run.Report != nil {
+			counts := run.Report.GenerationCounts
+			summary.Strategy, summary.Counts = run.Report.Strategy, &counts
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// Função para obter, por ID de sprint, o horário da última geração do time
+// que não foi dry-run
+func (s *runStore) lastGenerated(team string) map[string]time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last := make(map[string]time.Time)
+	for _, run := range s.runs {
+		if run.Source != runSourceGenerate || run.DryRun || run.SprintID == "" || !run.ofTeam(team) {
+			continue
+		}
+		if run.CreatedAt.After(last[run.SprintID]) {
+			last[run.SprintID] = run.CreatedAt
+		}
+	}
+	return last
+}
+
+// Função para obter a última geração do time na sprint que gravou datas
+// (fora dry-run e sem rollback completo)
+func (s *runStore) lastGeneration(team, sprintID string) (DueDateRun, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := s.sorted()
+	for i := len(runs) - 1; i >= 0; i-- {
+		run := runs[i]
+		if run.Source == runSourceGenerate && !run.DryRun && run.RolledBackAt == nil && strings.EqualFold(run.SprintID, sprintID) && run.ofTeam(team) {
+			return run.copy(time.Now()), true
+		}
+	}
+	return DueDateRun{}, false
+}
+
+// Função para obter, por work item, a última data gravada por gerações do
+// time na sprint (fora dry-run), ignorando itens já revertidos
+func (s *runStore) generatedDueDates(team, sprintID string) map[int]time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dates := make(map[int]time.Time)
+	for _, run := range s.sorted() {
+		if run.Source != runSourceGenerate || run.DryRun || !strings.EqualFold(run.SprintID, sprintID) || !run.ofTeam(team) {
+			continue
+		}
+		for _, item := range run.Items {
+			if item.NewDueDate != nil && !item.RolledBack {
+				dates[item.ID] = *item.NewDueDate
+			}
+		}
+	}
+	return dates
+}
+
+// Função para reservar o rollback de uma execução; false se já houver um em andamento
+func (s *runStore) startRollback(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[id] {
+		return false
+	}
+	s.active[id] = true
+	return true
+}
+
+func (s *runStore) finishRollback(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.active, id)
+}
+
+// Função para reservar a sprint para uma geração. Com outra geração em
+// andamento na mesma sprint, devolve o ID dela e false; com wait, espera ela
+// terminar até maxGenerationLockWait ou o fim de ctx. release deve ser chamado
+// (com defer) quando a reserva é obtida.
+func (s *runStore) startGeneration(ctx context.Context, sprintID, runID string, wait bool) (release func(), inFlight string, ok bool) {
+	timer := time.NewTimer(maxGenerationLockWait)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		current := s.generating[sprintID]
+		if current == nil {
+			lock := &generationLock{runID: runID, done: make(chan struct{})}
+			s.generating[sprintID] = lock
+			s.mu.Unlock()
+			var once sync.Once
+			return func() {
+				once.Do(func() {
+					s.mu.Lock()
+					delete(s.generating, sprintID)
+					s.mu.Unlock()
+					close(lock.done)
+				})
+			}, "", true
+		}
+		s.mu.Unlock()
+		if !wait {
+			return nil, current.runID, false
+		}
+		select {
+		case <-current.done:
+		case <-timer.C:
+			return nil, current.runID, false
+		case <-ctx.Done():
+			return nil, current.runID, false
+		}
+	}
+}
+
+// Função para marcar itens como revertidos e, quando todos estiverem, a execução
+func (s *runStore) markRolledBack(id string, itemIds map[int]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[id]
+	if !ok {
+		return
+	}
+	complete := true
+	for i := range run.Items {
+		if itemIds[run.Items[i].ID] {
+			run.Items[i].RolledBack = true
+		}
+		complete = complete && run.Items[i].RolledBack
+	}
+	if complete {
+		now := time.Now().UTC()
+		run.RolledBackAt = &now
+	}
+	s.persist()
+}
+
+// Função para descartar as execuções fora da retenção (mais antigas que
+// MaxAge ou além das MaxRuns mais recentes). Dry-runs têm a sua própria
+// contagem, para que simulações repetidas não tirem de /runs execuções que
+// gravaram datas e ainda podem ser desfeitas. Chamar com mu travado.
+func (s *runStore) prune() {
+	runs := s.sorted()
+	if s.retention.MaxAge > 0 {
+		cutoff := time.Now().Add(-s.retention.MaxAge)
+		for len(runs) > 0 && runs[0].CreatedAt.Before(cutoff) {
+			delete(s.runs, runs[0].ID)
+			runs = runs[1:]
+		}
+	}
+	if s.retention.MaxRuns <= 0 {
+		return
+	}
+	var written, dryRuns []*DueDateRun
+	for _, run := range runs {
+		if run.DryRun {
+			dryRuns = append(dryRuns, run)
+		} else {
+			written = append(written, run)
+		}
+	}
+	for _, group := range [][]*DueDateRun{written, dryRuns} {
+		if len(group) > s.retention.MaxRuns {
+			for _, run := range group[:len(group)-s.retention.MaxRuns] {
+				delete(s.runs, run.ID)
+			}
+		}
+	}
+}
+
+// Função para listar as execuções da mais antiga para a mais recente. Chamar com mu travado.
+func (s *runStore) sorted() []*DueDateRun {
+	runs := make([]*DueDateRun, 0, len(s.runs))
+	for _, run := range s.runs {
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].CreatedAt.Before(runs[j].CreatedAt)
+	})
+	return runs
+}
+
+// Função para gravar o arquivo de execuções (escreve em um temporário e
+// renomeia). Falhas só vão para o log: o registro em memória continua valendo.
+// Chamar com mu travado.
+func (s *runStore) persist() {
+	if s.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(s.sorted(), "", "  ")
+	if err != nil {
+		log.Printf("[ERROR] Erro ao codificar execuções de datas: %v", err)
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		log.Printf("[ERROR] Erro ao gravar %s: %v", s.path, err)
+		return
+	}
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr != nil || closeErr != nil {
+		os.Remove(tmp.Name())
+		log.Printf("[ERROR] Erro ao gravar %s: %v", s.path, errors.Join(writeErr, closeErr))
+		return
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("[ERROR] Erro ao gravar %s: %v", s.path, err)
+	}
+}
+
+// Resposta de POST /runs/{id}/rollback
+type RollbackResponse struct {
+	RunID         string               `json:"runId"`
+	RollbackRunID string               `json:"rollbackRunId,omitempty"`
+	Message       string               `json:"message,omitempty"`
+	Updated       int                  `json:"updated"`
+	Skipped       int                  `json:"skipped"`
+	Failed        int                  `json:"failed"`
+	Conflicts     int                  `json:"conflicts"` // ignorados porque o DueDate mudou depois da execução; contam também em skipped
+	Results       []DueDateBatchResult `json:"results"`
+}
+
+// Handler das rotas de /runs: GET /runs, GET /runs/{id},
+// POST /runs/{id}/rollback e POST /runs/{id}/approve
+func handleRuns(pool *adoPool, cfg *Config, runs *runStore, idempotency *idempotencyStore, events *notifierSet) http.HandlerFunc {
+	rollback := handleRunRollback(pool, cfg, runs)
+	// A aprovação repete a geração pedida sem o Idempotency-Key do pedido,
+	// mas com o mesmo store da geração
+	approve := handleRunApprove(cfg, runs, generationHandler(pool, cfg, runs, idempotency, events, generationWrite))
+	return func(w http.ResponseWriter, r *http.Request) {
+		runID, suffix, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/runs"), "/"), "/")
+		if suffix == "approve" {
+			approve(w, r)
+			return
+		}
+		if suffix != "" {
+			rollback(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			jsonError(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		if runID == "" {
+			query := r.URL.Query()
+			sprint := strings.TrimSpace(query.Get("sprint"))
+			if sprint == "" {
+				sprint = strings.TrimSpace(query.Get("sprintId"))
+			}
+			writeJSON(w, http.StatusOK, runs.list(cfg.Team, sprint, strings.TrimSpace(query.Get("source"))))
+			return
+		}
+		run, ok := runs.get(runID)
+		if !ok || !run.ofTeam(cfg.Team) {
+			jsonError(w, fmt.Sprintf("Execução '%s' não encontrada", runID), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, run)
+	}
+}
+
+// Handler de POST /runs/{id}/rollback: regrava o DueDate anterior de cada item
+// da execução (limpando o campo de quem não tinha data). Itens já revertidos
+// são ignorados, então repetir o rollback não altera nada. Itens cujo DueDate
+// foi editado depois da execução ficam como estão e voltam como conflito,
+// exceto com ?force=true; a gravação testa a revisão lida (/rev) para não
+// sobrescrever uma edição feita durante o rollback.
+func handleRunRollback(pool *adoPool, cfg *Config, runs *runStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			jsonError(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		runID, suffix, found := strings.Cut(strings.TrimPrefix(r.URL.Path, "/runs/"), "/")
+		if !found || suffix != "rollback" || runID == "" {
+			jsonError(w, "Rota não encontrada", http.StatusNotFound)
+			return
+		}
+
+		if !runs.startRollback(runID) {
+			jsonError(w, fmt.Sprintf("Rollback da execução '%s' já está em andamento", runID), http.StatusConflict)
+			return
+		}
+		defer runs.finishRollback(runID)
+
+		run, ok := runs.get(runID)
+		if !ok || !run.ofTeam(cfg.Team) {
+			jsonError(w, fmt.Sprintf("Execução '%s' não encontrada", runID), http.StatusNotFound)
+			return
+		}
+		response := RollbackResponse{RunID: runID, Results: make([]DueDateBatchResult, 0, len(run.Items))}
+		if len(run.Items) == 0 {
+			response.Message = fmt.Sprintf("Execução '%s' não gravou datas; nada foi alterado", runID)
+			writeJSON(w, http.StatusOK, response)
+			return
+		}
+		if run.RolledBackAt != nil {
+			response.Message = fmt.Sprintf("Execução '%s' já foi revertida em %s; nada foi alterado", runID, run.RolledBackAt.Format(time.RFC3339))
+			writeJSON(w, http.StatusOK, response)
+			return
+		}
+
+		force := r.URL.Query().Get("force") == "true"
+
+		ctx := r.Context()
+		witClient, err := pool.WorkItems(ctx, "rollback")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+
+		// Valor atual de cada item, para o relatório e para o novo registro,
+		// no campo que a execução gravou
+		field := run.Field
+		if field == "" {
+			field = dueDateField
+		}
+		var ids []int
+		for _, item := range run.Items {
+			if !item.RolledBack {
+				ids = append(ids, item.ID)
+			}
+		}
+		current := make(map[int]*time.Time)
+		revisions := make(map[int]int)
+		if len(ids) > 0 {
+			workItems, err := getWorkItemsChunked(ctx, witClient, cfg.Project, ids, []string{field})
+			if err != nil {
+				respondError(w, "Erro ao buscar os work items da execução", err)
+				return
+			}
+			for _, workItem := range presentWorkItems(ids, workItems) {
+				current[*workItem.Id] = currentFieldDate(workItem.Fields, field)
+				if workItem.Rev != nil {
+					revisions[*workItem.Id] = *workItem.Rev
+				}
+			}
+		}
+
+		reverted := make(map[int]bool)
+		var written []DueDateRunItem
+		wrote := false
+		for _, item := range run.Items {
+			result := DueDateBatchResult{DueDateUpdate: DueDateUpdate{ID: item.ID, NewDueDate: item.PreviousDueDate}}
+			switch {
+			case item.RolledBack:
+				result.Status, result.Reason = dueDateSkipped, "já revertido"
+			case sameDueDate(current[item.ID], item.PreviousDueDate):
+				result.OldDueDate = current[item.ID]
+				result.Status, result.Reason = dueDateSkipped, "já está com o valor anterior"
+				reverted[item.ID] = true
+			case revisions[item.ID] == 0:
+				result.Status, result.Reason = dueDateSkipped, "work item não encontrado (provavelmente excluído)"
+			case !force && !sameDueDate(current[item.ID], item.NewDueDate):
+				result.OldDueDate = current[item.ID]
+				result.Status, result.Reason = dueDateSkipped, fmt.Sprintf("conflito: DueDate alterado depois da execução (gravado %s, atual %s); mantido, use ?force=true para reverter mesmo assim",
+					formatDueDate(item.NewDueDate), formatDueDate(current[item.ID]))
+				response.Conflicts++
+			default:
+				if wrote {
+					select {
+					case <-time.After(dueDateBatchInterval):
+					case <-ctx.Done():
+					}
+				}
+				wrote = true
+				document := append(revisionTest(revisions[item.ID]), fieldDatePatch(field, item.PreviousDueDate)...)
+				update, err := patchDueDate(ctx, witClient, cfg.Project, item.ID, field, document, current[item.ID], item.PreviousDueDate)
+				result.DueDateUpdate = update
+				switch {
+				case errors.Is(err, ErrConcurrentModification):
+					log.Printf("[WARN] Work item #%d alterado durante o rollback da execução %s: %v", item.ID, runID, err)
+					result.Status, result.Reason = dueDateSkipped, "conflito: work item alterado durante o rollback; mantido"
+					response.Conflicts++
+				case err != nil:
+					log.Printf("[ERROR] %v", err)
+					result.Status, result.Reason = dueDateFailed, err.Error()
+				default:
+					result.Status = dueDateUpdated
+					reverted[item.ID] = true
+					written = append(written, DueDateRunItem{ID: item.ID, PreviousDueDate: current[item.ID], NewDueDate: item.PreviousDueDate})
+				}
+			}
+
+			switch result.Status {
+			case dueDateUpdated:
+				response.Updated++
+			case dueDateSkipped:
+				response.Skipped++
+			case dueDateFailed:
+				response.Failed++
+			}
+			response.Results = append(response.Results, result)
+		}
+
+		runs.markRolledBack(runID, reverted)
+		// O rollback fica no time e no campo da execução revertida
+		team := run.Team
+		if team == "" {
+			team = cfg.Team
+		}
+		response.RollbackRunID = runs.record(runSourceRollback, team, field, written)
+		log.Printf("[DEBUG] Rollback da execução %s: %d revertidos, %d ignorados (%d conflitos), %d falhas", runID, response.Updated, response.Skipped, response.Conflicts, response.Failed)
+
+		status := http.StatusOK
+		if response.Failed > 0 {
+			status = http.StatusMultiStatus
+		}
+		writeJSON(w, status, response)
+	}
+}
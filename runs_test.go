@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/webapi"
+)
+
+func TestRunStorePruneCountsDryRunsSeparately(t *testing.T) {
+	store, err := newRunStore("", runRetention{MaxRuns: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	add := func(id string, dryRun bool, minutes int) {
+		store.add(&DueDateRun{ID: id, Source: runSourceGenerate, DryRun: dryRun, CreatedAt: base.Add(time.Duration(minutes) * time.Minute)})
+	}
+
+	add("written-1", false, 0)
+	add("written-2", false, 1)
+	for i := 0; i < 5; i++ {
+		add(fmt.Sprintf("dry-%d", i), true, 10+i)
+	}
+
+	for _, id := range []string{"written-1", "written-2", "dry-3", "dry-4"} {
+		if _, ok := store.get(id); !ok {
+			t.Errorf("execução %s descartada, mas deveria ficar", id)
+		}
+	}
+	for _, id := range []string{"dry-0", "dry-1", "dry-2"} {
+		if _, ok := store.get(id); ok {
+			t.Errorf("execução %s mantida além do limite de dry-runs", id)
+		}
+	}
+
+	add("written-3", false, 20)
+	if _, ok := store.get("written-1"); ok {
+		t.Error("written-1 deveria sair ao passar do limite de execuções gravadas")
+	}
+	if _, ok := store.get("dry-4"); !ok {
+		t.Error("dry-4 não deveria sair por causa de uma execução gravada")
+	}
+}
+
+func TestRunStorePruneByAge(t *testing.T) {
+	store, err := newRunStore("", runRetention{MaxAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.add(&DueDateRun{ID: "old", CreatedAt: time.Now().Add(-48 * time.Hour)})
+	store.add(&DueDateRun{ID: "new", CreatedAt: time.Now()})
+	if _, ok := store.get("old"); ok {
+		t.Error("execução mais antiga que MaxAge deveria ser descartada")
+	}
+	if _, ok := store.get("new"); !ok {
+		t.Error("execução recente não deveria ser descartada")
+	}
+}
+
+func TestHandleRunRollbackConflicts(t *testing.T) {
+	previous, written, edited := day(2024, 3, 6), day(2024, 3, 8), day(2024, 3, 12)
+	dueDate := func(date time.Time) map[string]interface{} {
+		return map[string]interface{}{dueDateField: date.Format(time.RFC3339)}
+	}
+	newFixture := func() (*fakeWitClient, *runStore) {
+		witClient := newFakeWitClient(
+			fakeWorkItem(1, dueDate(written)),
+			// Editado à mão depois da execução
+			fakeWorkItem(2, dueDate(edited)),
+			// 3 foi excluído
+			fakeWorkItem(4, dueDate(written)),
+		)
+		// O 4 é editado entre a leitura e a gravação do rollback
+		witClient.beforeUpdate = func(id int) {
+			if id == 4 {
+				witClient.edit(4, dueDateField, edited.Format(time.RFC3339))
+			}
+		}
+		store, err := newRunStore("", runRetention{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var items []DueDateRunItem
+		for _, id := range []int{1, 2, 3, 4} {
+			previous, written := previous, written
+			items = append(items, DueDateRunItem{ID: id, PreviousDueDate: &previous, NewDueDate: &written})
+		}
+		store.add(&DueDateRun{ID: "run-1", Source: runSourceGenerate, CreatedAt: time.Now(), Items: items})
+		return witClient, store
+	}
+	rollback := func(witClient *fakeWitClient, store *runStore, query string) RollbackResponse {
+		t.Helper()
+		handler := handleRunRollback(newFakePool(nil, witClient), &Config{Project: "Projeto"}, store)
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest(http.MethodPost, "/runs/run-1/rollback"+query, nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+		}
+		var response RollbackResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+	statuses := func(response RollbackResponse) map[int]string {
+		result := make(map[int]string)
+		for _, item := range response.Results {
+			result[item.ID] = item.Status
+		}
+		return result
+	}
+
+	t.Run("sem force", func(t *testing.T) {
+		witClient, store := newFixture()
+		response := rollback(witClient, store, "")
+		want := map[int]string{1: dueDateUpdated, 2: dueDateSkipped, 3: dueDateSkipped, 4: dueDateSkipped}
+		if got := statuses(response); !reflect.DeepEqual(got, want) {
+			t.Errorf("status = %v, quer %v", got, want)
+		}
+		if response.Updated != 1 || response.Skipped != 3 || response.Conflicts != 2 || response.Failed != 0 {
+			t.Errorf("updated=%d skipped=%d conflicts=%d failed=%d, quer 1/3/2/0", response.Updated, response.Skipped, response.Conflicts, response.Failed)
+		}
+		// Toda gravação começa pelo test na revisão lida
+		for _, id := range []int{1, 4} {
+			documents := witClient.updates[id]
+			if len(documents) != 1 || *documents[0][0].Op != webapi.OperationValues.Test || *documents[0][0].Path != "/rev" || documents[0][0].Value != 1 {
+				t.Errorf("#%d gravado com %+v, quer test em /rev = 1 primeiro", id, documents)
+			}
+		}
+		if _, ok := witClient.updates[2]; ok {
+			t.Error("#2 foi editado depois da execução e não deveria ser gravado")
+		}
+		if got := currentFieldDate(witClient.items[2].Fields, dueDateField); !sameDueDate(got, &edited) {
+			t.Errorf("#2 = %v, quer a edição manual mantida", got)
+		}
+		if got := currentFieldDate(witClient.items[4].Fields, dueDateField); !sameDueDate(got, &edited) {
+			t.Errorf("#4 = %v, quer a edição concorrente mantida", got)
+		}
+		if got := currentFieldDate(witClient.items[1].Fields, dueDateField); !sameDueDate(got, &previous) {
+			t.Errorf("#1 = %v, quer a data anterior", got)
+		}
+		run, _ := store.get("run-1")
+		if run.RolledBackAt != nil {
+			t.Error("execução marcada como revertida com itens em conflito")
+		}
+	})
+
+	t.Run("force reverte as edições feitas depois da execução", func(t *testing.T) {
+		witClient, store := newFixture()
+		witClient.beforeUpdate = nil
+		response := rollback(witClient, store, "?force=true")
+		want := map[int]string{1: dueDateUpdated, 2: dueDateUpdated, 3: dueDateSkipped, 4: dueDateUpdated}
+		if got := statuses(response); !reflect.DeepEqual(got, want) {
+			t.Errorf("status = %v, quer %v", got, want)
+		}
+		if response.Conflicts != 0 {
+			t.Errorf("conflicts = %d, quer 0", response.Conflicts)
+		}
+		if got := currentFieldDate(witClient.items[2].Fields, dueDateField); !sameDueDate(got, &previous) {
+			t.Errorf("#2 = %v, quer a data anterior", got)
+		}
+	})
+}
+
+func TestHandleRunsOtherTeam(t *testing.T) {
+	const field = "Custom.DataEntrega"
+	previous, written := day(2024, 3, 6), day(2024, 3, 8)
+	witClient := newFakeWitClient(fakeWorkItem(1, map[string]interface{}{field: written.Format(time.RFC3339)}))
+	store, err := newRunStore("", runRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.add(&DueDateRun{ID: "run-1", Source: runSourceBatch, CreatedAt: time.Now(), Team: "Time B", Field: field,
+		Items: []DueDateRunItem{{ID: 1, PreviousDueDate: &previous, NewDueDate: &written}}})
+	call := func(team, method, target string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handleRuns(newFakePool(nil, witClient), &Config{Project: "Projeto", Team: team}, store, nil, nil)(recorder, httptest.NewRequest(method, target, nil))
+		return recorder
+	}
+
+	for _, route := range []struct{ method, target string }{{http.MethodGet, "/runs/run-1"}, {http.MethodPost, "/runs/run-1/rollback"}} {
+		if recorder := call("Time A", route.method, route.target); recorder.Code != http.StatusNotFound {
+			t.Errorf("%s %s pelo Time A: status = %d, quer 404", route.method, route.target, recorder.Code)
+		}
+	}
+	if len(witClient.updates) != 0 {
+		t.Fatalf("rollback pelo Time A gravou %d itens", len(witClient.updates))
+	}
+
+	recorder := call("Time B", http.MethodPost, "/runs/run-1/rollback")
+	var response RollbackResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || recorder.Code != http.StatusOK || response.Updated != 1 {
+		t.Fatalf("rollback pelo Time B: status = %d, %+v (%v)", recorder.Code, response, err)
+	}
+	if rollback, ok := store.get(response.RollbackRunID); !ok || rollback.Team != "Time B" || rollback.Field != field {
+		t.Errorf("execução do rollback = time %q, campo %q; quer Time B e %s", rollback.Team, rollback.Field, field)
+	}
+}
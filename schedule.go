@@ -0,0 +1,896 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Data calculada para uma User Story antes da gravação. DueDate nil indica
+// que a User Story ficou de fora, com o motivo em Reason.
+type generationPlan struct {
+	Story WorkItem
+	// Primeiro dia de trabalho da User Story no cálculo, base da folga percentual
+	Start   time.Time
+	DueDate *time.Time
+	// Data antes da folga (?bufferPercent= ou ?bufferDays=); nil sem folga
+	RawDueDate    *time.Time
+	Reason        string
+	ReasonCode    string
+	RemainingWork *float64
+	AtRisk        bool
+	// Origem da data quando há ajuste em overrides (overrideDueDate ou
+	// overrideWorkingDays); vazio quando veio da estratégia
+	Override string
+	// Data levada ao último dia útil por estar bloqueada (?blockedToEnd=true)
+	MovedBlocked bool
+	// Data mais cedo que a User Story aceita sem violar a capacidade; nil
+	// quando a data calculada já é a mais cedo possível (capacity, rollup,
+	// durações de overrides)
+	Earliest *time.Time
+	// Data antecipada por ?spreadWithinWeek=true
+	Spread bool
+	// Regras do cálculo que mudaram a data, em texto para leitura
+	Explanation []string
+	// Em risco só por causa do congelamento (FREEZE_DAYS_BEFORE_END): o
+	// trabalho caberia na sprint usando os dias congelados
+	FrozenOut bool
+}
+
+// Tipos de ajuste aceitos em overrides no corpo de POST /generate-due-dates
+const (
+	overrideDueDate     = "dueDate"
+	overrideWorkingDays = "workingDays"
+)
+
+// Ajuste de uma User Story na geração: data explícita, que ignora a
+// estratégia, ou duração em dias úteis, que substitui o esforço calculado
+type generationOverride struct {
+	DueDate     *time.Time
+	WorkingDays int
+}
+
+// Função para obter o n-ésimo dia útil (1 = o primeiro) a partir de start.
+// ok é false quando os dias acabam antes.
+func nthWorkingDate(days []time.Time, start time.Time, n int) (time.Time, bool) {
+	for _, day := range days {
+		if day.Before(start) {
+			continue
+		}
+		n--
+		if n == 0 {
+			return day, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Função para distribuir count itens pelos dias úteis: o item N de M fica no
+// dia útil ceil(N*dias/M). As datas nunca diminuem e a última é o último dia útil.
+func evenDueDates(count int, days []time.Time) []time.Time {
+	dates := make([]time.Time, count)
+	for i := range dates {
+		n := i + 1
+		index := (n*len(days)+count-1)/count - 1
+		dates[i] = days[index]
+	}
+	return dates
+}
+
+// Função para montar o plano da estratégia even (User Stories já em ordem de
+// prioridade). A even não modela capacidade, então qualquer dia útil da
+// sprint até a data calculada é viável (Earliest).
+func planEven(stories []WorkItem, days []time.Time) []generationPlan {
+	plans := make([]generationPlan, len(stories))
+	start := 0
+	for i, date := range evenDueDates(len(stories), days) {
+		date := date
+		plan := generationPlan{Story: stories[i], DueDate: &date, Earliest: &days[0]}
+		// Começa no dia seguinte à User Story anterior; empates começam no próprio dia
+		if start < len(days) && !days[start].After(date) {
+			plan.Start = days[start]
+		} else {
+			plan.Start = date
+		}
+		for start < len(days) && !days[start].After(date) {
+			start++
+		}
+		plans[i] = plan
+	}
+	return plans
+}
+
+// Dia de trabalho de um desenvolvedor com as horas disponíveis nele
+type capacityDay struct {
+	Date  time.Time
+	Hours float64
+}
+
+// Função para listar os dias com horas disponíveis de um desenvolvedor entre
+// start e end: capacidade diária × peso do dia, descontadas as folgas
+// (parciais pela fração). Dias sem horas ficam de fora.
+func capacityDays(start, end time.Time, perDay float64, daysOff []DayOff, cal workCalendar) ([]capacityDay, error) {
+	dates, err := workingDates(start, end, daysOff, cal)
+	if err != nil {
+		return nil, err
+	}
+	var days []capacityDay
+	for _, date := range dates {
+		hours := perDay * cal.dayWeight(date) * (1 - cal.dayOffFraction(date, daysOff))
+		if hours > 0 {
+			days = append(days, capacityDay{Date: date, Hours: hours})
+		}
+	}
+	return days, nil
+}
+
+// Função para listar os dias em que um desenvolvedor está disponível com
+// GRANULARITY=days: cada dia útil vale um dia inteiro de trabalho (Hours 1)
+// quando ele tem capacidade. Dias com folga, mesmo parcial, ficam de fora,
+// porque não comportam um dia inteiro.
+func availableDays(start, end time.Time, available bool, daysOff []DayOff, cal workCalendar) ([]capacityDay, error) {
+	dates, err := workingDates(start, end, daysOff, cal)
+	if err != nil || !available {
+		return nil, err
+	}
+	var days []capacityDay
+	for _, date := range dates {
+		if cal.dayOffFraction(date, daysOff) == 0 {
+			days = append(days, capacityDay{Date: date, Hours: 1})
+		}
+	}
+	return days, nil
+}
+
+// capacityCursor consome as horas de um desenvolvedor dia a dia, na ordem
+// em que as User Stories dele são planejadas
+type capacityCursor struct {
+	days  []capacityDay
+	index int
+	used  float64
+}
+
+// Tolerância para arredondamentos na soma de horas
+const capacityEpsilon = 1e-9
+
+// Função para consumir hours a partir do ponto atual e devolver o dia em que
+// o trabalho termina. ok é false quando as horas acabam antes (trabalho além
+// da sprint); a partir daí todo consumo também falha.
+func (c *capacityCursor) consume(hours float64) (time.Time, bool) {
+	for c.index < len(c.days) {
+		free := c.days[c.index].Hours - c.used
+		if hours <= free+capacityEpsilon {
+			c.used += hours
+			return c.days[c.index].Date, true
+		}
+		hours -= free
+		c.index++
+		c.used = 0
+	}
+	return time.Time{}, false
+}
+
+// Função para consumir n dias de trabalho a partir do ponto atual e devolver
+// o último deles. O dia atual conta como o primeiro mesmo que já tenha horas
+// usadas, e o último fica cheio. ok é false quando os dias acabam antes.
+func (c *capacityCursor) consumeDays(n int) (time.Time, bool) {
+	if c.index < len(c.days) && c.used >= c.days[c.index].Hours-capacityEpsilon {
+		c.index++
+		c.used = 0
+	}
+	target := c.index + n - 1
+	if target >= len(c.days) {
+		if c.index < len(c.days) {
+			c.index, c.used = len(c.days), 0
+		}
+		return time.Time{}, false
+	}
+	c.index, c.used = target, c.days[target].Hours
+	return c.days[target].Date, true
+}
+
+// Função para rodar fn com o cursor limitado aos dias anteriores a limit
+// (zero não limita), para o trabalho que não pode entrar no congelamento.
+// O consumo feito por fn continua valendo depois.
+func (c *capacityCursor) within(limit time.Time, fn func()) {
+	if limit.IsZero() {
+		fn()
+		return
+	}
+	all := c.days
+	c.days = all[:sort.Search(len(all), func(i int) bool { return !all[i].Date.Before(limit) })]
+	fn()
+	c.days = all
+}
+
+// Função para pular os dias anteriores a date; o trabalho seguinte começa
+// nele ou depois
+func (c *capacityCursor) skipTo(date time.Time) {
+	for c.index < len(c.days) && c.days[c.index].Date.Before(date) {
+		c.index++
+		c.used = 0
+	}
+}
+
+// Função para obter o dia em que o próximo trabalho começa (o dia atual, ou
+// o seguinte quando o atual já está cheio); end quando as horas acabaram
+func (c *capacityCursor) position(end time.Time) time.Time {
+	index := c.index
+	if index < len(c.days) && c.used >= c.days[index].Hours-capacityEpsilon {
+		index++
+	}
+	if index < len(c.days) {
+		return c.days[index].Date
+	}
+	return end
+}
+
+// Entradas da estratégia capacity
+type capacityPlanInput struct {
+	// Início do planejamento: o maior entre o início da sprint e hoje
+	Start time.Time
+	// Último dia da sprint, usado como data das User Stories em risco
+	End time.Time
+	// Soma do RemainingWork das tasks abertas, por User Story com tasks estimadas
+	TaskWork map[int]float64
+	// Durações em dias úteis vindas de overrides, no lugar do esforço calculado
+	Durations map[int]int
+	// Capacidades da sprint indexadas por identityKey
+	Capacities         map[string]TeamMemberCapacity
+	TeamDaysOff        []DayOff
+	DefaultPerDay      float64
+	HoursPerStoryPoint float64
+	Calendar           workCalendar
+	// Parte da capacidade que vira trabalho nos itens (FOCUS_FACTOR); zero vale 1
+	FocusFactor float64
+	// Horas por dia de cerimônias descontadas antes de FocusFactor
+	// (CEREMONY_HOURS_PER_DAY)
+	CeremonyHours float64
+	// Ordem implícita entre as atividades das tasks de uma User Story
+	// (ACTIVITY_ORDER), usada pela estratégia rollup; vazia desativa
+	ActivityOrder []string
+	// Janelas de disponibilidade (AVAILABILITY_FILE) por identityKey: fora
+	// delas o desenvolvedor não tem dias de trabalho
+	Availability map[string]AvailabilityWindow
+	// Zona congelada do fim da sprint (FREEZE_DAYS_BEFORE_END): o trabalho
+	// das atividades não isentas termina antes dela
+	Freeze sprintFreeze
+	// Cálculo em dias inteiros (GRANULARITY=days): TaskWork e o trabalho das
+	// tasks vêm em dias, e cada dia útil do desenvolvedor vale 1 ou 0
+	// (availableDays), sem cerimônias nem FocusFactor
+	Days bool
+}
+
+// capacityPlanner guarda um capacityCursor por desenvolvedor, criado na
+// primeira vez em que ele aparece no plano
+type capacityPlanner struct {
+	input     capacityPlanInput
+	cursors   map[string]*capacityCursor
+	defaulted []string
+}
+
+func newCapacityPlanner(input capacityPlanInput) *capacityPlanner {
+	return &capacityPlanner{input: input, cursors: make(map[string]*capacityCursor)}
+}
+
+// Função para obter o cursor de um desenvolvedor: capacidade da sprint
+// (soma das atividades) e folgas dele e do time, ou DEFAULT_CAPACITY_PER_DAY
+// para quem não tem capacidade configurada
+func (p *capacityPlanner) cursor(identity *Identity) (*capacityCursor, error) {
+	key := identityKey(identity.UniqueName, identity.Descriptor, identity.DisplayName)
+	if cursor, ok := p.cursors[key]; ok {
+		return cursor, nil
+	}
+	days, _, configured, err := p.input.memberDays(key)
+	if !configured {
+		p.defaulted = append(p.defaulted, identity.DisplayName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cursor := &capacityCursor{days: days}
+	p.cursors[key] = cursor
+	return cursor, nil
+}
+
+// Função para obter as horas por dia de um desenvolvedor no cálculo (soma
+// das atividades, ou DEFAULT_CAPACITY_PER_DAY sem capacidade configurada,
+// menos as cerimônias, vezes FOCUS_FACTOR) e as folgas dele e do time. configured é false quando
+// vale a capacidade padrão.
+func (input capacityPlanInput) memberCapacity(key string) (perDay float64, daysOff []DayOff, configured bool) {
+	perDay = input.DefaultPerDay
+	daysOff = append([]DayOff{}, input.TeamDaysOff...)
+	capacity, configured := input.Capacities[key]
+	if configured {
+		perDay = 0
+		for _, activity := range capacity.Activities {
+			perDay += activity.CapacityPerDay
+		}
+		daysOff = append(daysOff, capacity.DaysOff...)
+	}
+	if input.Days {
+		return math.Min(perDay, 1), daysOff, configured
+	}
+	perDay = math.Max(perDay-input.CeremonyHours, 0)
+	if input.FocusFactor > 0 {
+		perDay *= input.FocusFactor
+	}
+	return perDay, daysOff, configured
+}
+
+// Função para listar os dias de trabalho de um desenvolvedor no cálculo, com
+// as horas de cada um (capacityDays) ou, com GRANULARITY=days, 1 nos dias
+// em que está disponível (availableDays). perDay é 1 ou 0 em dias. A janela
+// de disponibilidade dele recorta o intervalo; inteira fora, não há dias.
+func (input capacityPlanInput) memberDays(key string) (days []capacityDay, perDay float64, configured bool, err error) {
+	perDay, daysOff, configured := input.memberCapacity(key)
+	start, end := input.Start, input.End
+	if window, ok := input.Availability[key]; ok {
+		var empty bool
+		if start, end, _, empty = window.clip(start, end); empty {
+			return nil, perDay, configured, nil
+		}
+	}
+	if input.Days {
+		days, err = availableDays(start, end, perDay > 0, daysOff, input.Calendar)
+	} else {
+		days, err = capacityDays(start, end, perDay, daysOff, input.Calendar)
+	}
+	return days, perDay, configured, err
+}
+
+// Trabalho encaixado na capacidade de um desenvolvedor
+type capacitySlot struct {
+	Start  time.Time
+	Finish time.Time
+	// O trabalho não cabe: Finish é o fim da sprint ou, quando só o
+	// congelamento impede, o último dia antes dele
+	AtRisk bool
+	// Dia em que o trabalho começaria sem notBefore; zero quando a
+	// restrição não mudou nada
+	ShiftedFrom time.Time
+	// Em risco só por causa do congelamento
+	FrozenOut bool
+}
+
+// Função para consumir as horas de um desenvolvedor e devolver o dia em que
+// o trabalho termina; o que não cabe fica no fim da sprint, em risco
+func (p *capacityPlanner) finish(identity *Identity, hours float64, activity string) (capacitySlot, error) {
+	return p.finishAfter(identity, hours, time.Time{}, activity)
+}
+
+// Função para consumir as horas de um desenvolvedor começando no dia
+// notBefore ou depois. Atividades não isentas do congelamento só usam os
+// dias anteriores a ele; quando só ele impede o trabalho de caber, o
+// resultado vem com FrozenOut e a data do último dia antes dele.
+func (p *capacityPlanner) finishAfter(identity *Identity, hours float64, notBefore time.Time, activity string) (capacitySlot, error) {
+	var slot capacitySlot
+	cursor, err := p.cursor(identity)
+	if err != nil {
+		return slot, err
+	}
+	limit := p.input.Freeze.limit(activity)
+	all, fits := cursor.days, false
+	cursor.within(limit, func() {
+		slot.Start = cursor.position(p.input.End)
+		if slot.Start.Before(notBefore) {
+			slot.ShiftedFrom = slot.Start
+			cursor.skipTo(notBefore)
+			slot.Start = cursor.position(p.input.End)
+		}
+		probe := *cursor
+		slot.Finish, fits = cursor.consume(hours)
+		if !fits && !limit.IsZero() {
+			// Sem o limite, o mesmo consumo caberia nos dias congelados?
+			probe.days = all
+			_, slot.FrozenOut = probe.consume(hours)
+		}
+	})
+	if !fits {
+		slot.AtRisk, slot.Finish = true, p.input.End
+		if slot.FrozenOut && !p.input.Freeze.LastOpen.IsZero() {
+			slot.Finish = p.input.Freeze.LastOpen
+		}
+	}
+	return slot, nil
+}
+
+// Função para reservar n dias úteis de um desenvolvedor (duração de
+// overrides). Sem responsável, conta os dias úteis do time sem consumir a
+// capacidade de ninguém. O que não cabe fica no fim da sprint, em risco; o
+// congelamento vale como em finishAfter, para uma User Story sem atividade.
+func (p *capacityPlanner) finishDays(identity *Identity, n int) (capacitySlot, error) {
+	var slot capacitySlot
+	limit := p.input.Freeze.limit("")
+	fits := false
+	if identity == nil {
+		days, err := workingDates(p.input.Start, p.input.End, p.input.TeamDaysOff, p.input.Calendar)
+		if err != nil {
+			return slot, err
+		}
+		slot.Start = p.input.End
+		if len(days) > 0 {
+			slot.Start = days[0]
+		}
+		open := days
+		if !limit.IsZero() {
+			open = days[:sort.Search(len(days), func(i int) bool { return !days[i].Before(limit) })]
+		}
+		if slot.Finish, fits = nthWorkingDate(open, p.input.Start, n); !fits && len(open) < len(days) {
+			_, slot.FrozenOut = nthWorkingDate(days, p.input.Start, n)
+		}
+	} else {
+		cursor, err := p.cursor(identity)
+		if err != nil {
+			return slot, err
+		}
+		all := cursor.days
+		cursor.within(limit, func() {
+			slot.Start = cursor.position(p.input.End)
+			probe := *cursor
+			if slot.Finish, fits = cursor.consumeDays(n); !fits && !limit.IsZero() {
+				probe.days = all
+				_, slot.FrozenOut = probe.consumeDays(n)
+			}
+		})
+	}
+	if !fits {
+		slot.AtRisk, slot.Finish = true, p.input.End
+		if slot.FrozenOut && !p.input.Freeze.LastOpen.IsZero() {
+			slot.Finish = p.input.Freeze.LastOpen
+		}
+	}
+	return slot, nil
+}
+
+// Função para montar os avisos do cálculo
+func (p *capacityPlanner) warnings() []string {
+	if len(p.defaulted) == 0 {
+		return nil
+	}
+	sort.Strings(p.defaulted)
+	if p.input.Days {
+		return []string{fmt.Sprintf("Sem capacidade configurada na sprint para %s; considerados disponíveis em todos os dias úteis",
+			strings.Join(p.defaulted, ", "))}
+	}
+	return []string{fmt.Sprintf("Sem capacidade configurada na sprint para %s; usando %gh/dia",
+		strings.Join(p.defaulted, ", "), p.input.DefaultPerDay)}
+}
+
+// Função para explicar a data de uma User Story que não coube antes do
+// congelamento
+func (plan *generationPlan) explainFreeze(freeze sprintFreeze) {
+	if plan.FrozenOut {
+		plan.Explanation = append(plan.Explanation, fmt.Sprintf(
+			"Não cabe antes do congelamento em %s (FREEZE_DAYS_BEFORE_END); caberia usando os dias congelados",
+			freeze.Start.Format("2006-01-02")))
+	}
+}
+
+// Função para montar o plano da estratégia capacity: para cada responsável,
+// percorre as User Stories dele em ordem de prioridade acumulando o trabalho
+// restante contra as horas por dia, e a data é o dia em que o trabalho
+// termina. O que não cabe até o fim da sprint recebe o último dia e fica
+// marcado como em risco. User Stories com duração em overrides reservam os
+// dias úteis informados no lugar das horas. Com GRANULARITY=days o trabalho
+// é em dias inteiros, e a data é o último dia consumido. Devolve também os
+// avisos do cálculo.
+func planCapacity(stories []WorkItem, input capacityPlanInput) ([]generationPlan, []string, error) {
+	plans := make([]generationPlan, 0, len(stories))
+	planner := newCapacityPlanner(input)
+	for _, story := range stories {
+		plan := generationPlan{Story: story}
+		if workingDays, ok := input.Durations[story.ID]; ok {
+			slot, err := planner.finishDays(story.AssignedTo, workingDays)
+			if err != nil {
+				return nil, nil, err
+			}
+			plan.Start, plan.DueDate, plan.AtRisk, plan.FrozenOut, plan.Override = slot.Start, &slot.Finish, slot.AtRisk, slot.FrozenOut, overrideWorkingDays
+			plan.explainFreeze(input.Freeze)
+			plans = append(plans, plan)
+			continue
+		}
+		if story.AssignedTo == nil {
+			plan.ReasonCode, plan.Reason = reasonNoAssignee, "sem responsável"
+			plans = append(plans, plan)
+			continue
+		}
+
+		// Trabalho restante: tasks estimadas, ou Story Points × horas por ponto.
+		// Em dias, TaskWork já traz a estimativa da própria User Story.
+		if hours, ok := input.TaskWork[story.ID]; ok {
+			plan.RemainingWork = &hours
+		} else if input.Days {
+			plan.ReasonCode, plan.Reason = reasonNoEstimate, "sem estimativa em dias (nenhuma task com DAY_ESTIMATE_FIELD e a User Story sem ele)"
+			plans = append(plans, plan)
+			continue
+		} else if story.StoryPoints != nil {
+			hours := *story.StoryPoints * input.HoursPerStoryPoint
+			plan.RemainingWork = &hours
+		} else {
+			plan.ReasonCode, plan.Reason = reasonNoEstimate, "sem estimativa (nenhuma task com RemainingWork e sem Story Points)"
+			plans = append(plans, plan)
+			continue
+		}
+
+		// A User Story não tem atividade: com congelamento, termina antes dele
+		slot, err := planner.finish(story.AssignedTo, *plan.RemainingWork, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		plan.Start, plan.DueDate, plan.AtRisk, plan.FrozenOut = slot.Start, &slot.Finish, slot.AtRisk, slot.FrozenOut
+		plan.explainFreeze(input.Freeze)
+		plans = append(plans, plan)
+	}
+	return plans, planner.warnings(), nil
+}
+
+// Task aberta considerada pela estratégia rollup
+type rollupTask struct {
+	ID            int
+	AssignedTo    *Identity
+	Activity      string
+	RemainingWork *float64
+	DueDate       *time.Time
+	// StackRank (ou BacklogPriority) da própria task; nil quando ausente
+	StackRank *float64
+}
+
+// Função para obter a posição de uma atividade em ACTIVITY_ORDER (sem
+// diferenciar maiúsculas); -1 para atividades fora da lista, que não entram
+// na regra
+func activityRank(order []string, activity string) int {
+	for i, name := range order {
+		if strings.EqualFold(name, activity) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Função para ordenar as tasks de uma User Story para o cálculo: as de fora
+// de ACTIVITY_ORDER primeiro e depois as atividades na ordem configurada,
+// mantendo a ordem por ID dentro de cada grupo
+func orderByActivity(tasks []rollupTask, order []string) []rollupTask {
+	if len(order) == 0 {
+		return tasks
+	}
+	sorted := append([]rollupTask{}, tasks...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return activityRank(order, sorted[i].Activity) < activityRank(order, sorted[j].Activity)
+	})
+	return sorted
+}
+
+// Função para montar o plano da estratégia rollup: a data de cada User Story
+// é a maior data entre as tasks abertas dela, limitada ao fim da sprint.
+// Tasks sem data são calculadas pelo RemainingWork contra a capacidade do
+// responsável, como na estratégia capacity; tasks sem data, sem estimativa
+// ou sem responsável não entram. Com ActivityOrder, tasks calculadas de uma
+// atividade posterior só começam no dia em que terminam as tasks das
+// atividades anteriores da mesma User Story, e a explicação do plano mostra
+// quando isso mudou a data.
+func planRollup(stories []WorkItem, tasks map[int][]rollupTask, input capacityPlanInput) ([]generationPlan, []string, error) {
+	plans := make([]generationPlan, 0, len(stories))
+	planner := newCapacityPlanner(input)
+	for _, story := range stories {
+		plan := generationPlan{Story: story, Start: input.Start}
+		children := tasks[story.ID]
+		if len(children) == 0 {
+			plan.ReasonCode, plan.Reason = reasonNoTasks, "sem tasks abertas"
+			plans = append(plans, plan)
+			continue
+		}
+
+		var latest *time.Time
+		remaining := 0.0
+		// Tasks em risco pelo congelamento e por falta de capacidade
+		var frozenOut, shortfall bool
+		// Fim das tasks das atividades anteriores e da atividade atual
+		var earlierEnd, rankEnd time.Time
+		rank := -1
+		for _, task := range orderByActivity(children, input.ActivityOrder) {
+			if taskRank := activityRank(input.ActivityOrder, task.Activity); taskRank > rank {
+				if rankEnd.After(earlierEnd) {
+					earlierEnd = rankEnd
+				}
+				rank = taskRank
+			}
+			date := task.DueDate
+			if date == nil && task.RemainingWork != nil && task.AssignedTo != nil {
+				notBefore := time.Time{}
+				if rank >= 0 {
+					notBefore = earlierEnd
+				}
+				slot, err := planner.finishAfter(task.AssignedTo, *task.RemainingWork, notBefore, task.Activity)
+				if err != nil {
+					return nil, nil, err
+				}
+				if !slot.ShiftedFrom.IsZero() {
+					plan.Explanation = append(plan.Explanation, fmt.Sprintf(
+						"Task #%d (%s) começa em %s, depois das tasks das atividades anteriores (ACTIVITY_ORDER); sem a regra começaria em %s",
+						task.ID, task.Activity, slot.Start.Format("2006-01-02"), slot.ShiftedFrom.Format("2006-01-02")))
+				}
+				if slot.FrozenOut {
+					frozenOut = true
+					plan.Explanation = append(plan.Explanation, fmt.Sprintf(
+						"Task #%d (%s) não cabe antes do congelamento em %s (FREEZE_DAYS_BEFORE_END); caberia usando os dias congelados",
+						task.ID, task.Activity, input.Freeze.Start.Format("2006-01-02")))
+				} else if slot.AtRisk {
+					shortfall = true
+				}
+				plan.AtRisk = plan.AtRisk || slot.AtRisk
+				date = &slot.Finish
+			}
+			if date != nil && rank >= 0 && sprintDate(*date).After(rankEnd) {
+				rankEnd = sprintDate(*date)
+			}
+			if task.RemainingWork != nil {
+				remaining += *task.RemainingWork
+			}
+			if date == nil {
+				continue
+			}
+			day := sprintDate(*date)
+			if latest == nil || day.After(*latest) {
+				latest = &day
+			}
+		}
+		if latest == nil {
+			plan.ReasonCode, plan.Reason = reasonNoDatableTasks, "nenhuma task com data, ou com RemainingWork e responsável"
+			plans = append(plans, plan)
+			continue
+		}
+		if latest.After(input.End) {
+			end := input.End
+			latest = &end
+			plan.AtRisk, shortfall = true, true
+		}
+		plan.FrozenOut = frozenOut && !shortfall
+		plan.DueDate = latest
+		plan.RemainingWork = &remaining
+		plans = append(plans, plan)
+	}
+	return plans, planner.warnings(), nil
+}
+
+// Folga aplicada às datas calculadas: percentual da duração de cada User
+// Story (?bufferPercent=) ou dias úteis fixos (?bufferDays=)
+type generationBuffer struct {
+	Percent float64
+	Days    int
+}
+
+// Maior folga percentual aceita
+const maxBufferPercent = 200
+
+// Função para ler a folga da query string; os dois parâmetros juntos são
+// recusados e, sem nenhum deles, vale fallback (DUE_DATE_BUFFER)
+func bufferFromQuery(query url.Values, fallback generationBuffer) (generationBuffer, error) {
+	var buffer generationBuffer
+	percent, days := query.Get("bufferPercent"), query.Get("bufferDays")
+	if percent != "" && days != "" {
+		return buffer, fmt.Errorf("Use apenas um dos parâmetros 'bufferPercent' ou 'bufferDays'")
+	}
+	if percent == "" && days == "" {
+		return fallback, nil
+	}
+	if percent != "" {
+		value, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(percent, "%")), 64)
+		if err != nil || value < 0 || value > maxBufferPercent {
+			return buffer, fmt.Errorf("Parâmetro 'bufferPercent' inválido: %q (use um número entre 0 e %d)", percent, maxBufferPercent)
+		}
+		buffer.Percent = value
+	}
+	if days != "" {
+		value, err := strconv.Atoi(days)
+		if err != nil || value < 0 || value > maxCalendarDays {
+			return buffer, fmt.Errorf("Parâmetro 'bufferDays' inválido: %q (use um inteiro maior ou igual a 0)", days)
+		}
+		buffer.Days = value
+	}
+	return buffer, nil
+}
+
+func (b generationBuffer) enabled() bool {
+	return b.Percent > 0 || b.Days > 0
+}
+
+// Função para calcular quantos dias úteis de folga uma User Story recebe:
+// bufferDays fixo, ou o percentual da duração (dias úteis de Start até a
+// data calculada) arredondado para cima
+func (b generationBuffer) extraDays(plan generationPlan, days []time.Time) int {
+	if b.Days > 0 {
+		return b.Days
+	}
+	duration := 0
+	for _, day := range days {
+		if !day.Before(plan.Start) && !day.After(*plan.DueDate) {
+			duration++
+		}
+	}
+	return int(math.Ceil(float64(duration)*b.Percent/100 - capacityEpsilon))
+}
+
+// Função para levar as User Stories bloqueadas para o último dia útil da
+// sprint (?blockedToEnd=true), antes das dependências, para que as sucessoras
+// fiquem depois delas. Datas explícitas de overrides são mantidas.
+func pushBlockedToEnd(plans []generationPlan, days []time.Time) {
+	if len(days) == 0 {
+		return
+	}
+	last := days[len(days)-1]
+	for i := range plans {
+		plan := &plans[i]
+		if !plan.Story.Blocked || plan.DueDate == nil || plan.Override == overrideDueDate {
+			continue
+		}
+		date := last
+		plan.DueDate = &date
+		plan.MovedBlocked = true
+	}
+}
+
+// Função para aplicar a folga depois do cálculo (o risco continua refletindo
+// as datas sem folga). Cada data avança os dias úteis da folga, sem passar do
+// último dia útil da sprint, e os sucessores continuam depois dos
+// predecessores. A data original fica em RawDueDate. Datas explícitas de
+// overrides e as bloqueadas levadas ao fim não recebem folga.
+func applyBuffer(plans []generationPlan, buffer generationBuffer, days []time.Time, predecessors map[int][]int) {
+	if !buffer.enabled() || len(days) == 0 {
+		return
+	}
+	dueDates := make(map[int]*time.Time, len(plans))
+	for i := range plans {
+		plan := &plans[i]
+		if plan.DueDate == nil {
+			continue
+		}
+		if plan.Override == overrideDueDate || plan.MovedBlocked {
+			dueDates[plan.Story.ID] = plan.DueDate
+			continue
+		}
+		raw := *plan.DueDate
+		plan.RawDueDate = &raw
+
+		// Último dia útil até a data calculada; a folga conta a partir dele
+		index := -1
+		for j, day := range days {
+			if !day.After(raw) {
+				index = j
+			}
+		}
+		buffered := raw
+		if target := index + buffer.extraDays(*plan, days); target >= 0 {
+			if candidate := days[min(target, len(days)-1)]; candidate.After(buffered) {
+				buffered = candidate
+			}
+		}
+		for _, predecessor := range predecessors[plan.Story.ID] {
+			if before := dueDates[predecessor]; before != nil {
+				if next, ok := nextWorkingDate(days, *before); ok && buffered.Before(next) {
+					buffered = next
+				}
+			}
+		}
+		plan.DueDate = &buffered
+		dueDates[plan.Story.ID] = plan.DueDate
+	}
+}
+
+// Função para espalhar na semana as datas de um mesmo responsável que caem no
+// mesmo dia (?spreadWithinWeek=true). Em cada grupo a última User Story (em
+// ordem de prioridade) fica na data e as anteriores vão, do fim para o
+// começo, para o dia útil livre do responsável mais próximo antes da
+// seguinte, sem sair da semana. Só usa a folga do plano (Earliest), nunca
+// passa antes do dia útil seguinte aos predecessores e, como só antecipa
+// datas, não atrasa sucessores. O resultado depende só da ordem dos planos.
+func spreadWithinWeek(plans []generationPlan, days []time.Time, predecessors map[int][]int) {
+	type slot struct {
+		developer string
+		date      time.Time
+	}
+	byID := make(map[int]*generationPlan, len(plans))
+	taken := make(map[slot]int)
+	groups := make(map[slot][]int)
+	var order []slot
+	for i := range plans {
+		plan := &plans[i]
+		byID[plan.Story.ID] = plan
+		if plan.DueDate == nil || plan.Story.AssignedTo == nil {
+			continue
+		}
+		assignee := plan.Story.AssignedTo
+		key := slot{developer: identityKey(assignee.UniqueName, assignee.Descriptor, assignee.DisplayName), date: *plan.DueDate}
+		taken[key]++
+		if len(groups[key]) == 0 {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	for _, key := range order {
+		group := groups[key]
+		year, week := key.date.ISOWeek()
+		later := key.date
+		for g := len(group) - 2; g >= 0; g-- {
+			plan := &plans[group[g]]
+			if plan.Earliest == nil || plan.Override == overrideDueDate || plan.MovedBlocked {
+				continue
+			}
+			// Primeiro dia possível: a folga do plano e o dia útil depois de cada predecessor
+			bound := *plan.Earliest
+			for _, predecessor := range predecessors[plan.Story.ID] {
+				before := byID[predecessor]
+				if before == nil || before.DueDate == nil {
+					continue
+				}
+				if next, ok := nextWorkingDate(days, *before.DueDate); ok && next.After(bound) {
+					bound = next
+				}
+			}
+			for d := len(days) - 1; d >= 0; d-- {
+				day := days[d]
+				if !day.Before(later) {
+					continue
+				}
+				if day.Before(bound) {
+					break
+				}
+				if y, w := day.ISOWeek(); y != year || w != week {
+					break
+				}
+				target := slot{developer: key.developer, date: day}
+				if taken[target] > 0 {
+					continue
+				}
+				taken[key]--
+				taken[target]++
+				date := day
+				plan.DueDate = &date
+				if plan.Start.After(date) {
+					plan.Start = date
+				}
+				plan.Spread = true
+				later = day
+				break
+			}
+		}
+	}
+}
+
+// Quantidade de datas em um dia da semana, no resumo da geração
+type WeekdayCount struct {
+	Weekday string `json:"weekday"`
+	Count   int    `json:"count"`
+}
+
+// Função para contar as datas dos itens por dia da semana, de segunda a domingo
+func weekdayDistribution(items []GenerationItem) []WeekdayCount {
+	counts := make([]WeekdayCount, 7)
+	for i := range counts {
+		counts[i].Weekday = time.Weekday((i + 1) % 7).String()
+	}
+	for _, item := range items {
+		if item.DueDate != nil {
+			counts[(int(item.DueDate.Weekday())+6)%7].Count++
+		}
+	}
+	return counts
+}
+
+// Função para tirar as datas de fins de semana e feriados, último passo antes
+// de gravar ou devolver o plano. As datas ficam sempre dentro da sprint.
+func rollDueDates(plans []generationPlan, cal workCalendar, start, end time.Time, direction string) {
+	for i := range plans {
+		if plans[i].DueDate == nil {
+			continue
+		}
+		rolled := cal.rollToBusinessDay(*plans[i].DueDate, start, end, direction)
+		plans[i].DueDate = &rolled
+	}
+}
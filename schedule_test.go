@@ -0,0 +1,525 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Sprint de segunda 04/03/2024 a domingo 10/03/2024 em que o time está de
+// folga de segunda a sábado: o único dia possível é o domingo
+func sundayOnlySprint(includeWeekends bool) capacityPlanInput {
+	return capacityPlanInput{
+		Start:         day(2024, 3, 4),
+		End:           day(2024, 3, 10),
+		TeamDaysOff:   []DayOff{{Start: day(2024, 3, 4), End: day(2024, 3, 9)}},
+		Capacities:    map[string]TeamMemberCapacity{},
+		DefaultPerDay: 6,
+		Calendar:      workCalendar{IncludeWeekends: includeWeekends, WeekendFactor: 1, Location: time.UTC},
+	}
+}
+
+func TestPlanCapacitySundayOnlyCompletion(t *testing.T) {
+	hours := 6.0
+	story := WorkItem{ID: 1, AssignedTo: &Identity{DisplayName: "Ana", UniqueName: "ana@example.com"}}
+	tests := []struct {
+		name            string
+		includeWeekends bool
+		wantDate        time.Time
+		wantAtRisk      bool
+	}{
+		{name: "com fins de semana", includeWeekends: true, wantDate: day(2024, 3, 10), wantAtRisk: false},
+		{name: "sem fins de semana", includeWeekends: false, wantDate: day(2024, 3, 10), wantAtRisk: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := sundayOnlySprint(tt.includeWeekends)
+			input.TaskWork = map[int]float64{1: hours}
+			plans, _, err := planCapacity([]WorkItem{story}, input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			plan := plans[0]
+			if plan.DueDate == nil || !plan.DueDate.Equal(tt.wantDate) {
+				t.Fatalf("DueDate = %v, quer %s", plan.DueDate, tt.wantDate.Format("2006-01-02"))
+			}
+			if plan.AtRisk != tt.wantAtRisk {
+				t.Errorf("AtRisk = %t, quer %t", plan.AtRisk, tt.wantAtRisk)
+			}
+			// O ajuste para dia útil mantém o domingo quando ele conta
+			if tt.includeWeekends {
+				rolled := input.Calendar.rollToBusinessDay(*plan.DueDate, input.Start, input.End, rollPrevious)
+				if !rolled.Equal(tt.wantDate) {
+					t.Errorf("rollToBusinessDay moveu o domingo para %s", rolled.Format("2006-01-02"))
+				}
+			}
+		})
+	}
+}
+
+func TestWorkingDatesSundayOnlySprint(t *testing.T) {
+	tests := []struct {
+		name            string
+		includeWeekends bool
+		want            []time.Time
+	}{
+		// A estratégia even recusa a geração (ErrPlanInfeasible) quando não há dias
+		{name: "sem fins de semana não há dia viável", includeWeekends: false, want: nil},
+		{name: "com fins de semana só o domingo", includeWeekends: true, want: []time.Time{day(2024, 3, 10)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := sundayOnlySprint(tt.includeWeekends)
+			days, err := workingDates(input.Start, input.End, input.TeamDaysOff, input.Calendar)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(days) != len(tt.want) {
+				t.Fatalf("workingDates = %v, quer %v", days, tt.want)
+			}
+			for i := range days {
+				if !days[i].Equal(tt.want[i]) {
+					t.Errorf("dia %d = %s, quer %s", i, days[i].Format("2006-01-02"), tt.want[i].Format("2006-01-02"))
+				}
+			}
+			if tt.includeWeekends {
+				plans := planEven([]WorkItem{{ID: 1}, {ID: 2}}, days)
+				for _, plan := range plans {
+					if !plan.DueDate.Equal(day(2024, 3, 10)) {
+						t.Errorf("#%d recebeu %s, quer o domingo", plan.Story.ID, plan.DueDate.Format("2006-01-02"))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCapacityDaysWeekendFactor(t *testing.T) {
+	cal := workCalendar{IncludeWeekends: true, WeekendFactor: 0.5, Location: time.UTC}
+	days, err := capacityDays(day(2024, 3, 8), day(2024, 3, 10), 8, nil, cal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{8, 4, 4}
+	if len(days) != len(want) {
+		t.Fatalf("capacityDays = %v", days)
+	}
+	for i, d := range days {
+		if d.Hours != want[i] {
+			t.Errorf("%s: %v horas, quer %v", d.Date.Format("Mon"), d.Hours, want[i])
+		}
+	}
+}
+
+func TestRollDueDatesFridayHolidayBeforeWeekend(t *testing.T) {
+	// Sexta 29/03/2024 é feriado e emenda com o fim de semana
+	cal := workCalendar{
+		Location: mustLocation(t, "America/Sao_Paulo"),
+		Holidays: map[time.Time]Holiday{day(2024, 3, 29): {Date: day(2024, 3, 29), Name: "Sexta-feira Santa"}},
+	}
+	tests := []struct {
+		name       string
+		date       time.Time
+		start, end time.Time
+		direction  string
+		want       time.Time
+	}{
+		{name: "feriado volta para quinta", date: day(2024, 3, 29), start: day(2024, 3, 25), end: day(2024, 4, 5), direction: rollPrevious, want: day(2024, 3, 28)},
+		{name: "sábado volta para quinta", date: day(2024, 3, 30), start: day(2024, 3, 25), end: day(2024, 4, 5), direction: rollPrevious, want: day(2024, 3, 28)},
+		{name: "domingo volta para quinta", date: day(2024, 3, 31), start: day(2024, 3, 25), end: day(2024, 4, 5), direction: rollPrevious, want: day(2024, 3, 28)},
+		{name: "feriado avança para segunda", date: day(2024, 3, 29), start: day(2024, 3, 25), end: day(2024, 4, 5), direction: rollNext, want: day(2024, 4, 1)},
+		{name: "sábado avança para segunda", date: day(2024, 3, 30), start: day(2024, 3, 25), end: day(2024, 4, 5), direction: rollNext, want: day(2024, 4, 1)},
+		// Sprint que termina no domingo: seguir adiante sairia dela, então volta
+		{name: "next no fim da sprint volta para quinta", date: day(2024, 3, 29), start: day(2024, 3, 18), end: day(2024, 3, 31), direction: rollNext, want: day(2024, 3, 28)},
+		// Sprint que começa na sexta: voltar sairia dela, então avança
+		{name: "previous no início da sprint avança", date: day(2024, 3, 29), start: day(2024, 3, 29), end: day(2024, 4, 5), direction: rollPrevious, want: day(2024, 4, 1)},
+		{name: "sprint sem dia útil mantém a data", date: day(2024, 3, 29), start: day(2024, 3, 29), end: day(2024, 3, 31), direction: rollPrevious, want: day(2024, 3, 29)},
+		{name: "dia útil não muda", date: day(2024, 3, 28), start: day(2024, 3, 25), end: day(2024, 4, 5), direction: rollNext, want: day(2024, 3, 28)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			date := tt.date
+			plans := []generationPlan{{Story: WorkItem{ID: 1}, DueDate: &date}, {Story: WorkItem{ID: 2}}}
+			rollDueDates(plans, cal, tt.start, tt.end, tt.direction)
+			if !plans[0].DueDate.Equal(tt.want) {
+				t.Errorf("data = %s, quer %s", plans[0].DueDate.Format("Mon 2006-01-02"), tt.want.Format("Mon 2006-01-02"))
+			}
+			if plans[1].DueDate != nil {
+				t.Errorf("plano sem data recebeu %v", plans[1].DueDate)
+			}
+		})
+	}
+}
+
+func TestSpreadWithinWeek(t *testing.T) {
+	// Sprint de duas semanas: segunda 04/03/2024 a sexta 15/03/2024
+	days, err := workingDates(day(2024, 3, 4), day(2024, 3, 15), nil, workCalendar{Location: time.UTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ana := &Identity{DisplayName: "Ana", UniqueName: "ana@example.com"}
+	bruno := &Identity{DisplayName: "Bruno", UniqueName: "bruno@example.com"}
+	monday := day(2024, 3, 4)
+	plan := func(id int, assignee *Identity, date time.Time, earliest *time.Time) generationPlan {
+		return generationPlan{Story: WorkItem{ID: id, AssignedTo: assignee}, Start: date, DueDate: &date, Earliest: earliest}
+	}
+	buildPlans := func() []generationPlan {
+		fixed := plan(8, ana, day(2024, 3, 8), nil)
+		fixed.Override = overrideDueDate
+		return []generationPlan{
+			plan(1, ana, day(2024, 3, 8), &monday),
+			plan(2, ana, day(2024, 3, 8), &monday),
+			plan(3, ana, day(2024, 3, 8), &monday),
+			plan(4, ana, day(2024, 3, 6), &monday),
+			plan(5, bruno, day(2024, 3, 6), &monday),
+			// Sem folga (data de capacity) não se move
+			plan(6, bruno, day(2024, 3, 8), nil),
+			plan(7, bruno, day(2024, 3, 8), &monday),
+			fixed,
+			// Segunda da semana seguinte: não volta para a sexta anterior
+			plan(9, ana, day(2024, 3, 11), &monday),
+			plan(10, ana, day(2024, 3, 11), &monday),
+			plan(11, nil, day(2024, 3, 8), &monday),
+			plan(12, nil, day(2024, 3, 8), &monday),
+		}
+	}
+	// #2 depende de #5 (quarta), então não pode ficar antes de quinta
+	predecessors := map[int][]int{2: {5}}
+	distribution := func(plans []generationPlan) map[string]int {
+		items := make([]GenerationItem, len(plans))
+		for i, plan := range plans {
+			items[i] = GenerationItem{ID: plan.Story.ID, DueDate: plan.DueDate}
+		}
+		got := make(map[string]int)
+		for _, count := range weekdayDistribution(items) {
+			if count.Count > 0 {
+				got[count.Weekday] = count.Count
+			}
+		}
+		return got
+	}
+
+	tests := []struct {
+		name             string
+		spread           bool
+		wantDates        map[int]time.Time
+		wantDistribution map[string]int
+	}{
+		{
+			name:             "desligado",
+			spread:           false,
+			wantDates:        map[int]time.Time{1: day(2024, 3, 8), 2: day(2024, 3, 8), 3: day(2024, 3, 8), 9: day(2024, 3, 11)},
+			wantDistribution: map[string]int{"Wednesday": 2, "Friday": 8, "Monday": 2},
+		},
+		{
+			name:   "ligado",
+			spread: true,
+			wantDates: map[int]time.Time{
+				// #3 vai para quinta; #2 não cabe antes de quinta e fica; #1 pula a quarta de #4
+				1: day(2024, 3, 5), 2: day(2024, 3, 8), 3: day(2024, 3, 7), 4: day(2024, 3, 6),
+				6: day(2024, 3, 8), 7: day(2024, 3, 8), 8: day(2024, 3, 8),
+				9: day(2024, 3, 11), 10: day(2024, 3, 11), 11: day(2024, 3, 8),
+			},
+			wantDistribution: map[string]int{"Monday": 2, "Tuesday": 1, "Wednesday": 2, "Thursday": 1, "Friday": 6},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plans := buildPlans()
+			if tt.spread {
+				spreadWithinWeek(plans, days, predecessors)
+			}
+			byID := make(map[int]generationPlan)
+			for _, plan := range plans {
+				byID[plan.Story.ID] = plan
+			}
+			for id, want := range tt.wantDates {
+				if got := *byID[id].DueDate; !got.Equal(want) {
+					t.Errorf("#%d = %s, quer %s", id, got.Format("Mon 2006-01-02"), want.Format("Mon 2006-01-02"))
+				}
+			}
+			if got := distribution(plans); !reflect.DeepEqual(got, tt.wantDistribution) {
+				t.Errorf("distribuição = %v, quer %v", got, tt.wantDistribution)
+			}
+			for _, plan := range plans {
+				if plan.Spread && (plan.DueDate.Before(*plan.Earliest) || plan.Start.After(*plan.DueDate)) {
+					t.Errorf("#%d antecipada para fora da folga: %s", plan.Story.ID, plan.DueDate.Format("2006-01-02"))
+				}
+			}
+		})
+	}
+
+	// Determinístico: a mesma entrada dá sempre o mesmo resultado
+	first, second := buildPlans(), buildPlans()
+	spreadWithinWeek(first, days, predecessors)
+	spreadWithinWeek(second, days, predecessors)
+	for i := range first {
+		if !first[i].DueDate.Equal(*second[i].DueDate) {
+			t.Errorf("#%d mudou entre execuções: %s e %s", first[i].Story.ID, first[i].DueDate, second[i].DueDate)
+		}
+	}
+}
+
+func TestPlanRollupActivityOrder(t *testing.T) {
+	hours := func(value float64) *float64 { return &value }
+	thursday := day(2024, 3, 7)
+	ana := &Identity{DisplayName: "Ana", UniqueName: "ana@example.com"}
+	bruno := &Identity{DisplayName: "Bruno", UniqueName: "bruno@example.com"}
+	carla := &Identity{DisplayName: "Carla", UniqueName: "carla@example.com"}
+	tasks := map[int][]rollupTask{
+		1: {
+			{ID: 12, AssignedTo: bruno, Activity: "testing", RemainingWork: hours(12)},
+			{ID: 11, AssignedTo: ana, Activity: "Development", RemainingWork: hours(12)},
+			// Fora de ACTIVITY_ORDER: não espera ninguém
+			{ID: 13, AssignedTo: carla, Activity: "Documentation", RemainingWork: hours(4)},
+		},
+		// Task com data não é empurrada, mas conta para as atividades seguintes
+		2: {
+			{ID: 21, AssignedTo: ana, Activity: "Development", DueDate: &thursday},
+			{ID: 22, AssignedTo: carla, Activity: "Testing", RemainingWork: hours(4)},
+		},
+	}
+	tests := []struct {
+		name            string
+		order           []string
+		want            map[int]time.Time
+		wantExplanation map[int]int
+	}{
+		{
+			name:            "sem regra",
+			order:           nil,
+			want:            map[int]time.Time{1: day(2024, 3, 5), 2: day(2024, 3, 7)},
+			wantExplanation: map[int]int{1: 0, 2: 0},
+		},
+		{
+			name:  "desenvolvimento antes de teste",
+			order: []string{"Development", "Testing"},
+			// #12 começa na terça, quando #11 termina, e vai até quarta;
+			// #22 espera a data de #21 (quinta)
+			want:            map[int]time.Time{1: day(2024, 3, 6), 2: day(2024, 3, 7)},
+			wantExplanation: map[int]int{1: 1, 2: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := capacityPlanInput{
+				Start:         day(2024, 3, 4),
+				End:           day(2024, 3, 8),
+				Capacities:    map[string]TeamMemberCapacity{},
+				DefaultPerDay: 8,
+				Calendar:      workCalendar{Location: time.UTC},
+				ActivityOrder: tt.order,
+			}
+			plans, _, err := planRollup([]WorkItem{{ID: 1}, {ID: 2}}, tasks, input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, plan := range plans {
+				if plan.DueDate == nil || !plan.DueDate.Equal(tt.want[plan.Story.ID]) {
+					t.Errorf("#%d = %v, quer %s", plan.Story.ID, plan.DueDate, tt.want[plan.Story.ID].Format("2006-01-02"))
+				}
+				if len(plan.Explanation) != tt.wantExplanation[plan.Story.ID] {
+					t.Errorf("#%d explicação = %q, quer %d linha(s)", plan.Story.ID, plan.Explanation, tt.wantExplanation[plan.Story.ID])
+				}
+			}
+		})
+	}
+}
+
+func TestPlanCapacityFocusFactor(t *testing.T) {
+	ana := &Identity{DisplayName: "Ana", UniqueName: "ana@example.com"}
+	tests := []struct {
+		name        string
+		focusFactor float64
+		ceremonies  float64
+		wantDate    time.Time
+	}{
+		{name: "zero vale a capacidade inteira", focusFactor: 0, wantDate: day(2024, 3, 5)},
+		{name: "capacidade inteira", focusFactor: 1, wantDate: day(2024, 3, 5)},
+		{name: "metade da capacidade", focusFactor: 0.5, wantDate: day(2024, 3, 7)},
+		{name: "cerimônias descontadas", focusFactor: 1, ceremonies: 4, wantDate: day(2024, 3, 7)},
+		{name: "cerimônias antes do foco", focusFactor: 0.5, ceremonies: 4, wantDate: day(2024, 3, 13)},
+		{name: "cerimônias maiores que o dia", ceremonies: 10, wantDate: day(2024, 3, 15)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 16h com 8h/dia configuradas: 2 dias cheios, ou 4 com foco de 50%
+			// ou com 4h de cerimônias, 8 com as duas; sem capacidade o item fica no
+			// fim da sprint
+			input := capacityPlanInput{
+				Start:         day(2024, 3, 4),
+				End:           day(2024, 3, 15),
+				TaskWork:      map[int]float64{1: 16},
+				Capacities:    map[string]TeamMemberCapacity{"ana@example.com": {Activities: []CapacityActivity{{CapacityPerDay: 8}}}},
+				Calendar:      workCalendar{Location: time.UTC},
+				FocusFactor:   tt.focusFactor,
+				CeremonyHours: tt.ceremonies,
+			}
+			plans, _, err := planCapacity([]WorkItem{{ID: 1, AssignedTo: ana}}, input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plans[0].DueDate == nil || !plans[0].DueDate.Equal(tt.wantDate) {
+				t.Errorf("DueDate = %v, quer %s", plans[0].DueDate, tt.wantDate.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+// Sprint de 04/03 a 15/03/2024 com feriado na sexta 08/03 e meia folga de
+// Ana na quarta 06/03, usada pelas duas granularidades
+func granularitySprint(days bool, taskWork map[int]float64) capacityPlanInput {
+	return capacityPlanInput{
+		Start:    day(2024, 3, 4),
+		End:      day(2024, 3, 15),
+		TaskWork: taskWork,
+		Capacities: map[string]TeamMemberCapacity{
+			"ana@example.com":   {Activities: []CapacityActivity{{CapacityPerDay: 6}}, DaysOff: []DayOff{{Start: day(2024, 3, 6), End: day(2024, 3, 6), Fraction: 0.5}}},
+			"bruno@example.com": {Activities: []CapacityActivity{{CapacityPerDay: 0}}},
+		},
+		DefaultPerDay:      8,
+		HoursPerStoryPoint: 8,
+		Calendar:           workCalendar{Location: time.UTC, Holidays: map[time.Time]Holiday{day(2024, 3, 8): {Date: day(2024, 3, 8)}}},
+		Days:               days,
+	}
+}
+
+func TestPlanCapacityGranularity(t *testing.T) {
+	ana := &Identity{DisplayName: "Ana", UniqueName: "ana@example.com"}
+	bruno := &Identity{DisplayName: "Bruno", UniqueName: "bruno@example.com"}
+	points := 1.0
+	stories := []WorkItem{{ID: 1, AssignedTo: ana}, {ID: 2, AssignedTo: ana}, {ID: 3, AssignedTo: ana, StoryPoints: &points}, {ID: 4, AssignedTo: bruno}}
+	type want struct {
+		date   time.Time
+		atRisk bool
+		reason string
+	}
+	tests := []struct {
+		name     string
+		days     bool
+		taskWork map[int]float64
+		want     map[int]want
+	}{
+		{
+			// 6h por dia e 3h na quarta: 9h terminam na terça, mais 12h na
+			// quinta; Story Points viram horas e Bruno, sem horas, fica em risco
+			name:     "horas",
+			taskWork: map[int]float64{1: 9, 2: 12, 4: 1},
+			want: map[int]want{
+				1: {date: day(2024, 3, 5)},
+				2: {date: day(2024, 3, 7)},
+				3: {date: day(2024, 3, 12)},
+				4: {date: day(2024, 3, 15), atRisk: true},
+			},
+		},
+		{
+			// Dias inteiros: a quarta com meia folga e a sexta de feriado não
+			// contam; a data é o último dia consumido. Story Points não valem
+			// como estimativa em dias.
+			name:     "dias",
+			days:     true,
+			taskWork: map[int]float64{1: 2, 2: 2, 4: 1},
+			want: map[int]want{
+				1: {date: day(2024, 3, 5)},
+				2: {date: day(2024, 3, 11)},
+				3: {reason: reasonNoEstimate},
+				4: {date: day(2024, 3, 15), atRisk: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plans, _, err := planCapacity(stories, granularitySprint(tt.days, tt.taskWork))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, plan := range plans {
+				expected := tt.want[plan.Story.ID]
+				if expected.reason != "" {
+					if plan.DueDate != nil || plan.ReasonCode != expected.reason {
+						t.Errorf("#%d = %v (%s), quer fora com %s", plan.Story.ID, plan.DueDate, plan.ReasonCode, expected.reason)
+					}
+					continue
+				}
+				if plan.DueDate == nil || !plan.DueDate.Equal(expected.date) || plan.AtRisk != expected.atRisk {
+					t.Errorf("#%d = %v (em risco %t), quer %s (em risco %t)", plan.Story.ID, plan.DueDate, plan.AtRisk, expected.date.Format("2006-01-02"), expected.atRisk)
+				}
+			}
+		})
+	}
+}
+
+func TestMemberDaysGranularity(t *testing.T) {
+	tests := []struct {
+		name      string
+		days      bool
+		key       string
+		wantDates []time.Time
+		wantTotal float64
+	}{
+		{name: "horas com meia folga", key: "ana@example.com", wantDates: []time.Time{day(2024, 3, 4), day(2024, 3, 5), day(2024, 3, 6), day(2024, 3, 7)}, wantTotal: 21},
+		{name: "dias sem o dia de meia folga", days: true, key: "ana@example.com", wantDates: []time.Time{day(2024, 3, 4), day(2024, 3, 5), day(2024, 3, 7)}, wantTotal: 3},
+		{name: "horas sem capacidade", key: "bruno@example.com"},
+		{name: "dias sem capacidade", days: true, key: "bruno@example.com"},
+		{name: "dias com a capacidade padrão", days: true, key: "carla@example.com", wantDates: []time.Time{day(2024, 3, 4), day(2024, 3, 5), day(2024, 3, 6), day(2024, 3, 7)}, wantTotal: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := granularitySprint(tt.days, nil)
+			input.End = day(2024, 3, 8)
+			days, _, _, err := input.memberDays(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var dates []time.Time
+			total := 0.0
+			for _, d := range days {
+				dates = append(dates, d.Date)
+				total += d.Hours
+			}
+			if !reflect.DeepEqual(dates, tt.wantDates) || total != tt.wantTotal {
+				t.Errorf("dias = %v (total %g), quer %v (total %g)", dates, total, tt.wantDates, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestPlanCapacityAvailabilityWindow(t *testing.T) {
+	datePtr := func(date time.Time) *time.Time { return &date }
+	story := WorkItem{ID: 1, AssignedTo: &Identity{DisplayName: "Ana", UniqueName: "Ana@Example.com"}}
+	tests := []struct {
+		name       string
+		window     *AvailabilityWindow
+		wantDate   time.Time
+		wantAtRisk bool
+	}{
+		{name: "sem janela", wantDate: day(2024, 3, 5)},
+		// Entra na quinta: 16h terminam na sexta
+		{name: "entrada no meio da sprint", window: &AvailabilityWindow{From: datePtr(day(2024, 3, 7))}, wantDate: day(2024, 3, 8)},
+		// Sai depois da segunda: só 8h cabem
+		{name: "saída no meio da sprint", window: &AvailabilityWindow{To: datePtr(day(2024, 3, 4))}, wantDate: day(2024, 3, 15), wantAtRisk: true},
+		{name: "janela fora da sprint", window: &AvailabilityWindow{From: datePtr(day(2024, 4, 1)), To: datePtr(day(2024, 4, 12))}, wantDate: day(2024, 3, 15), wantAtRisk: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := capacityPlanInput{
+				Start:         day(2024, 3, 4),
+				End:           day(2024, 3, 15),
+				TaskWork:      map[int]float64{1: 16},
+				Capacities:    map[string]TeamMemberCapacity{},
+				DefaultPerDay: 8,
+				Calendar:      workCalendar{WeekendFactor: 1, Location: time.UTC},
+			}
+			if tt.window != nil {
+				input.Availability = map[string]AvailabilityWindow{"ana@example.com": *tt.window}
+			}
+			plans, _, err := planCapacity([]WorkItem{story}, input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plan := plans[0]; plan.DueDate == nil || !plan.DueDate.Equal(tt.wantDate) || plan.AtRisk != tt.wantAtRisk {
+				t.Errorf("DueDate = %v, atRisk %t; quer %s, %t", plan.DueDate, plan.AtRisk, tt.wantDate.Format("2006-01-02"), tt.wantAtRisk)
+			}
+		})
+	}
+}
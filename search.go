@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+// Tamanho máximo do termo de ?q= em GET /search
+const maxSearchTermLength = 255
+
+// Máximo de candidatos lidos do WIQL em GET /search. O corte em
+// SEARCH_MAX_RESULTS é feito depois de filtrar e ordenar, então os
+// candidatos precisam ir além dele.
+const maxSearchCandidates = 2000
+
+// GET /search: itens da sprint com o termo no título (System.Title CONTAINS,
+// sem diferenciar maiúsculas), no mesmo formato de /user-stories. Evita
+// baixar a sprint inteira para achar um item. O corte em SEARCH_MAX_RESULTS
+// vale sobre o resultado já ordenado por ?sort=, como em /user-stories.
+func handleSearch(pool *adoPool, cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ref, err := sprintRefFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		term := strings.TrimSpace(r.URL.Query().Get("q"))
+		if term == "" {
+			jsonError(w, "Parâmetro 'q' é obrigatório", http.StatusBadRequest)
+			return
+		}
+		if utf8.RuneCountInString(term) > maxSearchTermLength {
+			jsonError(w, fmt.Sprintf("Parâmetro 'q' excede %d caracteres", maxSearchTermLength), http.StatusBadRequest)
+			return
+		}
+		includeRemoved := r.URL.Query().Get("includeRemoved") == "true"
+		types, explicitTypes := typesFromRequest(cfg, r)
+		// sort e order são validados antes de consultar o Azure DevOps
+		if err := sortWorkItems(nil, r.URL.Query().Get("sort"), r.URL.Query().Get("order")); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		workClient, err := pool.Work(ctx, "search")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
+			return
+		}
+		targetIteration, err := resolveSprintRef(ctx, workClient, cfg, ref)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		sprintName := *targetIteration.Name
+		setSprintHeaders(w, targetIteration)
+		if targetIteration.Path == nil {
+			respondError(w, "", fmt.Errorf("Sprint '%s' sem caminho de iteração", sprintName))
+			return
+		}
+
+		witClient, err := pool.WorkItems(ctx, "search")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+		// Como em /user-stories, ?types= com tipo inexistente é 400 e não uma busca vazia
+		if explicitTypes {
+			known, err := projectTypeNames(ctx, witClient, cfg.Project)
+			if err != nil {
+				respondError(w, "Erro ao buscar tipos de work item", err)
+				return
+			}
+			if err := validateKnownTypes(types, known); err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		// O termo vira literal WIQL escapado; nunca é interpolado na consulta.
+		// ORDER BY deixa estável o conjunto de candidatos quando há mais que o limite.
+		wiql, err := newWiqlQuery("System.Id").
+			Where("System.IterationPath", "=", *targetIteration.Path).
+			WhereInStrings("System.WorkItemType", types).
+			Where("System.Title", "CONTAINS", term).
+			OrderBy("System.Id", false).
+			Build()
+		if err != nil {
+			respondError(w, "Erro ao montar consulta de busca", err)
+			return
+		}
+		// Um a mais que o limite, para saber se a busca foi cortada
+		top := max(maxSearchCandidates, cfg.SearchMaxResults+1)
+		query := workitemtracking.Wiql{Query: &wiql}
+		queryResults, err := witClient.QueryByWiql(ctx, workitemtracking.QueryByWiqlArgs{
+			Wiql:    &query,
+			Project: &cfg.Project,
+			Top:     &top,
+		})
+		if err != nil {
+			respondError(w, "Erro ao buscar work items", wrapAdoError(err, "QueryByWiql", "sprint=%s", sprintName))
+			return
+		}
+		var ids []int
+		if queryResults != nil && queryResults.WorkItems != nil {
+			for _, item := range *queryResults.WorkItems {
+				if item.Id != nil {
+					ids = append(ids, *item.Id)
+				}
+			}
+		}
+		truncated := len(ids) >= top
+		if truncated {
+			ids = ids[:top-1]
+			log.Printf("[WARN] Busca por %q na sprint '%s' com mais de %d candidatos; ordenando só os primeiros por ID", term, sprintName, top-1)
+		}
+
+		result := make([]WorkItem, 0)
+		if len(ids) > 0 {
+			backlogRanks, err := fetchBacklogRanks(ctx, workClient, cfg)
+			if err != nil {
+				log.Printf("[WARN] Ordem do backlog indisponível: %v", err)
+			}
+			workItems, err := getWorkItemsChunked(ctx, witClient, cfg.Project, ids, withExtraFields(userStoryFields, cfg.ExtraFields))
+			if err != nil {
+				respondError(w, "Erro ao buscar detalhes dos work items", err)
+				return
+			}
+			setMissingWorkItemsHeader(w, missingWorkItemIds(ids, workItems))
+			for _, detail := range presentWorkItems(ids, workItems) {
+				if item, ok := buildUserStory(detail, cfg, types, backlogRanks); ok {
+					if item.Removed && !includeRemoved {
+						continue
+					}
+					result = append(result, item)
+				}
+			}
+		}
+
+		if err := sortWorkItems(result, r.URL.Query().Get("sort"), r.URL.Query().Get("order")); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(result) > cfg.SearchMaxResults {
+			result = result[:cfg.SearchMaxResults]
+			truncated = true
+			log.Printf("[WARN] Busca por %q na sprint '%s' cortada em %d itens (SEARCH_MAX_RESULTS)", term, sprintName, cfg.SearchMaxResults)
+		}
+		if truncated {
+			w.Header().Set("X-Search-Truncated", "true")
+		}
+		log.Printf("[DEBUG] Busca por %q na sprint '%s': %d itens", term, sprintName, len(result))
+		writeJSON(w, http.StatusOK, result)
+	}
+}
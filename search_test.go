@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+)
+
+// Função para montar o cenário de /search: cinco User Stories com "Login" no
+// título e prioridades que não seguem a ordem dos IDs
+func searchFixture() (*fakeWorkClient, *fakeWitClient, work.TeamSettingsIteration) {
+	iteration := fakeIteration("Sprint 1", day(2024, 3, 4), day(2024, 3, 15), "")
+	workClient := &fakeWorkClient{iterations: []work.TeamSettingsIteration{iteration}}
+	ranks := map[int]float64{10: 500, 11: 400, 12: 300, 13: 200, 14: 100}
+	witClient := newFakeWitClient()
+	for id, rank := range ranks {
+		witClient.items[id] = fakeWorkItem(id, map[string]interface{}{
+			"System.WorkItemType":             "User Story",
+			"System.Title":                    "Login",
+			"System.State":                    "Active",
+			"Microsoft.VSTS.Common.StackRank": rank,
+		})
+	}
+	witClient.types = []string{"User Story", "Bug", "Task"}
+	// O Azure DevOps devolve os IDs na ordem pedida; aqui em ordem de ID
+	witClient.queryByWiql = func(query string) []int { return []int{10, 11, 12, 13, 14} }
+	return workClient, witClient, iteration
+}
+
+func TestHandleSearchTruncatesAfterSorting(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantIds   []int
+		truncated bool
+	}{
+		{name: "prioridade padrão", wantIds: []int{14, 13}, truncated: true},
+		{name: "ID decrescente", query: "&sort=id&order=desc", wantIds: []int{14, 13}, truncated: true},
+		{name: "ID crescente", query: "&sort=id", wantIds: []int{10, 11}, truncated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workClient, witClient, iteration := searchFixture()
+			cfg := &Config{Project: "Projeto", Team: "Time", WorkItemTypes: []string{"User Story"}, SearchMaxResults: 2}
+			recorder := httptest.NewRecorder()
+			handleSearch(newFakePool(workClient, witClient), cfg)(recorder,
+				httptest.NewRequest(http.MethodGet, "/search?q=login&sprintId="+iteration.Id.String()+tt.query, nil))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+			}
+			var items []WorkItem
+			if err := json.Unmarshal(recorder.Body.Bytes(), &items); err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, item := range items {
+				ids = append(ids, item.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIds) {
+				t.Errorf("IDs = %v, quer %v", ids, tt.wantIds)
+			}
+			if got := recorder.Header().Get("X-Search-Truncated") == "true"; got != tt.truncated {
+				t.Errorf("X-Search-Truncated = %t, quer %t", got, tt.truncated)
+			}
+			if len(witClient.wiqlQueries) != 1 || !strings.HasSuffix(witClient.wiqlQueries[0], "ORDER BY [System.Id] ASC") {
+				t.Errorf("consultas = %v, quer ORDER BY [System.Id]", witClient.wiqlQueries)
+			}
+		})
+	}
+}
+
+func TestHandleSearchValidatesParameters(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		wantText string
+	}{
+		{name: "tipo inexistente", query: "&types=Bug,Epico", wantText: "Epico"},
+		{name: "sort inválido", query: "&sort=estado", wantText: "sort"},
+		{name: "order inválido", query: "&order=aleatorio", wantText: "order"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workClient, witClient, iteration := searchFixture()
+			cfg := &Config{Project: "Projeto", Team: "Time", WorkItemTypes: []string{"User Story"}, SearchMaxResults: 2}
+			recorder := httptest.NewRecorder()
+			handleSearch(newFakePool(workClient, witClient), cfg)(recorder,
+				httptest.NewRequest(http.MethodGet, "/search?q=login&sprintId="+iteration.Id.String()+tt.query, nil))
+			if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), tt.wantText) {
+				t.Errorf("status = %d, corpo = %s; quer 400 citando %q", recorder.Code, recorder.Body.String(), tt.wantText)
+			}
+			if len(witClient.wiqlQueries) != 0 {
+				t.Errorf("consulta WIQL feita com parâmetros inválidos: %v", witClient.wiqlQueries)
+			}
+		})
+	}
+}
+
+func TestValidateKnownTypes(t *testing.T) {
+	known := []string{"User Story", "Bug", "Task"}
+	if err := validateKnownTypes([]string{"user story", "BUG"}, known); err != nil {
+		t.Errorf("tipos existentes recusados: %v", err)
+	}
+	if err := validateKnownTypes([]string{"Feature"}, nil); err != nil {
+		t.Errorf("sem lista de tipos a validação deveria passar: %v", err)
+	}
+	err := validateKnownTypes([]string{"Bug", "Epico"}, known)
+	if err == nil || !strings.Contains(err.Error(), "Epico") || !strings.Contains(err.Error(), "Bug, Task, User Story") {
+		t.Errorf("erro = %v", err)
+	}
+}
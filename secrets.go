@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7"
+)
+
+// Origem de um segredo, exposta em /health
+const (
+	secretSourceEnv  = "env"
+	secretSourceFile = "file"
+)
+
+// Intervalo mínimo entre duas releituras disparadas por falha de
+// autenticação, para um PAT revogado não virar uma leitura por chamada
+const authReloadCooldown = 30 * time.Second
+
+// secret guarda um segredo lido do ambiente ou de NOME_FILE. Quando vem de
+// arquivo, pode ser relido sem reiniciar o servidor (por exemplo quando o
+// Kubernetes rotaciona o segredo montado).
+type secret struct {
+	name string
+	// Caminho de NOME_FILE; vazio quando o segredo vem da variável NOME
+	path string
+	// Validação opcional de um valor relido; um valor inválido é recusado
+	// e o anterior mantido
+	validate func(string) error
+
+	mu       sync.RWMutex
+	value    string
+	loadedAt time.Time
+}
+
+// Situação de um segredo em /health; o valor nunca é exposto
+type SecretStatus struct {
+	Source   string    `json:"source"`
+	File     string    `json:"file,omitempty"`
+	Set      bool      `json:"set"`
+	LoadedAt time.Time `json:"loadedAt"`
+}
+
+// Função para carregar um segredo com readSecret, guardando de onde veio
+func loadSecret(name string) (*secret, error) {
+	value, err := readSecret(name)
+	if err != nil {
+		return nil, err
+	}
+	return &secret{name: name, path: os.Getenv(name + "_FILE"), value: value, loadedAt: time.Now().UTC()}, nil
+}
+
+// Função para ler um segredo do ambiente. Se NOME_FILE estiver definido, o
+// conteúdo do arquivo (sem espaços nas pontas) tem precedência sobre NOME, o
+// que permite usar segredos montados como arquivo (por exemplo no Kubernetes).
+func readSecret(name string) (string, error) {
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return os.Getenv(name), nil
+	}
+	value, err := readSecretFile(name, path)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("[DEBUG] %s carregado de arquivo (%s_FILE)", name, name)
+	return value, nil
+}
+
+// Função para ler o conteúdo de NOME_FILE; arquivo vazio é erro
+func readSecretFile(name, path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%s_FILE aponta para um arquivo que não pôde ser lido (%s): %w", name, path, err)
+	}
+	value := strings.TrimSpace(string(content))
+	if value == "" {
+		return "", fmt.Errorf("%s_FILE aponta para um arquivo vazio: %s", name, path)
+	}
+	return value, nil
+}
+
+// Função para obter o valor atual; vazio para um segredo não configurado (nil)
+func (s *secret) Value() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.value
+}
+
+// Função para descrever a origem do segredo sem expor o valor
+func (s *secret) Status() SecretStatus {
+	if s == nil {
+		return SecretStatus{Source: secretSourceEnv}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	status := SecretStatus{Source: secretSourceEnv, Set: s.value != "", LoadedAt: s.loadedAt}
+	if s.path != "" {
+		status.Source, status.File = secretSourceFile, s.path
+	}
+	return status
+}
+
+// Função para reler o segredo de NOME_FILE. Segredos vindos do ambiente não
+// mudam em execução, então não há o que reler. changed indica um valor novo;
+// em caso de erro (arquivo sumiu ou ficou vazio no meio da rotação) o valor
+// anterior é mantido.
+func (s *secret) reload() (changed bool, err error) {
+	if s == nil || s.path == "" {
+		return false, nil
+	}
+	value, err := readSecretFile(s.name, s.path)
+	if err != nil {
+		return false, err
+	}
+	if s.validate != nil {
+		if err := s.validate(value); err != nil {
+			return false, err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed = value != s.value
+	s.value = value
+	s.loadedAt = time.Now().UTC()
+	return changed, nil
+}
+
+// secretRefresher relê os segredos vindos de arquivo periodicamente
+// (SECRETS_RELOAD_INTERVAL) e quando o Azure DevOps recusa o PAT. Um PAT novo
+// recria a conexão do pool; a chave administrativa e a URL do webhook são
+// lidas a cada uso.
+type secretRefresher struct {
+	cfg  *Config
+	pool *adoPool
+
+	mu             sync.Mutex
+	lastAuthReload time.Time
+}
+
+// Função para reler os segredos, aplicando um PAT novo ao pool
+func (r *secretRefresher) refresh(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changed, err := r.cfg.PAT.reload()
+	if err != nil {
+		log.Printf("[WARN] Releitura do AZURE_DEVOPS_PAT (%s) falhou; mantendo o valor anterior: %v", reason, err)
+	} else if changed {
+		r.pool.reconnect(azuredevops.NewPatConnection(r.cfg.Organization, r.cfg.PAT.Value()))
+		log.Printf("[DEBUG] AZURE_DEVOPS_PAT relido de arquivo (%s); clientes do Azure DevOps recriados", reason)
+	}
+
+	changed, err = r.cfg.AdminAPIKey.reload()
+	if err != nil {
+		log.Printf("[WARN] Releitura do ADMIN_API_KEY (%s) falhou; mantendo o valor anterior: %v", reason, err)
+	} else if changed {
+		log.Printf("[DEBUG] ADMIN_API_KEY relido de arquivo (%s)", reason)
+	}
+
+	changed, err = r.cfg.NotifyWebhook.reload()
+	if err != nil {
+		log.Printf("[WARN] Releitura do NOTIFY_WEBHOOK_URL (%s) falhou; mantendo o valor anterior: %v", reason, err)
+	} else if changed {
+		log.Printf("[DEBUG] NOTIFY_WEBHOOK_URL relido de arquivo (%s)", reason)
+	}
+}
+
+// Função chamada pelo pool quando o Azure DevOps responde 401/403. Releituras
+// seguidas são limitadas por authReloadCooldown.
+func (r *secretRefresher) onAuthError() {
+	r.mu.Lock()
+	if time.Since(r.lastAuthReload) < authReloadCooldown {
+		r.mu.Unlock()
+		return
+	}
+	r.lastAuthReload = time.Now()
+	r.mu.Unlock()
+	r.refresh("falha de autenticação")
+}
+
+// Função para reler os segredos a cada interval; roda até o processo terminar
+func (r *secretRefresher) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		r.refresh("periódica")
+	}
+}
+
+// Função para saber se algum segredo vem de arquivo e pode mudar em execução
+func (cfg *Config) hasSecretFiles() bool {
+	for _, s := range []*secret{cfg.PAT, cfg.AdminAPIKey, cfg.NotifyWebhook} {
+		if s.Status().Source == secretSourceFile {
+			return true
+		}
+	}
+	return false
+}
+
+// Função para reconhecer falhas de autenticação do Azure DevOps nos erros crus do SDK
+func isAdoAuthError(err error) bool {
+	return errors.Is(classifyAdoError(err), ErrAdoAuth)
+}
+
+// Resposta de GET /health
+type HealthResponse struct {
+	Status  string                  `json:"status"`
+	Secrets map[string]SecretStatus `json:"secrets"`
+}
+
+// Handler de GET /health: o serviço responde e de onde vem cada segredo
+// (env ou file) e quando foi lido pela última vez
+func handleHealth(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, HealthResponse{
+			Status: "ok",
+			Secrets: map[string]SecretStatus{
+				"AZURE_DEVOPS_PAT":   cfg.PAT.Status(),
+				"ADMIN_API_KEY":      cfg.AdminAPIKey.Status(),
+				"NOTIFY_WEBHOOK_URL": cfg.NotifyWebhook.Status(),
+			},
+		})
+	}
+}
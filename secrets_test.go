@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7"
+)
+
+// Função para gravar o conteúdo de um segredo montado como arquivo
+func writeSecretFile(t *testing.T, path, value string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(value), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSecretReloadFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pat")
+	writeSecretFile(t, path, "antigo\n")
+	t.Setenv("AZURE_DEVOPS_PAT", "do-ambiente")
+	t.Setenv("AZURE_DEVOPS_PAT_FILE", path)
+
+	pat, err := loadSecret("AZURE_DEVOPS_PAT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pat.Value() != "antigo" {
+		t.Fatalf("valor = %q, quer o conteúdo do arquivo", pat.Value())
+	}
+	if status := pat.Status(); status.Source != secretSourceFile || status.File != path || !status.Set {
+		t.Errorf("status = %+v, quer origem file em %s", status, path)
+	}
+
+	if changed, err := pat.reload(); err != nil || changed {
+		t.Errorf("releitura sem mudança = %t, %v; quer false, nil", changed, err)
+	}
+
+	writeSecretFile(t, path, "novo")
+	if changed, err := pat.reload(); err != nil || !changed {
+		t.Fatalf("releitura após rotação = %t, %v; quer true, nil", changed, err)
+	}
+	if pat.Value() != "novo" {
+		t.Errorf("valor = %q, quer novo", pat.Value())
+	}
+
+	// Arquivo vazio no meio da rotação mantém o valor anterior
+	writeSecretFile(t, path, "  ")
+	if _, err := pat.reload(); err == nil {
+		t.Error("arquivo vazio deveria falhar")
+	}
+	if pat.Value() != "novo" {
+		t.Errorf("valor = %q após falha, quer novo", pat.Value())
+	}
+}
+
+func TestSecretFromEnvIsNotReloaded(t *testing.T) {
+	t.Setenv("ADMIN_API_KEY", "chave")
+	t.Setenv("ADMIN_API_KEY_FILE", "")
+
+	key, err := loadSecret("ADMIN_API_KEY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := key.Status(); status.Source != secretSourceEnv || status.File != "" || !status.Set {
+		t.Errorf("status = %+v, quer origem env", status)
+	}
+	t.Setenv("ADMIN_API_KEY", "outra")
+	if changed, err := key.reload(); err != nil || changed || key.Value() != "chave" {
+		t.Errorf("releitura = %t, %v, valor %q; quer nada mudado", changed, err, key.Value())
+	}
+
+	var unset *secret
+	if unset.Value() != "" || unset.Status().Set {
+		t.Error("segredo nil deveria estar vazio")
+	}
+}
+
+func TestAdoPoolAuthErrorHook(t *testing.T) {
+	pool := newFakePool(&fakeWorkClient{}, &fakeWitClient{})
+	calls := make(chan struct{}, 4)
+	pool.onAuthError = func() { calls <- struct{}{} }
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "401", err: adoStatusError(http.StatusUnauthorized, "unauthorized"), want: true},
+		{name: "403", err: adoStatusError(http.StatusForbidden, "forbidden"), want: true},
+		{name: "404", err: adoStatusError(http.StatusNotFound, "not found"), want: false},
+		{name: "sucesso", err: nil, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release, err := pool.acquire(context.Background(), "teste")
+			if err != nil {
+				t.Fatal(err)
+			}
+			release(tt.err)
+			select {
+			case <-calls:
+				if !tt.want {
+					t.Error("onAuthError chamado sem falha de autenticação")
+				}
+			case <-time.After(200 * time.Millisecond):
+				if tt.want {
+					t.Error("onAuthError não foi chamado")
+				}
+			}
+		})
+	}
+}
+
+func TestSecretRefresherReconnectsOnNewPat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pat")
+	writeSecretFile(t, path, "antigo")
+	t.Setenv("AZURE_DEVOPS_PAT_FILE", path)
+	pat, err := loadSecret("AZURE_DEVOPS_PAT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{Organization: "https://dev.azure.com/org", PAT: pat}
+	original := azuredevops.NewPatConnection(cfg.Organization, pat.Value())
+	pool := newFakePool(&fakeWorkClient{}, &fakeWitClient{})
+	pool.connection = original
+	refresher := &secretRefresher{cfg: cfg, pool: pool}
+
+	// Sem mudança no arquivo os clientes continuam
+	refresher.refresh("teste")
+	if pool.work == nil || pool.wit == nil || pool.connection != original {
+		t.Fatal("refresh sem PAT novo descartou os clientes")
+	}
+
+	writeSecretFile(t, path, "novo")
+	refresher.onAuthError()
+	if pool.work != nil || pool.wit != nil {
+		t.Error("PAT novo deveria descartar os clientes criados com o antigo")
+	}
+	if pool.connection == original || pool.connection.AuthorizationString != azuredevops.NewPatConnection(cfg.Organization, "novo").AuthorizationString {
+		t.Error("a conexão nova deveria usar o PAT novo")
+	}
+
+	// Uma segunda falha dentro do intervalo mínimo não relê
+	writeSecretFile(t, path, "terceiro")
+	refresher.onAuthError()
+	if pat.Value() != "novo" {
+		t.Errorf("PAT = %q, quer novo (releitura limitada por authReloadCooldown)", pat.Value())
+	}
+}
+
+func TestHandleHealthReportsSecretSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pat")
+	writeSecretFile(t, path, "segredo-do-arquivo")
+	t.Setenv("AZURE_DEVOPS_PAT_FILE", path)
+	pat, err := loadSecret("AZURE_DEVOPS_PAT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{PAT: pat}
+
+	rec := httptest.NewRecorder()
+	handleHealth(cfg)(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var resp HealthResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Secrets["AZURE_DEVOPS_PAT"]; got.Source != secretSourceFile || got.File != path || !got.Set {
+		t.Errorf("AZURE_DEVOPS_PAT = %+v, quer origem file", got)
+	}
+	if got := resp.Secrets["ADMIN_API_KEY"]; got.Source != secretSourceEnv || got.Set {
+		t.Errorf("ADMIN_API_KEY = %+v, quer env não definido", got)
+	}
+	if strings.Contains(rec.Body.String(), "segredo-do-arquivo") {
+		t.Error("/health expôs o valor do segredo")
+	}
+}
+
+func TestNotifyWebhookURLFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhook")
+	writeSecretFile(t, path, "https://hooks.example.com/antigo\n")
+	t.Setenv("NOTIFY_WEBHOOK_URL_FILE", path)
+	webhook, err := loadSecret("NOTIFY_WEBHOOK_URL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhook.validate = validateWebhookURL
+	cfg := &Config{NotifyWebhook: webhook}
+	if !cfg.hasSecretFiles() {
+		t.Error("NOTIFY_WEBHOOK_URL_FILE deveria ativar a releitura de segredos")
+	}
+	refresher := &secretRefresher{cfg: cfg, pool: newFakePool(&fakeWorkClient{}, &fakeWitClient{})}
+
+	writeSecretFile(t, path, "https://hooks.example.com/novo")
+	refresher.refresh("teste")
+	if webhook.Value() != "https://hooks.example.com/novo" {
+		t.Errorf("webhook = %q, quer a URL rotacionada", webhook.Value())
+	}
+
+	// URL inválida no arquivo mantém a anterior
+	writeSecretFile(t, path, "não é uma url")
+	refresher.refresh("teste")
+	if webhook.Value() != "https://hooks.example.com/novo" {
+		t.Errorf("webhook = %q após URL inválida, quer a anterior", webhook.Value())
+	}
+
+	rec := httptest.NewRecorder()
+	handleHealth(cfg)(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var resp HealthResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Secrets["NOTIFY_WEBHOOK_URL"]; got.Source != secretSourceFile || got.File != path || !got.Set {
+		t.Errorf("NOTIFY_WEBHOOK_URL = %+v, quer origem file", got)
+	}
+	if strings.Contains(rec.Body.String(), "hooks.example.com") {
+		t.Error("/health expôs a URL do webhook")
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Ações aceitas em adjustments de POST /simulate
+const (
+	adjustmentAdd        = "add"
+	adjustmentRemove     = "remove"
+	adjustmentUpdate     = "update"
+	adjustmentAddDaysOff = "addDaysOff"
+)
+
+// Maior capacidade por dia aceita numa atividade simulada
+const maxSimulatedCapacityPerDay = 24
+
+// Folga de um ajuste simulado, como DayOff mas com datas em texto (2024-07-15)
+type SimulatedDayOff struct {
+	Start    string  `json:"start"`
+	End      string  `json:"end"`
+	Fraction float64 `json:"fraction,omitempty"`
+}
+
+// Ajuste de capacidade de POST /simulate, no formato de TeamMemberCapacity.
+// Stories reatribui User Stories da sprint ao desenvolvedor (add e update).
+type CapacityAdjustment struct {
+	Action     string             `json:"action"`
+	Name       string             `json:"name,omitempty"`
+	Email      string             `json:"email"`
+	Activities []CapacityActivity `json:"activities,omitempty"`
+	DaysOff    []SimulatedDayOff  `json:"daysOff,omitempty"`
+	Stories    []int              `json:"stories,omitempty"`
+}
+
+// Ajuste validado, com as folgas já convertidas
+type capacityAdjustment struct {
+	CapacityAdjustment
+	daysOff []DayOff
+}
+
+// Utilização de um desenvolvedor na simulação. TotalCapacity são as horas do
+// início do cálculo ao fim da sprint; AllocatedHours soma o trabalho restante
+// das User Stories dele (tasks estimadas ou Story Points × horas por ponto).
+type SimulatedDeveloper struct {
+	Name            string   `json:"name"`
+	Email           string   `json:"email"`
+	Adjustment      string   `json:"adjustment,omitempty"`
+	DefaultCapacity bool     `json:"defaultCapacity,omitempty"`
+	CapacityPerDay  float64  `json:"capacityPerDay"`
+	TotalCapacity   float64  `json:"totalCapacity"`
+	AllocatedHours  float64  `json:"allocatedHours"`
+	Utilization     *float64 `json:"utilization"`
+	Stories         int      `json:"stories"`
+	AtRisk          int      `json:"atRisk"`
+}
+
+// Resposta de POST /simulate: o relatório de um dry-run com os ajustes
+// aplicados e a utilização resultante de cada desenvolvedor
+type SimulationReport struct {
+	GenerationReport
+	Adjustments []CapacityAdjustment `json:"adjustments"`
+	Developers  []SimulatedDeveloper `json:"developers"`
+}
+
+// POST /simulate: geração em dry-run com ajustes de capacidade só em memória.
+// Nada é gravado no Azure DevOps nem registrado em /runs.
+func handleSimulate(pool *adoPool, cfg *Config) http.HandlerFunc {
+	return generationHandler(pool, cfg, nil, nil, nil, generationSimulate)
+}
+
+// Função para validar os ajustes da simulação antes de consultar o Azure DevOps
+func parseCapacityAdjustments(requests []CapacityAdjustment) ([]capacityAdjustment, error) {
+	adjustments := make([]capacityAdjustment, 0, len(requests))
+	for i, request := range requests {
+		request.Email = strings.TrimSpace(request.Email)
+		request.Name = strings.TrimSpace(request.Name)
+		adjustment := capacityAdjustment{CapacityAdjustment: request}
+		if request.Email == "" {
+			return nil, fmt.Errorf("adjustments[%d]: campo 'email' é obrigatório", i)
+		}
+		switch request.Action {
+		case adjustmentAdd, adjustmentUpdate:
+			if len(request.Activities) == 0 {
+				return nil, fmt.Errorf("adjustments[%d]: informe 'activities' com a capacidade por dia", i)
+			}
+		case adjustmentRemove:
+		case adjustmentAddDaysOff:
+			if len(request.DaysOff) == 0 {
+				return nil, fmt.Errorf("adjustments[%d]: informe 'daysOff'", i)
+			}
+		default:
+			return nil, fmt.Errorf("adjustments[%d]: ação inválida %q (use %s, %s, %s ou %s)",
+				i, request.Action, adjustmentAdd, adjustmentRemove, adjustmentUpdate, adjustmentAddDaysOff)
+		}
+		if len(request.Stories) > 0 && request.Action != adjustmentAdd && request.Action != adjustmentUpdate {
+			return nil, fmt.Errorf("adjustments[%d]: 'stories' só é aceito em %s e %s", i, adjustmentAdd, adjustmentUpdate)
+		}
+		for _, activity := range request.Activities {
+			if activity.CapacityPerDay < 0 || activity.CapacityPerDay > maxSimulatedCapacityPerDay {
+				return nil, fmt.Errorf("adjustments[%d]: 'capacityPerDay' deve estar entre 0 e %d", i, maxSimulatedCapacityPerDay)
+			}
+		}
+		for _, dayOff := range request.DaysOff {
+			start, err := parseDueDate(dayOff.Start)
+			if err != nil {
+				return nil, fmt.Errorf("adjustments[%d]: início de folga inválido: %v", i, err)
+			}
+			end, err := parseDueDate(dayOff.End)
+			if err != nil {
+				return nil, fmt.Errorf("adjustments[%d]: fim de folga inválido: %v", i, err)
+			}
+			if end.Before(start) {
+				return nil, fmt.Errorf("adjustments[%d]: folga termina antes de começar", i)
+			}
+			if dayOff.Fraction < 0 || dayOff.Fraction > 1 {
+				return nil, fmt.Errorf("adjustments[%d]: 'fraction' deve estar entre 0 e 1", i)
+			}
+			adjustment.daysOff = append(adjustment.daysOff, DayOff{Start: start, End: end, Fraction: dayOff.Fraction})
+		}
+		for _, id := range request.Stories {
+			if id <= 0 {
+				return nil, fmt.Errorf("adjustments[%d]: ID de User Story inválido: %d", i, id)
+			}
+		}
+		adjustments = append(adjustments, adjustment)
+	}
+	return adjustments, nil
+}
+
+// Função para encontrar a chave de capacidade (identityKey) de um e-mail; sem
+// capacidade configurada, a chave é o próprio e-mail em minúsculas
+func adjustmentKey(capacities map[string]TeamMemberCapacity, email string) string {
+	for key, capacity := range capacities {
+		if strings.EqualFold(capacity.Email, email) {
+			return key
+		}
+	}
+	return strings.ToLower(email)
+}
+
+// Função para aplicar os ajustes, em ordem, a uma cópia das capacidades da
+// sprint. Desenvolvedores removidos ficam sem capacidade (as User Stories
+// deles ficam em risco) e os que não tinham capacidade partem de
+// DEFAULT_CAPACITY_PER_DAY. Devolve também a última ação de cada chave.
+func applyCapacityAdjustments(capacities map[string]TeamMemberCapacity, adjustments []capacityAdjustment, defaultPerDay float64) (map[string]TeamMemberCapacity, map[string]string, error) {
+	adjusted := make(map[string]TeamMemberCapacity, len(capacities))
+	for key, capacity := range capacities {
+		adjusted[key] = capacity
+	}
+	actions := make(map[string]string)
+	for i, adjustment := range adjustments {
+		key := adjustmentKey(adjusted, adjustment.Email)
+		capacity, exists := adjusted[key]
+		if !exists {
+			capacity = TeamMemberCapacity{
+				Name:       adjustment.Email,
+				Email:      adjustment.Email,
+				Activities: []CapacityActivity{{CapacityPerDay: defaultPerDay}},
+				DaysOff:    []DayOff{},
+			}
+		}
+		if adjustment.Name != "" {
+			capacity.Name = adjustment.Name
+		}
+		switch adjustment.Action {
+		case adjustmentAdd:
+			if exists {
+				return nil, nil, fmt.Errorf("adjustments[%d]: %s já tem capacidade na sprint; use %s", i, adjustment.Email, adjustmentUpdate)
+			}
+			capacity.Activities = append([]CapacityActivity{}, adjustment.Activities...)
+			capacity.DaysOff = append([]DayOff{}, adjustment.daysOff...)
+		case adjustmentRemove:
+			capacity.Activities = []CapacityActivity{}
+		case adjustmentUpdate:
+			capacity.Activities = append([]CapacityActivity{}, adjustment.Activities...)
+			if len(adjustment.daysOff) > 0 {
+				capacity.DaysOff = append(append([]DayOff{}, capacity.DaysOff...), adjustment.daysOff...)
+			}
+		case adjustmentAddDaysOff:
+			capacity.DaysOff = append(append([]DayOff{}, capacity.DaysOff...), adjustment.daysOff...)
+		}
+		adjusted[key] = capacity
+		actions[key] = adjustment.Action
+	}
+	return adjusted, actions, nil
+}
+
+// Função para reatribuir as User Stories listadas em stories dos ajustes.
+// IDs fora das User Stories consideradas viram avisos.
+func reassignStories(stories []WorkItem, adjustments []capacityAdjustment, capacities map[string]TeamMemberCapacity) []string {
+	position := make(map[int]int, len(stories))
+	for i, story := range stories {
+		position[story.ID] = i
+	}
+	var warnings []string
+	for _, adjustment := range adjustments {
+		if len(adjustment.Stories) == 0 {
+			continue
+		}
+		// A identidade precisa gerar a mesma chave de capacidade do ajuste
+		key := adjustmentKey(capacities, adjustment.Email)
+		identity := &Identity{DisplayName: adjustment.Email, UniqueName: adjustment.Email}
+		if key != strings.ToLower(adjustment.Email) {
+			identity = &Identity{DisplayName: adjustment.Email, Descriptor: key}
+		}
+		if capacity, ok := capacities[key]; ok && capacity.Name != "" {
+			identity.DisplayName = capacity.Name
+		}
+		for _, id := range adjustment.Stories {
+			index, ok := position[id]
+			if !ok {
+				warnings = append(warnings, fmt.Sprintf("Reatribuição de #%d para %s ignorada: não é uma User Story aberta considerada na sprint", id, adjustment.Email))
+				continue
+			}
+			stories[index].AssignedTo = identity
+		}
+	}
+	return warnings
+}
+
+// Função para calcular a utilização de cada desenvolvedor com capacidade na
+// sprint ou com User Stories atribuídas, ordenados por nome
+func simulatedUtilization(plans []generationPlan, input capacityPlanInput, actions map[string]string) ([]SimulatedDeveloper, error) {
+	developers := make(map[string]*SimulatedDeveloper)
+	developer := func(key, name, email string) (*SimulatedDeveloper, error) {
+		if found, ok := developers[key]; ok {
+			return found, nil
+		}
+		found := &SimulatedDeveloper{Name: name, Email: email, Adjustment: actions[key]}
+		days, perDay, configured, err := input.memberDays(key)
+		if configured {
+			capacity := input.Capacities[key]
+			found.Name, found.Email = capacity.Name, capacity.Email
+		} else {
+			found.DefaultCapacity = true
+		}
+		if err != nil {
+			return nil, err
+		}
+		found.CapacityPerDay = perDay
+		for _, day := range days {
+			found.TotalCapacity += day.Hours
+		}
+		developers[key] = found
+		return found, nil
+	}
+
+	for key, capacity := range input.Capacities {
+		if _, err := developer(key, capacity.Name, capacity.Email); err != nil {
+			return nil, err
+		}
+	}
+	for _, plan := range plans {
+		assignee := plan.Story.AssignedTo
+		if assignee == nil {
+			continue
+		}
+		email, _ := identityEmail(assignee.UniqueName, assignee.Descriptor)
+		found, err := developer(identityKey(assignee.UniqueName, assignee.Descriptor, assignee.DisplayName), assignee.DisplayName, email)
+		if err != nil {
+			return nil, err
+		}
+		found.Stories++
+		if plan.AtRisk {
+			found.AtRisk++
+		}
+		if hours, ok := input.TaskWork[plan.Story.ID]; ok {
+			found.AllocatedHours += hours
+		} else if plan.Story.StoryPoints != nil && !input.Days {
+			found.AllocatedHours += *plan.Story.StoryPoints * input.HoursPerStoryPoint
+		}
+	}
+
+	result := make([]SimulatedDeveloper, 0, len(developers))
+	for _, found := range developers {
+		if found.TotalCapacity > 0 {
+			utilization := found.AllocatedHours / found.TotalCapacity
+			found.Utilization = &utilization
+		}
+		result = append(result, *found)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].Email < result[j].Email
+	})
+	return result, nil
+}
+
+// Função para montar a resposta da simulação a partir do relatório do dry-run
+func simulationReport(report GenerationReport, adjustments []capacityAdjustment, developers []SimulatedDeveloper) SimulationReport {
+	requests := make([]CapacityAdjustment, 0, len(adjustments))
+	for _, adjustment := range adjustments {
+		requests = append(requests, adjustment.CapacityAdjustment)
+	}
+	return SimulationReport{GenerationReport: report, Adjustments: requests, Developers: developers}
+}
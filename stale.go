@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Máximo de IDs de User Stories novas citados no aviso de plano desatualizado
+const maxStaleItemsInWarning = 10
+
+// Situação do plano de datas da sprint frente à última geração que gravou
+// datas, em /sprint-summary e /at-risk
+type PlanFreshness struct {
+	LastGeneratedAt *time.Time `json:"lastGeneratedAt"`
+	LastRunID       string     `json:"lastRunId,omitempty"`
+	// Dias úteis do dia da última geração até hoje, no calendário do time
+	PlanAgeWorkingDays *float64 `json:"planAgeWorkingDays"`
+	PlanStale          bool     `json:"planStale"`
+	// User Stories abertas que a última geração não cobriu
+	NewItems []int `json:"newItems,omitempty"`
+}
+
+// Função para comparar as User Stories abertas da sprint com as cobertas pela
+// última geração e calcular a idade do plano. O plano fica desatualizado com
+// User Stories novas ou com mais de staleAfter dias úteis (zero desliga a
+// regra da idade). Devolve também o aviso, vazio quando o plano está em dia.
+func planFreshness(runs *runStore, team, sprintID string, open []WorkItem, now time.Time, cal workCalendar, staleAfter int) (PlanFreshness, string, error) {
+	var freshness PlanFreshness
+	run, found := runs.lastGeneration(team, sprintID)
+	if !found {
+		for _, story := range open {
+			freshness.NewItems = append(freshness.NewItems, story.ID)
+		}
+		if len(open) == 0 {
+			return freshness, "", nil
+		}
+		freshness.PlanStale = true
+		return freshness, fmt.Sprintf("Plano de datas desatualizado: nenhuma geração registrada para a sprint e %d User Stories abertas", len(open)), nil
+	}
+
+	freshness.LastGeneratedAt, freshness.LastRunID = &run.CreatedAt, run.ID
+	age, err := elapsedWorkingDays(run.CreatedAt, now, cal)
+	if err != nil {
+		return freshness, "", err
+	}
+	freshness.PlanAgeWorkingDays = &age
+
+	covered := make(map[int]bool)
+	if run.Report != nil {
+		for _, item := range run.Report.Items {
+			covered[item.ID] = true
+		}
+	}
+	for _, item := range run.Items {
+		covered[item.ID] = true
+	}
+	for _, story := range open {
+		if !covered[story.ID] {
+			freshness.NewItems = append(freshness.NewItems, story.ID)
+		}
+	}
+
+	var reasons []string
+	if len(freshness.NewItems) > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d User Stories abertas fora da última geração (%s)", len(freshness.NewItems), formatItemIds(freshness.NewItems, maxStaleItemsInWarning)))
+	}
+	if staleAfter > 0 && age > float64(staleAfter) {
+		reasons = append(reasons, fmt.Sprintf("última geração há %g dias úteis (limite PLAN_STALE_AFTER_DAYS=%d)", age, staleAfter))
+	}
+	if len(reasons) == 0 {
+		return freshness, "", nil
+	}
+	freshness.PlanStale = true
+	return freshness, "Plano de datas desatualizado: " + strings.Join(reasons, "; "), nil
+}
+
+// Função para listar IDs como "#1, #2", citando no máximo limit
+func formatItemIds(ids []int, limit int) string {
+	var parts []string
+	for i, id := range ids {
+		if i == limit {
+			parts = append(parts, fmt.Sprintf("e mais %d", len(ids)-limit))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("#%d", id))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// stalePlanReminder verifica periodicamente o plano da sprint atual e publica
+// o resumo (digest.due) e, quando ele está desatualizado, o aviso
+// (plan.stale). Usa uma listagem nova dos itens da sprint por verificação e
+// o registro de execuções. Cada time configurado é verificado separadamente.
+type stalePlanReminder struct {
+	pool   *adoPool
+	teams  *teamConfigs
+	runs   *runStore
+	events *notifierSet
+}
+
+// Função para verificar a sprint atual do time uma vez, publicando o resumo
+// e, com o plano desatualizado, o aviso. Devolve se o plano está desatualizado.
+func (r *stalePlanReminder) check(ctx context.Context, cfg *Config) (bool, error) {
+	workClient, err := r.pool.Work(ctx, "stale-plan")
+	if err != nil {
+		return false, wrapAdoError(err, "work.NewClient", "")
+	}
+	iteration, err := resolveRelativeSprint(ctx, workClient, cfg, sprintCurrent)
+	if err != nil {
+		return false, err
+	}
+	witClient, err := r.pool.WorkItems(ctx, "stale-plan")
+	if err != nil {
+		return false, wrapAdoError(err, "workitemtracking.NewClient", "")
+	}
+	stories, _, _, err := fetchSprintStories(ctx, workClient, witClient, cfg, iteration, cfg.WorkItemTypes, nil)
+	if err != nil {
+		return false, err
+	}
+	var open []WorkItem
+	withoutDueDate := 0
+	for _, story := range stories {
+		if isOpenStory(story) {
+			open = append(open, story)
+			if story.DueDate == nil {
+				withoutDueDate++
+			}
+		}
+	}
+	freshness, warning, err := planFreshness(r.runs, cfg.Team, iteration.Id.String(), open, time.Now(), cfg.calendar(), cfg.PlanStaleAfterDays)
+	if err != nil {
+		return false, err
+	}
+
+	sprint := fmt.Sprintf("Sprint '%s' (%s)", *iteration.Name, cfg.TeamName)
+	digest := fmt.Sprintf("%s: %d User Stories abertas, %d sem data", sprint, len(open), withoutDueDate)
+	if freshness.PlanAgeWorkingDays != nil {
+		digest += fmt.Sprintf("; última geração há %g dias úteis (execução %s)", *freshness.PlanAgeWorkingDays, freshness.LastRunID)
+	} else {
+		digest += "; nenhuma geração registrada"
+	}
+	event := Event{Sprint: *iteration.Name, SprintID: iteration.Id.String(), RunID: freshness.LastRunID}
+	digestEvent := event
+	digestEvent.Type, digestEvent.Text = EventDigestDue, digest
+	r.events.publish(digestEvent)
+
+	if warning == "" {
+		log.Printf("[DEBUG] Plano de datas da sprint '%s' em dia", *iteration.Name)
+		return false, nil
+	}
+	staleEvent := event
+	staleEvent.Type, staleEvent.Items = EventPlanStale, freshness.NewItems
+	staleEvent.Text = fmt.Sprintf("%s: %s. Gere as datas de novo com POST /generate-due-dates.", sprint, warning)
+	r.events.publish(staleEvent)
+	log.Printf("[DEBUG] Aviso de plano desatualizado publicado para a sprint '%s'", *iteration.Name)
+	return true, nil
+}
+
+// Função para verificar todos os times a cada interval; roda até o
+// processo terminar. A falha de um time não impede a verificação dos outros.
+func (r *stalePlanReminder) run(interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, cfg := range r.teams.all() {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			if _, err := r.check(ctx, cfg); err != nil {
+				log.Printf("[WARN] Verificação do plano desatualizado do time '%s' falhou: %v", cfg.TeamName, err)
+			}
+			cancel()
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+// Função para registrar uma geração que cobriu os itens informados
+func addGenerationRun(store *runStore, id, sprintID string, createdAt time.Time, dryRun bool, ids ...int) {
+	report := &GenerationReport{RunID: id}
+	for _, itemID := range ids {
+		report.Items = append(report.Items, GenerationItem{ID: itemID})
+	}
+	store.add(&DueDateRun{ID: id, Source: runSourceGenerate, CreatedAt: createdAt, SprintID: sprintID, DryRun: dryRun, Items: []DueDateRunItem{}, Report: report})
+}
+
+func TestPlanFreshness(t *testing.T) {
+	// Sexta-feira; a geração de segunda tem 4 dias úteis
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	monday := time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC)
+	open := []WorkItem{{ID: 1}, {ID: 2}}
+
+	tests := []struct {
+		name       string
+		setup      func(store *runStore)
+		open       []WorkItem
+		staleAfter int
+		wantStale  bool
+		wantAge    *float64
+		wantNew    []int
+	}{
+		{name: "sem geração e sem User Stories abertas", open: nil, staleAfter: 5},
+		{name: "sem geração com User Stories abertas", open: open, staleAfter: 5, wantStale: true, wantNew: []int{1, 2}},
+		{
+			name:       "geração recente cobrindo tudo",
+			setup:      func(store *runStore) { addGenerationRun(store, "run", "sprint", monday, false, 1, 2) },
+			open:       open,
+			staleAfter: 5,
+			wantAge:    ptrFloat(4),
+		},
+		{
+			name:       "User Story nova depois da geração",
+			setup:      func(store *runStore) { addGenerationRun(store, "run", "sprint", monday, false, 1) },
+			open:       open,
+			staleAfter: 5,
+			wantStale:  true,
+			wantAge:    ptrFloat(4),
+			wantNew:    []int{2},
+		},
+		{
+			name:       "geração mais velha que o limite",
+			setup:      func(store *runStore) { addGenerationRun(store, "run", "sprint", monday, false, 1, 2) },
+			open:       open,
+			staleAfter: 3,
+			wantStale:  true,
+			wantAge:    ptrFloat(4),
+		},
+		{
+			name:       "limite zero considera só as User Stories novas",
+			setup:      func(store *runStore) { addGenerationRun(store, "run", "sprint", monday.AddDate(0, -1, 0), false, 1, 2) },
+			open:       open,
+			staleAfter: 0,
+			wantAge:    ptrFloat(25),
+		},
+		{
+			name: "dry-run, rollback e outra sprint não contam",
+			setup: func(store *runStore) {
+				addGenerationRun(store, "old", "sprint", monday, false, 1)
+				addGenerationRun(store, "dry", "sprint", now.Add(-time.Hour), true, 1, 2)
+				addGenerationRun(store, "other", "outra", now.Add(-time.Hour), false, 1, 2)
+				addGenerationRun(store, "undone", "sprint", now.Add(-2*time.Hour), false, 1, 2)
+				store.runs["undone"].RolledBackAt = &now
+			},
+			open:       open,
+			staleAfter: 5,
+			wantStale:  true,
+			wantAge:    ptrFloat(4),
+			wantNew:    []int{2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := newRunStore("", runRetention{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.setup != nil {
+				tt.setup(store)
+			}
+			got, warning, err := planFreshness(store, "Time", "sprint", tt.open, now, workCalendar{}, tt.staleAfter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.PlanStale != tt.wantStale || (warning != "") != tt.wantStale {
+				t.Errorf("planStale = %t, aviso %q; quer %t", got.PlanStale, warning, tt.wantStale)
+			}
+			if !reflect.DeepEqual(got.PlanAgeWorkingDays, tt.wantAge) {
+				t.Errorf("planAgeWorkingDays = %v, quer %v", formatFloatPtr(got.PlanAgeWorkingDays), formatFloatPtr(tt.wantAge))
+			}
+			if !reflect.DeepEqual(got.NewItems, tt.wantNew) {
+				t.Errorf("newItems = %v, quer %v", got.NewItems, tt.wantNew)
+			}
+			if (got.LastGeneratedAt != nil) != (tt.wantAge != nil) {
+				t.Errorf("lastGeneratedAt = %v", got.LastGeneratedAt)
+			}
+		})
+	}
+}
+
+// Função para obter o ponteiro de um número nas tabelas de teste
+func ptrFloat(value float64) *float64 {
+	return &value
+}
+
+// Função para mostrar um *float64 pelo valor nas mensagens de erro
+func formatFloatPtr(value *float64) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+func TestStalePlanReminderPublishesEvents(t *testing.T) {
+	today := sprintDate(time.Now())
+	iteration := fakeIteration("Sprint 7", today.AddDate(0, 0, -3), today.AddDate(0, 0, 10), work.TimeFrameValues.Current)
+	workClient := &fakeWorkClient{
+		iterations: []work.TeamSettingsIteration{iteration},
+		relations: map[uuid.UUID][]workitemtracking.WorkItemLink{
+			*iteration.Id: {fakeLink(0, 10), fakeLink(0, 11), fakeLink(0, 12)},
+		},
+	}
+	witClient := newFakeWitClient(fakeStory(10, "Login", "Active"), fakeStory(11, "Logout", "Closed"), fakeStory(12, "Perfil", "New"))
+
+	store, err := newRunStore("", runRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &recordingNotifier{}
+	cfg := &Config{Project: "Projeto", Team: "Time", TeamName: "Time", WorkItemTypes: []string{"User Story"}, PlanStaleAfterDays: 5}
+	reminder := &stalePlanReminder{pool: newFakePool(workClient, witClient), runs: store, events: &notifierSet{notifiers: []Notifier{recorder}}}
+
+	// A 12 entrou na sprint depois da geração; a 11 fechada não conta
+	addGenerationRun(store, "run", iteration.Id.String(), time.Now(), false, 10)
+	stale, err := reminder.check(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reminder.events.wait()
+	events := recorder.received()
+	if !stale || len(events) != 2 {
+		t.Fatalf("desatualizado = %t, eventos = %+v; quer resumo e aviso", stale, events)
+	}
+	byType := map[EventType]Event{events[0].Type: events[0], events[1].Type: events[1]}
+	if digest, ok := byType[EventDigestDue]; !ok || !strings.Contains(digest.Text, "2 User Stories abertas") || digest.RunID != "run" {
+		t.Errorf("resumo = %+v", digest)
+	}
+	if warning, ok := byType[EventPlanStale]; !ok || !reflect.DeepEqual(warning.Items, []int{12}) || !strings.Contains(warning.Text, "#12") || strings.Contains(warning.Text, "#11") {
+		t.Errorf("aviso = %+v, quer um aviso citando só a #12", warning)
+	}
+
+	// Com a geração cobrindo as abertas só o resumo é publicado
+	addGenerationRun(store, "run-2", iteration.Id.String(), time.Now(), false, 10, 12)
+	stale, err = reminder.check(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reminder.events.wait()
+	events = recorder.received()
+	if stale || len(events) != 3 || events[2].Type != EventDigestDue {
+		t.Errorf("desatualizado = %t, eventos = %+v; quer só mais um resumo", stale, events)
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+)
+
+// Sprints avaliadas em paralelo por GET /stats/annual; cada uma ainda passa
+// pelo limite de ADO_MAX_CONCURRENCY
+const annualStatsConcurrency = 3
+
+// Validade das sprints encerradas em cache: depois do fim, as User Stories
+// fechadas e a capacidade quase não mudam
+const annualStatsCacheTTL = 6 * time.Hour
+
+// Sprint do ano em GET /stats/annual. Valores sem dados ficam null, com o
+// motivo em Missing (pelo nome do campo), para não entrarem nas médias.
+type AnnualSprintStats struct {
+	Sprint      string    `json:"sprint"`
+	SprintID    string    `json:"sprintId"`
+	SprintStart time.Time `json:"sprintStart"`
+	SprintEnd   time.Time `json:"sprintEnd"`
+	// User Stories fechadas e horas entregues, como em GET /tuning
+	Delivered      *int     `json:"delivered"`
+	HoursCompleted *float64 `json:"hoursCompleted"`
+	// Capacidade bruta e horas entregues sobre ela, em percentual
+	CapacityHours      *float64 `json:"capacityHours"`
+	UtilizationPercent *float64 `json:"utilizationPercent"`
+	// No prazo pela data atual e pela data gravada pela geração
+	OnTimePercent          *float64 `json:"onTimePercent"`
+	GeneratedOnTimePercent *float64 `json:"generatedOnTimePercent"`
+	// Gerações com escrita registradas em /runs para a sprint
+	Generations int               `json:"generations"`
+	Cached      bool              `json:"cached,omitempty"`
+	Missing     map[string]string `json:"missing,omitempty"`
+}
+
+// Totais do ano. As médias usam só as sprints com o dado; o percentual no
+// prazo é ponderado pelas User Stories comparadas.
+type AnnualStatsTotals struct {
+	Sprints                   int      `json:"sprints"`
+	Evaluated                 int      `json:"evaluated"`
+	Delivered                 int      `json:"delivered"`
+	HoursCompleted            float64  `json:"hoursCompleted"`
+	CapacityHours             float64  `json:"capacityHours"`
+	AverageUtilizationPercent *float64 `json:"averageUtilizationPercent"`
+	UtilizationSprints        int      `json:"utilizationSprints"`
+	OnTime                    int      `json:"onTime"`
+	Compared                  int      `json:"compared"`
+	OnTimePercent             *float64 `json:"onTimePercent"`
+	GeneratedOnTime           int      `json:"generatedOnTime"`
+	GeneratedCompared         int      `json:"generatedCompared"`
+	GeneratedOnTimePercent    *float64 `json:"generatedOnTimePercent"`
+	Generations               int      `json:"generations"`
+}
+
+// Resposta de GET /stats/annual
+type AnnualStatsReport struct {
+	Year     int                 `json:"year"`
+	Team     string              `json:"team"`
+	Sprints  []AnnualSprintStats `json:"sprints"`
+	Totals   AnnualStatsTotals   `json:"totals"`
+	Warnings []string            `json:"warnings"`
+}
+
+// Sprint encerrada avaliada, guardada em cache
+type annualStatsEntry struct {
+	row       TuningSprint
+	warnings  []string
+	fetchedAt time.Time
+}
+
+// Cache das sprints encerradas de GET /stats/annual, por time, sprint e
+// parâmetros que mudam o cálculo
+type annualStatsCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]annualStatsEntry
+}
+
+func newAnnualStatsCache(ttl time.Duration) *annualStatsCache {
+	return &annualStatsCache{ttl: ttl, entries: make(map[string]annualStatsEntry)}
+}
+
+func (c *annualStatsCache) get(key string) (annualStatsEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Since(entry.fetchedAt) >= c.ttl {
+		return annualStatsEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *annualStatsCache) put(key string, entry annualStatsEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry
+}
+
+// Função para listar as sprints do time que terminam no ano, em ordem
+// cronológica; sprints sem datas ficam de fora
+func sprintsOfYear(ctx context.Context, workClient work.Client, cfg *Config, year int) ([]work.TeamSettingsIteration, error) {
+	result, err := workClient.GetTeamIterations(ctx, work.GetTeamIterationsArgs{
+		Project: &cfg.Project,
+		Team:    &cfg.Team,
+	})
+	if err != nil {
+		return nil, wrapAdoError(err, "GetTeamIterations", "team=%s", cfg.TeamName)
+	}
+	var sprints []work.TeamSettingsIteration
+	if result != nil {
+		for _, iteration := range *result {
+			if iteration.Name == nil || iteration.Id == nil {
+				continue
+			}
+			if _, end, err := sprintDates(&iteration); err == nil && end.Year() == year {
+				sprints = append(sprints, iteration)
+			}
+		}
+	}
+	sort.SliceStable(sprints, func(i, j int) bool {
+		return sprints[i].Attributes.FinishDate.Time.Before(sprints[j].Attributes.FinishDate.Time)
+	})
+	return sprints, nil
+}
+
+// Função para arredondar um percentual a uma casa, como em /metrics/due-date-accuracy
+func roundPercent(part, total float64) float64 {
+	return math.Round(part/total*1000) / 10
+}
+
+// Função para montar a linha de uma sprint encerrada a partir da avaliação de
+// GET /tuning, marcando em Missing os valores que faltam
+func annualSprintRow(evaluated TuningSprint, generations int) AnnualSprintStats {
+	row := AnnualSprintStats{
+		Sprint:         evaluated.Sprint,
+		SprintID:       evaluated.SprintID,
+		SprintStart:    evaluated.SprintStart,
+		SprintEnd:      evaluated.SprintEnd,
+		Delivered:      &evaluated.Closed,
+		HoursCompleted: &evaluated.ThroughputHours,
+		OnTimePercent:  evaluated.OnTimePercent,
+		Generations:    generations,
+		Missing:        map[string]string{},
+	}
+	if evaluated.RawCapacityHours > 0 {
+		utilization := roundPercent(evaluated.ThroughputHours, evaluated.RawCapacityHours)
+		row.CapacityHours, row.UtilizationPercent = &evaluated.RawCapacityHours, &utilization
+	} else {
+		row.Missing["capacityHours"] = "sem capacidade configurada na sprint"
+		row.Missing["utilizationPercent"] = "sem capacidade configurada na sprint"
+	}
+	if row.OnTimePercent == nil {
+		row.Missing["onTimePercent"] = "nenhuma User Story fechada com data para comparar"
+	}
+	switch {
+	case evaluated.generatedAccuracy != nil:
+		row.GeneratedOnTimePercent = &evaluated.generatedAccuracy.OnTimePercent
+	case generations == 0:
+		row.Missing["generatedOnTimePercent"] = "nenhuma geração de datas gravada na sprint"
+	default:
+		row.Missing["generatedOnTimePercent"] = "nenhuma User Story fechada com data gerada"
+	}
+	return row
+}
+
+// Função para linha de uma sprint sem avaliação (ainda não encerrada ou com
+// erro), com todos os valores null pelo mesmo motivo
+func annualSprintPlaceholder(iteration work.TeamSettingsIteration, generations int, reason string) AnnualSprintStats {
+	start, end, _ := sprintDates(&iteration)
+	row := AnnualSprintStats{Sprint: *iteration.Name, SprintID: iteration.Id.String(), SprintStart: start, SprintEnd: end, Generations: generations, Missing: map[string]string{}}
+	for _, field := range []string{"delivered", "hoursCompleted", "capacityHours", "utilizationPercent", "onTimePercent", "generatedOnTimePercent"} {
+		row.Missing[field] = reason
+	}
+	return row
+}
+
+// Função para somar os totais do ano a partir das linhas e das avaliações
+// (nil nas sprints não avaliadas)
+func annualTotals(rows []AnnualSprintStats, evaluated []*TuningSprint) AnnualStatsTotals {
+	totals := AnnualStatsTotals{Sprints: len(rows)}
+	utilization := 0.0
+	for i, row := range rows {
+		totals.Generations += row.Generations
+		if evaluated[i] == nil {
+			continue
+		}
+		totals.Evaluated++
+		totals.Delivered += evaluated[i].Closed
+		totals.HoursCompleted += evaluated[i].ThroughputHours
+		if row.UtilizationPercent != nil {
+			totals.CapacityHours += evaluated[i].RawCapacityHours
+			totals.UtilizationSprints++
+			utilization += *row.UtilizationPercent
+		}
+		if stats := evaluated[i].accuracy; stats != nil {
+			totals.OnTime, totals.Compared = totals.OnTime+stats.OnTime, totals.Compared+stats.Count
+		}
+		if stats := evaluated[i].generatedAccuracy; stats != nil {
+			totals.GeneratedOnTime, totals.GeneratedCompared = totals.GeneratedOnTime+stats.OnTime, totals.GeneratedCompared+stats.Count
+		}
+	}
+	if totals.UtilizationSprints > 0 {
+		average := math.Round(utilization/float64(totals.UtilizationSprints)*10) / 10
+		totals.AverageUtilizationPercent = &average
+	}
+	if totals.Compared > 0 {
+		percent := roundPercent(float64(totals.OnTime), float64(totals.Compared))
+		totals.OnTimePercent = &percent
+	}
+	if totals.GeneratedCompared > 0 {
+		percent := roundPercent(float64(totals.GeneratedOnTime), float64(totals.GeneratedCompared))
+		totals.GeneratedOnTimePercent = &percent
+	}
+	return totals
+}
+
+// GET /stats/annual?year=: entregas, horas, utilização e percentual no prazo
+// de cada sprint do ano (pela data de fim) e os totais. As sprints encerradas
+// são avaliadas como em GET /tuning, algumas em paralelo, e ficam em cache;
+// ?refresh=true recalcula.
+func handleAnnualStats(pool *adoPool, cfg *Config, runs *runStore, cache *annualStatsCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cal, err := calendarForRequest(cfg, r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		today := cal.dateOf(time.Now())
+		year := today.Year()
+		if value := r.URL.Query().Get("year"); value != "" {
+			year, err = strconv.Atoi(value)
+			if err != nil || year < 2000 || year > today.Year()+1 {
+				jsonError(w, fmt.Sprintf("Parâmetro 'year' inválido: %q (use um ano entre 2000 e %d)", value, today.Year()+1), http.StatusBadRequest)
+				return
+			}
+		}
+		keepArea, err := areaPathFilterFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		types, _ := typesFromRequest(cfg, r)
+		refresh := r.URL.Query().Get("refresh") == "true"
+		// Parâmetros que mudam o cálculo fazem parte da chave do cache
+		query := r.URL.Query()
+		for _, name := range []string{"year", "refresh"} {
+			query.Del(name)
+		}
+		cacheScope := cfg.Team + "?" + query.Encode()
+
+		ctx := r.Context()
+		workClient, err := pool.Work(ctx, "stats")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
+			return
+		}
+		witClient, err := pool.WorkItems(ctx, "stats")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+		iterations, err := sprintsOfYear(ctx, workClient, cfg, year)
+		if err != nil {
+			respondError(w, "Erro ao buscar sprints", err)
+			return
+		}
+
+		rows := make([]AnnualSprintStats, len(iterations))
+		evaluated := make([]*TuningSprint, len(iterations))
+		warnings := make([][]string, len(iterations))
+		sem := make(chan struct{}, annualStatsConcurrency)
+		var wg sync.WaitGroup
+		for i := range iterations {
+			iteration := &iterations[i]
+			generations := 0
+			for _, run := range runs.list(cfg.Team, iteration.Id.String(), runSourceGenerate) {
+				if !run.DryRun {
+					generations++
+				}
+			}
+			_, end, _ := sprintDates(iteration)
+			if !end.Before(today) {
+				rows[i] = annualSprintPlaceholder(*iteration, generations, fmt.Sprintf("sprint termina em %s; entra nos totais depois de encerrada", end.Format("2006-01-02")))
+				continue
+			}
+			key := cacheScope + "/" + iteration.Id.String()
+			if entry, ok := cache.get(key); ok && !refresh {
+				row := entry.row
+				rows[i], evaluated[i], warnings[i] = annualSprintRow(row, generations), &row, entry.warnings
+				rows[i].Cached = true
+				continue
+			}
+			wg.Add(1)
+			go func(i, generations int) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				row, rowWarnings, err := evaluateTuningSprint(ctx, workClient, witClient, cfg, runs, iteration, types, keepArea, cal)
+				if err != nil {
+					log.Printf("[WARN] Erro ao avaliar a sprint '%s' nas estatísticas de %d: %v", *iteration.Name, year, err)
+					rows[i] = annualSprintPlaceholder(*iteration, generations, "erro ao buscar os dados da sprint no Azure DevOps")
+					warnings[i] = []string{fmt.Sprintf("Sprint '%s' sem dados: %v", *iteration.Name, err)}
+					return
+				}
+				cache.put(key, annualStatsEntry{row: row, warnings: rowWarnings, fetchedAt: time.Now()})
+				rows[i], evaluated[i], warnings[i] = annualSprintRow(row, generations), &row, rowWarnings
+			}(i, generations)
+		}
+		wg.Wait()
+
+		report := AnnualStatsReport{Year: year, Team: cfg.TeamName, Sprints: rows, Totals: annualTotals(rows, evaluated), Warnings: []string{}}
+		for i, row := range rows {
+			report.Warnings = append(report.Warnings, warnings[i]...)
+			if len(row.Missing) == 0 {
+				rows[i].Missing = nil
+			}
+		}
+		if len(iterations) == 0 {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("Nenhuma sprint do time '%s' termina em %d", cfg.TeamName, year))
+		}
+		log.Printf("[DEBUG] Estatísticas de %d do time '%s': %d sprints, %d avaliadas, %d User Stories entregues", year, cfg.TeamName, report.Totals.Sprints, report.Totals.Evaluated, report.Totals.Delivered)
+		writeJSON(w, http.StatusOK, report)
+	}
+}
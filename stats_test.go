@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/webapi"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+func TestAnnualStats(t *testing.T) {
+	// Duas sprints encerradas em 2025 e uma de 2024, que fica de fora
+	first := fakeIteration("Sprint 5", day(2025, 3, 3), day(2025, 3, 14), work.TimeFrameValues.Past)
+	second := fakeIteration("Sprint 6", day(2025, 3, 17), day(2025, 3, 28), work.TimeFrameValues.Past)
+	previous := fakeIteration("Sprint 1", day(2024, 12, 2), day(2024, 12, 13), work.TimeFrameValues.Past)
+	perDay := float32(6)
+	development, anaRef, anaName := "Development", "ana@example.com", "Ana Souza"
+	workClient := &fakeWorkClient{
+		iterations: []work.TeamSettingsIteration{second, previous, first},
+		relations: map[uuid.UUID][]workitemtracking.WorkItemLink{
+			*first.Id:  {fakeLink(0, 1), fakeLink(0, 2)},
+			*second.Id: {fakeLink(0, 3)},
+		},
+		// Só a primeira sprint tem capacidade: 6h × 10 dias úteis
+		capacities: map[uuid.UUID]*work.TeamCapacity{
+			*first.Id: {TeamMembers: &[]work.TeamMemberCapacityIdentityRef{{
+				TeamMember: &webapi.IdentityRef{DisplayName: &anaName, UniqueName: &anaRef},
+				Activities: &[]work.Activity{{CapacityPerDay: &perDay, Name: &development}},
+			}}},
+		},
+	}
+	closedStory := func(id int, points float64, due, closed time.Time) workitemtracking.WorkItem {
+		item := fakeStory(id, "História", "Closed")
+		(*item.Fields)["Microsoft.VSTS.Scheduling.StoryPoints"] = points
+		(*item.Fields)[dueDateField] = due.Format(time.RFC3339)
+		(*item.Fields)["Microsoft.VSTS.Common.ClosedDate"] = closed.Format(time.RFC3339)
+		return item
+	}
+	witClient := newFakeWitClient(
+		closedStory(1, 2, day(2025, 3, 13), day(2025, 3, 12)),
+		closedStory(2, 1, day(2025, 3, 14), day(2025, 3, 18)),
+		closedStory(3, 1, day(2025, 3, 21), day(2025, 3, 20)),
+	)
+	cfg := &Config{Project: "Projeto", Team: "Time", TeamName: "Time", WorkItemTypes: []string{"User Story"}, ChildWorkItemTypes: []string{"Task"},
+		DoneStates: []string{"Closed"}, DefaultCapacityPerDay: 8, HoursPerStoryPoint: 8, FocusFactor: 1, Location: time.UTC}
+	store, err := newRunStore("", runRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Uma geração gravada e um dry-run na primeira sprint; o dry-run não conta
+	generated := day(2025, 3, 13)
+	store.add(&DueDateRun{ID: "gravada", Source: runSourceGenerate, CreatedAt: day(2025, 3, 3), SprintID: first.Id.String(), Team: "Time",
+		Items: []DueDateRunItem{{ID: 1, NewDueDate: &generated}}})
+	addGenerationRun(store, "dry-run", first.Id.String(), day(2025, 3, 4), true, 1)
+
+	handler := handleAnnualStats(newFakePool(workClient, witClient), cfg, store, newAnnualStatsCache(time.Hour))
+	get := func(query string) (int, AnnualStatsReport) {
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest(http.MethodGet, "/stats/annual"+query, nil))
+		var report AnnualStatsReport
+		if recorder.Code == http.StatusOK {
+			if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return recorder.Code, report
+	}
+
+	code, report := get("?year=2025")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, quer 200", code)
+	}
+	if len(report.Sprints) != 2 || report.Sprints[0].Sprint != "Sprint 5" || report.Sprints[1].Sprint != "Sprint 6" {
+		t.Fatalf("sprints = %+v, quer Sprint 5 e Sprint 6 em ordem", report.Sprints)
+	}
+	sprint5, sprint6 := report.Sprints[0], report.Sprints[1]
+	// Vazão pelos Story Points: (2 + 1) × 8h sobre 60h de capacidade
+	if *sprint5.Delivered != 2 || *sprint5.HoursCompleted != 24 || *sprint5.CapacityHours != 60 || *sprint5.UtilizationPercent != 40 {
+		t.Errorf("Sprint 5 = %d entregues, %gh de %gh (%g%%), quer 2, 24h de 60h (40%%)", *sprint5.Delivered, *sprint5.HoursCompleted, *sprint5.CapacityHours, *sprint5.UtilizationPercent)
+	}
+	if *sprint5.OnTimePercent != 50 || sprint5.GeneratedOnTimePercent == nil || *sprint5.GeneratedOnTimePercent != 100 || sprint5.Generations != 1 || sprint5.Missing != nil {
+		t.Errorf("Sprint 5 = no prazo %v, gerada %v, %d gerações, faltando %v", formatFloatPtr(sprint5.OnTimePercent), formatFloatPtr(sprint5.GeneratedOnTimePercent), sprint5.Generations, sprint5.Missing)
+	}
+	if sprint6.CapacityHours != nil || sprint6.UtilizationPercent != nil || sprint6.GeneratedOnTimePercent != nil || sprint6.Generations != 0 {
+		t.Errorf("Sprint 6 = capacidade %v, utilização %v, gerada %v, quer null sem capacidade e sem geração",
+			formatFloatPtr(sprint6.CapacityHours), formatFloatPtr(sprint6.UtilizationPercent), formatFloatPtr(sprint6.GeneratedOnTimePercent))
+	}
+	wantMissing := []string{"capacityHours", "generatedOnTimePercent", "utilizationPercent"}
+	var gotMissing []string
+	for field := range sprint6.Missing {
+		gotMissing = append(gotMissing, field)
+	}
+	if len(gotMissing) != len(wantMissing) || sprint6.Missing["generatedOnTimePercent"] != "nenhuma geração de datas gravada na sprint" {
+		t.Errorf("Sprint 6 faltando = %v, quer %v", sprint6.Missing, wantMissing)
+	}
+
+	// A média de utilização ignora a sprint sem capacidade; o no prazo é
+	// ponderado pelas User Stories (2 de 3)
+	totals := report.Totals
+	want := AnnualStatsTotals{Sprints: 2, Evaluated: 2, Delivered: 3, HoursCompleted: 32, CapacityHours: 60, AverageUtilizationPercent: ptrFloat(40), UtilizationSprints: 1,
+		OnTime: 2, Compared: 3, OnTimePercent: ptrFloat(66.7), GeneratedOnTime: 1, GeneratedCompared: 1, GeneratedOnTimePercent: ptrFloat(100), Generations: 1}
+	if !reflect.DeepEqual(totals, want) {
+		t.Errorf("totais = %+v, quer %+v", totals, want)
+	}
+
+	// A segunda consulta vem do cache, sem chamar o Azure DevOps de novo
+	calls := len(workClient.calls)
+	if _, again := get("?year=2025"); !again.Sprints[0].Cached || !reflect.DeepEqual(again.Totals, want) || len(workClient.calls) != calls+1 {
+		t.Errorf("segunda consulta: cached %t, %d chamadas novas, quer só GetTeamIterations", again.Sprints[0].Cached, len(workClient.calls)-calls)
+	}
+	if _, refreshed := get("?year=2025&refresh=true"); refreshed.Sprints[0].Cached {
+		t.Error("refresh=true deveria recalcular as sprints")
+	}
+
+	// Ano sem sprints responde vazio, com aviso
+	if code, empty := get("?year=2023"); code != http.StatusOK || len(empty.Sprints) != 0 || empty.Totals.Sprints != 0 || len(empty.Warnings) != 1 {
+		t.Errorf("2023: status %d, %+v", code, empty)
+	}
+	for _, year := range []string{"abc", "1999", strconv.Itoa(time.Now().Year() + 2)} {
+		if code, _ := get("?year=" + year); code != http.StatusBadRequest {
+			t.Errorf("year=%s: status = %d, quer 400", year, code)
+		}
+	}
+}
+
+func TestAnnualStatsUnfinishedSprint(t *testing.T) {
+	year := time.Now().Year() + 1
+	iteration := fakeIteration("Sprint 9", day(year, 6, 2), day(year, 6, 13), work.TimeFrameValues.Future)
+	workClient := &fakeWorkClient{iterations: []work.TeamSettingsIteration{iteration}}
+	cfg := &Config{Project: "Projeto", Team: "Time", TeamName: "Time", DoneStates: []string{"Closed"}, Location: time.UTC}
+	store, err := newRunStore("", runRetention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := handleAnnualStats(newFakePool(workClient, newFakeWitClient()), cfg, store, newAnnualStatsCache(time.Hour))
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodGet, "/stats/annual?year="+strconv.Itoa(year), nil))
+	var report AnnualStatsReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil || recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d (%v)", recorder.Code, err)
+	}
+	if len(report.Sprints) != 1 || report.Sprints[0].Delivered != nil || len(report.Sprints[0].Missing) != 6 || report.Totals.Evaluated != 0 || report.Totals.OnTimePercent != nil {
+		t.Errorf("sprint não encerrada = %+v, totais %+v; quer valores null com motivo", report.Sprints, report.Totals)
+	}
+}
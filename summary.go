@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Resposta de GET /sprint-summary: contagens das User Stories da sprint (fora
+// as removidas), a capacidade do time, calculada como em /developers, e a
+// situação do plano de datas frente à última geração
+type SprintSummary struct {
+	Sprint               string         `json:"sprint"`
+	SprintStart          *time.Time     `json:"sprintStart"`
+	SprintEnd            *time.Time     `json:"sprintEnd"`
+	Stories              int            `json:"stories"`
+	ByState              map[string]int `json:"byState"`
+	StoryPoints          float64        `json:"storyPoints"`
+	CompletedStoryPoints float64        `json:"completedStoryPoints"`
+	WithDueDate          int            `json:"withDueDate"`
+	WithoutDueDate       int            `json:"withoutDueDate"`
+	Assigned             int            `json:"assigned"`
+	Unassigned           int            `json:"unassigned"`
+	// User Stories com pelo menos uma task aberta com RemainingWork
+	WithEstimatedTasks    int     `json:"withEstimatedTasks"`
+	WithoutEstimatedTasks int     `json:"withoutEstimatedTasks"`
+	WorkingDays           float64 `json:"workingDays"`
+	TotalCapacity         float64 `json:"totalCapacity"`
+	// Última geração de datas e se o plano ficou desatualizado
+	PlanFreshness
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Função para calcular a capacidade total de um membro na sprint: horas por
+// dia (soma das atividades) × dias úteis, descontadas as folgas dele e do time
+func memberTotalCapacity(capacity TeamMemberCapacity, start, end time.Time, teamDaysOff []DayOff, cal workCalendar) (float64, error) {
+	perDay := 0.0
+	for _, activity := range capacity.Activities {
+		perDay += activity.CapacityPerDay
+	}
+	workingDays, err := calculateWorkingDays(start, end, append(append([]DayOff{}, teamDaysOff...), capacity.DaysOff...), cal)
+	if err != nil {
+		return 0, err
+	}
+	return workingDays * perDay, nil
+}
+
+// GET /sprint-summary: visão geral da sprint numa chamada só, juntando o que
+// /user-stories e /developers devolvem separadamente
+func handleSprintSummary(pool *adoPool, cfg *Config, runs *runStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ref, err := sprintRefFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cal, err := calendarForRequest(cfg, r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		keepArea, err := areaPathFilterFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		workClient, err := pool.Work(ctx, "sprint-summary")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
+			return
+		}
+		targetIteration, err := resolveSprintRef(ctx, workClient, cfg, ref)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		sprintName := *targetIteration.Name
+		setSprintHeaders(w, targetIteration)
+		witClient, err := pool.WorkItems(ctx, "sprint-summary")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+
+		types, explicitTypes := typesFromRequest(cfg, r)
+		sprintStories, details, missing, err := fetchSprintStories(ctx, workClient, witClient, cfg, targetIteration, types, nil)
+		if err != nil {
+			respondError(w, "Erro ao buscar User Stories", err)
+			return
+		}
+		if explicitTypes {
+			if err := validateRequestedTypes(types, details); err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		var stories, open []WorkItem
+		var storyIds []int
+		for _, story := range sprintStories {
+			if !story.Removed && keepArea(story.AreaPath) {
+				stories = append(stories, story)
+				storyIds = append(storyIds, story.ID)
+				if isOpenStory(story) {
+					open = append(open, story)
+				}
+			}
+		}
+
+		// Tasks abertas: estimativa das User Stories e quem precisa de capacidade
+		estimated := make(map[int]bool)
+		assignees := make(map[string]string)
+		if len(storyIds) > 0 {
+			tasks, err := fetchChildTasks(ctx, witClient, cfg, storyIds, []string{"System.Parent", "System.AssignedTo", "Microsoft.VSTS.Scheduling.RemainingWork"})
+			if err != nil {
+				respondError(w, "Erro ao buscar tasks das User Stories", err)
+				return
+			}
+			for _, task := range tasks {
+				if parent := getFieldFloat(task.Fields, "System.Parent"); parent != nil && getFieldFloat(task.Fields, "Microsoft.VSTS.Scheduling.RemainingWork") != nil {
+					estimated[int(*parent)] = true
+				}
+				if assignedTo := getFieldIdentity(task.Fields, "System.AssignedTo"); assignedTo != nil {
+					assignees[identityKey(assignedTo.UniqueName, assignedTo.Descriptor, assignedTo.DisplayName)] = assignedTo.DisplayName
+				}
+			}
+		}
+
+		summary := SprintSummary{Sprint: sprintName, Stories: len(stories), ByState: map[string]int{}, Warnings: missingWorkItemWarnings(missing)}
+		for _, story := range stories {
+			summary.ByState[story.State]++
+			if story.StoryPoints != nil {
+				summary.StoryPoints += *story.StoryPoints
+				if isDoneState(cfg, story.State) {
+					summary.CompletedStoryPoints += *story.StoryPoints
+				}
+			}
+			if story.DueDate != nil {
+				summary.WithDueDate++
+			} else {
+				summary.WithoutDueDate++
+			}
+			if story.AssignedTo != nil {
+				summary.Assigned++
+			} else {
+				summary.Unassigned++
+			}
+			if estimated[story.ID] {
+				summary.WithEstimatedTasks++
+			} else {
+				summary.WithoutEstimatedTasks++
+			}
+		}
+
+		// Dias úteis e capacidade só fazem sentido em sprints com datas
+		sprintStart, sprintEnd, err := sprintDates(targetIteration)
+		if err != nil {
+			summary.Warnings = append(summary.Warnings, fmt.Sprintf("Sprint '%s' sem datas de início e fim; dias úteis e capacidade ficam zerados", sprintName))
+		} else {
+			summary.SprintStart, summary.SprintEnd = &sprintStart, &sprintEnd
+			teamDaysOff, err := fetchTeamDaysOff(ctx, workClient, cfg, targetIteration)
+			if err != nil {
+				respondError(w, "Erro ao buscar folgas do time", err)
+				return
+			}
+			capacities, err := fetchTeamCapacities(ctx, workClient, cfg, targetIteration)
+			if err != nil {
+				respondError(w, "Erro ao buscar capacidades da sprint", err)
+				return
+			}
+			summary.WorkingDays, err = calculateWorkingDays(sprintStart, sprintEnd, teamDaysOff, cal)
+			if err != nil {
+				respondError(w, fmt.Sprintf("Datas inválidas na sprint '%s'", sprintName), err)
+				return
+			}
+			// Quem tem tasks mas não tem capacidade na sprint recebe a capacidade padrão, como em /developers
+			var defaulted []string
+			for key, name := range assignees {
+				if _, exists := capacities[key]; !exists {
+					capacities[key] = TeamMemberCapacity{Activities: []CapacityActivity{{CapacityPerDay: cfg.DefaultCapacityPerDay}}}
+					defaulted = append(defaulted, name)
+				}
+			}
+			for _, capacity := range capacities {
+				total, err := memberTotalCapacity(capacity, sprintStart, sprintEnd, teamDaysOff, cal)
+				if err != nil {
+					respondError(w, fmt.Sprintf("Datas inválidas na sprint '%s'", sprintName), err)
+					return
+				}
+				summary.TotalCapacity += total
+			}
+			if len(defaulted) > 0 {
+				sort.Strings(defaulted)
+				summary.Warnings = append(summary.Warnings, fmt.Sprintf("Sem capacidade configurada na sprint '%s' para %s; usando %gh/dia",
+					sprintName, strings.Join(defaulted, ", "), cfg.DefaultCapacityPerDay))
+			}
+		}
+
+		freshness, warning, err := planFreshness(runs, cfg.Team, targetIteration.Id.String(), open, time.Now(), cal, cfg.PlanStaleAfterDays)
+		if err != nil {
+			respondError(w, fmt.Sprintf("Datas inválidas na sprint '%s'", sprintName), err)
+			return
+		}
+		summary.PlanFreshness = freshness
+		if warning != "" {
+			summary.Warnings = append(summary.Warnings, warning)
+		}
+
+		log.Printf("[DEBUG] Resumo da sprint '%s': %d User Stories, %.1f Story Points", sprintName, summary.Stories, summary.StoryPoints)
+		writeJSON(w, http.StatusOK, summary)
+	}
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/webapi"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+// Campos buscados para cada task
+var taskDetailFields = []string{
+	"System.Title",
+	"System.State",
+	"System.Description",
+	"System.AssignedTo",
+	"System.IterationPath",
+	"Microsoft.VSTS.Common.Activity",
+	"Microsoft.VSTS.Scheduling.RemainingWork",
+	"Microsoft.VSTS.Scheduling.OriginalEstimate",
+	"Microsoft.VSTS.Scheduling.CompletedWork",
+}
+
+// Resposta de /user-story-tasks/{id}
+type UserStoryTasksResponse struct {
+	ParentID    int      `json:"parentId"`
+	ParentTitle string   `json:"parentTitle"`
+	Tasks       []Task   `json:"tasks"`
+	Warnings    []string `json:"warnings"`
+	// Só com ?limit= ou ?offset=
+	*PageInfo
+}
+
+// Dados mínimos de um work item pai, guardados em cache
+type parentInfo struct {
+	Title     string
+	Type      string
+	fetchedAt time.Time
+}
+
+// Cache das verificações de existência do pai, evitando um GetWorkItem extra
+// a cada expansão de User Story no frontend
+type parentCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[int]parentInfo
+}
+
+func newParentCache(ttl time.Duration) *parentCache {
+	return &parentCache{ttl: ttl, entries: make(map[int]parentInfo)}
+}
+
+// Função para obter título e tipo de um work item, consultando o cache antes do Azure DevOps
+func (c *parentCache) get(ctx context.Context, witClient workitemtracking.Client, project string, id int) (parentInfo, error) {
+	c.mu.Lock()
+	info, ok := c.entries[id]
+	c.mu.Unlock()
+	if ok && time.Since(info.fetchedAt) < c.ttl {
+		return info, nil
+	}
+
+	workItem, err := witClient.GetWorkItem(ctx, workitemtracking.GetWorkItemArgs{
+		Id:      &id,
+		Project: &project,
+		Fields:  &[]string{"System.Title", "System.WorkItemType"},
+	})
+	if err != nil {
+		return parentInfo{}, wrapAdoError(err, "GetWorkItem", "id=%d", id)
+	}
+
+	info = parentInfo{
+		Title:     getFieldValue(workItem.Fields, "System.Title"),
+		Type:      getFieldValue(workItem.Fields, "System.WorkItemType"),
+		fetchedAt: time.Now(),
+	}
+	c.mu.Lock()
+	c.entries[id] = info
+	c.mu.Unlock()
+	return info, nil
+}
+
+// Função para montar uma Task a partir do work item do Azure DevOps
+func buildTask(workItem workitemtracking.WorkItem, cfg *Config, format string) Task {
+	task := Task{
+		ID:    *workItem.Id,
+		Title: getFieldValue(workItem.Fields, "System.Title"),
+		State: getFieldValue(workItem.Fields, "System.State"),
+	}
+	task.Removed = isRemovedState(task.State)
+	task.ExtraFields = extraFieldValues(workItem.Fields, cfg.ExtraFields)
+	task.Url = workItemURL(cfg, task.ID)
+	task.IterationPath = getFieldValue(workItem.Fields, "System.IterationPath")
+	task.Activity = getFieldValue(workItem.Fields, "Microsoft.VSTS.Common.Activity")
+	// Horas; ficam null quando a task não foi estimada
+	task.RemainingWork = getFieldFloat(workItem.Fields, "Microsoft.VSTS.Scheduling.RemainingWork")
+	task.OriginalEstimate = getFieldFloat(workItem.Fields, "Microsoft.VSTS.Scheduling.OriginalEstimate")
+	task.CompletedWork = getFieldFloat(workItem.Fields, "Microsoft.VSTS.Scheduling.CompletedWork")
+
+	// Campos opcionais
+	if desc := getFieldValue(workItem.Fields, "System.Description"); desc != "" {
+		task.Description = formatDescription(desc, format)
+	}
+	if assignedTo := getFieldIdentity(workItem.Fields, "System.AssignedTo"); assignedTo != nil {
+		task.AssignedTo = assignedTo
+		task.AssignedToName = assignedTo.DisplayName
+	}
+	return task
+}
+
+// Função para buscar as tasks de várias User Stories de uma vez: uma consulta
+// WIQL com System.Parent IN (...) e os detalhes em lotes. Devolve as tasks
+// agrupadas pelo pai, na ordem da consulta, e avisos de tasks não encontradas.
+func fetchTasksByParent(ctx context.Context, witClient workitemtracking.Client, cfg *Config, parentIds []int, format string, includeRemoved bool) (map[int][]Task, []string, error) {
+	wiql, err := newWiqlQuery("System.Id").
+		Where("System.WorkItemType", "=", "Task").
+		WhereInInts("System.Parent", parentIds).
+		Build()
+	if err != nil {
+		return nil, nil, err
+	}
+	query := workitemtracking.Wiql{Query: &wiql}
+	queryResults, err := witClient.QueryByWiql(ctx, workitemtracking.QueryByWiqlArgs{
+		Wiql:    &query,
+		Project: &cfg.Project,
+	})
+	if err != nil {
+		return nil, nil, wrapAdoError(err, "QueryByWiql", "parents=%d", len(parentIds))
+	}
+
+	var taskIds []int
+	if queryResults != nil && queryResults.WorkItems != nil {
+		for _, item := range *queryResults.WorkItems {
+			if item.Id != nil {
+				taskIds = append(taskIds, *item.Id)
+			}
+		}
+	}
+	tasksByParent := make(map[int][]Task)
+	if len(taskIds) == 0 {
+		return tasksByParent, nil, nil
+	}
+
+	taskFields := withExtraFields(append([]string{"System.Parent"}, taskDetailFields...), cfg.ExtraFields)
+	workItems, err := getWorkItemsChunked(ctx, witClient, cfg.Project, taskIds, taskFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	var warnings []string
+	for _, missingID := range missingWorkItemIds(taskIds, workItems) {
+		warnings = append(warnings, fmt.Sprintf("Task #%d não encontrada (provavelmente excluída)", missingID))
+	}
+	for _, workItem := range presentWorkItems(taskIds, workItems) {
+		parent := getFieldFloat(workItem.Fields, "System.Parent")
+		if parent == nil {
+			continue
+		}
+		task := buildTask(workItem, cfg, format)
+		if task.Removed && !includeRemoved {
+			continue
+		}
+		tasksByParent[int(*parent)] = append(tasksByParent[int(*parent)], task)
+	}
+	log.Printf("[DEBUG] %d tasks de %d User Stories buscadas em uma consulta", len(taskIds), len(parentIds))
+	return tasksByParent, warnings, nil
+}
+
+// Handler de GET /user-story-tasks/{id}
+func handleUserStoryTasks(pool *adoPool, cfg *Config) http.HandlerFunc {
+	parents := newParentCache(5 * time.Minute)
+	project := cfg.Project
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Extrair ID da User Story da URL
+		userStoryID := r.URL.Path[len("/user-story-tasks/"):]
+		if userStoryID == "" {
+			jsonError(w, "ID da User Story é obrigatório", http.StatusBadRequest)
+			return
+		}
+
+		id, err := strconv.Atoi(userStoryID)
+		if err != nil {
+			jsonError(w, "ID da User Story inválido", http.StatusBadRequest)
+			return
+		}
+
+		format, ok := descriptionFormatFromQuery(r.URL.Query().Get("format"))
+		if !ok {
+			jsonError(w, fmt.Sprintf("Parâmetro 'format' inválido: %q (use text ou html)", r.URL.Query().Get("format")), http.StatusBadRequest)
+			return
+		}
+		page, err := pageFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		witClient, err := pool.WorkItems(ctx, "user-story-tasks")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+
+		// O pai precisa existir e ser uma User Story; antes, um ID de task
+		// devolvia uma lista vazia, o que era enganoso
+		parent, err := parents.get(ctx, witClient, project, id)
+		if err != nil {
+			respondError(w, fmt.Sprintf("Erro ao buscar User Story #%d", id), err)
+			return
+		}
+		if !isTrackedType(cfg.WorkItemTypes, parent.Type) {
+			jsonError(w, fmt.Sprintf("Work item #%d é do tipo '%s', fora dos tipos acompanhados (%s)", id, parent.Type, strings.Join(cfg.WorkItemTypes, ", ")), http.StatusNotFound)
+			return
+		}
+
+		// Buscar tasks vinculadas à User Story
+		wiql, err := newWiqlQuery("System.Id", "System.Title", "System.State", "System.Description", "System.AssignedTo").
+			Where("System.WorkItemType", "=", "Task").
+			Where("System.Parent", "=", id).
+			Build()
+		if err != nil {
+			respondError(w, "Erro ao montar consulta de tasks", err)
+			return
+		}
+
+		query := workitemtracking.Wiql{Query: &wiql}
+		queryResults, err := witClient.QueryByWiql(ctx, workitemtracking.QueryByWiqlArgs{
+			Wiql:    &query,
+			Project: &project,
+		})
+
+		if err != nil {
+			respondError(w, "Erro ao buscar tasks", wrapAdoError(err, "QueryByWiql", "parent=%d", id))
+			return
+		}
+
+		var taskIds []int
+		if queryResults != nil && queryResults.WorkItems != nil {
+			for _, item := range *queryResults.WorkItems {
+				if item.Id != nil {
+					taskIds = append(taskIds, *item.Id)
+				}
+			}
+		}
+
+		includeRemoved := r.URL.Query().Get("includeRemoved") == "true"
+
+		response := UserStoryTasksResponse{
+			ParentID:    id,
+			ParentTitle: parent.Title,
+			Tasks:       make([]Task, 0),
+			Warnings:    make([]string, 0),
+		}
+		if len(taskIds) > 0 {
+			taskFields := withExtraFields(taskDetailFields, cfg.ExtraFields)
+			workItems, err := getWorkItemsChunked(ctx, witClient, project, taskIds, taskFields)
+			if err != nil {
+				respondError(w, "Erro ao buscar detalhes das tasks", err)
+				return
+			}
+
+			for _, missingID := range missingWorkItemIds(taskIds, workItems) {
+				response.Warnings = append(response.Warnings, fmt.Sprintf("Task #%d não encontrada (provavelmente excluída)", missingID))
+			}
+
+			for _, workItem := range presentWorkItems(taskIds, workItems) {
+				task := buildTask(workItem, cfg, format)
+				if task.Removed && !includeRemoved {
+					continue
+				}
+				response.Tasks = append(response.Tasks, task)
+			}
+		}
+		if page.Enabled {
+			start, end, info := page.bounds(len(response.Tasks))
+			response.Tasks, response.PageInfo = response.Tasks[start:end], &info
+		}
+
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+// Tamanho máximo de System.Title no Azure DevOps
+const maxTaskTitleLength = 255
+
+// Corpo de POST /user-story-tasks/{id}
+type createTaskRequest struct {
+	Title         string   `json:"title"`
+	AssignedTo    string   `json:"assignedTo"`
+	RemainingWork *float64 `json:"remainingWork"`
+	Activity      string   `json:"activity"`
+}
+
+// Função para montar o JSON Patch de criação de uma task filha de parentURL,
+// na iteração e na área do pai; campos vazios ficam de fora
+func createTaskPatch(body createTaskRequest, iterationPath, areaPath, parentURL string) []webapi.JsonPatchOperation {
+	var document []webapi.JsonPatchOperation
+	addField := func(field string, value interface{}) {
+		path := "/fields/" + field
+		document = append(document, webapi.JsonPatchOperation{Op: &webapi.OperationValues.Add, Path: &path, Value: value})
+	}
+	addField("System.Title", body.Title)
+	addField("System.IterationPath", iterationPath)
+	if areaPath != "" {
+		addField("System.AreaPath", areaPath)
+	}
+	if body.AssignedTo != "" {
+		addField("System.AssignedTo", body.AssignedTo)
+	}
+	if body.RemainingWork != nil {
+		addField("Microsoft.VSTS.Scheduling.RemainingWork", *body.RemainingWork)
+	}
+	if body.Activity != "" {
+		addField("Microsoft.VSTS.Common.Activity", body.Activity)
+	}
+	relationsPath := "/relations/-"
+	document = append(document, webapi.JsonPatchOperation{
+		Op:   &webapi.OperationValues.Add,
+		Path: &relationsPath,
+		Value: map[string]string{
+			"rel": "System.LinkTypes.Hierarchy-Reverse",
+			"url": parentURL,
+		},
+	})
+	return document
+}
+
+// Handler de POST /user-story-tasks/{id}: cria uma task filha da User Story,
+// na mesma iteração dela, e devolve a task no formato do GET
+func handleCreateUserStoryTask(pool *adoPool, cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			jsonError(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		idText := strings.TrimPrefix(r.URL.Path, "/user-story-tasks/")
+		id, err := strconv.Atoi(idText)
+		if err != nil || id <= 0 {
+			jsonError(w, fmt.Sprintf("ID da User Story inválido: %q", idText), http.StatusBadRequest)
+			return
+		}
+		format, ok := descriptionFormatFromQuery(r.URL.Query().Get("format"))
+		if !ok {
+			jsonError(w, fmt.Sprintf("Parâmetro 'format' inválido: %q (use text ou html)", r.URL.Query().Get("format")), http.StatusBadRequest)
+			return
+		}
+
+		var body createTaskRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			jsonError(w, fmt.Sprintf("Corpo inválido: %v", err), http.StatusBadRequest)
+			return
+		}
+		body.Title = strings.TrimSpace(body.Title)
+		body.AssignedTo = strings.TrimSpace(body.AssignedTo)
+		body.Activity = strings.TrimSpace(body.Activity)
+		if body.Title == "" {
+			jsonError(w, "Campo 'title' é obrigatório", http.StatusBadRequest)
+			return
+		}
+		if utf8.RuneCountInString(body.Title) > maxTaskTitleLength {
+			jsonError(w, fmt.Sprintf("Campo 'title' excede %d caracteres", maxTaskTitleLength), http.StatusBadRequest)
+			return
+		}
+		if body.RemainingWork != nil && *body.RemainingWork < 0 {
+			jsonError(w, "Campo 'remainingWork' não pode ser negativo", http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		witClient, err := pool.WorkItems(ctx, "create-task")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+
+		// Sem cache aqui: a iteração do pai precisa ser a atual
+		parent, err := witClient.GetWorkItem(ctx, workitemtracking.GetWorkItemArgs{
+			Id:      &id,
+			Project: &cfg.Project,
+			Fields:  &[]string{"System.WorkItemType", "System.IterationPath", "System.AreaPath"},
+		})
+		if err != nil {
+			respondError(w, fmt.Sprintf("Erro ao buscar User Story #%d", id), wrapAdoError(err, "GetWorkItem", "id=%d", id))
+			return
+		}
+		parentType := getFieldValue(parent.Fields, "System.WorkItemType")
+		if !isTrackedType(cfg.WorkItemTypes, parentType) {
+			jsonError(w, fmt.Sprintf("Work item #%d é do tipo '%s', fora dos tipos acompanhados (%s)", id, parentType, strings.Join(cfg.WorkItemTypes, ", ")), http.StatusNotFound)
+			return
+		}
+		if parent.Url == nil {
+			respondError(w, "", fmt.Errorf("User Story #%d sem URL no Azure DevOps", id))
+			return
+		}
+
+		document := createTaskPatch(body, getFieldValue(parent.Fields, "System.IterationPath"), getFieldValue(parent.Fields, "System.AreaPath"), *parent.Url)
+		taskType := "Task"
+		created, err := witClient.CreateWorkItem(ctx, workitemtracking.CreateWorkItemArgs{
+			Document: &document,
+			Project:  &cfg.Project,
+			Type:     &taskType,
+		})
+		if err != nil {
+			err = wrapAdoError(err, "CreateWorkItem", "parent=%d", id)
+			// 400 do Azure DevOps é validação do conteúdo (responsável ou
+			// atividade inexistentes, por exemplo); a mensagem dele vai junto
+			if adoStatusCode(err) == http.StatusBadRequest {
+				log.Printf("[WARN] Task recusada pelo Azure DevOps: %v", err)
+				jsonError(w, fmt.Sprintf("Azure DevOps recusou a task: %v", err), http.StatusUnprocessableEntity)
+				return
+			}
+			respondError(w, fmt.Sprintf("Erro ao criar task na User Story #%d", id), err)
+			return
+		}
+		if created == nil || created.Id == nil {
+			respondError(w, "", fmt.Errorf("Azure DevOps não devolveu a task criada na User Story #%d", id))
+			return
+		}
+
+		task := buildTask(*created, cfg, format)
+		log.Printf("[DEBUG] Task #%d criada na User Story #%d (%s)", task.ID, id, task.IterationPath)
+		writeJSON(w, http.StatusCreated, task)
+	}
+}
@@ -0,0 +1,567 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Chaves aceitas em cada time de TEAMS_CONFIG, na ordem da documentação
+var teamProfileKeys = []string{"storyTypes", "childTypes", "focusFactor", "buffer", "ceremonies", "excludedAssignees", "dueDateWriteField", "leaderboard"}
+
+// Chaves de TEAMS_CONFIG que recebem uma lista
+var teamProfileListKeys = map[string]bool{"storyTypes": true, "childTypes": true, "excludedAssignees": true}
+
+// Ajustes de um time em TEAMS_CONFIG. Só as chaves presentes no arquivo
+// (Keys) substituem os valores do ambiente.
+type TeamProfile struct {
+	Name              string
+	Line              int
+	StoryTypes        []string
+	ChildTypes        []string
+	FocusFactor       float64
+	Buffer            generationBuffer
+	Ceremonies        float64
+	ExcludedAssignees []string
+	DueDateWriteField string
+	Leaderboard       bool
+	// Chaves presentes no arquivo, na ordem em que aparecem
+	Keys []string
+}
+
+// Função para saber se o perfil define a chave
+func (p *TeamProfile) has(key string) bool {
+	if p == nil {
+		return false
+	}
+	for _, present := range p.Keys {
+		if present == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Função para ler TEAMS_CONFIG; os erros citam o arquivo e a linha
+func loadTeamProfiles(path string) ([]TeamProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("TEAMS_CONFIG: %w", err)
+	}
+	profiles, err := parseTeamProfiles(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("TEAMS_CONFIG %s: %w", path, err)
+	}
+	return profiles, nil
+}
+
+// Função para interpretar o YAML de TEAMS_CONFIG: um mapa de nome do time
+// para os ajustes dele. Aceita o subconjunto de YAML que o arquivo precisa:
+// comentários com #, valores com ou sem aspas, listas na mesma linha
+// ([a, b]) ou em linhas "- item". Chaves desconhecidas, repetidas ou fora de
+// um time são erros com o número da linha.
+func parseTeamProfiles(data string) ([]TeamProfile, error) {
+	var profiles []TeamProfile
+	var current *TeamProfile
+	keyIndent := 0
+	// Lista em linhas "- item" em andamento
+	listKey, listLine := "", 0
+	var listItems []string
+
+	closeList := func() error {
+		if listKey == "" {
+			return nil
+		}
+		key, line, items := listKey, listLine, listItems
+		listKey, listItems = "", nil
+		if len(items) == 0 {
+			return fmt.Errorf("linha %d: '%s' sem valor", line, key)
+		}
+		return setTeamProfileValue(current, key, items, line)
+	}
+
+	for i, raw := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		number := i + 1
+		line := stripYAMLComment(raw)
+		if strings.TrimSpace(line) == "" || (i == 0 && strings.TrimSpace(line) == "---") {
+			continue
+		}
+		content := strings.TrimLeft(line, " ")
+		indent := len(line) - len(content)
+		if strings.HasPrefix(content, "\t") {
+			return nil, fmt.Errorf("linha %d: use espaços, não tabs, na indentação", number)
+		}
+		content = strings.TrimRight(content, " \t")
+
+		// Item de uma lista em linhas "- item"
+		if content == "-" || strings.HasPrefix(content, "- ") {
+			if listKey == "" || indent <= keyIndent {
+				return nil, fmt.Errorf("linha %d: item de lista fora de uma chave de lista", number)
+			}
+			item := unquoteYAML(strings.TrimSpace(strings.TrimPrefix(content, "-")))
+			if item == "" {
+				return nil, fmt.Errorf("linha %d: item de lista vazio", number)
+			}
+			listItems = append(listItems, item)
+			continue
+		}
+		if err := closeList(); err != nil {
+			return nil, err
+		}
+
+		key, value, found := splitYAMLKey(content)
+		if !found {
+			return nil, fmt.Errorf("linha %d: esperado 'chave: valor'", number)
+		}
+
+		// Nome do time
+		if indent == 0 {
+			if value != "" {
+				return nil, fmt.Errorf("linha %d: esperado o nome do time seguido de ':' e os ajustes nas linhas seguintes", number)
+			}
+			for _, existing := range profiles {
+				if strings.EqualFold(existing.Name, key) {
+					return nil, fmt.Errorf("linha %d: time '%s' repetido (linha %d)", number, key, existing.Line)
+				}
+			}
+			profiles = append(profiles, TeamProfile{Name: key, Line: number})
+			current = &profiles[len(profiles)-1]
+			keyIndent = 0
+			continue
+		}
+
+		if current == nil {
+			return nil, fmt.Errorf("linha %d: ajuste fora de um time", number)
+		}
+		if keyIndent == 0 {
+			keyIndent = indent
+		} else if indent != keyIndent {
+			return nil, fmt.Errorf("linha %d: indentação diferente das outras chaves do time '%s'", number, current.Name)
+		}
+		known := false
+		for _, name := range teamProfileKeys {
+			if name == key {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("linha %d: chave desconhecida '%s' (use %s)", number, key, strings.Join(teamProfileKeys, ", "))
+		}
+		if current.has(key) {
+			return nil, fmt.Errorf("linha %d: chave '%s' repetida no time '%s'", number, key, current.Name)
+		}
+
+		switch {
+		case value == "":
+			if !teamProfileListKeys[key] {
+				return nil, fmt.Errorf("linha %d: '%s' sem valor", number, key)
+			}
+			listKey, listLine, listItems = key, number, []string{}
+		case strings.HasPrefix(value, "["):
+			if !strings.HasSuffix(value, "]") {
+				return nil, fmt.Errorf("linha %d: lista sem ']' no fim", number)
+			}
+			if !teamProfileListKeys[key] {
+				return nil, fmt.Errorf("linha %d: '%s' não aceita lista", number, key)
+			}
+			items := []string{}
+			for _, part := range strings.Split(strings.TrimSuffix(strings.TrimPrefix(value, "["), "]"), ",") {
+				if item := unquoteYAML(strings.TrimSpace(part)); item != "" {
+					items = append(items, item)
+				}
+			}
+			if err := setTeamProfileValue(current, key, items, number); err != nil {
+				return nil, err
+			}
+		default:
+			if err := setTeamProfileValue(current, key, []string{unquoteYAML(value)}, number); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := closeList(); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// Função para validar e guardar o valor de uma chave do perfil. Listas
+// podem vir vazias ([]) só em excludedAssignees.
+func setTeamProfileValue(profile *TeamProfile, key string, values []string, line int) error {
+	var err error
+	switch key {
+	case "storyTypes", "childTypes":
+		if len(values) == 0 {
+			return fmt.Errorf("linha %d: '%s' precisa de pelo menos um tipo", line, key)
+		}
+		if key == "storyTypes" {
+			profile.StoryTypes = values
+		} else {
+			profile.ChildTypes = values
+		}
+	case "excludedAssignees":
+		profile.ExcludedAssignees = values
+	case "focusFactor":
+		profile.FocusFactor, err = parseFocusFactor(values[0])
+	case "buffer":
+		profile.Buffer, err = parseBufferSetting(values[0])
+	case "ceremonies":
+		profile.Ceremonies, err = parseCeremonyHours(values[0])
+	case "dueDateWriteField":
+		if !fieldReferencePattern.MatchString(values[0]) {
+			err = fmt.Errorf("%q (use o reference name do campo, por exemplo Custom.CommittedDate)", values[0])
+		}
+		profile.DueDateWriteField = values[0]
+	case "leaderboard":
+		profile.Leaderboard, err = strconv.ParseBool(values[0])
+	}
+	if err != nil {
+		return fmt.Errorf("linha %d: '%s' inválido: %w", line, key, err)
+	}
+	profile.Keys = append(profile.Keys, key)
+	return nil
+}
+
+// Função para separar "chave: valor", com a chave opcionalmente entre aspas
+func splitYAMLKey(content string) (key, value string, found bool) {
+	if quote := content[0]; quote == '"' || quote == '\'' {
+		end := strings.IndexByte(content[1:], quote)
+		if end < 0 || !strings.HasPrefix(content[end+2:], ":") {
+			return "", "", false
+		}
+		return content[1 : end+1], strings.TrimSpace(content[end+3:]), true
+	}
+	index := strings.Index(content, ":")
+	if index <= 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(content[:index]), strings.TrimSpace(content[index+1:]), true
+}
+
+// Função para remover o comentário (# no início ou depois de um espaço)
+// fora de aspas
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// Função para tirar as aspas de um valor
+func unquoteYAML(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// Função para montar a configuração de um time: uma cópia do ambiente com
+// as chaves do perfil aplicadas. Sem perfil, a cópia fica igual ao ambiente.
+func (cfg *Config) withProfile(profile *TeamProfile) *Config {
+	team := *cfg
+	team.Profile = profile
+	if profile == nil {
+		return &team
+	}
+	for _, key := range profile.Keys {
+		switch key {
+		case "storyTypes":
+			team.WorkItemTypes = profile.StoryTypes
+		case "childTypes":
+			team.ChildWorkItemTypes = profile.ChildTypes
+		case "focusFactor":
+			team.FocusFactor = profile.FocusFactor
+		case "buffer":
+			team.DueDateBuffer = profile.Buffer
+		case "ceremonies":
+			team.CeremonyHoursPerDay = profile.Ceremonies
+		case "excludedAssignees":
+			team.ExcludedAssignees = profile.ExcludedAssignees
+		case "dueDateWriteField":
+			team.DueDateWriteField = profile.DueDateWriteField
+		case "leaderboard":
+			team.LeaderboardEnabled = profile.Leaderboard
+		}
+	}
+	return &team
+}
+
+// teamConfigs guarda a configuração efetiva de cada time atendido: o de
+// AZURE_DEVOPS_TEAM (padrão) e os de TEAMS_CONFIG, escolhidos por ?team=.
+// Na releitura do arquivo o conjunto é trocado inteiro; uma releitura com
+// erro mantém o anterior.
+type teamConfigs struct {
+	// Configuração do ambiente, com o time padrão já resolvido
+	base *Config
+	pool *adoPool
+
+	mu       sync.RWMutex
+	teams    []*Config
+	loadedAt time.Time
+	// Incrementada a cada releitura, para as rotas montarem os handlers de novo
+	version int
+}
+
+// Função para montar as configurações a partir do ambiente e, com
+// TEAMS_CONFIG, do arquivo. Os times do arquivo são resolvidos contra o
+// projeto como AZURE_DEVOPS_TEAM.
+func newTeamConfigs(ctx context.Context, pool *adoPool, base *Config) (*teamConfigs, error) {
+	configs := &teamConfigs{base: base, pool: pool}
+	if err := configs.reload(ctx); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+// Função para (re)ler TEAMS_CONFIG e trocar as configurações dos times
+func (t *teamConfigs) reload(ctx context.Context) error {
+	var profiles []TeamProfile
+	if t.base.TeamsConfigFile != "" {
+		var err error
+		profiles, err = loadTeamProfiles(t.base.TeamsConfigFile)
+		if err != nil {
+			return err
+		}
+	}
+	teams, err := t.build(ctx, profiles)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.teams, t.loadedAt = teams, time.Now().UTC()
+	t.version++
+	t.mu.Unlock()
+	names := make([]string, len(teams))
+	for i, team := range teams {
+		names[i] = team.TeamName
+	}
+	log.Printf("[DEBUG] Times configurados: %s", strings.Join(names, ", "))
+	return nil
+}
+
+// Função para montar a configuração de cada time; a do time padrão vem primeiro
+func (t *teamConfigs) build(ctx context.Context, profiles []TeamProfile) ([]*Config, error) {
+	var teams []*Config
+	var pending []*TeamProfile
+	defaultTeam := t.base.withProfile(nil)
+	for i := range profiles {
+		if strings.EqualFold(strings.TrimSpace(profiles[i].Name), t.base.TeamName) || strings.EqualFold(profiles[i].Name, t.base.Team) {
+			defaultTeam = t.base.withProfile(&profiles[i])
+			continue
+		}
+		pending = append(pending, &profiles[i])
+	}
+	teams = append(teams, defaultTeam)
+	if len(pending) == 0 {
+		return teams, nil
+	}
+
+	coreClient, err := t.pool.Core(ctx, "teams-config")
+	if err != nil {
+		return nil, wrapAdoError(err, "core.NewClient", "")
+	}
+	available, err := listTeams(ctx, coreClient, t.base.Project)
+	if err != nil {
+		return nil, err
+	}
+	for _, profile := range pending {
+		team := t.base.withProfile(profile)
+		team.Team = profile.Name
+		if err := applyTeamMatch(available, team); err != nil {
+			return nil, fmt.Errorf("TEAMS_CONFIG linha %d: %w", profile.Line, err)
+		}
+		for _, existing := range teams {
+			if existing.Team == team.Team {
+				return nil, fmt.Errorf("TEAMS_CONFIG linha %d: '%s' é o mesmo time de '%s'", profile.Line, profile.Name, existing.TeamName)
+			}
+		}
+		teams = append(teams, team)
+	}
+	return teams, nil
+}
+
+// Função para encontrar a configuração de um time pelo nome (sem diferenciar
+// maiúsculas) ou pelo ID; vazio é o time padrão
+func (t *teamConfigs) lookup(name string) (*Config, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return t.teams[0], true
+	}
+	for _, team := range t.teams {
+		if strings.EqualFold(team.TeamName, name) || strings.EqualFold(team.Team, name) {
+			return team, true
+		}
+	}
+	return nil, false
+}
+
+// Função para listar as configurações de todos os times, o padrão primeiro
+func (t *teamConfigs) all() []*Config {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return append([]*Config(nil), t.teams...)
+}
+
+// Função para listar os nomes dos times configurados, em ordem alfabética
+func (t *teamConfigs) names() []string {
+	var names []string
+	for _, team := range t.all() {
+		names = append(names, team.TeamName)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Função para escolher a configuração pelo ?team= da requisição; responde
+// 404 quando o time não está configurado
+func (t *teamConfigs) forRequest(w http.ResponseWriter, r *http.Request) (*Config, bool) {
+	name := r.URL.Query().Get("team")
+	team, ok := t.lookup(name)
+	if !ok {
+		jsonError(w, fmt.Sprintf("Time '%s' não configurado. Times disponíveis: %s", strings.TrimSpace(name), strings.Join(t.names(), ", ")), http.StatusNotFound)
+		return nil, false
+	}
+	return team, true
+}
+
+// Função para atender uma rota com a configuração do time da requisição. O
+// handler de cada time é montado uma vez (guardando caches como o dos pais
+// das tasks) e montado de novo depois de uma releitura de TEAMS_CONFIG.
+func (t *teamConfigs) route(build func(cfg *Config) http.HandlerFunc) http.HandlerFunc {
+	var mu sync.Mutex
+	handlers := make(map[*Config]http.HandlerFunc)
+	version := 0
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg, ok := t.forRequest(w, r)
+		if !ok {
+			return
+		}
+		t.mu.RLock()
+		current := t.version
+		t.mu.RUnlock()
+
+		mu.Lock()
+		if version != current {
+			handlers, version = make(map[*Config]http.HandlerFunc), current
+		}
+		handler, ok := handlers[cfg]
+		if !ok {
+			handler = build(cfg)
+			handlers[cfg] = handler
+		}
+		mu.Unlock()
+		handler(w, r)
+	}
+}
+
+// Configuração efetiva de um time em GET /config
+type TeamConfigResponse struct {
+	Team    string `json:"team"`
+	TeamID  string `json:"teamId"`
+	Default bool   `json:"default"`
+	// Chaves vindas de TEAMS_CONFIG; as outras são do ambiente
+	Overrides         []string  `json:"overrides"`
+	StoryTypes        []string  `json:"storyTypes"`
+	ChildTypes        []string  `json:"childTypes"`
+	FocusFactor       float64   `json:"focusFactor"`
+	Buffer            string    `json:"buffer"`
+	Ceremonies        float64   `json:"ceremonies"`
+	ExcludedAssignees []string  `json:"excludedAssignees"`
+	DueDateWriteField string    `json:"dueDateWriteField"`
+	Leaderboard       bool      `json:"leaderboard"`
+	TeamsConfigFile   string    `json:"teamsConfigFile,omitempty"`
+	LoadedAt          time.Time `json:"loadedAt"`
+	Teams             []string  `json:"teams"`
+}
+
+// GET /config?team=: a configuração efetiva do time (padrão sem ?team=)
+func handleConfig(teams *teamConfigs) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			jsonError(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		cfg, ok := teams.forRequest(w, r)
+		if !ok {
+			return
+		}
+		teams.mu.RLock()
+		loadedAt := teams.loadedAt
+		defaultTeam := teams.teams[0] == cfg
+		teams.mu.RUnlock()
+
+		response := TeamConfigResponse{
+			Team:              cfg.TeamName,
+			TeamID:            cfg.Team,
+			Default:           defaultTeam,
+			Overrides:         []string{},
+			StoryTypes:        cfg.WorkItemTypes,
+			ChildTypes:        cfg.childTypes(),
+			FocusFactor:       cfg.FocusFactor,
+			Buffer:            cfg.DueDateBuffer.String(),
+			Ceremonies:        cfg.CeremonyHoursPerDay,
+			ExcludedAssignees: append([]string{}, cfg.ExcludedAssignees...),
+			DueDateWriteField: cfg.writeField(),
+			Leaderboard:       cfg.LeaderboardEnabled,
+			TeamsConfigFile:   cfg.TeamsConfigFile,
+			LoadedAt:          loadedAt,
+			Teams:             teams.names(),
+		}
+		if cfg.Profile != nil {
+			response.Overrides = append(response.Overrides, cfg.Profile.Keys...)
+		}
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+// POST /config/reload: relê TEAMS_CONFIG sem reiniciar o serviço. Com erro no
+// arquivo, a configuração anterior continua valendo.
+func handleConfigReload(teams *teamConfigs) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			jsonError(w, "Método não permitido", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := teams.reload(r.Context()); err != nil {
+			log.Printf("[ERROR] Releitura de TEAMS_CONFIG falhou; configuração anterior mantida: %v", err)
+			jsonError(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string][]string{"teams": teams.names()})
+	}
+}
+
+// Função para reler TEAMS_CONFIG a cada SIGHUP; roda até o processo terminar
+func reloadTeamsOnSignal(teams *teamConfigs) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := teams.reload(ctx); err != nil {
+			log.Printf("[ERROR] Releitura de TEAMS_CONFIG falhou; configuração anterior mantida: %v", err)
+		}
+		cancel()
+	}
+}
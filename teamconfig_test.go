@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/core"
+)
+
+// Cliente core de teste que só lista os times informados
+type fakeCoreClient struct {
+	core.Client
+
+	teams []core.WebApiTeam
+}
+
+func (c *fakeCoreClient) GetTeams(ctx context.Context, args core.GetTeamsArgs) (*[]core.WebApiTeam, error) {
+	teams := c.teams
+	if args.Skip != nil && *args.Skip >= len(teams) {
+		teams = nil
+	}
+	return &teams, nil
+}
+
+// Função para montar um time do projeto com ID aleatório
+func fakeTeam(name string) core.WebApiTeam {
+	id := uuid.New()
+	return core.WebApiTeam{Id: &id, Name: &name}
+}
+
+func TestParseTeamProfiles(t *testing.T) {
+	data := `---
+# Ajustes por time
+"Time Pagamentos":
+  storyTypes: [User Story, Bug]
+  childTypes:
+    - Task
+    - "Bug Task"
+  focusFactor: 0.7   # 70% do dia
+  buffer: 15%
+  ceremonies: 1.5
+
+Time Mobile:
+  excludedAssignees: []
+  dueDateWriteField: 'Custom.CommittedDate'
+  leaderboard: false
+`
+	profiles, err := parseTeamProfiles(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 2 {
+		t.Fatalf("perfis = %+v, quer 2", profiles)
+	}
+	payments, mobile := profiles[0], profiles[1]
+	if payments.Name != "Time Pagamentos" || payments.Line != 3 {
+		t.Errorf("time = %q na linha %d", payments.Name, payments.Line)
+	}
+	if !reflect.DeepEqual(payments.StoryTypes, []string{"User Story", "Bug"}) || !reflect.DeepEqual(payments.ChildTypes, []string{"Task", "Bug Task"}) {
+		t.Errorf("tipos = %q / %q", payments.StoryTypes, payments.ChildTypes)
+	}
+	if payments.FocusFactor != 0.7 || payments.Buffer.Percent != 15 || payments.Ceremonies != 1.5 {
+		t.Errorf("perfil = %+v", payments)
+	}
+	if want := []string{"storyTypes", "childTypes", "focusFactor", "buffer", "ceremonies"}; !reflect.DeepEqual(payments.Keys, want) {
+		t.Errorf("chaves = %q, quer %q", payments.Keys, want)
+	}
+	if mobile.DueDateWriteField != "Custom.CommittedDate" || len(mobile.ExcludedAssignees) != 0 || !mobile.has("excludedAssignees") || mobile.has("buffer") || mobile.Leaderboard || !mobile.has("leaderboard") {
+		t.Errorf("perfil = %+v", mobile)
+	}
+}
+
+func TestParseTeamProfilesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "chave desconhecida", data: "Time:\n  focusFactor: 0.8\n  bufer: 2d\n", wantErr: "linha 3: chave desconhecida 'bufer'"},
+		{name: "fator fora do intervalo", data: "Time:\n  focusFactor: 1.5\n", wantErr: "linha 2: 'focusFactor' inválido"},
+		{name: "folga sem unidade", data: "Time:\n  buffer: 15\n", wantErr: "linha 2: 'buffer' inválido"},
+		{name: "cerimônias negativas", data: "Time:\n  ceremonies: -1\n", wantErr: "linha 2: 'ceremonies' inválido"},
+		{name: "campo inválido", data: "Time:\n  dueDateWriteField: Due Date\n", wantErr: "linha 2: 'dueDateWriteField' inválido"},
+		{name: "leaderboard não booleano", data: "Time:\n  leaderboard: talvez\n", wantErr: "linha 2: 'leaderboard' inválido"},
+		{name: "tab na indentação", data: "Time:\n\tbuffer: 2d\n", wantErr: "linha 2: use espaços"},
+		{name: "time repetido", data: "Time:\n  buffer: 2d\ntime:\n  buffer: 1d\n", wantErr: "linha 3: time 'time' repetido (linha 1)"},
+		{name: "chave repetida", data: "Time:\n  buffer: 2d\n  buffer: 1d\n", wantErr: "linha 3: chave 'buffer' repetida"},
+		{name: "ajuste fora de um time", data: "  buffer: 2d\n", wantErr: "linha 1: ajuste fora de um time"},
+		{name: "lista sem itens", data: "Time:\n  childTypes:\n  buffer: 2d\n", wantErr: "linha 2: 'childTypes' sem valor"},
+		{name: "lista vazia de tipos", data: "Time:\n  storyTypes: []\n", wantErr: "linha 2: 'storyTypes' precisa de pelo menos um tipo"},
+		{name: "lista em chave escalar", data: "Time:\n  buffer: [2d]\n", wantErr: "linha 2: 'buffer' não aceita lista"},
+		{name: "indentação diferente", data: "Time:\n  buffer: 2d\n    ceremonies: 1\n", wantErr: "linha 3: indentação diferente"},
+		{name: "valor no nome do time", data: "Time: 2d\n", wantErr: "linha 1: esperado o nome do time"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTeamProfiles(tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("erro = %v, quer %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigWithProfile(t *testing.T) {
+	base := &Config{
+		Team:              "id",
+		WorkItemTypes:     []string{"User Story"},
+		FocusFactor:       0.8,
+		DueDateBuffer:     generationBuffer{Days: 1},
+		ExcludedAssignees: []string{"gerente@example.com"},
+	}
+	profiles, err := parseTeamProfiles("Time:\n  focusFactor: 0.6\n  buffer: 0\n  childTypes: Bug\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	team := base.withProfile(&profiles[0])
+	if team.FocusFactor != 0.6 || team.DueDateBuffer != (generationBuffer{}) || !reflect.DeepEqual(team.childTypes(), []string{"Bug"}) {
+		t.Errorf("perfil não aplicado: %+v", team)
+	}
+	// O que o perfil não define continua vindo do ambiente
+	if !reflect.DeepEqual(team.WorkItemTypes, base.WorkItemTypes) || !reflect.DeepEqual(team.ExcludedAssignees, base.ExcludedAssignees) || team.writeField() != dueDateField {
+		t.Errorf("configuração do ambiente perdida: %+v", team)
+	}
+	if base.FocusFactor != 0.8 || base.Profile != nil {
+		t.Errorf("ambiente alterado: %+v", base)
+	}
+}
+
+func TestParseBufferSetting(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    generationBuffer
+		wantErr bool
+	}{
+		{value: "0"},
+		{value: "15%", want: generationBuffer{Percent: 15}},
+		{value: " 2d ", want: generationBuffer{Days: 2}},
+		{value: "15", wantErr: true},
+		{value: "-1d", wantErr: true},
+		{value: "2h", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseBufferSetting(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseBufferSetting(%q) = %+v, %v; quer %+v, erro %t", tt.value, got, err, tt.want, tt.wantErr)
+		}
+		if err == nil && strings.TrimSpace(tt.value) != got.String() {
+			t.Errorf("String() = %q, quer %q", got.String(), strings.TrimSpace(tt.value))
+		}
+	}
+}
+
+func TestExcludedAssignee(t *testing.T) {
+	excluded := []string{"gerente@example.com", "Bot de Build"}
+	tests := []struct {
+		name     string
+		identity *Identity
+		want     bool
+	}{
+		{name: "sem responsável", identity: nil},
+		{name: "pelo e-mail", identity: &Identity{DisplayName: "Gerente", UniqueName: "GERENTE@example.com"}, want: true},
+		{name: "pelo nome de exibição", identity: &Identity{DisplayName: "bot de build"}, want: true},
+		{name: "outra pessoa", identity: &Identity{DisplayName: "Ana", UniqueName: "ana@example.com"}},
+	}
+	for _, tt := range tests {
+		if got := excludedAssignee(tt.identity, excluded); got != tt.want {
+			t.Errorf("%s: excluído = %t, quer %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Função para montar as configurações dos times com o arquivo informado
+func newTestTeamConfigs(t *testing.T, data string, projectTeams ...core.WebApiTeam) *teamConfigs {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "teams.yaml")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	pool := newAdoPool(nil, 4)
+	pool.core = &fakeCoreClient{teams: projectTeams}
+	base := &Config{Project: "Projeto", Team: "id-padrao", TeamName: "Time Padrão", WorkItemTypes: []string{"User Story"}, FocusFactor: 0.8, TeamsConfigFile: path}
+	teams, err := newTeamConfigs(context.Background(), pool, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return teams
+}
+
+func TestTeamConfigsRoute(t *testing.T) {
+	mobile := fakeTeam("Time Mobile")
+	teams := newTestTeamConfigs(t, "time padrão:\n  buffer: 1d\ntime mobile:\n  focusFactor: 0.5\n", fakeTeam("Time Padrão"), mobile)
+
+	built := 0
+	handler := teams.route(func(cfg *Config) http.HandlerFunc {
+		built++
+		return func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"team": cfg.Team, "focusFactor": cfg.FocusFactor, "buffer": cfg.DueDateBuffer.String()})
+		}
+	})
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantTeam   string
+		wantFocus  float64
+		wantBuffer string
+	}{
+		{name: "sem ?team= usa o padrão", wantStatus: http.StatusOK, wantTeam: "id-padrao", wantFocus: 0.8, wantBuffer: "1d"},
+		{name: "pelo nome", query: "?team=TIME%20MOBILE", wantStatus: http.StatusOK, wantTeam: mobile.Id.String(), wantFocus: 0.5, wantBuffer: "0"},
+		{name: "pelo ID", query: "?team=" + mobile.Id.String(), wantStatus: http.StatusOK, wantTeam: mobile.Id.String(), wantFocus: 0.5, wantBuffer: "0"},
+		{name: "time não configurado", query: "?team=Outro", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler(recorder, httptest.NewRequest(http.MethodGet, "/sprints"+tt.query, nil))
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, quer %d: %s", recorder.Code, tt.wantStatus, recorder.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				if !strings.Contains(recorder.Body.String(), "Time Mobile, Time Padrão") {
+					t.Errorf("resposta = %s, quer os times disponíveis", recorder.Body.String())
+				}
+				return
+			}
+			var body struct {
+				Team        string  `json:"team"`
+				FocusFactor float64 `json:"focusFactor"`
+				Buffer      string  `json:"buffer"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Team != tt.wantTeam || body.FocusFactor != tt.wantFocus || body.Buffer != tt.wantBuffer {
+				t.Errorf("resposta = %+v, quer time %s, foco %g e folga %s", body, tt.wantTeam, tt.wantFocus, tt.wantBuffer)
+			}
+		})
+	}
+	if built != 2 {
+		t.Errorf("handlers montados = %d, quer um por time", built)
+	}
+
+	// Uma releitura com erro mantém a configuração anterior
+	if err := os.WriteFile(teams.base.TeamsConfigFile, []byte("time mobile:\n  focus: 0.5\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := teams.reload(context.Background()); err == nil || !strings.Contains(err.Error(), "linha 2") {
+		t.Errorf("erro = %v, quer a linha da chave desconhecida", err)
+	}
+	if cfg, ok := teams.lookup("time mobile"); !ok || cfg.FocusFactor != 0.5 {
+		t.Errorf("configuração anterior perdida: %+v", cfg)
+	}
+}
+
+func TestTeamConfigsUnknownTeam(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "teams.yaml")
+	if err := os.WriteFile(path, []byte("Time Inexistente:\n  buffer: 1d\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	pool := newAdoPool(nil, 4)
+	pool.core = &fakeCoreClient{teams: []core.WebApiTeam{fakeTeam("Time Padrão")}}
+	base := &Config{Project: "Projeto", Team: "id-padrao", TeamName: "Time Padrão", TeamsConfigFile: path}
+	_, err := newTeamConfigs(context.Background(), pool, base)
+	if err == nil || !strings.Contains(err.Error(), "linha 1") || !strings.Contains(err.Error(), "Time Inexistente") {
+		t.Errorf("erro = %v, quer o time não encontrado com a linha", err)
+	}
+}
+
+func TestHandleConfig(t *testing.T) {
+	teams := newTestTeamConfigs(t, "Time Mobile:\n  childTypes: [Task, Bug]\n  dueDateWriteField: Custom.CommittedDate\n", fakeTeam("Time Mobile"))
+
+	recorder := httptest.NewRecorder()
+	handleConfig(teams)(recorder, httptest.NewRequest(http.MethodGet, "/config?team=Time%20Mobile", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var got TeamConfigResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Team != "Time Mobile" || got.Default || !reflect.DeepEqual(got.Overrides, []string{"childTypes", "dueDateWriteField"}) {
+		t.Errorf("time = %+v", got)
+	}
+	if !reflect.DeepEqual(got.ChildTypes, []string{"Task", "Bug"}) || got.DueDateWriteField != "Custom.CommittedDate" || got.FocusFactor != 0.8 || got.Buffer != "0" {
+		t.Errorf("configuração efetiva = %+v", got)
+	}
+	if !reflect.DeepEqual(got.Teams, []string{"Time Mobile", "Time Padrão"}) || got.LoadedAt.IsZero() {
+		t.Errorf("times = %q, carregado em %v", got.Teams, got.LoadedAt)
+	}
+
+	recorder = httptest.NewRecorder()
+	handleConfig(teams)(recorder, httptest.NewRequest(http.MethodGet, "/config", nil))
+	if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Team != "Time Padrão" || !got.Default || len(got.Overrides) != 0 || !reflect.DeepEqual(got.ChildTypes, []string{defaultChildType}) || got.DueDateWriteField != dueDateField {
+		t.Errorf("time padrão = %+v", got)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/core"
+)
+
+// Quantidade de times pedida por página ao listar os times do projeto
+const teamsPageSize = 100
+
+// Função para listar todos os times do projeto, página a página
+func listTeams(ctx context.Context, coreClient core.Client, project string) ([]core.WebApiTeam, error) {
+	var teams []core.WebApiTeam
+	top := teamsPageSize
+	for skip := 0; ; skip += top {
+		skip := skip
+		page, err := coreClient.GetTeams(ctx, core.GetTeamsArgs{
+			ProjectId: &project,
+			Top:       &top,
+			Skip:      &skip,
+		})
+		if err != nil {
+			return nil, wrapAdoError(err, "GetTeams", "project=%s", project)
+		}
+		if page == nil {
+			break
+		}
+		teams = append(teams, *page...)
+		if len(*page) < top {
+			break
+		}
+	}
+	return teams, nil
+}
+
+// Função para encontrar o time configurado ignorando espaços nas pontas e
+// diferenças de maiúsculas/minúsculas. A correspondência exata tem prioridade.
+func matchTeam(teams []core.WebApiTeam, configured string) *core.WebApiTeam {
+	wanted := strings.TrimSpace(configured)
+	var caseInsensitive *core.WebApiTeam
+	for i := range teams {
+		if teams[i].Name == nil || teams[i].Id == nil {
+			continue
+		}
+		if *teams[i].Name == wanted {
+			return &teams[i]
+		}
+		if caseInsensitive == nil && strings.EqualFold(*teams[i].Name, wanted) {
+			caseInsensitive = &teams[i]
+		}
+	}
+	return caseInsensitive
+}
+
+// Função para resolver AZURE_DEVOPS_TEAM contra os times do projeto na
+// inicialização. Em caso de sucesso, cfg.Team passa a ser o ID do time (usado em
+// todas as chamadas) e cfg.TeamName guarda o nome canônico. Falhas transitórias
+// do Azure DevOps são tentadas novamente uma vez.
+func resolveTeam(ctx context.Context, pool *adoPool, cfg *Config) error {
+	coreClient, err := pool.Core(ctx, "resolve-team")
+	if err != nil {
+		return wrapAdoError(err, "core.NewClient", "")
+	}
+
+	teams, err := listTeams(ctx, coreClient, cfg.Project)
+	if errors.Is(err, ErrAdoUnavailable) {
+		log.Printf("[WARN] Falha transitória ao listar times, tentando novamente: %v", err)
+		teams, err = listTeams(ctx, coreClient, cfg.Project)
+	}
+	if err != nil {
+		return err
+	}
+	return applyTeamMatch(teams, cfg)
+}
+
+// Função para trocar o nome configurado em cfg.Team pelo ID do time
+// correspondente entre teams, guardando o nome canônico em cfg.TeamName
+func applyTeamMatch(teams []core.WebApiTeam, cfg *Config) error {
+	team := matchTeam(teams, cfg.Team)
+	if team == nil {
+		names := make([]string, 0, len(teams))
+		for _, candidate := range teams {
+			if candidate.Name != nil {
+				names = append(names, *candidate.Name)
+			}
+		}
+		sort.Strings(names)
+		return fmt.Errorf("Time '%s' não encontrado no projeto '%s'. Times disponíveis: %s",
+			cfg.Team, cfg.Project, strings.Join(names, ", "))
+	}
+
+	if *team.Name != cfg.Team {
+		log.Printf("[WARN] Nome do time corrigido de %q para %q", cfg.Team, *team.Name)
+	}
+	cfg.TeamName = *team.Name
+	cfg.Team = team.Id.String()
+	log.Printf("[DEBUG] Time resolvido: %s (%s)", cfg.TeamName, cfg.Team)
+	return nil
+}
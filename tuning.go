@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+// Quantidade padrão e máxima de sprints encerradas analisadas (?lookback=)
+const (
+	defaultTuningLookback = 4
+	maxTuningLookback     = 12
+)
+
+// Mínimo de sprints com dados para sugerir um FOCUS_FACTOR; com menos, a
+// sugestão seria extrapolada de uma ou duas sprints
+const minTuningSprints = 3
+
+// Distância relativa à mediana a partir da qual a razão de uma sprint é
+// tratada como ponto fora da curva (0.5 = metade da mediana)
+const tuningOutlierDeviation = 0.5
+
+// Coeficiente de variação acima do qual a sugestão vem com aviso de dispersão
+const tuningHighVariation = 0.25
+
+// Sprint encerrada avaliada por GET /tuning. Ratio é a vazão dividida pela
+// capacidade bruta; nil quando a sprint fica fora do ajuste (Excluded).
+type TuningSprint struct {
+	Sprint      string    `json:"sprint"`
+	SprintID    string    `json:"sprintId"`
+	SprintStart time.Time `json:"sprintStart"`
+	SprintEnd   time.Time `json:"sprintEnd"`
+	Closed      int       `json:"closed"`
+	// Horas entregues: CompletedWork das tasks das User Stories fechadas; sem
+	// tasks com CompletedWork, Story Points × HOURS_PER_STORY_POINT
+	ThroughputHours float64 `json:"throughputHours"`
+	// Fechadas sem CompletedWork nem Story Points, fora da vazão
+	Unestimated []int `json:"unestimated,omitempty"`
+	// Capacidade do Azure DevOps na sprint (soma das atividades × dias úteis,
+	// descontadas as folgas), sem FOCUS_FACTOR
+	RawCapacityHours float64  `json:"rawCapacityHours"`
+	Ratio            *float64 `json:"ratio"`
+	// Percentual no prazo de /metrics/due-date-accuracy; nil sem datas para comparar
+	OnTimePercent *float64 `json:"onTimePercent"`
+	Outlier       bool     `json:"outlier,omitempty"`
+	Excluded      string   `json:"excluded,omitempty"`
+	// Contagens da precisão, pela data atual e pela gerada, para os totais
+	// de GET /stats/annual; fora do JSON
+	accuracy, generatedAccuracy *AccuracyStats
+}
+
+// Resposta de GET /tuning. A sugestão é só consultiva: FOCUS_FACTOR não é
+// alterado.
+type TuningReport struct {
+	Lookback             int      `json:"lookback"`
+	CurrentFocusFactor   float64  `json:"currentFocusFactor"`
+	SuggestedFocusFactor *float64 `json:"suggestedFocusFactor"`
+	SprintsUsed          int      `json:"sprintsUsed"`
+	// Média e variância das razões das sprints usadas
+	MeanRatio        *float64       `json:"meanRatio"`
+	Variance         *float64       `json:"variance"`
+	OutliersExcluded []string       `json:"outliersExcluded"`
+	Notes            []string       `json:"notes"`
+	Sprints          []TuningSprint `json:"sprints"`
+	Warnings         []string       `json:"warnings"`
+}
+
+// Resultado do ajuste das razões
+type focusFactorFit struct {
+	Suggested *float64
+	Mean      *float64
+	Variance  *float64
+	Used      int
+	Outliers  []string
+	Notes     []string
+}
+
+// Função para ajustar o FOCUS_FACTOR às razões das sprints: descarta as que
+// se afastam da mediana mais que tuningOutlierDeviation (marcando Outlier) e
+// sugere a média das restantes, limitada a 1. Com menos de minTuningSprints
+// sprints utilizáveis não há sugestão, só a nota explicando por quê.
+func fitFocusFactor(rows []TuningSprint) focusFactorFit {
+	var fit focusFactorFit
+	var candidates []int
+	for i, row := range rows {
+		if row.Ratio != nil {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) < minTuningSprints {
+		fit.Notes = append(fit.Notes, fmt.Sprintf("Histórico insuficiente: %d sprints encerradas com dados, mínimo %d; nenhuma sugestão", len(candidates), minTuningSprints))
+		return fit
+	}
+
+	ratios := make([]float64, len(candidates))
+	for i, index := range candidates {
+		ratios[i] = *rows[index].Ratio
+	}
+	sort.Float64s(ratios)
+	median := percentile(ratios, 0.5)
+
+	var used []float64
+	for _, index := range candidates {
+		ratio := *rows[index].Ratio
+		if median > 0 && math.Abs(ratio-median) > tuningOutlierDeviation*median {
+			rows[index].Outlier = true
+			fit.Outliers = append(fit.Outliers, rows[index].Sprint)
+			continue
+		}
+		used = append(used, ratio)
+	}
+	fit.Used = len(used)
+	if len(fit.Outliers) > 0 {
+		fit.Notes = append(fit.Notes, fmt.Sprintf("%d sprints fora da curva descartadas (razão a mais de %.0f%% da mediana %.2f)", len(fit.Outliers), tuningOutlierDeviation*100, median))
+	}
+	if len(used) < minTuningSprints {
+		fit.Notes = append(fit.Notes, fmt.Sprintf("Histórico insuficiente: %d sprints utilizáveis depois de descartar as fora da curva, mínimo %d; nenhuma sugestão", len(used), minTuningSprints))
+		return fit
+	}
+
+	mean := 0.0
+	for _, ratio := range used {
+		mean += ratio
+	}
+	mean /= float64(len(used))
+	variance := 0.0
+	for _, ratio := range used {
+		variance += (ratio - mean) * (ratio - mean)
+	}
+	variance /= float64(len(used))
+	fit.Mean, fit.Variance = &mean, &variance
+	if mean <= 0 {
+		fit.Notes = append(fit.Notes, "Nenhuma hora entregue nas sprints analisadas; nenhuma sugestão")
+		return fit
+	}
+
+	suggested := math.Round(mean*100) / 100
+	if suggested > 1 {
+		suggested = 1
+		fit.Notes = append(fit.Notes, fmt.Sprintf("A vazão passou da capacidade bruta (média %.2f); sugestão limitada a 1. Confira se as capacidades da sprint estão preenchidas", mean))
+	}
+	if suggested <= 0 {
+		suggested = 0.01
+	}
+	fit.Suggested = &suggested
+	fit.Notes = append(fit.Notes, fmt.Sprintf("Sugestão a partir de %d sprints: média %.2f, desvio padrão %.2f", len(used), mean, math.Sqrt(variance)))
+	if math.Sqrt(variance)/mean > tuningHighVariation {
+		fit.Notes = append(fit.Notes, "Variação alta entre as sprints; use a sugestão com cautela")
+	}
+	return fit
+}
+
+// Função para somar as horas entregues pelas User Stories fechadas: o
+// CompletedWork das tasks delas ou, sem ele, Story Points × horas por ponto.
+// Devolve também as que não têm nenhum dos dois.
+func throughputHours(closed []WorkItem, completedWork map[int]float64, hoursPerStoryPoint float64) (float64, []int) {
+	total := 0.0
+	var unestimated []int
+	for _, story := range closed {
+		if hours, ok := completedWork[story.ID]; ok {
+			total += hours
+			continue
+		}
+		if story.StoryPoints != nil {
+			total += *story.StoryPoints * hoursPerStoryPoint
+			continue
+		}
+		unestimated = append(unestimated, story.ID)
+	}
+	return total, unestimated
+}
+
+// Função para somar o CompletedWork das tasks (inclusive fechadas) de cada
+// User Story; só entram as que têm pelo menos uma task com CompletedWork
+func storyCompletedWork(ctx context.Context, witClient workitemtracking.Client, cfg *Config, storyIds []int) (map[int]float64, error) {
+	completed := make(map[int]float64)
+	if len(storyIds) == 0 {
+		return completed, nil
+	}
+	tasks, err := queryChildTasks(ctx, witClient, cfg, storyIds, []string{"System.Parent", "Microsoft.VSTS.Scheduling.CompletedWork"}, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		parent := getFieldFloat(task.Fields, "System.Parent")
+		hours := getFieldFloat(task.Fields, "Microsoft.VSTS.Scheduling.CompletedWork")
+		if parent == nil || hours == nil {
+			continue
+		}
+		completed[int(*parent)] += *hours
+	}
+	return completed, nil
+}
+
+// Função para listar as últimas n sprints já encerradas (fim antes de hoje),
+// da mais antiga para a mais recente
+func completedSprints(ctx context.Context, workClient work.Client, cfg *Config, today time.Time, n int) ([]work.TeamSettingsIteration, error) {
+	result, err := workClient.GetTeamIterations(ctx, work.GetTeamIterationsArgs{
+		Project: &cfg.Project,
+		Team:    &cfg.Team,
+	})
+	if err != nil {
+		return nil, wrapAdoError(err, "GetTeamIterations", "team=%s", cfg.TeamName)
+	}
+	var past []work.TeamSettingsIteration
+	if result != nil {
+		for _, iteration := range *result {
+			if iteration.Name == nil || iteration.Id == nil {
+				continue
+			}
+			if _, end, err := sprintDates(&iteration); err == nil && end.Before(today) {
+				past = append(past, iteration)
+			}
+		}
+	}
+	sort.SliceStable(past, func(i, j int) bool {
+		return past[i].Attributes.FinishDate.Time.Before(past[j].Attributes.FinishDate.Time)
+	})
+	if len(past) > n {
+		past = past[len(past)-n:]
+	}
+	return past, nil
+}
+
+// Função para avaliar uma sprint encerrada: vazão das User Stories fechadas,
+// capacidade bruta do time e o percentual no prazo
+func evaluateTuningSprint(ctx context.Context, workClient work.Client, witClient workitemtracking.Client, cfg *Config, runs *runStore, iteration *work.TeamSettingsIteration, types []string, keepArea func(string) bool, cal workCalendar) (TuningSprint, []string, error) {
+	sprintStart, sprintEnd, _ := sprintDates(iteration)
+	row := TuningSprint{Sprint: *iteration.Name, SprintID: iteration.Id.String(), SprintStart: sprintStart, SprintEnd: sprintEnd}
+
+	stories, _, missing, err := fetchSprintStories(ctx, workClient, witClient, cfg, iteration, types, nil)
+	if err != nil {
+		return row, nil, err
+	}
+	accuracy, err := dueDateAccuracy(ctx, witClient, cfg, runs, iteration, stories, keepArea, cal, defaultAccuracyTop)
+	if err != nil {
+		return row, nil, err
+	}
+	warnings := append(missingWorkItemWarnings(missing), accuracy.Warnings...)
+	if accuracy.Accuracy != nil {
+		row.OnTimePercent = &accuracy.Accuracy.OnTimePercent
+	}
+	row.accuracy, row.generatedAccuracy = accuracy.Accuracy, accuracy.GeneratedAccuracy
+
+	var closed []WorkItem
+	var closedIds []int
+	for _, story := range stories {
+		if !story.Removed && isDoneState(cfg, story.State) && keepArea(story.AreaPath) {
+			closed = append(closed, story)
+			closedIds = append(closedIds, story.ID)
+		}
+	}
+	row.Closed = len(closed)
+	completed, err := storyCompletedWork(ctx, witClient, cfg, closedIds)
+	if err != nil {
+		return row, nil, err
+	}
+	row.ThroughputHours, row.Unestimated = throughputHours(closed, completed, cfg.HoursPerStoryPoint)
+
+	capacities, err := fetchTeamCapacities(ctx, workClient, cfg, iteration)
+	if err != nil {
+		return row, nil, err
+	}
+	teamDaysOff, err := fetchTeamDaysOff(ctx, workClient, cfg, iteration)
+	if err != nil {
+		return row, nil, err
+	}
+	for _, capacity := range capacities {
+		total, err := memberTotalCapacity(capacity, sprintStart, sprintEnd, teamDaysOff, cal)
+		if err != nil {
+			return row, nil, err
+		}
+		row.RawCapacityHours += total
+	}
+
+	switch {
+	case row.RawCapacityHours <= 0:
+		row.Excluded = "sem capacidade configurada na sprint"
+	case row.Closed == 0:
+		row.Excluded = "nenhuma User Story fechada"
+	default:
+		ratio := row.ThroughputHours / row.RawCapacityHours
+		row.Ratio = &ratio
+	}
+	return row, warnings, nil
+}
+
+// GET /tuning: sugere um FOCUS_FACTOR a partir da razão entre a vazão e a
+// capacidade bruta das últimas sprints encerradas. Nada é alterado.
+func handleTuning(pool *adoPool, cfg *Config, runs *runStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lookback := defaultTuningLookback
+		if value := r.URL.Query().Get("lookback"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 || parsed > maxTuningLookback {
+				jsonError(w, fmt.Sprintf("Parâmetro 'lookback' inválido: %q (use um inteiro entre 1 e %d)", value, maxTuningLookback), http.StatusBadRequest)
+				return
+			}
+			lookback = parsed
+		}
+		cal, err := calendarForRequest(cfg, r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		keepArea, err := areaPathFilterFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		types, _ := typesFromRequest(cfg, r)
+
+		ctx := r.Context()
+		workClient, err := pool.Work(ctx, "tuning")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
+			return
+		}
+		witClient, err := pool.WorkItems(ctx, "tuning")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+		iterations, err := completedSprints(ctx, workClient, cfg, cal.dateOf(time.Now()), lookback)
+		if err != nil {
+			respondError(w, "Erro ao buscar sprints", err)
+			return
+		}
+
+		report := TuningReport{
+			Lookback:           lookback,
+			CurrentFocusFactor: cfg.FocusFactor,
+			OutliersExcluded:   []string{},
+			Sprints:            []TuningSprint{},
+			Warnings:           []string{},
+		}
+		for i := range iterations {
+			row, warnings, err := evaluateTuningSprint(ctx, workClient, witClient, cfg, runs, &iterations[i], types, keepArea, cal)
+			if err != nil {
+				respondError(w, fmt.Sprintf("Erro ao avaliar a sprint '%s'", *iterations[i].Name), err)
+				return
+			}
+			report.Sprints = append(report.Sprints, row)
+			report.Warnings = append(report.Warnings, warnings...)
+		}
+
+		fit := fitFocusFactor(report.Sprints)
+		report.SuggestedFocusFactor, report.MeanRatio, report.Variance = fit.Suggested, fit.Mean, fit.Variance
+		report.SprintsUsed = fit.Used
+		if fit.Outliers != nil {
+			report.OutliersExcluded = fit.Outliers
+		}
+		report.Notes = append(fit.Notes, "Só uma sugestão: FOCUS_FACTOR continua como configurado até ser alterado no ambiente")
+
+		log.Printf("[DEBUG] Ajuste do FOCUS_FACTOR: %d sprints analisadas, %d usadas", len(report.Sprints), report.SprintsUsed)
+		writeJSON(w, http.StatusOK, report)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+)
+
+// Função para montar as sprints avaliadas a partir das razões; nil fica fora do ajuste
+func tuningRows(ratios ...*float64) []TuningSprint {
+	rows := make([]TuningSprint, len(ratios))
+	for i, ratio := range ratios {
+		rows[i] = TuningSprint{Sprint: "Sprint " + string(rune('A'+i)), Ratio: ratio}
+		if ratio == nil {
+			rows[i].Excluded = "sem capacidade configurada na sprint"
+		}
+	}
+	return rows
+}
+
+func TestFitFocusFactor(t *testing.T) {
+	tests := []struct {
+		name          string
+		rows          []TuningSprint
+		wantSuggested *float64
+		wantUsed      int
+		wantOutliers  []string
+	}{
+		{
+			name:     "uma sprint não basta",
+			rows:     tuningRows(ptrFloat(0.7)),
+			wantUsed: 0,
+		},
+		{
+			name:     "sprints sem dados não contam para o mínimo",
+			rows:     tuningRows(ptrFloat(0.7), nil, ptrFloat(0.6), nil),
+			wantUsed: 0,
+		},
+		{
+			name:          "média das razões",
+			rows:          tuningRows(ptrFloat(0.6), ptrFloat(0.7), ptrFloat(0.65), ptrFloat(0.7)),
+			wantSuggested: ptrFloat(0.66),
+			wantUsed:      4,
+		},
+		{
+			name:          "ponto fora da curva descartado",
+			rows:          tuningRows(ptrFloat(0.6), ptrFloat(0.7), ptrFloat(2.0), ptrFloat(0.65)),
+			wantSuggested: ptrFloat(0.65),
+			wantUsed:      3,
+			wantOutliers:  []string{"Sprint C"},
+		},
+		{
+			name:         "sem sprints suficientes depois do descarte",
+			rows:         tuningRows(ptrFloat(0.6), ptrFloat(0.1), ptrFloat(0.65)),
+			wantUsed:     2,
+			wantOutliers: []string{"Sprint B"},
+		},
+		{
+			name:          "vazão acima da capacidade fica limitada a 1",
+			rows:          tuningRows(ptrFloat(1.1), ptrFloat(1.2), ptrFloat(1.15)),
+			wantSuggested: ptrFloat(1),
+			wantUsed:      3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fit := fitFocusFactor(tt.rows)
+			if !reflect.DeepEqual(fit.Suggested, tt.wantSuggested) {
+				t.Errorf("sugestão = %v, quer %v", formatFloatPtr(fit.Suggested), formatFloatPtr(tt.wantSuggested))
+			}
+			if fit.Used != tt.wantUsed {
+				t.Errorf("sprints usadas = %d, quer %d", fit.Used, tt.wantUsed)
+			}
+			if !reflect.DeepEqual(fit.Outliers, tt.wantOutliers) {
+				t.Errorf("fora da curva = %v, quer %v", fit.Outliers, tt.wantOutliers)
+			}
+			if len(fit.Notes) == 0 {
+				t.Error("o ajuste deveria explicar o resultado em notes")
+			}
+			for _, row := range tt.rows {
+				if row.Outlier != slices.Contains(tt.wantOutliers, row.Sprint) {
+					t.Errorf("%s: outlier = %t", row.Sprint, row.Outlier)
+				}
+			}
+		})
+	}
+}
+
+func TestThroughputHours(t *testing.T) {
+	points := 3.0
+	closed := []WorkItem{
+		{ID: 1},                       // tasks com CompletedWork
+		{ID: 2, StoryPoints: &points}, // sem tasks: Story Points × horas
+		{ID: 3, StoryPoints: &points}, // CompletedWork tem precedência
+		{ID: 4},                       // sem nada
+	}
+	total, unestimated := throughputHours(closed, map[int]float64{1: 10, 3: 5}, 8)
+	if total != 10+24+5 {
+		t.Errorf("vazão = %g, quer 39", total)
+	}
+	if !reflect.DeepEqual(unestimated, []int{4}) {
+		t.Errorf("sem estimativa = %v, quer [4]", unestimated)
+	}
+}
+
+func TestCompletedSprints(t *testing.T) {
+	today := day(2024, 4, 10)
+	var iterations []work.TeamSettingsIteration
+	for i, start := range []int{1, 15, 29} {
+		iterations = append(iterations, fakeIteration("Sprint "+string(rune('1'+i)), day(2024, 2, start), day(2024, 2, start).AddDate(0, 0, 11), ""))
+	}
+	current := fakeIteration("Sprint 4", day(2024, 4, 1), day(2024, 4, 12), "")
+	undated := fakeIteration("Sem datas", day(2024, 1, 1), day(2024, 1, 12), "")
+	undated.Attributes = nil
+	// Fora de ordem cronológica na resposta do Azure DevOps
+	workClient := &fakeWorkClient{iterations: []work.TeamSettingsIteration{iterations[2], current, iterations[0], undated, iterations[1]}}
+	cfg := &Config{Project: "Projeto", Team: "Time"}
+
+	got, err := completedSprints(context.Background(), workClient, cfg, today, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, iteration := range got {
+		names = append(names, *iteration.Name)
+	}
+	if want := []string{"Sprint 2", "Sprint 3"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("sprints = %v, quer %v", names, want)
+	}
+}
@@ -0,0 +1,758 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+// Limite do servidor para a quantidade de IDs em uma chamada ao GetWorkItems
+const maxWorkItemsPerCall = 200
+
+// Estado dos itens removidos do backlog; ficam fora de todas as contagens
+const removedState = "Removed"
+
+// Estado das tasks concluídas; por padrão não contam na carga de /developers
+const closedState = "Closed"
+
+// Função para verificar se um estado corresponde a um item removido
+func isRemovedState(state string) bool {
+	return strings.EqualFold(state, removedState)
+}
+
+// Campos pedidos ao Azure DevOps para montar as User Stories
+var userStoryFields = []string{
+	"System.Title",
+	"System.WorkItemType",
+	"System.State",
+	"Microsoft.VSTS.Scheduling.DueDate",
+	"Microsoft.VSTS.Scheduling.TargetDate",
+	"System.BoardColumn",
+	"System.BoardColumnDone",
+	"Microsoft.VSTS.CMMI.Blocked",
+	"System.AssignedTo",
+	"System.IterationPath",
+	"System.AreaPath",
+	"Microsoft.VSTS.Scheduling.StoryPoints",
+	"Microsoft.VSTS.Scheduling.Effort",
+	"Microsoft.VSTS.Common.StackRank",
+	"Microsoft.VSTS.Common.BacklogPriority",
+	"System.Tags",
+	"System.Parent",
+}
+
+// Datas do ciclo de vida de um work item (?expand=dates), em RFC3339; null
+// quando o Azure DevOps não tem o valor (por exemplo, item ainda não fechado)
+type WorkItemDates struct {
+	CreatedDate   *time.Time `json:"createdDate"`
+	ActivatedDate *time.Time `json:"activatedDate"`
+	ChangedDate   *time.Time `json:"changedDate"`
+	ClosedDate    *time.Time `json:"closedDate"`
+}
+
+// Campos pedidos ao Azure DevOps com ?expand=dates
+var workItemDateFields = []string{
+	"System.CreatedDate",
+	"Microsoft.VSTS.Common.ActivatedDate",
+	"System.ChangedDate",
+	"Microsoft.VSTS.Common.ClosedDate",
+}
+
+// Função para ler as datas do ciclo de vida de um work item
+func workItemDates(fields *map[string]interface{}) *WorkItemDates {
+	return &WorkItemDates{
+		CreatedDate:   getFieldTime(fields, "System.CreatedDate"),
+		ActivatedDate: getFieldTime(fields, "Microsoft.VSTS.Common.ActivatedDate"),
+		ChangedDate:   getFieldTime(fields, "System.ChangedDate"),
+		ClosedDate:    getFieldTime(fields, "Microsoft.VSTS.Common.ClosedDate"),
+	}
+}
+
+// Função para verificar se o tipo do work item está entre os tipos acompanhados
+// (WORK_ITEM_TYPES), ignorando maiúsculas/minúsculas
+func isTrackedType(types []string, workItemType string) bool {
+	for _, tracked := range types {
+		if strings.EqualFold(tracked, workItemType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Função para ler ?types= (tipos separados por vírgula). Sem o parâmetro,
+// valem os tipos configurados em WORK_ITEM_TYPES.
+func typesFromRequest(cfg *Config, r *http.Request) ([]string, bool) {
+	if types := splitList(r.URL.Query().Get("types")); len(types) > 0 {
+		return types, true
+	}
+	return cfg.WorkItemTypes, false
+}
+
+// Função para montar o filtro de estados a partir de ?states= (apenas esses)
+// ou ?excludeStates= (todos menos esses). Sem parâmetros, aceita tudo.
+func stateFilterFromRequest(r *http.Request) (func(state string) bool, error) {
+	states := splitList(r.URL.Query().Get("states"))
+	excluded := splitList(r.URL.Query().Get("excludeStates"))
+	if len(states) > 0 && len(excluded) > 0 {
+		return nil, fmt.Errorf("Use apenas um dos parâmetros 'states' ou 'excludeStates'")
+	}
+	contains := func(list []string, state string) bool {
+		for _, item := range list {
+			if strings.EqualFold(item, state) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(state string) bool {
+		if len(states) > 0 {
+			return contains(states, state)
+		}
+		return !contains(excluded, state)
+	}, nil
+}
+
+// Função para montar o filtro de ?areaPath=: a área e as subáreas, ou só a
+// área exata com ?areaPathExact=true. Sem areaPath, aceita tudo.
+func areaPathFilterFromRequest(r *http.Request) (func(areaPath string) bool, error) {
+	wanted := normalizeAreaPath(r.URL.Query().Get("areaPath"))
+	exact := false
+	if value := r.URL.Query().Get("areaPathExact"); value != "" {
+		var err error
+		exact, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("Parâmetro 'areaPathExact' inválido: %q", value)
+		}
+	}
+	if wanted == "" {
+		if exact {
+			return nil, fmt.Errorf("Parâmetro 'areaPathExact' exige 'areaPath'")
+		}
+		return func(string) bool { return true }, nil
+	}
+	return func(areaPath string) bool {
+		areaPath = normalizeAreaPath(areaPath)
+		return areaPath == wanted || (!exact && strings.HasPrefix(areaPath, wanted+`\`))
+	}, nil
+}
+
+// Função para comparar caminhos de área sem diferenciar maiúsculas, barras
+// ou barras nas pontas
+func normalizeAreaPath(path string) string {
+	path = strings.ReplaceAll(strings.TrimSpace(path), "/", `\`)
+	return strings.ToLower(strings.Trim(path, `\`))
+}
+
+// Função para ordenar as User Stories por ?sort=priority|id|title|dueDate e
+// ?order=asc|desc. O padrão é prioridade crescente, a ordem em que o gerador
+// deve consumir os itens. Itens sem o valor de ordenação (sem rank ou sem
+// data) vão sempre para o fim, ordenados por ID.
+func sortWorkItems(items []WorkItem, sortKey string, order string) error {
+	if sortKey == "" {
+		sortKey = "priority"
+	}
+	var descending bool
+	switch strings.ToLower(order) {
+	case "", "asc":
+	case "desc":
+		descending = true
+	default:
+		return fmt.Errorf("Parâmetro 'order' inválido: %q (use asc ou desc)", order)
+	}
+
+	// compare devolve <0, 0 ou >0; missing indica itens sem valor de ordenação
+	var compare func(a, b WorkItem) int
+	var missing func(item WorkItem) bool
+	switch sortKey {
+	case "priority":
+		missing = func(item WorkItem) bool { return item.StackRank == nil }
+		compare = func(a, b WorkItem) int {
+			switch {
+			case *a.StackRank < *b.StackRank:
+				return -1
+			case *a.StackRank > *b.StackRank:
+				return 1
+			}
+			return 0
+		}
+	case "id":
+		missing = func(item WorkItem) bool { return false }
+		compare = func(a, b WorkItem) int { return a.ID - b.ID }
+	case "title":
+		missing = func(item WorkItem) bool { return false }
+		compare = func(a, b WorkItem) int { return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title)) }
+	case "dueDate":
+		missing = func(item WorkItem) bool { return item.DueDate == nil }
+		compare = func(a, b WorkItem) int { return a.DueDate.Compare(*b.DueDate) }
+	default:
+		return fmt.Errorf("Parâmetro 'sort' inválido: %q (use priority, id, title ou dueDate)", sortKey)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if missing(a) || missing(b) {
+			if missing(a) && missing(b) {
+				return a.ID < b.ID
+			}
+			return missing(b)
+		}
+		result := compare(a, b)
+		if result == 0 {
+			return a.ID < b.ID
+		}
+		if descending {
+			return result > 0
+		}
+		return result < 0
+	})
+	return nil
+}
+
+// Função para validar os tipos pedidos contra os tipos presentes na sprint.
+// Devolve erro com a lista de tipos existentes quando algum pedido não aparece.
+func validateRequestedTypes(requested []string, workItems []workitemtracking.WorkItem) error {
+	present := make(map[string]bool)
+	for _, workItem := range workItems {
+		if workItemType := getFieldValue(workItem.Fields, "System.WorkItemType"); workItemType != "" {
+			present[workItemType] = true
+		}
+	}
+	if len(present) == 0 {
+		return nil
+	}
+
+	var unknown []string
+	for _, workItemType := range requested {
+		found := false
+		for presentType := range present {
+			if strings.EqualFold(presentType, workItemType) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, workItemType)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	presentTypes := make([]string, 0, len(present))
+	for workItemType := range present {
+		presentTypes = append(presentTypes, workItemType)
+	}
+	sort.Strings(presentTypes)
+	return fmt.Errorf("Tipos não encontrados na sprint: %s. Tipos presentes: %s",
+		strings.Join(unknown, ", "), strings.Join(presentTypes, ", "))
+}
+
+// Função para listar os nomes dos tipos de work item do projeto
+func projectTypeNames(ctx context.Context, witClient workitemtracking.Client, project string) ([]string, error) {
+	workItemTypes, err := witClient.GetWorkItemTypes(ctx, workitemtracking.GetWorkItemTypesArgs{Project: &project})
+	if err != nil {
+		return nil, wrapAdoError(err, "GetWorkItemTypes", "project=%s", project)
+	}
+	var names []string
+	if workItemTypes != nil {
+		for _, workItemType := range *workItemTypes {
+			if workItemType.Name != nil {
+				names = append(names, *workItemType.Name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// Função para validar os tipos pedidos contra os tipos do projeto, para
+// consultas que não baixam a sprint inteira (como /search). Devolve erro com
+// a lista de tipos existentes quando algum pedido não existe.
+func validateKnownTypes(requested []string, known []string) error {
+	if len(known) == 0 {
+		return nil
+	}
+	var unknown []string
+	for _, workItemType := range requested {
+		if !isTrackedType(known, workItemType) {
+			unknown = append(unknown, workItemType)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sorted := append([]string{}, known...)
+	sort.Strings(sorted)
+	return fmt.Errorf("Tipos não encontrados no projeto: %s. Tipos existentes: %s",
+		strings.Join(unknown, ", "), strings.Join(sorted, ", "))
+}
+
+// Função para extrair os IDs (sem repetição) dos work items vinculados a uma iteração
+func iterationWorkItemIds(response *work.IterationWorkItems) []int {
+	var workItemIds []int
+	if response != nil && response.WorkItemRelations != nil {
+		for _, relation := range *response.WorkItemRelations {
+			if relation.Target != nil && relation.Target.Id != nil {
+				workItemIds = append(workItemIds, *relation.Target.Id)
+			}
+		}
+	}
+	return dedupeIds(workItemIds)
+}
+
+// Função para remover IDs repetidos mantendo a ordem da primeira ocorrência.
+// O ADO pode devolver o mesmo item duas vezes (como raiz e como filho de um link).
+func dedupeIds(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	unique := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	if dropped := len(ids) - len(unique); dropped > 0 {
+		log.Printf("[DEBUG] %d IDs de work item duplicados ignorados na lista da iteração", dropped)
+	}
+	return unique
+}
+
+// Quantidade de blocos do GetWorkItems buscados em paralelo por requisição
+const workItemFetchConcurrency = 4
+
+// Função para buscar work items em blocos de até 200 IDs (limite do ADO), com
+// até workItemFetchConcurrency blocos em paralelo. O resultado mantém a ordem e
+// as posições de ids, como uma única chamada com a política Omit, então
+// presentWorkItems e missingWorkItemIds continuam funcionando com a lista completa.
+func getWorkItemsChunked(ctx context.Context, witClient workitemtracking.Client, project string, ids []int, fields []string) (*[]workitemtracking.WorkItem, error) {
+	chunks := chunkIds(ids, maxWorkItemsPerCall)
+	results := make([][]workitemtracking.WorkItem, len(chunks))
+	errs := make([]error, len(chunks))
+	sem := make(chan struct{}, workItemFetchConcurrency)
+
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk []int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			workItems, err := witClient.GetWorkItems(ctx, workitemtracking.GetWorkItemsArgs{
+				Ids:         &chunk,
+				Fields:      &fields,
+				Project:     &project,
+				ErrorPolicy: &omitMissingWorkItems,
+			})
+			if err != nil {
+				errs[i] = wrapAdoError(err, "GetWorkItems", "ids=%d..%d (%d itens)", chunk[0], chunk[len(chunk)-1], len(chunk))
+				return
+			}
+			if workItems != nil {
+				results[i] = *workItems
+			}
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	merged := make([]workitemtracking.WorkItem, 0, len(ids))
+	for i := range chunks {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		merged = append(merged, results[i]...)
+	}
+	if len(chunks) > 1 {
+		log.Printf("[DEBUG] %d work items buscados em %d blocos", len(ids), len(chunks))
+	}
+	return &merged, nil
+}
+
+// Função para dividir uma lista de IDs em blocos de no máximo size itens
+func chunkIds(ids []int, size int) [][]int {
+	var chunks [][]int
+	for start := 0; start < len(ids); start += size {
+		end := start + size
+		if end > len(ids) {
+			end = len(ids)
+		}
+		chunks = append(chunks, ids[start:end])
+	}
+	return chunks
+}
+
+// Função para buscar os itens de backlog (tipos de types) de uma sprint,
+// montados como em /user-stories e na ordem da iteração. Itens removidos vêm
+// marcados com Removed; cabe a quem chama descartá-los. Devolve também todos
+// os work items da sprint, de qualquer tipo, para validateRequestedTypes, e os
+// IDs que sumiram entre a listagem e a busca de detalhes.
+func fetchSprintStories(ctx context.Context, workClient work.Client, witClient workitemtracking.Client, cfg *Config, iteration *work.TeamSettingsIteration, types []string, backlogRanks map[int]int) ([]WorkItem, []workitemtracking.WorkItem, []int, error) {
+	workItemsResponse, err := workClient.GetIterationWorkItems(ctx, work.GetIterationWorkItemsArgs{
+		Project:     &cfg.Project,
+		Team:        &cfg.Team,
+		IterationId: iteration.Id,
+	})
+	if err != nil {
+		return nil, nil, nil, wrapAdoError(err, "GetIterationWorkItems", "sprint=%s", *iteration.Name)
+	}
+	stories := make([]WorkItem, 0)
+	workItemIds := iterationWorkItemIds(workItemsResponse)
+	if len(workItemIds) == 0 {
+		return stories, nil, nil, nil
+	}
+	workItems, err := getWorkItemsChunked(ctx, witClient, cfg.Project, workItemIds, withExtraFields(userStoryFields, cfg.ExtraFields))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	details := presentWorkItems(workItemIds, workItems)
+	for _, detail := range details {
+		if item, ok := buildUserStory(detail, cfg, types, backlogRanks); ok {
+			stories = append(stories, item)
+		}
+	}
+	return stories, details, missingWorkItemIds(workItemIds, workItems), nil
+}
+
+// Função para converter um work item do Azure DevOps em WorkItem.
+// Retorna false quando o item não é uma User Story.
+func buildUserStory(detail workitemtracking.WorkItem, cfg *Config, types []string, backlogRanks map[int]int) (WorkItem, bool) {
+	workItemType := getFieldValue(detail.Fields, "System.WorkItemType")
+	if !isTrackedType(types, workItemType) {
+		return WorkItem{}, false
+	}
+	log.Printf("Processando User Story #%d", *detail.Id)
+
+	item := WorkItem{
+		ID:      *detail.Id,
+		Title:   getFieldValue(detail.Fields, "System.Title"),
+		Type:    workItemType,
+		State:   getFieldValue(detail.Fields, "System.State"),
+		DueDate: nil,
+	}
+	item.Removed = isRemovedState(item.State)
+	item.AssignedTo = getFieldIdentity(detail.Fields, "System.AssignedTo")
+	if item.AssignedTo != nil {
+		item.AssignedToName = item.AssignedTo.DisplayName
+	}
+	item.StoryPoints = workItemStoryPoints(detail.Fields)
+	// StackRank no processo Agile, BacklogPriority no Scrum
+	item.StackRank = getFieldFloat(detail.Fields, "Microsoft.VSTS.Common.StackRank")
+	if item.StackRank == nil {
+		item.StackRank = getFieldFloat(detail.Fields, "Microsoft.VSTS.Common.BacklogPriority")
+	}
+	item.ExtraFields = extraFieldValues(detail.Fields, cfg.ExtraFields)
+	item.Url = workItemURL(cfg, *detail.Id)
+	item.IterationPath = getFieldValue(detail.Fields, "System.IterationPath")
+	item.AreaPath = getFieldValue(detail.Fields, "System.AreaPath")
+
+	if rank, ok := backlogRanks[*detail.Id]; ok {
+		item.BacklogRank = &rank
+	}
+
+	// Log dos campos disponíveis
+	log.Printf("=== Campos disponíveis para US #%d ===", *detail.Id)
+	for fieldName, fieldValue := range *detail.Fields {
+		log.Printf("[DEBUG] Campo %s = %v (tipo: %T)", fieldName, fieldValue, fieldValue)
+	}
+
+	item.DueDate, item.dueDateField = workItemDueDate(*detail.Id, detail.Fields)
+	if detail.Rev != nil {
+		item.revision = *detail.Rev
+	}
+	item.tags = workItemTags(detail.Fields)
+	item.BoardColumn = getFieldValue(detail.Fields, "System.BoardColumn")
+	if done, ok := getFieldTyped(detail.Fields, "System.BoardColumnDone").(bool); ok && item.BoardColumn != "" {
+		item.BoardColumnDone = &done
+	}
+	item.Blocked = isBlocked(detail.Fields, item.tags, cfg.BlockedTag)
+	if parent := getFieldFloat(detail.Fields, "System.Parent"); parent != nil {
+		item.parentID = int(*parent)
+	}
+
+	return item, true
+}
+
+// Função para ler a estimativa: Story Points no processo Agile, Effort no
+// Scrum; nil quando não estimado
+func workItemStoryPoints(fields *map[string]interface{}) *float64 {
+	if points := getFieldFloat(fields, "Microsoft.VSTS.Scheduling.StoryPoints"); points != nil {
+		return points
+	}
+	return getFieldFloat(fields, "Microsoft.VSTS.Scheduling.Effort")
+}
+
+// Campos lidos, em ordem, para a data de entrega de um work item
+var dueDateFields = []string{
+	"Microsoft.VSTS.Scheduling.DueDate",
+	"Microsoft.VSTS.Scheduling.TargetDate",
+	"Microsoft.VSTS.Common.DueDate",
+}
+
+// Função para ler a data de entrega de um work item: vale o primeiro campo
+// de dueDateFields preenchido. Devolve também o campo usado; nil quando
+// nenhum está preenchido ou o valor não é uma data.
+func workItemDueDate(id int, fields *map[string]interface{}) (*time.Time, string) {
+	var dueDateStr, dueDateField string
+	for _, field := range dueDateFields {
+		dueDateStr = getFieldValue(fields, field)
+		if dueDateStr != "" {
+			dueDateField = field
+			log.Printf("[DEBUG] Data encontrada no campo %s para US #%d: %s", field, id, dueDateStr)
+			break
+		}
+	}
+
+	if dueDateStr == "" {
+		log.Printf("[DEBUG] Nenhuma data encontrada para US #%d nos campos: %v", id, dueDateFields)
+		return nil, ""
+	}
+	log.Printf("[DEBUG] Tentando converter data '%s' para US #%d", dueDateStr, id)
+	dueDate, err := parseDate(dueDateStr)
+	if err != nil {
+		log.Printf("[ERROR] Erro ao converter data '%s' para US #%d: %v", dueDateStr, id, err)
+		return nil, dueDateField
+	}
+	log.Printf("[DEBUG] Data convertida com sucesso para US #%d: %v", id, dueDate)
+	return &dueDate, dueDateField
+}
+
+// Linha do stream NDJSON de /user-stories/stream
+type userStoryStreamLine struct {
+	Kind    string                `json:"kind"`
+	Story   *WorkItem             `json:"story,omitempty"`
+	Rollup  *userStoryRollup      `json:"rollup,omitempty"`
+	Summary *userStoryStreamTotal `json:"summary,omitempty"`
+}
+
+// Totais das tasks de uma User Story, enviados depois de todas as linhas
+// "story" e ligados a elas pelo ID
+type userStoryRollup struct {
+	ID               int     `json:"id"`
+	Tasks            int     `json:"tasks"`
+	OpenTasks        int     `json:"openTasks"`
+	UnestimatedTasks int     `json:"unestimatedTasks"`
+	RemainingWork    float64 `json:"remainingWork"`
+	CompletedWork    float64 `json:"completedWork"`
+}
+
+// Resumo enviado na última linha do stream
+type userStoryStreamTotal struct {
+	Sprint     string   `json:"sprint"`
+	TotalItems int      `json:"totalItems"`
+	Stories    int      `json:"stories"`
+	Rollups    int      `json:"rollups"`
+	Chunks     int      `json:"chunks"`
+	Warnings   []string `json:"warnings"`
+}
+
+// Função para somar as tasks de uma User Story em uma linha de rollup. Só
+// as tasks abertas (fora Closed) contam no trabalho restante.
+func rollupTasks(id int, tasks []Task) userStoryRollup {
+	rollup := userStoryRollup{ID: id, Tasks: len(tasks)}
+	for _, task := range tasks {
+		if task.CompletedWork != nil {
+			rollup.CompletedWork += *task.CompletedWork
+		}
+		if strings.EqualFold(task.State, closedState) || task.Removed {
+			continue
+		}
+		rollup.OpenTasks++
+		if task.RemainingWork == nil {
+			rollup.UnestimatedTasks++
+			continue
+		}
+		rollup.RemainingWork += *task.RemainingWork
+	}
+	return rollup
+}
+
+// Handler de GET /user-stories/stream: emite uma linha NDJSON por User Story
+// à medida que cada bloco do GetWorkItems é resolvido, depois uma linha de
+// rollup das tasks por User Story (chave id) e termina com uma linha de
+// resumo. Cada linha é enviada assim que escrita; o stream para assim que o
+// cliente desconecta, inclusive no meio de um bloco.
+func handleUserStoriesStream(pool *adoPool, cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ref, err := sprintRefFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		keepState, err := stateFilterFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		keepArea, err := areaPathFilterFromRequest(r)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Rollups das tasks vêm por padrão; ?rollup=false envia só as User Stories
+		withRollup := r.URL.Query().Get("rollup") != "false"
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			jsonError(w, "Streaming não suportado pelo servidor", http.StatusInternalServerError)
+			return
+		}
+
+		ctx := r.Context()
+		workClient, err := pool.Work(ctx, "user-stories-stream")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente do Azure DevOps", wrapAdoError(err, "work.NewClient", ""))
+			return
+		}
+
+		targetIteration, err := resolveSprintRef(ctx, workClient, cfg, ref)
+		if err != nil {
+			respondError(w, "", err)
+			return
+		}
+		sprintName := *targetIteration.Name
+		setSprintHeaders(w, targetIteration)
+
+		workItemsResponse, err := workClient.GetIterationWorkItems(ctx, work.GetIterationWorkItemsArgs{
+			Project:     &cfg.Project,
+			Team:        &cfg.Team,
+			IterationId: targetIteration.Id,
+		})
+		if err != nil {
+			respondError(w, "Erro ao buscar work items", wrapAdoError(err, "GetIterationWorkItems", "sprint=%s", sprintName))
+			return
+		}
+
+		witClient, err := pool.WorkItems(ctx, "user-stories-stream")
+		if err != nil {
+			respondError(w, "Erro ao criar cliente de work items", wrapAdoError(err, "workitemtracking.NewClient", ""))
+			return
+		}
+
+		includeRemoved := r.URL.Query().Get("includeRemoved") == "true"
+		types, explicitTypes := typesFromRequest(cfg, r)
+		// Os blocos só chegam depois do 200, então ?types= é validado contra os
+		// tipos do projeto; tipo inexistente é 400, como em /user-stories
+		if explicitTypes {
+			known, err := projectTypeNames(ctx, witClient, cfg.Project)
+			if err != nil {
+				respondError(w, "Erro ao buscar tipos de work item", err)
+				return
+			}
+			if err := validateKnownTypes(types, known); err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		summary := userStoryStreamTotal{Sprint: sprintName, Warnings: []string{}}
+		backlogRanks, err := fetchBacklogRanks(ctx, workClient, cfg)
+		if err != nil {
+			log.Printf("[WARN] Ordem do backlog indisponível: %v", err)
+			summary.Warnings = append(summary.Warnings, "Ordem do backlog indisponível")
+		}
+
+		// A partir daqui o status 200 já foi enviado; falhas viram avisos no resumo
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(w)
+		// Função para escrever e enviar uma linha; false quando o cliente
+		// desconectou ou a escrita falhou
+		send := func(line userStoryStreamLine) bool {
+			if ctx.Err() != nil {
+				log.Printf("Cliente desconectou durante o stream da sprint '%s'", sprintName)
+				return false
+			}
+			if err := encoder.Encode(line); err != nil {
+				log.Printf("Erro ao escrever linha do stream: %v", err)
+				return false
+			}
+			flusher.Flush()
+			return true
+		}
+
+		storyFields := withExtraFields(userStoryFields, cfg.ExtraFields)
+		workItemIds := iterationWorkItemIds(workItemsResponse)
+		summary.TotalItems = len(workItemIds)
+		var storyIds []int
+		for _, chunk := range chunkIds(workItemIds, maxWorkItemsPerCall) {
+			if ctx.Err() != nil {
+				log.Printf("Cliente desconectou durante o stream da sprint '%s'", sprintName)
+				return
+			}
+
+			chunk := chunk
+			workItems, err := witClient.GetWorkItems(ctx, workitemtracking.GetWorkItemsArgs{
+				Ids:         &chunk,
+				Fields:      &storyFields,
+				Project:     &cfg.Project,
+				ErrorPolicy: &omitMissingWorkItems,
+			})
+			summary.Chunks++
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				err = wrapAdoError(err, "GetWorkItems", "ids=%d..%d", chunk[0], chunk[len(chunk)-1])
+				log.Printf("Erro ao buscar bloco de work items: %v", err)
+				summary.Warnings = append(summary.Warnings, err.Error())
+				continue
+			}
+			summary.Warnings = append(summary.Warnings, missingWorkItemWarnings(missingWorkItemIds(chunk, workItems))...)
+
+			for _, detail := range presentWorkItems(chunk, workItems) {
+				// Filtra o estado antes de interpretar datas, evitando logs de itens descartados
+				if !keepState(getFieldValue(detail.Fields, "System.State")) || !keepArea(getFieldValue(detail.Fields, "System.AreaPath")) {
+					continue
+				}
+				item, ok := buildUserStory(detail, cfg, types, backlogRanks)
+				if !ok || (item.Removed && !includeRemoved) {
+					continue
+				}
+				if !send(userStoryStreamLine{Kind: "story", Story: &item}) {
+					return
+				}
+				summary.Stories++
+				storyIds = append(storyIds, item.ID)
+			}
+		}
+
+		// Rollups só depois de todas as User Stories, para o cliente já ter
+		// cada linha "story" quando o rollup do mesmo id chegar
+		if withRollup {
+			for _, chunk := range chunkIds(storyIds, maxWorkItemsPerCall) {
+				if ctx.Err() != nil {
+					log.Printf("Cliente desconectou durante o stream da sprint '%s'", sprintName)
+					return
+				}
+				tasksByParent, warnings, err := fetchTasksByParent(ctx, witClient, cfg, chunk, descriptionFormatText, false)
+				if err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+					log.Printf("Erro ao buscar tasks do bloco de User Stories: %v", err)
+					summary.Warnings = append(summary.Warnings, err.Error())
+					continue
+				}
+				summary.Warnings = append(summary.Warnings, warnings...)
+				for _, id := range chunk {
+					rollup := rollupTasks(id, tasksByParent[id])
+					if !send(userStoryStreamLine{Kind: "rollup", Rollup: &rollup}) {
+						return
+					}
+					summary.Rollups++
+				}
+			}
+		}
+
+		send(userStoryStreamLine{Kind: "summary", Summary: &summary})
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/work"
+	"github.com/microsoft/azure-devops-go-api/azuredevops/v7/workitemtracking"
+)
+
+// Função para montar uma User Story do fake com tipo, título e estado
+func fakeStory(id int, title, state string) workitemtracking.WorkItem {
+	return fakeWorkItem(id, map[string]interface{}{
+		"System.WorkItemType": "User Story",
+		"System.Title":        title,
+		"System.State":        state,
+	})
+}
+
+func TestIterationWorkItemIdsDeduplicates(t *testing.T) {
+	// O ADO devolve a User Story 1 como raiz e de novo como alvo de um link
+	relations := []workitemtracking.WorkItemLink{
+		fakeLink(0, 1),
+		fakeLink(0, 2),
+		fakeLink(1, 3),
+		fakeLink(2, 1),
+		fakeLink(0, 3),
+	}
+	got := iterationWorkItemIds(&work.IterationWorkItems{WorkItemRelations: &relations})
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("iterationWorkItemIds = %v, quer %v", got, want)
+	}
+	if got := iterationWorkItemIds(nil); len(got) != 0 {
+		t.Errorf("iterationWorkItemIds(nil) = %v", got)
+	}
+}
+
+func TestFetchSprintStoriesWithDuplicateRelations(t *testing.T) {
+	iteration := fakeIteration("Sprint 1", day(2024, 3, 4), day(2024, 3, 15), "")
+	workClient := &fakeWorkClient{
+		iterations: []work.TeamSettingsIteration{iteration},
+		relations: map[uuid.UUID][]workitemtracking.WorkItemLink{
+			*iteration.Id: {fakeLink(0, 10), fakeLink(10, 11), fakeLink(0, 11), fakeLink(0, 10)},
+		},
+	}
+	witClient := newFakeWitClient(fakeStory(10, "Login", "Active"), fakeStory(11, "Logout", "New"))
+	cfg := &Config{Project: "Projeto", Team: "Time"}
+
+	stories, _, _, err := fetchSprintStories(context.Background(), workClient, witClient, cfg, &iteration, []string{"User Story"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stories) != 2 || stories[0].ID != 10 || stories[1].ID != 11 {
+		t.Errorf("stories = %+v, quer #10 e #11 uma vez cada", stories)
+	}
+	if want := [][]int{{10, 11}}; !reflect.DeepEqual(witClient.getWorkItemsCalls, want) {
+		t.Errorf("GetWorkItems chamado com %v, quer %v", witClient.getWorkItemsCalls, want)
+	}
+}
+
+func TestFetchSprintStoriesReportsDeletedItems(t *testing.T) {
+	iteration := fakeIteration("Sprint 1", day(2024, 3, 4), day(2024, 3, 15), "")
+	workClient := &fakeWorkClient{
+		iterations: []work.TeamSettingsIteration{iteration},
+		relations: map[uuid.UUID][]workitemtracking.WorkItemLink{
+			*iteration.Id: {fakeLink(0, 10), fakeLink(0, 11), fakeLink(0, 12)},
+		},
+	}
+	// A 11 aparece na listagem da iteração, mas já não existe no GetWorkItems
+	witClient := newFakeWitClient(fakeStory(10, "Login", "Active"), fakeStory(12, "Perfil", "New"))
+	cfg := &Config{Project: "Projeto", Team: "Time"}
+
+	stories, details, missing, err := fetchSprintStories(context.Background(), workClient, witClient, cfg, &iteration, []string{"User Story"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stories) != 2 || len(details) != 2 {
+		t.Errorf("stories = %+v, quer #10 e #12", stories)
+	}
+	if !reflect.DeepEqual(missing, []int{11}) {
+		t.Errorf("missing = %v, quer [11]", missing)
+	}
+	warnings := missingWorkItemWarnings(missing)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "#11") {
+		t.Errorf("warnings = %v", warnings)
+	}
+	if warnings := missingWorkItemWarnings(nil); warnings == nil || len(warnings) != 0 {
+		t.Errorf("missingWorkItemWarnings(nil) = %#v, quer lista vazia", warnings)
+	}
+}
+
+func TestGetWorkItemsChunkedBoundaries(t *testing.T) {
+	tests := []struct {
+		count      int
+		wantChunks []int
+	}{
+		{count: 1, wantChunks: []int{1}},
+		{count: 200, wantChunks: []int{200}},
+		{count: 201, wantChunks: []int{200, 1}},
+		{count: 400, wantChunks: []int{200, 200}},
+		{count: 401, wantChunks: []int{200, 200, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.count), func(t *testing.T) {
+			ids := make([]int, tt.count)
+			witClient := newFakeWitClient()
+			for i := range ids {
+				ids[i] = 1000 + i
+				// Um item a cada 50 foi excluído e volta vazio, na mesma posição
+				if i%50 != 49 {
+					witClient.items[ids[i]] = fakeStory(ids[i], "Item", "New")
+				}
+			}
+
+			workItems, err := getWorkItemsChunked(context.Background(), witClient, "Projeto", ids, userStoryFields)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var sizes []int
+			for _, call := range witClient.getWorkItemsCalls {
+				if len(call) > maxWorkItemsPerCall {
+					t.Errorf("bloco com %d IDs, limite %d", len(call), maxWorkItemsPerCall)
+				}
+				sizes = append(sizes, len(call))
+			}
+			sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+			if !reflect.DeepEqual(sizes, tt.wantChunks) {
+				t.Errorf("blocos de %v IDs, quer %v", sizes, tt.wantChunks)
+			}
+			// O resultado mantém as posições dos IDs pedidos, como uma chamada única
+			if len(*workItems) != tt.count {
+				t.Fatalf("%d work items, quer %d", len(*workItems), tt.count)
+			}
+			for i, workItem := range *workItems {
+				if workItem.Id != nil && *workItem.Id != ids[i] {
+					t.Fatalf("posição %d tem #%d, quer #%d", i, *workItem.Id, ids[i])
+				}
+			}
+			if missing := missingWorkItemIds(ids, workItems); len(missing) != tt.count/50 {
+				t.Errorf("%d ausentes, quer %d", len(missing), tt.count/50)
+			}
+		})
+	}
+}
+
+func TestGetWorkItemsChunkedError(t *testing.T) {
+	ids := make([]int, 450)
+	for i := range ids {
+		ids[i] = i + 1
+	}
+	witClient := newFakeWitClient()
+	witClient.getWorkItems = func(ctx context.Context, args workitemtracking.GetWorkItemsArgs) (*[]workitemtracking.WorkItem, error) {
+		if (*args.Ids)[0] == 201 {
+			return nil, adoStatusError(503, "Service Unavailable")
+		}
+		result := make([]workitemtracking.WorkItem, len(*args.Ids))
+		return &result, nil
+	}
+	_, err := getWorkItemsChunked(context.Background(), witClient, "Projeto", ids, userStoryFields)
+	if !errors.Is(err, ErrAdoUnavailable) {
+		t.Fatalf("erro = %v, quer ErrAdoUnavailable", err)
+	}
+	if !strings.Contains(err.Error(), "ids=201..400") {
+		t.Errorf("erro sem o bloco que falhou: %v", err)
+	}
+}
+
+// Função para montar a sprint de /user-stories/stream: count User Stories a
+// partir do #1000; #1000 e #1001 têm tasks
+func streamFixture(count int) (*fakeWorkClient, *fakeWitClient, work.TeamSettingsIteration) {
+	iteration := fakeIteration("Sprint 1", day(2024, 3, 4), day(2024, 3, 15), "")
+	var links []workitemtracking.WorkItemLink
+	witClient := newFakeWitClient()
+	for i := 0; i < count; i++ {
+		links = append(links, fakeLink(0, 1000+i))
+		witClient.items[1000+i] = fakeStory(1000+i, "Item", "Active")
+	}
+	task := func(id, parent int, state string, remaining, completed interface{}) {
+		fields := map[string]interface{}{"System.WorkItemType": "Task", "System.Parent": float64(parent), "System.State": state}
+		if remaining != nil {
+			fields["Microsoft.VSTS.Scheduling.RemainingWork"] = remaining
+		}
+		if completed != nil {
+			fields["Microsoft.VSTS.Scheduling.CompletedWork"] = completed
+		}
+		witClient.items[id] = fakeWorkItem(id, fields)
+	}
+	task(5000, 1000, "Active", 3.0, 1.0)
+	task(5001, 1000, "Closed", nil, 2.0)
+	task(5002, 1001, "Active", nil, nil)
+	witClient.types = []string{"User Story", "Bug", "Task"}
+	witClient.queryByWiql = func(query string) []int {
+		parents := map[int]bool{}
+		if match := wiqlParentsPattern.FindStringSubmatch(query); match != nil {
+			for _, value := range strings.Split(match[1], ",") {
+				id, _ := strconv.Atoi(value)
+				parents[id] = true
+			}
+		}
+		var ids []int
+		for _, id := range []int{5000, 5001, 5002} {
+			if parents[int((*witClient.items[id].Fields)["System.Parent"].(float64))] {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+	workClient := &fakeWorkClient{
+		iterations: []work.TeamSettingsIteration{iteration},
+		relations:  map[uuid.UUID][]workitemtracking.WorkItemLink{*iteration.Id: links},
+	}
+	return workClient, witClient, iteration
+}
+
+// Função para servir o stream num httptest.Server; done fecha quando o handler retorna
+func serveStream(t *testing.T, handler http.HandlerFunc) (url string, done chan struct{}) {
+	t.Helper()
+	done = make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, done
+}
+
+func TestHandleUserStoriesStreamIncremental(t *testing.T) {
+	workClient, witClient, iteration := streamFixture(250)
+	// O segundo bloco só é resolvido depois que o cliente leu o primeiro:
+	// se as linhas não fossem enviadas a cada bloco, o teste travaria aqui
+	firstChunkRead := make(chan struct{})
+	witClient.getWorkItems = func(ctx context.Context, args workitemtracking.GetWorkItemsArgs) (*[]workitemtracking.WorkItem, error) {
+		if (*args.Ids)[0] == 1200 {
+			select {
+			case <-firstChunkRead:
+			case <-time.After(5 * time.Second):
+				return nil, adoStatusError(503, "primeiro bloco não chegou ao cliente")
+			}
+		}
+		return witClient.omitted(*args.Ids), nil
+	}
+	cfg := &Config{Project: "Projeto", Team: "Time", WorkItemTypes: []string{"User Story"}}
+	url, done := serveStream(t, handleUserStoriesStream(newFakePool(workClient, witClient), cfg))
+
+	response, err := http.Get(url + "/user-stories/stream?sprintId=" + iteration.Id.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("status = %d, Content-Type = %q", response.StatusCode, response.Header.Get("Content-Type"))
+	}
+
+	scanner := bufio.NewScanner(response.Body)
+	var kinds []string
+	stories := make(map[int]bool)
+	rollups := make(map[int]userStoryRollup)
+	var summary *userStoryStreamTotal
+	for scanner.Scan() {
+		var line userStoryStreamLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("linha inválida %q: %v", scanner.Text(), err)
+		}
+		if summary != nil {
+			t.Fatalf("linha %q depois do resumo", line.Kind)
+		}
+		kinds = append(kinds, line.Kind)
+		switch line.Kind {
+		case "story":
+			if len(rollups) > 0 {
+				t.Fatalf("story #%d depois de um rollup", line.Story.ID)
+			}
+			stories[line.Story.ID] = true
+			if len(stories) == maxWorkItemsPerCall {
+				close(firstChunkRead)
+			}
+		case "rollup":
+			if !stories[line.Rollup.ID] {
+				t.Fatalf("rollup de #%d antes da story", line.Rollup.ID)
+			}
+			rollups[line.Rollup.ID] = *line.Rollup
+		case "summary":
+			summary = line.Summary
+		default:
+			t.Fatalf("tipo de linha desconhecido: %q", line.Kind)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	if summary == nil {
+		t.Fatalf("stream sem resumo; tipos lidos: %d", len(kinds))
+	}
+	if summary.TotalItems != 250 || summary.Stories != 250 || summary.Rollups != 250 || summary.Chunks != 2 || len(summary.Warnings) != 0 {
+		t.Errorf("resumo = %+v", summary)
+	}
+	if want := (userStoryRollup{ID: 1000, Tasks: 2, OpenTasks: 1, RemainingWork: 3, CompletedWork: 3}); rollups[1000] != want {
+		t.Errorf("rollup #1000 = %+v, quer %+v", rollups[1000], want)
+	}
+	if want := (userStoryRollup{ID: 1001, Tasks: 1, OpenTasks: 1, UnestimatedTasks: 1}); rollups[1001] != want {
+		t.Errorf("rollup #1001 = %+v, quer %+v", rollups[1001], want)
+	}
+	if want := (userStoryRollup{ID: 1002}); rollups[1002] != want {
+		t.Errorf("rollup #1002 = %+v, quer %+v", rollups[1002], want)
+	}
+}
+
+func TestHandleUserStoriesStreamStopsOnDisconnect(t *testing.T) {
+	workClient, witClient, iteration := streamFixture(250)
+	// O segundo bloco só volta quando a requisição é cancelada
+	witClient.getWorkItems = func(ctx context.Context, args workitemtracking.GetWorkItemsArgs) (*[]workitemtracking.WorkItem, error) {
+		if (*args.Ids)[0] == 1200 {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return witClient.omitted(*args.Ids), nil
+	}
+	cfg := &Config{Project: "Projeto", Team: "Time", WorkItemTypes: []string{"User Story"}}
+	url, done := serveStream(t, handleUserStoriesStream(newFakePool(workClient, witClient), cfg))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, url+"/user-stories/stream?sprintId="+iteration.Id.String(), nil)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(response.Body)
+	if _, err := reader.ReadBytes('\n'); err != nil {
+		t.Fatalf("primeira linha: %v", err)
+	}
+	cancel()
+	response.Body.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler continuou depois que o cliente desconectou")
+	}
+	if len(witClient.wiqlQueries) != 0 {
+		t.Errorf("rollups buscados depois da desconexão: %v", witClient.wiqlQueries)
+	}
+}
+
+func TestHandleUserStoriesStreamRejectsUnknownTypes(t *testing.T) {
+	workClient, witClient, iteration := streamFixture(3)
+	cfg := &Config{Project: "Projeto", Team: "Time", WorkItemTypes: []string{"User Story"}}
+	recorder := httptest.NewRecorder()
+	handleUserStoriesStream(newFakePool(workClient, witClient), cfg)(recorder,
+		httptest.NewRequest(http.MethodGet, "/user-stories/stream?types=Epico&sprintId="+iteration.Id.String(), nil))
+	if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "Epico") {
+		t.Errorf("status = %d, corpo = %s; quer 400 citando o tipo", recorder.Code, recorder.Body.String())
+	}
+	if len(witClient.getWorkItemsCalls) != 0 {
+		t.Errorf("GetWorkItems chamado com tipo inválido: %v", witClient.getWorkItemsCalls)
+	}
+}
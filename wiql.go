@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Nomes de campo aceitos pelo builder (reference names como System.Parent ou
+// Custom.Centro_De_Custo). Qualquer outro texto não pode virar [campo].
+var wiqlFieldPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.]*$`)
+
+// wiqlQuery monta consultas WIQL sem interpolar texto livre: campos são
+// validados, textos são sempre literais com aspas escapadas e listas de IN
+// são montadas a partir de valores tipados.
+type wiqlQuery struct {
+	fields     []string
+	conditions []string
+	orderBy    []string
+	err        error
+}
+
+// Função para iniciar uma consulta WIQL sobre WorkItems com os campos informados
+func newWiqlQuery(fields ...string) *wiqlQuery {
+	q := &wiqlQuery{}
+	for _, field := range fields {
+		q.fields = append(q.fields, q.field(field))
+	}
+	return q
+}
+
+// Função para escapar um texto como literal WIQL, duplicando as aspas simples
+func wiqlString(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// Função para converter um valor Go em literal WIQL
+func (q *wiqlQuery) literal(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return wiqlString(v)
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		q.fail(fmt.Errorf("tipo não suportado em WIQL: %T", value))
+		return ""
+	}
+}
+
+func (q *wiqlQuery) field(name string) string {
+	if !wiqlFieldPattern.MatchString(name) {
+		q.fail(fmt.Errorf("nome de campo inválido em WIQL: %q", name))
+		return ""
+	}
+	return "[" + name + "]"
+}
+
+func (q *wiqlQuery) fail(err error) {
+	if q.err == nil {
+		q.err = err
+	}
+}
+
+// Função para adicionar uma condição [campo] <op> <valor>, unida por AND
+func (q *wiqlQuery) Where(field, op string, value interface{}) *wiqlQuery {
+	switch op {
+	case "=", "<>", "<", "<=", ">", ">=", "CONTAINS", "UNDER":
+	default:
+		q.fail(fmt.Errorf("operador não suportado em WIQL: %q", op))
+	}
+	q.conditions = append(q.conditions, fmt.Sprintf("%s %s %s", q.field(field), op, q.literal(value)))
+	return q
+}
+
+// Função para adicionar uma condição [campo] IN (...) com IDs numéricos
+func (q *wiqlQuery) WhereInInts(field string, values []int) *wiqlQuery {
+	if len(values) == 0 {
+		q.fail(fmt.Errorf("lista vazia em IN para o campo %s", field))
+		return q
+	}
+	items := make([]string, len(values))
+	for i, value := range values {
+		items[i] = strconv.Itoa(value)
+	}
+	q.conditions = append(q.conditions, fmt.Sprintf("%s IN (%s)", q.field(field), strings.Join(items, ",")))
+	return q
+}
+
+// Função para adicionar uma condição [campo] IN (...) com textos, cada um
+// escapado como literal
+func (q *wiqlQuery) WhereInStrings(field string, values []string) *wiqlQuery {
+	if len(values) == 0 {
+		q.fail(fmt.Errorf("lista vazia em IN para o campo %s", field))
+		return q
+	}
+	items := make([]string, len(values))
+	for i, value := range values {
+		items[i] = wiqlString(value)
+	}
+	q.conditions = append(q.conditions, fmt.Sprintf("%s IN (%s)", q.field(field), strings.Join(items, ",")))
+	return q
+}
+
+// Função para ordenar o resultado por [campo] (ASC ou DESC); chamadas
+// seguintes desempatam as anteriores
+func (q *wiqlQuery) OrderBy(field string, descending bool) *wiqlQuery {
+	direction := "ASC"
+	if descending {
+		direction = "DESC"
+	}
+	q.orderBy = append(q.orderBy, q.field(field)+" "+direction)
+	return q
+}
+
+// Função para gerar o texto final da consulta
+func (q *wiqlQuery) Build() (string, error) {
+	if q.err != nil {
+		return "", q.err
+	}
+	if len(q.fields) == 0 {
+		return "", fmt.Errorf("consulta WIQL sem campos")
+	}
+	var query strings.Builder
+	query.WriteString("SELECT ")
+	query.WriteString(strings.Join(q.fields, ", "))
+	query.WriteString(" FROM WorkItems")
+	if len(q.conditions) > 0 {
+		query.WriteString(" WHERE ")
+		query.WriteString(strings.Join(q.conditions, " AND "))
+	}
+	if len(q.orderBy) > 0 {
+		query.WriteString(" ORDER BY ")
+		query.WriteString(strings.Join(q.orderBy, ", "))
+	}
+	return query.String(), nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import "testing"
+
+func TestWiqlQueryEscaping(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func() *wiqlQuery
+		want  string
+	}{
+		{
+			name: "aspas simples em texto",
+			build: func() *wiqlQuery {
+				return newWiqlQuery("System.Id").Where("System.Title", "CONTAINS", "O'Brien's login")
+			},
+			want: "SELECT [System.Id] FROM WorkItems WHERE [System.Title] CONTAINS 'O''Brien''s login'",
+		},
+		{
+			name: "tentativa de fechar o literal",
+			build: func() *wiqlQuery {
+				return newWiqlQuery("System.Id").Where("System.Title", "=", "x' OR [System.Id] > 0 OR 'a'='a")
+			},
+			want: "SELECT [System.Id] FROM WorkItems WHERE [System.Title] = 'x'' OR [System.Id] > 0 OR ''a''=''a'",
+		},
+		{
+			name: "colchetes em texto continuam literais",
+			build: func() *wiqlQuery {
+				return newWiqlQuery("System.Id").Where("System.AreaPath", "UNDER", `Projeto\[Legado] Time`)
+			},
+			want: `SELECT [System.Id] FROM WorkItems WHERE [System.AreaPath] UNDER 'Projeto\[Legado] Time'`,
+		},
+		{
+			name: "lista de textos com aspas e colchetes",
+			build: func() *wiqlQuery {
+				return newWiqlQuery("System.Id", "System.Title").
+					WhereInStrings("System.WorkItemType", []string{"User Story", "Bug's", "[Epic]"}).
+					Where("System.Id", ">", 10)
+			},
+			want: "SELECT [System.Id], [System.Title] FROM WorkItems WHERE [System.WorkItemType] IN ('User Story','Bug''s','[Epic]') AND [System.Id] > 10",
+		},
+		{
+			name: "IDs numéricos e booleano",
+			build: func() *wiqlQuery {
+				return newWiqlQuery("System.Id").WhereInInts("System.Parent", []int{1, 2, 3}).Where("Custom.Flag", "=", true)
+			},
+			want: "SELECT [System.Id] FROM WorkItems WHERE [System.Parent] IN (1,2,3) AND [Custom.Flag] = true",
+		},
+		{
+			name: "ordenação",
+			build: func() *wiqlQuery {
+				return newWiqlQuery("System.Id").Where("System.Title", "CONTAINS", "login").
+					OrderBy("Microsoft.VSTS.Common.StackRank", false).OrderBy("System.Id", true)
+			},
+			want: "SELECT [System.Id] FROM WorkItems WHERE [System.Title] CONTAINS 'login' ORDER BY [Microsoft.VSTS.Common.StackRank] ASC, [System.Id] DESC",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.build().Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Build() =\n%s\nquer\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWiqlQueryRejectsUnsafeInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func() *wiqlQuery
+	}{
+		{name: "colchete no nome do campo", build: func() *wiqlQuery {
+			return newWiqlQuery("System.Id").Where("System.Title] = 'x' OR [System.Id", "=", "y")
+		}},
+		{name: "campo selecionado com colchete", build: func() *wiqlQuery { return newWiqlQuery("[System.Id]") }},
+		{name: "campo com espaço", build: func() *wiqlQuery { return newWiqlQuery("System Id") }},
+		{name: "operador desconhecido", build: func() *wiqlQuery { return newWiqlQuery("System.Id").Where("System.Id", "OR", 1) }},
+		{name: "tipo não suportado", build: func() *wiqlQuery { return newWiqlQuery("System.Id").Where("System.Id", "=", 1.5) }},
+		{name: "IN vazio", build: func() *wiqlQuery { return newWiqlQuery("System.Id").WhereInInts("System.Parent", nil) }},
+		{name: "sem campos", build: func() *wiqlQuery { return newWiqlQuery() }},
+		{name: "ordenação por campo inválido", build: func() *wiqlQuery { return newWiqlQuery("System.Id").OrderBy("System.Id DESC, [x]", false) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if query, err := tt.build().Build(); err == nil {
+				t.Errorf("Build() aceitou a consulta: %s", query)
+			}
+		})
+	}
+}